@@ -1,30 +1,613 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"srv.exe.dev/srv"
 )
 
-var flagListenAddr = flag.String("listen", ":8000", "address to listen on")
+var (
+	flagListenAddr     = flag.String("listen", ":8000", "address to listen on")
+	flagOCRURL         = flag.String("ocr-url", "", "optional OCR service endpoint for unrecognized images")
+	flagAckConfig      = flag.String("ack-config", "", "optional JSON file with per-endpoint/per-camera ingest acknowledgments")
+	flagCompat         = flag.String("compat-config", "", "optional JSON file with legacy vendor receiver paths and responses")
+	flagMappings       = flag.String("mapping-config", "", "optional JSON file with field-mapping rules for payloads of other cameras")
+	flagQuotas         = flag.String("quota-config", "", "optional JSON file with image storage quotas per camera and in total")
+	flagPrivacy        = flag.String("privacy-config", "", "optional JSON file with an anonymization policy (hash or strip plates and delete images after N days)")
+	flagMQTT           = flag.String("mqtt-config", "", "optional JSON file with an MQTT broker URL, topic filters, credentials and TLS files to ingest camera events from and publish stored events to")
+	flagGenetec        = flag.String("genetec-config", "", "optional JSON file with a Genetec Security Center LPR endpoint and credentials to forward stored reads and their images to")
+	flagMilestone      = flag.String("milestone-config", "", "optional JSON file with a Milestone XProtect Event Server address and analytics event name to send each stored read to")
+	flagInbox          = flag.String("inbox-config", "", "optional JSON file with an inbox directory to ingest camera JSON and image file drops from, and an embedded FTP server writing to it")
+	flagSnapshots      = flag.String("snapshot-config", "", "optional JSON file with per-camera overview camera snapshot URLs (http(s) or rtsp via ffmpeg) grabbed as a scene image for each new read")
+	flagVIN            = flag.String("vin-config", "", "optional JSON file with manufacturer (WMI) and model patterns added to the built-in VIN decoding tables")
+	flagDemo           = flag.Bool("demo", false, "seed an empty database with synthetic cameras, reads with images, archives, compare results and a watchlist to demo the dashboard and exports without cameras")
+	flagPlateMatch     = flag.String("plate-match-config", "", "optional JSON file with the edit distance and character substitution costs under which a plate read differing from the true plate counts as partially correct in the compare workflow")
+	flagCCTV           = flag.String("cctv-config", "", "optional JSON file with per-camera NVR playback URL templates linking events to recorded video of co-located CCTV cameras")
+	flagCalendar       = flag.String("calendar-config", "", "optional JSON file with the site's working hours and holidays, splitting traffic analytics into in and out of hours and pausing camera offline alerting while closed")
+	flagEmail          = flag.String("email-config", "", "optional JSON file with an SMTP server and the alerts emailed through it: watchlist plates (with the plate crop attached) and the ingest error rate")
+	flagExportSchedule = flag.String("export-schedule-config", "", "optional JSON file with exports of the current session (CSV, XLSX or ZIP) written on a schedule to a directory, SFTP server or S3 bucket")
+	flagPanels         = flag.String("panels-config", "", "optional JSON file with custom dashboard panels (saved read-only SQL queries)")
+	flagUpdateURL      = flag.String("update-url", "", "optional release server URL returning the latest version as JSON")
+	flagCameraTZ       = flag.String("camera-tz", "", "time zone of camera timestamps without an offset, e.g. UTC or Europe/Berlin (default: local)")
+	flagPublicURL      = flag.String("public-url", "", "external URL of this server for links in exports, e.g. https://lpr.example.com (default: from the request)")
+	flagNodeID         = flag.String("node-id", "", "site/node identifier stamped on every event (default: hostname)")
+	flagDBPath         = flag.String("db", "db.sqlite3", "path to the SQLite database")
+	flagReplica        = flag.String("replica", "", "optional replica destination (directory or s3://bucket/prefix) for warm standby snapshots")
+	flagBlobStore      = flag.String("blob-store", "", "optional store for camera JSON and images (directory or s3://bucket/prefix?endpoint=.. for MinIO); the database then keeps only their keys")
+	flagBISnapshot     = flag.String("bi-snapshot", "", "optional path of a read-only database copy for BI tools (no images or credentials), rewritten every -bi-snapshot-interval")
+	flagSigningKey     = flag.String("signing-key", "", "optional Ed25519 private key (PEM PKCS#8) for signing exported files; created if missing")
+	flagPushKey        = flag.String("push-key", "", "optional ECDSA P-256 private key (PEM PKCS#8) identifying this server to browser push services, enabling notifications to reviewers; created if missing")
+	flagPushMail       = flag.String("push-contact", "", "mailto: or https: URL push services can reach the operator at (default: mailto:mmrapi@hostname)")
+	flagTLSCert        = flag.String("tls-cert", "", "serve HTTPS with this PEM certificate chain (re-read when it changes); needs -tls-key")
+	flagTLSKey         = flag.String("tls-key", "", "PEM private key for -tls-cert")
+	flagAutocert       = flag.Bool("autocert", false, "serve HTTPS with a Let's Encrypt certificate for the -public-url host (default: hostname)")
+	flagACMEEmail      = flag.String("autocert-email", "", "optional contact email for the Let's Encrypt account")
+	flagACMEDir        = flag.String("autocert-dir", "", "directory for Let's Encrypt keys and certificates (default: data/autocert)")
+	flagJSONFields     = flag.String("json-fields", strings.Join(srv.DefaultJSONFields, ","), "comma-separated multipart form field names read as event JSON, besides .json files and application/json parts")
+	flagHTTPListen     = flag.String("http-listen", "", "with HTTPS, also listen for plain HTTP on this address (e.g. :80) for ACME challenges and redirects to HTTPS")
+	flagNoJournal      = flag.Bool("no-journal", false, "don't journal ingest requests to disk before processing (faster, not crash-safe)")
+	flagReadOnly       = flag.Bool("read-only", false, "serve a copied database for review without accepting ingest or mutations")
+	flagAPIKey         = flag.Bool("require-api-key", false, "reject ingest without an enabled API key (keys are managed on /api-keys)")
+	flagLogin          = flag.Bool("require-login", false, "require a signed-in user for the dashboard and APIs other than ingest (users are managed with `srv user`)")
+	flagVersion        = flag.Bool("version", false, "print version and exit")
+
+	defaultHTTP           = srv.DefaultHTTPConfig()
+	flagReadHeaderTimeout = flag.Duration("read-header-timeout", defaultHTTP.ReadHeaderTimeout, "max time to read request headers (0 = none)")
+	flagReadTimeout       = flag.Duration("read-timeout", defaultHTTP.ReadTimeout, "max time to read a whole request including the body (0 = none)")
+	flagWriteTimeout      = flag.Duration("write-timeout", defaultHTTP.WriteTimeout, "max time to write a response (0 = none)")
+	flagIdleTimeout       = flag.Duration("idle-timeout", defaultHTTP.IdleTimeout, "close idle keep-alive connections after this long (0 = none)")
+	flagMaxHeaderBytes    = flag.Int("max-header-bytes", defaultHTTP.MaxHeaderBytes, "max size of request headers in bytes")
+	flagNoKeepAlive       = flag.Bool("no-keepalive", false, "close every connection after one request")
+	flagDrainTimeout      = flag.Duration("drain-timeout", defaultHTTP.DrainTimeout, "on SIGINT/SIGTERM, wait this long for in-flight requests before closing connections")
+	flagLateAfter         = flag.Duration("late-after", 2*time.Minute, "flag events received this long after their capture time (0 = never)")
+	flagTrashTTL          = flag.Duration("trash-retention", srv.DefaultTrashTTL, "purge deleted archives after this long in the trash unless under legal hold (0 = keep until purged on /trash)")
+	flagSessionTTL        = flag.Duration("session-ttl", srv.DefaultSessionTTL, "log users out after this long without a request")
+	flagMergeWindow       = flag.Duration("merge-window", srv.DefaultMergeWindow, "merge carState update/lost messages into the event of the same car and camera received this recently (0 = store every message as an event)")
+	flagGzipLimit         = flag.Int64("gzip-limit", srv.DefaultGzipLimit, "largest size in bytes a gzip-encoded ingest body may decompress to")
+	flagUploadMemory      = flag.Int64("upload-memory", srv.DefaultUploadMemory, "upload bytes held in memory across concurrent ingest requests; larger parts are spooled to the data dir and requests beyond it wait")
+	flagDedupWindow       = flag.Duration("dedup-window", srv.DefaultDedupWindow, "treat a message resent this soon after the original (same camera, carID and packetCounter, else identical JSON) as a duplicate (0 = store every message)")
+	flagDedupLink         = flag.Bool("dedup-link", false, "store duplicates as events linked to the original instead of ignoring them")
+	flagRollupEvery       = flag.Duration("rollup-interval", srv.DefaultRollupEvery, "bring the hourly camera and archive accuracy rollups behind /api/analytics up to date this often (0 = never)")
+	flagThumbWidth        = flag.Int("thumb-width", srv.DefaultThumbWidth, "width of thumbnails made when images are stored and served by /image/{id}/thumb without ?w= (0 = make them on first request, 160 px wide)")
+	flagIdleGap           = flag.Duration("idle-gap", srv.DefaultIdleGap, "suggest archiving the current session up to a traffic gap at least this long on the dashboard (0 = don't)")
+	flagChunkTimeout      = flag.Duration("chunk-timeout", srv.DefaultChunkTimeout, "wait this long for the rest of a BinaryImage split across messages before storing the event without it (0 = don't reassemble)")
+	flagReplicaInterval   = flag.Duration("replica-interval", 5*time.Minute, "how often to replicate the database and data dir")
+	flagReplicaKeep       = flag.Int("replica-keep", 3, "number of database snapshots to keep in the replica")
+	flagOfflineAfter      = flag.Duration("camera-offline-after", 0, "report a camera that sent reads in the last week as offline after this long without one, counting only working time with -calendar-config (0 = never)")
+	flagAccuracyDelta     = flag.Float64("accuracy-alarm-delta", 0, "alarm when a camera's plate, make, model or color accuracy in a reviewed archive is more than this many percentage points below its previous archives (0 = never)")
+	flagBIInterval        = flag.Duration("bi-snapshot-interval", time.Hour, "how often to rewrite the BI snapshot")
+
+	defaultExportImages    = srv.DefaultExportImageConfig()
+	flagExportPlateScale   = flag.Float64("export-plate-scale", defaultExportImages.PlateScale, "scale of plate crops embedded into XLSX exports")
+	flagExportVehicleScale = flag.Float64("export-vehicle-scale", defaultExportImages.VehicleScale, "scale of vehicle images embedded into XLSX exports")
+	flagExportMaxPixels    = flag.Int("export-max-px", defaultExportImages.MaxPixels, "downscale images embedded into XLSX exports to this many pixels on the longest side (0 = keep originals)")
+	flagExportWorkers      = flag.Int("export-workers", defaultExportImages.Workers, "rows whose images are loaded and downscaled in parallel during XLSX exports (default: one per CPU, at most 8)")
+)
 
 func main() {
-	if err := run(); err != nil {
+	var err error
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "restore":
+		err = runRestore(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "user":
+		err = runUser(os.Args[2:])
+	case len(os.Args) > 1 && os.Args[1] == "config":
+		err = runConfig(os.Args[2:])
+	default:
+		err = run()
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 	}
 }
 
+// runRestore rebuilds the database and data dir from a replica:
+//
+//	srv restore -replica /mnt/backup [-db db.sqlite3] [-snapshot 20260102T150405Z.sqlite3.gz]
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dest := fs.String("replica", "", "replica to restore from (directory or s3://bucket/prefix)")
+	dbPath := fs.String("db", "db.sqlite3", "database path to restore to; must not exist")
+	snapshot := fs.String("snapshot", "", "snapshot to restore (default: newest)")
+	fs.Parse(args)
+	if *dest == "" {
+		return fmt.Errorf("restore: -replica is required")
+	}
+
+	ctx := context.Background()
+	name, err := srv.RestoreReplica(ctx, *dest, *snapshot, *dbPath)
+	if err != nil {
+		return fmt.Errorf("restore database: %w", err)
+	}
+	fmt.Printf("restored %s to %s\n", name, *dbPath)
+
+	// Opening the server migrates the restored database and locates the data dir
+	server, err := srv.New(*dbPath, "restore")
+	if err != nil {
+		return fmt.Errorf("open restored database: %w", err)
+	}
+	defer server.DB.Close()
+	n, err := srv.RestoreReplicaData(ctx, *dest, server.DataDir)
+	if err != nil {
+		return fmt.Errorf("restore data files: %w", err)
+	}
+	fmt.Printf("restored %d data files to %s\n", n, server.DataDir)
+	return nil
+}
+
+// runUser manages the users who can log in with -require-login:
+//
+//	srv user [-db db.sqlite3] add|passwd|delete NAME
+//	srv user [-db db.sqlite3] list
+//
+// add and passwd read the password from the first line of stdin.
+func runUser(args []string) error {
+	fs := flag.NewFlagSet("user", flag.ExitOnError)
+	dbPath := fs.String("db", "db.sqlite3", "path to the SQLite database")
+	fs.Parse(args)
+	cmd, name := fs.Arg(0), fs.Arg(1)
+	if cmd == "" || (cmd != "list" && name == "") {
+		return fmt.Errorf("usage: srv user [-db path] add|passwd|delete NAME, or srv user list")
+	}
+
+	server, err := srv.New(*dbPath, "user")
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer server.DB.Close()
+	ctx := context.Background()
+
+	switch cmd {
+	case "add", "passwd":
+		fmt.Fprint(os.Stderr, "Password: ")
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && password == "" {
+			return fmt.Errorf("read password: %w", err)
+		}
+		password = strings.TrimRight(password, "\r\n")
+		if cmd == "add" {
+			err = server.AddUser(ctx, name, password)
+		} else {
+			err = server.SetPassword(ctx, name, password)
+		}
+		if err != nil {
+			return fmt.Errorf("%s user: %w", cmd, err)
+		}
+		fmt.Printf("user %s saved\n", name)
+	case "delete":
+		if err := server.DeleteUser(ctx, name); err != nil {
+			return fmt.Errorf("delete user: %w", err)
+		}
+		fmt.Printf("user %s deleted\n", name)
+	case "list":
+		users, err := server.Users(ctx)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			last := "never"
+			if u.LastLoginAt != nil {
+				last = u.LastLoginAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\tcreated %s\tlast login %s\n", u.Username, u.CreatedAt.Format(time.RFC3339), last)
+		}
+	default:
+		return fmt.Errorf("unknown user command %q", cmd)
+	}
+	return nil
+}
+
+// runConfig moves a site's configuration (settings, config files, users, API
+// keys, test vehicles and watchlists) to another box as a YAML bundle:
+//
+//	srv config export [server flags] > site.yaml
+//	srv config import [-db db.sqlite3] [-dir .] site.yaml
+//
+// export takes the command line the server runs with. import adds what the
+// database is missing, writes the config files to -dir and prints the
+// command line to start the server with.
+func runConfig(args []string) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		return fmt.Errorf("usage: srv config export [server flags], or srv config import [-db path] [-dir path] FILE")
+	}
+	ctx := context.Background()
+
+	if args[0] == "export" {
+		flag.CommandLine.Parse(args[1:])
+		if flag.NArg() > 0 {
+			return fmt.Errorf("config export: unexpected argument %q", flag.Arg(0))
+		}
+		server, err := srv.New(*flagDBPath, "config")
+		if err != nil {
+			return fmt.Errorf("open database: %w", err)
+		}
+		defer server.DB.Close()
+		if *flagNodeID != "" {
+			server.NodeID = *flagNodeID
+		} else if hostname, err := os.Hostname(); err == nil {
+			server.NodeID = hostname
+		}
+		flags := map[string]string{}
+		flag.Visit(func(f *flag.Flag) { flags[f.Name] = f.Value.String() })
+		return server.ExportConfig(ctx, os.Stdout, flags)
+	}
+
+	fs := flag.NewFlagSet("config import", flag.ExitOnError)
+	dbPath := fs.String("db", "db.sqlite3", "path to the SQLite database")
+	dir := fs.String("dir", ".", "directory to write the config files to")
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: srv config import [-db path] [-dir path] FILE")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	server, err := srv.New(*dbPath, "config")
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer server.DB.Close()
+	res, err := server.ImportConfig(ctx, f, *dir)
+	if err != nil {
+		return fmt.Errorf("import config: %w", err)
+	}
+	for _, path := range res.Files {
+		fmt.Printf("wrote %s\n", path)
+	}
+	fmt.Printf("added %d users, %d API keys and %d watchlists, saved %d test vehicles\n", res.Users, res.APIKeys, res.Watchlists, res.TestVehicles)
+	fmt.Printf("start with:\n  srv -db %s %s\n", *dbPath, strings.Join(res.Args, " "))
+	return nil
+}
+
 func run() error {
 	flag.Parse()
+	if *flagVersion {
+		fmt.Println(srv.Version, srv.Commit, srv.BuildDate)
+		return nil
+	}
 	hostname, err := os.Hostname()
 	if err != nil {
 		hostname = "unknown"
 	}
-	server, err := srv.New("db.sqlite3", hostname)
+	server, err := srv.New(*flagDBPath, hostname)
 	if err != nil {
 		return fmt.Errorf("create server: %w", err)
 	}
-	return server.Serve(*flagListenAddr)
+	defer server.Close()
+	server.Journal = !*flagNoJournal
+	server.RequireAPIKey = *flagAPIKey
+	server.RequireLogin = *flagLogin
+	if *flagSessionTTL <= 0 {
+		return fmt.Errorf("session-ttl must be positive")
+	}
+	server.SessionTTL = *flagSessionTTL
+	server.TrashTTL = *flagTrashTTL
+	server.LateAfter = *flagLateAfter
+	server.MergeWindow = *flagMergeWindow
+	server.ChunkTimeout = *flagChunkTimeout
+	server.IdleGap = *flagIdleGap
+	server.GzipLimit = *flagGzipLimit
+	if *flagUploadMemory < 1<<20 {
+		return fmt.Errorf("upload-memory must be at least 1 MiB")
+	}
+	server.UploadMemory = *flagUploadMemory
+	server.DedupWindow = *flagDedupWindow
+	server.DedupLink = *flagDedupLink
+	if w := *flagThumbWidth; w != 0 && (w < 16 || w > 640) {
+		return fmt.Errorf("thumb-width must be 0 or 16-640")
+	}
+	server.ThumbWidth = *flagThumbWidth
+	if *flagRollupEvery < 0 {
+		return fmt.Errorf("rollup-interval must not be negative")
+	}
+	server.RollupEvery = *flagRollupEvery
+	if *flagOfflineAfter < 0 {
+		return fmt.Errorf("camera-offline-after must not be negative")
+	}
+	server.OfflineAfter = *flagOfflineAfter
+	if *flagAccuracyDelta < 0 || *flagAccuracyDelta >= 100 {
+		return fmt.Errorf("accuracy-alarm-delta must be 0-100")
+	}
+	server.AccuracyAlarmDelta = *flagAccuracyDelta
+	for _, f := range strings.Split(*flagJSONFields, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			server.JSONFields = append(server.JSONFields, f)
+		}
+	}
+	server.ExportImages = srv.ExportImageConfig{
+		PlateScale:   *flagExportPlateScale,
+		VehicleScale: *flagExportVehicleScale,
+		MaxPixels:    *flagExportMaxPixels,
+		Workers:      *flagExportWorkers,
+	}
+	if err := server.ExportImages.Validate(); err != nil {
+		return fmt.Errorf("export images: %w", err)
+	}
+	if *flagReadOnly {
+		if *flagReplica != "" {
+			return fmt.Errorf("-replica can't be used with -read-only")
+		}
+		if *flagLogin {
+			return fmt.Errorf("-require-login can't be used with -read-only")
+		}
+		if *flagBISnapshot != "" {
+			return fmt.Errorf("-bi-snapshot can't be used with -read-only; point BI tools at the copy itself")
+		}
+		if *flagMQTT != "" {
+			return fmt.Errorf("-mqtt-config can't be used with -read-only")
+		}
+		if *flagExportSchedule != "" {
+			return fmt.Errorf("-export-schedule-config can't be used with -read-only")
+		}
+		if *flagGenetec != "" {
+			return fmt.Errorf("-genetec-config can't be used with -read-only")
+		}
+		if *flagMilestone != "" {
+			return fmt.Errorf("-milestone-config can't be used with -read-only")
+		}
+		if *flagInbox != "" {
+			return fmt.Errorf("-inbox-config can't be used with -read-only")
+		}
+		if *flagSnapshots != "" {
+			return fmt.Errorf("-snapshot-config can't be used with -read-only")
+		}
+		if *flagPushKey != "" {
+			return fmt.Errorf("-push-key can't be used with -read-only")
+		}
+		if *flagDemo {
+			return fmt.Errorf("-demo can't be used with -read-only")
+		}
+		if err := server.SetReadOnly(*flagDBPath); err != nil {
+			return err
+		}
+	}
+	if *flagNodeID != "" {
+		server.NodeID = *flagNodeID
+	}
+	if *flagPublicURL != "" {
+		u, err := url.Parse(*flagPublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("public-url must be an http(s) URL")
+		}
+		server.PublicURL = *flagPublicURL
+	}
+	if *flagCameraTZ != "" {
+		loc, err := time.LoadLocation(*flagCameraTZ)
+		if err != nil {
+			return fmt.Errorf("camera-tz: %w", err)
+		}
+		server.CameraTZ = loc
+	}
+	if *flagOCRURL != "" {
+		server.OCR = srv.NewHTTPOCR(*flagOCRURL)
+	}
+	if *flagCompat != "" {
+		compat, err := srv.LoadCompatConfig(*flagCompat)
+		if err != nil {
+			return fmt.Errorf("load compat config: %w", err)
+		}
+		server.Compat = compat
+	}
+	if *flagMappings != "" {
+		mappings, err := srv.LoadMappingConfig(*flagMappings)
+		if err != nil {
+			return fmt.Errorf("load mapping config: %w", err)
+		}
+		server.Mappings = mappings
+	}
+	if *flagCCTV != "" {
+		cctv, err := srv.LoadCCTVConfig(*flagCCTV)
+		if err != nil {
+			return fmt.Errorf("load cctv config: %w", err)
+		}
+		server.CCTV = cctv
+	}
+	if *flagVIN != "" {
+		vin, err := srv.LoadVINConfig(*flagVIN)
+		if err != nil {
+			return fmt.Errorf("load vin config: %w", err)
+		}
+		server.VIN = vin
+	}
+	if *flagPlateMatch != "" {
+		plateMatch, err := srv.LoadPlateMatchConfig(*flagPlateMatch)
+		if err != nil {
+			return fmt.Errorf("load plate match config: %w", err)
+		}
+		server.PlateMatch = plateMatch
+	}
+	if *flagCalendar != "" {
+		calendar, err := srv.LoadSiteCalendar(*flagCalendar)
+		if err != nil {
+			return fmt.Errorf("load calendar config: %w", err)
+		}
+		server.Calendar = calendar
+	}
+	if *flagEmail != "" {
+		email, err := srv.LoadEmailConfig(*flagEmail)
+		if err != nil {
+			return fmt.Errorf("load email config: %w", err)
+		}
+		server.Email = email
+		if !*flagReadOnly {
+			if err := server.ImportEmailWatchlist(context.Background()); err != nil {
+				return fmt.Errorf("email config watchlist: %w", err)
+			}
+		}
+	}
+	if *flagExportSchedule != "" {
+		schedule, err := srv.LoadExportScheduleConfig(*flagExportSchedule)
+		if err != nil {
+			return fmt.Errorf("load export schedule config: %w", err)
+		}
+		server.ExportSchedule = schedule
+	}
+	if *flagSnapshots != "" {
+		snapshots, err := srv.LoadSnapshotConfig(*flagSnapshots)
+		if err != nil {
+			return fmt.Errorf("load snapshot config: %w", err)
+		}
+		server.Snapshots = snapshots
+	}
+	if *flagSigningKey != "" {
+		signer, err := srv.LoadExportSigner(*flagSigningKey)
+		if err != nil {
+			return fmt.Errorf("load signing key: %w", err)
+		}
+		server.Signer = signer
+	}
+	if *flagPushKey != "" {
+		push, err := srv.LoadPushSender(*flagPushKey, *flagPushMail)
+		if err != nil {
+			return fmt.Errorf("load push key: %w", err)
+		}
+		server.Push = push
+	}
+	if *flagQuotas != "" {
+		quotas, err := srv.LoadQuotaConfig(*flagQuotas)
+		if err != nil {
+			return fmt.Errorf("load quota config: %w", err)
+		}
+		server.Quotas = quotas
+	}
+	if *flagPrivacy != "" {
+		privacy, err := srv.LoadPrivacyConfig(*flagPrivacy)
+		if err != nil {
+			return fmt.Errorf("load privacy config: %w", err)
+		}
+		server.Privacy = privacy
+	}
+	if *flagMQTT != "" {
+		mqtt, err := srv.LoadMQTTConfig(*flagMQTT)
+		if err != nil {
+			return fmt.Errorf("load mqtt config: %w", err)
+		}
+		server.MQTT = mqtt
+	}
+	if *flagGenetec != "" {
+		genetec, err := srv.LoadGenetecConfig(*flagGenetec)
+		if err != nil {
+			return fmt.Errorf("load genetec config: %w", err)
+		}
+		server.Genetec = genetec
+	}
+	if *flagMilestone != "" {
+		milestone, err := srv.LoadMilestoneConfig(*flagMilestone)
+		if err != nil {
+			return fmt.Errorf("load milestone config: %w", err)
+		}
+		server.Milestone = milestone
+	}
+	if *flagInbox != "" {
+		inbox, err := srv.LoadInboxConfig(*flagInbox)
+		if err != nil {
+			return fmt.Errorf("load inbox config: %w", err)
+		}
+		server.Inbox = inbox
+	}
+	if *flagPanels != "" {
+		panels, err := srv.LoadPanelConfig(*flagPanels)
+		if err != nil {
+			return fmt.Errorf("load panels config: %w", err)
+		}
+		if err := panels.Open(*flagDBPath); err != nil {
+			return fmt.Errorf("open panels: %w", err)
+		}
+		server.Panels = panels
+	}
+	if *flagBlobStore != "" {
+		blobs, err := srv.OpenBlobStore(*flagBlobStore)
+		if err != nil {
+			return fmt.Errorf("blob store: %w", err)
+		}
+		server.Blobs = blobs
+	}
+	if *flagReplica != "" {
+		replica, err := srv.NewReplicator(*flagReplica, *flagDBPath)
+		if err != nil {
+			return fmt.Errorf("replica: %w", err)
+		}
+		replica.Interval = *flagReplicaInterval
+		replica.Keep = *flagReplicaKeep
+		server.Replica = replica
+	}
+	if *flagBISnapshot != "" {
+		if filepath.Clean(*flagBISnapshot) == filepath.Clean(*flagDBPath) {
+			return fmt.Errorf("-bi-snapshot must not be the database itself")
+		}
+		if *flagBIInterval <= 0 {
+			return fmt.Errorf("bi-snapshot-interval must be positive")
+		}
+		bi := srv.NewBISnapshot(*flagBISnapshot)
+		bi.Interval = *flagBIInterval
+		server.BI = bi
+	}
+	if *flagUpdateURL != "" {
+		server.Updates = srv.NewUpdateChecker(*flagUpdateURL)
+	}
+	if *flagAckConfig != "" {
+		acks, err := srv.LoadAckConfig(*flagAckConfig)
+		if err != nil {
+			return fmt.Errorf("load ack config: %w", err)
+		}
+		server.Acks = acks
+	}
+	if *flagDemo {
+		summary, err := server.SeedDemo(context.Background(), time.Now())
+		if err != nil {
+			return fmt.Errorf("seed demo data: %w", err)
+		}
+		if summary.Skipped {
+			fmt.Println("demo: the database already has events, nothing seeded")
+		}
+		server.Demo = true
+	}
+	server.HTTP = srv.HTTPConfig{
+		ReadHeaderTimeout: *flagReadHeaderTimeout,
+		ReadTimeout:       *flagReadTimeout,
+		WriteTimeout:      *flagWriteTimeout,
+		IdleTimeout:       *flagIdleTimeout,
+		MaxHeaderBytes:    *flagMaxHeaderBytes,
+		DisableKeepAlives: *flagNoKeepAlive,
+		DrainTimeout:      *flagDrainTimeout,
+	}
+	if *flagTLSCert != "" || *flagTLSKey != "" || *flagAutocert {
+		switch {
+		case *flagAutocert && *flagTLSCert != "":
+			return fmt.Errorf("-autocert and -tls-cert can't be used together")
+		case !*flagAutocert && (*flagTLSCert == "" || *flagTLSKey == ""):
+			return fmt.Errorf("-tls-cert and -tls-key must be given together")
+		}
+		server.TLS = &srv.TLSConfig{
+			CertFile: *flagTLSCert,
+			KeyFile:  *flagTLSKey,
+			Autocert: *flagAutocert,
+			Email:    *flagACMEEmail,
+			CacheDir: *flagACMEDir,
+			HTTPAddr: *flagHTTPListen,
+		}
+	} else if *flagHTTPListen != "" {
+		return fmt.Errorf("-http-listen needs -tls-cert or -autocert")
+	}
+
+	// The first signal shuts down gracefully; a second one kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return server.Serve(ctx, *flagListenAddr)
 }
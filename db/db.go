@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"regexp"
 	"sort"
 	"strconv"
@@ -18,9 +19,24 @@ import (
 //go:embed migrations/*.sql
 var migrationFS embed.FS
 
+// dsn builds the connection string of the database file at path with the
+// given driver parameters, which apply to every pooled connection. The path
+// is escaped, so names with ?, # or % open the right file.
+func dsn(path string, params url.Values) string {
+	return (&url.URL{Scheme: "file", Path: path, RawQuery: params.Encode()}).String()
+}
+
 // Open opens an sqlite database and prepares pragmas suitable for a small web app.
 func Open(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", path)
+	// busy_timeout goes in the DSN so every pooled connection waits for the
+	// write lock; background writers otherwise fail with SQLITE_BUSY while
+	// an ingest is writing. Transactions take the write lock when they
+	// begin: one that read first could not wait for it once another
+	// connection had written, and would fail with SQLITE_BUSY instead.
+	db, err := sql.Open("sqlite", dsn(path, url.Values{
+		"_pragma": {"busy_timeout(5000)"},
+		"_txlock": {"immediate"},
+	}))
 	if err != nil {
 		return nil, err
 	}
@@ -33,9 +49,19 @@ func Open(path string) (*sql.DB, error) {
 		_ = db.Close()
 		return nil, fmt.Errorf("set WAL: %w", err)
 	}
-	if _, err := db.Exec("PRAGMA busy_timeout=5000;"); err != nil {
+	return db, nil
+}
+
+// OpenReadOnly opens an sqlite database with query_only set on every
+// connection, so any write fails with SQLITE_READONLY.
+func OpenReadOnly(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn(path, url.Values{"_pragma": {"query_only(1)", "foreign_keys(1)", "busy_timeout(5000)"}}))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
 		_ = db.Close()
-		return nil, fmt.Errorf("set busy_timeout: %w", err)
+		return nil, fmt.Errorf("open read-only: %w", err)
 	}
 	return db, nil
 }
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenEscapesPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "site #1 100%?")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "db.sqlite3")
+	sqlDB, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RunMigrations(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+	var timeout int
+	if err := sqlDB.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil || timeout != 5000 {
+		t.Errorf("busy_timeout = %d, %v", timeout, err)
+	}
+	sqlDB.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("database not created at %q: %v", path, err)
+	}
+
+	ro, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if _, err := ro.Exec("CREATE TABLE t (x)"); err == nil {
+		t.Error("write succeeded on a read-only database")
+	}
+}
+
+func TestTransactionsWaitForWriters(t *testing.T) {
+	sqlDB, err := Open(filepath.Join(t.TempDir(), "db.sqlite3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	if _, err := sqlDB.Exec("CREATE TABLE t (x INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A transaction that reads before it writes, while another connection
+	// writes in between
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM t").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	written := make(chan error, 1)
+	go func() {
+		_, err := sqlDB.Exec("INSERT INTO t VALUES (1)")
+		written <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := tx.Exec("INSERT INTO t VALUES (?)", n+2); err != nil {
+		t.Fatalf("write in transaction: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-written; err != nil {
+		t.Errorf("concurrent write: %v", err)
+	}
+}
+
+func TestArrivalDelayMigration(t *testing.T) {
+	sqlDB, err := Open(filepath.Join(t.TempDir(), "db.sqlite3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	if err := RunMigrations(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+	// An event stored before 016, with a capture time but no delay
+	captured := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	received := captured.Add(7*time.Minute + 250*time.Millisecond)
+	for _, stmt := range []string{"DROP INDEX idx_events_timeline", "ALTER TABLE events DROP COLUMN arrival_delay_ms"} {
+		if _, err := sqlDB.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := sqlDB.Exec("INSERT INTO events (car_id, source, captured_at, created_at) VALUES ('1', 'manual', ?, ?)", captured, received); err != nil {
+		t.Fatal(err)
+	}
+	if err := executeMigration(sqlDB, "016-arrival-delay.sql"); err != nil {
+		t.Fatal(err)
+	}
+	var at *time.Time
+	if err := sqlDB.QueryRow("SELECT captured_at FROM events").Scan(&at); err != nil {
+		t.Fatal(err)
+	}
+	if at == nil || !at.Equal(captured) {
+		t.Errorf("captured_at %v, want %v", at, captured)
+	}
+}
+
+func TestUTCTime(t *testing.T) {
+	sqlDB, err := Open(filepath.Join(t.TempDir(), "db.sqlite3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	if _, err := sqlDB.Exec("CREATE TABLE t (id INTEGER PRIMARY KEY, ts DATETIME)"); err != nil {
+		t.Fatal(err)
+	}
+	// The night clocks go back: 02:30 summer time is before 02:10 winter time
+	cest, cet := time.FixedZone("CEST", 2*3600), time.FixedZone("CET", 3600)
+	for _, ts := range []any{time.Date(2026, 10, 25, 2, 30, 0, 0, cest), time.Date(2026, 10, 25, 2, 10, 0, 0, cet), nil, "garbage"} {
+		if _, err := sqlDB.Exec("INSERT INTO t (ts) VALUES (?)", ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []int64
+	rows, err := sqlDB.Query("SELECT id FROM t WHERE utc_time(ts) >= utc_time(?) ORDER BY utc_time(ts)", time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id int64
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("ids in UTC order %v, want [1 2]", ids)
+	}
+	var got *string
+	if err := sqlDB.QueryRow("SELECT utc_time(ts) FROM t WHERE id = 2").Scan(&got); err != nil || got == nil || *got != "2026-10-25 01:10:00.000000000" {
+		t.Errorf("utc_time = %v, %v", got, err)
+	}
+	if err := sqlDB.QueryRow("SELECT utc_time(ts) FROM t WHERE id = 4").Scan(&got); err != nil || got != nil {
+		t.Errorf("utc_time of garbage = %v, %v", got, err)
+	}
+}
@@ -7,6 +7,7 @@ package dbgen
 
 import (
 	"context"
+	"strings"
 	"time"
 )
 
@@ -41,6 +42,66 @@ func (q *Queries) CountEvents(ctx context.Context) (int64, error) {
 	return count, err
 }
 
+const countMatchingEvents = `-- name: CountMatchingEvents :one
+SELECT COUNT(*) FROM events e
+WHERE (e.archive_id = ?1 OR (e.archive_id IS NULL AND CAST(?1 AS INTEGER) IS NULL))
+  AND (CAST(?2 AS TEXT) IS NULL OR UPPER(e.plate_utf8) GLOB ?2 OR UPPER(e.manual_plate) GLOB ?2)
+  AND (CAST(?3 AS TEXT) IS NULL OR e.camera_serial = ?3 COLLATE NOCASE
+       OR e.sensor_provider_id = ?3 COLLATE NOCASE OR e.camera_ip = ?3)
+  AND (CAST(?4 AS TEXT) IS NULL OR e.plate_country = ?4 COLLATE NOCASE)
+  AND (CAST(?5 AS TEXT) IS NULL OR e.car_state = ?5 COLLATE NOCASE)
+  AND (CAST(?6 AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) >= ?6)
+  AND (CAST(?7 AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) < ?7)
+`
+
+type CountMatchingEventsParams struct {
+	ArchiveID    *int64     `json:"archive_id"`
+	Plate        *string    `json:"plate"`
+	Camera       *string    `json:"camera"`
+	Country      *string    `json:"country"`
+	CarState     *string    `json:"car_state"`
+	CapturedFrom *time.Time `json:"captured_from"`
+	CapturedTo   *time.Time `json:"captured_to"`
+}
+
+func (q *Queries) CountMatchingEvents(ctx context.Context, arg CountMatchingEventsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countMatchingEvents,
+		arg.ArchiveID,
+		arg.Plate,
+		arg.Camera,
+		arg.Country,
+		arg.CarState,
+		arg.CapturedFrom,
+		arg.CapturedTo,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countSearchEvents = `-- name: CountSearchEvents :one
+SELECT COUNT(*) FROM events e
+WHERE e.plate_key GLOB CAST(?1 AS TEXT) OR e.manual_plate_key GLOB ?1
+`
+
+func (q *Queries) CountSearchEvents(ctx context.Context, pattern string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countSearchEvents, pattern)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUnrecognizedEvents = `-- name: CountUnrecognizedEvents :one
+SELECT COUNT(*) FROM events WHERE unrecognized = 1 AND manual_plate IS NULL
+`
+
+func (q *Queries) CountUnrecognizedEvents(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnrecognizedEvents)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createArchive = `-- name: CreateArchive :one
 INSERT INTO archives (name, event_count, created_at)
 VALUES (?, ?, ?)
@@ -87,6 +148,15 @@ func (q *Queries) DeleteArchiveImages(ctx context.Context, archiveID *int64) err
 	return err
 }
 
+const deleteArchiveTrash = `-- name: DeleteArchiveTrash :exec
+DELETE FROM archive_trash WHERE archive_id = ?
+`
+
+func (q *Queries) DeleteArchiveTrash(ctx context.Context, archiveID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteArchiveTrash, archiveID)
+	return err
+}
+
 const deleteCompareResultsByArchive = `-- name: DeleteCompareResultsByArchive :exec
 DELETE FROM compare_results WHERE archive_id = ?
 `
@@ -96,6 +166,100 @@ func (q *Queries) DeleteCompareResultsByArchive(ctx context.Context, archiveID i
 	return err
 }
 
+const exportEvents = `-- name: ExportEvents :many
+SELECT id, car_id, plate_utf8, car_state, sensor_provider_id, event_datetime, capture_timestamp, plate_country, plate_region, plate_confidence, geotag_lat, geotag_lon, vehicle_make, vehicle_model, vehicle_color, camera_serial, camera_ip, raw_json, created_at, archive_id, json_filename, vehicle_type, confidence_mmr, confidence_color, plate_region_code, unrecognized, manual_plate, source, ocr_plate, ocr_confidence, node_id, origin_id, uid, captured_at, timestamp_error, arrival_delay_ms, updated_at, message_count, plate_key, manual_plate_key, anonymized_at, duplicate_of, duplicates, lane, direction, vin, vin_make, vin_model, vin_year FROM events
+WHERE id > ?1
+  AND utc_time(created_at) >= utc_time(?2)
+  AND utc_time(created_at) < utc_time(?3)
+ORDER BY id
+LIMIT ?4
+`
+
+type ExportEventsParams struct {
+	Cursor int64     `json:"cursor"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+	Limit  int64     `json:"limit"`
+}
+
+func (q *Queries) ExportEvents(ctx context.Context, arg ExportEventsParams) ([]Event, error) {
+	rows, err := q.db.QueryContext(ctx, exportEvents,
+		arg.Cursor,
+		arg.Since,
+		arg.Until,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Event{}
+	for rows.Next() {
+		var i Event
+		if err := rows.Scan(
+			&i.ID,
+			&i.CarID,
+			&i.PlateUtf8,
+			&i.CarState,
+			&i.SensorProviderID,
+			&i.EventDatetime,
+			&i.CaptureTimestamp,
+			&i.PlateCountry,
+			&i.PlateRegion,
+			&i.PlateConfidence,
+			&i.GeotagLat,
+			&i.GeotagLon,
+			&i.VehicleMake,
+			&i.VehicleModel,
+			&i.VehicleColor,
+			&i.CameraSerial,
+			&i.CameraIp,
+			&i.RawJson,
+			&i.CreatedAt,
+			&i.ArchiveID,
+			&i.JsonFilename,
+			&i.VehicleType,
+			&i.ConfidenceMmr,
+			&i.ConfidenceColor,
+			&i.PlateRegionCode,
+			&i.Unrecognized,
+			&i.ManualPlate,
+			&i.Source,
+			&i.OcrPlate,
+			&i.OcrConfidence,
+			&i.NodeID,
+			&i.OriginID,
+			&i.Uid,
+			&i.CapturedAt,
+			&i.TimestampError,
+			&i.ArrivalDelayMs,
+			&i.UpdatedAt,
+			&i.MessageCount,
+			&i.PlateKey,
+			&i.ManualPlateKey,
+			&i.AnonymizedAt,
+			&i.DuplicateOf,
+			&i.Duplicates,
+			&i.Lane,
+			&i.Direction,
+			&i.Vin,
+			&i.VinMake,
+			&i.VinModel,
+			&i.VinYear,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getArchiveByID = `-- name: GetArchiveByID :one
 SELECT id, name, event_count, created_at FROM archives WHERE id = ?
 `
@@ -112,8 +276,135 @@ func (q *Queries) GetArchiveByID(ctx context.Context, id int64) (Archive, error)
 	return i, err
 }
 
+const getArchiveEventsSince = `-- name: GetArchiveEventsSince :many
+SELECT 
+    e.id, e.car_id, e.plate_utf8, e.car_state, e.sensor_provider_id, 
+    e.event_datetime, e.created_at, e.plate_country, e.plate_region, e.plate_region_code,
+    e.vehicle_make, e.vehicle_model, e.vehicle_color, e.vehicle_type,
+    e.plate_confidence, e.confidence_mmr, e.confidence_color,
+    e.json_filename, e.unrecognized, e.manual_plate, e.source, e.node_id, e.uid,
+    e.captured_at, e.arrival_delay_ms,
+    COALESCE(best.plate_image_id, 0) as plate_image_id,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+WHERE e.archive_id = ?1 AND e.id > ?2
+  AND (CAST(?3 AS TEXT) IS NULL OR UPPER(e.plate_utf8) GLOB ?3 OR UPPER(e.manual_plate) GLOB ?3)
+  AND (CAST(?4 AS TEXT) IS NULL OR e.camera_serial = ?4 COLLATE NOCASE
+       OR e.sensor_provider_id = ?4 COLLATE NOCASE OR e.camera_ip = ?4)
+  AND (CAST(?5 AS TEXT) IS NULL OR e.plate_country = ?5 COLLATE NOCASE)
+  AND (CAST(?6 AS TEXT) IS NULL OR e.car_state = ?6 COLLATE NOCASE)
+  AND (CAST(?7 AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) >= ?7)
+  AND (CAST(?8 AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) < ?8)
+ORDER BY e.id
+LIMIT ?9
+`
+
+type GetArchiveEventsSinceParams struct {
+	ArchiveID    *int64     `json:"archive_id"`
+	SinceID      int64      `json:"since_id"`
+	Plate        *string    `json:"plate"`
+	Camera       *string    `json:"camera"`
+	Country      *string    `json:"country"`
+	CarState     *string    `json:"car_state"`
+	CapturedFrom *time.Time `json:"captured_from"`
+	CapturedTo   *time.Time `json:"captured_to"`
+	Limit        int64      `json:"limit"`
+}
+
+type GetArchiveEventsSinceRow struct {
+	ID               int64       `json:"id"`
+	CarID            string      `json:"car_id"`
+	PlateUtf8        *string     `json:"plate_utf8"`
+	CarState         *string     `json:"car_state"`
+	SensorProviderID *string     `json:"sensor_provider_id"`
+	EventDatetime    *string     `json:"event_datetime"`
+	CreatedAt        time.Time   `json:"created_at"`
+	PlateCountry     *string     `json:"plate_country"`
+	PlateRegion      *string     `json:"plate_region"`
+	PlateRegionCode  *string     `json:"plate_region_code"`
+	VehicleMake      *string     `json:"vehicle_make"`
+	VehicleModel     *string     `json:"vehicle_model"`
+	VehicleColor     *string     `json:"vehicle_color"`
+	VehicleType      *string     `json:"vehicle_type"`
+	PlateConfidence  *float64    `json:"plate_confidence"`
+	ConfidenceMmr    *string     `json:"confidence_mmr"`
+	ConfidenceColor  *string     `json:"confidence_color"`
+	JsonFilename     *string     `json:"json_filename"`
+	Unrecognized     bool        `json:"unrecognized"`
+	ManualPlate      *string     `json:"manual_plate"`
+	Source           string      `json:"source"`
+	NodeID           *string     `json:"node_id"`
+	Uid              *string     `json:"uid"`
+	CapturedAt       *time.Time  `json:"captured_at"`
+	ArrivalDelayMs   *int64      `json:"arrival_delay_ms"`
+	PlateImageID     interface{} `json:"plate_image_id"`
+	VehicleImageID   interface{} `json:"vehicle_image_id"`
+}
+
+func (q *Queries) GetArchiveEventsSince(ctx context.Context, arg GetArchiveEventsSinceParams) ([]GetArchiveEventsSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, getArchiveEventsSince,
+		arg.ArchiveID,
+		arg.SinceID,
+		arg.Plate,
+		arg.Camera,
+		arg.Country,
+		arg.CarState,
+		arg.CapturedFrom,
+		arg.CapturedTo,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetArchiveEventsSinceRow{}
+	for rows.Next() {
+		var i GetArchiveEventsSinceRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CarID,
+			&i.PlateUtf8,
+			&i.CarState,
+			&i.SensorProviderID,
+			&i.EventDatetime,
+			&i.CreatedAt,
+			&i.PlateCountry,
+			&i.PlateRegion,
+			&i.PlateRegionCode,
+			&i.VehicleMake,
+			&i.VehicleModel,
+			&i.VehicleColor,
+			&i.VehicleType,
+			&i.PlateConfidence,
+			&i.ConfidenceMmr,
+			&i.ConfidenceColor,
+			&i.JsonFilename,
+			&i.Unrecognized,
+			&i.ManualPlate,
+			&i.Source,
+			&i.NodeID,
+			&i.Uid,
+			&i.CapturedAt,
+			&i.ArrivalDelayMs,
+			&i.PlateImageID,
+			&i.VehicleImageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getArchivedEventFiles = `-- name: GetArchivedEventFiles :many
-SELECT e.id, e.json_filename, i.disk_filename
+SELECT e.id, e.json_filename, i.id AS image_id, i.disk_filename
 FROM events e
 LEFT JOIN images i ON i.event_id = e.id
 WHERE e.archive_id = ?
@@ -122,6 +413,7 @@ WHERE e.archive_id = ?
 type GetArchivedEventFilesRow struct {
 	ID           int64   `json:"id"`
 	JsonFilename *string `json:"json_filename"`
+	ImageID      *int64  `json:"image_id"`
 	DiskFilename *string `json:"disk_filename"`
 }
 
@@ -134,7 +426,44 @@ func (q *Queries) GetArchivedEventFiles(ctx context.Context, archiveID *int64) (
 	items := []GetArchivedEventFilesRow{}
 	for rows.Next() {
 		var i GetArchivedEventFilesRow
-		if err := rows.Scan(&i.ID, &i.JsonFilename, &i.DiskFilename); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.JsonFilename,
+			&i.ImageID,
+			&i.DiskFilename,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getArchivedEventUIDs = `-- name: GetArchivedEventUIDs :many
+SELECT id, uid FROM events WHERE archive_id = ? ORDER BY id
+`
+
+type GetArchivedEventUIDsRow struct {
+	ID  int64   `json:"id"`
+	Uid *string `json:"uid"`
+}
+
+func (q *Queries) GetArchivedEventUIDs(ctx context.Context, archiveID *int64) ([]GetArchivedEventUIDsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getArchivedEventUIDs, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetArchivedEventUIDsRow{}
+	for rows.Next() {
+		var i GetArchivedEventUIDsRow
+		if err := rows.Scan(&i.ID, &i.Uid); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -154,14 +483,15 @@ SELECT
     e.event_datetime, e.created_at, e.plate_country, e.plate_region, e.plate_region_code,
     e.vehicle_make, e.vehicle_model, e.vehicle_color, e.vehicle_type,
     e.plate_confidence, e.confidence_mmr, e.confidence_color,
-    e.json_filename,
-    COALESCE((SELECT id FROM images WHERE event_id = e.id AND image_type = 'plate' LIMIT 1),
-             (SELECT id FROM images WHERE event_id = e.id ORDER BY id LIMIT 1 OFFSET 1), 0) as plate_image_id,
-    COALESCE((SELECT id FROM images WHERE event_id = e.id AND image_type = 'vehicle' LIMIT 1),
-             (SELECT id FROM images WHERE event_id = e.id ORDER BY id LIMIT 1), 0) as vehicle_image_id
+    e.json_filename, e.unrecognized, e.manual_plate, e.source, e.node_id, e.uid,
+    e.captured_at, e.arrival_delay_ms, e.camera_serial, e.camera_ip, e.geotag_lat, e.geotag_lon,
+    e.vin_make, e.vin_model, e.vin_year,
+    COALESCE(best.plate_image_id, 0) as plate_image_id,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id
 FROM events e
+JOIN event_best_images best ON best.event_id = e.id
 WHERE e.archive_id = ?
-ORDER BY e.created_at DESC
+ORDER BY COALESCE(e.captured_at, e.created_at) DESC, e.id DESC
 `
 
 type GetArchivedEventsRow struct {
@@ -183,6 +513,20 @@ type GetArchivedEventsRow struct {
 	ConfidenceMmr    *string     `json:"confidence_mmr"`
 	ConfidenceColor  *string     `json:"confidence_color"`
 	JsonFilename     *string     `json:"json_filename"`
+	Unrecognized     bool        `json:"unrecognized"`
+	ManualPlate      *string     `json:"manual_plate"`
+	Source           string      `json:"source"`
+	NodeID           *string     `json:"node_id"`
+	Uid              *string     `json:"uid"`
+	CapturedAt       *time.Time  `json:"captured_at"`
+	ArrivalDelayMs   *int64      `json:"arrival_delay_ms"`
+	CameraSerial     *string     `json:"camera_serial"`
+	CameraIp         *string     `json:"camera_ip"`
+	GeotagLat        *float64    `json:"geotag_lat"`
+	GeotagLon        *float64    `json:"geotag_lon"`
+	VinMake          *string     `json:"vin_make"`
+	VinModel         *string     `json:"vin_model"`
+	VinYear          *int64      `json:"vin_year"`
 	PlateImageID     interface{} `json:"plate_image_id"`
 	VehicleImageID   interface{} `json:"vehicle_image_id"`
 }
@@ -215,6 +559,20 @@ func (q *Queries) GetArchivedEvents(ctx context.Context, archiveID *int64) ([]Ge
 			&i.ConfidenceMmr,
 			&i.ConfidenceColor,
 			&i.JsonFilename,
+			&i.Unrecognized,
+			&i.ManualPlate,
+			&i.Source,
+			&i.NodeID,
+			&i.Uid,
+			&i.CapturedAt,
+			&i.ArrivalDelayMs,
+			&i.CameraSerial,
+			&i.CameraIp,
+			&i.GeotagLat,
+			&i.GeotagLon,
+			&i.VinMake,
+			&i.VinModel,
+			&i.VinYear,
 			&i.PlateImageID,
 			&i.VehicleImageID,
 		); err != nil {
@@ -232,7 +590,9 @@ func (q *Queries) GetArchivedEvents(ctx context.Context, archiveID *int64) ([]Ge
 }
 
 const getArchives = `-- name: GetArchives :many
-SELECT id, name, event_count, created_at FROM archives ORDER BY created_at DESC
+SELECT id, name, event_count, created_at FROM archives
+WHERE id NOT IN (SELECT archive_id FROM archive_trash)
+ORDER BY created_at DESC
 `
 
 func (q *Queries) GetArchives(ctx context.Context) ([]Archive, error) {
@@ -296,8 +656,70 @@ func (q *Queries) GetCompareResults(ctx context.Context, archiveID int64) ([]Get
 	return items, nil
 }
 
+const getCompareResultsDetail = `-- name: GetCompareResultsDetail :many
+SELECT event_id, field, is_incorrect, updated_at FROM compare_results
+WHERE archive_id = ?
+ORDER BY event_id, field
+`
+
+type GetCompareResultsDetailRow struct {
+	EventID     int64      `json:"event_id"`
+	Field       string     `json:"field"`
+	IsIncorrect bool       `json:"is_incorrect"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
+
+func (q *Queries) GetCompareResultsDetail(ctx context.Context, archiveID int64) ([]GetCompareResultsDetailRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCompareResultsDetail, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetCompareResultsDetailRow{}
+	for rows.Next() {
+		var i GetCompareResultsDetailRow
+		if err := rows.Scan(
+			&i.EventID,
+			&i.Field,
+			&i.IsIncorrect,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEventBestImages = `-- name: GetEventBestImages :one
+SELECT
+    COALESCE(best.plate_image_id, 0) as plate_image_id,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+WHERE e.id = ?
+`
+
+type GetEventBestImagesRow struct {
+	PlateImageID   interface{} `json:"plate_image_id"`
+	VehicleImageID interface{} `json:"vehicle_image_id"`
+}
+
+func (q *Queries) GetEventBestImages(ctx context.Context, id int64) (GetEventBestImagesRow, error) {
+	row := q.db.QueryRowContext(ctx, getEventBestImages, id)
+	var i GetEventBestImagesRow
+	err := row.Scan(&i.PlateImageID, &i.VehicleImageID)
+	return i, err
+}
+
 const getEventByID = `-- name: GetEventByID :one
-SELECT id, car_id, plate_utf8, car_state, sensor_provider_id, event_datetime, capture_timestamp, plate_country, plate_region, plate_confidence, geotag_lat, geotag_lon, vehicle_make, vehicle_model, vehicle_color, camera_serial, camera_ip, raw_json, created_at, archive_id, json_filename, vehicle_type, confidence_mmr, confidence_color, plate_region_code FROM events WHERE id = ?
+SELECT id, car_id, plate_utf8, car_state, sensor_provider_id, event_datetime, capture_timestamp, plate_country, plate_region, plate_confidence, geotag_lat, geotag_lon, vehicle_make, vehicle_model, vehicle_color, camera_serial, camera_ip, raw_json, created_at, archive_id, json_filename, vehicle_type, confidence_mmr, confidence_color, plate_region_code, unrecognized, manual_plate, source, ocr_plate, ocr_confidence, node_id, origin_id, uid, captured_at, timestamp_error, arrival_delay_ms, updated_at, message_count, plate_key, manual_plate_key, anonymized_at, duplicate_of, duplicates, lane, direction, vin, vin_make, vin_model, vin_year FROM events WHERE id = ?
 `
 
 func (q *Queries) GetEventByID(ctx context.Context, id int64) (Event, error) {
@@ -329,57 +751,434 @@ func (q *Queries) GetEventByID(ctx context.Context, id int64) (Event, error) {
 		&i.ConfidenceMmr,
 		&i.ConfidenceColor,
 		&i.PlateRegionCode,
+		&i.Unrecognized,
+		&i.ManualPlate,
+		&i.Source,
+		&i.OcrPlate,
+		&i.OcrConfidence,
+		&i.NodeID,
+		&i.OriginID,
+		&i.Uid,
+		&i.CapturedAt,
+		&i.TimestampError,
+		&i.ArrivalDelayMs,
+		&i.UpdatedAt,
+		&i.MessageCount,
+		&i.PlateKey,
+		&i.ManualPlateKey,
+		&i.AnonymizedAt,
+		&i.DuplicateOf,
+		&i.Duplicates,
+		&i.Lane,
+		&i.Direction,
+		&i.Vin,
+		&i.VinMake,
+		&i.VinModel,
+		&i.VinYear,
 	)
 	return i, err
 }
 
-const getImageData = `-- name: GetImageData :one
-SELECT image_data FROM images WHERE id = ?
-`
-
-func (q *Queries) GetImageData(ctx context.Context, id int64) ([]byte, error) {
-	row := q.db.QueryRowContext(ctx, getImageData, id)
-	var image_data []byte
-	err := row.Scan(&image_data)
-	return image_data, err
-}
-
-const getImageWithFilename = `-- name: GetImageWithFilename :one
-SELECT id, event_id, image_type, filename, disk_filename, created_at FROM images WHERE id = ?
+const getEventByUID = `-- name: GetEventByUID :one
+SELECT id, car_id, plate_utf8, car_state, sensor_provider_id, event_datetime, capture_timestamp, plate_country, plate_region, plate_confidence, geotag_lat, geotag_lon, vehicle_make, vehicle_model, vehicle_color, camera_serial, camera_ip, raw_json, created_at, archive_id, json_filename, vehicle_type, confidence_mmr, confidence_color, plate_region_code, unrecognized, manual_plate, source, ocr_plate, ocr_confidence, node_id, origin_id, uid, captured_at, timestamp_error, arrival_delay_ms, updated_at, message_count, plate_key, manual_plate_key, anonymized_at, duplicate_of, duplicates, lane, direction, vin, vin_make, vin_model, vin_year FROM events WHERE uid = ?
 `
 
-type GetImageWithFilenameRow struct {
-	ID           int64     `json:"id"`
-	EventID      int64     `json:"event_id"`
-	ImageType    *string   `json:"image_type"`
-	Filename     *string   `json:"filename"`
-	DiskFilename *string   `json:"disk_filename"`
-	CreatedAt    time.Time `json:"created_at"`
-}
-
-func (q *Queries) GetImageWithFilename(ctx context.Context, id int64) (GetImageWithFilenameRow, error) {
-	row := q.db.QueryRowContext(ctx, getImageWithFilename, id)
-	var i GetImageWithFilenameRow
+func (q *Queries) GetEventByUID(ctx context.Context, uid *string) (Event, error) {
+	row := q.db.QueryRowContext(ctx, getEventByUID, uid)
+	var i Event
 	err := row.Scan(
 		&i.ID,
-		&i.EventID,
-		&i.ImageType,
-		&i.Filename,
-		&i.DiskFilename,
+		&i.CarID,
+		&i.PlateUtf8,
+		&i.CarState,
+		&i.SensorProviderID,
+		&i.EventDatetime,
+		&i.CaptureTimestamp,
+		&i.PlateCountry,
+		&i.PlateRegion,
+		&i.PlateConfidence,
+		&i.GeotagLat,
+		&i.GeotagLon,
+		&i.VehicleMake,
+		&i.VehicleModel,
+		&i.VehicleColor,
+		&i.CameraSerial,
+		&i.CameraIp,
+		&i.RawJson,
 		&i.CreatedAt,
+		&i.ArchiveID,
+		&i.JsonFilename,
+		&i.VehicleType,
+		&i.ConfidenceMmr,
+		&i.ConfidenceColor,
+		&i.PlateRegionCode,
+		&i.Unrecognized,
+		&i.ManualPlate,
+		&i.Source,
+		&i.OcrPlate,
+		&i.OcrConfidence,
+		&i.NodeID,
+		&i.OriginID,
+		&i.Uid,
+		&i.CapturedAt,
+		&i.TimestampError,
+		&i.ArrivalDelayMs,
+		&i.UpdatedAt,
+		&i.MessageCount,
+		&i.PlateKey,
+		&i.ManualPlateKey,
+		&i.AnonymizedAt,
+		&i.DuplicateOf,
+		&i.Duplicates,
+		&i.Lane,
+		&i.Direction,
+		&i.Vin,
+		&i.VinMake,
+		&i.VinModel,
+		&i.VinYear,
 	)
 	return i, err
 }
 
-const getImagesByEventID = `-- name: GetImagesByEventID :many
-SELECT id, image_type, filename, created_at FROM images WHERE event_id = ?
+const getEventCompareResults = `-- name: GetEventCompareResults :many
+SELECT field, is_incorrect, updated_at FROM compare_results
+WHERE archive_id = ? AND event_id = ?
+ORDER BY field
 `
 
-type GetImagesByEventIDRow struct {
-	ID        int64     `json:"id"`
-	ImageType *string   `json:"image_type"`
-	Filename  *string   `json:"filename"`
-	CreatedAt time.Time `json:"created_at"`
+type GetEventCompareResultsParams struct {
+	ArchiveID int64 `json:"archive_id"`
+	EventID   int64 `json:"event_id"`
+}
+
+type GetEventCompareResultsRow struct {
+	Field       string     `json:"field"`
+	IsIncorrect bool       `json:"is_incorrect"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
+
+func (q *Queries) GetEventCompareResults(ctx context.Context, arg GetEventCompareResultsParams) ([]GetEventCompareResultsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getEventCompareResults, arg.ArchiveID, arg.EventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetEventCompareResultsRow{}
+	for rows.Next() {
+		var i GetEventCompareResultsRow
+		if err := rows.Scan(&i.Field, &i.IsIncorrect, &i.UpdatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEventsSince = `-- name: GetEventsSince :many
+SELECT 
+    e.id, e.car_id, e.plate_utf8, e.car_state, e.sensor_provider_id, 
+    e.event_datetime, e.created_at, e.plate_country, e.plate_region, e.plate_region_code,
+    e.vehicle_make, e.vehicle_model, e.vehicle_color, e.vehicle_type,
+    e.plate_confidence, e.confidence_mmr, e.confidence_color,
+    e.json_filename, e.unrecognized, e.manual_plate, e.source, e.node_id, e.uid,
+    e.captured_at, e.arrival_delay_ms,
+    COALESCE(best.plate_image_id, 0) as plate_image_id,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+WHERE e.archive_id IS NULL AND e.id > ?1
+  AND (CAST(?2 AS TEXT) IS NULL OR UPPER(e.plate_utf8) GLOB ?2 OR UPPER(e.manual_plate) GLOB ?2)
+  AND (CAST(?3 AS TEXT) IS NULL OR e.camera_serial = ?3 COLLATE NOCASE
+       OR e.sensor_provider_id = ?3 COLLATE NOCASE OR e.camera_ip = ?3)
+  AND (CAST(?4 AS TEXT) IS NULL OR e.plate_country = ?4 COLLATE NOCASE)
+  AND (CAST(?5 AS TEXT) IS NULL OR e.car_state = ?5 COLLATE NOCASE)
+  AND (CAST(?6 AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) >= ?6)
+  AND (CAST(?7 AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) < ?7)
+ORDER BY e.id
+LIMIT ?8
+`
+
+type GetEventsSinceParams struct {
+	SinceID      int64      `json:"since_id"`
+	Plate        *string    `json:"plate"`
+	Camera       *string    `json:"camera"`
+	Country      *string    `json:"country"`
+	CarState     *string    `json:"car_state"`
+	CapturedFrom *time.Time `json:"captured_from"`
+	CapturedTo   *time.Time `json:"captured_to"`
+	Limit        int64      `json:"limit"`
+}
+
+type GetEventsSinceRow struct {
+	ID               int64       `json:"id"`
+	CarID            string      `json:"car_id"`
+	PlateUtf8        *string     `json:"plate_utf8"`
+	CarState         *string     `json:"car_state"`
+	SensorProviderID *string     `json:"sensor_provider_id"`
+	EventDatetime    *string     `json:"event_datetime"`
+	CreatedAt        time.Time   `json:"created_at"`
+	PlateCountry     *string     `json:"plate_country"`
+	PlateRegion      *string     `json:"plate_region"`
+	PlateRegionCode  *string     `json:"plate_region_code"`
+	VehicleMake      *string     `json:"vehicle_make"`
+	VehicleModel     *string     `json:"vehicle_model"`
+	VehicleColor     *string     `json:"vehicle_color"`
+	VehicleType      *string     `json:"vehicle_type"`
+	PlateConfidence  *float64    `json:"plate_confidence"`
+	ConfidenceMmr    *string     `json:"confidence_mmr"`
+	ConfidenceColor  *string     `json:"confidence_color"`
+	JsonFilename     *string     `json:"json_filename"`
+	Unrecognized     bool        `json:"unrecognized"`
+	ManualPlate      *string     `json:"manual_plate"`
+	Source           string      `json:"source"`
+	NodeID           *string     `json:"node_id"`
+	Uid              *string     `json:"uid"`
+	CapturedAt       *time.Time  `json:"captured_at"`
+	ArrivalDelayMs   *int64      `json:"arrival_delay_ms"`
+	PlateImageID     interface{} `json:"plate_image_id"`
+	VehicleImageID   interface{} `json:"vehicle_image_id"`
+}
+
+func (q *Queries) GetEventsSince(ctx context.Context, arg GetEventsSinceParams) ([]GetEventsSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, getEventsSince,
+		arg.SinceID,
+		arg.Plate,
+		arg.Camera,
+		arg.Country,
+		arg.CarState,
+		arg.CapturedFrom,
+		arg.CapturedTo,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetEventsSinceRow{}
+	for rows.Next() {
+		var i GetEventsSinceRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CarID,
+			&i.PlateUtf8,
+			&i.CarState,
+			&i.SensorProviderID,
+			&i.EventDatetime,
+			&i.CreatedAt,
+			&i.PlateCountry,
+			&i.PlateRegion,
+			&i.PlateRegionCode,
+			&i.VehicleMake,
+			&i.VehicleModel,
+			&i.VehicleColor,
+			&i.VehicleType,
+			&i.PlateConfidence,
+			&i.ConfidenceMmr,
+			&i.ConfidenceColor,
+			&i.JsonFilename,
+			&i.Unrecognized,
+			&i.ManualPlate,
+			&i.Source,
+			&i.NodeID,
+			&i.Uid,
+			&i.CapturedAt,
+			&i.ArrivalDelayMs,
+			&i.PlateImageID,
+			&i.VehicleImageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEventsWithoutCaptureTime = `-- name: GetEventsWithoutCaptureTime :many
+SELECT id, event_datetime, capture_timestamp, created_at FROM events
+WHERE captured_at IS NULL AND timestamp_error IS NULL
+  AND (event_datetime <> '' OR capture_timestamp <> '')
+ORDER BY id LIMIT ?
+`
+
+type GetEventsWithoutCaptureTimeRow struct {
+	ID               int64     `json:"id"`
+	EventDatetime    *string   `json:"event_datetime"`
+	CaptureTimestamp *string   `json:"capture_timestamp"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func (q *Queries) GetEventsWithoutCaptureTime(ctx context.Context, limit int64) ([]GetEventsWithoutCaptureTimeRow, error) {
+	rows, err := q.db.QueryContext(ctx, getEventsWithoutCaptureTime, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetEventsWithoutCaptureTimeRow{}
+	for rows.Next() {
+		var i GetEventsWithoutCaptureTimeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventDatetime,
+			&i.CaptureTimestamp,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEventsWithoutArrivalDelay = `-- name: GetEventsWithoutArrivalDelay :many
+SELECT id, captured_at, created_at FROM events
+WHERE arrival_delay_ms IS NULL AND captured_at IS NOT NULL
+ORDER BY id LIMIT ?
+`
+
+type GetEventsWithoutArrivalDelayRow struct {
+	ID         int64      `json:"id"`
+	CapturedAt *time.Time `json:"captured_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (q *Queries) GetEventsWithoutArrivalDelay(ctx context.Context, limit int64) ([]GetEventsWithoutArrivalDelayRow, error) {
+	rows, err := q.db.QueryContext(ctx, getEventsWithoutArrivalDelay, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetEventsWithoutArrivalDelayRow{}
+	for rows.Next() {
+		var i GetEventsWithoutArrivalDelayRow
+		if err := rows.Scan(&i.ID, &i.CapturedAt, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEventsWithoutUID = `-- name: GetEventsWithoutUID :many
+SELECT id, created_at FROM events WHERE uid IS NULL ORDER BY id LIMIT ?
+`
+
+type GetEventsWithoutUIDRow struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (q *Queries) GetEventsWithoutUID(ctx context.Context, limit int64) ([]GetEventsWithoutUIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, getEventsWithoutUID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetEventsWithoutUIDRow{}
+	for rows.Next() {
+		var i GetEventsWithoutUIDRow
+		if err := rows.Scan(&i.ID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getImageData = `-- name: GetImageData :one
+SELECT image_data, disk_filename FROM images WHERE id = ? AND deleted_at IS NULL
+`
+
+type GetImageDataRow struct {
+	ImageData    []byte  `json:"image_data"`
+	DiskFilename *string `json:"disk_filename"`
+}
+
+func (q *Queries) GetImageData(ctx context.Context, id int64) (GetImageDataRow, error) {
+	row := q.db.QueryRowContext(ctx, getImageData, id)
+	var i GetImageDataRow
+	err := row.Scan(&i.ImageData, &i.DiskFilename)
+	return i, err
+}
+
+const getImageWithFilename = `-- name: GetImageWithFilename :one
+SELECT id, event_id, image_type, filename, disk_filename, created_at, deleted_at FROM images WHERE id = ?
+`
+
+type GetImageWithFilenameRow struct {
+	ID           int64      `json:"id"`
+	EventID      int64      `json:"event_id"`
+	ImageType    *string    `json:"image_type"`
+	Filename     *string    `json:"filename"`
+	DiskFilename *string    `json:"disk_filename"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DeletedAt    *time.Time `json:"deleted_at"`
+}
+
+func (q *Queries) GetImageWithFilename(ctx context.Context, id int64) (GetImageWithFilenameRow, error) {
+	row := q.db.QueryRowContext(ctx, getImageWithFilename, id)
+	var i GetImageWithFilenameRow
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.ImageType,
+		&i.Filename,
+		&i.DiskFilename,
+		&i.CreatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getImagesByEventID = `-- name: GetImagesByEventID :many
+SELECT id, image_type, classified_type, filename, created_at, captured_at, width, height, sharpness, quality,
+    deleted_at, deleted_by, delete_reason
+FROM images WHERE event_id = ? ORDER BY id
+`
+
+type GetImagesByEventIDRow struct {
+	ID             int64      `json:"id"`
+	ImageType      *string    `json:"image_type"`
+	ClassifiedType *string    `json:"classified_type"`
+	Filename       *string    `json:"filename"`
+	CreatedAt      time.Time  `json:"created_at"`
+	CapturedAt     *time.Time `json:"captured_at"`
+	Width          *int64     `json:"width"`
+	Height         *int64     `json:"height"`
+	Sharpness      *float64   `json:"sharpness"`
+	Quality        *float64   `json:"quality"`
+	DeletedAt      *time.Time `json:"deleted_at"`
+	DeletedBy      *string    `json:"deleted_by"`
+	DeleteReason   *string    `json:"delete_reason"`
 }
 
 func (q *Queries) GetImagesByEventID(ctx context.Context, eventID int64) ([]GetImagesByEventIDRow, error) {
@@ -394,6 +1193,68 @@ func (q *Queries) GetImagesByEventID(ctx context.Context, eventID int64) ([]GetI
 		if err := rows.Scan(
 			&i.ID,
 			&i.ImageType,
+			&i.ClassifiedType,
+			&i.Filename,
+			&i.CreatedAt,
+			&i.CapturedAt,
+			&i.Width,
+			&i.Height,
+			&i.Sharpness,
+			&i.Quality,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.DeleteReason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getImagesByEventIDs = `-- name: GetImagesByEventIDs :many
+SELECT id, event_id, image_type, filename, created_at FROM images
+WHERE event_id IN (/*SLICE:event_ids*/?) AND deleted_at IS NULL
+ORDER BY event_id, id
+`
+
+type GetImagesByEventIDsRow struct {
+	ID        int64     `json:"id"`
+	EventID   int64     `json:"event_id"`
+	ImageType *string   `json:"image_type"`
+	Filename  *string   `json:"filename"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (q *Queries) GetImagesByEventIDs(ctx context.Context, eventIds []int64) ([]GetImagesByEventIDsRow, error) {
+	query := getImagesByEventIDs
+	var queryParams []interface{}
+	if len(eventIds) > 0 {
+		for _, v := range eventIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:event_ids*/?", strings.Repeat(",?", len(eventIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:event_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetImagesByEventIDsRow{}
+	for rows.Next() {
+		var i GetImagesByEventIDsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.ImageType,
 			&i.Filename,
 			&i.CreatedAt,
 		); err != nil {
@@ -410,23 +1271,82 @@ func (q *Queries) GetImagesByEventID(ctx context.Context, eventID int64) ([]GetI
 	return items, nil
 }
 
+const getImagesForOCR = `-- name: GetImagesForOCR :many
+SELECT id, image_type, image_data, disk_filename FROM images
+WHERE event_id = ? AND deleted_at IS NULL
+ORDER BY CASE COALESCE(classified_type, image_type) WHEN 'plate' THEN 0 WHEN 'vehicle' THEN 1 ELSE 2 END, quality IS NULL, quality DESC, id
+`
+
+type GetImagesForOCRRow struct {
+	ID           int64   `json:"id"`
+	ImageType    *string `json:"image_type"`
+	ImageData    []byte  `json:"image_data"`
+	DiskFilename *string `json:"disk_filename"`
+}
+
+func (q *Queries) GetImagesForOCR(ctx context.Context, eventID int64) ([]GetImagesForOCRRow, error) {
+	rows, err := q.db.QueryContext(ctx, getImagesForOCR, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetImagesForOCRRow{}
+	for rows.Next() {
+		var i GetImagesForOCRRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ImageType,
+			&i.ImageData,
+			&i.DiskFilename,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getRecentEvents = `-- name: GetRecentEvents :many
 SELECT 
     e.id, e.car_id, e.plate_utf8, e.car_state, e.sensor_provider_id, 
     e.event_datetime, e.created_at, e.plate_country, e.plate_region, e.plate_region_code,
     e.vehicle_make, e.vehicle_model, e.vehicle_color, e.vehicle_type,
     e.plate_confidence, e.confidence_mmr, e.confidence_color,
-    e.json_filename,
-    COALESCE((SELECT id FROM images WHERE event_id = e.id AND image_type = 'plate' LIMIT 1),
-             (SELECT id FROM images WHERE event_id = e.id ORDER BY id LIMIT 1 OFFSET 1), 0) as plate_image_id,
-    COALESCE((SELECT id FROM images WHERE event_id = e.id AND image_type = 'vehicle' LIMIT 1),
-             (SELECT id FROM images WHERE event_id = e.id ORDER BY id LIMIT 1), 0) as vehicle_image_id
+    e.json_filename, e.unrecognized, e.manual_plate, e.source, e.node_id, e.uid,
+    e.captured_at, e.arrival_delay_ms,
+    COALESCE(best.plate_image_id, 0) as plate_image_id,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id
 FROM events e
+JOIN event_best_images best ON best.event_id = e.id
 WHERE e.archive_id IS NULL
-ORDER BY e.created_at DESC
-LIMIT ?
+  AND (CAST(?1 AS TEXT) IS NULL OR UPPER(e.plate_utf8) GLOB ?1 OR UPPER(e.manual_plate) GLOB ?1)
+  AND (CAST(?2 AS TEXT) IS NULL OR e.camera_serial = ?2 COLLATE NOCASE
+       OR e.sensor_provider_id = ?2 COLLATE NOCASE OR e.camera_ip = ?2)
+  AND (CAST(?3 AS TEXT) IS NULL OR e.plate_country = ?3 COLLATE NOCASE)
+  AND (CAST(?4 AS TEXT) IS NULL OR e.car_state = ?4 COLLATE NOCASE)
+  AND (CAST(?5 AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) >= ?5)
+  AND (CAST(?6 AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) < ?6)
+ORDER BY CASE WHEN CAST(?7 AS BOOLEAN) THEN e.created_at ELSE COALESCE(e.captured_at, e.created_at) END DESC, e.id DESC
+LIMIT ?8
 `
 
+type GetRecentEventsParams struct {
+	Plate        *string    `json:"plate"`
+	Camera       *string    `json:"camera"`
+	Country      *string    `json:"country"`
+	CarState     *string    `json:"car_state"`
+	CapturedFrom *time.Time `json:"captured_from"`
+	CapturedTo   *time.Time `json:"captured_to"`
+	ByReceived   bool       `json:"by_received"`
+	Limit        int64      `json:"limit"`
+}
+
 type GetRecentEventsRow struct {
 	ID               int64       `json:"id"`
 	CarID            string      `json:"car_id"`
@@ -446,12 +1366,28 @@ type GetRecentEventsRow struct {
 	ConfidenceMmr    *string     `json:"confidence_mmr"`
 	ConfidenceColor  *string     `json:"confidence_color"`
 	JsonFilename     *string     `json:"json_filename"`
+	Unrecognized     bool        `json:"unrecognized"`
+	ManualPlate      *string     `json:"manual_plate"`
+	Source           string      `json:"source"`
+	NodeID           *string     `json:"node_id"`
+	Uid              *string     `json:"uid"`
+	CapturedAt       *time.Time  `json:"captured_at"`
+	ArrivalDelayMs   *int64      `json:"arrival_delay_ms"`
 	PlateImageID     interface{} `json:"plate_image_id"`
 	VehicleImageID   interface{} `json:"vehicle_image_id"`
 }
 
-func (q *Queries) GetRecentEvents(ctx context.Context, limit int64) ([]GetRecentEventsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getRecentEvents, limit)
+func (q *Queries) GetRecentEvents(ctx context.Context, arg GetRecentEventsParams) ([]GetRecentEventsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentEvents,
+		arg.Plate,
+		arg.Camera,
+		arg.Country,
+		arg.CarState,
+		arg.CapturedFrom,
+		arg.CapturedTo,
+		arg.ByReceived,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -478,6 +1414,13 @@ func (q *Queries) GetRecentEvents(ctx context.Context, limit int64) ([]GetRecent
 			&i.ConfidenceMmr,
 			&i.ConfidenceColor,
 			&i.JsonFilename,
+			&i.Unrecognized,
+			&i.ManualPlate,
+			&i.Source,
+			&i.NodeID,
+			&i.Uid,
+			&i.CapturedAt,
+			&i.ArrivalDelayMs,
 			&i.PlateImageID,
 			&i.VehicleImageID,
 		); err != nil {
@@ -494,41 +1437,159 @@ func (q *Queries) GetRecentEvents(ctx context.Context, limit int64) ([]GetRecent
 	return items, nil
 }
 
+const getUnmeasuredImages = `-- name: GetUnmeasuredImages :many
+SELECT id, image_type, image_data, disk_filename FROM images WHERE measured_at IS NULL AND id > ? ORDER BY id LIMIT ?
+`
+
+type GetUnmeasuredImagesParams struct {
+	ID    int64 `json:"id"`
+	Limit int64 `json:"limit"`
+}
+
+type GetUnmeasuredImagesRow struct {
+	ID           int64   `json:"id"`
+	ImageType    *string `json:"image_type"`
+	ImageData    []byte  `json:"image_data"`
+	DiskFilename *string `json:"disk_filename"`
+}
+
+func (q *Queries) GetUnmeasuredImages(ctx context.Context, arg GetUnmeasuredImagesParams) ([]GetUnmeasuredImagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUnmeasuredImages, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetUnmeasuredImagesRow{}
+	for rows.Next() {
+		var i GetUnmeasuredImagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ImageType,
+			&i.ImageData,
+			&i.DiskFilename,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnrecognizedEvents = `-- name: GetUnrecognizedEvents :many
+SELECT
+    e.id, e.car_id, e.archive_id, e.event_datetime, e.created_at,
+    e.camera_serial, e.sensor_provider_id, e.manual_plate, e.ocr_plate, e.ocr_confidence,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id,
+    (SELECT COUNT(*) FROM images WHERE event_id = e.id AND deleted_at IS NULL) as image_count
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+WHERE e.unrecognized = 1
+ORDER BY e.created_at DESC
+LIMIT ?
+`
+
+type GetUnrecognizedEventsRow struct {
+	ID               int64       `json:"id"`
+	CarID            string      `json:"car_id"`
+	ArchiveID        *int64      `json:"archive_id"`
+	EventDatetime    *string     `json:"event_datetime"`
+	CreatedAt        time.Time   `json:"created_at"`
+	CameraSerial     *string     `json:"camera_serial"`
+	SensorProviderID *string     `json:"sensor_provider_id"`
+	ManualPlate      *string     `json:"manual_plate"`
+	OcrPlate         *string     `json:"ocr_plate"`
+	OcrConfidence    *float64    `json:"ocr_confidence"`
+	VehicleImageID   interface{} `json:"vehicle_image_id"`
+	ImageCount       int64       `json:"image_count"`
+}
+
+func (q *Queries) GetUnrecognizedEvents(ctx context.Context, limit int64) ([]GetUnrecognizedEventsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUnrecognizedEvents, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetUnrecognizedEventsRow{}
+	for rows.Next() {
+		var i GetUnrecognizedEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CarID,
+			&i.ArchiveID,
+			&i.EventDatetime,
+			&i.CreatedAt,
+			&i.CameraSerial,
+			&i.SensorProviderID,
+			&i.ManualPlate,
+			&i.OcrPlate,
+			&i.OcrConfidence,
+			&i.VehicleImageID,
+			&i.ImageCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const insertEvent = `-- name: InsertEvent :one
 INSERT INTO events (
     car_id, plate_utf8, car_state, sensor_provider_id,
     event_datetime, capture_timestamp, plate_country, plate_region, plate_region_code, plate_confidence,
     geotag_lat, geotag_lon, vehicle_make, vehicle_model, vehicle_color,
     vehicle_type, confidence_mmr, confidence_color,
-    camera_serial, camera_ip, raw_json, created_at
+    camera_serial, camera_ip, lane, direction, raw_json, unrecognized, source, node_id, uid,
+    captured_at, timestamp_error, arrival_delay_ms, created_at
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 ) RETURNING id
 `
 
 type InsertEventParams struct {
-	CarID            string    `json:"car_id"`
-	PlateUtf8        *string   `json:"plate_utf8"`
-	CarState         *string   `json:"car_state"`
-	SensorProviderID *string   `json:"sensor_provider_id"`
-	EventDatetime    *string   `json:"event_datetime"`
-	CaptureTimestamp *string   `json:"capture_timestamp"`
-	PlateCountry     *string   `json:"plate_country"`
-	PlateRegion      *string   `json:"plate_region"`
-	PlateRegionCode  *string   `json:"plate_region_code"`
-	PlateConfidence  *float64  `json:"plate_confidence"`
-	GeotagLat        *float64  `json:"geotag_lat"`
-	GeotagLon        *float64  `json:"geotag_lon"`
-	VehicleMake      *string   `json:"vehicle_make"`
-	VehicleModel     *string   `json:"vehicle_model"`
-	VehicleColor     *string   `json:"vehicle_color"`
-	VehicleType      *string   `json:"vehicle_type"`
-	ConfidenceMmr    *string   `json:"confidence_mmr"`
-	ConfidenceColor  *string   `json:"confidence_color"`
-	CameraSerial     *string   `json:"camera_serial"`
-	CameraIp         *string   `json:"camera_ip"`
-	RawJson          *string   `json:"raw_json"`
-	CreatedAt        time.Time `json:"created_at"`
+	CarID            string     `json:"car_id"`
+	PlateUtf8        *string    `json:"plate_utf8"`
+	CarState         *string    `json:"car_state"`
+	SensorProviderID *string    `json:"sensor_provider_id"`
+	EventDatetime    *string    `json:"event_datetime"`
+	CaptureTimestamp *string    `json:"capture_timestamp"`
+	PlateCountry     *string    `json:"plate_country"`
+	PlateRegion      *string    `json:"plate_region"`
+	PlateRegionCode  *string    `json:"plate_region_code"`
+	PlateConfidence  *float64   `json:"plate_confidence"`
+	GeotagLat        *float64   `json:"geotag_lat"`
+	GeotagLon        *float64   `json:"geotag_lon"`
+	VehicleMake      *string    `json:"vehicle_make"`
+	VehicleModel     *string    `json:"vehicle_model"`
+	VehicleColor     *string    `json:"vehicle_color"`
+	VehicleType      *string    `json:"vehicle_type"`
+	ConfidenceMmr    *string    `json:"confidence_mmr"`
+	ConfidenceColor  *string    `json:"confidence_color"`
+	CameraSerial     *string    `json:"camera_serial"`
+	CameraIp         *string    `json:"camera_ip"`
+	Lane             *int64     `json:"lane"`
+	Direction        *string    `json:"direction"`
+	RawJson          *string    `json:"raw_json"`
+	Unrecognized     bool       `json:"unrecognized"`
+	Source           string     `json:"source"`
+	NodeID           *string    `json:"node_id"`
+	Uid              *string    `json:"uid"`
+	CapturedAt       *time.Time `json:"captured_at"`
+	TimestampError   *string    `json:"timestamp_error"`
+	ArrivalDelayMs   *int64     `json:"arrival_delay_ms"`
+	CreatedAt        time.Time  `json:"created_at"`
 }
 
 func (q *Queries) InsertEvent(ctx context.Context, arg InsertEventParams) (int64, error) {
@@ -553,7 +1614,16 @@ func (q *Queries) InsertEvent(ctx context.Context, arg InsertEventParams) (int64
 		arg.ConfidenceColor,
 		arg.CameraSerial,
 		arg.CameraIp,
+		arg.Lane,
+		arg.Direction,
 		arg.RawJson,
+		arg.Unrecognized,
+		arg.Source,
+		arg.NodeID,
+		arg.Uid,
+		arg.CapturedAt,
+		arg.TimestampError,
+		arg.ArrivalDelayMs,
 		arg.CreatedAt,
 	)
 	var id int64
@@ -561,27 +1631,62 @@ func (q *Queries) InsertEvent(ctx context.Context, arg InsertEventParams) (int64
 	return id, err
 }
 
-const insertImage = `-- name: InsertImage :exec
-INSERT INTO images (event_id, image_type, filename, image_data, created_at)
-VALUES (?, ?, ?, ?, ?)
+const insertImage = `-- name: InsertImage :one
+INSERT INTO images (event_id, image_type, filename, image_data, size_bytes, created_at, captured_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING id
 `
 
 type InsertImageParams struct {
-	EventID   int64     `json:"event_id"`
-	ImageType *string   `json:"image_type"`
-	Filename  *string   `json:"filename"`
-	ImageData []byte    `json:"image_data"`
-	CreatedAt time.Time `json:"created_at"`
+	EventID    int64      `json:"event_id"`
+	ImageType  *string    `json:"image_type"`
+	Filename   *string    `json:"filename"`
+	ImageData  []byte     `json:"image_data"`
+	SizeBytes  int64      `json:"size_bytes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	CapturedAt *time.Time `json:"captured_at"`
 }
 
-func (q *Queries) InsertImage(ctx context.Context, arg InsertImageParams) error {
-	_, err := q.db.ExecContext(ctx, insertImage,
+func (q *Queries) InsertImage(ctx context.Context, arg InsertImageParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, insertImage,
 		arg.EventID,
 		arg.ImageType,
 		arg.Filename,
 		arg.ImageData,
+		arg.SizeBytes,
 		arg.CreatedAt,
+		arg.CapturedAt,
 	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const moveEventJSONToBlobStore = `-- name: MoveEventJSONToBlobStore :exec
+UPDATE events SET json_filename = ?, raw_json = NULL WHERE id = ?
+`
+
+type MoveEventJSONToBlobStoreParams struct {
+	JsonFilename *string `json:"json_filename"`
+	ID           int64   `json:"id"`
+}
+
+func (q *Queries) MoveEventJSONToBlobStore(ctx context.Context, arg MoveEventJSONToBlobStoreParams) error {
+	_, err := q.db.ExecContext(ctx, moveEventJSONToBlobStore, arg.JsonFilename, arg.ID)
+	return err
+}
+
+const moveImageToBlobStore = `-- name: MoveImageToBlobStore :exec
+UPDATE images SET disk_filename = ?, image_data = X'' WHERE id = ?
+`
+
+type MoveImageToBlobStoreParams struct {
+	DiskFilename *string `json:"disk_filename"`
+	ID           int64   `json:"id"`
+}
+
+func (q *Queries) MoveImageToBlobStore(ctx context.Context, arg MoveImageToBlobStoreParams) error {
+	_, err := q.db.ExecContext(ctx, moveImageToBlobStore, arg.DiskFilename, arg.ID)
 	return err
 }
 
@@ -653,6 +1758,82 @@ func (q *Queries) SearchByPlate(ctx context.Context, arg SearchByPlateParams) ([
 	return items, nil
 }
 
+const searchEvents = `-- name: SearchEvents :many
+SELECT
+    e.id, e.plate_utf8, e.manual_plate, e.car_state, e.sensor_provider_id, e.camera_serial,
+    e.plate_country, e.vehicle_make, e.vehicle_model, e.plate_confidence,
+    e.created_at, e.captured_at, e.archive_id, a.name AS archive_name,
+    COALESCE(best.plate_image_id, 0) as plate_image_id
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+LEFT JOIN archives a ON a.id = e.archive_id
+WHERE e.plate_key GLOB CAST(?1 AS TEXT) OR e.manual_plate_key GLOB ?1
+ORDER BY COALESCE(e.captured_at, e.created_at) DESC, e.id DESC
+LIMIT ?2
+`
+
+type SearchEventsParams struct {
+	Pattern string `json:"pattern"`
+	Limit   int64  `json:"limit"`
+}
+
+type SearchEventsRow struct {
+	ID               int64       `json:"id"`
+	PlateUtf8        *string     `json:"plate_utf8"`
+	ManualPlate      *string     `json:"manual_plate"`
+	CarState         *string     `json:"car_state"`
+	SensorProviderID *string     `json:"sensor_provider_id"`
+	CameraSerial     *string     `json:"camera_serial"`
+	PlateCountry     *string     `json:"plate_country"`
+	VehicleMake      *string     `json:"vehicle_make"`
+	VehicleModel     *string     `json:"vehicle_model"`
+	PlateConfidence  *float64    `json:"plate_confidence"`
+	CreatedAt        time.Time   `json:"created_at"`
+	CapturedAt       *time.Time  `json:"captured_at"`
+	ArchiveID        *int64      `json:"archive_id"`
+	ArchiveName      *string     `json:"archive_name"`
+	PlateImageID     interface{} `json:"plate_image_id"`
+}
+
+func (q *Queries) SearchEvents(ctx context.Context, arg SearchEventsParams) ([]SearchEventsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchEvents, arg.Pattern, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SearchEventsRow{}
+	for rows.Next() {
+		var i SearchEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PlateUtf8,
+			&i.ManualPlate,
+			&i.CarState,
+			&i.SensorProviderID,
+			&i.CameraSerial,
+			&i.PlateCountry,
+			&i.VehicleMake,
+			&i.VehicleModel,
+			&i.PlateConfidence,
+			&i.CreatedAt,
+			&i.CapturedAt,
+			&i.ArchiveID,
+			&i.ArchiveName,
+			&i.PlateImageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const setCompareResult = `-- name: SetCompareResult :exec
 INSERT INTO compare_results (archive_id, event_id, field, is_incorrect, updated_at)
 VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
@@ -678,6 +1859,128 @@ func (q *Queries) SetCompareResult(ctx context.Context, arg SetCompareResultPara
 	return err
 }
 
+const setEventCaptureTime = `-- name: SetEventCaptureTime :exec
+UPDATE events SET captured_at = ?, timestamp_error = ?, arrival_delay_ms = ? WHERE id = ?
+`
+
+type SetEventCaptureTimeParams struct {
+	CapturedAt     *time.Time `json:"captured_at"`
+	TimestampError *string    `json:"timestamp_error"`
+	ArrivalDelayMs *int64     `json:"arrival_delay_ms"`
+	ID             int64      `json:"id"`
+}
+
+func (q *Queries) SetEventCaptureTime(ctx context.Context, arg SetEventCaptureTimeParams) error {
+	_, err := q.db.ExecContext(ctx, setEventCaptureTime,
+		arg.CapturedAt,
+		arg.TimestampError,
+		arg.ArrivalDelayMs,
+		arg.ID,
+	)
+	return err
+}
+
+const setEventArrivalDelay = `-- name: SetEventArrivalDelay :exec
+UPDATE events SET arrival_delay_ms = ? WHERE id = ?
+`
+
+type SetEventArrivalDelayParams struct {
+	ArrivalDelayMs *int64 `json:"arrival_delay_ms"`
+	ID             int64  `json:"id"`
+}
+
+func (q *Queries) SetEventArrivalDelay(ctx context.Context, arg SetEventArrivalDelayParams) error {
+	_, err := q.db.ExecContext(ctx, setEventArrivalDelay, arg.ArrivalDelayMs, arg.ID)
+	return err
+}
+
+const setEventUID = `-- name: SetEventUID :exec
+UPDATE events SET uid = ? WHERE id = ?
+`
+
+type SetEventUIDParams struct {
+	Uid *string `json:"uid"`
+	ID  int64   `json:"id"`
+}
+
+func (q *Queries) SetEventUID(ctx context.Context, arg SetEventUIDParams) error {
+	_, err := q.db.ExecContext(ctx, setEventUID, arg.Uid, arg.ID)
+	return err
+}
+
+const setImageQuality = `-- name: SetImageQuality :exec
+UPDATE images SET width = ?, height = ?, sharpness = ?, quality = ?, classified_type = ?, phash = ?,
+    captured_at = COALESCE(captured_at, ?), measured_at = ? WHERE id = ?
+`
+
+type SetImageQualityParams struct {
+	Width          *int64     `json:"width"`
+	Height         *int64     `json:"height"`
+	Sharpness      *float64   `json:"sharpness"`
+	Quality        *float64   `json:"quality"`
+	ClassifiedType *string    `json:"classified_type"`
+	Phash          *int64     `json:"phash"`
+	CapturedAt     *time.Time `json:"captured_at"`
+	MeasuredAt     *time.Time `json:"measured_at"`
+	ID             int64      `json:"id"`
+}
+
+func (q *Queries) SetImageQuality(ctx context.Context, arg SetImageQualityParams) error {
+	_, err := q.db.ExecContext(ctx, setImageQuality,
+		arg.Width,
+		arg.Height,
+		arg.Sharpness,
+		arg.Quality,
+		arg.ClassifiedType,
+		arg.Phash,
+		arg.CapturedAt,
+		arg.MeasuredAt,
+		arg.ID,
+	)
+	return err
+}
+
+const setManualPlate = `-- name: SetManualPlate :exec
+UPDATE events SET manual_plate = ? WHERE id = ?
+`
+
+type SetManualPlateParams struct {
+	ManualPlate *string `json:"manual_plate"`
+	ID          int64   `json:"id"`
+}
+
+func (q *Queries) SetManualPlate(ctx context.Context, arg SetManualPlateParams) error {
+	_, err := q.db.ExecContext(ctx, setManualPlate, arg.ManualPlate, arg.ID)
+	return err
+}
+
+const setOCRSuggestion = `-- name: SetOCRSuggestion :exec
+UPDATE events SET ocr_plate = ?, ocr_confidence = ? WHERE id = ?
+`
+
+type SetOCRSuggestionParams struct {
+	OcrPlate      *string  `json:"ocr_plate"`
+	OcrConfidence *float64 `json:"ocr_confidence"`
+	ID            int64    `json:"id"`
+}
+
+func (q *Queries) SetOCRSuggestion(ctx context.Context, arg SetOCRSuggestionParams) error {
+	_, err := q.db.ExecContext(ctx, setOCRSuggestion, arg.OcrPlate, arg.OcrConfidence, arg.ID)
+	return err
+}
+
+const stampNodeID = `-- name: StampNodeID :execrows
+UPDATE events SET node_id = ? WHERE node_id IS NULL
+`
+
+func (q *Queries) StampNodeID(ctx context.Context, nodeID *string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, stampNodeID, nodeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateEventJsonFilename = `-- name: UpdateEventJsonFilename :exec
 UPDATE events SET json_filename = ? WHERE id = ?
 `
@@ -8,6 +8,31 @@ import (
 	"time"
 )
 
+type AccuracyAlarm struct {
+	ID               int64     `json:"id"`
+	ArchiveID        int64     `json:"archive_id"`
+	Camera           string    `json:"camera"`
+	Field            string    `json:"field"`
+	Correct          int64     `json:"correct"`
+	Incorrect        int64     `json:"incorrect"`
+	Pct              float64   `json:"pct"`
+	BaselinePct      float64   `json:"baseline_pct"`
+	BaselineArchives int64     `json:"baseline_archives"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type ApiKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	KeyHash    string     `json:"key_hash"`
+	KeyPrefix  string     `json:"key_prefix"`
+	Enabled    bool       `json:"enabled"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIp *string    `json:"last_used_ip"`
+	UseCount   int64      `json:"use_count"`
+}
+
 type Archive struct {
 	ID         int64     `json:"id"`
 	Name       *string   `json:"name"`
@@ -15,6 +40,77 @@ type Archive struct {
 	CreatedAt  time.Time `json:"created_at"`
 }
 
+type ArchiveAudit struct {
+	ID        int64     `json:"id"`
+	ArchiveID int64     `json:"archive_id"`
+	Action    string    `json:"action"`
+	Detail    *string   `json:"detail"`
+	Actor     *string   `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ArchiveCompaction struct {
+	ArchiveID     int64     `json:"archive_id"`
+	CompactedAt   time.Time `json:"compacted_at"`
+	CompactedBy   *string   `json:"compacted_by"`
+	ImagesRemoved int64     `json:"images_removed"`
+	BytesFreed    int64     `json:"bytes_freed"`
+}
+
+type ArchiveHold struct {
+	ArchiveID int64     `json:"archive_id"`
+	Reason    string    `json:"reason"`
+	PlacedAt  time.Time `json:"placed_at"`
+	PlacedBy  *string   `json:"placed_by"`
+}
+
+type ArchiveLock struct {
+	ArchiveID  int64      `json:"archive_id"`
+	MerkleRoot string     `json:"merkle_root"`
+	EventCount int64      `json:"event_count"`
+	LockedAt   time.Time  `json:"locked_at"`
+	LockedBy   *string    `json:"locked_by"`
+	VerifiedAt *time.Time `json:"verified_at"`
+	VerifiedOk *bool      `json:"verified_ok"`
+}
+
+type ArchiveLockLeafe struct {
+	ArchiveID int64  `json:"archive_id"`
+	EventID   int64  `json:"event_id"`
+	LeafHash  string `json:"leaf_hash"`
+}
+
+type ArchiveReviewer struct {
+	ArchiveID  int64     `json:"archive_id"`
+	Username   string    `json:"username"`
+	AssignedBy *string   `json:"assigned_by"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+type ArchiveTrash struct {
+	ArchiveID int64     `json:"archive_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy *string   `json:"deleted_by"`
+}
+
+type AutoCompareResult struct {
+	ArchiveID   int64     `json:"archive_id"`
+	EventID     int64     `json:"event_id"`
+	Field       string    `json:"field"`
+	IsIncorrect bool      `json:"is_incorrect"`
+	ComparedAt  time.Time `json:"compared_at"`
+}
+
+type ComparePlate struct {
+	ArchiveID int64     `json:"archive_id"`
+	EventID   int64     `json:"event_id"`
+	TruePlate string    `json:"true_plate"`
+	Source    string    `json:"source"`
+	Distance  float64   `json:"distance"`
+	Partial   bool      `json:"partial"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type CompareResult struct {
 	ID          int64      `json:"id"`
 	ArchiveID   int64      `json:"archive_id"`
@@ -26,41 +122,139 @@ type CompareResult struct {
 }
 
 type Event struct {
-	ID               int64     `json:"id"`
-	CarID            string    `json:"car_id"`
-	PlateUtf8        *string   `json:"plate_utf8"`
-	CarState         *string   `json:"car_state"`
-	SensorProviderID *string   `json:"sensor_provider_id"`
-	EventDatetime    *string   `json:"event_datetime"`
-	CaptureTimestamp *string   `json:"capture_timestamp"`
-	PlateCountry     *string   `json:"plate_country"`
-	PlateRegion      *string   `json:"plate_region"`
-	PlateConfidence  *float64  `json:"plate_confidence"`
-	GeotagLat        *float64  `json:"geotag_lat"`
-	GeotagLon        *float64  `json:"geotag_lon"`
-	VehicleMake      *string   `json:"vehicle_make"`
-	VehicleModel     *string   `json:"vehicle_model"`
-	VehicleColor     *string   `json:"vehicle_color"`
-	CameraSerial     *string   `json:"camera_serial"`
-	CameraIp         *string   `json:"camera_ip"`
-	RawJson          *string   `json:"raw_json"`
-	CreatedAt        time.Time `json:"created_at"`
-	ArchiveID        *int64    `json:"archive_id"`
-	JsonFilename     *string   `json:"json_filename"`
-	VehicleType      *string   `json:"vehicle_type"`
-	ConfidenceMmr    *string   `json:"confidence_mmr"`
-	ConfidenceColor  *string   `json:"confidence_color"`
-	PlateRegionCode  *string   `json:"plate_region_code"`
+	ID               int64      `json:"id"`
+	CarID            string     `json:"car_id"`
+	PlateUtf8        *string    `json:"plate_utf8"`
+	CarState         *string    `json:"car_state"`
+	SensorProviderID *string    `json:"sensor_provider_id"`
+	EventDatetime    *string    `json:"event_datetime"`
+	CaptureTimestamp *string    `json:"capture_timestamp"`
+	PlateCountry     *string    `json:"plate_country"`
+	PlateRegion      *string    `json:"plate_region"`
+	PlateConfidence  *float64   `json:"plate_confidence"`
+	GeotagLat        *float64   `json:"geotag_lat"`
+	GeotagLon        *float64   `json:"geotag_lon"`
+	VehicleMake      *string    `json:"vehicle_make"`
+	VehicleModel     *string    `json:"vehicle_model"`
+	VehicleColor     *string    `json:"vehicle_color"`
+	CameraSerial     *string    `json:"camera_serial"`
+	CameraIp         *string    `json:"camera_ip"`
+	RawJson          *string    `json:"raw_json"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ArchiveID        *int64     `json:"archive_id"`
+	JsonFilename     *string    `json:"json_filename"`
+	VehicleType      *string    `json:"vehicle_type"`
+	ConfidenceMmr    *string    `json:"confidence_mmr"`
+	ConfidenceColor  *string    `json:"confidence_color"`
+	PlateRegionCode  *string    `json:"plate_region_code"`
+	Unrecognized     bool       `json:"unrecognized"`
+	ManualPlate      *string    `json:"manual_plate"`
+	Source           string     `json:"source"`
+	OcrPlate         *string    `json:"ocr_plate"`
+	OcrConfidence    *float64   `json:"ocr_confidence"`
+	NodeID           *string    `json:"node_id"`
+	OriginID         *int64     `json:"origin_id"`
+	Uid              *string    `json:"uid"`
+	CapturedAt       *time.Time `json:"captured_at"`
+	TimestampError   *string    `json:"timestamp_error"`
+	ArrivalDelayMs   *int64     `json:"arrival_delay_ms"`
+	UpdatedAt        *time.Time `json:"updated_at"`
+	MessageCount     int64      `json:"message_count"`
+	PlateKey         *string    `json:"plate_key"`
+	ManualPlateKey   *string    `json:"manual_plate_key"`
+	AnonymizedAt     *time.Time `json:"anonymized_at"`
+	DuplicateOf      *int64     `json:"duplicate_of"`
+	Duplicates       int64      `json:"duplicates"`
+	Lane             *int64     `json:"lane"`
+	Direction        *string    `json:"direction"`
+	Vin              *string    `json:"vin"`
+	VinMake          *string    `json:"vin_make"`
+	VinModel         *string    `json:"vin_model"`
+	VinYear          *int64     `json:"vin_year"`
+}
+
+type EventMessage struct {
+	ID              int64     `json:"id"`
+	EventID         int64     `json:"event_id"`
+	Uid             string    `json:"uid"`
+	CarState        *string   `json:"car_state"`
+	PlateUtf8       *string   `json:"plate_utf8"`
+	RawJson         *string   `json:"raw_json"`
+	Images          int64     `json:"images"`
+	ReceivedAt      time.Time `json:"received_at"`
+	PlateConfidence *float64  `json:"plate_confidence"`
+	DedupKey        *string   `json:"dedup_key"`
+}
+
+type EventReview struct {
+	ArchiveID  int64     `json:"archive_id"`
+	EventID    int64     `json:"event_id"`
+	Username   string    `json:"username"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+}
+
+type ExportSignature struct {
+	ID        int64     `json:"id"`
+	Sha256    string    `json:"sha256"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`
+	KeyID     string    `json:"key_id"`
+	Signature string    `json:"signature"`
+	SignedAt  time.Time `json:"signed_at"`
+	SignedBy  *string   `json:"signed_by"`
+}
+
+type ForwardCursor struct {
+	Name        string    `json:"name"`
+	LastEventID int64     `json:"last_event_id"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type GroundTruth struct {
+	ArchiveID  int64     `json:"archive_id"`
+	EventID    int64     `json:"event_id"`
+	Plate      *string   `json:"plate"`
+	Maker      *string   `json:"maker"`
+	Model      *string   `json:"model"`
+	Color      *string   `json:"color"`
+	ImportedAt time.Time `json:"imported_at"`
 }
 
 type Image struct {
-	ID           int64     `json:"id"`
-	EventID      int64     `json:"event_id"`
-	ImageType    *string   `json:"image_type"`
-	Filename     *string   `json:"filename"`
-	ImageData    []byte    `json:"image_data"`
-	CreatedAt    time.Time `json:"created_at"`
-	DiskFilename *string   `json:"disk_filename"`
+	ID             int64      `json:"id"`
+	EventID        int64      `json:"event_id"`
+	ImageType      *string    `json:"image_type"`
+	Filename       *string    `json:"filename"`
+	ImageData      []byte     `json:"image_data"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DiskFilename   *string    `json:"disk_filename"`
+	SizeBytes      int64      `json:"size_bytes"`
+	Width          *int64     `json:"width"`
+	Height         *int64     `json:"height"`
+	Sharpness      *float64   `json:"sharpness"`
+	Quality        *float64   `json:"quality"`
+	MeasuredAt     *time.Time `json:"measured_at"`
+	ClassifiedType *string    `json:"classified_type"`
+	CapturedAt     *time.Time `json:"captured_at"`
+	Phash          *int64     `json:"phash"`
+	DeletedAt      *time.Time `json:"deleted_at"`
+	DeletedBy      *string    `json:"deleted_by"`
+	DeleteReason   *string    `json:"delete_reason"`
+}
+
+type ImportArchive struct {
+	ID         int64  `json:"id"`
+	ArchiveID  int64  `json:"archive_id"`
+	OriginNode string `json:"origin_node"`
+	OriginID   *int64 `json:"origin_id"`
+}
+
+type Lap struct {
+	ID        int64      `json:"id"`
+	ArchiveID *int64     `json:"archive_id"`
+	Number    int64      `json:"number"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
 }
 
 type Migration struct {
@@ -69,9 +263,146 @@ type Migration struct {
 	ExecutedAt      time.Time `json:"executed_at"`
 }
 
+type PushSubscription struct {
+	ID         int64      `json:"id"`
+	Endpoint   string     `json:"endpoint"`
+	P256dh     string     `json:"p256dh"`
+	Auth       string     `json:"auth"`
+	Username   *string    `json:"username"`
+	Topics     string     `json:"topics"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+	Failures   int64      `json:"failures"`
+}
+
+type ReviewSlice struct {
+	ID           int64     `json:"id"`
+	ArchiveID    int64     `json:"archive_id"`
+	Username     string    `json:"username"`
+	FirstEventID int64     `json:"first_event_id"`
+	LastEventID  int64     `json:"last_event_id"`
+	AssignedBy   *string   `json:"assigned_by"`
+	AssignedAt   time.Time `json:"assigned_at"`
+	Overlap      bool      `json:"overlap"`
+}
+
+type RollupArchiveAccuracy struct {
+	ArchiveID      int64     `json:"archive_id"`
+	Events         int64     `json:"events"`
+	PlateCorrect   int64     `json:"plate_correct"`
+	PlateIncorrect int64     `json:"plate_incorrect"`
+	MakerCorrect   int64     `json:"maker_correct"`
+	MakerIncorrect int64     `json:"maker_incorrect"`
+	ModelCorrect   int64     `json:"model_correct"`
+	ModelIncorrect int64     `json:"model_incorrect"`
+	ColorCorrect   int64     `json:"color_correct"`
+	ColorIncorrect int64     `json:"color_incorrect"`
+	Signature      string    `json:"signature"`
+	BuiltAt        time.Time `json:"built_at"`
+}
+
+type RollupArchiveCameraAccuracy struct {
+	ArchiveID      int64  `json:"archive_id"`
+	Camera         string `json:"camera"`
+	Events         int64  `json:"events"`
+	PlateCorrect   int64  `json:"plate_correct"`
+	PlateIncorrect int64  `json:"plate_incorrect"`
+	MakerCorrect   int64  `json:"maker_correct"`
+	MakerIncorrect int64  `json:"maker_incorrect"`
+	ModelCorrect   int64  `json:"model_correct"`
+	ModelIncorrect int64  `json:"model_incorrect"`
+	ColorCorrect   int64  `json:"color_correct"`
+	ColorIncorrect int64  `json:"color_incorrect"`
+}
+
+type RollupCameraHour struct {
+	Hour         string `json:"hour"`
+	Camera       string `json:"camera"`
+	Events       int64  `json:"events"`
+	Unrecognized int64  `json:"unrecognized"`
+	DelaySumMs   int64  `json:"delay_sum_ms"`
+	DelayCount   int64  `json:"delay_count"`
+}
+
+type RollupDay struct {
+	Day     string    `json:"day"`
+	BuiltAt time.Time `json:"built_at"`
+}
+
+type SecondOpinion struct {
+	ArchiveID  int64     `json:"archive_id"`
+	EventID    int64     `json:"event_id"`
+	Username   string    `json:"username"`
+	Plate      bool      `json:"plate"`
+	Maker      bool      `json:"maker"`
+	Model      bool      `json:"model"`
+	Color      bool      `json:"color"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+}
+
+type Session struct {
+	ID        int64     `json:"id"`
+	TokenHash string    `json:"token_hash"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RemoteIp  *string   `json:"remote_ip"`
+	UserAgent *string   `json:"user_agent"`
+}
+
+type TestVehicle struct {
+	ID             int64     `json:"id"`
+	Plate          string    `json:"plate"`
+	Label          *string   `json:"label"`
+	ExpectedPerLap int64     `json:"expected_per_lap"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type User struct {
+	ID           int64      `json:"id"`
+	Username     string     `json:"username"`
+	PasswordHash string     `json:"password_hash"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastLoginAt  *time.Time `json:"last_login_at"`
+}
+
 type Visitor struct {
 	ID        string    `json:"id"`
 	ViewCount int64     `json:"view_count"`
 	CreatedAt time.Time `json:"created_at"`
 	LastSeen  time.Time `json:"last_seen"`
 }
+
+type Watchlist struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	WebhookUrl *string   `json:"webhook_url"`
+	EmailTo    *string   `json:"email_to"`
+	Flag       bool      `json:"flag"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WatchlistEntry struct {
+	ID          int64      `json:"id"`
+	WatchlistID int64      `json:"watchlist_id"`
+	Plate       string     `json:"plate"`
+	Note        *string    `json:"note"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedBy   *string    `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type WatchlistHit struct {
+	ID            int64     `json:"id"`
+	EventID       int64     `json:"event_id"`
+	WatchlistID   int64     `json:"watchlist_id"`
+	EntryID       *int64    `json:"entry_id"`
+	Pattern       string    `json:"pattern"`
+	Plate         string    `json:"plate"`
+	Camera        *string   `json:"camera"`
+	Note          *string   `json:"note"`
+	CreatedAt     time.Time `json:"created_at"`
+	WebhookStatus *string   `json:"webhook_status"`
+	EmailStatus   *string   `json:"email_status"`
+}
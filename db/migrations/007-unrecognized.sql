@@ -0,0 +1,12 @@
+-- Image-only events (no recognition result from the camera)
+ALTER TABLE events ADD COLUMN unrecognized BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE events ADD COLUMN manual_plate TEXT;
+
+-- Backfill: events stored without a plate are no-reads
+UPDATE events SET unrecognized = 1 WHERE plate_utf8 IS NULL OR plate_utf8 = '';
+
+CREATE INDEX IF NOT EXISTS idx_events_unrecognized ON events(unrecognized);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (007, '007-unrecognized');
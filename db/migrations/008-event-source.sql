@@ -0,0 +1,7 @@
+-- Event source: 'camera' for ingested events, 'manual' for events entered
+-- by hand during supervised test passes
+ALTER TABLE events ADD COLUMN source TEXT NOT NULL DEFAULT 'camera';
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (008, '008-event-source');
@@ -0,0 +1,7 @@
+-- Candidate plate from the secondary OCR service for unrecognized events
+ALTER TABLE events ADD COLUMN ocr_plate TEXT;
+ALTER TABLE events ADD COLUMN ocr_confidence REAL;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (009, '009-ocr-suggestion');
@@ -0,0 +1,23 @@
+-- Vehicles that loop past the camera during drive-by testing
+CREATE TABLE IF NOT EXISTS test_vehicles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    plate TEXT NOT NULL UNIQUE,
+    label TEXT,
+    expected_per_lap INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Laps of the current session (archive_id NULL) or of an archived session
+CREATE TABLE IF NOT EXISTS laps (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    archive_id INTEGER REFERENCES archives(id) ON DELETE CASCADE,
+    number INTEGER NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_laps_archive ON laps(archive_id);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (010, '010-laps');
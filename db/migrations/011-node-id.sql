@@ -0,0 +1,10 @@
+-- Identifier of the edge server that recorded the event, so data merged from
+-- several instances stays attributable. Existing rows are stamped with the
+-- local node ID on startup.
+ALTER TABLE events ADD COLUMN node_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_events_node_id ON events(node_id);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (011, '011-node-id');
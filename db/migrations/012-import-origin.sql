@@ -0,0 +1,21 @@
+-- Events and archives merged in from another instance remember where they
+-- came from, so repeating an import doesn't duplicate them.
+-- origin_id is the event ID on the node in node_id; NULL for local events.
+ALTER TABLE events ADD COLUMN origin_id INTEGER;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_origin ON events(node_id, origin_id);
+
+-- Local archive holding the events of each imported source archive.
+-- origin_id is the archive ID on origin_node; NULL for that node's current session.
+CREATE TABLE IF NOT EXISTS import_archives (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    archive_id INTEGER NOT NULL REFERENCES archives(id) ON DELETE CASCADE,
+    origin_node TEXT NOT NULL,
+    origin_id INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_archives_origin ON import_archives(origin_node, origin_id);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (012, '012-import-origin');
@@ -0,0 +1,10 @@
+-- Globally unique, time-sortable event ID (ULID). Rowids collide once data
+-- from several instances is merged; the uid is kept across imports.
+-- Existing rows are assigned a uid on startup.
+ALTER TABLE events ADD COLUMN uid TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_uid ON events(uid);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (013, '013-event-uid');
@@ -0,0 +1,10 @@
+-- Stored image size, for storage quotas without reading every blob
+ALTER TABLE images ADD COLUMN size_bytes INTEGER NOT NULL DEFAULT 0;
+
+UPDATE images SET size_bytes = length(image_data);
+
+CREATE INDEX IF NOT EXISTS idx_images_event_id ON images(event_id);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (014, '014-image-size');
@@ -0,0 +1,11 @@
+-- Capture time parsed from event_datetime / capture_timestamp, which keep
+-- the original strings. timestamp_error is set when neither could be parsed.
+-- Existing rows are filled in by the server at startup.
+ALTER TABLE events ADD COLUMN captured_at DATETIME;
+ALTER TABLE events ADD COLUMN timestamp_error TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_events_captured_at ON events(captured_at);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (015, '015-captured-at');
@@ -0,0 +1,13 @@
+-- How long after capture an event reached us (created_at - captured_at), so
+-- events from store-and-forward cameras can be flagged as late arrivals
+ALTER TABLE events ADD COLUMN arrival_delay_ms INTEGER;
+
+-- Rows parsed before it was recorded keep their capture time; the server
+-- fills in their delay at startup
+
+-- Event lists are ordered by capture time, falling back to receive time
+CREATE INDEX IF NOT EXISTS idx_events_timeline ON events(COALESCE(captured_at, created_at));
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (016, '016-arrival-delay');
@@ -0,0 +1,14 @@
+-- Image measurements for picking the best plate and vehicle frame of an
+-- event: pixel size, sharpness (variance of the Laplacian) and an overall
+-- quality score. NULL until measured; undecodable images stay NULL.
+ALTER TABLE images ADD COLUMN width INTEGER;
+ALTER TABLE images ADD COLUMN height INTEGER;
+ALTER TABLE images ADD COLUMN sharpness REAL;
+ALTER TABLE images ADD COLUMN quality REAL;
+ALTER TABLE images ADD COLUMN measured_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_images_unmeasured ON images(id) WHERE measured_at IS NULL;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (017, '017-image-quality');
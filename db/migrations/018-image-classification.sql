@@ -0,0 +1,11 @@
+-- Plate vs vehicle guessed from image content for images whose camera
+-- filename or tag doesn't say ('uploaded', 'embedded', vendor names).
+-- image_type keeps what the camera sent.
+ALTER TABLE images ADD COLUMN classified_type TEXT;
+
+-- Measure untyped images again so they get classified
+UPDATE images SET measured_at = NULL WHERE COALESCE(image_type, '') NOT IN ('plate', 'vehicle');
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (018, '018-image-classification');
@@ -0,0 +1,10 @@
+-- Capture time of each frame, from the camera's per-image timestamp or the
+-- JPEG's EXIF data, for the event page's frame sequence viewer
+ALTER TABLE images ADD COLUMN captured_at TIMESTAMP;
+
+-- Measure JPEGs again to read their EXIF capture times
+UPDATE images SET measured_at = NULL WHERE substr(image_data, 1, 2) = X'FFD8';
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (019, '019-frame-times');
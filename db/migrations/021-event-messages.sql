@@ -0,0 +1,26 @@
+-- Cameras send several messages per car (carState new → update → lost).
+-- Continuation messages are merged into the event of the first one; every
+-- message is recorded here. raw_json is kept for continuations only, the
+-- first message's JSON is the event's raw_json.
+CREATE TABLE IF NOT EXISTS event_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL REFERENCES events(id),
+    uid TEXT NOT NULL UNIQUE,
+    car_state TEXT,
+    plate_utf8 TEXT,
+    raw_json TEXT,
+    images INTEGER NOT NULL DEFAULT 0,
+    received_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_messages_event ON event_messages(event_id);
+
+ALTER TABLE events ADD COLUMN updated_at TIMESTAMP;
+ALTER TABLE events ADD COLUMN message_count INTEGER NOT NULL DEFAULT 1;
+
+-- Open events of a car are looked up by car ID on every continuation
+CREATE INDEX IF NOT EXISTS idx_events_car_id ON events(car_id) WHERE archive_id IS NULL;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (021, '021-event-messages');
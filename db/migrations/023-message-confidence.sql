@@ -0,0 +1,7 @@
+-- Plate confidence of each camera message, to find the best read of a
+-- vehicle's lifecycle
+ALTER TABLE event_messages ADD COLUMN plate_confidence REAL;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (023, '023-message-confidence');
@@ -0,0 +1,14 @@
+-- Normalized plates (upper case, no spaces or dashes) for /api/search.
+-- Indexed columns let wildcard searches with a literal prefix ("ABC*") use
+-- an index range instead of scanning every event.
+ALTER TABLE events ADD COLUMN plate_key TEXT
+    GENERATED ALWAYS AS (REPLACE(REPLACE(UPPER(plate_utf8), ' ', ''), '-', '')) VIRTUAL;
+ALTER TABLE events ADD COLUMN manual_plate_key TEXT
+    GENERATED ALWAYS AS (REPLACE(REPLACE(UPPER(manual_plate), ' ', ''), '-', '')) VIRTUAL;
+
+CREATE INDEX IF NOT EXISTS idx_events_plate_key ON events(plate_key);
+CREATE INDEX IF NOT EXISTS idx_events_manual_plate_key ON events(manual_plate_key);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (024, '024-plate-search');
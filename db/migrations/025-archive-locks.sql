@@ -0,0 +1,23 @@
+-- Locking an archive after review records a Merkle root over its events,
+-- images and compare results; verification recomputes it to show the data
+-- wasn't altered. Leaves are kept per event so changes can be pinpointed.
+CREATE TABLE IF NOT EXISTS archive_locks (
+    archive_id INTEGER PRIMARY KEY REFERENCES archives(id),
+    merkle_root TEXT NOT NULL,  -- hex SHA-256
+    event_count INTEGER NOT NULL,
+    locked_at TIMESTAMP NOT NULL,
+    locked_by TEXT,
+    verified_at TIMESTAMP,
+    verified_ok BOOLEAN
+);
+
+CREATE TABLE IF NOT EXISTS archive_lock_leaves (
+    archive_id INTEGER NOT NULL REFERENCES archives(id),
+    event_id INTEGER NOT NULL,
+    leaf_hash TEXT NOT NULL,
+    PRIMARY KEY (archive_id, event_id)
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (025, '025-archive-locks');
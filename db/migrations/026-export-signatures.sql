@@ -0,0 +1,18 @@
+-- With -signing-key every exported file is signed; the signature is kept so
+-- a file handed over later can be checked against what the server exported.
+CREATE TABLE IF NOT EXISTS export_signatures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sha256 TEXT NOT NULL,     -- hex SHA-256 of the file
+    filename TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    key_id TEXT NOT NULL,     -- fingerprint of the signing key
+    signature TEXT NOT NULL,  -- base64 Ed25519 signature of the file
+    signed_at TIMESTAMP NOT NULL,
+    signed_by TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_signatures_sha256 ON export_signatures(sha256);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (026, '026-export-signatures');
@@ -0,0 +1,32 @@
+-- Deleting an archive moves it to the trash, from where it can be restored
+-- or purged. A legal hold keeps an archive's data through deletion, trash
+-- retention and quota pruning while it is subject to a dispute. Every hold,
+-- delete, restore and purge is recorded in the audit log, which outlives
+-- the archive.
+CREATE TABLE IF NOT EXISTS archive_trash (
+    archive_id INTEGER PRIMARY KEY REFERENCES archives(id),
+    deleted_at TIMESTAMP NOT NULL,
+    deleted_by TEXT
+);
+
+CREATE TABLE IF NOT EXISTS archive_holds (
+    archive_id INTEGER PRIMARY KEY REFERENCES archives(id),
+    reason TEXT NOT NULL,
+    placed_at TIMESTAMP NOT NULL,
+    placed_by TEXT
+);
+
+CREATE TABLE IF NOT EXISTS archive_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    archive_id INTEGER NOT NULL,
+    action TEXT NOT NULL,  -- hold, release, delete, restore, purge, blocked
+    detail TEXT,
+    actor TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_archive_audit_archive ON archive_audit(archive_id);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (027, '027-archive-holds');
@@ -0,0 +1,10 @@
+-- The privacy policy (-privacy-config) anonymizes events after N days:
+-- plates are hashed or stripped and overview images deleted, while the
+-- event rows and compare results stay for statistics.
+ALTER TABLE events ADD COLUMN anonymized_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_events_anonymized ON events(anonymized_at);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (028, '028-anonymization');
@@ -0,0 +1,15 @@
+-- Cameras in store-and-forward mode resend messages they aren't sure were
+-- delivered. Every message records a dedup key: its camera, carID and
+-- packetCounter, else a hash of its JSON. A message whose key was seen
+-- within the dedup window is a duplicate: it is ignored, or stored as an
+-- event linked to the original by duplicate_of, and counted on the original.
+ALTER TABLE event_messages ADD COLUMN dedup_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_event_messages_dedup ON event_messages(dedup_key) WHERE dedup_key IS NOT NULL;
+
+ALTER TABLE events ADD COLUMN duplicate_of INTEGER;
+ALTER TABLE events ADD COLUMN duplicates INTEGER NOT NULL DEFAULT 0;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (029, '029-duplicates');
@@ -0,0 +1,43 @@
+-- Analytics read rollups instead of scanning events. A background job
+-- rebuilds the hours of every day that received events since its last run
+-- and the accuracy of every reviewed archive whose compare results changed.
+-- hour is the local capture hour ('2006-01-02 15'), camera the serial
+-- number, else the sensor provider ID. Rollups keep counting events purged
+-- later; an archive's accuracy goes with the archive.
+CREATE TABLE IF NOT EXISTS rollup_camera_hours (
+    hour TEXT NOT NULL,
+    camera TEXT NOT NULL,
+    events INTEGER NOT NULL,
+    unrecognized INTEGER NOT NULL,
+    delay_sum_ms INTEGER NOT NULL,
+    delay_count INTEGER NOT NULL,
+    PRIMARY KEY (hour, camera)
+);
+
+-- Days rebuilt; built_at is when the run that rebuilt them started
+CREATE TABLE IF NOT EXISTS rollup_days (
+    day TEXT PRIMARY KEY,
+    built_at TIMESTAMP NOT NULL
+);
+
+-- Correct and incorrect reads per compare field, by the rules of the
+-- compare workbook's Statistics sheet. signature summarizes the compare
+-- results and manual plates it was built from.
+CREATE TABLE IF NOT EXISTS rollup_archive_accuracy (
+    archive_id INTEGER PRIMARY KEY REFERENCES archives(id) ON DELETE CASCADE,
+    events INTEGER NOT NULL,
+    plate_correct INTEGER NOT NULL,
+    plate_incorrect INTEGER NOT NULL,
+    maker_correct INTEGER NOT NULL,
+    maker_incorrect INTEGER NOT NULL,
+    model_correct INTEGER NOT NULL,
+    model_incorrect INTEGER NOT NULL,
+    color_correct INTEGER NOT NULL,
+    color_incorrect INTEGER NOT NULL,
+    signature TEXT NOT NULL,
+    built_at TIMESTAMP NOT NULL
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (030, '030-rollups');
@@ -0,0 +1,15 @@
+-- Compacting an archive drops the camera JSON and overview images of its
+-- events but keeps the event rows, plate crops and compare results, so old
+-- sessions stay available for statistics at a fraction of the space. The
+-- totals add up over repeated compactions.
+CREATE TABLE IF NOT EXISTS archive_compactions (
+    archive_id INTEGER PRIMARY KEY REFERENCES archives(id),
+    compacted_at TIMESTAMP NOT NULL,
+    compacted_by TEXT,
+    images_removed INTEGER NOT NULL,
+    bytes_freed INTEGER NOT NULL
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (031, '031-archive-compaction');
@@ -0,0 +1,11 @@
+-- Perceptual hash (64-bit dHash of the grayscale image) for finding similar
+-- images: the same cached frame sent again, or the same vehicle captured
+-- twice. Images that differ in a few bits look alike. Set when an image is
+-- measured; images measured before this are hashed in the background.
+ALTER TABLE images ADD COLUMN phash INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_images_unhashed ON images(id) WHERE phash IS NULL AND width IS NOT NULL;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (032, '032-image-hashes');
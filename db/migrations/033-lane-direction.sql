@@ -0,0 +1,8 @@
+-- Multi-lane cameras (Dahua ITC and others) report the lane a vehicle was
+-- read in and whether it was approaching or leaving the camera.
+ALTER TABLE events ADD COLUMN lane INTEGER;
+ALTER TABLE events ADD COLUMN direction TEXT;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (033, '033-lane-direction');
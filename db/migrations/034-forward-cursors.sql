@@ -0,0 +1,12 @@
+-- Integrations that forward stored reads to another system (Genetec
+-- Security Center) remember the last event they delivered, so a restart or
+-- an outage of the other side resumes where forwarding stopped.
+CREATE TABLE IF NOT EXISTS forward_cursors (
+    name TEXT PRIMARY KEY,
+    last_event_id INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (034, '034-forward-cursors');
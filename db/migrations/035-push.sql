@@ -0,0 +1,26 @@
+-- Browser push (Web Push) subscriptions of reviewers. Each browser that
+-- turned notifications on has one row; topics is a comma-separated list of
+-- what it wants to hear about (session, export, review).
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    endpoint TEXT NOT NULL UNIQUE,
+    p256dh TEXT NOT NULL,
+    auth TEXT NOT NULL,
+    username TEXT,
+    topics TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_sent_at TIMESTAMP,
+    failures INTEGER NOT NULL DEFAULT 0
+);
+
+-- The user an archive is assigned to for review
+CREATE TABLE IF NOT EXISTS archive_reviewers (
+    archive_id INTEGER PRIMARY KEY REFERENCES archives(id),
+    username TEXT NOT NULL,
+    assigned_by TEXT,
+    assigned_at TIMESTAMP NOT NULL
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (035, '035-push');
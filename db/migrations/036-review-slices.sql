@@ -0,0 +1,27 @@
+-- Review workload: slices of an archive's events (by event id range)
+-- assigned to reviewers, and who reviewed each event. Events outside every
+-- slice belong to the archive's reviewer, if any (archive_reviewers).
+CREATE TABLE IF NOT EXISTS review_slices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    archive_id INTEGER NOT NULL REFERENCES archives(id),
+    username TEXT NOT NULL,
+    first_event_id INTEGER NOT NULL,
+    last_event_id INTEGER NOT NULL,
+    assigned_by TEXT,
+    assigned_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_slices_archive ON review_slices(archive_id);
+
+-- The first user to save a verdict for an event (or mark it reviewed)
+CREATE TABLE IF NOT EXISTS event_reviews (
+    archive_id INTEGER NOT NULL REFERENCES archives(id),
+    event_id INTEGER NOT NULL,
+    username TEXT NOT NULL,
+    reviewed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (archive_id, event_id)
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (036, '036-review-slices');
@@ -0,0 +1,20 @@
+-- Inter-annotator agreement: an overlap slice gives events another reviewer
+-- already owns to a second reviewer, whose verdicts are kept apart from the
+-- archive's compare results (one row per event, true = incorrect).
+ALTER TABLE review_slices ADD COLUMN overlap BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS second_opinions (
+    archive_id INTEGER NOT NULL REFERENCES archives(id),
+    event_id INTEGER NOT NULL,
+    username TEXT NOT NULL,
+    plate BOOLEAN NOT NULL DEFAULT 0,
+    maker BOOLEAN NOT NULL DEFAULT 0,
+    model BOOLEAN NOT NULL DEFAULT 0,
+    color BOOLEAN NOT NULL DEFAULT 0,
+    reviewed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (archive_id, event_id, username)
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (037, '037-second-opinions');
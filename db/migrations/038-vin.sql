@@ -0,0 +1,11 @@
+-- Some integrations (access control badges) know the vehicle's VIN. The
+-- make, model and model year decoded from it are the ground truth for MMR
+-- accuracy.
+ALTER TABLE events ADD COLUMN vin TEXT;
+ALTER TABLE events ADD COLUMN vin_make TEXT;
+ALTER TABLE events ADD COLUMN vin_model TEXT;
+ALTER TABLE events ADD COLUMN vin_year INTEGER;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (038, '038-vin');
@@ -0,0 +1,38 @@
+-- Each camera's accuracy in a reviewed archive, by the same rules as
+-- rollup_archive_accuracy, so a session can be compared with the camera's
+-- earlier ones. Rebuilt with the archive's row.
+CREATE TABLE IF NOT EXISTS rollup_archive_camera_accuracy (
+    archive_id INTEGER NOT NULL REFERENCES archives(id) ON DELETE CASCADE,
+    camera TEXT NOT NULL,
+    events INTEGER NOT NULL,
+    plate_correct INTEGER NOT NULL,
+    plate_incorrect INTEGER NOT NULL,
+    maker_correct INTEGER NOT NULL,
+    maker_incorrect INTEGER NOT NULL,
+    model_correct INTEGER NOT NULL,
+    model_incorrect INTEGER NOT NULL,
+    color_correct INTEGER NOT NULL,
+    color_incorrect INTEGER NOT NULL,
+    PRIMARY KEY (archive_id, camera)
+);
+
+-- Compare fields whose accuracy in an archive dropped further below the
+-- camera's baseline (its previous reviewed archives) than allowed. A row
+-- goes away when later verdicts bring the field back within bounds.
+CREATE TABLE IF NOT EXISTS accuracy_alarms (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    archive_id INTEGER NOT NULL REFERENCES archives(id) ON DELETE CASCADE,
+    camera TEXT NOT NULL,
+    field TEXT NOT NULL,
+    correct INTEGER NOT NULL,
+    incorrect INTEGER NOT NULL,
+    pct REAL NOT NULL,
+    baseline_pct REAL NOT NULL,
+    baseline_archives INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (archive_id, camera, field)
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (039, '039-accuracy-alarms');
@@ -0,0 +1,48 @@
+-- Plate watchlists managed on /watchlists. Every stored read is checked
+-- against the entries of the enabled lists; a match is recorded as a hit
+-- and triggers the list's actions.
+CREATE TABLE IF NOT EXISTS watchlists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    webhook_url TEXT,                  -- POSTed each hit as JSON
+    email_to TEXT,                     -- comma-separated; sent through -email-config
+    flag BOOLEAN NOT NULL DEFAULT 1,   -- mark hits on the dashboard
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Normalized plates (no spaces, dashes or dots); * and ? are wildcards
+CREATE TABLE IF NOT EXISTS watchlist_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    watchlist_id INTEGER NOT NULL REFERENCES watchlists(id) ON DELETE CASCADE,
+    plate TEXT NOT NULL,
+    note TEXT,
+    expires_at TIMESTAMP,
+    created_by TEXT,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (watchlist_id, plate)
+);
+
+-- One row per event and list. The entry is copied so the hit outlives it;
+-- the action statuses are "sent", "cooldown" or the error, NULL without
+-- the action.
+CREATE TABLE IF NOT EXISTS watchlist_hits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    watchlist_id INTEGER NOT NULL REFERENCES watchlists(id) ON DELETE CASCADE,
+    entry_id INTEGER REFERENCES watchlist_entries(id) ON DELETE SET NULL,
+    pattern TEXT NOT NULL,
+    plate TEXT NOT NULL,
+    camera TEXT,
+    note TEXT,
+    created_at TIMESTAMP NOT NULL,
+    webhook_status TEXT,
+    email_status TEXT,
+    UNIQUE (event_id, watchlist_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_watchlist_hits_watchlist ON watchlist_hits(watchlist_id, id);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (040, '040-watchlists');
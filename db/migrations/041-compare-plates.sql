@@ -0,0 +1,18 @@
+-- The plate a reviewer (or a test vehicle, see platematch.go) says an
+-- archived event really had. The read's weighted edit distance to it
+-- classifies near misses within -plate-match-config's maximum as partially
+-- correct; the plate verdict in compare_results stays incorrect.
+CREATE TABLE IF NOT EXISTS compare_plates (
+    archive_id INTEGER NOT NULL REFERENCES archives(id) ON DELETE CASCADE,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    true_plate TEXT NOT NULL,          -- normalized
+    source TEXT NOT NULL,              -- 'reviewer' or 'test_vehicle'
+    distance REAL NOT NULL,
+    partial BOOLEAN NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (archive_id, event_id)
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (041, '041-compare-plates');
@@ -0,0 +1,28 @@
+-- Imported ground truth of an archive's events: what the vehicle really
+-- was, from a reference system or a spreadsheet. The auto-compare job (see
+-- groundtruth.go) turns it into compare verdicts.
+CREATE TABLE IF NOT EXISTS ground_truth (
+    archive_id INTEGER NOT NULL REFERENCES archives(id) ON DELETE CASCADE,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    plate TEXT,                        -- normalized; NULL when not known
+    maker TEXT,
+    model TEXT,
+    color TEXT,
+    imported_at DATETIME NOT NULL,
+    PRIMARY KEY (archive_id, event_id)
+);
+
+-- The verdicts the auto-compare job set. A compare result that differs
+-- from it, or was there before it, is a reviewer's override and is kept.
+CREATE TABLE IF NOT EXISTS auto_compare_results (
+    archive_id INTEGER NOT NULL REFERENCES archives(id) ON DELETE CASCADE,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    field TEXT NOT NULL,
+    is_incorrect BOOLEAN NOT NULL,
+    compared_at DATETIME NOT NULL,
+    PRIMARY KEY (archive_id, event_id, field)
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (042, '042-ground-truth');
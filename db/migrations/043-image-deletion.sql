@@ -0,0 +1,11 @@
+-- Images removed from an event by a reviewer (a frame of another vehicle,
+-- an inappropriate picture) are soft-deleted: they stay stored and can be
+-- restored, but aren't picked as the event's best images, exported or
+-- matched any more.
+ALTER TABLE images ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE images ADD COLUMN deleted_by TEXT;
+ALTER TABLE images ADD COLUMN delete_reason TEXT;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (043, '043-image-deletion');
@@ -0,0 +1,22 @@
+-- The plate and vehicle image shown for an event in lists, exports and
+-- the event page (see imagequality.go for the rules). Queries join it on
+-- event_id instead of repeating the subqueries; both IDs are NULL for an
+-- event without (undeleted) images.
+CREATE VIEW IF NOT EXISTS event_best_images AS
+SELECT
+    e.id AS event_id,
+    COALESCE((SELECT id FROM images WHERE event_id = e.id AND deleted_at IS NULL AND image_type = 'plate'
+              ORDER BY quality IS NULL, quality DESC, id LIMIT 1),
+             (SELECT id FROM images WHERE event_id = e.id AND deleted_at IS NULL AND classified_type = 'plate'
+              ORDER BY quality DESC, id LIMIT 1),
+             (SELECT id FROM images WHERE event_id = e.id AND deleted_at IS NULL ORDER BY id LIMIT 1 OFFSET 1)) AS plate_image_id,
+    COALESCE((SELECT id FROM images WHERE event_id = e.id AND deleted_at IS NULL AND image_type = 'vehicle'
+              ORDER BY quality IS NULL, quality DESC, id LIMIT 1),
+             (SELECT id FROM images WHERE event_id = e.id AND deleted_at IS NULL AND classified_type = 'vehicle'
+              ORDER BY quality DESC, id LIMIT 1),
+             (SELECT id FROM images WHERE event_id = e.id AND deleted_at IS NULL ORDER BY id LIMIT 1)) AS vehicle_image_id
+FROM events e;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (044, '044-event-best-images');
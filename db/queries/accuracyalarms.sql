@@ -0,0 +1,38 @@
+-- name: DeleteArchiveCameraAccuracy :exec
+DELETE FROM rollup_archive_camera_accuracy WHERE archive_id = ?;
+
+-- name: InsertArchiveCameraAccuracy :exec
+INSERT INTO rollup_archive_camera_accuracy (archive_id, camera, events, plate_correct, plate_incorrect,
+    maker_correct, maker_incorrect, model_correct, model_incorrect, color_correct, color_incorrect)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetArchiveCameraAccuracy :many
+SELECT * FROM rollup_archive_camera_accuracy WHERE archive_id = ? ORDER BY camera;
+
+-- name: GetCameraAccuracyBaseline :many
+SELECT * FROM rollup_archive_camera_accuracy
+WHERE camera = sqlc.arg(camera) AND archive_id < sqlc.arg(archive_id)
+  AND archive_id IN (SELECT archive_id FROM compare_results)
+ORDER BY archive_id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: GetArchiveAccuracyAlarms :many
+SELECT * FROM accuracy_alarms WHERE archive_id = ? ORDER BY camera, field;
+
+-- name: UpsertAccuracyAlarm :exec
+INSERT INTO accuracy_alarms (archive_id, camera, field, correct, incorrect, pct, baseline_pct, baseline_archives)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(archive_id, camera, field) DO UPDATE SET
+    correct = excluded.correct, incorrect = excluded.incorrect, pct = excluded.pct,
+    baseline_pct = excluded.baseline_pct, baseline_archives = excluded.baseline_archives;
+
+-- name: DeleteAccuracyAlarm :exec
+DELETE FROM accuracy_alarms WHERE id = ?;
+
+-- name: GetAccuracyAlarms :many
+SELECT al.id, al.archive_id, a.name AS archive_name, al.camera, al.field, al.correct, al.incorrect,
+       al.pct, al.baseline_pct, al.baseline_archives, al.created_at
+FROM accuracy_alarms al
+JOIN archives a ON a.id = al.archive_id
+ORDER BY al.id DESC
+LIMIT ?;
@@ -0,0 +1,25 @@
+-- name: GetAPIKeys :many
+SELECT * FROM api_keys ORDER BY id;
+
+-- name: GetAPIKey :one
+SELECT * FROM api_keys WHERE id = ?;
+
+-- name: GetAPIKeyByHash :one
+SELECT * FROM api_keys WHERE key_hash = ?;
+
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (name, key_hash, key_prefix, created_at)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: SetAPIKeyEnabled :execrows
+UPDATE api_keys SET enabled = ? WHERE id = ?;
+
+-- name: DeleteAPIKey :execrows
+DELETE FROM api_keys WHERE id = ?;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys SET last_used_at = ?, last_used_ip = ?, use_count = use_count + 1 WHERE id = ?;
+
+-- name: CountEnabledAPIKeys :one
+SELECT COUNT(*) FROM api_keys WHERE enabled = 1;
@@ -0,0 +1,41 @@
+-- name: GetArchiveHold :one
+SELECT * FROM archive_holds WHERE archive_id = ?;
+
+-- name: PlaceArchiveHold :exec
+INSERT INTO archive_holds (archive_id, reason, placed_at, placed_by) VALUES (?, ?, ?, ?)
+ON CONFLICT(archive_id) DO UPDATE SET reason = excluded.reason;
+
+-- name: ReleaseArchiveHold :execrows
+DELETE FROM archive_holds WHERE archive_id = ?;
+
+-- name: GetHeldArchiveIDs :many
+SELECT archive_id FROM archive_holds ORDER BY archive_id;
+
+-- name: TrashArchive :exec
+INSERT INTO archive_trash (archive_id, deleted_at, deleted_by) VALUES (?, ?, ?);
+
+-- name: RestoreArchive :execrows
+DELETE FROM archive_trash WHERE archive_id = ?;
+
+-- name: GetArchiveTrash :one
+SELECT * FROM archive_trash WHERE archive_id = ?;
+
+-- name: GetTrashedArchives :many
+SELECT a.id, a.name, a.event_count, a.created_at, t.deleted_at, t.deleted_by,
+    h.reason AS hold_reason
+FROM archive_trash t
+JOIN archives a ON a.id = t.archive_id
+LEFT JOIN archive_holds h ON h.archive_id = t.archive_id
+ORDER BY t.deleted_at DESC;
+
+-- name: GetExpiredTrash :many
+SELECT t.archive_id FROM archive_trash t
+WHERE t.deleted_at < ?
+  AND t.archive_id NOT IN (SELECT archive_id FROM archive_holds)
+ORDER BY t.archive_id;
+
+-- name: InsertArchiveAudit :exec
+INSERT INTO archive_audit (archive_id, action, detail, actor, created_at) VALUES (?, ?, ?, ?, ?);
+
+-- name: GetArchiveAudit :many
+SELECT * FROM archive_audit WHERE archive_id = ? ORDER BY id DESC;
@@ -0,0 +1,21 @@
+-- name: GetArchiveLock :one
+SELECT * FROM archive_locks WHERE archive_id = ?;
+
+-- name: CreateArchiveLock :exec
+INSERT INTO archive_locks (archive_id, merkle_root, event_count, locked_at, locked_by)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: InsertArchiveLockLeaf :exec
+INSERT INTO archive_lock_leaves (archive_id, event_id, leaf_hash) VALUES (?, ?, ?);
+
+-- name: GetArchiveLockLeaves :many
+SELECT event_id, leaf_hash FROM archive_lock_leaves WHERE archive_id = ? ORDER BY event_id;
+
+-- name: SetArchiveVerified :exec
+UPDATE archive_locks SET verified_at = ?, verified_ok = ? WHERE archive_id = ?;
+
+-- name: GetArchiveEventsForLock :many
+SELECT * FROM events WHERE archive_id = ? ORDER BY id;
+
+-- name: GetEventImagesForLock :many
+SELECT id, image_type, image_data, disk_filename FROM images WHERE event_id = ? ORDER BY id;
@@ -0,0 +1,10 @@
+-- name: GetBundleEvents :many
+SELECT id, uid, car_id, plate_utf8, manual_plate, camera_serial, sensor_provider_id, captured_at, created_at
+FROM events
+WHERE archive_id IS sqlc.narg(archive_id)
+  AND (CAST(sqlc.narg(captured_from) AS TIMESTAMP) IS NULL OR COALESCE(captured_at, created_at) >= sqlc.narg(captured_from))
+  AND (CAST(sqlc.narg(captured_to) AS TIMESTAMP) IS NULL OR COALESCE(captured_at, created_at) < sqlc.narg(captured_to))
+ORDER BY id;
+
+-- name: GetEventRawJSON :one
+SELECT raw_json, json_filename FROM events WHERE id = ?;
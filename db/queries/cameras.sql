@@ -0,0 +1,10 @@
+-- name: GetCameraLastReads :many
+SELECT CAST(COALESCE(camera_serial, sensor_provider_id) AS TEXT) AS camera, created_at
+FROM events
+WHERE id IN (
+    SELECT MAX(id) FROM events
+    WHERE created_at >= sqlc.arg(since) AND source = 'camera' AND origin_id IS NULL
+      AND COALESCE(camera_serial, sensor_provider_id) IS NOT NULL
+    GROUP BY COALESCE(camera_serial, sensor_provider_id)
+)
+ORDER BY camera;
@@ -0,0 +1,33 @@
+-- name: GetArchiveCompaction :one
+SELECT archive_id, compacted_at, compacted_by, images_removed, bytes_freed
+FROM archive_compactions WHERE archive_id = ?;
+
+-- name: GetArchiveJSONToCompact :many
+SELECT id, json_filename, CAST(COALESCE(LENGTH(raw_json), 0) AS INTEGER) AS json_bytes
+FROM events
+WHERE archive_id = ? AND (raw_json IS NOT NULL OR json_filename IS NOT NULL)
+ORDER BY id;
+
+-- name: GetArchiveOverviewImages :many
+SELECT i.id, i.disk_filename, i.size_bytes,
+       CAST(COALESCE(LENGTH(i.image_data), 0) AS INTEGER) AS image_db_bytes
+FROM images i
+JOIN events e ON e.id = i.event_id
+WHERE e.archive_id = ? AND COALESCE(i.classified_type, i.image_type, '') != 'plate'
+ORDER BY i.id;
+
+-- name: CompactArchiveEvents :exec
+UPDATE events SET raw_json = NULL, json_filename = NULL WHERE archive_id = ?;
+
+-- name: CompactArchiveMessages :exec
+UPDATE event_messages SET raw_json = NULL
+WHERE event_id IN (SELECT id FROM events WHERE archive_id = ?);
+
+-- name: RecordArchiveCompaction :exec
+INSERT INTO archive_compactions (archive_id, compacted_at, compacted_by, images_removed, bytes_freed)
+VALUES (sqlc.arg(archive_id), sqlc.arg(compacted_at), sqlc.narg(compacted_by), sqlc.arg(images_removed), sqlc.arg(bytes_freed))
+ON CONFLICT (archive_id) DO UPDATE SET
+    compacted_at = excluded.compacted_at,
+    compacted_by = excluded.compacted_by,
+    images_removed = archive_compactions.images_removed + excluded.images_removed,
+    bytes_freed = archive_compactions.bytes_freed + excluded.bytes_freed;
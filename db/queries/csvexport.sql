@@ -0,0 +1,14 @@
+-- name: GetCSVEvents :many
+SELECT e.id, e.car_id, e.plate_utf8, e.manual_plate, e.plate_country, e.plate_confidence,
+    e.vehicle_make, e.vehicle_model, e.vehicle_color, e.confidence_mmr, e.confidence_color,
+    e.camera_serial, e.sensor_provider_id, e.geotag_lat, e.geotag_lon, e.captured_at, e.created_at
+FROM events e
+WHERE e.archive_id IS sqlc.narg(archive_id)
+  AND (CAST(sqlc.narg(plate) AS TEXT) IS NULL OR UPPER(e.plate_utf8) GLOB sqlc.narg(plate) OR UPPER(e.manual_plate) GLOB sqlc.narg(plate))
+  AND (CAST(sqlc.narg(camera) AS TEXT) IS NULL OR e.camera_serial = sqlc.narg(camera) COLLATE NOCASE
+       OR e.sensor_provider_id = sqlc.narg(camera) COLLATE NOCASE OR e.camera_ip = sqlc.narg(camera))
+  AND (CAST(sqlc.narg(country) AS TEXT) IS NULL OR e.plate_country = sqlc.narg(country) COLLATE NOCASE)
+  AND (CAST(sqlc.narg(car_state) AS TEXT) IS NULL OR e.car_state = sqlc.narg(car_state) COLLATE NOCASE)
+  AND (CAST(sqlc.narg(captured_from) AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) >= sqlc.narg(captured_from))
+  AND (CAST(sqlc.narg(captured_to) AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) < sqlc.narg(captured_to))
+ORDER BY e.id;
@@ -4,14 +4,16 @@ INSERT INTO events (
     event_datetime, capture_timestamp, plate_country, plate_region, plate_region_code, plate_confidence,
     geotag_lat, geotag_lon, vehicle_make, vehicle_model, vehicle_color,
     vehicle_type, confidence_mmr, confidence_color,
-    camera_serial, camera_ip, raw_json, created_at
+    camera_serial, camera_ip, lane, direction, raw_json, unrecognized, source, node_id, uid,
+    captured_at, timestamp_error, arrival_delay_ms, created_at
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 ) RETURNING id;
 
--- name: InsertImage :exec
-INSERT INTO images (event_id, image_type, filename, image_data, created_at)
-VALUES (?, ?, ?, ?, ?);
+-- name: InsertImage :one
+INSERT INTO images (event_id, image_type, filename, image_data, size_bytes, created_at, captured_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING id;
 
 -- name: GetRecentEvents :many
 SELECT 
@@ -19,15 +21,68 @@ SELECT
     e.event_datetime, e.created_at, e.plate_country, e.plate_region, e.plate_region_code,
     e.vehicle_make, e.vehicle_model, e.vehicle_color, e.vehicle_type,
     e.plate_confidence, e.confidence_mmr, e.confidence_color,
-    e.json_filename,
-    COALESCE((SELECT id FROM images WHERE event_id = e.id AND image_type = 'plate' LIMIT 1),
-             (SELECT id FROM images WHERE event_id = e.id ORDER BY id LIMIT 1 OFFSET 1), 0) as plate_image_id,
-    COALESCE((SELECT id FROM images WHERE event_id = e.id AND image_type = 'vehicle' LIMIT 1),
-             (SELECT id FROM images WHERE event_id = e.id ORDER BY id LIMIT 1), 0) as vehicle_image_id
+    e.json_filename, e.unrecognized, e.manual_plate, e.source, e.node_id, e.uid,
+    e.captured_at, e.arrival_delay_ms,
+    COALESCE(best.plate_image_id, 0) as plate_image_id,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id
 FROM events e
+JOIN event_best_images best ON best.event_id = e.id
 WHERE e.archive_id IS NULL
-ORDER BY e.created_at DESC
-LIMIT ?;
+  AND (CAST(sqlc.narg(plate) AS TEXT) IS NULL OR UPPER(e.plate_utf8) GLOB sqlc.narg(plate) OR UPPER(e.manual_plate) GLOB sqlc.narg(plate))
+  AND (CAST(sqlc.narg(camera) AS TEXT) IS NULL OR e.camera_serial = sqlc.narg(camera) COLLATE NOCASE
+       OR e.sensor_provider_id = sqlc.narg(camera) COLLATE NOCASE OR e.camera_ip = sqlc.narg(camera))
+  AND (CAST(sqlc.narg(country) AS TEXT) IS NULL OR e.plate_country = sqlc.narg(country) COLLATE NOCASE)
+  AND (CAST(sqlc.narg(car_state) AS TEXT) IS NULL OR e.car_state = sqlc.narg(car_state) COLLATE NOCASE)
+  AND (CAST(sqlc.narg(captured_from) AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) >= sqlc.narg(captured_from))
+  AND (CAST(sqlc.narg(captured_to) AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) < sqlc.narg(captured_to))
+ORDER BY CASE WHEN CAST(sqlc.arg(by_received) AS BOOLEAN) THEN e.created_at ELSE COALESCE(e.captured_at, e.created_at) END DESC, e.id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: GetEventsSince :many
+SELECT 
+    e.id, e.car_id, e.plate_utf8, e.car_state, e.sensor_provider_id, 
+    e.event_datetime, e.created_at, e.plate_country, e.plate_region, e.plate_region_code,
+    e.vehicle_make, e.vehicle_model, e.vehicle_color, e.vehicle_type,
+    e.plate_confidence, e.confidence_mmr, e.confidence_color,
+    e.json_filename, e.unrecognized, e.manual_plate, e.source, e.node_id, e.uid,
+    e.captured_at, e.arrival_delay_ms,
+    COALESCE(best.plate_image_id, 0) as plate_image_id,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+WHERE e.archive_id IS NULL AND e.id > sqlc.arg(since_id)
+  AND (CAST(sqlc.narg(plate) AS TEXT) IS NULL OR UPPER(e.plate_utf8) GLOB sqlc.narg(plate) OR UPPER(e.manual_plate) GLOB sqlc.narg(plate))
+  AND (CAST(sqlc.narg(camera) AS TEXT) IS NULL OR e.camera_serial = sqlc.narg(camera) COLLATE NOCASE
+       OR e.sensor_provider_id = sqlc.narg(camera) COLLATE NOCASE OR e.camera_ip = sqlc.narg(camera))
+  AND (CAST(sqlc.narg(country) AS TEXT) IS NULL OR e.plate_country = sqlc.narg(country) COLLATE NOCASE)
+  AND (CAST(sqlc.narg(car_state) AS TEXT) IS NULL OR e.car_state = sqlc.narg(car_state) COLLATE NOCASE)
+  AND (CAST(sqlc.narg(captured_from) AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) >= sqlc.narg(captured_from))
+  AND (CAST(sqlc.narg(captured_to) AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) < sqlc.narg(captured_to))
+ORDER BY e.id
+LIMIT sqlc.arg(limit);
+
+-- name: GetArchiveEventsSince :many
+SELECT 
+    e.id, e.car_id, e.plate_utf8, e.car_state, e.sensor_provider_id, 
+    e.event_datetime, e.created_at, e.plate_country, e.plate_region, e.plate_region_code,
+    e.vehicle_make, e.vehicle_model, e.vehicle_color, e.vehicle_type,
+    e.plate_confidence, e.confidence_mmr, e.confidence_color,
+    e.json_filename, e.unrecognized, e.manual_plate, e.source, e.node_id, e.uid,
+    e.captured_at, e.arrival_delay_ms,
+    COALESCE(best.plate_image_id, 0) as plate_image_id,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+WHERE e.archive_id = sqlc.arg(archive_id) AND e.id > sqlc.arg(since_id)
+  AND (CAST(sqlc.narg(plate) AS TEXT) IS NULL OR UPPER(e.plate_utf8) GLOB sqlc.narg(plate) OR UPPER(e.manual_plate) GLOB sqlc.narg(plate))
+  AND (CAST(sqlc.narg(camera) AS TEXT) IS NULL OR e.camera_serial = sqlc.narg(camera) COLLATE NOCASE
+       OR e.sensor_provider_id = sqlc.narg(camera) COLLATE NOCASE OR e.camera_ip = sqlc.narg(camera))
+  AND (CAST(sqlc.narg(country) AS TEXT) IS NULL OR e.plate_country = sqlc.narg(country) COLLATE NOCASE)
+  AND (CAST(sqlc.narg(car_state) AS TEXT) IS NULL OR e.car_state = sqlc.narg(car_state) COLLATE NOCASE)
+  AND (CAST(sqlc.narg(captured_from) AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) >= sqlc.narg(captured_from))
+  AND (CAST(sqlc.narg(captured_to) AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) < sqlc.narg(captured_to))
+ORDER BY e.id
+LIMIT sqlc.arg(limit);
 
 -- name: GetArchivedEvents :many
 SELECT 
@@ -35,26 +90,98 @@ SELECT
     e.event_datetime, e.created_at, e.plate_country, e.plate_region, e.plate_region_code,
     e.vehicle_make, e.vehicle_model, e.vehicle_color, e.vehicle_type,
     e.plate_confidence, e.confidence_mmr, e.confidence_color,
-    e.json_filename,
-    COALESCE((SELECT id FROM images WHERE event_id = e.id AND image_type = 'plate' LIMIT 1),
-             (SELECT id FROM images WHERE event_id = e.id ORDER BY id LIMIT 1 OFFSET 1), 0) as plate_image_id,
-    COALESCE((SELECT id FROM images WHERE event_id = e.id AND image_type = 'vehicle' LIMIT 1),
-             (SELECT id FROM images WHERE event_id = e.id ORDER BY id LIMIT 1), 0) as vehicle_image_id
+    e.json_filename, e.unrecognized, e.manual_plate, e.source, e.node_id, e.uid,
+    e.captured_at, e.arrival_delay_ms, e.camera_serial, e.camera_ip, e.geotag_lat, e.geotag_lon,
+    e.vin_make, e.vin_model, e.vin_year,
+    COALESCE(best.plate_image_id, 0) as plate_image_id,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id
 FROM events e
+JOIN event_best_images best ON best.event_id = e.id
 WHERE e.archive_id = ?
-ORDER BY e.created_at DESC;
+ORDER BY COALESCE(e.captured_at, e.created_at) DESC, e.id DESC;
 
 -- name: CountCurrentEvents :one
 SELECT COUNT(*) FROM events WHERE archive_id IS NULL;
 
+-- name: CountMatchingEvents :one
+SELECT COUNT(*) FROM events e
+WHERE (e.archive_id = sqlc.narg(archive_id) OR (e.archive_id IS NULL AND CAST(sqlc.narg(archive_id) AS INTEGER) IS NULL))
+  AND (CAST(sqlc.narg(plate) AS TEXT) IS NULL OR UPPER(e.plate_utf8) GLOB sqlc.narg(plate) OR UPPER(e.manual_plate) GLOB sqlc.narg(plate))
+  AND (CAST(sqlc.narg(camera) AS TEXT) IS NULL OR e.camera_serial = sqlc.narg(camera) COLLATE NOCASE
+       OR e.sensor_provider_id = sqlc.narg(camera) COLLATE NOCASE OR e.camera_ip = sqlc.narg(camera))
+  AND (CAST(sqlc.narg(country) AS TEXT) IS NULL OR e.plate_country = sqlc.narg(country) COLLATE NOCASE)
+  AND (CAST(sqlc.narg(car_state) AS TEXT) IS NULL OR e.car_state = sqlc.narg(car_state) COLLATE NOCASE)
+  AND (CAST(sqlc.narg(captured_from) AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) >= sqlc.narg(captured_from))
+  AND (CAST(sqlc.narg(captured_to) AS TIMESTAMP) IS NULL OR COALESCE(e.captured_at, e.created_at) < sqlc.narg(captured_to));
+
+-- name: SearchEvents :many
+SELECT
+    e.id, e.plate_utf8, e.manual_plate, e.car_state, e.sensor_provider_id, e.camera_serial,
+    e.plate_country, e.vehicle_make, e.vehicle_model, e.plate_confidence,
+    e.created_at, e.captured_at, e.archive_id, a.name AS archive_name,
+    COALESCE(best.plate_image_id, 0) as plate_image_id
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+LEFT JOIN archives a ON a.id = e.archive_id
+WHERE e.plate_key GLOB CAST(sqlc.arg(pattern) AS TEXT) OR e.manual_plate_key GLOB sqlc.arg(pattern)
+ORDER BY COALESCE(e.captured_at, e.created_at) DESC, e.id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: CountSearchEvents :one
+SELECT COUNT(*) FROM events e
+WHERE e.plate_key GLOB CAST(sqlc.arg(pattern) AS TEXT) OR e.manual_plate_key GLOB sqlc.arg(pattern);
+
 -- name: GetEventByID :one
 SELECT * FROM events WHERE id = ?;
 
+-- name: GetEventByUID :one
+SELECT * FROM events WHERE uid = ?;
+
+-- name: GetEventsWithoutUID :many
+SELECT id, created_at FROM events WHERE uid IS NULL ORDER BY id LIMIT ?;
+
+-- name: SetEventUID :exec
+UPDATE events SET uid = ? WHERE id = ?;
+
+-- name: GetEventsWithoutCaptureTime :many
+SELECT id, event_datetime, capture_timestamp, created_at FROM events
+WHERE captured_at IS NULL AND timestamp_error IS NULL
+  AND (event_datetime <> '' OR capture_timestamp <> '')
+ORDER BY id LIMIT ?;
+
+-- name: SetEventCaptureTime :exec
+UPDATE events SET captured_at = ?, timestamp_error = ?, arrival_delay_ms = ? WHERE id = ?;
+
+-- name: GetEventsWithoutArrivalDelay :many
+SELECT id, captured_at, created_at FROM events
+WHERE arrival_delay_ms IS NULL AND captured_at IS NOT NULL
+ORDER BY id LIMIT ?;
+
+-- name: SetEventArrivalDelay :exec
+UPDATE events SET arrival_delay_ms = ? WHERE id = ?;
+
 -- name: GetImagesByEventID :many
-SELECT id, image_type, filename, created_at FROM images WHERE event_id = ?;
+SELECT id, image_type, classified_type, filename, created_at, captured_at, width, height, sharpness, quality,
+    deleted_at, deleted_by, delete_reason
+FROM images WHERE event_id = ? ORDER BY id;
+
+-- name: GetEventBestImages :one
+SELECT
+    COALESCE(best.plate_image_id, 0) as plate_image_id,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+WHERE e.id = ?;
+
+-- name: GetUnmeasuredImages :many
+SELECT id, image_type, image_data, disk_filename FROM images WHERE measured_at IS NULL AND id > ? ORDER BY id LIMIT ?;
+
+-- name: SetImageQuality :exec
+UPDATE images SET width = ?, height = ?, sharpness = ?, quality = ?, classified_type = ?, phash = ?,
+    captured_at = COALESCE(captured_at, ?), measured_at = ? WHERE id = ?;
 
 -- name: GetImageData :one
-SELECT image_data FROM images WHERE id = ?;
+SELECT image_data, disk_filename FROM images WHERE id = ? AND deleted_at IS NULL;
 
 -- name: CountEvents :one
 SELECT COUNT(*) FROM events;
@@ -67,6 +194,32 @@ ORDER BY created_at DESC
 LIMIT ?;
 
 
+-- name: GetUnrecognizedEvents :many
+SELECT
+    e.id, e.car_id, e.archive_id, e.event_datetime, e.created_at,
+    e.camera_serial, e.sensor_provider_id, e.manual_plate, e.ocr_plate, e.ocr_confidence,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id,
+    (SELECT COUNT(*) FROM images WHERE event_id = e.id AND deleted_at IS NULL) as image_count
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+WHERE e.unrecognized = 1
+ORDER BY e.created_at DESC
+LIMIT ?;
+
+-- name: CountUnrecognizedEvents :one
+SELECT COUNT(*) FROM events WHERE unrecognized = 1 AND manual_plate IS NULL;
+
+-- name: SetManualPlate :exec
+UPDATE events SET manual_plate = ? WHERE id = ?;
+
+-- name: SetOCRSuggestion :exec
+UPDATE events SET ocr_plate = ?, ocr_confidence = ? WHERE id = ?;
+
+-- name: GetImagesForOCR :many
+SELECT id, image_type, image_data, disk_filename FROM images
+WHERE event_id = ? AND deleted_at IS NULL
+ORDER BY CASE COALESCE(classified_type, image_type) WHEN 'plate' THEN 0 WHEN 'vehicle' THEN 1 ELSE 2 END, quality IS NULL, quality DESC, id;
+
 -- name: CreateArchive :one
 INSERT INTO archives (name, event_count, created_at)
 VALUES (?, ?, ?)
@@ -76,7 +229,9 @@ RETURNING id;
 UPDATE events SET archive_id = ? WHERE archive_id IS NULL;
 
 -- name: GetArchives :many
-SELECT id, name, event_count, created_at FROM archives ORDER BY created_at DESC;
+SELECT id, name, event_count, created_at FROM archives
+WHERE id NOT IN (SELECT archive_id FROM archive_trash)
+ORDER BY created_at DESC;
 
 -- name: GetArchiveByID :one
 SELECT id, name, event_count, created_at FROM archives WHERE id = ?;
@@ -87,11 +242,17 @@ UPDATE events SET json_filename = ? WHERE id = ?;
 -- name: UpdateImageDiskFilename :exec
 UPDATE images SET disk_filename = ? WHERE id = ?;
 
+-- name: MoveEventJSONToBlobStore :exec
+UPDATE events SET json_filename = ?, raw_json = NULL WHERE id = ?;
+
+-- name: MoveImageToBlobStore :exec
+UPDATE images SET disk_filename = ?, image_data = X'' WHERE id = ?;
+
 -- name: GetImageWithFilename :one
-SELECT id, event_id, image_type, filename, disk_filename, created_at FROM images WHERE id = ?;
+SELECT id, event_id, image_type, filename, disk_filename, created_at, deleted_at FROM images WHERE id = ?;
 
 -- name: GetArchivedEventFiles :many
-SELECT e.id, e.json_filename, i.disk_filename
+SELECT e.id, e.json_filename, i.id AS image_id, i.disk_filename
 FROM events e
 LEFT JOIN images i ON i.event_id = e.id
 WHERE e.archive_id = ?;
@@ -105,6 +266,9 @@ DELETE FROM events WHERE archive_id = ?;
 -- name: DeleteArchive :exec
 DELETE FROM archives WHERE id = ?;
 
+-- name: DeleteArchiveTrash :exec
+DELETE FROM archive_trash WHERE archive_id = ?;
+
 -- name: RenameArchive :exec
 UPDATE archives SET name = ? WHERE id = ?;
 
@@ -118,5 +282,34 @@ ON CONFLICT(archive_id, event_id, field) DO UPDATE SET
 -- name: GetCompareResults :many
 SELECT event_id, field, is_incorrect FROM compare_results WHERE archive_id = ?;
 
+-- name: GetCompareResultsDetail :many
+SELECT event_id, field, is_incorrect, updated_at FROM compare_results
+WHERE archive_id = ?
+ORDER BY event_id, field;
+
+-- name: GetEventCompareResults :many
+SELECT field, is_incorrect, updated_at FROM compare_results
+WHERE archive_id = ? AND event_id = ?
+ORDER BY field;
+
+-- name: GetArchivedEventUIDs :many
+SELECT id, uid FROM events WHERE archive_id = ? ORDER BY id;
+
 -- name: DeleteCompareResultsByArchive :exec
 DELETE FROM compare_results WHERE archive_id = ?;
+
+-- name: StampNodeID :execrows
+UPDATE events SET node_id = ? WHERE node_id IS NULL;
+
+-- name: ExportEvents :many
+SELECT * FROM events
+WHERE id > sqlc.arg(cursor)
+  AND utc_time(created_at) >= utc_time(sqlc.arg(since))
+  AND utc_time(created_at) < utc_time(sqlc.arg(until))
+ORDER BY id
+LIMIT sqlc.arg(limit);
+
+-- name: GetImagesByEventIDs :many
+SELECT id, event_id, image_type, filename, created_at FROM images
+WHERE event_id IN (sqlc.slice(event_ids)) AND deleted_at IS NULL
+ORDER BY event_id, id;
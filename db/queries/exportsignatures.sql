@@ -0,0 +1,9 @@
+-- name: CreateExportSignature :exec
+INSERT INTO export_signatures (sha256, filename, size, key_id, signature, signed_at, signed_by)
+VALUES (?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetExportSignature :one
+SELECT * FROM export_signatures WHERE sha256 = ? ORDER BY id DESC LIMIT 1;
+
+-- name: ListExportSignatures :many
+SELECT * FROM export_signatures ORDER BY id DESC LIMIT ?;
@@ -0,0 +1,20 @@
+-- name: GetForwardCursor :one
+SELECT last_event_id FROM forward_cursors WHERE name = ?;
+
+-- name: SetForwardCursor :exec
+INSERT INTO forward_cursors (name, last_event_id, updated_at)
+VALUES (?, ?, ?)
+ON CONFLICT (name) DO UPDATE SET last_event_id = excluded.last_event_id, updated_at = excluded.updated_at;
+
+-- name: GetMaxEventID :one
+SELECT CAST(COALESCE(MAX(id), 0) AS INTEGER) FROM events;
+
+-- name: GetEventsToForward :many
+SELECT * FROM events
+WHERE id > sqlc.arg(cursor) AND source = 'camera' AND origin_id IS NULL AND duplicate_of IS NULL
+ORDER BY id
+LIMIT sqlc.arg(limit);
+
+-- name: CountEventsToForward :one
+SELECT COUNT(*) FROM events
+WHERE id > ? AND source = 'camera' AND origin_id IS NULL AND duplicate_of IS NULL;
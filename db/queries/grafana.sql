@@ -0,0 +1,21 @@
+-- name: GetGrafanaEvents :many
+SELECT id, created_at, captured_at, camera_serial, sensor_provider_id,
+       unrecognized, manual_plate, source, arrival_delay_ms, archive_id
+FROM events
+WHERE COALESCE(captured_at, created_at) >= sqlc.arg(range_from)
+  AND COALESCE(captured_at, created_at) < sqlc.arg(range_to)
+ORDER BY COALESCE(captured_at, created_at);
+
+-- name: GetGrafanaIncorrectFields :many
+SELECT c.event_id, c.field FROM compare_results c
+JOIN events e ON e.id = c.event_id
+WHERE c.is_incorrect
+  AND COALESCE(e.captured_at, e.created_at) >= sqlc.arg(range_from)
+  AND COALESCE(e.captured_at, e.created_at) < sqlc.arg(range_to);
+
+-- name: GetReviewedArchiveIDs :many
+SELECT DISTINCT archive_id FROM compare_results;
+
+-- name: GetEventCameras :many
+SELECT DISTINCT camera_serial, sensor_provider_id FROM events
+WHERE camera_serial IS NOT NULL OR sensor_provider_id IS NOT NULL;
@@ -0,0 +1,33 @@
+-- name: UpsertGroundTruth :exec
+INSERT INTO ground_truth (archive_id, event_id, plate, maker, model, color, imported_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(archive_id, event_id) DO UPDATE SET
+    plate = COALESCE(excluded.plate, plate),
+    maker = COALESCE(excluded.maker, maker),
+    model = COALESCE(excluded.model, model),
+    color = COALESCE(excluded.color, color),
+    imported_at = excluded.imported_at;
+
+-- name: GetGroundTruth :many
+SELECT * FROM ground_truth WHERE archive_id = ? ORDER BY event_id;
+
+-- name: DeleteGroundTruth :execrows
+DELETE FROM ground_truth WHERE archive_id = ?;
+
+-- name: GetGroundTruthEvents :many
+SELECT e.id, e.source, e.unrecognized, e.plate_utf8, e.vehicle_make, e.vehicle_model, e.vehicle_color,
+    g.plate AS true_plate, g.maker AS true_maker, g.model AS true_model, g.color AS true_color
+FROM ground_truth g
+JOIN events e ON e.id = g.event_id AND e.archive_id = g.archive_id
+WHERE g.archive_id = ?
+ORDER BY e.id;
+
+-- name: GetAutoCompareResults :many
+SELECT * FROM auto_compare_results WHERE archive_id = ? ORDER BY event_id, field;
+
+-- name: UpsertAutoCompareResult :exec
+INSERT INTO auto_compare_results (archive_id, event_id, field, is_incorrect, compared_at)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(archive_id, event_id, field) DO UPDATE SET
+    is_incorrect = excluded.is_incorrect,
+    compared_at = excluded.compared_at;
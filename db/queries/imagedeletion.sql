@@ -0,0 +1,15 @@
+-- name: SoftDeleteImage :execrows
+UPDATE images SET deleted_at = ?, deleted_by = ?, delete_reason = ?
+WHERE id = ? AND event_id = ? AND deleted_at IS NULL;
+
+-- name: RestoreImage :execrows
+UPDATE images SET deleted_at = NULL, deleted_by = NULL, delete_reason = NULL
+WHERE id = ? AND event_id = ? AND deleted_at IS NOT NULL;
+
+-- name: GetDeletedImages :many
+SELECT i.id, i.event_id, i.image_type, i.deleted_at, i.deleted_by, i.delete_reason, e.plate_utf8, e.archive_id
+FROM images i
+JOIN events e ON e.id = i.event_id
+WHERE i.deleted_at IS NOT NULL
+ORDER BY i.deleted_at DESC
+LIMIT ?;
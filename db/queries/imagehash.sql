@@ -0,0 +1,18 @@
+-- name: GetImageHash :one
+SELECT id, event_id, phash, COALESCE(classified_type, image_type, '') AS kind
+FROM images WHERE id = ?;
+
+-- name: GetHashedImages :many
+SELECT i.id, i.event_id, i.phash, e.plate_utf8, e.camera_serial, e.archive_id, e.created_at
+FROM images i
+JOIN events e ON e.id = i.event_id
+WHERE i.phash IS NOT NULL AND i.deleted_at IS NULL AND i.id != sqlc.arg(id)
+  AND COALESCE(i.classified_type, i.image_type, '') = sqlc.arg(kind)
+ORDER BY i.id;
+
+-- name: GetUnhashedImages :many
+SELECT id, image_data, disk_filename FROM images
+WHERE phash IS NULL AND width IS NOT NULL AND id > ? ORDER BY id LIMIT ?;
+
+-- name: SetImageHash :exec
+UPDATE images SET phash = ? WHERE id = ?;
@@ -0,0 +1,13 @@
+-- name: MoveImage :execrows
+UPDATE images SET event_id = sqlc.arg(to_event_id)
+WHERE id = sqlc.arg(id) AND event_id = sqlc.arg(from_event_id) AND deleted_at IS NULL;
+
+-- name: SetImageType :execrows
+UPDATE images SET image_type = ?
+WHERE id = ? AND event_id = ? AND deleted_at IS NULL;
+
+-- name: GetNeighbourEvents :many
+SELECT id, car_id, plate_utf8, created_at FROM events
+WHERE sensor_provider_id IS sqlc.narg(sensor_provider_id)
+    AND id BETWEEN sqlc.arg(from_id) AND sqlc.arg(to_id) AND id != sqlc.arg(id)
+ORDER BY id;
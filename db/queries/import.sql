@@ -0,0 +1,36 @@
+-- name: ImportEvent :one
+INSERT INTO events (
+    car_id, plate_utf8, car_state, sensor_provider_id,
+    event_datetime, capture_timestamp, plate_country, plate_region, plate_region_code, plate_confidence,
+    geotag_lat, geotag_lon, vehicle_make, vehicle_model, vehicle_color,
+    vehicle_type, confidence_mmr, confidence_color,
+    camera_serial, camera_ip, lane, direction, raw_json, unrecognized, manual_plate, source,
+    node_id, origin_id, uid, archive_id, captured_at, timestamp_error, arrival_delay_ms, created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+) RETURNING id;
+
+-- name: FindEventByOrigin :one
+SELECT id FROM events WHERE node_id = ? AND origin_id = ?;
+
+-- name: FindEventByUID :one
+SELECT id FROM events WHERE uid = ?;
+
+-- name: GetImportedArchive :one
+SELECT ia.archive_id FROM import_archives ia
+JOIN archives a ON a.id = ia.archive_id
+WHERE ia.origin_node = sqlc.arg(origin_node)
+  AND (ia.origin_id = sqlc.narg(origin_id) OR (ia.origin_id IS NULL AND sqlc.narg(origin_id) IS NULL));
+
+-- name: CreateImportedArchive :exec
+INSERT INTO import_archives (archive_id, origin_node, origin_id)
+VALUES (?, ?, ?);
+
+-- name: RefreshArchiveEventCount :exec
+UPDATE archives SET event_count = (SELECT COUNT(*) FROM events WHERE archive_id = archives.id)
+WHERE archives.id = ?;
+
+-- name: CountBlobStoredFiles :one
+SELECT
+    (SELECT COUNT(*) FROM images WHERE disk_filename IS NOT NULL AND length(image_data) = 0) +
+    (SELECT COUNT(*) FROM events WHERE json_filename IS NOT NULL AND raw_json IS NULL);
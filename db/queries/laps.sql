@@ -0,0 +1,42 @@
+-- name: GetTestVehicles :many
+SELECT * FROM test_vehicles ORDER BY plate;
+
+-- name: CreateTestVehicle :exec
+INSERT INTO test_vehicles (plate, label, expected_per_lap, created_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(plate) DO UPDATE SET
+    label = excluded.label,
+    expected_per_lap = excluded.expected_per_lap;
+
+-- name: DeleteTestVehicle :exec
+DELETE FROM test_vehicles WHERE id = ?;
+
+-- name: GetCurrentLaps :many
+SELECT * FROM laps WHERE archive_id IS NULL ORDER BY number;
+
+-- name: GetArchiveLaps :many
+SELECT * FROM laps WHERE archive_id = ? ORDER BY number;
+
+-- name: EndOpenLap :exec
+UPDATE laps SET ended_at = ? WHERE archive_id IS NULL AND ended_at IS NULL;
+
+-- name: StartLap :one
+INSERT INTO laps (number, started_at)
+VALUES ((SELECT COALESCE(MAX(number), 0) + 1 FROM laps WHERE archive_id IS NULL), ?)
+RETURNING number;
+
+-- name: ArchiveCurrentLaps :exec
+UPDATE laps SET archive_id = ?, ended_at = COALESCE(ended_at, ?) WHERE archive_id IS NULL;
+
+-- name: DeleteCurrentLaps :exec
+DELETE FROM laps WHERE archive_id IS NULL;
+
+-- name: GetLapEventPlates :many
+SELECT id, car_id, plate_utf8, created_at, captured_at FROM events
+WHERE archive_id IS NULL AND source = 'camera' AND plate_utf8 IS NOT NULL
+ORDER BY COALESCE(captured_at, created_at);
+
+-- name: GetArchiveLapEventPlates :many
+SELECT id, car_id, plate_utf8, created_at, captured_at FROM events
+WHERE archive_id = ? AND source = 'camera' AND plate_utf8 IS NOT NULL
+ORDER BY COALESCE(captured_at, created_at);
@@ -0,0 +1,80 @@
+-- name: FindOpenCarEvent :one
+SELECT id, uid FROM events
+WHERE archive_id IS NULL AND source = 'camera' AND car_id = ?
+  AND COALESCE(camera_serial, sensor_provider_id, '') = sqlc.arg(camera)
+  AND COALESCE(updated_at, created_at) >= sqlc.arg(since)
+  AND (sqlc.arg(camera) <> '' OR camera_ip = sqlc.narg(camera_ip))
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: MergeEventMessage :exec
+UPDATE events SET
+    car_state = COALESCE(sqlc.narg(car_state), car_state),
+    plate_utf8 = COALESCE(sqlc.narg(plate_utf8), plate_utf8),
+    plate_country = COALESCE(sqlc.narg(plate_country), plate_country),
+    plate_region = COALESCE(sqlc.narg(plate_region), plate_region),
+    plate_region_code = COALESCE(sqlc.narg(plate_region_code), plate_region_code),
+    plate_confidence = COALESCE(sqlc.narg(plate_confidence), plate_confidence),
+    geotag_lat = COALESCE(sqlc.narg(geotag_lat), geotag_lat),
+    geotag_lon = COALESCE(sqlc.narg(geotag_lon), geotag_lon),
+    vehicle_make = COALESCE(sqlc.narg(vehicle_make), vehicle_make),
+    vehicle_model = COALESCE(sqlc.narg(vehicle_model), vehicle_model),
+    vehicle_color = COALESCE(sqlc.narg(vehicle_color), vehicle_color),
+    vehicle_type = COALESCE(sqlc.narg(vehicle_type), vehicle_type),
+    confidence_mmr = COALESCE(sqlc.narg(confidence_mmr), confidence_mmr),
+    confidence_color = COALESCE(sqlc.narg(confidence_color), confidence_color),
+    camera_ip = COALESCE(sqlc.narg(camera_ip), camera_ip),
+    lane = COALESCE(sqlc.narg(lane), lane),
+    direction = COALESCE(sqlc.narg(direction), direction),
+    unrecognized = (COALESCE(sqlc.narg(plate_utf8), plate_utf8) IS NULL),
+    updated_at = sqlc.arg(updated_at),
+    message_count = message_count + 1
+WHERE id = sqlc.arg(id);
+
+-- name: InsertEventMessage :exec
+INSERT INTO event_messages (event_id, uid, car_state, plate_utf8, plate_confidence, raw_json, images, received_at, dedup_key)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: FindDuplicateMessage :one
+SELECT m.event_id, e.uid, e.plate_utf8, e.unrecognized FROM event_messages m
+JOIN events e ON e.id = m.event_id
+WHERE m.dedup_key = sqlc.arg(dedup_key) AND m.received_at >= sqlc.arg(since)
+ORDER BY m.id
+LIMIT 1;
+
+-- name: CountEventDuplicate :exec
+UPDATE events SET duplicates = duplicates + 1 WHERE id = ?;
+
+-- name: LinkDuplicateEvent :exec
+UPDATE events SET duplicate_of = ? WHERE id = ?;
+
+-- name: GetEventMessages :many
+SELECT * FROM event_messages WHERE event_id = ? ORDER BY received_at, id;
+
+-- name: FindEventMessageByUID :one
+SELECT event_id FROM event_messages WHERE uid = ?;
+
+-- name: DeleteArchiveMessages :exec
+DELETE FROM event_messages WHERE event_id IN (SELECT id FROM events WHERE archive_id = ?);
+
+-- name: GetLifecycleEvents :many
+SELECT id, camera_serial, sensor_provider_id, car_state, plate_utf8, message_count, created_at FROM events
+WHERE archive_id IS NULL AND source = 'camera'
+ORDER BY id;
+
+-- name: GetArchiveLifecycleEvents :many
+SELECT id, camera_serial, sensor_provider_id, car_state, plate_utf8, message_count, created_at FROM events
+WHERE archive_id = ? AND source = 'camera'
+ORDER BY id;
+
+-- name: GetLifecycleMessages :many
+SELECT m.event_id, m.car_state, m.plate_utf8, m.plate_confidence, m.received_at
+FROM event_messages m JOIN events e ON e.id = m.event_id
+WHERE e.archive_id IS NULL AND e.source = 'camera'
+ORDER BY m.event_id, m.received_at, m.id;
+
+-- name: GetArchiveLifecycleMessages :many
+SELECT m.event_id, m.car_state, m.plate_utf8, m.plate_confidence, m.received_at
+FROM event_messages m JOIN events e ON e.id = m.event_id
+WHERE e.archive_id = ? AND e.source = 'camera'
+ORDER BY m.event_id, m.received_at, m.id;
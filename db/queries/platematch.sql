@@ -0,0 +1,23 @@
+-- name: UpsertComparePlate :exec
+INSERT INTO compare_plates (archive_id, event_id, true_plate, source, distance, partial, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(archive_id, event_id) DO UPDATE SET
+    true_plate = excluded.true_plate,
+    source = excluded.source,
+    distance = excluded.distance,
+    partial = excluded.partial,
+    updated_at = excluded.updated_at;
+
+-- name: DeleteComparePlate :exec
+DELETE FROM compare_plates WHERE archive_id = ? AND event_id = ?;
+
+-- name: GetComparePlate :one
+SELECT * FROM compare_plates WHERE archive_id = ? AND event_id = ?;
+
+-- name: GetComparePlates :many
+SELECT * FROM compare_plates WHERE archive_id = ? ORDER BY event_id;
+
+-- name: GetArchivePlateReads :many
+SELECT id, plate_utf8 FROM events
+WHERE archive_id = ? AND plate_utf8 IS NOT NULL AND source != 'manual'
+ORDER BY id;
@@ -0,0 +1,29 @@
+-- name: GetEventsToAnonymize :many
+SELECT id, plate_utf8, manual_plate, ocr_plate, json_filename
+FROM events
+WHERE anonymized_at IS NULL
+  AND COALESCE(captured_at, created_at) < sqlc.arg(cutoff)
+  AND (archive_id IS NULL OR (
+    archive_id NOT IN (SELECT archive_id FROM archive_holds)
+    AND (CAST(sqlc.arg(exempt_locked) AS BOOLEAN) = 0 OR archive_id NOT IN (SELECT archive_id FROM archive_locks))))
+ORDER BY id
+LIMIT sqlc.arg(limit);
+
+-- name: AnonymizeEvent :exec
+UPDATE events
+SET plate_utf8 = sqlc.narg(plate_utf8), manual_plate = sqlc.narg(manual_plate), ocr_plate = sqlc.narg(ocr_plate),
+    raw_json = NULL, json_filename = NULL, anonymized_at = sqlc.arg(anonymized_at)
+WHERE id = sqlc.arg(id);
+
+-- name: GetEventMessagePlates :many
+SELECT id, plate_utf8 FROM event_messages WHERE event_id = ?;
+
+-- name: AnonymizeEventMessage :exec
+UPDATE event_messages SET plate_utf8 = ?, raw_json = NULL WHERE id = ?;
+
+-- name: GetEventImagesToAnonymize :many
+SELECT id, disk_filename, COALESCE(classified_type, image_type, '') AS kind
+FROM images WHERE event_id = ?;
+
+-- name: CountAnonymizedEvents :one
+SELECT COUNT(*) FROM events WHERE anonymized_at IS NOT NULL;
@@ -0,0 +1,38 @@
+-- name: UpsertPushSubscription :exec
+INSERT INTO push_subscriptions (endpoint, p256dh, auth, username, topics, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(endpoint) DO UPDATE SET
+    p256dh = excluded.p256dh,
+    auth = excluded.auth,
+    username = excluded.username,
+    topics = excluded.topics,
+    failures = 0;
+
+-- name: GetPushSubscriptions :many
+SELECT * FROM push_subscriptions ORDER BY id;
+
+-- name: GetPushSubscriptionByEndpoint :one
+SELECT * FROM push_subscriptions WHERE endpoint = ?;
+
+-- name: DeletePushSubscription :execrows
+DELETE FROM push_subscriptions WHERE endpoint = ?;
+
+-- name: RecordPushSent :exec
+UPDATE push_subscriptions SET last_sent_at = ?, failures = 0 WHERE id = ?;
+
+-- name: RecordPushFailure :one
+UPDATE push_subscriptions SET failures = failures + 1 WHERE id = ?
+RETURNING failures;
+
+-- name: GetArchiveReviewer :one
+SELECT * FROM archive_reviewers WHERE archive_id = ?;
+
+-- name: SetArchiveReviewer :exec
+INSERT INTO archive_reviewers (archive_id, username, assigned_by, assigned_at) VALUES (?, ?, ?, ?)
+ON CONFLICT(archive_id) DO UPDATE SET
+    username = excluded.username,
+    assigned_by = excluded.assigned_by,
+    assigned_at = excluded.assigned_at;
+
+-- name: ClearArchiveReviewer :execrows
+DELETE FROM archive_reviewers WHERE archive_id = ?;
@@ -0,0 +1,34 @@
+-- Cameras are identified by serial number, or sensor provider ID when there is none.
+
+-- name: GetImageBytes :one
+SELECT CAST(COALESCE(SUM(size_bytes), 0) AS INTEGER) FROM images;
+
+-- name: GetCameraImageBytes :one
+SELECT CAST(COALESCE(SUM(i.size_bytes), 0) AS INTEGER)
+FROM images i JOIN events e ON e.id = i.event_id
+WHERE COALESCE(e.camera_serial, e.sensor_provider_id, '') = sqlc.arg(camera);
+
+-- name: GetImageBytesByCamera :many
+SELECT CAST(COALESCE(e.camera_serial, e.sensor_provider_id, '') AS TEXT) AS camera,
+       CAST(SUM(i.size_bytes) AS INTEGER) AS bytes,
+       COUNT(*) AS images
+FROM images i JOIN events e ON e.id = i.event_id
+GROUP BY 1
+ORDER BY bytes DESC;
+
+-- name: GetOldestImages :many
+SELECT i.id, i.size_bytes, i.disk_filename, CAST(COALESCE(e.camera_serial, e.sensor_provider_id, '') AS TEXT) AS camera
+FROM images i JOIN events e ON e.id = i.event_id
+WHERE e.archive_id IS NULL OR e.archive_id NOT IN (SELECT archive_id FROM archive_holds)
+ORDER BY i.id LIMIT ?;
+
+-- name: GetOldestCameraImages :many
+SELECT i.id, i.size_bytes, i.disk_filename, CAST(COALESCE(e.camera_serial, e.sensor_provider_id, '') AS TEXT) AS camera
+FROM images i JOIN events e ON e.id = i.event_id
+WHERE COALESCE(e.camera_serial, e.sensor_provider_id, '') = sqlc.arg(camera)
+  AND (e.archive_id IS NULL OR e.archive_id NOT IN (SELECT archive_id FROM archive_holds))
+ORDER BY i.id
+LIMIT sqlc.arg(limit);
+
+-- name: DeleteImage :exec
+DELETE FROM images WHERE id = ?;
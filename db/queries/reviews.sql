@@ -0,0 +1,36 @@
+-- name: CreateReviewSlice :one
+INSERT INTO review_slices (archive_id, username, first_event_id, last_event_id, overlap, assigned_by, assigned_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING id;
+
+-- name: GetReviewSlices :many
+SELECT * FROM review_slices WHERE archive_id = ? ORDER BY first_event_id;
+
+-- name: GetAssignedArchiveIDs :many
+SELECT archive_id FROM archive_reviewers
+UNION
+SELECT archive_id FROM review_slices
+ORDER BY archive_id;
+
+-- name: DeleteReviewSlice :execrows
+DELETE FROM review_slices WHERE id = ? AND archive_id = ?;
+
+-- name: DeleteReviewSlices :execrows
+DELETE FROM review_slices WHERE archive_id = ? AND NOT overlap;
+
+-- name: RecordEventReview :exec
+INSERT INTO event_reviews (archive_id, event_id, username, reviewed_at) VALUES (?, ?, ?, ?)
+ON CONFLICT(archive_id, event_id) DO NOTHING;
+
+-- name: GetEventReviews :many
+SELECT * FROM event_reviews WHERE archive_id = ? ORDER BY event_id;
+
+-- name: GetSecondOpinions :many
+SELECT * FROM second_opinions WHERE archive_id = ? ORDER BY event_id;
+
+-- name: UpsertSecondOpinion :exec
+INSERT INTO second_opinions (archive_id, event_id, username, plate, maker, model, color, reviewed_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(archive_id, event_id, username) DO UPDATE SET
+    plate = excluded.plate, maker = excluded.maker, model = excluded.model, color = excluded.color,
+    reviewed_at = excluded.reviewed_at;
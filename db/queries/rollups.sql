@@ -0,0 +1,92 @@
+-- name: GetLastRollupBuild :one
+SELECT built_at FROM rollup_days ORDER BY built_at DESC LIMIT 1;
+
+-- name: GetReceivedDays :many
+SELECT DISTINCT substr(CAST(COALESCE(captured_at, created_at) AS TEXT), 1, 10) AS day
+FROM events
+WHERE created_at >= sqlc.arg(since)
+ORDER BY day;
+
+-- name: DeleteRollupDay :exec
+DELETE FROM rollup_camera_hours
+WHERE hour BETWEEN sqlc.arg(day) || ' 00' AND sqlc.arg(day) || ' 23';
+
+-- name: InsertRollupDay :exec
+INSERT INTO rollup_camera_hours (hour, camera, events, unrecognized, delay_sum_ms, delay_count)
+SELECT substr(CAST(COALESCE(captured_at, created_at) AS TEXT), 1, 13),
+       COALESCE(camera_serial, sensor_provider_id, ''),
+       COUNT(*), SUM(unrecognized), COALESCE(SUM(arrival_delay_ms), 0), COUNT(arrival_delay_ms)
+FROM events
+WHERE COALESCE(captured_at, created_at) >= sqlc.arg(day_start)
+  AND COALESCE(captured_at, created_at) < sqlc.arg(day_end)
+GROUP BY 1, 2;
+
+-- name: MarkRollupDay :exec
+INSERT INTO rollup_days (day, built_at) VALUES (?, ?)
+ON CONFLICT(day) DO UPDATE SET built_at = excluded.built_at;
+
+-- name: GetCameraHours :many
+SELECT hour, camera, events, unrecognized, delay_sum_ms, delay_count FROM rollup_camera_hours
+WHERE hour >= sqlc.arg(from_hour) AND hour < sqlc.arg(to_hour)
+  AND (CAST(sqlc.narg(camera) AS TEXT) IS NULL OR camera = sqlc.narg(camera) COLLATE NOCASE)
+ORDER BY hour, camera;
+
+-- name: GetCameraDays :many
+SELECT substr(hour, 1, 10) AS day, camera,
+       CAST(SUM(events) AS INTEGER) AS events, CAST(SUM(unrecognized) AS INTEGER) AS unrecognized,
+       CAST(SUM(delay_sum_ms) AS INTEGER) AS delay_sum_ms, CAST(SUM(delay_count) AS INTEGER) AS delay_count
+FROM rollup_camera_hours
+WHERE hour >= sqlc.arg(from_hour) AND hour < sqlc.arg(to_hour)
+  AND (CAST(sqlc.narg(camera) AS TEXT) IS NULL OR camera = sqlc.narg(camera) COLLATE NOCASE)
+GROUP BY 1, 2
+ORDER BY 1, 2;
+
+-- name: GetAccuracySources :many
+SELECT c.archive_id,
+       CAST(COUNT(*) || '/' || SUM(c.is_incorrect) || '/' || SUM(c.id * c.is_incorrect) || '/' || COALESCE(MAX(c.updated_at), '') || '/' ||
+            (SELECT COUNT(*) || '/' || COUNT(manual_plate) FROM events e WHERE e.archive_id = c.archive_id) AS TEXT) AS signature
+FROM compare_results c
+GROUP BY c.archive_id
+ORDER BY c.archive_id;
+
+-- name: GetArchiveAccuracySignature :one
+SELECT CAST(COALESCE(COUNT(*) || '/' || SUM(c.is_incorrect) || '/' || SUM(c.id * c.is_incorrect) || '/' || COALESCE(MAX(c.updated_at), '') || '/' ||
+            (SELECT COUNT(*) || '/' || COUNT(manual_plate) FROM events e WHERE e.archive_id = sqlc.arg(archive_id)), '') AS TEXT) AS signature
+FROM compare_results c
+WHERE c.archive_id = sqlc.arg(archive_id);
+
+-- name: GetAccuracySignatures :many
+SELECT archive_id, signature FROM rollup_archive_accuracy;
+
+-- name: GetArchiveAccuracyEvents :many
+SELECT id, unrecognized, manual_plate, source, CAST(COALESCE(camera_serial, sensor_provider_id, '') AS TEXT) AS camera
+FROM events WHERE archive_id = ?;
+
+-- name: GetArchiveIncorrectFields :many
+SELECT event_id, field FROM compare_results WHERE archive_id = ? AND is_incorrect;
+
+-- name: UpsertArchiveAccuracy :exec
+INSERT INTO rollup_archive_accuracy (archive_id, events, plate_correct, plate_incorrect, maker_correct, maker_incorrect,
+    model_correct, model_incorrect, color_correct, color_incorrect, signature, built_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(archive_id) DO UPDATE SET
+    events = excluded.events,
+    plate_correct = excluded.plate_correct, plate_incorrect = excluded.plate_incorrect,
+    maker_correct = excluded.maker_correct, maker_incorrect = excluded.maker_incorrect,
+    model_correct = excluded.model_correct, model_incorrect = excluded.model_incorrect,
+    color_correct = excluded.color_correct, color_incorrect = excluded.color_incorrect,
+    signature = excluded.signature, built_at = excluded.built_at;
+
+-- name: GetArchiveStats :one
+SELECT * FROM rollup_archive_accuracy WHERE archive_id = ?;
+
+-- name: DeleteArchiveStats :exec
+DELETE FROM rollup_archive_accuracy WHERE archive_id = ?;
+
+-- name: GetArchiveAccuracy :many
+SELECT r.archive_id, a.name, r.events, r.plate_correct, r.plate_incorrect, r.maker_correct, r.maker_incorrect,
+       r.model_correct, r.model_incorrect, r.color_correct, r.color_incorrect, r.built_at
+FROM rollup_archive_accuracy r
+JOIN archives a ON a.id = r.archive_id
+WHERE r.archive_id IN (SELECT archive_id FROM compare_results)
+ORDER BY r.archive_id;
@@ -0,0 +1,18 @@
+-- name: GetCurrentEventTimes :many
+SELECT id, created_at, captured_at FROM events
+WHERE archive_id IS NULL
+ORDER BY COALESCE(captured_at, created_at), id;
+
+-- name: CountCurrentEventsBefore :one
+SELECT COUNT(*) FROM events
+WHERE archive_id IS NULL
+  AND COALESCE(captured_at, created_at) < (SELECT COALESCE(b.captured_at, b.created_at) FROM events b WHERE b.id = sqlc.arg(boundary_id) AND b.archive_id IS NULL);
+
+-- name: ArchiveCurrentEventsBefore :exec
+UPDATE events SET archive_id = sqlc.arg(archive_id)
+WHERE archive_id IS NULL
+  AND COALESCE(captured_at, created_at) < (SELECT COALESCE(b.captured_at, b.created_at) FROM events b WHERE b.id = sqlc.arg(boundary_id) AND b.archive_id IS NULL);
+
+-- name: ArchiveCurrentLapsBefore :exec
+UPDATE laps SET archive_id = sqlc.arg(archive_id), ended_at = COALESCE(ended_at, sqlc.arg(ended_at))
+WHERE archive_id IS NULL AND started_at < sqlc.arg(before);
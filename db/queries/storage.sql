@@ -0,0 +1,49 @@
+-- Storage is split by where it lives: in the database (raw_json, image_data)
+-- and in files (data dir or blob store, named by json_filename / disk_filename).
+-- JSON files moved to a blob store no longer have a database copy to measure.
+
+-- name: GetStorageByArchive :many
+SELECT e.archive_id,
+       CAST(COALESCE(a.name, '') AS TEXT) AS name,
+       CAST(EXISTS (SELECT 1 FROM archive_trash t WHERE t.archive_id = e.archive_id) AS BOOLEAN) AS trashed,
+       COUNT(*) AS events,
+       CAST(COALESCE(SUM(i.images), 0) AS INTEGER) AS images,
+       CAST(COALESCE(SUM(LENGTH(e.raw_json)), 0) + COALESCE(SUM(i.db_bytes), 0) AS INTEGER) AS db_bytes,
+       CAST(COALESCE(SUM(CASE WHEN e.json_filename IS NOT NULL THEN LENGTH(e.raw_json) END), 0)
+            + COALESCE(SUM(i.file_bytes), 0) AS INTEGER) AS file_bytes
+FROM events e
+LEFT JOIN archives a ON a.id = e.archive_id
+LEFT JOIN (SELECT event_id, COUNT(*) AS images, SUM(LENGTH(image_data)) AS db_bytes,
+                  SUM(CASE WHEN disk_filename IS NOT NULL THEN size_bytes END) AS file_bytes
+           FROM images GROUP BY event_id) i ON i.event_id = e.id
+GROUP BY e.archive_id
+ORDER BY e.archive_id IS NOT NULL, e.archive_id;
+
+-- name: GetStorageByDay :many
+SELECT CAST(substr(CAST(e.created_at AS TEXT), 1, 10) AS TEXT) AS day,
+       COUNT(*) AS events,
+       CAST(COALESCE(SUM(LENGTH(e.raw_json)), 0) + COALESCE(SUM(i.db_bytes), 0) AS INTEGER) AS db_bytes,
+       CAST(COALESCE(SUM(CASE WHEN e.json_filename IS NOT NULL THEN LENGTH(e.raw_json) END), 0)
+            + COALESCE(SUM(i.file_bytes), 0) AS INTEGER) AS file_bytes
+FROM events e
+LEFT JOIN (SELECT event_id, SUM(LENGTH(image_data)) AS db_bytes,
+                  SUM(CASE WHEN disk_filename IS NOT NULL THEN size_bytes END) AS file_bytes
+           FROM images GROUP BY event_id) i ON i.event_id = e.id
+WHERE e.created_at >= sqlc.arg(since)
+GROUP BY 1
+ORDER BY 1;
+
+-- name: GetArchivePurgeFiles :many
+SELECT e.id, e.json_filename,
+       CAST(COALESCE(LENGTH(e.raw_json), 0) AS INTEGER) AS json_bytes,
+       i.id AS image_id, i.disk_filename, i.size_bytes,
+       CAST(COALESCE(LENGTH(i.image_data), 0) AS INTEGER) AS image_db_bytes
+FROM events e
+LEFT JOIN images i ON i.event_id = e.id
+WHERE e.archive_id = ?
+ORDER BY e.id, i.id;
+
+-- name: GetArchivePurgeMessages :one
+SELECT COUNT(*) AS messages, CAST(COALESCE(SUM(LENGTH(raw_json)), 0) AS INTEGER) AS json_bytes
+FROM event_messages
+WHERE event_id IN (SELECT id FROM events WHERE archive_id = ?);
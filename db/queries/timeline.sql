@@ -0,0 +1,18 @@
+-- name: GetTimelineCameras :many
+SELECT CAST(COALESCE(camera_serial, sensor_provider_id, camera_ip) AS TEXT) AS camera, COUNT(*) AS events
+FROM events
+WHERE COALESCE(camera_serial, sensor_provider_id, camera_ip) IS NOT NULL
+GROUP BY COALESCE(camera_serial, sensor_provider_id, camera_ip)
+ORDER BY camera;
+
+-- name: GetCameraTimeline :many
+SELECT e.id, e.car_id, e.plate_utf8, e.car_state, e.archive_id, e.created_at, e.captured_at,
+    COALESCE(best.vehicle_image_id, 0) as vehicle_image_id
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+WHERE (e.camera_serial = sqlc.arg(camera) COLLATE NOCASE OR e.sensor_provider_id = sqlc.arg(camera) COLLATE NOCASE
+       OR e.camera_ip = sqlc.arg(camera))
+  AND COALESCE(e.captured_at, e.created_at) >= sqlc.arg(captured_from)
+  AND COALESCE(e.captured_at, e.created_at) < sqlc.arg(captured_to)
+ORDER BY COALESCE(e.captured_at, e.created_at), e.id
+LIMIT sqlc.arg(limit);
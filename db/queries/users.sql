@@ -0,0 +1,43 @@
+-- name: GetUsers :many
+SELECT * FROM users ORDER BY username;
+
+-- name: GetUserByName :one
+SELECT * FROM users WHERE username = ?;
+
+-- name: CreateUser :one
+INSERT INTO users (username, password_hash, created_at)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: SetUserPassword :execrows
+UPDATE users SET password_hash = ? WHERE username = ?;
+
+-- name: DeleteUser :execrows
+DELETE FROM users WHERE username = ?;
+
+-- name: TouchUserLogin :exec
+UPDATE users SET last_login_at = ? WHERE id = ?;
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users;
+
+-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, created_at, expires_at, remote_ip, user_agent)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: GetSession :one
+SELECT sessions.id, sessions.user_id, sessions.expires_at, users.username
+FROM sessions JOIN users ON users.id = sessions.user_id
+WHERE sessions.token_hash = ?;
+
+-- name: ExtendSession :exec
+UPDATE sessions SET expires_at = ? WHERE id = ?;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions WHERE token_hash = ?;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM sessions WHERE user_id = ?;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at < ?;
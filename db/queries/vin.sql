@@ -0,0 +1,7 @@
+-- name: SetEventVIN :exec
+UPDATE events SET vin = ?, vin_make = ?, vin_model = ?, vin_year = ? WHERE id = ?;
+
+-- name: GetArchiveVINEvents :many
+SELECT id, vehicle_make, vehicle_model, vin_make, vin_model FROM events
+WHERE archive_id = ? AND vin_make IS NOT NULL AND source != 'manual'
+ORDER BY id;
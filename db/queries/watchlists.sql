@@ -0,0 +1,70 @@
+-- name: GetWatchlists :many
+SELECT w.id, w.name, w.webhook_url, w.email_to, w.flag, w.enabled, w.created_at,
+       (SELECT COUNT(*) FROM watchlist_entries e WHERE e.watchlist_id = w.id) AS entries,
+       (SELECT COUNT(*) FROM watchlist_hits h WHERE h.watchlist_id = w.id) AS hits
+FROM watchlists w
+ORDER BY w.name;
+
+-- name: GetWatchlist :one
+SELECT * FROM watchlists WHERE id = ?;
+
+-- name: CreateWatchlist :one
+INSERT INTO watchlists (name, webhook_url, email_to, flag, enabled, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: UpdateWatchlist :execrows
+UPDATE watchlists SET name = ?, webhook_url = ?, email_to = ?, flag = ?, enabled = ? WHERE id = ?;
+
+-- name: DeleteWatchlist :execrows
+DELETE FROM watchlists WHERE id = ?;
+
+-- name: GetWatchlistEntries :many
+SELECT * FROM watchlist_entries WHERE watchlist_id = ? ORDER BY plate;
+
+-- name: UpsertWatchlistEntry :one
+INSERT INTO watchlist_entries (watchlist_id, plate, note, expires_at, created_by, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(watchlist_id, plate) DO UPDATE SET
+    note = excluded.note, expires_at = excluded.expires_at
+RETURNING *;
+
+-- name: DeleteWatchlistEntry :execrows
+DELETE FROM watchlist_entries WHERE id = ? AND watchlist_id = ?;
+
+-- name: GetActiveWatchlistEntries :many
+SELECT e.id, e.watchlist_id, e.plate, e.note, e.expires_at,
+       w.name AS watchlist_name, w.webhook_url, w.email_to, w.flag
+FROM watchlist_entries e
+JOIN watchlists w ON w.id = e.watchlist_id
+WHERE w.enabled
+ORDER BY w.name, e.plate;
+
+-- name: InsertWatchlistHit :one
+INSERT INTO watchlist_hits (event_id, watchlist_id, entry_id, pattern, plate, camera, note, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(event_id, watchlist_id) DO NOTHING
+RETURNING id;
+
+-- name: SetWatchlistHitActions :exec
+UPDATE watchlist_hits SET webhook_status = ?, email_status = ? WHERE id = ?;
+
+-- name: GetWatchlistHits :many
+SELECT h.id, h.event_id, h.watchlist_id, w.name AS watchlist_name, h.entry_id, h.pattern, h.plate,
+       h.camera, h.note, h.created_at, h.webhook_status, h.email_status, w.flag, e.archive_id
+FROM watchlist_hits h
+JOIN watchlists w ON w.id = h.watchlist_id
+JOIN events e ON e.id = h.event_id
+WHERE (CAST(sqlc.narg(watchlist_id) AS INTEGER) IS NULL OR h.watchlist_id = sqlc.narg(watchlist_id))
+  AND (CAST(sqlc.narg(plate) AS TEXT) IS NULL OR h.plate GLOB sqlc.narg(plate))
+  AND (NOT sqlc.arg(current_only) OR e.archive_id IS NULL)
+  AND (NOT sqlc.arg(flagged_only) OR w.flag)
+ORDER BY h.id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: GetEventWatchlists :many
+SELECT w.name
+FROM watchlist_hits h
+JOIN watchlists w ON w.id = h.watchlist_id
+WHERE h.event_id = ?
+ORDER BY w.name;
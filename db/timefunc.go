@@ -0,0 +1,61 @@
+package db
+
+import (
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// Timestamps are stored as the driver writes time.Time values: local time
+// text with the zone offset ("2006-01-02 15:04:05.999 +0200 CEST"). As text
+// they only sort correctly within one offset, so a range across a DST change
+// compares utc_time(column) instead, e.g.
+//
+//	WHERE utc_time(created_at) >= utc_time(sqlc.arg(since))
+//
+// utc_time returns fixed-width UTC text, NULL for NULL or unparseable values.
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("utc_time", 1, utcTime)
+}
+
+// utcTimeFormat sorts as text in time order
+const utcTimeFormat = "2006-01-02 15:04:05.000000000"
+
+// storedTimeFormats are the time encodings the driver writes and reads
+var storedTimeFormats = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func utcTime(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	var s string
+	switch v := args[0].(type) {
+	case time.Time:
+		return v.UTC().Format(utcTimeFormat), nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return nil, nil
+	}
+	// time.String(): the zone name after the offset is redundant (and not
+	// always parseable, e.g. "+0100"), as is a monotonic clock reading
+	if f := strings.Fields(s); len(f) >= 3 {
+		if t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700", strings.Join(f[:3], " ")); err == nil {
+			return t.UTC().Format(utcTimeFormat), nil
+		}
+	}
+	for _, f := range storedTimeFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return t.UTC().Format(utcTimeFormat), nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,170 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// With -accuracy-alarm-delta every reviewed archive's accuracy is compared,
+// camera by camera and field by field, with the camera's baseline: its
+// previous reviewed archives pooled. A field more than the delta
+// (percentage points) below it raises an alarm, e.g. after a bad firmware
+// push: a warning is logged, subscribers of the "accuracy" notifications
+// get one and the compare page shows it. The check runs whenever the
+// archive's statistics are recomputed, so an alarm appears as soon as
+// enough verdicts are in and goes away if later ones bring the field back.
+//
+//	GET /api/analytics/accuracy/alarms?limit=100
+
+const (
+	accuracyBaselineArchives = 5  // previous archives pooled into a camera's baseline
+	accuracyAlarmMinReads    = 20 // counted reads of a field needed in the archive and in the baseline
+)
+
+// accuracyRegression is a field that dropped below its camera's baseline
+type accuracyRegression struct {
+	Camera string
+	Field  string
+	Pct    float64
+	Base   float64
+}
+
+// checkAccuracyAlarms compares the cameras of a reviewed archive with their
+// baselines, raising and clearing its alarms
+func (s *Server) checkAccuracyAlarms(ctx context.Context, archiveID int64) error {
+	q := dbgen.New(s.DB)
+	cameras, err := q.GetArchiveCameraAccuracy(ctx, archiveID)
+	if err != nil {
+		return err
+	}
+	alarms, err := q.GetArchiveAccuracyAlarms(ctx, archiveID)
+	if err != nil {
+		return err
+	}
+	raised := make(map[string]dbgen.AccuracyAlarm, len(alarms))
+	for _, a := range alarms {
+		raised[a.Camera+"/"+a.Field] = a
+	}
+
+	var fresh []accuracyRegression
+	for _, c := range cameras {
+		baseline, err := q.GetCameraAccuracyBaseline(ctx, dbgen.GetCameraAccuracyBaselineParams{
+			Camera:    c.Camera,
+			ArchiveID: archiveID,
+			Limit:     accuracyBaselineArchives,
+		})
+		if err != nil {
+			return err
+		}
+		current := cameraAccuracy(c)
+		pooled := map[string]*accuracy{}
+		for _, b := range baseline {
+			for field, a := range cameraAccuracy(b) {
+				if pooled[field] == nil {
+					pooled[field] = &accuracy{}
+				}
+				pooled[field].Correct += a.Correct
+				pooled[field].Incorrect += a.Incorrect
+			}
+		}
+		for _, field := range compareFields {
+			cur := current[field]
+			if len(baseline) == 0 || cur.Correct+cur.Incorrect < accuracyAlarmMinReads {
+				continue
+			}
+			base := newAccuracy(pooled[field].Correct, pooled[field].Incorrect)
+			if base.Correct+base.Incorrect < accuracyAlarmMinReads || *base.Pct-*cur.Pct <= s.AccuracyAlarmDelta {
+				continue
+			}
+			key := c.Camera + "/" + field
+			err := q.UpsertAccuracyAlarm(ctx, dbgen.UpsertAccuracyAlarmParams{
+				ArchiveID:        archiveID,
+				Camera:           c.Camera,
+				Field:            field,
+				Correct:          cur.Correct,
+				Incorrect:        cur.Incorrect,
+				Pct:              *cur.Pct,
+				BaselinePct:      *base.Pct,
+				BaselineArchives: int64(len(baseline)),
+			})
+			if err != nil {
+				return err
+			}
+			if _, ok := raised[key]; !ok {
+				fresh = append(fresh, accuracyRegression{Camera: c.Camera, Field: field, Pct: *cur.Pct, Base: *base.Pct})
+			}
+			delete(raised, key)
+		}
+	}
+	// Whatever wasn't raised again has recovered
+	for _, a := range raised {
+		if err := q.DeleteAccuracyAlarm(ctx, a.ID); err != nil {
+			return err
+		}
+		slog.Info("accuracy alarm cleared", "archive_id", archiveID, "camera", a.Camera, "field", a.Field)
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	name := fmt.Sprintf("Archive %d", archiveID)
+	if archive, err := q.GetArchiveByID(ctx, archiveID); err == nil && archive.Name != nil {
+		name = *archive.Name
+	}
+	parts := make([]string, len(fresh))
+	for i, r := range fresh {
+		slog.Warn("accuracy below camera baseline", "archive_id", archiveID, "camera", r.Camera, "field", r.Field,
+			"pct", fmt.Sprintf("%.1f", r.Pct), "baseline_pct", fmt.Sprintf("%.1f", r.Base))
+		parts[i] = fmt.Sprintf("%s %s %.0f%% (baseline %.0f%%)", r.Camera, r.Field, r.Pct, r.Base)
+	}
+	s.notify(pushAccuracy, "", pushMessage{
+		Title: "Accuracy dropped: " + name,
+		Body:  strings.Join(parts, ", "),
+		URL:   fmt.Sprintf("/archive/%d/compare", archiveID),
+		Tag:   fmt.Sprintf("accuracy-%d", archiveID),
+	})
+	return nil
+}
+
+// cameraAccuracy is a camera's accuracy per compare field
+func cameraAccuracy(r dbgen.RollupArchiveCameraAccuracy) map[string]accuracy {
+	return map[string]accuracy{
+		"plate": newAccuracy(r.PlateCorrect, r.PlateIncorrect),
+		"maker": newAccuracy(r.MakerCorrect, r.MakerIncorrect),
+		"model": newAccuracy(r.ModelCorrect, r.ModelIncorrect),
+		"color": newAccuracy(r.ColorCorrect, r.ColorIncorrect),
+	}
+}
+
+// HandleAccuracyAlarmsAPI returns the raised accuracy alarms, newest first
+func (s *Server) HandleAccuracyAlarmsAPI(w http.ResponseWriter, r *http.Request) {
+	limit := int64(100)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > 1000 {
+			s.jsonError(w, "limit must be 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	alarms, err := dbgen.New(s.DB).GetAccuracyAlarms(r.Context(), limit)
+	if err != nil {
+		slog.Warn("failed to load accuracy alarms", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"delta":             s.AccuracyAlarmDelta,
+		"baseline_archives": accuracyBaselineArchives,
+		"min_reads":         accuracyAlarmMinReads,
+		"alarms":            alarms,
+	})
+}
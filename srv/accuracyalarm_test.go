@@ -0,0 +1,83 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccuracyAlarms(t *testing.T) {
+	s := newTestServer(t)
+	s.AccuracyAlarmDelta = 10
+	ctx := context.Background()
+
+	// Three sessions of 20 reads from gate, the last also with 20 from yard
+	archive := func(name string, cameras ...string) (first int64) {
+		t.Helper()
+		s.DB.Exec("INSERT INTO archives (name) VALUES (?)", name)
+		for _, camera := range cameras {
+			for i := range 20 {
+				body := fmt.Sprintf(`{"plateUTF8":"AA%d","sensorProviderID":"%s"}`, i, camera)
+				res, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if first == 0 {
+					first = res.ID
+				}
+			}
+		}
+		s.DB.Exec("UPDATE events SET archive_id = (SELECT MAX(id) FROM archives) WHERE archive_id IS NULL")
+		return first
+	}
+	incorrect := func(archiveID, from int64, n int, field string) {
+		for id := from; id < from+int64(n); id++ {
+			s.DB.Exec("INSERT INTO compare_results (archive_id, event_id, field, is_incorrect) VALUES (?, ?, ?, 1)", archiveID, id, field)
+		}
+	}
+	incorrect(1, archive("monday", "gate"), 1, "plate")
+	incorrect(2, archive("tuesday", "gate"), 1, "plate")
+	third := archive("wednesday", "gate", "yard")
+	incorrect(3, third, 8, "plate")     // gate 60% against 95%
+	incorrect(3, third+20, 10, "color") // yard has no baseline
+	if err := s.buildRollups(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	alarms := func() []map[string]any {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.HandleAccuracyAlarmsAPI(w, httptest.NewRequest("GET", "/api/analytics/accuracy/alarms", nil))
+		var res struct{ Alarms []map[string]any }
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("%v: %s", err, w.Body)
+		}
+		return res.Alarms
+	}
+	got := alarms()
+	if len(got) != 1 || got[0]["archive_name"] != "wednesday" || got[0]["camera"] != "gate" || got[0]["field"] != "plate" ||
+		got[0]["pct"] != 60.0 || got[0]["baseline_pct"] != 95.0 || got[0]["baseline_archives"] != 2.0 {
+		t.Fatalf("alarms %v", got)
+	}
+	stats, err := s.compareStats(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Alarms) != 1 || stats.Alarms[0].Camera != "gate" {
+		t.Errorf("compare stats alarms %+v", stats.Alarms)
+	}
+	if stats, _ := s.compareStats(ctx, 2); len(stats.Alarms) != 0 {
+		t.Errorf("archive 2 alarms %+v", stats.Alarms)
+	}
+
+	// Reviewers take back most of the verdicts
+	s.DB.Exec("DELETE FROM compare_results WHERE archive_id = 3 AND field = 'plate' AND event_id > ?", third+1)
+	if err := s.buildRollups(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := alarms(); len(got) != 0 {
+		t.Errorf("after recovery: %v", got)
+	}
+}
@@ -0,0 +1,169 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"text/template"
+)
+
+// AckFormat is the response sent back to a camera after an ingest request.
+// Some camera firmwares retry forever unless they see an exact body, e.g.
+// "OK" or a vendor XML acknowledgment.
+type AckFormat struct {
+	Status      int    `json:"status"`       // defaults to 200
+	ContentType string `json:"content_type"` // defaults to text/plain
+	// Body is a text/template with .ID, .Plate, .Images, .Unrecognized,
+	// .Success and .Message available
+	Body    string     `json:"body"`
+	OnError *AckFormat `json:"on_error"` // response for failed requests; default JSON error when nil
+
+	tmpl *template.Template
+}
+
+// AckConfig selects an acknowledgment per camera or per endpoint. A camera
+// is matched by serial number, sensor provider ID, or remote IP address and
+// takes precedence over the endpoint path.
+type AckConfig struct {
+	Endpoints map[string]*AckFormat `json:"endpoints"`
+	Cameras   map[string]*AckFormat `json:"cameras"`
+}
+
+// LoadAckConfig reads an acknowledgment configuration file
+func LoadAckConfig(path string) (*AckConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg AckConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for name, f := range cfg.Endpoints {
+		if err := f.compile(); err != nil {
+			return nil, fmt.Errorf("endpoint %s: %w", name, err)
+		}
+	}
+	for name, f := range cfg.Cameras {
+		if err := f.compile(); err != nil {
+			return nil, fmt.Errorf("camera %s: %w", name, err)
+		}
+	}
+	return &cfg, nil
+}
+
+func (f *AckFormat) compile() error {
+	if f == nil {
+		return errors.New("empty acknowledgment")
+	}
+	if f.Status == 0 {
+		f.Status = http.StatusOK
+	}
+	if f.ContentType == "" {
+		f.ContentType = "text/plain; charset=utf-8"
+	}
+	tmpl, err := template.New("ack").Parse(f.Body)
+	if err != nil {
+		return fmt.Errorf("parse body: %w", err)
+	}
+	f.tmpl = tmpl
+	if f.OnError != nil {
+		if err := f.OnError.compile(); err != nil {
+			return fmt.Errorf("on_error: %w", err)
+		}
+	}
+	return nil
+}
+
+// lookup returns the most specific acknowledgment for the request
+func (c *AckConfig) lookup(r *http.Request, camera string, failed bool) *AckFormat {
+	var candidates []*AckFormat
+	if camera != "" {
+		candidates = append(candidates, c.Cameras[camera])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		candidates = append(candidates, c.Cameras[host])
+	}
+	candidates = append(candidates, c.Endpoints[r.URL.Path])
+
+	for _, f := range candidates {
+		if f == nil {
+			continue
+		}
+		if !failed {
+			return f
+		}
+		if f.OnError != nil {
+			return f.OnError
+		}
+	}
+	return nil
+}
+
+// writeAck sends the configured acknowledgment for an ingest request.
+// It reports false when no custom acknowledgment applies and the caller
+// should send its default JSON response.
+func (s *Server) writeAck(w http.ResponseWriter, r *http.Request, res ingestResult, ingestErr error) bool {
+	if s.Acks == nil {
+		return false
+	}
+	f := s.Acks.lookup(r, res.Camera, ingestErr != nil)
+	if f == nil {
+		return false
+	}
+	return f.write(w, res, ingestErr)
+}
+
+// writeIngestAck answers an ingest request with its acknowledgment, or the
+// default JSON response when none applies
+func (s *Server) writeIngestAck(w http.ResponseWriter, r *http.Request, req ingestRequest, res ingestResult, ingestErr error) {
+	if ingestErr != nil && res.Camera == "" {
+		res.Camera = s.requestCamera(req)
+	}
+	if s.writeAck(w, r, res, ingestErr) {
+		return
+	}
+	s.writeIngestResult(w, res, ingestErr)
+}
+
+// requestCamera names the camera of a request from its payload. A failed
+// request has no result to take it from, and without it a per-camera
+// on_error could only match by remote IP.
+func (s *Server) requestCamera(req ingestRequest) string {
+	p, err := s.parseEvent(req)
+	if err != nil {
+		return ""
+	}
+	return p.Camera
+}
+
+// write renders the acknowledgment for an ingest result
+func (f *AckFormat) write(w http.ResponseWriter, res ingestResult, ingestErr error) bool {
+	data := struct {
+		ingestResult
+		Success bool
+		Message string
+	}{
+		ingestResult: res,
+		Success:      ingestErr == nil,
+		Message:      "event recorded",
+	}
+	if ingestErr != nil {
+		data.Message = ingestErr.Error()
+	}
+
+	var buf bytes.Buffer
+	if err := f.tmpl.Execute(&buf, data); err != nil {
+		slog.Warn("failed to render acknowledgment", "error", err)
+		return false
+	}
+	w.Header().Set("Content-Type", f.ContentType)
+	w.WriteHeader(f.Status)
+	w.Write(buf.Bytes())
+	return true
+}
@@ -0,0 +1,68 @@
+package srv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIngestAcks(t *testing.T) {
+	s := newTestServer(t)
+	path := filepath.Join(s.DataDir, "acks.json")
+	os.WriteFile(path, []byte(`{
+	  "endpoints": {"/api": {"body": "OK"},
+	                "/api/stream": {"body": "OK", "on_error": {"body": "FAIL {{.Message}}"}}},
+	  "cameras": {"CAM1": {"content_type": "application/xml", "body": "<ack id=\"{{.ID}}\"/>",
+	                       "on_error": {"status": 202, "body": "RETRY"}}}}`), 0o644)
+	acks, err := LoadAckConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Acks = acks
+
+	post := func(h http.HandlerFunc, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("POST", target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	if w := post(s.HandleAPI, "/api", `{"plateUTF8":"AB123"}`); w.Code != http.StatusOK || w.Body.String() != "OK" {
+		t.Errorf("endpoint ack: %d %q", w.Code, w.Body)
+	}
+	if w := post(s.HandleAPI, "/api", `{"plateUTF8":"AB123","camera_info":{"SerialNumber":"CAM1"}}`); w.Body.String() != `<ack id="2"/>` ||
+		w.Header().Get("Content-Type") != "application/xml" {
+		t.Errorf("camera ack: %q %v", w.Body, w.Header())
+	}
+	if w := post(s.HandleAPI, "/api", `not json`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid JSON") {
+		t.Errorf("failure without on_error: %d %q", w.Code, w.Body)
+	}
+	if w := post(s.HandleStream, "/api/stream", "{\"plateUTF8\":\"CD456\"}\nnot json\n"); w.Body.String() != "FAIL 1 of 2 lines failed" {
+		t.Errorf("stream ack: %q", w.Body)
+	}
+
+	// A route without its own contract falls back to -ack-config
+	route := &CompatRoute{Path: "/cgi-bin/upload.cgi"}
+	if w := post(s.compatHandler(route), "/cgi-bin/upload.cgi", `{"plateUTF8":"EF789","sensorProviderID":"CAM1"}`); w.Body.String() != `<ack id="4"/>` {
+		t.Errorf("compat ack: %q", w.Body)
+	}
+
+	// A failed request still names its camera from the payload
+	if _, err := s.DB.Exec("CREATE TRIGGER full BEFORE INSERT ON events BEGIN SELECT RAISE(ABORT, 'disk full'); END"); err != nil {
+		t.Fatal(err)
+	}
+	if w := post(s.HandleAPI, "/api", `{"plateUTF8":"AB123","camera_info":{"SerialNumber":"CAM1"}}`); w.Code != http.StatusAccepted || w.Body.String() != "RETRY" {
+		t.Errorf("camera on_error: %d %q", w.Code, w.Body)
+	}
+	if w := post(s.compatHandler(route), "/cgi-bin/upload.cgi", `{"plateUTF8":"EF789","sensorProviderID":"CAM1"}`); w.Body.String() != "RETRY" {
+		t.Errorf("compat on_error: %q", w.Body)
+	}
+	if _, ok := route.cameras["CAM1"]; !ok || route.errors != 1 {
+		t.Errorf("compat route saw %v with %d errors", route.cameras, route.errors)
+	}
+}
@@ -0,0 +1,156 @@
+package srv
+
+import (
+	"context"
+	"fmt"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// How trustworthy the manual ground truth is shows where two people
+// reviewed the same events. An overlap slice (see review.go) gives events to
+// a second reviewer, whose verdicts are kept apart as second opinions; the
+// accuracy statistics then compare each second opinion with the archive's
+// verdicts on events the first reviewer has reviewed, as the share of
+// matching verdicts and Cohen's kappa per field. Manually created events
+// have no verdicts and unrecognized ones no plate verdict, so they are left
+// out like on the compare page.
+
+// fieldAgreement is how well two reviewers agreed on one field
+type fieldAgreement struct {
+	Field   string   `json:"field"`
+	Events  int      `json:"events"`
+	Agreed  int      `json:"agreed"`
+	Percent float64  `json:"percent"`
+	Kappa   *float64 `json:"kappa"` // null when both always gave the same verdict
+}
+
+// compareFieldLabels name the compare fields like the workbook's columns
+var compareFieldLabels = map[string]string{"plate": "LPR (Plate)", "maker": "CAR_MAKER", "model": "CAR_MODEL", "color": "CAR_COLOR"}
+
+// Label is the field's name on the compare page and in the workbook
+func (a fieldAgreement) Label() string {
+	return compareFieldLabels[a.Field]
+}
+
+// KappaText is kappa with two decimals, "-" where it is undefined
+func (a fieldAgreement) KappaText() string {
+	if a.Kappa == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", *a.Kappa)
+}
+
+// agreementStats is the inter-annotator agreement of an archive
+type agreementStats struct {
+	Events int              `json:"events"` // events with a second opinion
+	Fields []fieldAgreement `json:"fields"`
+}
+
+// verdictPairs counts pairs of verdicts: both saying incorrect, only the
+// first, only the second, neither
+type verdictPairs struct {
+	both, first, second, neither int
+}
+
+func (p *verdictPairs) add(first, second bool) {
+	switch {
+	case first && second:
+		p.both++
+	case first:
+		p.first++
+	case second:
+		p.second++
+	default:
+		p.neither++
+	}
+}
+
+// kappa is Cohen's kappa of the pairs, nil if chance agreement is already
+// perfect (every verdict the same)
+func (p verdictPairs) kappa() *float64 {
+	n := float64(p.both + p.first + p.second + p.neither)
+	if n == 0 {
+		return nil
+	}
+	observed := float64(p.both+p.neither) / n
+	p1 := float64(p.both+p.first) / n
+	p2 := float64(p.both+p.second) / n
+	chance := p1*p2 + (1-p1)*(1-p2)
+	if chance >= 1 {
+		return nil
+	}
+	k := (observed - chance) / (1 - chance)
+	return &k
+}
+
+// archiveAgreement compares the second opinions on an archive's events with
+// its verdicts; nil if no event has been reviewed twice
+func archiveAgreement(ctx context.Context, q *dbgen.Queries, archiveID int64) (*agreementStats, error) {
+	opinions, err := q.GetSecondOpinions(ctx, archiveID)
+	if err != nil || len(opinions) == 0 {
+		return nil, err
+	}
+	events, err := q.GetArchiveAccuracyEvents(ctx, &archiveID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]dbgen.GetArchiveAccuracyEventsRow, len(events))
+	for _, e := range events {
+		byID[e.ID] = e
+	}
+	reviews, err := q.GetEventReviews(ctx, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	reviewedBy := make(map[int64]string, len(reviews))
+	for _, rv := range reviews {
+		reviewedBy[rv.EventID] = rv.Username
+	}
+	marks, err := q.GetArchiveIncorrectFields(ctx, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	incorrect := make(map[int64]map[string]bool)
+	for _, m := range marks {
+		if incorrect[m.EventID] == nil {
+			incorrect[m.EventID] = make(map[string]bool)
+		}
+		incorrect[m.EventID][m.Field] = true
+	}
+
+	st := &agreementStats{}
+	pairs := make(map[string]*verdictPairs, len(compareFields))
+	for _, f := range compareFields {
+		pairs[f] = &verdictPairs{}
+	}
+	for _, op := range opinions {
+		e, ok := byID[op.EventID]
+		if !ok || e.Source == "manual" {
+			continue
+		}
+		if by := reviewedBy[op.EventID]; by == "" || by == op.Username {
+			continue
+		}
+		st.Events++
+		second := compareAnnotation{Plate: op.Plate, Maker: op.Maker, Model: op.Model, Color: op.Color}
+		for _, f := range compareFields {
+			if f == "plate" && e.Unrecognized {
+				continue
+			}
+			pairs[f].add(incorrect[op.EventID][f], second.incorrect(f))
+		}
+	}
+	if st.Events == 0 {
+		return nil, nil
+	}
+	for _, f := range compareFields {
+		p := pairs[f]
+		fa := fieldAgreement{Field: f, Events: p.both + p.first + p.second + p.neither, Agreed: p.both + p.neither, Kappa: p.kappa()}
+		if fa.Events > 0 {
+			fa.Percent = float64(fa.Agreed) / float64(fa.Events) * 100
+		}
+		st.Fields = append(st.Fields, fa)
+	}
+	return st, nil
+}
@@ -0,0 +1,154 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestKappa(t *testing.T) {
+	for _, tt := range []struct {
+		pairs verdictPairs
+		want  float64 // NaN: undefined
+	}{
+		{verdictPairs{both: 2, neither: 8}, 1},
+		{verdictPairs{first: 1, second: 1, both: 0, neither: 8}, -1.0 / 9},
+		{verdictPairs{both: 20, first: 5, second: 10, neither: 15}, 0.4},
+		{verdictPairs{neither: 10}, math.NaN()},
+	} {
+		k := tt.pairs.kappa()
+		switch {
+		case math.IsNaN(tt.want):
+			if k != nil {
+				t.Errorf("%+v: kappa %v, want undefined", tt.pairs, *k)
+			}
+		case k == nil || math.Abs(*k-tt.want) > 1e-9:
+			t.Errorf("%+v: kappa %v, want %v", tt.pairs, k, tt.want)
+		}
+	}
+}
+
+func TestSecondOpinions(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+	for _, u := range []string{"alice", "bob"} {
+		q.CreateUser(ctx, dbgen.CreateUserParams{Username: u, PasswordHash: "x", CreatedAt: time.Now()})
+	}
+	for i := 1; i <= 6; i++ {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(fmt.Sprintf(`{"plateUTF8":"SO%d"}`, i)), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name, event_count) VALUES ('Overlap', 6)")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+
+	as := func(user string, r *http.Request) *http.Request {
+		r.SetPathValue("id", "1")
+		return r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+	}
+	toggle := func(user string, eventID int64, field string) {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{"event_id": eventID, "field": field, "incorrect": true})
+		w := httptest.NewRecorder()
+		s.HandleCompareToggle(w, as(user, httptest.NewRequest("POST", "/archive/1/compare/toggle", strings.NewReader(string(body)))))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s toggles %d %s: %d %s", user, eventID, field, w.Code, w.Body)
+		}
+	}
+	markReviewed := func(user string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.HandleMarkReviewed(w, as(user, httptest.NewRequest("POST", "/archive/1/compare/reviewed", nil)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s marks reviewed: %d %s", user, w.Code, w.Body)
+		}
+	}
+
+	// alice reviews the archive; bob reviews it again
+	s.assignReviewer(ctx, 1, "alice", "admin")
+	if err := s.addReviewSlice(ctx, 1, "alice", 1, 6, true, "admin"); err == nil {
+		t.Error("alice got a second opinion on her own events")
+	}
+	if err := s.addReviewSlice(ctx, 1, "bob", 1, 6, true, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	toggle("alice", 1, "plate")
+	toggle("alice", 2, "maker")
+	markReviewed("alice")
+
+	// bob's verdicts neither conflict with alice's nor change them
+	toggle("bob", 1, "plate")
+	toggle("bob", 3, "plate")
+	w := httptest.NewRecorder()
+	s.HandleBulkCompareAPI(w, as("bob", httptest.NewRequest("PUT", "/api/archive/1/compare", strings.NewReader(`[{"event_id": 4, "color": true}]`))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("bulk: %d %s", w.Code, w.Body)
+	}
+	markReviewed("bob")
+	if res, _ := q.GetCompareResults(ctx, 1); len(res) != 2 {
+		t.Errorf("second opinions changed the verdicts: %+v", res)
+	}
+	plan, err := loadReviewPlan(ctx, q, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := plan.status()
+	if len(st.Slices) != 1 || st.Slices[0].Reviewed != 6 || len(st.Reviewers) != 2 || st.Reviewers[1].Reviewed != 6 || st.Total.Reviewed != 6 {
+		t.Errorf("progress: %+v", st)
+	}
+
+	// plate: both on 1, bob alone on 3; maker: alice alone; color: bob alone
+	w = httptest.NewRecorder()
+	s.HandleCompareStatsAPI(w, as("", httptest.NewRequest("GET", "/api/archive/1/compare/stats", nil)))
+	var stats compareStats
+	json.Unmarshal(w.Body.Bytes(), &stats)
+	a := stats.Agreement
+	if a == nil || a.Events != 6 || len(a.Fields) != 4 {
+		t.Fatalf("agreement: %s", w.Body)
+	}
+	want := map[string]string{"plate": "0.57", "maker": "0.00", "model": "-", "color": "0.00"}
+	for _, fa := range a.Fields {
+		if fa.KappaText() != want[fa.Field] {
+			t.Errorf("%s: kappa %s, want %s", fa.Field, fa.KappaText(), want[fa.Field])
+		}
+	}
+	if plate := a.Fields[0]; plate.Agreed != 5 || plate.Events != 6 {
+		t.Errorf("plate: %+v", plate)
+	}
+
+	// bob sees his own verdicts; the page counts alice's
+	w = httptest.NewRecorder()
+	s.HandleCompare(w, as("bob", httptest.NewRequest("GET", "/archive/1/compare", nil)))
+	body := w.Body.String()
+	if !strings.Contains(body, `id="event-3" data-event-id="3" data-source="camera" data-second=""`) ||
+		!strings.Contains(body, `data-event-id="3" data-field="plate" checked`) {
+		t.Errorf("compare page doesn't show bob's second opinion")
+	}
+	if !strings.Contains(body, "6 events reviewed twice") {
+		t.Errorf("compare page doesn't show the agreement")
+	}
+
+	archive, _ := q.GetArchiveByID(ctx, 1)
+	data, err := s.compareWorkbook(ctx, archive, compareExportOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := f.GetCellValue("Statistics", "E11"); v != "0.57" {
+		t.Errorf("workbook plate kappa %q", v)
+	}
+}
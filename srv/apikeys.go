@@ -0,0 +1,308 @@
+package srv
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// With RequireAPIKey set, the ingest endpoints (POST /api, /api/stream,
+// /api/event/{id}/images and legacy compat paths) only accept requests
+// carrying an enabled key, as an "X-API-Key" header, an "Authorization:
+// Bearer" header or an ?api_key= query parameter for cameras that can only
+// configure a URL. Keys are managed on /api-keys or via /api/keys; only a
+// hash is stored, so a key is shown once, when it is created.
+
+const apiKeyPrefix = "mmr_"
+
+// newAPIKey returns a random key, its hash and its display prefix
+func newAPIKey() (key, hash, prefix string) {
+	b := make([]byte, 20)
+	rand.Read(b)
+	key = apiKeyPrefix + hex.EncodeToString(b)
+	return key, hashAPIKey(key), key[:len(apiKeyPrefix)+8]
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// presentedAPIKey returns the key a request carries, if any
+func presentedAPIKey(r *http.Request) string {
+	if k := r.Header.Get("X-API-Key"); k != "" {
+		return strings.TrimSpace(k)
+	}
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// requireAPIKey rejects ingest requests without an enabled API key when
+// RequireAPIKey is set, and records when each key was last used
+func (s *Server) requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.RequireAPIKey || s.authorizeAPIKey(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// authorizeAPIKey checks the request's API key and records its use. It
+// answers the request and returns false if the key is missing, unknown or
+// disabled.
+func (s *Server) authorizeAPIKey(w http.ResponseWriter, r *http.Request) bool {
+	key := presentedAPIKey(r)
+	if key == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ingest"`)
+		s.jsonError(w, "API key required", http.StatusUnauthorized)
+		return false
+	}
+	q := dbgen.New(s.DB)
+	k, err := q.GetAPIKeyByHash(r.Context(), hashAPIKey(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.Warn("request with unknown API key", "remote", r.RemoteAddr, "path", r.URL.Path)
+		s.jsonError(w, "invalid API key", http.StatusUnauthorized)
+		return false
+	}
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return false
+	}
+	if !k.Enabled {
+		slog.Warn("request with disabled API key", "key", k.KeyPrefix, "name", k.Name, "remote", r.RemoteAddr)
+		s.jsonError(w, "API key disabled", http.StatusForbidden)
+		return false
+	}
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if err := q.TouchAPIKey(r.Context(), dbgen.TouchAPIKeyParams{
+		LastUsedAt: ptr(time.Now()),
+		LastUsedIp: ptrIfNotEmpty(ip),
+		ID:         k.ID,
+	}); err != nil {
+		slog.Warn("record API key use", "key", k.KeyPrefix, "error", err)
+	}
+	return true
+}
+
+// apiKeyView is an API key without its hash. Key is only set right after
+// creation.
+type apiKeyView struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Enabled    bool       `json:"enabled"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP *string    `json:"last_used_ip"`
+	UseCount   int64      `json:"use_count"`
+	Key        string     `json:"key,omitempty"`
+}
+
+func newAPIKeyView(k dbgen.ApiKey) apiKeyView {
+	return apiKeyView{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.KeyPrefix,
+		Enabled:    k.Enabled,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		LastUsedIP: k.LastUsedIp,
+		UseCount:   k.UseCount,
+	}
+}
+
+// createAPIKey stores a new key and returns it with the plain key set
+func (s *Server) createAPIKey(r *http.Request, name string) (apiKeyView, error) {
+	key, hash, prefix := newAPIKey()
+	k, err := dbgen.New(s.DB).CreateAPIKey(r.Context(), dbgen.CreateAPIKeyParams{
+		Name:      name,
+		KeyHash:   hash,
+		KeyPrefix: prefix,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return apiKeyView{}, err
+	}
+	slog.Info("API key created", "id", k.ID, "name", name, "key", prefix)
+	v := newAPIKeyView(k)
+	v.Key = key
+	return v, nil
+}
+
+// HandleAPIKeys shows the API keys page
+func (s *Server) HandleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	s.renderAPIKeys(w, r, nil)
+}
+
+func (s *Server) renderAPIKeys(w http.ResponseWriter, r *http.Request, created *apiKeyView) {
+	keys, err := dbgen.New(s.DB).GetAPIKeys(r.Context())
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	views := make([]apiKeyView, len(keys))
+	for i, k := range keys {
+		views[i] = newAPIKeyView(k)
+	}
+	data := struct {
+		Keys     []apiKeyView
+		Created  *apiKeyView
+		Required bool
+	}{
+		Keys:     views,
+		Created:  created,
+		Required: s.RequireAPIKey,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "api_keys.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleCreateAPIKey creates a key from the API keys page and shows it once
+func (s *Server) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	k, err := s.createAPIKey(r, name)
+	if err != nil {
+		slog.Error("failed to create API key", "error", err)
+		http.Error(w, "failed to create API key", http.StatusInternalServerError)
+		return
+	}
+	s.renderAPIKeys(w, r, &k)
+}
+
+// HandleAPIKeyAction enables, disables or deletes a key from the API keys
+// page
+func (s *Server) HandleAPIKeyAction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid key id", http.StatusBadRequest)
+		return
+	}
+	q := dbgen.New(s.DB)
+	action := r.PathValue("action")
+	switch action {
+	case "enable", "disable":
+		_, err = q.SetAPIKeyEnabled(r.Context(), dbgen.SetAPIKeyEnabledParams{Enabled: action == "enable", ID: id})
+	case "delete":
+		_, err = q.DeleteAPIKey(r.Context(), id)
+	default:
+		http.Error(w, "unknown action", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("API key updated", "id", id, "action", action)
+	http.Redirect(w, r, "/api-keys", http.StatusSeeOther)
+}
+
+// HandleAPIKeysAPI lists the API keys
+func (s *Server) HandleAPIKeysAPI(w http.ResponseWriter, r *http.Request) {
+	keys, err := dbgen.New(s.DB).GetAPIKeys(r.Context())
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	out := make([]apiKeyView, len(keys))
+	for i, k := range keys {
+		out[i] = newAPIKeyView(k)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// HandleCreateAPIKeyAPI creates a key from {"name": "..."}; the response is
+// the only time the key is returned
+func (s *Server) HandleCreateAPIKeyAPI(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		s.jsonError(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	k, err := s.createAPIKey(r, name)
+	if err != nil {
+		slog.Error("failed to create API key", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(k)
+}
+
+// HandleUpdateAPIKeyAPI enables or disables a key with {"enabled": bool}
+func (s *Server) HandleUpdateAPIKeyAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid key id", http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Enabled == nil {
+		s.jsonError(w, `expected {"enabled": true|false}`, http.StatusBadRequest)
+		return
+	}
+	q := dbgen.New(s.DB)
+	n, err := q.SetAPIKeyEnabled(r.Context(), dbgen.SetAPIKeyEnabledParams{Enabled: *body.Enabled, ID: id})
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		s.jsonError(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	slog.Info("API key updated", "id", id, "enabled", *body.Enabled)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "id": id, "enabled": *body.Enabled})
+}
+
+// HandleDeleteAPIKeyAPI deletes a key
+func (s *Server) HandleDeleteAPIKeyAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid key id", http.StatusBadRequest)
+		return
+	}
+	n, err := dbgen.New(s.DB).DeleteAPIKey(r.Context(), id)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		s.jsonError(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	slog.Info("API key deleted", "id", id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "id": id})
+}
@@ -0,0 +1,66 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	s := newTestServer(t)
+	s.RequireAPIKey = true
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+
+	key, hash, prefix := newAPIKey()
+	k, err := q.CreateAPIKey(ctx, dbgen.CreateAPIKeyParams{Name: "gate", KeyHash: hash, KeyPrefix: prefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := s.requireAPIKey(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	call := func(target string, header ...string) int {
+		r := httptest.NewRequest(http.MethodPost, target, nil)
+		if len(header) == 2 {
+			r.Header.Set(header[0], header[1])
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w.Code
+	}
+
+	for _, tc := range []struct {
+		name   string
+		code   int
+		target string
+		header []string
+	}{
+		{"no key", http.StatusUnauthorized, "/api", nil},
+		{"wrong key", http.StatusUnauthorized, "/api", []string{"X-API-Key", "mmr_wrong"}},
+		{"header", http.StatusNoContent, "/api", []string{"X-API-Key", key}},
+		{"bearer", http.StatusNoContent, "/api", []string{"Authorization", "Bearer " + key}},
+		{"query", http.StatusNoContent, "/api?api_key=" + key, nil},
+	} {
+		if code := call(tc.target, tc.header...); code != tc.code {
+			t.Errorf("%s: status = %d, want %d", tc.name, code, tc.code)
+		}
+	}
+
+	keys, _ := q.GetAPIKeys(ctx)
+	if keys[0].UseCount != 3 || keys[0].LastUsedAt == nil {
+		t.Errorf("use count = %d, last used %v; want 3 uses recorded", keys[0].UseCount, keys[0].LastUsedAt)
+	}
+
+	q.SetAPIKeyEnabled(ctx, dbgen.SetAPIKeyEnabledParams{Enabled: false, ID: k.ID})
+	if code := call("/api", "X-API-Key", key); code != http.StatusForbidden {
+		t.Errorf("disabled key: status = %d, want 403", code)
+	}
+
+	s.RequireAPIKey = false
+	if code := call("/api"); code != http.StatusNoContent {
+		t.Errorf("keys not required: status = %d", code)
+	}
+}
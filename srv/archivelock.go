@@ -0,0 +1,397 @@
+package srv
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Locking an archive after review makes its results tamper-evident. Each
+// event becomes a Merkle leaf, SHA-256(0x00 || JSON of its recorded fields,
+// the SHA-256 of every image and its compare verdicts), and interior nodes
+// are SHA-256(0x01 || left || right) with an odd last node carried up, as in
+// RFC 6962. The root and the leaves are stored; verification recomputes them
+// and names the events that changed. Locked archives refuse compare edits,
+// labeling imports, manual plates, added images and deletion; there is no
+// unlock.
+
+var errArchiveLocked = errors.New("archive is locked")
+
+// lockedEvent is what a leaf covers. Derived columns (plate keys, OCR
+// suggestions) are left out.
+type lockedEvent struct {
+	ID               int64           `json:"id"`
+	UID              *string         `json:"uid"`
+	CarID            string          `json:"car_id"`
+	Plate            *string         `json:"plate_utf8"`
+	ManualPlate      *string         `json:"manual_plate"`
+	CarState         *string         `json:"car_state"`
+	SensorProviderID *string         `json:"sensor_provider_id"`
+	CameraSerial     *string         `json:"camera_serial"`
+	CameraIP         *string         `json:"camera_ip"`
+	EventDatetime    *string         `json:"event_datetime"`
+	CaptureTimestamp *string         `json:"capture_timestamp"`
+	CapturedAt       string          `json:"captured_at"`
+	CreatedAt        string          `json:"created_at"`
+	PlateCountry     *string         `json:"plate_country"`
+	PlateRegion      *string         `json:"plate_region"`
+	PlateRegionCode  *string         `json:"plate_region_code"`
+	PlateConfidence  *float64        `json:"plate_confidence"`
+	GeotagLat        *float64        `json:"geotag_lat"`
+	GeotagLon        *float64        `json:"geotag_lon"`
+	VehicleMake      *string         `json:"vehicle_make"`
+	VehicleModel     *string         `json:"vehicle_model"`
+	VehicleColor     *string         `json:"vehicle_color"`
+	VehicleType      *string         `json:"vehicle_type"`
+	ConfidenceMmr    *string         `json:"confidence_mmr"`
+	ConfidenceColor  *string         `json:"confidence_color"`
+	Unrecognized     bool            `json:"unrecognized"`
+	Source           string          `json:"source"`
+	NodeID           *string         `json:"node_id"`
+	RawJSON          *string         `json:"raw_json"`
+	Images           []lockedImage   `json:"images"`
+	Incorrect        map[string]bool `json:"incorrect"` // compare verdicts
+}
+
+type lockedImage struct {
+	ID     int64   `json:"id"`
+	Type   *string `json:"type"`
+	SHA256 string  `json:"sha256"`
+}
+
+// lockTime formats a time independent of the server's zone
+func lockTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func leafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// merkleRoot combines the leaf hashes; an empty tree hashes to SHA-256("")
+func merkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+	level := leaves
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			h := sha256.New()
+			h.Write([]byte{1})
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// archiveLeaf is one event's hash
+type archiveLeaf struct {
+	EventID int64
+	Hash    []byte
+}
+
+// archiveLeaves hashes the archive's events in ID order
+func (s *Server) archiveLeaves(ctx context.Context, q *dbgen.Queries, archiveID int64) ([]archiveLeaf, error) {
+	events, err := q.GetArchiveEventsForLock(ctx, &archiveID)
+	if err != nil {
+		return nil, err
+	}
+	verdicts, err := q.GetCompareResults(ctx, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	incorrect := make(map[int64]map[string]bool)
+	for _, v := range verdicts {
+		if incorrect[v.EventID] == nil {
+			incorrect[v.EventID] = make(map[string]bool)
+		}
+		incorrect[v.EventID][v.Field] = v.IsIncorrect
+	}
+
+	leaves := make([]archiveLeaf, 0, len(events))
+	for _, e := range events {
+		le := lockedEvent{
+			ID: e.ID, UID: e.Uid, CarID: e.CarID,
+			Plate: e.PlateUtf8, ManualPlate: e.ManualPlate, CarState: e.CarState,
+			SensorProviderID: e.SensorProviderID, CameraSerial: e.CameraSerial, CameraIP: e.CameraIp,
+			EventDatetime: e.EventDatetime, CaptureTimestamp: e.CaptureTimestamp,
+			CapturedAt: lockTime(e.CapturedAt), CreatedAt: lockTime(&e.CreatedAt),
+			PlateCountry: e.PlateCountry, PlateRegion: e.PlateRegion, PlateRegionCode: e.PlateRegionCode,
+			PlateConfidence: e.PlateConfidence, GeotagLat: e.GeotagLat, GeotagLon: e.GeotagLon,
+			VehicleMake: e.VehicleMake, VehicleModel: e.VehicleModel, VehicleColor: e.VehicleColor,
+			VehicleType: e.VehicleType, ConfidenceMmr: e.ConfidenceMmr, ConfidenceColor: e.ConfidenceColor,
+			Unrecognized: e.Unrecognized, Source: e.Source, NodeID: e.NodeID, RawJSON: s.eventJSON(ctx, e.RawJson, e.JsonFilename),
+			Images:    []lockedImage{},
+			Incorrect: incorrect[e.ID],
+		}
+		images, err := q.GetEventImagesForLock(ctx, e.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, img := range images {
+			data, err := s.imageBytes(ctx, img.ImageData, img.DiskFilename)
+			if err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256(data)
+			le.Images = append(le.Images, lockedImage{ID: img.ID, Type: img.ImageType, SHA256: hex.EncodeToString(sum[:])})
+		}
+		data, err := json.Marshal(le)
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, archiveLeaf{EventID: e.ID, Hash: leafHash(data)})
+	}
+	return leaves, nil
+}
+
+func leafRoot(leaves []archiveLeaf) string {
+	hashes := make([][]byte, len(leaves))
+	for i, l := range leaves {
+		hashes[i] = l.Hash
+	}
+	return hex.EncodeToString(merkleRoot(hashes))
+}
+
+// archiveLocked reports whether the archive has been locked
+func (s *Server) archiveLocked(ctx context.Context, archiveID int64) (bool, error) {
+	_, err := dbgen.New(s.DB).GetArchiveLock(ctx, archiveID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// eventLocked reports whether the event belongs to a locked archive
+func (s *Server) eventLocked(ctx context.Context, archiveID *int64) (bool, error) {
+	if archiveID == nil {
+		return false, nil
+	}
+	return s.archiveLocked(ctx, *archiveID)
+}
+
+// lockArchive computes and stores the archive's Merkle root
+func (s *Server) lockArchive(ctx context.Context, archiveID int64, user string) (dbgen.ArchiveLock, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return dbgen.ArchiveLock{}, err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(s.DB).WithTx(tx)
+
+	if _, err := q.GetArchiveLock(ctx, archiveID); err == nil {
+		return dbgen.ArchiveLock{}, errArchiveLocked
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return dbgen.ArchiveLock{}, err
+	}
+	leaves, err := s.archiveLeaves(ctx, q, archiveID)
+	if err != nil {
+		return dbgen.ArchiveLock{}, err
+	}
+	lock := dbgen.CreateArchiveLockParams{
+		ArchiveID:  archiveID,
+		MerkleRoot: leafRoot(leaves),
+		EventCount: int64(len(leaves)),
+		LockedAt:   time.Now(),
+		LockedBy:   ptrIfNotEmpty(user),
+	}
+	if err := q.CreateArchiveLock(ctx, lock); err != nil {
+		return dbgen.ArchiveLock{}, err
+	}
+	for _, l := range leaves {
+		if err := q.InsertArchiveLockLeaf(ctx, dbgen.InsertArchiveLockLeafParams{
+			ArchiveID: archiveID,
+			EventID:   l.EventID,
+			LeafHash:  hex.EncodeToString(l.Hash),
+		}); err != nil {
+			return dbgen.ArchiveLock{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return dbgen.ArchiveLock{}, err
+	}
+	slog.Info("archive locked", "archive_id", archiveID, "events", len(leaves), "root", lock.MerkleRoot, "user", user)
+	return dbgen.New(s.DB).GetArchiveLock(ctx, archiveID)
+}
+
+// archiveVerification is the result of recomputing a locked archive's root
+type archiveVerification struct {
+	ArchiveID    int64     `json:"archive_id"`
+	Locked       bool      `json:"locked"`
+	LockedAt     time.Time `json:"locked_at,omitzero"`
+	LockedBy     string    `json:"locked_by,omitempty"`
+	MerkleRoot   string    `json:"merkle_root,omitempty"`
+	Events       int64     `json:"events"`
+	OK           bool      `json:"ok"`
+	ComputedRoot string    `json:"computed_root,omitempty"`
+	Changed      []int64   `json:"changed"` // events whose leaf differs
+	Missing      []int64   `json:"missing"` // locked events no longer in the archive
+	Added        []int64   `json:"added"`   // events added after locking
+	VerifiedAt   time.Time `json:"verified_at,omitzero"`
+}
+
+// verifyArchive recomputes the leaves of a locked archive, compares them
+// with the stored ones and records the outcome
+func (s *Server) verifyArchive(ctx context.Context, archiveID int64) (archiveVerification, error) {
+	q := dbgen.New(s.DB)
+	v := archiveVerification{ArchiveID: archiveID, Changed: []int64{}, Missing: []int64{}, Added: []int64{}}
+	lock, err := q.GetArchiveLock(ctx, archiveID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return v, nil
+	}
+	if err != nil {
+		return v, err
+	}
+	v.Locked = true
+	v.LockedAt = lock.LockedAt
+	v.LockedBy = deref(lock.LockedBy)
+	v.MerkleRoot = lock.MerkleRoot
+	v.Events = lock.EventCount
+
+	stored, err := q.GetArchiveLockLeaves(ctx, archiveID)
+	if err != nil {
+		return v, err
+	}
+	leaves, err := s.archiveLeaves(ctx, q, archiveID)
+	if err != nil {
+		return v, err
+	}
+	current := make(map[int64]string, len(leaves))
+	for _, l := range leaves {
+		current[l.EventID] = hex.EncodeToString(l.Hash)
+	}
+	for _, l := range stored {
+		switch h, ok := current[l.EventID]; {
+		case !ok:
+			v.Missing = append(v.Missing, l.EventID)
+		case h != l.LeafHash:
+			v.Changed = append(v.Changed, l.EventID)
+		}
+		delete(current, l.EventID)
+	}
+	for _, l := range leaves {
+		if _, ok := current[l.EventID]; ok {
+			v.Added = append(v.Added, l.EventID)
+		}
+	}
+
+	v.ComputedRoot = leafRoot(leaves)
+	v.OK = v.ComputedRoot == lock.MerkleRoot && len(v.Changed)+len(v.Missing)+len(v.Added) == 0
+	v.VerifiedAt = time.Now()
+	if err := q.SetArchiveVerified(ctx, dbgen.SetArchiveVerifiedParams{
+		VerifiedAt: &v.VerifiedAt,
+		VerifiedOk: &v.OK,
+		ArchiveID:  archiveID,
+	}); err != nil {
+		slog.Warn("record archive verification", "archive_id", archiveID, "error", err)
+	}
+	if !v.OK {
+		slog.Warn("archive verification failed", "archive_id", archiveID, "changed", v.Changed, "missing", v.Missing, "added", v.Added)
+	}
+	return v, nil
+}
+
+// archiveIDParam parses the archive id and checks that the archive exists
+func (s *Server) archiveIDParam(r *http.Request) (int64, int, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, http.StatusBadRequest, fmt.Errorf("invalid archive id")
+	}
+	if _, err := dbgen.New(s.DB).GetArchiveByID(r.Context(), id); err != nil {
+		return 0, http.StatusNotFound, fmt.Errorf("archive not found")
+	}
+	return id, 0, nil
+}
+
+// HandleLockArchive locks an archive from its page
+func (s *Server) HandleLockArchive(w http.ResponseWriter, r *http.Request) {
+	id, status, err := s.archiveIDParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if _, err := s.lockArchive(r.Context(), id, sessionUser(r)); err != nil && !errors.Is(err, errArchiveLocked) {
+		slog.Error("failed to lock archive", "archive_id", id, "error", err)
+		http.Error(w, "failed to lock archive", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/archive/%d", id), http.StatusSeeOther)
+}
+
+// HandleVerifyArchive re-checks a locked archive from its page
+func (s *Server) HandleVerifyArchive(w http.ResponseWriter, r *http.Request) {
+	id, status, err := s.archiveIDParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if _, err := s.verifyArchive(r.Context(), id); err != nil {
+		slog.Error("failed to verify archive", "archive_id", id, "error", err)
+		http.Error(w, "failed to verify archive", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/archive/%d", id), http.StatusSeeOther)
+}
+
+// HandleLockArchiveAPI locks an archive and returns its Merkle root
+func (s *Server) HandleLockArchiveAPI(w http.ResponseWriter, r *http.Request) {
+	id, status, err := s.archiveIDParam(r)
+	if err != nil {
+		s.jsonError(w, err.Error(), status)
+		return
+	}
+	lock, err := s.lockArchive(r.Context(), id, sessionUser(r))
+	if errors.Is(err, errArchiveLocked) {
+		s.jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to lock archive", "archive_id", id, "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lock)
+}
+
+// HandleVerifyArchiveAPI recomputes a locked archive's Merkle root
+func (s *Server) HandleVerifyArchiveAPI(w http.ResponseWriter, r *http.Request) {
+	id, status, err := s.archiveIDParam(r)
+	if err != nil {
+		s.jsonError(w, err.Error(), status)
+		return
+	}
+	v, err := s.verifyArchive(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to verify archive", "archive_id", id, "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
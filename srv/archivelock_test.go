@@ -0,0 +1,91 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestMerkleRoot(t *testing.T) {
+	a, b, c := leafHash([]byte("a")), leafHash([]byte("b")), leafHash([]byte("c"))
+	if got := merkleRoot([][]byte{a}); string(got) != string(a) {
+		t.Error("single leaf is not its own root")
+	}
+	abc := merkleRoot([][]byte{a, b, c})
+	if string(abc) == string(merkleRoot([][]byte{b, a, c})) {
+		t.Error("root ignores leaf order")
+	}
+	if string(abc) != string(merkleRoot([][]byte{merkleRoot([][]byte{a, b}), c})) {
+		t.Error("odd leaf not carried up")
+	}
+}
+
+func TestArchiveLock(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+
+	for _, plate := range []string{"AB1", "AB2", "AB3"} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"`+plate+`"}`), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name, event_count) VALUES ('reviewed', 3)")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+	q.SetCompareResult(ctx, dbgen.SetCompareResultParams{ArchiveID: 1, EventID: 2, Field: "plate", IsIncorrect: true})
+
+	lock, err := s.lockArchive(ctx, 1, "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.EventCount != 3 || len(lock.MerkleRoot) != 64 || deref(lock.LockedBy) != "ana" {
+		t.Errorf("lock = %+v", lock)
+	}
+	if _, err := s.lockArchive(ctx, 1, "ana"); !errors.Is(err, errArchiveLocked) {
+		t.Errorf("second lock: err = %v", err)
+	}
+	v, err := s.verifyArchive(ctx, 1)
+	if err != nil || !v.OK || v.ComputedRoot != lock.MerkleRoot {
+		t.Fatalf("untouched archive: ok = %v, err = %v", v.OK, err)
+	}
+
+	// Edits through the server are refused
+	if _, err := s.saveCompareUpdates(ctx, 1, []compareUpdate{{EventID: 1}}, "", false); !errors.Is(err, errArchiveLocked) {
+		t.Errorf("compare update: err = %v", err)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/archive/1/compare/toggle", strings.NewReader(`{"event_id":1,"field":"plate","incorrect":true}`))
+	r.SetPathValue("id", "1")
+	s.HandleCompareToggle(w, r)
+	if w.Code != http.StatusConflict {
+		t.Errorf("toggle: status %d, want 409", w.Code)
+	}
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/archive/1/delete", nil)
+	r.SetPathValue("id", "1")
+	s.HandleDeleteArchive(w, r)
+	if n, _ := q.CountEvents(ctx); w.Code != http.StatusConflict || n != 3 {
+		t.Errorf("delete: status %d, %d events left", w.Code, n)
+	}
+
+	// Edits behind its back are detected
+	s.DB.Exec("UPDATE events SET plate_utf8 = 'XX9' WHERE id = 3")
+	s.DB.Exec("UPDATE compare_results SET is_incorrect = 0 WHERE event_id = 2")
+	s.DB.Exec("UPDATE events SET archive_id = NULL WHERE id = 1")
+	v, err = s.verifyArchive(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.OK || !slices.Equal(v.Changed, []int64{2, 3}) || !slices.Equal(v.Missing, []int64{1}) {
+		t.Errorf("tampered archive: ok = %v, changed %v, missing %v", v.OK, v.Changed, v.Missing)
+	}
+	if stored, _ := q.GetArchiveLock(ctx, 1); stored.VerifiedOk == nil || *stored.VerifiedOk {
+		t.Error("failed verification not recorded")
+	}
+}
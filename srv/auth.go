@@ -0,0 +1,344 @@
+package srv
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// With RequireLogin set, every page and API except the camera ingest
+// endpoints (POST /api, /api/stream, /api/event/{id}/images, legacy compat
+// paths) and GET /api/version needs a signed-in user; the Grafana endpoints
+// also take an API key. Users are created with `srv user add`; a successful
+// login on /login sets an HttpOnly session cookie whose token is stored only
+// as a hash.
+
+const (
+	sessionCookie = "mmr_session"
+
+	// DefaultSessionTTL is how long a session lasts without being used
+	DefaultSessionTTL = 7 * 24 * time.Hour
+
+	passwordIterations = 600_000
+	minPasswordLength  = 8
+)
+
+// HashPassword returns a salted PBKDF2-SHA256 hash of password in the form
+// pbkdf2-sha256$iterations$salt$hash
+func HashPassword(password string) (string, error) {
+	return hashPassword(password, passwordIterations)
+}
+
+func hashPassword(password string, iterations int) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, 32)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", iterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// checkPassword reports whether password matches a hash from HashPassword
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := enc.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// hashSessionToken is what the sessions table stores for a cookie token
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type userKey struct{}
+
+// sessionUser returns the signed-in user's name, or "" when logins are off
+func sessionUser(r *http.Request) string {
+	name, _ := r.Context().Value(userKey{}).(string)
+	return name
+}
+
+// ingestRoute registers a camera ingest endpoint: it takes API keys instead
+// of a login, gzip-encoded bodies, and shares the upload memory budget
+func (s *Server) ingestRoute(mux *http.ServeMux, pattern string, h http.HandlerFunc) {
+	mux.HandleFunc(pattern, s.requireAPIKey(s.gunzipBody(s.limitUploads(h))))
+	s.publicRoute(pattern)
+}
+
+// publicRoute marks a route as reachable without a login
+func (s *Server) publicRoute(pattern string) {
+	if s.openRoutes == nil {
+		s.openRoutes = make(map[string]bool)
+	}
+	s.openRoutes[pattern] = true
+}
+
+// keyRoute registers a route for machine clients (Grafana, /ws) that may present
+// an API key instead of a login
+func (s *Server) keyRoute(mux *http.ServeMux, pattern string, h http.HandlerFunc) {
+	mux.HandleFunc(pattern, h)
+	if s.keyRoutes == nil {
+		s.keyRoutes = make(map[string]bool)
+	}
+	s.keyRoutes[pattern] = true
+}
+
+// requireLogin sends requests without a valid session to the login page,
+// or answers 401 for APIs and form posts
+func (s *Server) requireLogin(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.RequireLogin {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		_, pattern := mux.Handler(r)
+		if s.openRoutes[pattern] {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		if name, ok := s.session(w, r); ok {
+			mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, name)))
+			return
+		}
+		if s.keyRoutes[pattern] && presentedAPIKey(r) != "" {
+			if s.authorizeAPIKey(w, r) {
+				mux.ServeHTTP(w, r)
+			}
+			return
+		}
+		if s.keyRoutes[pattern] || r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+			s.jsonError(w, "login required", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "login required", http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+	})
+}
+
+// session validates the request's session cookie and returns the user name.
+// Sessions used after half their lifetime are extended.
+func (s *Server) session(w http.ResponseWriter, r *http.Request) (string, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return "", false
+	}
+	q := dbgen.New(s.DB)
+	sess, err := q.GetSession(r.Context(), hashSessionToken(c.Value))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Warn("look up session", "error", err)
+		}
+		return "", false
+	}
+	now := time.Now()
+	if now.After(sess.ExpiresAt) {
+		return "", false
+	}
+	if sess.ExpiresAt.Sub(now) < s.SessionTTL/2 {
+		expires := now.Add(s.SessionTTL)
+		if err := q.ExtendSession(r.Context(), dbgen.ExtendSessionParams{ExpiresAt: expires, ID: sess.ID}); err != nil {
+			slog.Warn("extend session", "user", sess.Username, "error", err)
+		} else {
+			s.setSessionCookie(w, r, c.Value, expires)
+		}
+	}
+	return sess.Username, true
+}
+
+func (s *Server) setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// safeNext returns a local redirect target, so ?next= can't send users to
+// another site
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// HandleLoginForm shows the login page
+func (s *Server) HandleLoginForm(w http.ResponseWriter, r *http.Request) {
+	s.renderLogin(w, r.URL.Query().Get("next"), "", "")
+}
+
+func (s *Server) renderLogin(w http.ResponseWriter, next, username, message string) {
+	data := struct {
+		Next     string
+		Username string
+		Error    string
+	}{
+		Next:     safeNext(next),
+		Username: username,
+		Error:    message,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if message != "" {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	if err := s.renderTemplate(w, "login.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleLogin checks the credentials and starts a session
+func (s *Server) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+	next := r.FormValue("next")
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+
+	q := dbgen.New(s.DB)
+	user, err := q.GetUserByName(r.Context(), username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || !checkPassword(user.PasswordHash, password) {
+		slog.Warn("failed login", "user", username, "remote", ip)
+		s.renderLogin(w, next, username, "Wrong username or password.")
+		return
+	}
+
+	token := make([]byte, 32)
+	rand.Read(token)
+	value := hex.EncodeToString(token)
+	now := time.Now()
+	expires := now.Add(s.SessionTTL)
+	if err := q.CreateSession(r.Context(), dbgen.CreateSessionParams{
+		TokenHash: hashSessionToken(value),
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: expires,
+		RemoteIp:  ptrIfNotEmpty(ip),
+		UserAgent: ptrIfNotEmpty(r.UserAgent()),
+	}); err != nil {
+		slog.Error("failed to create session", "user", username, "error", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	q.TouchUserLogin(r.Context(), dbgen.TouchUserLoginParams{LastLoginAt: &now, ID: user.ID})
+	if n, err := q.DeleteExpiredSessions(r.Context(), now); err == nil && n > 0 {
+		slog.Info("expired sessions removed", "count", n)
+	}
+
+	slog.Info("user logged in", "user", username, "remote", ip)
+	s.setSessionCookie(w, r, value, expires)
+	http.Redirect(w, r, safeNext(next), http.StatusSeeOther)
+}
+
+// HandleLogout ends the current session
+func (s *Server) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if err := dbgen.New(s.DB).DeleteSession(r.Context(), hashSessionToken(c.Value)); err != nil {
+			slog.Warn("delete session", "error", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// AddUser creates a dashboard user
+func (s *Server) AddUser(ctx context.Context, username, password string) error {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = dbgen.New(s.DB).CreateUser(ctx, dbgen.CreateUserParams{
+		Username:     username,
+		PasswordHash: hash,
+		CreatedAt:    time.Now(),
+	})
+	return err
+}
+
+// SetPassword changes a user's password and ends their sessions
+func (s *Server) SetPassword(ctx context.Context, username, password string) error {
+	q := dbgen.New(s.DB)
+	user, err := q.GetUserByName(ctx, username)
+	if err != nil {
+		return fmt.Errorf("user %q: %w", username, err)
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if _, err := q.SetUserPassword(ctx, dbgen.SetUserPasswordParams{PasswordHash: hash, Username: username}); err != nil {
+		return err
+	}
+	return q.DeleteUserSessions(ctx, user.ID)
+}
+
+// DeleteUser removes a user and their sessions
+func (s *Server) DeleteUser(ctx context.Context, username string) error {
+	q := dbgen.New(s.DB)
+	user, err := q.GetUserByName(ctx, username)
+	if err != nil {
+		return fmt.Errorf("user %q: %w", username, err)
+	}
+	if err := q.DeleteUserSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	_, err = q.DeleteUser(ctx, username)
+	return err
+}
+
+// Users lists the dashboard users
+func (s *Server) Users(ctx context.Context) ([]dbgen.User, error) {
+	return dbgen.New(s.DB).GetUsers(ctx)
+}
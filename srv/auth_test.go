@@ -0,0 +1,86 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := hashPassword("correct horse", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkPassword(hash, "correct horse") {
+		t.Error("right password rejected")
+	}
+	if checkPassword(hash, "correct horsE") || checkPassword("plain", "plain") {
+		t.Error("wrong password accepted")
+	}
+	if _, err := hashPassword("short", 1000); err == nil {
+		t.Error("short password accepted")
+	}
+}
+
+func TestRequireLogin(t *testing.T) {
+	s := newTestServer(t)
+	s.RequireLogin = true
+	s.SessionTTL = time.Hour
+	ctx := context.Background()
+	hash, _ := hashPassword("s3cret-pass", 1000)
+	dbgen.New(s.DB).CreateUser(ctx, dbgen.CreateUserParams{Username: "ana", PasswordHash: hash, CreatedAt: time.Now()})
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("user=" + sessionUser(r))) }
+	mux := http.NewServeMux()
+	s.ingestRoute(mux, "POST /api", ok)
+	mux.HandleFunc("GET /{$}", ok)
+	mux.HandleFunc("GET /api/events", ok)
+	mux.HandleFunc("POST /login", s.HandleLogin)
+	mux.HandleFunc("POST /logout", s.HandleLogout)
+	s.publicRoute("POST /login")
+	h := s.requireLogin(mux)
+
+	do := func(method, target string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do("POST", "/api", nil, nil); w.Code != http.StatusOK {
+		t.Errorf("ingest: status = %d, want 200 without login", w.Code)
+	}
+	if w := do("GET", "/?order=received", nil, nil); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?next=%2F%3Forder%3Dreceived" {
+		t.Errorf("dashboard: status = %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := do("GET", "/api/events", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("events API: status = %d, want 401", w.Code)
+	}
+
+	w := do("POST", "/login", url.Values{"username": {"ana"}, "password": {"s3cret-pass"}, "next": {"//evil.example"}}, nil)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" {
+		t.Fatalf("login: status = %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	cookie := w.Result().Cookies()[0]
+	if !cookie.HttpOnly || cookie.Name != sessionCookie {
+		t.Errorf("cookie = %+v", cookie)
+	}
+	if w := do("GET", "/", nil, cookie); w.Code != http.StatusOK || w.Body.String() != "user=ana" {
+		t.Errorf("dashboard after login: status = %d, body %q", w.Code, w.Body)
+	}
+
+	do("POST", "/logout", nil, cookie)
+	if w := do("GET", "/api/events", nil, cookie); w.Code != http.StatusUnauthorized {
+		t.Errorf("after logout: status = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package srv
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Axis cameras running a plate recognition ACAP report reads as VAPIX/ONVIF
+// event notifications. The read's values are not elements of their own but
+// SimpleItem Name/Value pairs under the message's Source and Data:
+//
+//	<wsnt:NotificationMessage>
+//	  <wsnt:Topic>tns1:CameraApplicationPlatform/LicensePlate/Read</wsnt:Topic>
+//	  <wsnt:Message><tt:Message UtcTime="2026-05-03T14:11:04Z">
+//	    <tt:Source><tt:SimpleItem Name="SerialNumber" Value="ACCC8E012345"/></tt:Source>
+//	    <tt:Data><tt:SimpleItem Name="Plate" Value="ABC123"/>
+//	             <tt:SimpleItem Name="Country" Value="SWE"/></tt:Data>
+//	  </tt:Message></wsnt:Message>
+//	</wsnt:NotificationMessage>
+//
+// Each pair becomes an element named for its Name holding its Value (an
+// ElementItem one holding its children), and the document is then read like
+// any XML event. Notifications on a topic that isn't about plates are
+// acknowledged without storing anything.
+
+// axisPlateTopics are the topic words of plate reads
+var axisPlateTopics = []string{"plate", "lpr", "anpr"}
+
+// axisJSON converts an Axis event notification into event JSON
+func axisJSON(data []byte) ([]byte, error) {
+	root, err := parseXMLTree(data)
+	if err != nil {
+		return nil, &payloadError{"invalid XML: " + err.Error()}
+	}
+	var topic string
+	var walk func(n *xmlNode)
+	walk = func(n *xmlNode) {
+		switch xmlName(n.name) {
+		case "topic":
+			topic = strings.TrimSpace(string(n.text))
+		case "simpleitem", "elementitem":
+			var name, value string
+			for _, a := range n.attrs {
+				switch a.Name.Local {
+				case "Name":
+					name = a.Value
+				case "Value":
+					value = a.Value
+				}
+			}
+			if name != "" {
+				n.name, n.attrs = name, nil
+				if len(n.children) == 0 {
+					n.text = []byte(value)
+				}
+			}
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(root)
+	isPlate := func(word string) bool { return strings.Contains(strings.ToLower(topic), word) }
+	if topic != "" && !slices.ContainsFunc(axisPlateTopics, isPlate) {
+		return nil, fmt.Errorf("%w: %s", errNotANPR, topic)
+	}
+	return xmlTreeJSON(root)
+}
@@ -0,0 +1,268 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BI tools (Grafana, Metabase) should not query the live database: a slow
+// dashboard query would hold up ingest. With -bi-snapshot the server writes
+// a read-only copy for them every interval, without image blobs and without
+// the user, session and API key tables. GET /api/schema describes the tables
+// for connector setup and /bi explains how to connect.
+
+// biExcludedTables hold credentials and are left out of snapshots and the schema
+var biExcludedTables = []string{"sessions", "users", "api_keys"}
+
+// BISnapshot periodically writes a stripped copy of the database for BI tools
+type BISnapshot struct {
+	Path     string
+	Interval time.Duration
+
+	mu       sync.Mutex
+	lastRun  time.Time
+	lastErr  error
+	size     int64
+	duration time.Duration
+}
+
+func NewBISnapshot(path string) *BISnapshot {
+	return &BISnapshot{Path: path, Interval: time.Hour}
+}
+
+// runBISnapshots regenerates the snapshot until ctx is done
+func (s *Server) runBISnapshots(ctx context.Context) {
+	bi := s.BI
+	slog.Info("BI snapshots enabled", "path", bi.Path, "interval", bi.Interval)
+	for {
+		if err := s.writeBISnapshot(ctx); err != nil {
+			slog.Warn("BI snapshot failed", "path", bi.Path, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(bi.Interval):
+		}
+	}
+}
+
+// writeBISnapshot replaces the snapshot file. The new copy is built next to
+// it and renamed into place, so tools never see a half-written file.
+func (s *Server) writeBISnapshot(ctx context.Context) error {
+	bi := s.BI
+	bi.mu.Lock()
+	defer bi.mu.Unlock()
+
+	start := time.Now()
+	err := s.buildBISnapshot(ctx, bi.Path)
+	bi.lastRun = start
+	bi.lastErr = err
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(bi.Path); err == nil {
+		bi.size = fi.Size()
+	}
+	bi.duration = time.Since(start)
+	slog.Info("BI snapshot written", "path", bi.Path, "bytes", bi.size, "elapsed", bi.duration.Round(time.Millisecond))
+	return nil
+}
+
+func (s *Server) buildBISnapshot(ctx context.Context, path string) error {
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	defer os.Remove(tmp)
+
+	// VACUUM INTO writes a consistent copy while the server keeps running
+	if _, err := s.DB.ExecContext(ctx, "VACUUM INTO ?", tmp); err != nil {
+		return fmt.Errorf("copy database: %w", err)
+	}
+	snap, err := sql.Open("sqlite", tmp)
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+	stmts := []string{"UPDATE images SET image_data = X''"}
+	for _, t := range biExcludedTables {
+		stmts = append(stmts, "DROP TABLE IF EXISTS "+t)
+	}
+	stmts = append(stmts, "VACUUM")
+	for _, stmt := range stmts {
+		if _, err := snap.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("strip snapshot: %s: %w", stmt, err)
+		}
+	}
+	if err := snap.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// snapshotStatus reports the BI snapshot for the schema endpoint and page
+func (bi *BISnapshot) status() map[string]any {
+	bi.mu.Lock()
+	defer bi.mu.Unlock()
+	st := map[string]any{
+		"path":     bi.Path,
+		"interval": bi.Interval.String(),
+	}
+	if !bi.lastRun.IsZero() {
+		st["last_run"] = bi.lastRun
+		st["bytes"] = bi.size
+	}
+	if bi.lastErr != nil {
+		st["error"] = bi.lastErr.Error()
+	}
+	return st
+}
+
+type schemaColumn struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	NotNull    bool    `json:"not_null"`
+	PrimaryKey bool    `json:"primary_key"`
+	Default    *string `json:"default,omitempty"`
+	References string  `json:"references,omitempty"` // table.column of a foreign key
+}
+
+type schemaTable struct {
+	Name    string         `json:"name"`
+	Columns []schemaColumn `json:"columns"`
+}
+
+// loadSchema describes the tables BI tools may query
+func (s *Server) loadSchema(ctx context.Context) (version int64, tables []schemaTable, err error) {
+	if err := s.DB.QueryRowContext(ctx, "SELECT COALESCE(MAX(migration_number), 0) FROM migrations").Scan(&version); err != nil {
+		return 0, nil, err
+	}
+	rows, err := s.DB.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return 0, nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		if !slices.Contains(biExcludedTables, name) {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	for _, name := range names {
+		t := schemaTable{Name: name}
+		refs, err := s.foreignKeys(ctx, name)
+		if err != nil {
+			return 0, nil, err
+		}
+		cols, err := s.DB.QueryContext(ctx, "SELECT name, type, \"notnull\", dflt_value, pk FROM pragma_table_info(?)", name)
+		if err != nil {
+			return 0, nil, err
+		}
+		for cols.Next() {
+			var c schemaColumn
+			var pk int
+			if err := cols.Scan(&c.Name, &c.Type, &c.NotNull, &c.Default, &pk); err != nil {
+				cols.Close()
+				return 0, nil, err
+			}
+			c.PrimaryKey = pk > 0
+			c.References = refs[c.Name]
+			t.Columns = append(t.Columns, c)
+		}
+		cols.Close()
+		if err := cols.Err(); err != nil {
+			return 0, nil, err
+		}
+		tables = append(tables, t)
+	}
+	return version, tables, nil
+}
+
+// foreignKeys maps a table's columns to the table.column they reference
+func (s *Server) foreignKeys(ctx context.Context, table string) (map[string]string, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT "from", "table", COALESCE("to", '') FROM pragma_foreign_key_list(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	refs := make(map[string]string)
+	for rows.Next() {
+		var from, to, col string
+		if err := rows.Scan(&from, &to, &col); err != nil {
+			return nil, err
+		}
+		if col == "" {
+			col = "id"
+		}
+		refs[from] = to + "." + col
+	}
+	return refs, rows.Err()
+}
+
+// HandleSchemaAPI returns the database schema as JSON for BI connector setup
+func (s *Server) HandleSchemaAPI(w http.ResponseWriter, r *http.Request) {
+	version, tables, err := s.loadSchema(r.Context())
+	if err != nil {
+		slog.Warn("failed to load schema", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	resp := map[string]any{
+		"schema_version": version,
+		"tables":         tables,
+		"excluded":       biExcludedTables,
+		"snapshot":       nil,
+	}
+	if s.BI != nil {
+		resp["snapshot"] = s.BI.status()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleBI shows how to connect BI tools to the snapshot
+func (s *Server) HandleBI(w http.ResponseWriter, r *http.Request) {
+	version, tables, err := s.loadSchema(r.Context())
+	if err != nil {
+		slog.Warn("failed to load schema", "error", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	data := struct {
+		Hostname string
+		Version  int64
+		Tables   []schemaTable
+		Excluded string
+		Snapshot map[string]any
+	}{
+		Hostname: s.Hostname,
+		Version:  version,
+		Tables:   tables,
+		Excluded: strings.Join(biExcludedTables, ", "),
+	}
+	if s.BI != nil {
+		data.Snapshot = s.BI.status()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "bi.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
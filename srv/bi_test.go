@@ -0,0 +1,62 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestBISnapshot(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.DataDir
+	s.BI = NewBISnapshot(filepath.Join(dir, "bi.sqlite3"))
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+	id, err := q.InsertEvent(ctx, dbgen.InsertEventParams{CarID: "1", PlateUtf8: ptr("AB123"), CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.DB.Exec("INSERT INTO images (event_id, image_data, created_at) VALUES (?, X'FFD8FFE0', ?)", id, time.Now())
+	q.CreateAPIKey(ctx, dbgen.CreateAPIKeyParams{Name: "cam", KeyHash: "h", KeyPrefix: "p", CreatedAt: time.Now()})
+
+	if err := s.writeBISnapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := sql.Open("sqlite", s.BI.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Close()
+	var events, blobBytes int
+	snap.QueryRow("SELECT COUNT(*) FROM events").Scan(&events)
+	snap.QueryRow("SELECT SUM(length(image_data)) FROM images").Scan(&blobBytes)
+	if events != 1 || blobBytes != 0 {
+		t.Errorf("snapshot: %d events, %d image bytes; want 1 event, no image data", events, blobBytes)
+	}
+	if _, err := snap.Exec("SELECT 1 FROM api_keys"); err == nil {
+		t.Error("snapshot still has api_keys")
+	}
+
+	_, tables, err := s.loadSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tb := range tables {
+		names = append(names, tb.Name)
+		if tb.Name == "images" {
+			i := slices.IndexFunc(tb.Columns, func(c schemaColumn) bool { return c.Name == "event_id" })
+			if i < 0 || tb.Columns[i].References != "events.id" {
+				t.Errorf("images.event_id = %+v, want reference to events.id", tb.Columns)
+			}
+		}
+	}
+	if !slices.Contains(names, "events") || slices.Contains(names, "users") {
+		t.Errorf("schema tables = %v", names)
+	}
+}
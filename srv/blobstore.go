@@ -0,0 +1,144 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Camera JSON and images are written as files under DataDir (json/ and
+// images/) and also kept in the database. With a blob store (-blob-store, a
+// directory or s3://bucket/prefix, which also covers MinIO) the files are
+// written there instead, and once written the database keeps only their
+// keys, json_filename and disk_filename, for deployments with a small local
+// disk. Continuation messages keep their JSON in the database, and cached
+// thumbnails stay under DataDir.
+
+// Blob key prefixes
+const (
+	blobJSONPrefix  = "json/"
+	blobImagePrefix = "images/"
+)
+
+// BlobStore keeps event JSON and image files under slash-separated keys
+type BlobStore struct {
+	URL string
+
+	target replicaTarget
+}
+
+// OpenBlobStore opens a directory or s3://bucket/prefix?region=..&endpoint=..
+func OpenBlobStore(url string) (*BlobStore, error) {
+	target, err := openReplicaTarget(url)
+	if err != nil {
+		return nil, err
+	}
+	return &BlobStore{URL: url, target: target}, nil
+}
+
+// blobs returns the configured blob store, else DataDir
+func (s *Server) blobs() replicaTarget {
+	if s.Blobs != nil {
+		return s.Blobs.target
+	}
+	return dirTarget{root: s.DataDir}
+}
+
+func (s *Server) putBlob(ctx context.Context, key string, data []byte) error {
+	return s.blobs().put(ctx, key, bytes.NewReader(data), int64(len(data)))
+}
+
+func (s *Server) getBlob(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.blobs().get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// removeBlob deletes a file; one that is already gone is not an error
+func (s *Server) removeBlob(ctx context.Context, key string) {
+	if err := s.blobs().remove(ctx, key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("failed to delete file", "key", key, "error", err)
+	}
+}
+
+// storeImageFile writes an image file and records its name. With a blob
+// store the database copy is dropped once the file is written.
+func (s *Server) storeImageFile(ctx context.Context, q *dbgen.Queries, imgID int64, diskFilename string, data []byte) {
+	if err := s.putBlob(ctx, blobImagePrefix+diskFilename, data); err != nil {
+		slog.Warn("failed to save image file", "id", imgID, "error", err)
+		return
+	}
+	var err error
+	if s.Blobs != nil {
+		err = q.MoveImageToBlobStore(ctx, dbgen.MoveImageToBlobStoreParams{DiskFilename: &diskFilename, ID: imgID})
+	} else {
+		err = q.UpdateImageDiskFilename(ctx, dbgen.UpdateImageDiskFilenameParams{DiskFilename: &diskFilename, ID: imgID})
+	}
+	if err != nil {
+		slog.Warn("failed to record image file", "id", imgID, "error", err)
+	}
+}
+
+// storeEventJSON writes an event's camera JSON file and records its name.
+// With a blob store the database copy is dropped once the file is written.
+func (s *Server) storeEventJSON(ctx context.Context, q *dbgen.Queries, eventID int64, jsonFilename string, rawJSON []byte) {
+	if err := s.putBlob(ctx, blobJSONPrefix+jsonFilename, rawJSON); err != nil {
+		slog.Warn("failed to save JSON file", "id", eventID, "error", err)
+		return
+	}
+	var err error
+	if s.Blobs != nil {
+		err = q.MoveEventJSONToBlobStore(ctx, dbgen.MoveEventJSONToBlobStoreParams{JsonFilename: &jsonFilename, ID: eventID})
+	} else {
+		err = q.UpdateEventJsonFilename(ctx, dbgen.UpdateEventJsonFilenameParams{JsonFilename: &jsonFilename, ID: eventID})
+	}
+	if err != nil {
+		slog.Warn("failed to record JSON file", "id", eventID, "error", err)
+	}
+}
+
+// imageBytes returns an image's data from the database, else its file
+func (s *Server) imageBytes(ctx context.Context, data []byte, diskFilename *string) ([]byte, error) {
+	if len(data) > 0 || diskFilename == nil || *diskFilename == "" {
+		return data, nil
+	}
+	data, err := s.getBlob(ctx, blobImagePrefix+*diskFilename)
+	if err != nil {
+		return nil, fmt.Errorf("read image %s: %w", path.Base(*diskFilename), err)
+	}
+	return data, nil
+}
+
+// loadImage returns the data of an image by ID; deleted images are not found
+func (s *Server) loadImage(ctx context.Context, q *dbgen.Queries, id int64) ([]byte, error) {
+	row, err := q.GetImageData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.imageBytes(ctx, row.ImageData, row.DiskFilename)
+}
+
+// eventJSON returns an event's camera JSON from the database, else its file
+func (s *Server) eventJSON(ctx context.Context, rawJSON, jsonFilename *string) *string {
+	if rawJSON != nil || jsonFilename == nil || *jsonFilename == "" {
+		return rawJSON
+	}
+	data, err := s.getBlob(ctx, blobJSONPrefix+*jsonFilename)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("failed to read JSON file", "key", *jsonFilename, "error", err)
+		}
+		return nil
+	}
+	return ptr(string(data))
+}
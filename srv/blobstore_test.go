@@ -0,0 +1,115 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestBlobStore(t *testing.T) {
+	// A bucket that keeps objects in memory, path-style like MinIO
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer bucket.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	blobs, err := OpenBlobStore("s3://mmr/site1?endpoint=" + bucket.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t)
+	dir := s.DataDir
+	s.Blobs = blobs
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 16, 16)))
+	body := `{"carID":"1","plateUTF8":"AB123","ImageArray":[{"ImageType":"plate","ImageFormat":"png","BinaryImage":"` +
+		base64.StdEncoding.EncodeToString(buf.Bytes()) + `"}]}`
+	res, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The database keeps keys, the bucket the files
+	ev, err := q.GetEventByID(ctx, res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.RawJson != nil || ev.JsonFilename == nil {
+		t.Errorf("event raw_json = %v, json_filename = %v; want only the key", ev.RawJson, ev.JsonFilename)
+	}
+	if got := s.eventJSON(ctx, ev.RawJson, ev.JsonFilename); got == nil || *got != body {
+		t.Errorf("JSON from the store = %v", got)
+	}
+	images, _ := q.GetImagesByEventID(ctx, res.ID)
+	if len(images) != 1 {
+		t.Fatalf("images = %d, want 1", len(images))
+	}
+	row, _ := q.GetImageData(ctx, images[0].ID)
+	if len(row.ImageData) != 0 || row.DiskFilename == nil {
+		t.Errorf("image row keeps %d bytes, key %v; want only the key", len(row.ImageData), row.DiskFilename)
+	}
+	if data, err := s.loadImage(ctx, q, images[0].ID); err != nil || !bytes.Equal(data, buf.Bytes()) {
+		t.Errorf("image from the store = %d bytes, %v", len(data), err)
+	}
+	if len(objects) != 2 {
+		t.Errorf("bucket has %d objects, want 2", len(objects))
+	}
+	for key := range objects {
+		if !strings.HasPrefix(key, "/mmr/site1/json/") && !strings.HasPrefix(key, "/mmr/site1/images/") {
+			t.Errorf("unexpected object %s", key)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "images")); len(entries) != 0 {
+		t.Errorf("data dir has %d image files", len(entries))
+	}
+
+	// Served like images kept in the database
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/image/1", nil)
+	r.SetPathValue("id", "1")
+	s.HandleImage(w, r)
+	if !bytes.Equal(w.Body.Bytes(), buf.Bytes()) {
+		t.Errorf("GET /image/1 = %d %d bytes", w.Code, w.Body.Len())
+	}
+
+	// Deleting files removes the objects
+	s.removeBlob(ctx, blobImagePrefix+*row.DiskFilename)
+	s.removeBlob(ctx, blobJSONPrefix+*ev.JsonFilename)
+	if len(objects) != 0 {
+		t.Errorf("bucket has %d objects after removal", len(objects))
+	}
+}
@@ -0,0 +1,213 @@
+package srv
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// GET /archive/{id}/download.zip hands a complete test session over, e.g.
+// to the camera vendor: every event's camera JSON and images, one folder
+// per event, and a manifest.json listing the events and the SHA-256 of each
+// file:
+//
+//	Trial_1/manifest.json
+//	Trial_1/events/000042_AB123CD/event.json
+//	Trial_1/events/000042_AB123CD/message_2_update.json   (continuation messages)
+//	Trial_1/events/000042_AB123CD/images/0107_plate.jpg
+//
+// The ZIP is streamed while events are read, so archives of any size
+// download without being held in memory; for the same reason it isn't
+// signed like the other exports; the manifest's hashes cover its files.
+
+// bundleManifest is manifest.json of an archive download
+type bundleManifest struct {
+	Archive    *dbgen.Archive `json:"archive,omitempty"` // none for the current session
+	ExportedAt time.Time      `json:"exported_at"`
+	Node       string         `json:"node,omitempty"`
+	Events     []bundleEvent  `json:"events"`
+	Files      int            `json:"files"`
+	Errors     []string       `json:"errors,omitempty"` // files that couldn't be read
+	files      map[string]int // paths in use
+}
+
+// bundleEvent is an event's entry in the manifest
+type bundleEvent struct {
+	ID         int64        `json:"id"`
+	UID        string       `json:"uid,omitempty"`
+	CarID      string       `json:"car_id"`
+	Plate      string       `json:"plate"`
+	Camera     string       `json:"camera,omitempty"`
+	CapturedAt *time.Time   `json:"captured_at,omitempty"`
+	ReceivedAt time.Time    `json:"received_at"`
+	Folder     string       `json:"folder"`
+	Files      []bundleFile `json:"files"`
+}
+
+// bundleFile is a file of an event in the ZIP
+type bundleFile struct {
+	Path   string `json:"path"` // relative to the event folder
+	Kind   string `json:"kind"` // json, message or image type
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// HandleArchiveBundle streams a ZIP of an archive's camera JSON and images
+func (s *Server) HandleArchiveBundle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	q := dbgen.New(s.DB)
+	archive, err := q.GetArchiveByID(ctx, id)
+	if err != nil {
+		http.Error(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	events, err := q.GetBundleEvents(ctx, dbgen.GetBundleEventsParams{ArchiveID: &id})
+	if err != nil {
+		slog.Error("failed to load archive events for download", "archive", id, "error", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	root := fmt.Sprintf("archive_%d", id)
+	if archive.Name != nil && sanitizeFilename(*archive.Name) != "" {
+		root = sanitizeFilename(*archive.Name)
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, root))
+
+	m, err := s.writeBundle(ctx, w, root, &archive, events)
+	if err != nil {
+		// Headers are out; a truncated ZIP is all the client can get
+		slog.Warn("archive download aborted", "archive", id, "error", err)
+		return
+	}
+	slog.Info("archive downloaded", "archive", id, "events", len(m.Events), "files", m.Files, "user", sessionUser(r))
+}
+
+// writeBundle writes the ZIP of events, with their manifest, under root
+func (s *Server) writeBundle(ctx context.Context, w io.Writer, root string, archive *dbgen.Archive, events []dbgen.GetBundleEventsRow) (*bundleManifest, error) {
+	q := dbgen.New(s.DB)
+	zw := zip.NewWriter(w)
+	m := &bundleManifest{Archive: archive, ExportedAt: time.Now(), Node: s.NodeID, Events: []bundleEvent{}, files: map[string]int{}}
+	for _, e := range events {
+		if err := ctx.Err(); err != nil {
+			return m, err
+		}
+		be, err := s.bundleEvent(ctx, q, zw, root, e, m)
+		if err != nil {
+			return m, fmt.Errorf("event %d: %w", e.ID, err)
+		}
+		m.Events = append(m.Events, be)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return m, err
+	}
+	if err := writeZipFile(zw, path.Join(root, "manifest.json"), m.ExportedAt, data, zip.Deflate); err != nil {
+		return m, err
+	}
+	return m, zw.Close()
+}
+
+// bundleEvent writes an event's files to the ZIP
+func (s *Server) bundleEvent(ctx context.Context, q *dbgen.Queries, zw *zip.Writer, root string, e dbgen.GetBundleEventsRow, m *bundleManifest) (bundleEvent, error) {
+	plate := coalesce(deref(e.PlateUtf8), deref(e.ManualPlate))
+	be := bundleEvent{
+		ID:         e.ID,
+		UID:        deref(e.Uid),
+		CarID:      e.CarID,
+		Plate:      plate,
+		Camera:     coalesce(deref(e.CameraSerial), deref(e.SensorProviderID)),
+		CapturedAt: e.CapturedAt,
+		ReceivedAt: e.CreatedAt,
+		Folder:     fmt.Sprintf("events/%06d_%s", e.ID, coalesce(sanitizeFilename(plate), "unknown")),
+		Files:      []bundleFile{},
+	}
+	modified := seenAt(e.CapturedAt, e.CreatedAt)
+	add := func(name, kind string, data []byte, method uint16) error {
+		if n := m.files[be.Folder+"/"+name]; n > 0 {
+			ext := path.Ext(name)
+			name = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), n+1, ext)
+		}
+		m.files[be.Folder+"/"+name]++
+		if err := writeZipFile(zw, path.Join(root, be.Folder, name), modified, data, method); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		be.Files = append(be.Files, bundleFile{Path: name, Kind: kind, Size: len(data), SHA256: hex.EncodeToString(sum[:])})
+		m.Files++
+		return nil
+	}
+
+	if row, err := q.GetEventRawJSON(ctx, e.ID); err != nil {
+		return be, err
+	} else if raw := s.eventJSON(ctx, row.RawJson, row.JsonFilename); raw != nil {
+		if err := add("event.json", "json", []byte(*raw), zip.Deflate); err != nil {
+			return be, err
+		}
+	}
+	messages, err := q.GetEventMessages(ctx, e.ID)
+	if err != nil {
+		return be, err
+	}
+	for i, msg := range messages {
+		if msg.RawJson == nil {
+			continue // the first message is event.json
+		}
+		name := fmt.Sprintf("message_%d", i+1)
+		if st := sanitizeFilename(deref(msg.CarState)); st != "" {
+			name += "_" + st
+		}
+		if err := add(name+".json", "message", []byte(*msg.RawJson), zip.Deflate); err != nil {
+			return be, err
+		}
+	}
+	images, err := q.GetImagesByEventID(ctx, e.ID)
+	if err != nil {
+		return be, err
+	}
+	for _, img := range images {
+		if img.DeletedAt != nil {
+			continue
+		}
+		data, err := s.loadImage(ctx, q, img.ID)
+		if err != nil {
+			m.Errors = append(m.Errors, fmt.Sprintf("image %d of event %d: %v", img.ID, e.ID, err))
+			continue
+		}
+		kind := coalesce(deref(img.ImageType), "image")
+		name := fmt.Sprintf("images/%04d_%s%s", img.ID, sanitizeFilename(kind), imageExt(data))
+		// Images are compressed already
+		if err := add(name, kind, data, zip.Store); err != nil {
+			return be, err
+		}
+	}
+	return be, nil
+}
+
+// writeZipFile adds a file to a ZIP
+func writeZipFile(zw *zip.Writer, name string, modified time.Time, data []byte, method uint16) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
@@ -0,0 +1,99 @@
+package srv
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestArchiveBundle(t *testing.T) {
+	s := newTestServer(t)
+	s.MergeWindow = DefaultMergeWindow
+	ctx := context.Background()
+	var img bytes.Buffer
+	png.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)))
+	b64 := base64.StdEncoding.EncodeToString(img.Bytes())
+	for _, body := range []string{
+		`{"carID":"7","carState":"new","sensorProviderID":"cam1","plateUTF8":"ZB 1","ImageArray":[{"ImageType":"plate","ImageFormat":"png","BinaryImage":"` + b64 + `"}]}`,
+		`{"carID":"7","carState":"update","sensorProviderID":"cam1","plateUTF8":"ZB 1"}`,
+		`{"carID":"8","plateUTF8":""}`,
+	} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('Trial 1')")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+
+	r := httptest.NewRequest("GET", "/archive/1/download.zip", nil)
+	r.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	s.HandleArchiveBundle(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Disposition") != `attachment; filename="Trial_1.zip"` {
+		t.Fatalf("%d %v", w.Code, w.Header())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	want := []string{
+		"Trial_1/events/000001_ZB_1/event.json",
+		"Trial_1/events/000001_ZB_1/images/0001_plate.png",
+		"Trial_1/events/000001_ZB_1/message_2_update.json",
+		"Trial_1/events/000002_unknown/event.json",
+		"Trial_1/manifest.json",
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("files %q", names)
+	}
+	if !bytes.Equal(files[want[1]], img.Bytes()) {
+		t.Error("image differs")
+	}
+
+	var m bundleManifest
+	if err := json.Unmarshal(files["Trial_1/manifest.json"], &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Events) != 2 || m.Files != 4 || m.Events[0].Plate != "ZB 1" || len(m.Events[0].Files) != 3 {
+		t.Fatalf("manifest %+v", m)
+	}
+	for _, e := range m.Events {
+		for _, f := range e.Files {
+			sum := sha256.Sum256(files["Trial_1/"+e.Folder+"/"+f.Path])
+			if hex.EncodeToString(sum[:]) != f.SHA256 {
+				t.Errorf("%s/%s: hash mismatch", e.Folder, f.Path)
+			}
+		}
+	}
+
+	r = httptest.NewRequest("GET", "/archive/9/download.zip", nil)
+	r.SetPathValue("id", "9")
+	w = httptest.NewRecorder()
+	s.HandleArchiveBundle(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing archive: %d", w.Code)
+	}
+}
@@ -0,0 +1,231 @@
+package srv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// With -calendar-config the site's operating hours and public holidays
+// split traffic analytics into in and out of hours, and camera-offline
+// alerting only counts silence while the site is working:
+//
+//	{"hours": {"mon-fri": ["07:00-12:00", "12:30-19:00"], "sat": ["08:00-12:00"], "sun": ["22:00-06:00"]},
+//	 "holidays": {"2026-12-25": "Christmas Day", "01-01": "New Year's Day"}}
+//
+// Days without hours are closed. A range ending at or before its start runs
+// past midnight and belongs to the day it starts on, like a night shift.
+// Holidays are closed all day; "MM-DD" repeats every year. Times are in the
+// server's zone, like the rollups.
+
+// Schedule periods of the analytics breakdown
+const (
+	periodInHours    = "in_hours"
+	periodOutOfHours = "out_of_hours"
+	periodHoliday    = "holiday"
+)
+
+// SiteCalendar is the site's working hours and holidays
+type SiteCalendar struct {
+	Hours    map[string][]string `json:"hours"`    // day or day range (mon-fri) -> "HH:MM-HH:MM" ranges
+	Holidays map[string]string   `json:"holidays"` // YYYY-MM-DD or MM-DD -> name
+
+	shifts [7][]shift // by time.Weekday
+}
+
+// shift is a working time range in minutes after the midnight it starts
+// from; end is past 1440 for shifts running into the next day
+type shift struct {
+	start, end int
+}
+
+var calendarDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// LoadSiteCalendar reads a site calendar file
+func LoadSiteCalendar(path string) (*SiteCalendar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c SiteCalendar
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := c.compile(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// compile parses the hours and checks the holiday dates
+func (c *SiteCalendar) compile() error {
+	if len(c.Hours) == 0 {
+		return errors.New("hours are required")
+	}
+	for days, ranges := range c.Hours {
+		weekdays, err := parseWeekdays(days)
+		if err != nil {
+			return err
+		}
+		for _, r := range ranges {
+			sh, err := parseShift(r)
+			if err != nil {
+				return fmt.Errorf("hours %s: %w", days, err)
+			}
+			for _, d := range weekdays {
+				c.shifts[d] = append(c.shifts[d], sh)
+			}
+		}
+	}
+	for date := range c.Holidays {
+		layout := time.DateOnly
+		if len(date) == len("01-02") {
+			layout = "01-02"
+		}
+		if _, err := time.Parse(layout, date); err != nil {
+			return fmt.Errorf("holiday %q: want YYYY-MM-DD or MM-DD", date)
+		}
+	}
+	return nil
+}
+
+// parseWeekdays reads "mon", "mon-fri" or "fri-mon"
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	day := func(name string) (int, error) {
+		for i, d := range calendarDays {
+			if strings.EqualFold(strings.TrimSpace(name), d) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown day %q: use mon, tue, ..., sun or a range like mon-fri", name)
+	}
+	from, to, isRange := strings.Cut(s, "-")
+	first, err := day(from)
+	if err != nil {
+		return nil, err
+	}
+	last := first
+	if isRange {
+		if last, err = day(to); err != nil {
+			return nil, err
+		}
+	}
+	var out []time.Weekday
+	for d := first; ; d = (d + 1) % 7 {
+		out = append(out, time.Weekday(d))
+		if d == last {
+			return out, nil
+		}
+	}
+}
+
+// parseShift reads "07:00-19:00"; "24:00" ends at midnight
+func parseShift(s string) (shift, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return shift{}, fmt.Errorf("invalid range %q: want HH:MM-HH:MM", s)
+	}
+	var sh shift
+	var err error
+	if sh.start, err = parseClock(from); err != nil || sh.start == 24*60 {
+		return shift{}, fmt.Errorf("invalid range %q: want HH:MM-HH:MM", s)
+	}
+	if sh.end, err = parseClock(to); err != nil {
+		return shift{}, fmt.Errorf("invalid range %q: want HH:MM-HH:MM", s)
+	}
+	if sh.end <= sh.start {
+		sh.end += 24 * 60
+	}
+	return sh, nil
+}
+
+// parseClock returns the minutes after midnight of HH:MM
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hour, err1 := strconv.Atoi(h)
+	minute, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// holiday returns the name of the holiday on t's day, if any
+func (c *SiteCalendar) holiday(t time.Time) string {
+	if c == nil {
+		return ""
+	}
+	if name, ok := c.Holidays[t.Format(time.DateOnly)]; ok {
+		return coalesce(name, "Holiday")
+	}
+	if name, ok := c.Holidays[t.Format("01-02")]; ok {
+		return coalesce(name, "Holiday")
+	}
+	return ""
+}
+
+// workingTime returns the working time ranges starting on day's date
+func (c *SiteCalendar) workingTime(day time.Time) [][2]time.Time {
+	if c.holiday(day) != "" {
+		return nil
+	}
+	var out [][2]time.Time
+	for _, sh := range c.shifts[day.Weekday()] {
+		out = append(out, [2]time.Time{
+			time.Date(day.Year(), day.Month(), day.Day(), 0, sh.start, 0, 0, day.Location()),
+			time.Date(day.Year(), day.Month(), day.Day(), 0, sh.end, 0, 0, day.Location()),
+		})
+	}
+	return out
+}
+
+// open reports whether the site works at t; without a calendar it always does
+func (c *SiteCalendar) open(t time.Time) bool {
+	return c.openFor(t, t.Add(time.Minute)) > 0
+}
+
+// openFor returns how much of from-to is working time
+func (c *SiteCalendar) openFor(from, to time.Time) time.Duration {
+	if !from.Before(to) {
+		return 0
+	}
+	if c == nil {
+		return to.Sub(from)
+	}
+	var total time.Duration
+	// Shifts of the day before can run into from's day
+	day := time.Date(from.Year(), from.Month(), from.Day()-1, 0, 0, 0, 0, from.Location())
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, r := range c.workingTime(day) {
+			start, end := r[0], r[1]
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			if start.Before(end) {
+				total += end.Sub(start)
+			}
+		}
+	}
+	return total
+}
+
+// period sorts an hour of traffic into in hours, out of hours or a
+// holiday by its middle, as rollups only have whole hours
+func (c *SiteCalendar) period(hour time.Time) string {
+	mid := hour.Add(30 * time.Minute)
+	switch {
+	case c.open(mid):
+		return periodInHours
+	case c.holiday(mid) != "":
+		return periodHoliday
+	default:
+		return periodOutOfHours
+	}
+}
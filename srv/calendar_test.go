@@ -0,0 +1,150 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testCalendar = `{
+	"hours": {"mon-fri": ["07:00-12:00", "12:30-19:00"], "sat": ["22:00-06:00"]},
+	"holidays": {"2026-04-03": "Good Friday", "01-01": "New Year's Day"}
+}`
+
+func loadTestCalendar(t *testing.T) *SiteCalendar {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "calendar.json")
+	os.WriteFile(path, []byte(testCalendar), 0o644)
+	c, err := LoadSiteCalendar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSiteCalendar(t *testing.T) {
+	c := loadTestCalendar(t)
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	for _, tt := range []struct {
+		at     string
+		open   bool
+		period string
+	}{
+		{"2026-03-30 06:59", false, periodOutOfHours}, // Monday
+		{"2026-03-30 10:00", true, periodInHours},
+		{"2026-03-30 12:15", false, periodInHours}, // the hour counts by its middle
+		{"2026-03-30 19:00", false, periodOutOfHours},
+		{"2026-04-03 10:00", false, periodHoliday},
+		{"2026-04-04 23:00", true, periodInHours}, // Saturday night shift
+		{"2026-04-05 03:00", true, periodInHours},
+		{"2026-04-05 07:00", false, periodOutOfHours},
+		{"2026-01-01 10:00", false, periodHoliday},
+	} {
+		if got := c.open(at(tt.at)); got != tt.open {
+			t.Errorf("open(%s) = %v", tt.at, got)
+		}
+		if got := c.period(at(tt.at).Truncate(time.Hour)); got != tt.period {
+			t.Errorf("period(%s) = %s, want %s", tt.at, got, tt.period)
+		}
+	}
+	if c.holiday(at("2026-01-01 10:00")) != "New Year's Day" || c.holiday(at("2026-04-02 10:00")) != "" {
+		t.Error("holiday names")
+	}
+	// Friday 18:00 to Monday 08:00: 1h Friday, 8h Saturday night, 1h Monday
+	if got := c.openFor(at("2026-03-27 18:00"), at("2026-03-30 08:00")); got != 10*time.Hour {
+		t.Errorf("openFor = %v", got)
+	}
+	var none *SiteCalendar
+	if !none.open(at("2026-04-03 03:00")) || none.openFor(at("2026-04-03 03:00"), at("2026-04-03 04:00")) != time.Hour {
+		t.Error("no calendar is always open")
+	}
+
+	path := filepath.Join(t.TempDir(), "calendar.json")
+	for _, bad := range []string{
+		`{}`,
+		`{"hours": {"weekdays": ["07:00-19:00"]}}`,
+		`{"hours": {"mon": ["7-19"]}}`,
+		`{"hours": {"mon": ["07:00-25:00"]}}`,
+		`{"hours": {"mon": ["07:00-19:00"]}, "holidays": {"25.12.": "Christmas"}}`,
+	} {
+		os.WriteFile(path, []byte(bad), 0o644)
+		if _, err := LoadSiteCalendar(path); err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+}
+
+func TestScheduleAnalytics(t *testing.T) {
+	s := newTestServer(t)
+	s.CameraTZ = time.Local
+	s.Calendar = loadTestCalendar(t)
+	ctx := context.Background()
+	for _, body := range []string{
+		`{"plateUTF8":"S1","sensorProviderID":"gate","capture_timestamp":"2026-03-30 10:00:00"}`,
+		`{"plateUTF8":"S2","sensorProviderID":"gate","capture_timestamp":"2026-03-30 10:40:00"}`,
+		`{"sensorProviderID":"gate","capture_timestamp":"2026-03-30 20:00:00"}`,
+		`{"plateUTF8":"S4","sensorProviderID":"gate","capture_timestamp":"2026-04-03 11:00:00"}`,
+	} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.buildRollups(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleAnalyticsSchedule(w, httptest.NewRequest("GET", "/api/analytics/schedule?from=2026-03-30&to=2026-04-03", nil))
+	var res struct {
+		Hours map[string]int `json:"hours"`
+		Rows  []cameraSchedule
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	// Monday-Thursday 07-18 in hours (12:00 counts by its middle), Friday a holiday
+	if res.Hours[periodInHours] != 48 || res.Hours[periodOutOfHours] != 48 || res.Hours[periodHoliday] != 24 {
+		t.Errorf("hours %v", res.Hours)
+	}
+	if len(res.Rows) != 1 {
+		t.Fatalf("rows %s", w.Body)
+	}
+	row := res.Rows[0]
+	if row.Camera != "gate" || row.InHours.Events != 2 || *row.InHours.PerHour != 2.0/48 ||
+		row.OutOfHours.Events != 1 || row.OutOfHours.Unrecognized != 1 || row.Holiday.Events != 1 {
+		t.Errorf("row %s", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	s.HandleAnalyticsDaily(w, httptest.NewRequest("GET", "/api/analytics/daily?from=2026-04-03&to=2026-04-03", nil))
+	var daily struct{ Rows []cameraCounts }
+	json.Unmarshal(w.Body.Bytes(), &daily)
+	if len(daily.Rows) != 1 || daily.Rows[0].Holiday != "Good Friday" {
+		t.Errorf("daily %s", w.Body)
+	}
+	w = httptest.NewRecorder()
+	s.HandleAnalyticsHourly(w, httptest.NewRequest("GET", "/api/analytics/hourly?from=2026-03-30&to=2026-03-30", nil))
+	var hourly struct{ Rows []cameraCounts }
+	json.Unmarshal(w.Body.Bytes(), &hourly)
+	if len(hourly.Rows) != 2 || hourly.Rows[0].Period != periodInHours || hourly.Rows[1].Period != periodOutOfHours {
+		t.Errorf("hourly %s", w.Body)
+	}
+
+	s.Calendar = nil
+	w = httptest.NewRecorder()
+	s.HandleAnalyticsSchedule(w, httptest.NewRequest("GET", "/api/analytics/schedule", nil))
+	if w.Code != 404 {
+		t.Errorf("without a calendar: %d", w.Code)
+	}
+}
@@ -0,0 +1,226 @@
+package srv
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// /cameras/{id}/setup shows, for the camera using API key {id}, what to
+// type into each supported camera platform's web interface to push reads
+// here: the target URL, how the key is sent and the upload format. Cameras
+// that can't set headers get the key as ?api_key=. Only the key's hash is
+// stored, so the snippets hold a placeholder made from its prefix; the page
+// fills in the real key when it is pasted in or passed as #key= (the link
+// shown when a key is created), without it reaching the server.
+//
+//	GET /api/cameras/{id}/setup   the same snippets as JSON
+
+// cameraSnippet is the configuration of one camera platform
+type cameraSnippet struct {
+	Platform string           `json:"platform"`
+	Where    string           `json:"where"` // where the settings are in the camera's interface
+	Settings []snippetSetting `json:"settings"`
+	Notes    string           `json:"notes,omitempty"`
+	Example  string           `json:"example,omitempty"` // a shell command sending the same kind of request
+}
+
+type snippetSetting struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// cameraSetup is the setup page of one key
+type cameraSetup struct {
+	Key            apiKeyView      `json:"key"`
+	KeyPlaceholder string          `json:"key_placeholder"` // stands for the key in the snippets
+	BaseURL        string          `json:"base_url"`
+	Required       bool            `json:"required"` // whether ingest needs a key (-require-api-key)
+	Snippets       []cameraSnippet `json:"snippets"`
+}
+
+// keyPlaceholder stands for a key of which only the prefix is known
+func keyPlaceholder(prefix string) string {
+	return prefix + "..."
+}
+
+// cameraSnippets returns the configuration of each camera platform for
+// pushing to base with key
+func (s *Server) cameraSnippets(base, key string) []cameraSnippet {
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" {
+		u = &url.URL{Scheme: "http", Host: "localhost"}
+	}
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	protocol, prefix := strings.ToUpper(u.Scheme), strings.TrimSuffix(u.Path, "/")
+	withKey := func(path string) string {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		return path + sep + "api_key=" + key
+	}
+	jsonField := s.jsonFields()[0]
+
+	snippets := []cameraSnippet{
+		{
+			Platform: "FF Group",
+			Where:    "Settings → Data transfer → HTTP(S) push",
+			Settings: []snippetSetting{
+				{"URL", base + "/api"},
+				{"Method", "POST"},
+				{"Header", "X-API-Key: " + key},
+				{"Body", "multipart/form-data"},
+				{"JSON part", fmt.Sprintf("field %q, file event.json, Content-Type application/json", jsonField)},
+				{"Image parts", "field \"plate\" (plate crop), field \"vehicle\" (overview), JPEG or PNG"},
+			},
+			Notes: "Plain JSON with base64 images in ImageArray works too (Content-Type: application/json).",
+			Example: fmt.Sprintf("curl -H 'X-API-Key: %s' -F '%s=@event.json;type=application/json' -F plate=@plate.jpg -F vehicle=@vehicle.jpg %s/api",
+				key, jsonField, base),
+		},
+		{
+			Platform: "Hikvision (ISAPI)",
+			Where:    "Configuration → Network → Advanced Settings → HTTP Listening; enable \"Notify Surveillance Center\" on the vehicle detection event",
+			Settings: []snippetSetting{
+				{"Destination IP or Host Name", host},
+				{"URL", withKey(prefix + "/api/hikvision")},
+				{"Port", port},
+				{"Protocol", protocol},
+			},
+			Notes: "The camera sends anpr.xml with licensePlatePicture.jpg and detectionPicture.jpg; heartbeats are acknowledged and not stored.",
+		},
+		{
+			Platform: "Dahua ITC",
+			Where:    "Setting → Network → HTTP Upload (or Event → Picture Upload)",
+			Settings: []snippetSetting{
+				{"Server Address", host},
+				{"Port", port},
+				{"Path", withKey(prefix + "/api/dahua")},
+				{"HTTPS", strconv.FormatBool(u.Scheme == "https")},
+				{"Upload", "plate info with the cutout and scene pictures"},
+			},
+			Notes: "Both the ITC JSON upload (base64 pictures) and event manager pushes (multipart) are accepted.",
+		},
+		{
+			Platform: "Axis (VAPIX events)",
+			Where:    "System → Events → Recipients: HTTP(S); then a rule on the plate recognition ACAP's \"plate read\" event sending a notification to it",
+			Settings: []snippetSetting{
+				{"Type", protocol},
+				{"URL", withKey(base + "/api?vendor=axis")},
+				{"Method", "POST"},
+			},
+			Notes: "Notifications on topics without plate/LPR/ANPR are acknowledged and not stored.",
+		},
+		{
+			Platform: "Vaxtor",
+			Where:    "Settings → Output → HTTP POST (XML)",
+			Settings: []snippetSetting{
+				{"URL", withKey(base + "/api?vendor=vaxtor")},
+				{"Method", "POST"},
+				{"Content-Type", "application/xml"},
+				{"Images", "include the plate and overview images (base64)"},
+			},
+		},
+	}
+	if s.Mappings != nil {
+		for _, m := range s.Mappings.Mappings {
+			snippets = append(snippets, cameraSnippet{
+				Platform: m.Vendor + " (field mapping)",
+				Where:    "The camera's HTTP push settings",
+				Settings: []snippetSetting{
+					{"URL", base + "/api?vendor=" + url.QueryEscape(m.Vendor)},
+					{"Method", "POST"},
+					{"Header", "X-API-Key: " + key},
+				},
+				Notes: "Read with the " + m.Vendor + " mapping of -mapping-config. Without header settings use " + withKey(base+"/api?vendor="+url.QueryEscape(m.Vendor)) + ".",
+			})
+		}
+	}
+	if s.Compat != nil {
+		for _, route := range s.Compat.Routes {
+			methods := route.Methods
+			if len(methods) == 0 {
+				methods = []string{"POST"}
+			}
+			settings := []snippetSetting{
+				{"URL", withKey(base + route.Path)},
+				{"Method", strings.Join(methods, ", ")},
+			}
+			if route.Vendor != "" {
+				settings = append(settings, snippetSetting{"Format", route.Vendor})
+			}
+			snippets = append(snippets, cameraSnippet{
+				Platform: "Legacy receiver " + route.Path,
+				Where:    "Cameras still pointed at the old receiver: change only the host",
+				Settings: settings,
+			})
+		}
+	}
+	return snippets
+}
+
+// cameraSetup loads a key and builds its snippets. It answers the request
+// and returns false if the key doesn't exist.
+func (s *Server) cameraSetup(w http.ResponseWriter, r *http.Request, fail func(http.ResponseWriter, string, int)) (cameraSetup, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		fail(w, "invalid key id", http.StatusBadRequest)
+		return cameraSetup{}, false
+	}
+	k, err := dbgen.New(s.DB).GetAPIKey(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		fail(w, "API key not found", http.StatusNotFound)
+		return cameraSetup{}, false
+	}
+	if err != nil {
+		fail(w, "database error", http.StatusInternalServerError)
+		return cameraSetup{}, false
+	}
+	base := s.linkBaseURL(r)
+	placeholder := keyPlaceholder(k.KeyPrefix)
+	return cameraSetup{
+		Key:            newAPIKeyView(k),
+		KeyPlaceholder: placeholder,
+		BaseURL:        base,
+		Required:       s.RequireAPIKey,
+		Snippets:       s.cameraSnippets(base, placeholder),
+	}, true
+}
+
+// HandleCameraSetup shows the configuration snippets for a camera's key
+func (s *Server) HandleCameraSetup(w http.ResponseWriter, r *http.Request) {
+	setup, ok := s.cameraSetup(w, r, http.Error)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "camera_setup.html", setup); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleCameraSetupAPI returns the configuration snippets for a camera's
+// key
+func (s *Server) HandleCameraSetupAPI(w http.ResponseWriter, r *http.Request) {
+	setup, ok := s.cameraSetup(w, r, s.jsonError)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(setup)
+}
@@ -0,0 +1,77 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestCameraSetup(t *testing.T) {
+	s := newTestServer(t)
+	s.RequireAPIKey = true
+	s.PublicURL = "https://lpr.example.com:8443/"
+	s.Compat = &CompatConfig{Routes: []*CompatRoute{{Path: "/receiver/upload.php", Vendor: "hikvision"}}}
+	key, hash, prefix := newAPIKey()
+	k, err := dbgen.New(s.DB).CreateAPIKey(context.Background(), dbgen.CreateAPIKeyParams{Name: "gate", KeyHash: hash, KeyPrefix: prefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatInt(k.ID, 10)
+
+	call := func(handler http.HandlerFunc, id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/cameras/"+id+"/setup", nil)
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+	w := call(s.HandleCameraSetupAPI, id)
+	var setup cameraSetup
+	if err := json.NewDecoder(w.Body).Decode(&setup); err != nil {
+		t.Fatal(err)
+	}
+	if setup.KeyPlaceholder != prefix+"..." || setup.BaseURL != "https://lpr.example.com:8443" || setup.Key.Name != "gate" {
+		t.Errorf("setup %+v", setup)
+	}
+	settings := map[string]map[string]string{}
+	for _, sn := range setup.Snippets {
+		settings[sn.Platform] = map[string]string{}
+		for _, st := range sn.Settings {
+			settings[sn.Platform][st.Name] = st.Value
+		}
+	}
+	hik := settings["Hikvision (ISAPI)"]
+	if hik["Destination IP or Host Name"] != "lpr.example.com" || hik["Port"] != "8443" || hik["Protocol"] != "HTTPS" ||
+		hik["URL"] != "/api/hikvision?api_key="+setup.KeyPlaceholder {
+		t.Errorf("hikvision %v", hik)
+	}
+	if ff := settings["FF Group"]; ff["URL"] != "https://lpr.example.com:8443/api" || ff["Header"] != "X-API-Key: "+setup.KeyPlaceholder {
+		t.Errorf("ffgroup %v", ff)
+	}
+	if axis := settings["Axis (VAPIX events)"]; axis["URL"] != "https://lpr.example.com:8443/api?vendor=axis&api_key="+setup.KeyPlaceholder {
+		t.Errorf("axis %v", axis)
+	}
+	if legacy := settings["Legacy receiver /receiver/upload.php"]; legacy["Format"] != "hikvision" || legacy["Method"] != "POST" {
+		t.Errorf("legacy %v", legacy)
+	}
+
+	// With the key filled in, the snippet's URL passes the key check
+	target := strings.ReplaceAll(hik["URL"], setup.KeyPlaceholder, key)
+	if !s.authorizeAPIKey(httptest.NewRecorder(), httptest.NewRequest("POST", target, nil)) {
+		t.Errorf("%s rejected", target)
+	}
+
+	if w := call(s.HandleCameraSetup, id); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), setup.KeyPlaceholder) ||
+		strings.Contains(w.Body.String(), key) {
+		t.Errorf("page %d:\n%s", w.Code, w.Body)
+	}
+	if w := call(s.HandleCameraSetupAPI, "999"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown key: %d", w.Code)
+	}
+}
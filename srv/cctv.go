@@ -0,0 +1,150 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"text/template"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// With -cctv-config the event page links to recorded video of the CCTV
+// camera that covers the same lane, at the moment of the read. Each ANPR
+// camera (by serial number, sensor provider ID or IP) gets a playback URL
+// of the site's NVR as a text/template:
+//
+//	{"cameras": {"CAM123": {
+//	  "name": "Gate 1 overview",
+//	  "url": "https://nvr.local/playback?ch=3&start={{.Start.Unix}}&end={{.End.Unix}}",
+//	  "before": 10, "after": 20, "zone": "UTC", "offset": -2
+//	}}}
+//
+// .Time is the capture time (else the time received), .Start and .End are
+// before and after seconds around it (default 10 and 20). All three are in
+// the NVR's zone (default the server's) and shifted by offset seconds for
+// an NVR clock that is off, so {{.Time.Format "2006-01-02T15:04:05"}},
+// {{.Start.Unix}} or {{.Time.UnixMilli}} give the form the NVR expects.
+// .Camera, .Plate and .EventID are there too; query escapes a value.
+
+// Default video window around a read
+const (
+	DefaultCCTVBefore = 10
+	DefaultCCTVAfter  = 20
+)
+
+// CCTVConfig maps ANPR cameras to the recorded video covering them
+type CCTVConfig struct {
+	Cameras map[string]*CCTVStream `json:"cameras"` // camera serial, sensor provider ID or IP -> stream
+}
+
+// CCTVStream is the NVR playback URL of one CCTV camera
+type CCTVStream struct {
+	Name   string `json:"name"`   // link text (default "Recorded video")
+	URL    string `json:"url"`    // text/template of the playback URL
+	Before *int   `json:"before"` // seconds of video before the read (default 10)
+	After  *int   `json:"after"`  // seconds of video after the read (default 20)
+	Zone   string `json:"zone"`   // time zone of the NVR, e.g. UTC (default local)
+	Offset int    `json:"offset"` // seconds added to event times for the NVR clock
+
+	tmpl *template.Template
+	loc  *time.Location
+}
+
+// videoLink is a link to recorded video of an event
+type videoLink struct {
+	Name string
+	URL  string
+}
+
+// LoadCCTVConfig reads a CCTV video link config file
+func LoadCCTVConfig(path string) (*CCTVConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg CCTVConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for name, st := range cfg.Cameras {
+		if err := st.compile(); err != nil {
+			return nil, fmt.Errorf("camera %s: %w", name, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// compile parses the URL template and fills in defaults
+func (st *CCTVStream) compile() error {
+	if st == nil || st.URL == "" {
+		return errors.New("url is required")
+	}
+	tmpl, err := template.New("cctv").Funcs(template.FuncMap{"query": url.QueryEscape}).Option("missingkey=error").Parse(st.URL)
+	if err != nil {
+		return fmt.Errorf("parse url: %w", err)
+	}
+	st.tmpl = tmpl
+	st.loc = time.Local
+	if st.Zone != "" {
+		if st.loc, err = time.LoadLocation(st.Zone); err != nil {
+			return fmt.Errorf("zone: %w", err)
+		}
+	}
+	if st.Before == nil {
+		st.Before = ptr(DefaultCCTVBefore)
+	}
+	if st.After == nil {
+		st.After = ptr(DefaultCCTVAfter)
+	}
+	if *st.Before < 0 || *st.After < 0 {
+		return errors.New("before and after can't be negative")
+	}
+	if st.Name == "" {
+		st.Name = "Recorded video"
+	}
+	// Fail at startup rather than on the event page
+	_, err = st.url(dbgen.Event{CreatedAt: time.Now()}, "")
+	return err
+}
+
+// url fills in the playback URL for an event
+func (st *CCTVStream) url(e dbgen.Event, camera string) (string, error) {
+	at := seenAt(e.CapturedAt, e.CreatedAt).Add(time.Duration(st.Offset) * time.Second).In(st.loc)
+	var buf bytes.Buffer
+	err := st.tmpl.Execute(&buf, map[string]any{
+		"Time":    at,
+		"Start":   at.Add(-time.Duration(*st.Before) * time.Second),
+		"End":     at.Add(time.Duration(*st.After) * time.Second),
+		"Camera":  camera,
+		"Plate":   coalesce(deref(e.ManualPlate), deref(e.PlateUtf8)),
+		"EventID": e.ID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("url: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// videoLink is the recorded video of an event's camera, nil if there is none
+func (c *CCTVConfig) videoLink(e dbgen.Event) (*videoLink, error) {
+	if c == nil {
+		return nil, nil
+	}
+	for _, k := range []string{deref(e.CameraSerial), deref(e.SensorProviderID), deref(e.CameraIp)} {
+		st, ok := c.Cameras[k]
+		if !ok || k == "" {
+			continue
+		}
+		u, err := st.url(e, k)
+		if err != nil {
+			return nil, err
+		}
+		return &videoLink{Name: st.Name, URL: u}, nil
+	}
+	return nil, nil
+}
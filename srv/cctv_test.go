@@ -0,0 +1,60 @@
+package srv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestCCTVVideoLink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cctv.json")
+	os.WriteFile(path, []byte(`{"cameras": {
+		"CAM-1": {"name": "Gate 1", "zone": "UTC", "offset": -2,
+		  "url": "https://nvr/play?ch=3&start={{.Start.Unix}}&end={{.End.Unix}}&at={{.Time.Format \"2006-01-02T15:04:05\"}}&plate={{query .Plate}}"},
+		"gate": {"url": "https://nvr/{{.Camera}}/{{.Time.UnixMilli}}", "before": 0, "after": 5}
+	}}`), 0o644)
+	cfg, err := LoadCCTVConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 5, 3, 14, 11, 4, 0, time.UTC)
+	for _, tt := range []struct {
+		event dbgen.Event
+		want  *videoLink
+	}{
+		{dbgen.Event{ID: 1, CameraSerial: ptr("CAM-1"), PlateUtf8: ptr("AB 1"), CapturedAt: &at},
+			&videoLink{"Gate 1", "https://nvr/play?ch=3&start=1777817452&end=1777817482&at=2026-05-03T14:11:02&plate=AB+1"}},
+		{dbgen.Event{ID: 2, SensorProviderID: ptr("gate"), CreatedAt: at},
+			&videoLink{"Recorded video", "https://nvr/gate/1777817464000"}},
+		{dbgen.Event{ID: 3, CameraSerial: ptr("CAM-2"), CreatedAt: at}, nil},
+	} {
+		got, err := cfg.videoLink(tt.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("event %d: %+v, want %+v", tt.event.ID, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"cameras": {"CAM-1": {"url": ""}}}`,
+		`{"cameras": {"CAM-1": {"url": "https://nvr/{{.Start.Unix"}}}`,
+		`{"cameras": {"CAM-1": {"url": "https://nvr/{{.Frame}}"}}}`,
+		`{"cameras": {"CAM-1": {"url": "https://nvr/", "zone": "Mars/Base"}}}`,
+		`{"cameras": {"CAM-1": {"url": "https://nvr/", "before": -1}}}`,
+	} {
+		os.WriteFile(path, []byte(bad), 0o644)
+		if _, err := LoadCCTVConfig(path); err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+
+	var none *CCTVConfig
+	if link, err := none.videoLink(dbgen.Event{CameraSerial: ptr("CAM-1")}); link != nil || err != nil {
+		t.Errorf("no config: %+v %v", link, err)
+	}
+}
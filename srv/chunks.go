@@ -0,0 +1,275 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Some cameras split a large BinaryImage across several messages with the
+// same carID and packetCounter. Each message carries a slice of the base64
+// in an ImageArray entry with ChunkIndex (from 0) and ChunkCount. Messages
+// are held in memory until every split image of the packet is complete, and
+// then ingested as one message: the first message's JSON with each split
+// image joined into a single ImageArray entry. A packet still incomplete
+// after ChunkTimeout is ingested without the incomplete images, so the event
+// isn't lost and no truncated image is stored. Held messages keep their
+// journal entries until their packet is ingested, so a restart resumes them.
+
+// DefaultChunkTimeout is how long a split image may take to arrive
+const DefaultChunkTimeout = 30 * time.Second
+
+const (
+	maxImageChunks     = 1000 // per image
+	chunkSweepInterval = time.Second
+)
+
+// chunkBuffer holds packets whose images are still arriving
+type chunkBuffer struct {
+	mu      sync.Mutex
+	packets map[string]*chunkPacket
+}
+
+// chunkPacket is a message whose split images are being reassembled
+type chunkPacket struct {
+	first    ingestRequest
+	images   []uploadedImage          // multipart images of every message
+	embedded []map[string]any         // ImageArray entries of every message that aren't split
+	chunked  map[string]*chunkedImage // split images by ImageType
+	order    []string                 // split image types in arrival order
+	entries  []string                 // journal entries to clear once ingested
+	updated  time.Time
+}
+
+// chunkedImage is one split ImageArray image
+type chunkedImage struct {
+	entry    map[string]any // ImageArray entry of the first chunk received
+	parts    []string
+	received int
+}
+
+func (img *chunkedImage) complete() bool {
+	return img.received == len(img.parts)
+}
+
+// chunkMessage is a message carrying at least one image chunk
+type chunkMessage struct {
+	key     string
+	event   IncomingEvent
+	entries []map[string]any // ImageArray as sent
+}
+
+// readChunkMessage returns the chunk details of a message, or nil if no
+// image of it is split
+func readChunkMessage(req ingestRequest) (*chunkMessage, error) {
+	if len(req.RawJSON) == 0 {
+		return nil, nil
+	}
+	var event IncomingEvent
+	if err := json.Unmarshal(req.RawJSON, &event); err != nil {
+		return nil, nil // ingest reports it
+	}
+	split := false
+	for i, img := range event.ImageArray {
+		if img.ChunkCount == 0 && img.ChunkIndex == 0 {
+			continue
+		}
+		if img.ChunkCount < 1 || img.ChunkCount > maxImageChunks {
+			return nil, &payloadError{fmt.Sprintf("ImageArray[%d]: ChunkCount must be 1 to %d", i, maxImageChunks)}
+		}
+		if img.ChunkIndex < 0 || img.ChunkIndex >= img.ChunkCount {
+			return nil, &payloadError{fmt.Sprintf("ImageArray[%d]: ChunkIndex %d out of range for %d chunks", i, img.ChunkIndex, img.ChunkCount)}
+		}
+		split = split || img.ChunkCount > 1
+	}
+	if !split {
+		return nil, nil
+	}
+	if event.PacketCounter == "" {
+		return nil, &payloadError{"split image without packetCounter"}
+	}
+	var raw struct {
+		ImageArray []map[string]any `json:"ImageArray"`
+	}
+	if err := json.Unmarshal(req.RawJSON, &raw); err != nil || len(raw.ImageArray) != len(event.ImageArray) {
+		return nil, &payloadError{"ImageArray entries must be objects"}
+	}
+
+	camera := event.SensorProviderID
+	if event.CameraInfo != nil && event.CameraInfo.SerialNumber != "" {
+		camera = event.CameraInfo.SerialNumber
+	}
+	carID := coalesce(event.CarID, event.CarId, event.CarId2)
+	return &chunkMessage{
+		key:     camera + "\x00" + carID + "\x00" + event.PacketCounter,
+		event:   event,
+		entries: raw.ImageArray,
+	}, nil
+}
+
+// add buffers a message of a packet and returns the packet once all of its
+// split images are complete. entry is the message's journal entry.
+func (b *chunkBuffer) add(req ingestRequest, msg *chunkMessage, entry string) (*chunkPacket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := b.packets[msg.key]
+	if p == nil {
+		p = &chunkPacket{first: req, chunked: map[string]*chunkedImage{}}
+	}
+
+	// Check before changing anything, so a bad message leaves the packet as it was
+	for i, img := range msg.event.ImageArray {
+		if c := p.chunked[coalesce(img.ImageType, "embedded")]; c != nil && img.ChunkCount > 1 && img.ChunkCount != len(c.parts) {
+			return nil, &payloadError{fmt.Sprintf("ImageArray[%d]: ChunkCount %d, earlier chunks said %d", i, img.ChunkCount, len(c.parts))}
+		}
+	}
+
+	for i, img := range msg.event.ImageArray {
+		if img.ChunkCount <= 1 {
+			p.embedded = append(p.embedded, msg.entries[i])
+			continue
+		}
+		imgType := coalesce(img.ImageType, "embedded")
+		c := p.chunked[imgType]
+		if c == nil {
+			c = &chunkedImage{entry: msg.entries[i], parts: make([]string, img.ChunkCount)}
+			p.chunked[imgType] = c
+			p.order = append(p.order, imgType)
+		}
+		if c.parts[img.ChunkIndex] == "" {
+			c.received++
+		}
+		// A resent chunk replaces the earlier copy
+		c.parts[img.ChunkIndex] = img.BinaryImage
+	}
+	p.images = append(p.images, req.Images...)
+	if entry != "" {
+		p.entries = append(p.entries, entry)
+	}
+	p.updated = time.Now()
+
+	for _, c := range p.chunked {
+		if !c.complete() {
+			if b.packets == nil {
+				b.packets = map[string]*chunkPacket{}
+			}
+			b.packets[msg.key] = p
+			return nil, nil
+		}
+	}
+	delete(b.packets, msg.key)
+	return p, nil
+}
+
+// expired removes and returns the packets not added to for timeout, or all
+// of them with a zero timeout
+func (b *chunkBuffer) expired(now time.Time, timeout time.Duration) []*chunkPacket {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []*chunkPacket
+	for key, p := range b.packets {
+		if timeout == 0 || now.Sub(p.updated) >= timeout {
+			out = append(out, p)
+			delete(b.packets, key)
+		}
+	}
+	return out
+}
+
+// request joins the packet into one ingest request. Incomplete split images
+// are left out and their types returned.
+func (p *chunkPacket) request() (ingestRequest, []string, error) {
+	var event map[string]any
+	if err := json.Unmarshal(p.first.RawJSON, &event); err != nil {
+		return ingestRequest{}, nil, err
+	}
+	images := append([]map[string]any{}, p.embedded...)
+	var missing []string
+	for _, imgType := range p.order {
+		c := p.chunked[imgType]
+		if !c.complete() {
+			missing = append(missing, fmt.Sprintf("%s (%d of %d chunks)", imgType, c.received, len(c.parts)))
+			continue
+		}
+		entry := map[string]any{}
+		for k, v := range c.entry {
+			entry[k] = v
+		}
+		delete(entry, "ChunkIndex")
+		delete(entry, "ChunkCount")
+		entry["BinaryImage"] = strings.Join(c.parts, "")
+		images = append(images, entry)
+	}
+	event["ImageArray"] = images
+	rawJSON, err := json.Marshal(event)
+	if err != nil {
+		return ingestRequest{}, nil, err
+	}
+	req := p.first
+	req.RawJSON = rawJSON
+	req.Images = p.images
+	return req, missing, nil
+}
+
+// ingestChunk buffers a message carrying part of a split image and ingests
+// its packet once complete. Until then the result is Pending.
+func (s *Server) ingestChunk(ctx context.Context, req ingestRequest, msg *chunkMessage, entry string) (ingestResult, error) {
+	p, err := s.chunks.add(req, msg, entry)
+	if err != nil {
+		s.settleJournalEntry(entry, err)
+		return ingestResult{}, err
+	}
+	if p == nil {
+		return ingestResult{UID: req.UID, Pending: true}, nil
+	}
+	return s.ingestPacket(ctx, p)
+}
+
+// ingestPacket ingests a reassembled packet and clears the journal entries
+// of its messages
+func (s *Server) ingestPacket(ctx context.Context, p *chunkPacket) (ingestResult, error) {
+	req, missing, err := p.request()
+	if err != nil {
+		// The first message was checked on receipt, so this is unexpected
+		return ingestResult{}, fmt.Errorf("join split images: %w", err)
+	}
+	if len(missing) > 0 {
+		slog.Warn("split images incomplete; ingesting without them", "uid", req.UID, "missing", missing, "messages", len(p.entries))
+	}
+	res, err := s.ingestEvent(ctx, req)
+	if err == nil {
+		s.publishEvent(ctx, res.ID)
+	}
+	for _, entry := range p.entries {
+		s.settleJournalEntry(entry, err)
+	}
+	return res, err
+}
+
+// runChunkExpiry ingests packets whose split images stopped arriving.
+// Without the journal, held packets are ingested on shutdown as they would
+// otherwise be lost.
+func (s *Server) runChunkExpiry(ctx context.Context) {
+	flush := func(timeout time.Duration) {
+		for _, p := range s.chunks.expired(time.Now(), timeout) {
+			if _, err := s.ingestPacket(context.WithoutCancel(ctx), p); err != nil {
+				slog.Error("failed to ingest split images", "uid", p.first.UID, "error", err)
+			}
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			if !s.Journal {
+				flush(0)
+			}
+			return
+		case <-time.After(chunkSweepInterval):
+			flush(s.ChunkTimeout)
+		}
+	}
+}
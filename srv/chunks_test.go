@@ -0,0 +1,97 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestReassembleSplitImages(t *testing.T) {
+	s := newTestServer(t)
+	s.Journal = true
+	s.ChunkTimeout = DefaultChunkTimeout
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 40, 30)))
+	b64 := base64.StdEncoding.EncodeToString(buf.Bytes())
+	third := len(b64) / 3
+	slices := []string{b64[:third], b64[third : 2*third], b64[2*third:]}
+	message := func(packet string, index int, extra string) []byte {
+		return fmt.Appendf(nil, `{"carID":"5","packetCounter":%q,"plateUTF8":"AB123","sensorProviderID":"cam1",
+			"ImageArray":[{"ImageType":"vehicle","ImageFormat":"png","ChunkIndex":%d,"ChunkCount":3,"BinaryImage":%q}%s]}`,
+			packet, index, slices[index], extra)
+	}
+	ingest := func(body []byte) ingestResult {
+		t.Helper()
+		res, err := s.ingest(ctx, newIngestRequest(body, "", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// Chunks out of order, one resent, a whole plate image alongside
+	if res := ingest(message("1", 2, "")); !res.Pending || res.ID != 0 {
+		t.Fatalf("first chunk = %+v, want pending", res)
+	}
+	ingest(message("1", 0, `,{"ImageType":"plate","ImageFormat":"png","BinaryImage":"`+b64+`"}`))
+	ingest(message("1", 0, ""))
+	res := ingest(message("1", 1, ""))
+	if res.Pending || res.ID == 0 || res.Images != 2 {
+		t.Fatalf("last chunk = %+v, want the event stored with 2 images", res)
+	}
+	images, _ := q.GetImagesByEventID(ctx, res.ID)
+	for _, img := range images {
+		data, _ := s.loadImage(ctx, q, img.ID)
+		if !bytes.Equal(data, buf.Bytes()) {
+			t.Errorf("%s image is %d bytes, want the joined %d", deref(img.ImageType), len(data), buf.Len())
+		}
+	}
+	if n, _ := q.CountEvents(ctx); n != 1 {
+		t.Errorf("events = %d, want 1", n)
+	}
+	if entries, _ := os.ReadDir(s.journalDir()); len(entries) != 0 {
+		t.Errorf("journal has %d entries after the packet was stored", len(entries))
+	}
+
+	// Mismatched chunk counts are rejected without disturbing the packet
+	ingest(message("2", 0, ""))
+	if _, err := s.ingest(ctx, newIngestRequest([]byte(`{"carID":"5","packetCounter":"2","sensorProviderID":"cam1",
+		"ImageArray":[{"ImageType":"vehicle","ChunkIndex":1,"ChunkCount":4,"BinaryImage":"AAAA"}]}`), "", nil)); err == nil {
+		t.Error("chunk count mismatch accepted")
+	}
+	if _, err := s.ingest(ctx, newIngestRequest([]byte(`{"carID":"5","ImageArray":[{"ChunkIndex":0,"ChunkCount":2,"BinaryImage":"AAAA"}]}`), "", nil)); err == nil {
+		t.Error("split image without packetCounter accepted")
+	}
+
+	// A restart rejoins held chunks from the journal; the timeout stores the
+	// event without the incomplete image
+	s.chunks = chunkBuffer{}
+	if err := s.replayJournal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expired := s.chunks.expired(time.Now().Add(time.Minute), DefaultChunkTimeout)
+	if len(expired) != 1 {
+		t.Fatalf("expired packets = %d, want 1", len(expired))
+	}
+	res, err := s.ingestPacket(ctx, expired[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ID == 0 || res.Images != 0 {
+		t.Errorf("expired packet = %+v, want an event without images", res)
+	}
+	if entries, _ := os.ReadDir(s.journalDir()); len(entries) != 0 {
+		t.Errorf("journal has %d entries after the packet expired", len(entries))
+	}
+}
@@ -0,0 +1,186 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Compacting an archive (/archive/{id}/compact, /api/archive/{id}/compact)
+// drops what is only needed to re-process its events: the camera JSON and
+// the overview (vehicle and other non-plate) images, in the database and as
+// files. The event rows with their normalized fields, the plate crops and
+// the compare results stay, so the archive still shows, compares and counts
+// in statistics. Held and locked archives can't be compacted.
+
+// compactionResult is the /api/archive/{id}/compact response; on GET it is
+// what compacting would remove
+type compactionResult struct {
+	ArchiveID   int64      `json:"archive_id"`
+	DryRun      bool       `json:"dry_run"`
+	Events      int        `json:"events"`     // events with camera JSON
+	Images      int        `json:"images"`     // overview images
+	DBBytes     int64      `json:"db_bytes"`   // including continuation messages' JSON
+	FileBytes   int64      `json:"file_bytes"` // recorded sizes of the files
+	CompactedAt *time.Time `json:"compacted_at,omitempty"`
+}
+
+// TotalBytes is everything compacting gives back
+func (c compactionResult) TotalBytes() int64 {
+	return c.DBBytes + c.FileBytes
+}
+
+// compactArchive strips an archive's camera JSON and overview images; with
+// dryRun it only measures them
+func (s *Server) compactArchive(ctx context.Context, id int64, actor string, dryRun bool) (compactionResult, error) {
+	res := compactionResult{ArchiveID: id, DryRun: dryRun}
+	if held, err := s.archiveHeld(ctx, id); err != nil || held {
+		if held {
+			if !dryRun {
+				s.auditArchive(ctx, id, auditBlocked, "compact refused", actor)
+			}
+			return res, errArchiveHeld
+		}
+		return res, err
+	}
+	if locked, err := s.archiveLocked(ctx, id); err != nil || locked {
+		if locked {
+			return res, errArchiveLocked
+		}
+		return res, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(s.DB).WithTx(tx)
+
+	events, err := q.GetArchiveJSONToCompact(ctx, &id)
+	if err != nil {
+		return res, err
+	}
+	images, err := q.GetArchiveOverviewImages(ctx, &id)
+	if err != nil {
+		return res, err
+	}
+	msgs, err := q.GetArchivePurgeMessages(ctx, &id)
+	if err != nil {
+		return res, err
+	}
+	res.Events, res.Images = len(events), len(images)
+	res.DBBytes = msgs.JsonBytes
+	var files []string
+	for _, e := range events {
+		res.DBBytes += e.JsonBytes
+		if e.JsonFilename != nil && *e.JsonFilename != "" {
+			res.FileBytes += e.JsonBytes
+			files = append(files, blobJSONPrefix+*e.JsonFilename)
+		}
+	}
+	for _, img := range images {
+		res.DBBytes += img.ImageDbBytes
+		if img.DiskFilename != nil && *img.DiskFilename != "" {
+			res.FileBytes += img.SizeBytes
+			files = append(files, blobImagePrefix+*img.DiskFilename)
+		}
+	}
+	if dryRun {
+		return res, nil
+	}
+
+	for _, img := range images {
+		if err := q.DeleteImage(ctx, img.ID); err != nil {
+			return res, err
+		}
+	}
+	if err := q.CompactArchiveEvents(ctx, &id); err != nil {
+		return res, err
+	}
+	if err := q.CompactArchiveMessages(ctx, &id); err != nil {
+		return res, err
+	}
+	now := time.Now()
+	err = q.RecordArchiveCompaction(ctx, dbgen.RecordArchiveCompactionParams{
+		ArchiveID:     id,
+		CompactedAt:   now,
+		CompactedBy:   ptrIfNotEmpty(actor),
+		ImagesRemoved: int64(len(images)),
+		BytesFreed:    res.TotalBytes(),
+	})
+	if err != nil {
+		return res, err
+	}
+	if err := tx.Commit(); err != nil {
+		return res, err
+	}
+	res.CompactedAt = &now
+	s.resetQuotaUsage()
+
+	// Files go once the database no longer points at them
+	for _, f := range files {
+		s.removeBlob(ctx, f)
+	}
+	for _, img := range images {
+		s.removeThumbs(img.ID)
+	}
+	s.auditArchive(ctx, id, auditCompact, fmt.Sprintf("camera JSON of %d events and %d overview images removed", res.Events, res.Images), actor)
+	return res, nil
+}
+
+// compactErrorMessage maps compaction errors to a status and message
+func compactErrorMessage(err error) (int, string) {
+	switch {
+	case errors.Is(err, errArchiveHeld):
+		return http.StatusConflict, "archive is under legal hold and can't be compacted"
+	case errors.Is(err, errArchiveLocked):
+		return http.StatusConflict, "archive is locked and can't be compacted"
+	}
+	return http.StatusInternalServerError, "database error"
+}
+
+// HandleCompactArchive compacts an archive from its page
+func (s *Server) HandleCompactArchive(w http.ResponseWriter, r *http.Request) {
+	id, status, err := s.archiveIDParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if _, err := s.compactArchive(r.Context(), id, sessionUser(r), false); err != nil {
+		status, msg := compactErrorMessage(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("failed to compact archive", "archive_id", id, "error", err)
+		}
+		http.Error(w, msg, status)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/archive/%d", id), http.StatusSeeOther)
+}
+
+// HandleCompactArchiveAPI compacts an archive (POST) or returns what
+// compacting would remove (GET)
+func (s *Server) HandleCompactArchiveAPI(w http.ResponseWriter, r *http.Request) {
+	id, status, err := s.archiveIDParam(r)
+	if err != nil {
+		s.jsonError(w, err.Error(), status)
+		return
+	}
+	res, err := s.compactArchive(r.Context(), id, sessionUser(r), r.Method == http.MethodGet)
+	if err != nil {
+		status, msg := compactErrorMessage(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("failed to compact archive", "archive_id", id, "error", err)
+		}
+		s.jsonError(w, msg, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,116 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompactArchive(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.DataDir
+	os.MkdirAll(filepath.Join(dir, "thumbs"), 0755)
+	var pic bytes.Buffer
+	png.Encode(&pic, image.NewGray(image.Rect(0, 0, 8, 8)))
+	ctx := context.Background()
+	for _, plate := range []string{"CMP1", "CMP2"} {
+		req := newIngestRequest([]byte(`{"plateUTF8":"`+plate+`"}`), "", []uploadedImage{
+			{Filename: "plate.png", Data: pic.Bytes()},
+			{Filename: "vehicle.png", Data: pic.Bytes()},
+		})
+		if _, err := s.ingestEvent(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('old'), ('held')")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+	var jsonFile, overviewFile string
+	var overviewID int64
+	s.DB.QueryRow("SELECT json_filename FROM events WHERE id = 1").Scan(&jsonFile)
+	s.DB.QueryRow("SELECT id, disk_filename FROM images WHERE image_type = 'vehicle' LIMIT 1").Scan(&overviewID, &overviewFile)
+	thumb := filepath.Join(dir, "thumbs", fmt.Sprintf("%d-320.jpg", overviewID))
+	os.WriteFile(thumb, []byte("thumb"), 0644)
+
+	compact := func(method, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "/api/archive/"+id+"/compact", nil)
+		r.SetPathValue("id", id)
+		s.HandleCompactArchiveAPI(w, r)
+		return w
+	}
+
+	// The dry run changes nothing
+	w := compact("GET", "1")
+	var res compactionResult
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if w.Code != http.StatusOK || !res.DryRun || res.Events != 2 || res.Images != 2 || res.FileBytes == 0 {
+		t.Fatalf("dry run: %d %s", w.Code, w.Body)
+	}
+	var n int
+	s.DB.QueryRow("SELECT COUNT(*) FROM images").Scan(&n)
+	if n != 4 {
+		t.Errorf("dry run deleted images: %d left", n)
+	}
+
+	w = compact("POST", "1")
+	res = compactionResult{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if w.Code != http.StatusOK || res.DryRun || res.Images != 2 || res.CompactedAt == nil {
+		t.Fatalf("compact: %d %s", w.Code, w.Body)
+	}
+	s.DB.QueryRow("SELECT COUNT(*) FROM events WHERE raw_json IS NOT NULL OR json_filename IS NOT NULL").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d events still have camera JSON", n)
+	}
+	s.DB.QueryRow("SELECT COUNT(*) FROM events WHERE plate_utf8 IN ('CMP1', 'CMP2')").Scan(&n)
+	if n != 2 {
+		t.Errorf("events kept: %d", n)
+	}
+	var plates, others int
+	s.DB.QueryRow("SELECT COUNT(*) FILTER (WHERE image_type = 'plate'), COUNT(*) FILTER (WHERE image_type != 'plate') FROM images").Scan(&plates, &others)
+	if plates != 2 || others != 0 {
+		t.Errorf("images left: %d plate, %d other", plates, others)
+	}
+	for _, f := range []string{filepath.Join(dir, "json", jsonFile), filepath.Join(dir, "images", overviewFile), thumb} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("%s not removed", f)
+		}
+	}
+	var freed int64
+	s.DB.QueryRow("SELECT bytes_freed FROM archive_compactions WHERE archive_id = 1").Scan(&freed)
+	if freed != res.TotalBytes() || freed == 0 {
+		t.Errorf("bytes freed %d, want %d", freed, res.TotalBytes())
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/archive/1", nil)
+	r.SetPathValue("id", "1")
+	s.HandleArchive(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "🗜 Compacted") {
+		t.Errorf("archive page: %d", w.Code)
+	}
+
+	// Compacting again finds nothing left
+	w = compact("GET", "1")
+	res = compactionResult{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if res.Events != 0 || res.Images != 0 || res.TotalBytes() != 0 {
+		t.Errorf("second dry run: %s", w.Body)
+	}
+
+	if err := s.placeHold(ctx, 2, "dispute", "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if w := compact("POST", "2"); w.Code != http.StatusConflict {
+		t.Errorf("held archive: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,418 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// The compare API lets external labeling tools (e.g. Label Studio) read and
+// write the same per-field verdicts as the compare page:
+//
+//	GET /api/archive/{id}/compare            all events of the archive
+//	PUT /api/archive/{id}/compare            bulk: [{"event_id": 12, "plate": true}, {"uid": "01J…", "color": false}]
+//	GET /api/archive/{id}/compare/{eventID}  one event
+//	PUT /api/archive/{id}/compare/{eventID}  {"plate": true, "model": false}
+//
+// true marks a field as incorrect. Fields left out of a PUT are unchanged.
+// "true_plate" gives the plate the vehicle really had instead of a plate
+// verdict; the verdict follows from comparing the read with it (see
+// platematch.go), "" removes it.
+// Verdicts on events assigned to or reviewed by another user are refused
+// with 409 unless ?force=1 is given; verdicts on events of the user's
+// overlap slices are saved as their second opinion (see review.go).
+
+// compareFields are the fields reviewers can mark as incorrect
+var compareFields = []string{"plate", "maker", "model", "color"}
+
+var errNotInArchive = errors.New("event not in archive")
+
+// compareAnnotation is the verdict of one archived event
+type compareAnnotation struct {
+	EventID   int64      `json:"event_id"`
+	UID       *string    `json:"uid"`
+	Plate     bool       `json:"plate"`
+	Maker     bool       `json:"maker"`
+	Model     bool       `json:"model"`
+	Color     bool       `json:"color"`
+	UpdatedAt *time.Time `json:"updated_at"`
+
+	TruePlate     *string  `json:"true_plate,omitempty"`
+	PlateDistance *float64 `json:"plate_distance,omitempty"` // weighted edit distance of the read to the true plate
+	PlatePartial  bool     `json:"plate_partial,omitempty"`  // a near miss within -plate-match-config's maximum distance
+}
+
+func (a *compareAnnotation) set(field string, incorrect bool) {
+	switch field {
+	case "plate":
+		a.Plate = incorrect
+	case "maker":
+		a.Maker = incorrect
+	case "model":
+		a.Model = incorrect
+	case "color":
+		a.Color = incorrect
+	}
+}
+
+// record sets a stored verdict, keeping the latest update time
+func (a *compareAnnotation) record(field string, incorrect bool, updatedAt *time.Time) {
+	a.set(field, incorrect)
+	if updatedAt != nil && (a.UpdatedAt == nil || updatedAt.After(*a.UpdatedAt)) {
+		a.UpdatedAt = updatedAt
+	}
+}
+
+func (a *compareAnnotation) incorrect(field string) bool {
+	switch field {
+	case "plate":
+		return a.Plate
+	case "maker":
+		return a.Maker
+	case "model":
+		return a.Model
+	case "color":
+		return a.Color
+	}
+	return false
+}
+
+// compareUpdate is a PUT body; the event is given by the URL, event_id or uid
+type compareUpdate struct {
+	EventID int64  `json:"event_id"`
+	UID     string `json:"uid"`
+	Plate   *bool  `json:"plate"`
+	Maker   *bool  `json:"maker"`
+	Model   *bool  `json:"model"`
+	Color   *bool  `json:"color"`
+
+	TruePlate   *string `json:"true_plate"`
+	truthSource string  // plateTruthReviewer unless set
+}
+
+func (u compareUpdate) values() map[string]*bool {
+	return map[string]*bool{"plate": u.Plate, "maker": u.Maker, "model": u.Model, "color": u.Color}
+}
+
+// set records a verdict for a compare field; unknown fields are ignored
+func (u *compareUpdate) set(field string, incorrect *bool) {
+	switch field {
+	case "plate":
+		u.Plate = incorrect
+	case "maker":
+		u.Maker = incorrect
+	case "model":
+		u.Model = incorrect
+	case "color":
+		u.Color = incorrect
+	}
+}
+
+// compareAnnotations loads the verdicts of all events in an archive, in
+// event id order
+func compareAnnotations(ctx context.Context, q *dbgen.Queries, archiveID int64) ([]*compareAnnotation, error) {
+	events, err := q.GetArchivedEventUIDs(ctx, &archiveID)
+	if err != nil {
+		return nil, err
+	}
+	results, err := q.GetCompareResultsDetail(ctx, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*compareAnnotation, len(events))
+	out := make([]*compareAnnotation, 0, len(events))
+	for _, e := range events {
+		a := &compareAnnotation{EventID: e.ID, UID: e.Uid}
+		byID[e.ID] = a
+		out = append(out, a)
+	}
+	for _, r := range results {
+		a := byID[r.EventID]
+		if a == nil {
+			continue
+		}
+		a.record(r.Field, r.IsIncorrect, r.UpdatedAt)
+	}
+	plates, err := q.GetComparePlates(ctx, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plates {
+		if a := byID[p.EventID]; a != nil {
+			a.TruePlate, a.PlateDistance, a.PlatePartial = ptr(p.TruePlate), ptr(p.Distance), p.Partial
+		}
+	}
+	return out, nil
+}
+
+// compareAnnotationOf loads the verdict of one archived event
+func compareAnnotationOf(ctx context.Context, q *dbgen.Queries, archiveID, eventID int64) (*compareAnnotation, error) {
+	e, err := q.GetEventByID(ctx, eventID)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && (e.ArchiveID == nil || *e.ArchiveID != archiveID) {
+		return nil, errNotInArchive
+	}
+	if err != nil {
+		return nil, err
+	}
+	results, err := q.GetEventCompareResults(ctx, dbgen.GetEventCompareResultsParams{ArchiveID: archiveID, EventID: eventID})
+	if err != nil {
+		return nil, err
+	}
+	a := &compareAnnotation{EventID: e.ID, UID: e.Uid}
+	for _, r := range results {
+		a.record(r.Field, r.IsIncorrect, r.UpdatedAt)
+	}
+	p, err := q.GetComparePlate(ctx, dbgen.GetComparePlateParams{ArchiveID: archiveID, EventID: eventID})
+	if err == nil {
+		a.TruePlate, a.PlateDistance, a.PlatePartial = ptr(p.TruePlate), ptr(p.Distance), p.Partial
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return a, nil
+}
+
+// compareArchiveID parses the archive id and checks that the archive exists
+func (s *Server) compareArchiveID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid archive id", http.StatusBadRequest)
+		return 0, false
+	}
+	if _, err := dbgen.New(s.DB).GetArchiveByID(r.Context(), id); err != nil {
+		s.jsonError(w, "archive not found", http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleCompareResultsAPI returns the verdicts of all events in an archive
+func (s *Server) HandleCompareResultsAPI(w http.ResponseWriter, r *http.Request) {
+	archiveID, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	annotations, err := compareAnnotations(r.Context(), dbgen.New(s.DB), archiveID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"archive_id": archiveID,
+		"fields":     compareFields,
+		"events":     annotations,
+	})
+}
+
+// HandleCompareResultAPI returns the verdict of one archived event
+func (s *Server) HandleCompareResultAPI(w http.ResponseWriter, r *http.Request) {
+	archiveID, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	eventID, err := strconv.ParseInt(r.PathValue("eventID"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid event id", http.StatusBadRequest)
+		return
+	}
+	s.writeCompareAnnotation(w, r, archiveID, eventID)
+}
+
+// HandlePutCompareResultAPI updates the verdict of one archived event
+func (s *Server) HandlePutCompareResultAPI(w http.ResponseWriter, r *http.Request) {
+	archiveID, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	eventID, err := strconv.ParseInt(r.PathValue("eventID"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid event id", http.StatusBadRequest)
+		return
+	}
+	var u compareUpdate
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&u); err != nil {
+		s.jsonError(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	u.EventID, u.UID = eventID, ""
+
+	force := r.URL.Query().Get("force") == "1"
+	if _, err := s.saveCompareUpdates(r.Context(), archiveID, []compareUpdate{u}, sessionUser(r), force); err != nil {
+		s.writeCompareError(w, err)
+		return
+	}
+	s.writeCompareAnnotation(w, r, archiveID, eventID)
+}
+
+// HandleBulkCompareAPI applies a list of updates in one transaction; if any
+// of them refers to an event outside the archive, nothing is saved
+func (s *Server) HandleBulkCompareAPI(w http.ResponseWriter, r *http.Request) {
+	archiveID, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	var updates []compareUpdate
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&updates); err != nil {
+		s.jsonError(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	force := r.URL.Query().Get("force") == "1"
+	changed, err := s.saveCompareUpdates(r.Context(), archiveID, updates, sessionUser(r), force)
+	if err != nil {
+		s.writeCompareError(w, err)
+		return
+	}
+	slog.Info("compare results updated", "archive_id", archiveID, "events", len(updates), "fields", changed)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"message": fmt.Sprintf("updated %d fields of %d events", changed, len(updates)),
+		"events":  len(updates),
+		"fields":  changed,
+	})
+}
+
+// saveCompareUpdates writes the given fields and returns how many were set.
+// The events are recorded as reviewed by actor; unless force is set, an
+// event assigned to or reviewed by someone else fails the whole batch.
+// Fields of events actor gives a second opinion on go to that opinion; a
+// true plate there only gives the plate verdict.
+func (s *Server) saveCompareUpdates(ctx context.Context, archiveID int64, updates []compareUpdate, actor string, force bool) (int, error) {
+	if locked, err := s.archiveLocked(ctx, archiveID); err != nil {
+		return 0, err
+	} else if locked {
+		return 0, errArchiveLocked
+	}
+	var plan *reviewPlan
+	if actor != "" {
+		var err error
+		if plan, err = loadReviewPlan(ctx, dbgen.New(s.DB), archiveID); err != nil {
+			return 0, err
+		}
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(s.DB).WithTx(tx)
+
+	events, err := q.GetArchivedEventUIDs(ctx, &archiveID)
+	if err != nil {
+		return 0, err
+	}
+	inArchive := make(map[int64]bool, len(events))
+	byUID := make(map[string]int64, len(events))
+	for _, e := range events {
+		inArchive[e.ID] = true
+		if e.Uid != nil {
+			byUID[*e.Uid] = e.ID
+		}
+	}
+
+	changed := 0
+	for i, u := range updates {
+		eventID := u.EventID
+		if eventID == 0 && u.UID != "" {
+			eventID = byUID[u.UID]
+		}
+		if !inArchive[eventID] {
+			ref := strconv.FormatInt(u.EventID, 10)
+			if u.EventID == 0 {
+				ref = fmt.Sprintf("uid %q", u.UID)
+			}
+			return 0, fmt.Errorf("%w: entry %d (%s)", errNotInArchive, i, ref)
+		}
+		if plan != nil && !force {
+			if err := plan.check(eventID, actor); err != nil {
+				return 0, err
+			}
+		}
+		truth, err := s.classifyTruePlate(ctx, q, archiveID, eventID, &u)
+		if err != nil {
+			return 0, err
+		}
+		if plan != nil && plan.secondOpinion(eventID, actor) {
+			n, err := plan.saveSecondOpinion(ctx, q, archiveID, eventID, actor, u)
+			if err != nil {
+				return 0, err
+			}
+			changed += n
+			continue
+		}
+		if actor != "" {
+			if err := q.RecordEventReview(ctx, dbgen.RecordEventReviewParams{
+				ArchiveID:  archiveID,
+				EventID:    eventID,
+				Username:   actor,
+				ReviewedAt: time.Now(),
+			}); err != nil {
+				return 0, err
+			}
+		}
+		for _, field := range compareFields {
+			v := u.values()[field]
+			if v == nil {
+				continue
+			}
+			if err := q.SetCompareResult(ctx, dbgen.SetCompareResultParams{
+				ArchiveID:   archiveID,
+				EventID:     eventID,
+				Field:       field,
+				IsIncorrect: *v,
+			}); err != nil {
+				return 0, err
+			}
+			changed++
+		}
+		if err := saveComparePlate(ctx, q, archiveID, eventID, u, truth); err != nil {
+			return 0, err
+		}
+	}
+	if changed > 0 {
+		if err := q.DeleteArchiveStats(ctx, archiveID); err != nil {
+			return 0, err
+		}
+	}
+	return changed, tx.Commit()
+}
+
+func (s *Server) writeCompareError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotInArchive) {
+		s.jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errArchiveLocked) || errors.Is(err, errReviewConflict) {
+		s.jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	slog.Warn("failed to save compare result", "error", err)
+	s.jsonError(w, "database error", http.StatusInternalServerError)
+}
+
+func (s *Server) writeCompareAnnotation(w http.ResponseWriter, r *http.Request, archiveID, eventID int64) {
+	a, err := compareAnnotationOf(r.Context(), dbgen.New(s.DB), archiveID, eventID)
+	if errors.Is(err, errNotInArchive) {
+		s.jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
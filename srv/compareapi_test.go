@@ -0,0 +1,54 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareResultAPI(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	for _, body := range []string{`{"plateUTF8":"AB1"}`, `{"plateUTF8":"AB2"}`, `{"plateUTF8":"AB3"}`} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('first'), ('second')")
+	s.DB.Exec("UPDATE events SET archive_id = 1 WHERE id < 3")
+	s.DB.Exec("UPDATE events SET archive_id = 2 WHERE id = 3")
+
+	call := func(h http.HandlerFunc, method, archive, event, body string) (int, compareAnnotation) {
+		t.Helper()
+		r := httptest.NewRequest(method, "/api/archive/"+archive+"/compare/"+event, strings.NewReader(body))
+		r.SetPathValue("id", archive)
+		r.SetPathValue("eventID", event)
+		w := httptest.NewRecorder()
+		h(w, r)
+		var a compareAnnotation
+		json.Unmarshal(w.Body.Bytes(), &a)
+		return w.Code, a
+	}
+
+	if code, a := call(s.HandlePutCompareResultAPI, "PUT", "1", "2", `{"maker": true, "true_plate": "AB2"}`); code != http.StatusOK ||
+		a.EventID != 2 || !a.Maker || a.Plate || deref(a.TruePlate) != "AB2" || a.UpdatedAt == nil {
+		t.Errorf("put: %d %+v", code, a)
+	}
+	// Another event's verdicts don't leak into the answer
+	s.DB.Exec("INSERT INTO compare_results (archive_id, event_id, field, is_incorrect) VALUES (1, 1, 'color', 1)")
+	if code, a := call(s.HandleCompareResultAPI, "GET", "1", "2", ""); code != http.StatusOK || !a.Maker || a.Color || a.UID == nil {
+		t.Errorf("get: %d %+v", code, a)
+	}
+	if code, a := call(s.HandleCompareResultAPI, "GET", "1", "1", ""); code != http.StatusOK || !a.Color || a.Maker || a.TruePlate != nil {
+		t.Errorf("get other: %d %+v", code, a)
+	}
+
+	for _, event := range []string{"3", "99"} {
+		if code, _ := call(s.HandleCompareResultAPI, "GET", "1", event, ""); code != http.StatusNotFound {
+			t.Errorf("event %s outside the archive: %d", event, code)
+		}
+	}
+}
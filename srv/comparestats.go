@@ -0,0 +1,182 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// The accuracy statistics of an archive (compare page, workbook Statistics
+// sheet, GET /api/archive/{id}/compare/stats) are cached in
+// rollup_archive_accuracy, computed on first use. Changing a verdict or a
+// manual plate drops the archive's entry; the rollup job also recomputes
+// entries whose compare results changed some other way. The reviewers'
+// agreement (see agreement.go) and the plate matching (see platematch.go)
+// are not cached but counted on every call.
+
+// compareStats are the cached statistics of an archive
+type compareStats struct {
+	ArchiveID  int64                 `json:"archive_id"`
+	Events     int64                 `json:"events"`
+	Fields     map[string]accuracy   `json:"fields"`
+	ComputedAt time.Time             `json:"computed_at"`
+	Agreement  *agreementStats       `json:"agreement,omitempty"`
+	Plates     *plateMatchStats      `json:"plates,omitempty"` // reads compared with a true plate
+	Alarms     []dbgen.AccuracyAlarm `json:"alarms,omitempty"` // fields below their camera's baseline
+}
+
+func newCompareStats(r dbgen.RollupArchiveAccuracy) compareStats {
+	return compareStats{
+		ArchiveID: r.ArchiveID,
+		Events:    r.Events,
+		Fields: map[string]accuracy{
+			"plate": newAccuracy(r.PlateCorrect, r.PlateIncorrect),
+			"maker": newAccuracy(r.MakerCorrect, r.MakerIncorrect),
+			"model": newAccuracy(r.ModelCorrect, r.ModelIncorrect),
+			"color": newAccuracy(r.ColorCorrect, r.ColorIncorrect),
+		},
+		ComputedAt: r.BuiltAt,
+	}
+}
+
+// Percent is the rounded accuracy shown on the compare page; 100 without data
+func (a accuracy) Percent() int {
+	if a.Pct == nil {
+		return 100
+	}
+	return int(math.Round(*a.Pct))
+}
+
+// compareStats returns an archive's cached statistics, computing them if
+// there are none. A read-only server computes them on every call.
+func (s *Server) compareStats(ctx context.Context, archiveID int64) (compareStats, error) {
+	q := dbgen.New(s.DB)
+	var stats compareStats
+	cached, err := q.GetArchiveStats(ctx, archiveID)
+	switch {
+	case err == nil:
+		stats = newCompareStats(cached)
+	case errors.Is(err, sql.ErrNoRows):
+		row, err := s.computeArchiveStats(ctx, archiveID, time.Now())
+		if err != nil {
+			return compareStats{}, err
+		}
+		stats = newCompareStats(row)
+	default:
+		return compareStats{}, err
+	}
+	if stats.Agreement, err = archiveAgreement(ctx, q, archiveID); err != nil {
+		return compareStats{}, err
+	}
+	if stats.Plates, err = s.archivePlateStats(ctx, q, archiveID, stats.Fields["plate"]); err != nil {
+		return compareStats{}, err
+	}
+	if stats.Alarms, err = q.GetArchiveAccuracyAlarms(ctx, archiveID); err != nil {
+		return compareStats{}, err
+	}
+	return stats, nil
+}
+
+// computeArchiveStats counts an archive's verdicts and caches the result,
+// unless the database is read-only
+func (s *Server) computeArchiveStats(ctx context.Context, archiveID int64, builtAt time.Time) (dbgen.RollupArchiveAccuracy, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return dbgen.RollupArchiveAccuracy{}, err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(s.DB).WithTx(tx)
+
+	signature, err := q.GetArchiveAccuracySignature(ctx, archiveID)
+	if err != nil {
+		return dbgen.RollupArchiveAccuracy{}, err
+	}
+	b, cameras, err := s.archiveAccuracy(ctx, q, archiveID)
+	if err != nil {
+		return dbgen.RollupArchiveAccuracy{}, err
+	}
+	row := dbgen.RollupArchiveAccuracy{
+		ArchiveID:      archiveID,
+		Events:         b.events,
+		PlateCorrect:   b.correct["plate"],
+		PlateIncorrect: b.incorrect["plate"],
+		MakerCorrect:   b.correct["maker"],
+		MakerIncorrect: b.incorrect["maker"],
+		ModelCorrect:   b.correct["model"],
+		ModelIncorrect: b.incorrect["model"],
+		ColorCorrect:   b.correct["color"],
+		ColorIncorrect: b.incorrect["color"],
+		Signature:      signature,
+		BuiltAt:        builtAt,
+	}
+	if s.ReadOnly {
+		return row, nil
+	}
+	if err := q.UpsertArchiveAccuracy(ctx, dbgen.UpsertArchiveAccuracyParams(row)); err != nil {
+		return row, err
+	}
+	if err := q.DeleteArchiveCameraAccuracy(ctx, archiveID); err != nil {
+		return row, err
+	}
+	for camera, c := range cameras {
+		err := q.InsertArchiveCameraAccuracy(ctx, dbgen.InsertArchiveCameraAccuracyParams{
+			ArchiveID:      archiveID,
+			Camera:         camera,
+			Events:         c.events,
+			PlateCorrect:   c.correct["plate"],
+			PlateIncorrect: c.incorrect["plate"],
+			MakerCorrect:   c.correct["maker"],
+			MakerIncorrect: c.incorrect["maker"],
+			ModelCorrect:   c.correct["model"],
+			ModelIncorrect: c.incorrect["model"],
+			ColorCorrect:   c.correct["color"],
+			ColorIncorrect: c.incorrect["color"],
+		})
+		if err != nil {
+			return row, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return row, err
+	}
+	// An empty signature means nobody reviewed the archive yet
+	if s.AccuracyAlarmDelta > 0 && signature != "" {
+		if err := s.checkAccuracyAlarms(ctx, archiveID); err != nil {
+			slog.Warn("accuracy alarm check failed", "archive_id", archiveID, "error", err)
+		}
+	}
+	return row, nil
+}
+
+// invalidateCompareStats drops the cached statistics of an archive
+func (s *Server) invalidateCompareStats(ctx context.Context, q *dbgen.Queries, archiveID *int64) {
+	if archiveID == nil {
+		return
+	}
+	if err := q.DeleteArchiveStats(ctx, *archiveID); err != nil {
+		slog.Warn("failed to invalidate archive stats", "archive_id", *archiveID, "error", err)
+	}
+}
+
+// HandleCompareStatsAPI returns the accuracy statistics of an archive
+func (s *Server) HandleCompareStatsAPI(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	stats, err := s.compareStats(r.Context(), id)
+	if err != nil {
+		slog.Warn("failed to load archive stats", "archive_id", id, "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
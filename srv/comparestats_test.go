@@ -0,0 +1,147 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestCompareStatsCache(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	for _, body := range []string{`{"plateUTF8":"AB1"}`, `{"plateUTF8":"AB2"}`, `{"plateUTF8":"AB3"}`, `{"carID":"4"}`} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('reviewed')")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+
+	stats := func() compareStats {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/archive/1/compare/stats", nil)
+		r.SetPathValue("id", "1")
+		s.HandleCompareStatsAPI(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var st compareStats
+		json.Unmarshal(w.Body.Bytes(), &st)
+		return st
+	}
+	first := stats()
+	// The unrecognized event isn't counted until its plate is entered
+	if p := first.Fields["plate"]; first.Events != 4 || p.Correct != 3 || p.Incorrect != 0 || first.ComputedAt.IsZero() {
+		t.Fatalf("first stats: %+v", first)
+	}
+
+	// Stats are served from the cache until a verdict changes
+	s.DB.Exec("INSERT INTO compare_results (archive_id, event_id, field, is_incorrect) VALUES (1, 1, 'maker', 1)")
+	if again := stats(); again.Fields["maker"].Incorrect != 0 || !again.ComputedAt.Equal(first.ComputedAt) {
+		t.Errorf("not cached: %+v", again)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/archive/1/compare/toggle", strings.NewReader(`{"event_id":2,"field":"plate","incorrect":true}`))
+	r.SetPathValue("id", "1")
+	s.HandleCompareToggle(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("toggle: %d %s", w.Code, w.Body)
+	}
+	toggled := stats()
+	if p := toggled.Fields["plate"]; p.Correct != 2 || p.Incorrect != 1 || toggled.Fields["maker"].Incorrect != 1 {
+		t.Errorf("after toggle: %+v", toggled)
+	}
+
+	// So does a manual plate, and bulk updates through the API
+	form := strings.NewReader("plate=XY999")
+	r = httptest.NewRequest("POST", "/event/4/plate", form)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetPathValue("id", "4")
+	s.HandleSetManualPlate(httptest.NewRecorder(), r)
+	if p := stats().Fields["plate"]; p.Correct != 2 || p.Incorrect != 2 {
+		t.Errorf("after manual plate: %+v", p)
+	}
+	if _, err := s.saveCompareUpdates(ctx, 1, []compareUpdate{{EventID: 3, Color: ptr(true)}}, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if c := stats().Fields["color"]; c.Incorrect != 1 || c.Pct == nil || *c.Pct != 75 {
+		t.Errorf("after bulk update: %+v", c)
+	}
+
+	// The workbook's Statistics sheet is the cached one
+	archive, _ := dbgen.New(s.DB).GetArchiveByID(ctx, 1)
+	data, err := s.compareWorkbook(ctx, archive, compareExportOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if v, _ := f.GetCellValue("Statistics", "E2"); v != "50.0%" {
+		t.Errorf("plate accuracy %q, want 50.0%%", v)
+	}
+	if v, _ := f.GetCellValue("Statistics", "B8"); v == "" {
+		t.Error("computed time missing")
+	}
+}
+
+func TestCompareStatsReadOnly(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	for _, body := range []string{`{"plateUTF8":"AB1"}`, `{"plateUTF8":"AB2"}`} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('reviewed')")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+	s.DB.Exec("INSERT INTO compare_results (archive_id, event_id, field, is_incorrect) VALUES (1, 2, 'plate', 1)")
+	if err := s.SetReadOnly(filepath.Join(s.DataDir, "db.sqlite3")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.DB.Close() })
+
+	// Nothing is cached, and nothing can be: the stats are counted each time
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/archive/1/compare/stats", nil)
+	r.SetPathValue("id", "1")
+	s.HandleCompareStatsAPI(w, r)
+	var st compareStats
+	json.Unmarshal(w.Body.Bytes(), &st)
+	if p := st.Fields["plate"]; w.Code != http.StatusOK || st.Events != 2 || p.Correct != 1 || p.Incorrect != 1 {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	page := httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/archive/1/compare", nil)
+	r.SetPathValue("id", "1")
+	s.HandleCompare(page, r)
+	if page.Code != http.StatusOK || !strings.Contains(page.Body.String(), "50%") {
+		t.Errorf("compare page %d:\n%s", page.Code, page.Body)
+	}
+
+	archive, _ := dbgen.New(s.DB).GetArchiveByID(ctx, 1)
+	data, err := s.compareWorkbook(ctx, archive, compareExportOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if v, _ := f.GetCellValue("Statistics", "E2"); v != "50.0%" {
+		t.Errorf("plate accuracy %q, want 50.0%%", v)
+	}
+}
@@ -0,0 +1,219 @@
+package srv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Compatibility routes let cameras that were pointed at the legacy vendor
+// receiver be repointed to this server by changing only the host: each
+// route accepts one of the old URL paths, feeds the request into the
+// normal ingest pipeline, and answers with the old response contract.
+//
+//	{"routes": [{
+//	  "path": "/cgi-bin/lpr_upload.cgi",
+//	  "methods": ["POST", "PUT"],
+//	  "vendor": "hikvision",
+//	  "ack": {"content_type": "text/xml", "body": "<Result><Code>0</Code></Result>",
+//	          "on_error": {"status": 200, "content_type": "text/xml", "body": "<Result><Code>1</Code></Result>"}}
+//	}]}
+//
+// Routes are only mounted when configured, so they can ship dark and be
+// enabled per site during the cut-over. GET /api/compat shows which cameras
+// have already moved.
+
+// CompatRoute is one legacy receiver path
+type CompatRoute struct {
+	Path    string     `json:"path"`
+	Methods []string   `json:"methods"` // defaults to POST
+	Ack     *AckFormat `json:"ack"`     // legacy response; default JSON when nil
+	Vendor  string     `json:"vendor"`  // payload format; sniffed (or ?vendor=) when empty
+
+	mu       sync.Mutex
+	requests int
+	errors   int
+	lastAt   time.Time
+	cameras  map[string]time.Time // camera (or remote IP) → last request
+}
+
+// CompatConfig holds the legacy receiver routes
+type CompatConfig struct {
+	Routes []*CompatRoute `json:"routes"`
+}
+
+// LoadCompatConfig reads a compatibility route configuration file
+func LoadCompatConfig(path string) (*CompatConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg CompatConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, route := range cfg.Routes {
+		if !strings.HasPrefix(route.Path, "/") || strings.ContainsAny(route.Path, "{} ") {
+			return nil, fmt.Errorf("route %d: path must be a literal URL path starting with /", i)
+		}
+		if len(route.Methods) == 0 {
+			route.Methods = []string{http.MethodPost}
+		}
+		for j, m := range route.Methods {
+			route.Methods[j] = strings.ToUpper(m)
+		}
+		if route.Ack != nil {
+			if err := route.Ack.compile(); err != nil {
+				return nil, fmt.Errorf("route %s: ack: %w", route.Path, err)
+			}
+		}
+	}
+	return &cfg, nil
+}
+
+// registerCompatRoutes mounts the legacy routes; a path that collides with
+// one of our own routes is reported instead of panicking
+func (s *Server) registerCompatRoutes(mux *http.ServeMux) (err error) {
+	if s.Compat == nil {
+		return nil
+	}
+	for _, route := range s.Compat.Routes {
+		if route.Vendor != "" {
+			// Checked here, as vendors include the field mappings
+			if _, err := s.vendorParser(route.Vendor); err != nil {
+				return fmt.Errorf("compat route %s: %w", route.Path, err)
+			}
+		}
+		for _, m := range route.Methods {
+			pattern := m + " " + route.Path
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						err = fmt.Errorf("compat route %s: %v", pattern, r)
+					}
+				}()
+				s.ingestRoute(mux, pattern, s.compatHandler(route))
+			}()
+			if err != nil {
+				return err
+			}
+		}
+		slog.Info("legacy receiver route enabled", "path", route.Path, "methods", route.Methods)
+	}
+	return nil
+}
+
+// compatHandler ingests a request sent to a legacy path
+func (s *Server) compatHandler(route *CompatRoute) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, _, err := s.readUpload(r, coalesce(route.Vendor, r.URL.Query().Get("vendor")))
+		var res ingestResult
+		if errors.Is(err, errNotANPR) {
+			// Heartbeats get the legacy success response
+			slog.Debug("camera message ignored", "path", route.Path, "reason", err)
+			if f := route.ack(false); f != nil && f.write(w, res, nil) {
+				return
+			}
+			if !s.writeAck(w, r, res, nil) {
+				writeIgnored(w)
+			}
+			return
+		}
+		if err == nil {
+			res, err = s.ingest(r.Context(), req)
+		}
+		var pe *payloadError
+		if err != nil && !errors.As(err, &pe) {
+			slog.Error("failed to insert event", "path", route.Path, "error", err)
+		}
+
+		if err != nil && res.Camera == "" {
+			res.Camera = s.requestCamera(req)
+		}
+		camera := res.Camera
+		if camera == "" {
+			camera, _, _ = net.SplitHostPort(r.RemoteAddr)
+		}
+		route.record(camera, err)
+
+		// The route's contract first, then -ack-config
+		if f := route.ack(err != nil); f != nil && f.write(w, res, err) {
+			return
+		}
+		s.writeIngestAck(w, r, req, res, err)
+	}
+}
+
+// ack returns the legacy response for a succeeded or failed request
+func (route *CompatRoute) ack(failed bool) *AckFormat {
+	if route.Ack == nil || !failed {
+		return route.Ack
+	}
+	return route.Ack.OnError
+}
+
+func (route *CompatRoute) record(camera string, err error) {
+	route.mu.Lock()
+	defer route.mu.Unlock()
+	now := time.Now()
+	route.requests++
+	if err != nil {
+		route.errors++
+	}
+	route.lastAt = now
+	if route.cameras == nil {
+		route.cameras = make(map[string]time.Time)
+	}
+	if camera != "" {
+		route.cameras[camera] = now
+	}
+}
+
+// HandleCompatStatus reports traffic on the legacy receiver routes since start
+func (s *Server) HandleCompatStatus(w http.ResponseWriter, r *http.Request) {
+	type cameraSeen struct {
+		Camera   string    `json:"camera"`
+		LastSeen time.Time `json:"last_seen"`
+	}
+	type routeStatus struct {
+		Path     string       `json:"path"`
+		Methods  []string     `json:"methods"`
+		Requests int          `json:"requests"`
+		Errors   int          `json:"errors"`
+		LastAt   *time.Time   `json:"last_at"`
+		Cameras  []cameraSeen `json:"cameras"`
+	}
+	out := []routeStatus{}
+	if s.Compat != nil {
+		for _, route := range s.Compat.Routes {
+			route.mu.Lock()
+			st := routeStatus{
+				Path:     route.Path,
+				Methods:  route.Methods,
+				Requests: route.requests,
+				Errors:   route.errors,
+				Cameras:  []cameraSeen{},
+			}
+			if !route.lastAt.IsZero() {
+				t := route.lastAt
+				st.LastAt = &t
+			}
+			for c, t := range route.cameras {
+				st.Cameras = append(st.Cameras, cameraSeen{c, t})
+			}
+			route.mu.Unlock()
+			sort.Slice(st.Cameras, func(i, j int) bool { return st.Cameras[i].Camera < st.Cameras[j].Camera })
+			out = append(out, st)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,98 @@
+package srv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompatRoutes(t *testing.T) {
+	s := newTestServer(t)
+	load := func(config string) (*CompatConfig, error) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "compat.json")
+		os.WriteFile(path, []byte(config), 0o644)
+		return LoadCompatConfig(path)
+	}
+	cfg, err := load(`{"routes": [
+	  {"path": "/cgi-bin/lpr_upload.cgi", "methods": ["post", "PUT"],
+	   "ack": {"content_type": "text/xml", "body": "<Result><Code>0</Code><ID>{{.ID}}</ID></Result>",
+	           "on_error": {"status": 200, "content_type": "text/xml", "body": "<Result><Code>1</Code></Result>"}}},
+	  {"path": "/receiver/event"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Compat = cfg
+	mux := http.NewServeMux()
+	if err := s.registerCompatRoutes(mux); err != nil {
+		t.Fatal(err)
+	}
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	// The legacy path answers with the old contract and stores the event
+	if w := send("POST", "/cgi-bin/lpr_upload.cgi", `{"plateUTF8":"LG1","sensorProviderID":"cam1"}`); w.Code != http.StatusOK ||
+		w.Body.String() != "<Result><Code>0</Code><ID>1</ID></Result>" || w.Header().Get("Content-Type") != "text/xml" {
+		t.Errorf("post: %d %q %v", w.Code, w.Body, w.Header())
+	}
+	if w := send("PUT", "/cgi-bin/lpr_upload.cgi", `{"plateUTF8":"LG2","sensorProviderID":"cam2"}`); w.Body.String() != "<Result><Code>0</Code><ID>2</ID></Result>" {
+		t.Errorf("put: %q", w.Body)
+	}
+	if w := send("POST", "/cgi-bin/lpr_upload.cgi", `not json`); w.Code != http.StatusOK || w.Body.String() != "<Result><Code>1</Code></Result>" {
+		t.Errorf("failure: %d %q", w.Code, w.Body)
+	}
+	if w := send("GET", "/cgi-bin/lpr_upload.cgi", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("get: %d", w.Code)
+	}
+	// Without an ack the normal JSON response is sent
+	if w := send("POST", "/receiver/event", `{"plateUTF8":"LG3"}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"success":true`) {
+		t.Errorf("default ack: %d %s", w.Code, w.Body)
+	}
+	s.background.Wait()
+	var n int
+	s.DB.QueryRow("SELECT COUNT(*) FROM events WHERE plate_utf8 LIKE 'LG%'").Scan(&n)
+	if n != 3 {
+		t.Errorf("%d events stored, want 3", n)
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleCompatStatus(w, httptest.NewRequest("GET", "/api/compat", nil))
+	var status []struct {
+		Path     string `json:"path"`
+		Requests int    `json:"requests"`
+		Errors   int    `json:"errors"`
+		Cameras  []struct {
+			Camera string `json:"camera"`
+		} `json:"cameras"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &status)
+	if len(status) != 2 || status[0].Requests != 3 || status[0].Errors != 1 || len(status[0].Cameras) != 3 ||
+		status[0].Cameras[0].Camera != "192.0.2.1" || status[0].Cameras[1].Camera != "cam1" {
+		t.Errorf("status: %s", w.Body)
+	}
+
+	// Our own routes can't be shadowed
+	own := http.NewServeMux()
+	own.HandleFunc("POST /api", s.HandleAPI)
+	s.Compat, _ = load(`{"routes": [{"path": "/api"}]}`)
+	if err := s.registerCompatRoutes(own); err == nil {
+		t.Error("route collision accepted")
+	}
+	s.Compat, _ = load(`{"routes": [{"path": "/cgi-bin/x.cgi", "vendor": "nope"}]}`)
+	if err := s.registerCompatRoutes(http.NewServeMux()); err == nil {
+		t.Error("unknown vendor accepted")
+	}
+	if _, err := load(`{"routes": [{"path": "/events/{id}"}]}`); err == nil {
+		t.Error("wildcard path accepted")
+	}
+}
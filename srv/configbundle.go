@@ -0,0 +1,401 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// `srv config export` writes a site's configuration as one YAML bundle and
+// `srv config import` provisions a fresh box from it:
+//
+//	format: 1
+//	flags: {require-login: "true", camera-tz: Europe/Berlin}
+//	files:
+//	  snapshot-config: {cameras: {gate: {url: "rtsp://..."}}}
+//	users: [{username: admin, password_hash: "pbkdf2-sha256$..."}]
+//	api_keys: [{name: gate, key_hash: "...", key_prefix: mmr_1a2b}]
+//	test_vehicles: [{plate: AB123CD, expected_per_lap: 1}]
+//	watchlists: [{name: stolen, flag: true, enabled: true, entries: [{plate: AB1*, note: "..."}]}]
+//
+// flags are the server's command line settings except -db and -node-id,
+// which belong to the box. The JSON files of -*-config flags (cameras,
+// mappings, watchlists, rules, ...) are embedded as YAML, so the bundle can
+// be edited per site. Users and API keys keep their hashes: people log in
+// and cameras send with the same credentials as on the original box.
+
+const configBundleFormat = 1
+
+// Flags that identify a box rather than configure the site
+var configBundleSkipFlags = []string{"db", "node-id", "version"}
+
+type configBundle struct {
+	Format       int                 `yaml:"format"`
+	Version      string              `yaml:"version,omitempty"`
+	Node         string              `yaml:"node,omitempty"`
+	ExportedAt   time.Time           `yaml:"exported_at"`
+	Flags        map[string]string   `yaml:"flags,omitempty"`
+	Files        map[string]any      `yaml:"files,omitempty"` // config flag -> file contents
+	Users        []bundleUser        `yaml:"users,omitempty"`
+	APIKeys      []bundleAPIKey      `yaml:"api_keys,omitempty"`
+	TestVehicles []bundleTestVehicle `yaml:"test_vehicles,omitempty"`
+	Watchlists   []bundleWatchlist   `yaml:"watchlists,omitempty"`
+}
+
+type bundleUser struct {
+	Username     string    `yaml:"username"`
+	PasswordHash string    `yaml:"password_hash"`
+	CreatedAt    time.Time `yaml:"created_at"`
+}
+
+type bundleAPIKey struct {
+	Name      string    `yaml:"name"`
+	KeyHash   string    `yaml:"key_hash"`
+	KeyPrefix string    `yaml:"key_prefix"`
+	Enabled   bool      `yaml:"enabled"`
+	CreatedAt time.Time `yaml:"created_at"`
+}
+
+type bundleTestVehicle struct {
+	Plate          string  `yaml:"plate"`
+	Label          *string `yaml:"label,omitempty"`
+	ExpectedPerLap int64   `yaml:"expected_per_lap"`
+}
+
+type bundleWatchlist struct {
+	Name       string                 `yaml:"name"`
+	WebhookURL string                 `yaml:"webhook_url,omitempty"`
+	EmailTo    []string               `yaml:"email_to,omitempty"`
+	Flag       bool                   `yaml:"flag"`
+	Enabled    bool                   `yaml:"enabled"`
+	Entries    []bundleWatchlistEntry `yaml:"entries,omitempty"`
+}
+
+type bundleWatchlistEntry struct {
+	Plate     string     `yaml:"plate"`
+	Note      string     `yaml:"note,omitempty"`
+	ExpiresAt *time.Time `yaml:"expires_at,omitempty"`
+}
+
+// ConfigImport is what `srv config import` did
+type ConfigImport struct {
+	Args         []string // server command line (shell-quoted): the bundle's flags, config flags pointing at the written files
+	Files        []string
+	Users        int // added; users and keys already there are kept
+	APIKeys      int
+	TestVehicles int // added or updated
+	Watchlists   int // added; the entries of existing ones are added or updated
+}
+
+// ExportConfig writes the configuration bundle of a server started with
+// flags (name -> value of the flags set on its command line)
+func (s *Server) ExportConfig(ctx context.Context, w io.Writer, flags map[string]string) error {
+	b := configBundle{
+		Format:     configBundleFormat,
+		Version:    Version,
+		Node:       s.NodeID,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Flags:      map[string]string{},
+		Files:      map[string]any{},
+	}
+	for name, value := range flags {
+		switch {
+		case slices.Contains(configBundleSkipFlags, name):
+		case strings.HasSuffix(name, "-config") && value != "":
+			data, err := os.ReadFile(value)
+			if err != nil {
+				return fmt.Errorf("-%s: %w", name, err)
+			}
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.UseNumber()
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				return fmt.Errorf("parse %s: %w", value, err)
+			}
+			b.Files[name] = yamlNumbers(v)
+		default:
+			b.Flags[name] = value
+		}
+	}
+
+	q := dbgen.New(s.DB)
+	users, err := q.GetUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("users: %w", err)
+	}
+	for _, u := range users {
+		b.Users = append(b.Users, bundleUser{Username: u.Username, PasswordHash: u.PasswordHash, CreatedAt: u.CreatedAt.UTC()})
+	}
+	keys, err := q.GetAPIKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("API keys: %w", err)
+	}
+	for _, k := range keys {
+		b.APIKeys = append(b.APIKeys, bundleAPIKey{
+			Name:      k.Name,
+			KeyHash:   k.KeyHash,
+			KeyPrefix: k.KeyPrefix,
+			Enabled:   k.Enabled,
+			CreatedAt: k.CreatedAt.UTC(),
+		})
+	}
+	vehicles, err := q.GetTestVehicles(ctx)
+	if err != nil {
+		return fmt.Errorf("test vehicles: %w", err)
+	}
+	for _, v := range vehicles {
+		b.TestVehicles = append(b.TestVehicles, bundleTestVehicle{Plate: v.Plate, Label: v.Label, ExpectedPerLap: v.ExpectedPerLap})
+	}
+	lists, err := q.GetWatchlists(ctx)
+	if err != nil {
+		return fmt.Errorf("watchlists: %w", err)
+	}
+	for _, l := range lists {
+		entries, err := q.GetWatchlistEntries(ctx, l.ID)
+		if err != nil {
+			return fmt.Errorf("watchlist %s: %w", l.Name, err)
+		}
+		bl := bundleWatchlist{
+			Name:       l.Name,
+			WebhookURL: deref(l.WebhookUrl),
+			EmailTo:    splitAddresses(deref(l.EmailTo)),
+			Flag:       l.Flag,
+			Enabled:    l.Enabled,
+		}
+		for _, e := range entries {
+			bl.Entries = append(bl.Entries, bundleWatchlistEntry{Plate: e.Plate, Note: deref(e.Note), ExpiresAt: e.ExpiresAt})
+		}
+		b.Watchlists = append(b.Watchlists, bl)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(b); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// yamlNumbers turns the json.Numbers of a decoded JSON file into ints and
+// floats, so the YAML has plain numbers that read back as the same JSON
+func yamlNumbers(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = yamlNumbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = yamlNumbers(e)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// ImportConfig reads a configuration bundle, adds its users, API keys, test
+// vehicles and watchlists to the database and writes its config files to
+// dir
+func (s *Server) ImportConfig(ctx context.Context, r io.Reader, dir string) (*ConfigImport, error) {
+	var b configBundle
+	if err := yaml.NewDecoder(r).Decode(&b); err != nil {
+		return nil, fmt.Errorf("parse bundle: %w", err)
+	}
+	if b.Format != configBundleFormat {
+		return nil, fmt.Errorf("unsupported bundle format %d", b.Format)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	res := &ConfigImport{}
+	args := map[string]string{}
+	for name, value := range b.Flags {
+		if strings.HasSuffix(name, "-config") || slices.Contains(configBundleSkipFlags, name) {
+			return nil, fmt.Errorf("flag %s doesn't belong in a bundle", name)
+		}
+		args[name] = value
+	}
+	files := map[string][]byte{}
+	for name, v := range b.Files {
+		if !strings.HasSuffix(name, "-config") || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("file %s: not a config flag", name)
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %w", name, err)
+		}
+		path := filepath.Join(dir, strings.TrimSuffix(name, "-config")+".json")
+		files[path] = append(data, '\n')
+		args[name] = path
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	for _, u := range b.Users {
+		if u.Username == "" || u.PasswordHash == "" {
+			return nil, fmt.Errorf("user %q: username and password_hash are required", u.Username)
+		}
+		if _, err := q.GetUserByName(ctx, u.Username); err == nil {
+			continue
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if _, err := q.CreateUser(ctx, dbgen.CreateUserParams{
+			Username:     u.Username,
+			PasswordHash: u.PasswordHash,
+			CreatedAt:    coalesceTime(u.CreatedAt),
+		}); err != nil {
+			return nil, fmt.Errorf("user %s: %w", u.Username, err)
+		}
+		res.Users++
+	}
+	for _, k := range b.APIKeys {
+		if k.Name == "" || k.KeyHash == "" {
+			return nil, fmt.Errorf("API key %q: name and key_hash are required", k.Name)
+		}
+		if _, err := q.GetAPIKeyByHash(ctx, k.KeyHash); err == nil {
+			continue
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		key, err := q.CreateAPIKey(ctx, dbgen.CreateAPIKeyParams{
+			Name:      k.Name,
+			KeyHash:   k.KeyHash,
+			KeyPrefix: k.KeyPrefix,
+			CreatedAt: coalesceTime(k.CreatedAt),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("API key %s: %w", k.Name, err)
+		}
+		if !k.Enabled {
+			if _, err := q.SetAPIKeyEnabled(ctx, dbgen.SetAPIKeyEnabledParams{Enabled: false, ID: key.ID}); err != nil {
+				return nil, err
+			}
+		}
+		res.APIKeys++
+	}
+	for _, v := range b.TestVehicles {
+		plate := normalizePlate(v.Plate)
+		if plate == "" {
+			return nil, fmt.Errorf("test vehicle without a plate")
+		}
+		if v.ExpectedPerLap < 1 {
+			v.ExpectedPerLap = 1
+		}
+		if err := q.CreateTestVehicle(ctx, dbgen.CreateTestVehicleParams{
+			Plate:          plate,
+			Label:          v.Label,
+			ExpectedPerLap: v.ExpectedPerLap,
+			CreatedAt:      time.Now(),
+		}); err != nil {
+			return nil, fmt.Errorf("test vehicle %s: %w", plate, err)
+		}
+		res.TestVehicles++
+	}
+	existing, err := q.GetWatchlists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range b.Watchlists {
+		var id int64
+		for _, e := range existing {
+			if e.Name == l.Name {
+				id = e.ID
+			}
+		}
+		if id == 0 {
+			p := dbgen.UpdateWatchlistParams{Flag: l.Flag, Enabled: l.Enabled}
+			if err := (watchlistChange{Name: &l.Name, WebhookURL: &l.WebhookURL, EmailTo: &l.EmailTo}).apply(&p); err != nil {
+				return nil, fmt.Errorf("watchlist %q: %w", l.Name, err)
+			}
+			w, err := q.CreateWatchlist(ctx, dbgen.CreateWatchlistParams{
+				Name:       p.Name,
+				WebhookUrl: p.WebhookUrl,
+				EmailTo:    p.EmailTo,
+				Flag:       p.Flag,
+				Enabled:    p.Enabled,
+				CreatedAt:  time.Now(),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("watchlist %s: %w", l.Name, err)
+			}
+			id = w.ID
+			res.Watchlists++
+		}
+		for _, e := range l.Entries {
+			pattern, err := watchlistPattern(e.Plate)
+			if err != nil {
+				return nil, fmt.Errorf("watchlist %s: %w", l.Name, err)
+			}
+			if _, err := q.UpsertWatchlistEntry(ctx, dbgen.UpsertWatchlistEntryParams{
+				WatchlistID: id,
+				Plate:       pattern,
+				Note:        ptrIfNotEmpty(e.Note),
+				ExpiresAt:   e.ExpiresAt,
+				CreatedBy:   ptr("config import"),
+				CreatedAt:   time.Now(),
+			}); err != nil {
+				return nil, fmt.Errorf("watchlist %s: %w", l.Name, err)
+			}
+		}
+	}
+
+	// Files are written before the commit so a failed write leaves the database untouched
+	if len(files) > 0 {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	for path, data := range files {
+		// Config files may hold credentials (SMTP, MQTT, SFTP)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, err
+		}
+		res.Files = append(res.Files, path)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.watchlists.invalidate()
+	slices.Sort(res.Files)
+	for _, name := range slices.Sorted(maps.Keys(args)) {
+		arg := "-" + name + "=" + args[name]
+		if strings.ContainsAny(arg, " \t\n'\"$`\\*?;&|<>()") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		res.Args = append(res.Args, arg)
+	}
+	return res, nil
+}
+
+// coalesceTime is t, or now for a bundle entry without a time
+func coalesceTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
@@ -0,0 +1,141 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestConfigBundle(t *testing.T) {
+	open := func() *Server {
+		t.Helper()
+		s := newTestServer(t)
+		s.NodeID = "site-a"
+		return s
+	}
+	ctx := context.Background()
+	src := open()
+	if err := src.AddUser(ctx, "admin", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	q := dbgen.New(src.DB)
+	for i, name := range []string{"gate", "old"} {
+		key, err := q.CreateAPIKey(ctx, dbgen.CreateAPIKeyParams{Name: name, KeyHash: name + "-hash", KeyPrefix: "mmr_" + name, CreatedAt: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			q.SetAPIKeyEnabled(ctx, dbgen.SetAPIKeyEnabledParams{Enabled: false, ID: key.ID})
+		}
+	}
+	label := "pool car"
+	q.CreateTestVehicle(ctx, dbgen.CreateTestVehicleParams{Plate: "AB123CD", Label: &label, ExpectedPerLap: 2, CreatedAt: time.Now()})
+	list, err := q.CreateWatchlist(ctx, dbgen.CreateWatchlistParams{Name: "stolen", EmailTo: ptr("a@example.com, b@example.com"), Flag: true, Enabled: true, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.UpsertWatchlistEntry(ctx, dbgen.UpsertWatchlistEntryParams{WatchlistID: list.ID, Plate: "XY9*", Note: ptr("reported 3 May"), CreatedAt: time.Now()})
+
+	cfgDir := t.TempDir()
+	calendar := filepath.Join(cfgDir, "calendar.json")
+	os.WriteFile(calendar, []byte(`{"hours": {"mon-fri": ["07:00-19:00"]}, "holidays": {"12-25": "Christmas Day"}}`), 0o644)
+	quota := filepath.Join(cfgDir, "quota.json")
+	os.WriteFile(quota, []byte(`{"total_bytes": 50000000000, "cameras": {"gate": {"bytes": 2000000}}, "ratio": 0.75}`), 0o644)
+
+	var bundle bytes.Buffer
+	err = src.ExportConfig(ctx, &bundle, map[string]string{
+		"db":              "/var/lib/mmrapi/db.sqlite3",
+		"node-id":         "site-a",
+		"require-login":   "true",
+		"camera-tz":       "Europe/Berlin",
+		"json-fields":     "json, event",
+		"calendar-config": calendar,
+		"quota-config":    quota,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bundle.String()
+	for _, want := range []string{"format: 1", "node: site-a", "require-login: \"true\"", "mon-fri:", "total_bytes: 50000000000",
+		"username: admin", "key_hash: gate-hash", "plate: AB123CD", "name: stolen", "plate: XY9*"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("bundle lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "/var/lib/mmrapi") || strings.Contains(out, "node-id") || strings.Contains(out, "correct horse") {
+		t.Errorf("bundle has box settings or a password:\n%s", out)
+	}
+
+	dst := open()
+	if err := dst.AddUser(ctx, "admin", "another password"); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "etc")
+	res, err := dst.ImportConfig(ctx, strings.NewReader(out), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Users != 0 || res.APIKeys != 2 || res.TestVehicles != 1 || res.Watchlists != 1 || len(res.Files) != 2 {
+		t.Errorf("import %+v", res)
+	}
+	wantArgs := []string{
+		"-calendar-config=" + filepath.Join(dir, "calendar.json"),
+		"-camera-tz=Europe/Berlin",
+		"'-json-fields=json, event'",
+		"-quota-config=" + filepath.Join(dir, "quota.json"),
+		"-require-login=true",
+	}
+	if !slices.Equal(res.Args, wantArgs) {
+		t.Errorf("args %q", res.Args)
+	}
+	if cal, err := LoadSiteCalendar(filepath.Join(dir, "calendar.json")); err != nil || cal.Holidays["12-25"] != "Christmas Day" {
+		t.Errorf("calendar %+v: %v", cal, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "quota.json")); !strings.Contains(string(data), `"total_bytes": 50000000000`) ||
+		!strings.Contains(string(data), `"ratio": 0.75`) {
+		t.Errorf("quota.json %s", data)
+	}
+
+	// The existing admin keeps their password; the keys work as before
+	dq := dbgen.New(dst.DB)
+	if u, _ := dq.GetUserByName(ctx, "admin"); !checkPassword(u.PasswordHash, "another password") {
+		t.Error("admin's password was replaced")
+	}
+	keys, _ := dq.GetAPIKeys(ctx)
+	if len(keys) != 2 {
+		t.Fatalf("keys %+v", keys)
+	}
+	for _, k := range keys {
+		if k.Enabled != (k.Name == "gate") || k.KeyHash != k.Name+"-hash" {
+			t.Errorf("key %+v", k)
+		}
+	}
+	if vehicles, _ := dq.GetTestVehicles(ctx); len(vehicles) != 1 || vehicles[0].ExpectedPerLap != 2 || *vehicles[0].Label != "pool car" {
+		t.Errorf("vehicles %+v", vehicles)
+	}
+	if lists, _ := dq.GetWatchlists(ctx); len(lists) != 1 || lists[0].Entries != 1 || *lists[0].EmailTo != "a@example.com,b@example.com" || !lists[0].Flag {
+		t.Errorf("watchlists %+v", lists)
+	}
+	// Importing again adds nothing
+	if res, err := dst.ImportConfig(ctx, strings.NewReader(out), dir); err != nil || res.APIKeys != 0 || res.Watchlists != 0 {
+		t.Errorf("second import %+v: %v", res, err)
+	}
+
+	for _, bad := range []string{
+		"format: 2\n",
+		"format: 1\nflags: {db: other.sqlite3}\n",
+		"format: 1\nfiles: {../x: {}}\n",
+		"format: 1\nusers: [{username: bob}]\n",
+	} {
+		if _, err := dst.ImportConfig(ctx, strings.NewReader(bad), dir); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
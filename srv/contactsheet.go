@@ -0,0 +1,142 @@
+package srv
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// A contact sheet is a printable grid of the vehicle images of an archive
+// with plate and verdict underneath, for reviewers to mark up on paper.
+// It is plain HTML with print CSS; use the browser's "Save as PDF" for a
+// PDF. ?download=1 embeds the thumbnails so the file works offline.
+
+const contactSheetThumbWidth = 320
+
+type contactCard struct {
+	ID       int64
+	Time     string
+	Plate    string
+	Manual   bool // plate entered by a reviewer
+	Vehicle  string
+	ImageSrc template.URL
+	Verdict  string // correct, incorrect, missed or pending
+	Wrong    []string
+}
+
+// HandleContactSheet renders the contact sheet of an archive
+func (s *Server) HandleContactSheet(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+
+	q := dbgen.New(s.DB)
+	archive, err := q.GetArchiveByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	events, _ := q.GetArchivedEvents(r.Context(), &id)
+	results, _ := q.GetCompareResults(r.Context(), id)
+	wrong := make(map[int64][]string)
+	incorrect := make(map[int64]map[string]bool)
+	for _, res := range results {
+		if res.IsIncorrect {
+			wrong[res.EventID] = append(wrong[res.EventID], res.Field)
+			if incorrect[res.EventID] == nil {
+				incorrect[res.EventID] = make(map[string]bool)
+			}
+			incorrect[res.EventID][res.Field] = true
+		}
+	}
+	download := r.URL.Query().Get("download") != ""
+
+	cards := make([]contactCard, 0, len(events))
+	counts := map[string]int{}
+	for _, e := range events {
+		c := contactCard{
+			ID:    e.ID,
+			Time:  seenAt(e.CapturedAt, e.CreatedAt).Format("2006-01-02 15:04:05"),
+			Wrong: wrong[e.ID],
+		}
+		switch {
+		case e.PlateUtf8 != nil && *e.PlateUtf8 != "":
+			c.Plate = *e.PlateUtf8
+		case e.ManualPlate != nil:
+			c.Plate, c.Manual = *e.ManualPlate, true
+		}
+		var vehicle []string
+		for _, v := range []*string{e.VehicleMake, e.VehicleModel, e.VehicleColor} {
+			if v != nil && *v != "" {
+				vehicle = append(vehicle, *v)
+			}
+		}
+		c.Vehicle = strings.Join(vehicle, " · ")
+
+		c.Verdict = eventVerdict(grafanaEvent{
+			NoRead:    e.Unrecognized,
+			Reviewed:  true,
+			Incorrect: incorrect[e.ID],
+			Manual:    e.Source == "manual",
+			Corrected: e.ManualPlate != nil,
+		})
+		counts[c.Verdict]++
+
+		imageID := toInt64(e.VehicleImageID)
+		if imageID == 0 {
+			imageID = toInt64(e.PlateImageID)
+		}
+		if imageID > 0 {
+			c.ImageSrc = template.URL(fmt.Sprintf("/image/%d/thumb?w=%d", imageID, contactSheetThumbWidth))
+			if download {
+				if data, contentType, err := s.thumbnail(r.Context(), imageID, contactSheetThumbWidth); err == nil {
+					c.ImageSrc = template.URL("data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data))
+				}
+			}
+		}
+		cards = append(cards, c)
+	}
+
+	archiveName := fmt.Sprintf("Archive %d", id)
+	if archive.Name != nil && *archive.Name != "" {
+		archiveName = *archive.Name
+	}
+	data := struct {
+		Hostname string
+		Archive  dbgen.Archive
+		Name     string
+		Cards    []contactCard
+		Counts   map[string]int
+		Download bool
+	}{
+		Hostname: s.Hostname,
+		Archive:  archive,
+		Name:     archiveName,
+		Cards:    cards,
+		Counts:   counts,
+		Download: download,
+	}
+
+	if download {
+		filename := fmt.Sprintf("contact_sheet_%s.html", sanitizeFilename(archiveName))
+		if err := s.renderExport(w, r, filename, "contact_sheet.html", data); err != nil {
+			slog.Warn("render template", "error", err)
+			http.Error(w, "template error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "contact_sheet.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
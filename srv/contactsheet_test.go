@@ -0,0 +1,69 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContactSheet(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	for _, body := range []string{`{"plateUTF8":"AB1"}`, `{"plateUTF8":"AB2"}`, `{"carID":"3"}`, `{"carID":"4"}`} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", []uploadedImage{{Filename: "vehicle.png", Data: pngOf(300, 200)}})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := httptest.NewRecorder()
+	s.HandleCreateEventAPI(w, httptest.NewRequest("POST", "/api/events", strings.NewReader(`{"plate":"MAN5"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("manual event: %d %s", w.Code, w.Body)
+	}
+	s.background.Wait()
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('Trial 1')")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+	s.DB.Exec("UPDATE events SET manual_plate = 'CD4' WHERE id = 4")
+	s.DB.Exec("INSERT INTO compare_results (archive_id, event_id, field, is_incorrect) VALUES (1, 2, 'maker', 1)")
+
+	render := func(target string) string {
+		t.Helper()
+		r := httptest.NewRequest("GET", target, nil)
+		r.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		s.HandleContactSheet(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", target, w.Code, w.Body)
+		}
+		return w.Body.String()
+	}
+
+	// Verdicts follow the compare statistics: the entered plate of an
+	// unrecognized read and the manual event are missed reads
+	body := render("/archive/1/contact-sheet")
+	if !strings.Contains(body, "✓ 1 correct · ✗ 1 incorrect · 2 missed · 1 pending") {
+		t.Errorf("counts missing: %s", body)
+	}
+	if !strings.Contains(body, "✗ maker") || !strings.Contains(body, "/thumb?w=320") {
+		t.Errorf("cards: %s", body)
+	}
+	stats, err := s.compareStats(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := stats.Fields["plate"]; p.Correct != 2 || p.Incorrect != 2 {
+		t.Errorf("plate stats %+v: correct and missed reads should match the sheet", p)
+	}
+
+	if body := render("/archive/1/contact-sheet?download=1"); !strings.Contains(body, "data:image/") {
+		t.Error("download doesn't embed the thumbnails")
+	}
+	r := httptest.NewRequest("GET", "/archive/9/contact-sheet", nil)
+	r.SetPathValue("id", "9")
+	w = httptest.NewRecorder()
+	s.HandleContactSheet(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown archive: %d", w.Code)
+	}
+}
@@ -0,0 +1,138 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Cameras send several messages per car: carState "new" when it appears,
+// "update" as the read improves and "lost" when it leaves. A message that
+// isn't "new" is merged into the open event of the same carID and camera in
+// the current session, if one was received or updated within MergeWindow:
+// fields it carries replace the event's, its images are appended, and it is
+// recorded in event_messages. Otherwise it is stored as an event of its own.
+// Messages without a camera serial or sensor provider ID are matched on the
+// camera IP instead, and never merged without one: carIDs are per-camera
+// counters, so unrelated cars share them.
+
+// DefaultMergeWindow is how long an event stays open for continuations
+const DefaultMergeWindow = 10 * time.Minute
+
+// isContinuation reports whether a carState continues an earlier message
+func isContinuation(carState string) bool {
+	return carState != "" && !strings.EqualFold(carState, "new")
+}
+
+// findOpenEvent returns the event a continuation message belongs to
+func (s *Server) findOpenEvent(ctx context.Context, q *dbgen.Queries, carID, camera string, cameraIP *string, now time.Time) (dbgen.FindOpenCarEventRow, bool) {
+	if s.MergeWindow <= 0 || (camera == "" && cameraIP == nil) {
+		return dbgen.FindOpenCarEventRow{}, false
+	}
+	open, err := q.FindOpenCarEvent(ctx, dbgen.FindOpenCarEventParams{
+		CarID:    carID,
+		Camera:   &camera,
+		Since:    ptr(now.Add(-s.MergeWindow)),
+		CameraIp: cameraIP,
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Warn("look up open event", "car_id", carID, "camera", camera, "error", err)
+		}
+		return dbgen.FindOpenCarEventRow{}, false
+	}
+	return open, true
+}
+
+// mergeMessage applies a continuation message to its open event. The
+// update, its images and the message are stored together, like a new
+// event's.
+func (s *Server) mergeMessage(ctx context.Context, q *dbgen.Queries, open dbgen.FindOpenCarEventRow, msg parsedEvent, req ingestRequest) (ingestResult, error) {
+	p, camera := msg.Params, msg.Camera
+	now := req.ReceivedAt
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ingestResult{}, err
+	}
+	defer tx.Rollback()
+	q = q.WithTx(tx)
+	if err := q.MergeEventMessage(ctx, dbgen.MergeEventMessageParams{
+		CarState:        p.CarState,
+		PlateUtf8:       p.PlateUtf8,
+		PlateCountry:    p.PlateCountry,
+		PlateRegion:     p.PlateRegion,
+		PlateRegionCode: p.PlateRegionCode,
+		PlateConfidence: p.PlateConfidence,
+		GeotagLat:       p.GeotagLat,
+		GeotagLon:       p.GeotagLon,
+		VehicleMake:     p.VehicleMake,
+		VehicleModel:    p.VehicleModel,
+		VehicleColor:    p.VehicleColor,
+		VehicleType:     p.VehicleType,
+		ConfidenceMmr:   p.ConfidenceMmr,
+		ConfidenceColor: p.ConfidenceColor,
+		CameraIp:        p.CameraIp,
+		Lane:            p.Lane,
+		Direction:       p.Direction,
+		UpdatedAt:       &now,
+		ID:              open.ID,
+	}); err != nil {
+		return ingestResult{}, err
+	}
+
+	ev, err := q.GetEventByID(ctx, open.ID)
+	if err != nil {
+		return ingestResult{}, err
+	}
+	plate := deref(ev.PlateUtf8)
+	imageCount, rejected, err := s.storeEventImages(ctx, q, open.ID, plate, camera, req.Images, msg.Event.ImageArray, now)
+	if err != nil {
+		return ingestResult{}, err
+	}
+	s.recordMessage(ctx, q, open.ID, req, msg, p.RawJson, imageCount)
+	s.ingestVIN(ctx, q, open.ID, msg.Event)
+	if err := tx.Commit(); err != nil {
+		s.resetQuotaUsage() // its images were counted
+		return ingestResult{}, err
+	}
+	if imageCount > 0 {
+		s.queueMeasure()
+	}
+
+	slog.Info("event message merged", "id", open.ID, "car_id", p.CarID, "car_state", deref(p.CarState),
+		"plate", plate, "images", imageCount, "messages", ev.MessageCount)
+	return ingestResult{
+		ID:           open.ID,
+		UID:          deref(open.Uid),
+		Plate:        plate,
+		Images:       imageCount,
+		Unrecognized: ev.Unrecognized,
+		Camera:       camera,
+		Rejected:     rejected,
+		Merged:       true,
+	}, nil
+}
+
+// recordMessage adds a camera message to the event's history. rawJSON is
+// nil for the first message, whose JSON is the event's own.
+func (s *Server) recordMessage(ctx context.Context, q *dbgen.Queries, eventID int64, req ingestRequest, msg parsedEvent, rawJSON *string, images int) {
+	p := msg.Params
+	if err := q.InsertEventMessage(ctx, dbgen.InsertEventMessageParams{
+		EventID:         eventID,
+		Uid:             req.UID,
+		CarState:        p.CarState,
+		PlateUtf8:       p.PlateUtf8,
+		PlateConfidence: p.PlateConfidence,
+		RawJson:         rawJSON,
+		Images:          int64(images),
+		ReceivedAt:      req.ReceivedAt,
+		DedupKey:        msg.DedupKey,
+	}); err != nil {
+		slog.Warn("record event message", "id", eventID, "uid", req.UID, "error", err)
+	}
+}
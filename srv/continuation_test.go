@@ -0,0 +1,71 @@
+package srv
+
+import (
+	"context"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestMergeContinuations(t *testing.T) {
+	s := newTestServer(t)
+	s.MergeWindow = DefaultMergeWindow
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+
+	ingest := func(body string) ingestResult {
+		t.Helper()
+		res, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	first := ingest(`{"carID":"7","carState":"new","sensorProviderID":"cam1","plateUTF8":"AB12"}`)
+	update := ingest(`{"carID":"7","carState":"update","sensorProviderID":"cam1","plateUTF8":"AB123"}`)
+	lost := ingest(`{"carID":"7","carState":"lost","sensorProviderID":"cam1"}`)
+	if first.Merged || !update.Merged || !lost.Merged {
+		t.Errorf("merged = %v, %v, %v; want false, true, true", first.Merged, update.Merged, lost.Merged)
+	}
+	if update.ID != first.ID || lost.ID != first.ID || lost.UID != first.UID {
+		t.Errorf("continuations stored as events %d, %d; want %d", update.ID, lost.ID, first.ID)
+	}
+
+	ev, err := q.GetEventByID(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deref(ev.PlateUtf8) != "AB123" || deref(ev.CarState) != "lost" || ev.MessageCount != 3 || ev.UpdatedAt == nil {
+		t.Errorf("event = plate %q, state %q, %d messages; want AB123, lost, 3", deref(ev.PlateUtf8), deref(ev.CarState), ev.MessageCount)
+	}
+	messages, _ := q.GetEventMessages(ctx, first.ID)
+	if len(messages) != 3 || messages[0].RawJson != nil || messages[1].RawJson == nil {
+		t.Errorf("messages = %d; want 3 with the continuations' JSON kept", len(messages))
+	}
+
+	// Another camera's car 7 and a fresh "new" are events of their own
+	if res := ingest(`{"carID":"7","carState":"update","sensorProviderID":"cam2"}`); res.Merged {
+		t.Error("update from another camera merged")
+	}
+	if res := ingest(`{"carID":"7","carState":"new","sensorProviderID":"cam1"}`); res.Merged || res.ID == first.ID {
+		t.Error("new message merged into the earlier event")
+	}
+	if n, _ := q.CountEvents(ctx); n != 3 {
+		t.Errorf("events = %d, want 3", n)
+	}
+
+	// Without a serial or provider ID the camera IP tells cameras apart,
+	// and without that nothing is merged
+	byIP := ingest(`{"carID":"9","carState":"new","camera_info":{"IPAddress":"10.0.0.5"}}`)
+	if res := ingest(`{"carID":"9","carState":"update","camera_info":{"IPAddress":"10.0.0.6"}}`); res.Merged {
+		t.Error("update from another camera IP merged")
+	}
+	if res := ingest(`{"carID":"9","carState":"lost","camera_info":{"IPAddress":"10.0.0.5"}}`); !res.Merged || res.ID != byIP.ID {
+		t.Errorf("update from the same camera IP stored as event %d, want %d", res.ID, byIP.ID)
+	}
+	ingest(`{"carID":"11","carState":"new"}`)
+	if res := ingest(`{"carID":"11","carState":"update"}`); res.Merged {
+		t.Error("update without any camera merged")
+	}
+}
@@ -0,0 +1,189 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"srv.exe.dev/db/dbgen"
+)
+
+// Flat CSV exports are for scripts rather than Excel: one row per event,
+// no images, RFC 3339 timestamps and empty cells for missing values.
+//
+//	GET /archive/{id}/export.csv       an archive's events
+//	GET /api/events/export.csv         the current session, or ?archive=ID
+//
+// Both take the /api/events filters (plate, camera, country, state, from,
+// to) and are signed like the other exports when a signing key is set.
+// The event_url column links to the event page under PublicURL (or the
+// request's host), like the compare workbook.
+
+// csvHeader names the columns of a CSV export
+var csvHeader = []string{"event_id", "timestamp", "car_id", "plate", "country", "make", "model", "color",
+	"plate_confidence", "mmr_confidence", "color_confidence", "camera", "lat", "lon", "event_url"}
+
+// csvRow is the CSV record of an event, linking to it under base (left
+// empty without one)
+func csvRow(e dbgen.GetCSVEventsRow, base string) []string {
+	camera := deref(e.CameraSerial)
+	if camera == "" {
+		camera = deref(e.SensorProviderID)
+	}
+	var link string
+	if base != "" {
+		link = eventLink(base, e.ID)
+	}
+	return []string{
+		strconv.FormatInt(e.ID, 10),
+		seenAt(e.CapturedAt, e.CreatedAt).Format(time.RFC3339),
+		e.CarID,
+		coalesce(deref(e.PlateUtf8), deref(e.ManualPlate)),
+		deref(e.PlateCountry),
+		deref(e.VehicleMake),
+		deref(e.VehicleModel),
+		deref(e.VehicleColor),
+		csvFloat(e.PlateConfidence),
+		deref(e.ConfidenceMmr),
+		deref(e.ConfidenceColor),
+		camera,
+		csvFloat(e.GeotagLat),
+		csvFloat(e.GeotagLon),
+		link,
+	}
+}
+
+func csvFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+// HandleArchiveCSV exports an archive's events as CSV
+func (s *Server) HandleArchiveCSV(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	archive, err := dbgen.New(s.DB).GetArchiveByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	name := fmt.Sprintf("archive_%d", id)
+	if archive.Name != nil {
+		name = sanitizeFilename(*archive.Name)
+	}
+	s.writeEventsCSV(w, r, &id, name+".csv")
+}
+
+// HandleEventsCSV exports the current session's events, or an archive's
+// with ?archive=, as CSV
+func (s *Server) HandleEventsCSV(w http.ResponseWriter, r *http.Request) {
+	var archiveID *int64
+	if a := r.URL.Query().Get("archive"); a != "" && a != "0" {
+		id, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			s.jsonError(w, "invalid archive id", http.StatusBadRequest)
+			return
+		}
+		archiveID = &id
+	}
+	s.writeEventsCSV(w, r, archiveID, fmt.Sprintf("events_%s.csv", time.Now().Format("20060102_150405")))
+}
+
+// writeEventsCSV sends the events matching the request's filters
+func (s *Server) writeEventsCSV(w http.ResponseWriter, r *http.Request, archiveID *int64, filename string) {
+	f, err := s.parseEventQuery(r.URL.Query())
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	events, err := dbgen.New(s.DB).GetCSVEvents(r.Context(), dbgen.GetCSVEventsParams{
+		ArchiveID:    archiveID,
+		Plate:        f.Plate,
+		Camera:       f.Camera,
+		Country:      f.Country,
+		CarState:     f.CarState,
+		CapturedFrom: f.From,
+		CapturedTo:   f.To,
+	})
+	if err != nil {
+		slog.Error("failed to export events as CSV", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := eventsCSV(events, s.linkBaseURL(r))
+	if err != nil {
+		s.jsonError(w, "failed to encode export", http.StatusInternalServerError)
+		return
+	}
+	s.writeExport(w, r, filename, "text/csv; charset=utf-8", data)
+}
+
+// eventsCSV encodes events with the CSV header
+func eventsCSV(events []dbgen.GetCSVEventsRow, base string) ([]byte, error) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write(csvHeader)
+	for _, e := range events {
+		cw.Write(csvRow(e, base))
+	}
+	cw.Flush()
+	return buf.Bytes(), cw.Error()
+}
+
+// eventsWorkbook writes events as an XLSX sheet with the CSV columns
+func eventsWorkbook(events []dbgen.GetCSVEventsRow, base string) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := "Events"
+	f.SetSheetName("Sheet1", sheet)
+	headerStyle, _ := f.NewStyle(&excelize.Style{
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"F8F9FA"}, Pattern: 1},
+		Font: &excelize.Font{Bold: true},
+	})
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]any, len(csvHeader))
+	for i, h := range csvHeader {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: h}
+	}
+	if err := sw.SetRow("A1", header); err != nil {
+		return nil, err
+	}
+	for i, e := range events {
+		values := csvRow(e, base)
+		row := make([]any, len(values))
+		for j, v := range values {
+			row[j] = v
+		}
+		row[0] = e.ID // numeric, so it sorts
+		if link := values[len(values)-1]; link != "" {
+			row[len(row)-1] = excelize.Cell{Value: link, Formula: `HYPERLINK("` + strings.ReplaceAll(link, `"`, `""`) + `")`}
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := sw.SetRow(cell, row); err != nil {
+			return nil, err
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
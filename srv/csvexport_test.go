@@ -0,0 +1,71 @@
+package srv
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventsCSV(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	for _, body := range []string{
+		`{"carID":"7","plateUTF8":"CS1","plateCountry":"D","plateConfidence":"0.91","capture_timestamp":"2026-05-03T14:11:04Z",
+		  "vehicle_info":{"make":"Audi","model":"A4","color":"black","confidenceMMR":"0.8","confidenceColor":"0.7"},
+		  "camera_info":{"SerialNumber":"CAM-1"},"geotag":{"lat":52.52,"lon":13.405}}`,
+		`{"carID":"8","plateUTF8":"CS2, \"B\"","sensorProviderID":"gate"}`,
+	} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('Trial 1')")
+	s.DB.Exec("UPDATE events SET archive_id = 1 WHERE id = 1")
+
+	get := func(target string, h http.HandlerFunc) [][]string {
+		t.Helper()
+		r := httptest.NewRequest("GET", target, nil)
+		r.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", target, w.Code, w.Body)
+		}
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return records
+	}
+
+	rows := get("/archive/1/export.csv", s.HandleArchiveCSV)
+	if len(rows) != 2 || !reflect.DeepEqual(rows[0], csvHeader) {
+		t.Fatalf("archive CSV: %q", rows)
+	}
+	// Times are in the server's zone
+	if ts, err := time.Parse(time.RFC3339, rows[1][1]); err != nil || !ts.Equal(time.Date(2026, 5, 3, 14, 11, 4, 0, time.UTC)) {
+		t.Errorf("timestamp %s", rows[1][1])
+	}
+	want := []string{"1", "", "7", "CS1", "D", "Audi", "A4", "black", "0.91", "0.8", "0.7", "CAM-1", "52.52", "13.405", "http://example.com/event/1"}
+	if rows[1][1] = ""; !reflect.DeepEqual(rows[1], want) {
+		t.Errorf("archive CSV: %q", rows[1])
+	}
+
+	rows = get("/api/events/export.csv", s.HandleEventsCSV)
+	if len(rows) != 2 || rows[1][3] != `CS2, "B"` || rows[1][11] != "gate" || rows[1][12] != "" {
+		t.Errorf("session CSV: %q", rows)
+	}
+	if rows = get("/api/events/export.csv?archive=1&plate=XX*", s.HandleEventsCSV); len(rows) != 1 {
+		t.Errorf("filtered CSV: %q", rows)
+	}
+
+	// Links use the public URL like the compare workbook
+	s.PublicURL = "https://lpr.example.com/"
+	if rows = get("/api/events/export.csv", s.HandleEventsCSV); rows[1][14] != "https://lpr.example.com/event/2" {
+		t.Errorf("event_url %q", rows[1][14])
+	}
+}
@@ -0,0 +1,134 @@
+package srv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Integrators page through large event sets by ID rather than by the
+// dashboard's capture-time order: GET /api/events?since_id=0 returns the
+// oldest events first, and the rel="next" URL of the Link header continues
+// after the last one until a page comes back short. X-Total-Count is the
+// number of matching events in the session, current or ?archive=ID.
+
+const (
+	defaultCursorLimit = 100
+	maxCursorLimit     = 1000
+)
+
+// cursorLimit parses the page size of a cursor request
+func cursorLimit(v string) (int64, bool) {
+	if v == "" {
+		return defaultCursorLimit, true
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return min(n, maxCursorLimit), true
+}
+
+// serveEventsPage writes the filtered events after ?since_id= in ID order
+func (s *Server) serveEventsPage(w http.ResponseWriter, r *http.Request, f eventQuery) {
+	query := r.URL.Query()
+	var sinceID int64
+	if v := query.Get("since_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			s.jsonError(w, "invalid since_id", http.StatusBadRequest)
+			return
+		}
+		sinceID = id
+	}
+	limit, ok := cursorLimit(query.Get("limit"))
+	if !ok {
+		s.jsonError(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+	var archiveID *int64
+	if a := query.Get("archive"); a != "" && a != "0" {
+		id, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			s.jsonError(w, "invalid archive id", http.StatusBadRequest)
+			return
+		}
+		archiveID = &id
+	}
+
+	q := dbgen.New(s.DB)
+	var (
+		events any
+		n      int
+		lastID int64
+		total  int64
+		err    error
+	)
+	if archiveID == nil {
+		var rows []dbgen.GetEventsSinceRow
+		rows, err = q.GetEventsSince(r.Context(), dbgen.GetEventsSinceParams{
+			SinceID:      sinceID,
+			Plate:        f.Plate,
+			Camera:       f.Camera,
+			Country:      f.Country,
+			CarState:     f.CarState,
+			CapturedFrom: f.From,
+			CapturedTo:   f.To,
+			Limit:        limit,
+		})
+		if err == nil {
+			if n = len(rows); n > 0 {
+				lastID = rows[n-1].ID
+			}
+			if rows == nil {
+				rows = []dbgen.GetEventsSinceRow{}
+			}
+			events = rows
+		}
+	} else {
+		var rows []dbgen.GetArchiveEventsSinceRow
+		rows, err = q.GetArchiveEventsSince(r.Context(), dbgen.GetArchiveEventsSinceParams{
+			ArchiveID:    archiveID,
+			SinceID:      sinceID,
+			Plate:        f.Plate,
+			Camera:       f.Camera,
+			Country:      f.Country,
+			CarState:     f.CarState,
+			CapturedFrom: f.From,
+			CapturedTo:   f.To,
+			Limit:        limit,
+		})
+		if err == nil {
+			if n = len(rows); n > 0 {
+				lastID = rows[n-1].ID
+			}
+			if rows == nil {
+				rows = []dbgen.GetArchiveEventsSinceRow{}
+			}
+			events = rows
+		}
+	}
+	if err == nil {
+		total, err = f.count(r.Context(), q, archiveID)
+	}
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	if int64(n) == limit {
+		next := url.Values{}
+		for k, v := range query {
+			next[k] = v
+		}
+		next.Set("since_id", strconv.FormatInt(lastID, 10))
+		next.Set("limit", strconv.FormatInt(limit, 10))
+		w.Header().Set("Link", "<"+s.linkBaseURL(r)+r.URL.Path+"?"+next.Encode()+`>; rel="next"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
@@ -0,0 +1,59 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEventsCursor(t *testing.T) {
+	s := newTestServer(t)
+	s.PublicURL = "https://lpr.example.com"
+	for i := range 5 {
+		body := fmt.Sprintf(`{"carID":"%d","plateUTF8":"P%d"}`, i, i)
+		if _, err := s.ingestEvent(context.Background(), newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.HandleEventsAPI(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	var ids []int64
+	target := "/api/events?since_id=0&limit=2"
+	for pages := 0; target != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination doesn't end")
+		}
+		w := get(target)
+		if w.Code != http.StatusOK || w.Header().Get("X-Total-Count") != "5" {
+			t.Fatalf("%s: status = %d, total %q", target, w.Code, w.Header().Get("X-Total-Count"))
+		}
+		var page []struct{ ID int64 }
+		json.Unmarshal(w.Body.Bytes(), &page)
+		for _, e := range page {
+			ids = append(ids, e.ID)
+		}
+		target = ""
+		if link := w.Header().Get("Link"); link != "" {
+			target = strings.TrimPrefix(link[1:strings.Index(link, ">")], "https://lpr.example.com")
+		}
+	}
+	if fmt.Sprint(ids) != "[1 2 3 4 5]" {
+		t.Errorf("paged ids = %v, want [1 2 3 4 5]", ids)
+	}
+
+	if w := get("/api/events?since_id=5"); w.Body.String() != "[]\n" || w.Header().Get("Link") != "" {
+		t.Errorf("past the end: body %q, link %q", w.Body, w.Header().Get("Link"))
+	}
+	if w := get("/api/events?since_id=x"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad cursor: status = %d, want 400", w.Code)
+	}
+}
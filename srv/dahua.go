@@ -0,0 +1,201 @@
+package srv
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Dahua ITC traffic cameras push plate reads over HTTP in two shapes. The
+// ITC upload sends one JSON object with the read under Picture, the
+// pictures inline as base64:
+//
+//	{"Picture": {
+//	  "Plate":    {"PlateNumber": "ABC123", "Confidence": 96, "PlateColor": "Yellow"},
+//	  "Vehicle":  {"VehicleColor": "White", "VehicleType": "SUV", "VehicleSign": "Toyota"},
+//	  "SnapInfo": {"DeviceID": "6G0123PAZ", "AccurateTime": "2026-05-03 14:11:04.569",
+//	               "LaneNo": 2, "Direction": "Obverse", "UID": "123"},
+//	  "NormalPic": {"Content": "/9j/..."}, "CutoutPic": {"Content": "/9j/..."}}}
+//
+// The event manager (CGI) push sends the read as form keys or a JSON part,
+// Events[0].TrafficCar.PlateNumber=ABC123 and so on, with the pictures as
+// multipart files. Point the camera's HTTP upload at /api/dahua. Both are
+// mapped into event JSON, with lane and direction normalized into their
+// columns; fields without an events column are kept under "dahua".
+// Keepalives and events without a traffic read are acknowledged without
+// storing anything.
+
+// dahuaNoPlate are the plate numbers Dahua sends when it couldn't read one
+var dahuaNoPlate = []string{"", "unknown", "无车牌", "无牌", "未识别"}
+
+// dahuaSections are the objects a read's fields are looked up in
+var dahuaSections = []string{"Plate", "TrafficCar", "Object", "Vehicle", "SnapInfo"}
+
+// dahuaRoot finds the object holding the read: Picture in ITC uploads, the
+// first event (or its Data) in event manager pushes
+func dahuaRoot(doc map[string]any) map[string]any {
+	if p, ok := doc["Picture"].(map[string]any); ok {
+		return p
+	}
+	var ev map[string]any
+	switch events := doc["Events"].(type) {
+	case []any:
+		if len(events) > 0 {
+			ev, _ = events[0].(map[string]any)
+		}
+	case map[string]any:
+		// Form keys Events.0.TrafficCar...
+		ev, _ = events["0"].(map[string]any)
+	}
+	if ev == nil {
+		// Form keys Events[0].TrafficCar...
+		ev, _ = doc["Events[0]"].(map[string]any)
+	}
+	if ev != nil {
+		if data, ok := ev["Data"].(map[string]any); ok {
+			for k, v := range ev {
+				if _, taken := data[k]; !taken && k != "Data" {
+					data[k] = v
+				}
+			}
+			return data
+		}
+		return ev
+	}
+	return doc
+}
+
+// dahuaValue is the first of the dotted paths set in root, as a string
+func dahuaValue(root map[string]any, paths ...string) string {
+	for _, p := range paths {
+		var v any = root
+		for _, k := range strings.Split(p, ".") {
+			m, ok := v.(map[string]any)
+			if !ok {
+				v = nil
+				break
+			}
+			v = m[k]
+		}
+		switch v := v.(type) {
+		case string:
+			if strings.TrimSpace(v) != "" {
+				return strings.TrimSpace(v)
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(v)
+		}
+	}
+	return ""
+}
+
+// dahuaJSON converts a Dahua ITC upload or event manager push into event
+// JSON
+func dahuaJSON(data []byte) ([]byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, &payloadError{"invalid JSON: " + err.Error()}
+	}
+	root := dahuaRoot(doc)
+	found := false
+	for _, sec := range dahuaSections {
+		if _, ok := root[sec].(map[string]any); ok {
+			found = true
+		}
+	}
+	if !found {
+		code := dahuaValue(root, "Code", "EventBaseInfo.Code")
+		return nil, fmt.Errorf("%w: %s", errNotANPR, coalesce(code, "no traffic read"))
+	}
+
+	event := map[string]any{}
+	set := func(m map[string]any, key, v string) {
+		if v != "" {
+			m[key] = v
+		}
+	}
+	plate := dahuaValue(root, "Plate.PlateNumber", "TrafficCar.PlateNumber", "Object.Text")
+	if slices.Contains(dahuaNoPlate, strings.ToLower(plate)) {
+		plate = ""
+	}
+	set(event, "plateUTF8", plate)
+	set(event, "plateConfidence", dahuaValue(root, "Plate.Confidence", "Object.Confidence", "TrafficCar.Confidence"))
+	set(event, "plateCountry", dahuaValue(root, "Plate.Country", "TrafficCar.Country"))
+	set(event, "carID", dahuaValue(root, "SnapInfo.UID", "GroupID", "TrafficCar.RecNo", "UID", "EventID"))
+	set(event, "capture_timestamp", dahuaValue(root, "SnapInfo.AccurateTime", "SnapInfo.SnapTime", "UTC"))
+	set(event, "lane", dahuaValue(root, "SnapInfo.LaneNo", "SnapInfo.Lane", "TrafficCar.Lane", "Lane", "LaneNo"))
+	set(event, "direction", dahuaValue(root, "SnapInfo.Direction", "TrafficCar.Direction", "Vehicle.Direction", "Direction"))
+	set(event, "sensorProviderID", dahuaValue(root, "SnapInfo.DeviceName", "MachineName", "Name"))
+
+	camera := map[string]any{}
+	set(camera, "SerialNumber", dahuaValue(root, "SnapInfo.DeviceID", "SnapInfo.SerialNo", "DeviceID", "SerialNo"))
+	set(camera, "IPAddress", dahuaValue(root, "SnapInfo.DeviceIP", "DeviceIP"))
+	vehicle := map[string]any{}
+	set(vehicle, "make", dahuaValue(root, "Vehicle.VehicleSign", "Vehicle.Text", "TrafficCar.VehicleSign"))
+	set(vehicle, "model", dahuaValue(root, "Vehicle.VehicleSeries", "TrafficCar.VehicleSeries"))
+	set(vehicle, "color", dahuaValue(root, "Vehicle.VehicleColor", "TrafficCar.VehicleColor"))
+	set(vehicle, "type", dahuaValue(root, "Vehicle.VehicleType", "Vehicle.Category", "TrafficCar.VehicleType"))
+	dh := map[string]any{}
+	set(dh, "plateColor", dahuaValue(root, "Plate.PlateColor", "TrafficCar.PlateColor"))
+	set(dh, "plateType", dahuaValue(root, "Plate.PlateType", "TrafficCar.PlateType"))
+	set(dh, "speed", dahuaValue(root, "Vehicle.Speed", "TrafficCar.Speed", "SnapInfo.Speed"))
+	set(dh, "code", dahuaValue(root, "Code", "TrafficCar.Event"))
+	for key, m := range map[string]map[string]any{"camera_info": camera, "vehicle_info": vehicle, "dahua": dh} {
+		if len(m) > 0 {
+			event[key] = m
+		}
+	}
+
+	// Inline pictures are objects with a base64 Content
+	var images []map[string]any
+	for _, name := range slices.Sorted(maps.Keys(root)) {
+		pic, ok := root[name].(map[string]any)
+		if !ok {
+			continue
+		}
+		content, _ := pic["Content"].(string)
+		if content == "" {
+			continue
+		}
+		if _, err := base64.StdEncoding.DecodeString(content); err != nil {
+			slog.Warn("dahua picture is not base64", "picture", name, "error", err)
+			continue
+		}
+		images = append(images, map[string]any{
+			"ImageType":   coalesce(dahuaPictureType(name), strings.ToLower(name)),
+			"ImageFormat": "jpg",
+			"BinaryImage": content,
+		})
+	}
+	if len(images) > 0 {
+		event["ImageArray"] = images
+	}
+	return json.Marshal(event)
+}
+
+// dahuaPictureType is the image type of a Dahua picture object or file
+func dahuaPictureType(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+	switch {
+	case strings.Contains(name, "cutout"), strings.Contains(name, "plate"):
+		return "plate"
+	case strings.Contains(name, "normal"), strings.Contains(name, "vehicle"), strings.Contains(name, "scene"),
+		strings.Contains(name, "global"):
+		return "vehicle"
+	}
+	return ""
+}
+
+// HandleDahua ingests Dahua ITC plate reads
+func (s *Server) HandleDahua(w http.ResponseWriter, r *http.Request) {
+	s.handleIngest(w, r, "dahua")
+}
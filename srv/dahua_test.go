@@ -0,0 +1,132 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const dahuaITC = `{"Picture": {
+  "Plate": {"PlateNumber": "DH5678", "Confidence": 96, "PlateColor": "Yellow", "PlateType": "Normal", "Country": "CN"},
+  "Vehicle": {"VehicleColor": "White", "VehicleType": "SUV", "VehicleSign": "Toyota", "VehicleSeries": "RAV4", "Speed": 42},
+  "SnapInfo": {"DeviceID": "6G0123PAZ", "AccurateTime": "2026-05-03 14:11:04.569", "LaneNo": 2,
+               "Direction": "Obverse", "UID": "7001"},
+  "NormalPic": {"Content": "%s", "PicName": "normal.jpg"},
+  "CutoutPic": {"Content": "%s", "PicName": "cutout.jpg"}}}`
+
+func TestDahuaJSON(t *testing.T) {
+	got, err := dahuaJSON([]byte(strings.ReplaceAll(dahuaITC, "%s", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"carID":"7001","plateUTF8":"DH5678","plateConfidence":"96","plateCountry":"CN",
+		"capture_timestamp":"2026-05-03 14:11:04.569","lane":"2","direction":"Obverse",
+		"camera_info":{"SerialNumber":"6G0123PAZ"},
+		"vehicle_info":{"make":"Toyota","model":"RAV4","color":"White","type":"SUV"},
+		"dahua":{"plateColor":"Yellow","plateType":"Normal","speed":"42"}}`
+	var a, b any
+	json.Unmarshal(got, &a)
+	json.Unmarshal([]byte(want), &b)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+
+	// Event manager push as form keys
+	form := url.Values{
+		"Events[0].Code":                    {"TrafficJunction"},
+		"Events[0].GroupID":                 {"88"},
+		"Events[0].TrafficCar.PlateNumber":  {"无车牌"},
+		"Events[0].TrafficCar.Lane":         {"3"},
+		"Events[0].TrafficCar.Direction":    {"Reverse"},
+		"Events[0].TrafficCar.VehicleColor": {"Black"},
+	}
+	s := &Server{}
+	body, err := s.formJSON([]byte(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = dahuaJSON(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `{"carID":"88","lane":"3","direction":"Reverse","vehicle_info":{"color":"Black"},"dahua":{"code":"TrafficJunction"}}`
+	json.Unmarshal(got, &a)
+	json.Unmarshal([]byte(want), &b)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("form: got %s\nwant %s", got, want)
+	}
+
+	if _, err := dahuaJSON([]byte(`{"Events":[{"Code":"HeartBeat"}]}`)); err == nil || !strings.Contains(err.Error(), "HeartBeat") {
+		t.Errorf("keepalive: %v", err)
+	}
+}
+
+func TestHandleDahua(t *testing.T) {
+	s := newTestServer(t)
+	var pic bytes.Buffer
+	jpeg.Encode(&pic, image.NewGray(image.Rect(0, 0, 16, 16)), nil)
+	b64 := base64.StdEncoding.EncodeToString(pic.Bytes())
+
+	post := func(contentType string, body []byte) map[string]any {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/api/dahua", bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		s.HandleDahua(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST /api/dahua = %d %s", w.Code, w.Body)
+		}
+		var res map[string]any
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return res
+	}
+
+	if res := post("application/json", []byte(strings.ReplaceAll(dahuaITC, "%s", b64))); res["plate"] != "DH5678" || res["images"] != 2.0 {
+		t.Errorf("ITC upload: %v", res)
+	}
+	// Event manager push: the read as a JSON part, pictures as files
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormField("json")
+	fw.Write([]byte(`{"Events":[{"Code":"TrafficJunction","Data":{"GroupID":9,"TrafficCar":{"PlateNumber":"DH9","Lane":1,"Direction":"Reverse"}}}]}`))
+	fw, _ = mw.CreateFormFile("file", "plate_0.jpg")
+	fw.Write(pic.Bytes())
+	mw.Close()
+	if res := post(mw.FormDataContentType(), body.Bytes()); res["plate"] != "DH9" || res["images"] != 1.0 {
+		t.Errorf("event manager push: %v", res)
+	}
+	if res := post("application/json", []byte(`{"Events":[{"Code":"HeartBeat"}]}`)); res["message"] != "ignored" {
+		t.Errorf("keepalive: %v", res)
+	}
+
+	rows, err := s.DB.Query("SELECT plate_utf8, lane, direction FROM events ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var plate, direction string
+		var lane int
+		rows.Scan(&plate, &lane, &direction)
+		got = append(got, fmt.Sprintf("%s %s %d", plate, direction, lane))
+	}
+	if want := []string{"DH5678 approaching 2", "DH9 leaving 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events %q, want %q", got, want)
+	}
+	var types string
+	s.DB.QueryRow("SELECT GROUP_CONCAT(image_type) FROM (SELECT image_type FROM images WHERE event_id = 1 ORDER BY image_type)").Scan(&types)
+	if types != "plate,vehicle" {
+		t.Errorf("ITC images %q", types)
+	}
+}
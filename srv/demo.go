@@ -0,0 +1,467 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// -demo turns an empty database into a showroom: three cameras with a
+// day's worth of reads on each of the last three days and a current
+// session of the last hours, with plate crops and overview pictures drawn
+// on the fly. The older days are archived; the two oldest are reviewed,
+// including a few misreads with their true plate (see platematch.go) and
+// wrong makes, models and colors, and the newest is left for a live
+// review. A watchlist flags one of the current reads. Everything goes
+// through the normal ingest, archive and compare paths, so analytics and
+// exports look as they would with cameras. The data is the same on every
+// run; a database that already has events is left alone. The dashboard
+// marks a demo instance.
+
+// DemoSummary is what SeedDemo added
+type DemoSummary struct {
+	Events   int
+	Archives int
+	Verdicts int
+	Skipped  bool // the database already had events
+}
+
+const (
+	demoSessions       = 3  // archived days
+	demoSessionEvents  = 40 // reads per archived day
+	demoCurrentEvents  = 25
+	demoReviewed       = 2 // oldest archives with verdicts
+	demoWatchlistName  = "Demo: stolen vehicles"
+	demoWatchlistIndex = 7 // the watchlist flags the first good read from here
+)
+
+type demoVehicle struct {
+	Make, Model, Type string
+}
+
+var demoVehicles = []demoVehicle{
+	{"Volkswagen", "Golf", "car"},
+	{"Volkswagen", "Passat", "car"},
+	{"BMW", "3 Series", "car"},
+	{"BMW", "X5", "suv"},
+	{"Mercedes-Benz", "C-Class", "car"},
+	{"Mercedes-Benz", "Sprinter", "van"},
+	{"Audi", "A4", "car"},
+	{"Skoda", "Octavia", "car"},
+	{"Toyota", "RAV4", "suv"},
+	{"Ford", "Transit", "van"},
+	{"Renault", "Clio", "car"},
+	{"Tesla", "Model 3", "car"},
+	{"Volvo", "XC60", "suv"},
+	{"Peugeot", "308", "car"},
+}
+
+type demoColor struct {
+	Name string
+	RGB  color.RGBA
+}
+
+var demoColors = []demoColor{
+	{"white", color.RGBA{235, 235, 235, 255}},
+	{"black", color.RGBA{25, 25, 30, 255}},
+	{"silver", color.RGBA{185, 190, 195, 255}},
+	{"gray", color.RGBA{110, 112, 118, 255}},
+	{"blue", color.RGBA{30, 70, 160, 255}},
+	{"red", color.RGBA{170, 30, 35, 255}},
+	{"green", color.RGBA{40, 110, 60, 255}},
+}
+
+var demoCameras = []struct {
+	Name, Direction string
+}{
+	{"entrance-north", "in"},
+	{"exit-north", "out"},
+	{"garage", "in"},
+}
+
+// demoConfusions are the characters a misread swaps
+var demoConfusions = map[rune]rune{'0': 'O', 'O': '0', '1': 'I', 'I': '1', '8': 'B', 'B': '8', '5': 'S', 'S': '5', '2': 'Z', 'Z': '2'}
+
+// demoRead is one synthetic camera read
+type demoRead struct {
+	At           time.Time
+	Camera       int
+	Plate        string // on the vehicle
+	Read         string // what the camera reports; empty for no read
+	Country      string
+	Vehicle      demoVehicle // on the picture
+	Reported     demoVehicle // what the camera reports
+	Color        demoColor
+	ReportedCol  demoColor
+	Confidence   float64
+	MakerWrong   bool
+	ModelWrong   bool
+	ColorWrong   bool
+	Unrecognized bool
+}
+
+// demoPlate makes a plate in the style of one of a few countries
+func demoPlate(rng *rand.Rand) (plate, country string) {
+	const letters = "ABCDEFGHJKLMNPRSTUVWXYZ"
+	letter := func() byte { return letters[rng.IntN(len(letters))] }
+	digits := func(n int) string {
+		var b strings.Builder
+		for range n {
+			b.WriteByte(byte('0' + rng.IntN(10)))
+		}
+		return b.String()
+	}
+	switch rng.IntN(4) {
+	case 0:
+		city := string(letter())
+		if rng.IntN(2) == 0 {
+			city += string(letter())
+		}
+		return fmt.Sprintf("%s %c%c %s", city, letter(), letter(), strconv.Itoa(1+rng.IntN(9998))), "D"
+	case 1:
+		return fmt.Sprintf("%c%c %s", letter(), letter(), digits(5)), "PL"
+	case 2:
+		return fmt.Sprintf("%d%c%d %s", 1+rng.IntN(9), letter(), rng.IntN(10), digits(4)), "CZ"
+	default:
+		return fmt.Sprintf("%c%c-%s-%c", letter(), letter(), digits(3), letter()), "NL"
+	}
+}
+
+// demoMisread swaps a confusable character of a plate, or drops its last
+// one if it has none
+func demoMisread(rng *rand.Rand, plate string) string {
+	chars := []rune(plate)
+	var candidates []int
+	for i, c := range chars {
+		if _, ok := demoConfusions[c]; ok {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return string(chars[:len(chars)-1])
+	}
+	i := candidates[rng.IntN(len(candidates))]
+	chars[i] = demoConfusions[chars[i]]
+	return string(chars)
+}
+
+// demoReads makes n reads spread between from and to, in capture order
+func demoReads(rng *rand.Rand, n int, from, to time.Time) []demoRead {
+	reads := make([]demoRead, n)
+	span := to.Sub(from)
+	for i := range reads {
+		r := demoRead{
+			At:         from.Add(time.Duration(rng.Int64N(int64(span)))).Truncate(time.Second),
+			Camera:     rng.IntN(len(demoCameras)),
+			Vehicle:    demoVehicles[rng.IntN(len(demoVehicles))],
+			Color:      demoColors[rng.IntN(len(demoColors))],
+			Confidence: 0.85 + rng.Float64()*0.14,
+		}
+		r.Plate, r.Country = demoPlate(rng)
+		r.Read, r.Reported, r.ReportedCol = r.Plate, r.Vehicle, r.Color
+		switch p := rng.IntN(100); {
+		case p < 4:
+			r.Unrecognized, r.Read = true, ""
+		case p < 12:
+			r.Read = demoMisread(rng, r.Plate)
+			r.Confidence -= 0.25
+		}
+		switch p := rng.IntN(100); {
+		case p < 5:
+			r.MakerWrong, r.ModelWrong = true, true
+			for r.Reported.Make == r.Vehicle.Make {
+				r.Reported = demoVehicles[rng.IntN(len(demoVehicles))]
+			}
+		case p < 12:
+			r.ModelWrong = true
+			for r.Reported.Model == r.Vehicle.Model {
+				r.Reported.Model = demoVehicles[rng.IntN(len(demoVehicles))].Model
+			}
+		}
+		if rng.IntN(100) < 8 {
+			r.ColorWrong = true
+			for r.ReportedCol.Name == r.Color.Name {
+				r.ReportedCol = demoColors[rng.IntN(len(demoColors))]
+			}
+		}
+		reads[i] = r
+	}
+	slices.SortFunc(reads, func(a, b demoRead) int { return a.At.Compare(b.At) })
+	return reads
+}
+
+// message is the FF Group camera JSON of a read
+func (r demoRead) message(carID int) []byte {
+	msg := map[string]any{
+		"carID":            strconv.Itoa(carID),
+		"plateUTF8":        r.Read,
+		"plateCountry":     r.Country,
+		"plateConfidence":  strconv.FormatFloat(r.Confidence, 'f', 2, 64),
+		"carState":         "new",
+		"datetime":         r.At.Format(time.RFC3339),
+		"sensorProviderID": demoCameras[r.Camera].Name,
+		"packetCounter":    strconv.Itoa(carID),
+		"direction":        demoCameras[r.Camera].Direction,
+		"vehicle_info": map[string]string{
+			"make":            r.Reported.Make,
+			"model":           r.Reported.Model,
+			"color":           r.ReportedCol.Name,
+			"type":            r.Reported.Type,
+			"confidenceMMR":   "0.90",
+			"confidenceColor": "0.85",
+		},
+	}
+	if r.Unrecognized {
+		delete(msg, "plateUTF8")
+		delete(msg, "plateConfidence")
+	}
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+// images draws the read's plate crop and overview picture
+func (r demoRead) images() ([]uploadedImage, error) {
+	vehicle, err := encodeDemoJPEG(drawDemoVehicle(r))
+	if err != nil {
+		return nil, err
+	}
+	images := []uploadedImage{{Filename: "vehicle.jpg", Field: "vehicle", Data: vehicle}}
+	if r.Unrecognized {
+		return images, nil
+	}
+	plate, err := encodeDemoJPEG(drawDemoPlate(r.Plate))
+	if err != nil {
+		return nil, err
+	}
+	return append(images, uploadedImage{Filename: "plate.jpg", Field: "plate", Data: plate}), nil
+}
+
+// verdict is the compare result a reviewer gives the read
+func (r demoRead) verdict(eventID int64) (compareUpdate, bool) {
+	u := compareUpdate{EventID: eventID}
+	if r.Read != r.Plate && !r.Unrecognized {
+		u.TruePlate = ptr(r.Plate)
+	}
+	if r.MakerWrong {
+		u.Maker = ptr(true)
+	}
+	if r.ModelWrong {
+		u.Model = ptr(true)
+	}
+	if r.ColorWrong {
+		u.Color = ptr(true)
+	}
+	return u, u.TruePlate != nil || u.Maker != nil || u.Model != nil || u.Color != nil
+}
+
+// SeedDemo fills an empty database with the demo data
+func (s *Server) SeedDemo(ctx context.Context, now time.Time) (DemoSummary, error) {
+	q := dbgen.New(s.DB)
+	if n, err := q.CountEvents(ctx); err != nil {
+		return DemoSummary{}, err
+	} else if n > 0 {
+		return DemoSummary{Skipped: true}, nil
+	}
+	rng := rand.New(rand.NewPCG(2026, 1))
+
+	var sessions [][]demoRead
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for d := demoSessions; d > 0; d-- {
+		day := today.AddDate(0, 0, -d)
+		sessions = append(sessions, demoReads(rng, demoSessionEvents, day.Add(7*time.Hour), day.Add(19*time.Hour)))
+	}
+	current := demoReads(rng, demoCurrentEvents, now.Add(-3*time.Hour), now.Add(-time.Minute))
+	sessions = append(sessions, current)
+
+	list, _, err := s.createWatchlist(ctx, watchlistChange{Name: ptr(demoWatchlistName)})
+	if err != nil {
+		return DemoSummary{}, err
+	}
+	// A read of the plate, not a misread
+	flagged := current[demoWatchlistIndex]
+	for _, r := range current[demoWatchlistIndex:] {
+		if r.Read == r.Plate {
+			flagged = r
+			break
+		}
+	}
+	if _, _, err := s.addWatchlistEntry(ctx, list.ID, flagged.Plate, "Reported stolen (demo)", "", ""); err != nil {
+		return DemoSummary{}, err
+	}
+
+	var summary DemoSummary
+	ids := make([][]int64, len(sessions))
+	carID := 1000
+	for i, reads := range sessions {
+		for _, r := range reads {
+			carID++
+			images, err := r.images()
+			if err != nil {
+				return summary, err
+			}
+			res, err := s.ingestEvent(ctx, newIngestRequest(r.message(carID), "", images))
+			if err != nil {
+				return summary, fmt.Errorf("ingest demo read: %w", err)
+			}
+			ids[i] = append(ids[i], res.ID)
+			summary.Events++
+		}
+	}
+
+	// Each archived day ends where the next one starts
+	for i := range demoSessions {
+		archiveID, _, err := s.splitSession(ctx, ids[i+1][0])
+		if err != nil {
+			return summary, fmt.Errorf("archive demo session: %w", err)
+		}
+		summary.Archives++
+		if i >= demoReviewed {
+			continue
+		}
+		var updates []compareUpdate
+		for j, r := range sessions[i] {
+			if u, ok := r.verdict(ids[i][j]); ok {
+				updates = append(updates, u)
+			}
+		}
+		n, err := s.saveCompareUpdates(ctx, archiveID, updates, "", false)
+		if err != nil {
+			return summary, fmt.Errorf("review demo archive: %w", err)
+		}
+		summary.Verdicts += n
+	}
+	slog.Info("demo data seeded", "events", summary.Events, "archives", summary.Archives, "verdicts", summary.Verdicts)
+	return summary, nil
+}
+
+// demoGlyphs is a 5x7 pixel font, one string of rows per character
+var demoGlyphs = map[rune]string{
+	'0': "01110100011001110101110011000101110",
+	'1': "00100011000010000100001000010001110",
+	'2': "01110100010000100010001000100011111",
+	'3': "11110000010000101110000010000111110",
+	'4': "00010001100101010010111110001000010",
+	'5': "11111100001111000001000011000101110",
+	'6': "00110010001000011110100011000101110",
+	'7': "11111000010001000100010000100001000",
+	'8': "01110100011000101110100011000101110",
+	'9': "01110100011000101111000010001001100",
+	'A': "01110100011000111111100011000110001",
+	'B': "11110100011000111110100011000111110",
+	'C': "01110100011000010000100001000101110",
+	'D': "11100100101000110001100011001011100",
+	'E': "11111100001000011110100001000011111",
+	'F': "11111100001000011110100001000010000",
+	'G': "01110100011000010111100011000101111",
+	'H': "10001100011000111111100011000110001",
+	'I': "01110001000010000100001000010001110",
+	'J': "00111000100001000010000101001001100",
+	'K': "10001100101010011000101001001010001",
+	'L': "10000100001000010000100001000011111",
+	'M': "10001110111010110101100011000110001",
+	'N': "10001100011100110101100111000110001",
+	'O': "01110100011000110001100011000101110",
+	'P': "11110100011000111110100001000010000",
+	'Q': "01110100011000110001101011001001101",
+	'R': "11110100011000111110101001001010001",
+	'S': "01111100001000001110000010000111110",
+	'T': "11111001000010000100001000010000100",
+	'U': "10001100011000110001100011000101110",
+	'V': "10001100011000110001100010101000100",
+	'W': "10001100011000110101101011010101010",
+	'X': "10001100010101000100010101000110001",
+	'Y': "10001100010101000100001000010000100",
+	'Z': "11111000010001000100010001000011111",
+	'-': "00000000000000011111000000000000000",
+}
+
+// drawDemoText writes text with its top left corner at (x, y), each font
+// pixel scale pixels wide
+func drawDemoText(img draw.Image, x, y, scale int, text string, c color.Color) {
+	src := image.NewUniform(c)
+	for _, ch := range text {
+		if glyph, ok := demoGlyphs[ch]; ok {
+			for i, bit := range glyph {
+				if bit == '1' {
+					px, py := x+(i%5)*scale, y+(i/5)*scale
+					draw.Draw(img, image.Rect(px, py, px+scale, py+scale), src, image.Point{}, draw.Src)
+				}
+			}
+		}
+		x += 6 * scale
+	}
+}
+
+func demoTextWidth(text string, scale int) int {
+	return (6*len([]rune(text)) - 1) * scale
+}
+
+func fillDemoRect(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// drawDemoPlate draws a plate crop: white with a blue EU band on the left
+func drawDemoPlate(plate string) image.Image {
+	const scale = 3
+	w := 30 + demoTextWidth(plate, scale) + 16
+	img := image.NewRGBA(image.Rect(0, 0, w, 7*scale+24))
+	fillDemoRect(img, img.Bounds(), color.RGBA{20, 20, 20, 255})
+	fillDemoRect(img, img.Bounds().Inset(3), color.RGBA{245, 245, 240, 255})
+	fillDemoRect(img, image.Rect(3, 3, 24, img.Bounds().Dy()-3), color.RGBA{0, 51, 153, 255})
+	drawDemoText(img, 30, 12, scale, plate, color.Black)
+	return img
+}
+
+// drawDemoVehicle draws an overview picture: the vehicle from the front in
+// its color on a road, its plate on the bumper
+func drawDemoVehicle(r demoRead) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 480, 320))
+	fillDemoRect(img, image.Rect(0, 0, 480, 120), color.RGBA{150, 165, 180, 255})
+	fillDemoRect(img, image.Rect(0, 120, 480, 320), color.RGBA{85, 88, 92, 255})
+	fillDemoRect(img, image.Rect(236, 280, 244, 320), color.RGBA{220, 220, 210, 255})
+
+	roof := 105
+	switch r.Vehicle.Type {
+	case "suv":
+		roof = 85
+	case "van":
+		roof = 55
+	}
+	body, dark := r.Color.RGB, color.RGBA{r.Color.RGB.R / 2, r.Color.RGB.G / 2, r.Color.RGB.B / 2, 255}
+	fillDemoRect(img, image.Rect(120, 255, 170, 285), color.RGBA{15, 15, 15, 255}) // wheels
+	fillDemoRect(img, image.Rect(310, 255, 360, 285), color.RGBA{15, 15, 15, 255})
+	fillDemoRect(img, image.Rect(150, roof, 330, 165), dark)                           // cabin
+	fillDemoRect(img, image.Rect(162, roof+10, 318, 160), color.RGBA{60, 72, 85, 255}) // windshield
+	fillDemoRect(img, image.Rect(100, 160, 380, 262), body)                            // body
+	fillDemoRect(img, image.Rect(112, 178, 162, 198), color.RGBA{250, 245, 200, 255})  // lights
+	fillDemoRect(img, image.Rect(318, 178, 368, 198), color.RGBA{250, 245, 200, 255})
+	if !r.Unrecognized {
+		w := demoTextWidth(r.Plate, 1) + 12
+		plate := image.Rect(240-w/2, 222, 240+w/2, 240)
+		fillDemoRect(img, plate, color.RGBA{245, 245, 240, 255})
+		drawDemoText(img, plate.Min.X+6, plate.Min.Y+5, 1, r.Plate, color.Black)
+	}
+	drawDemoText(img, 8, 8, 2, strings.ToUpper(demoCameras[r.Camera].Name), color.White)
+	return img
+}
+
+func encodeDemoJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,51 @@
+package srv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestSeedDemo(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	now := time.Date(2026, 5, 4, 15, 0, 0, 0, time.UTC)
+
+	summary, err := s.SeedDemo(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.background.Wait()
+	want := demoSessions*demoSessionEvents + demoCurrentEvents
+	if summary.Events != want || summary.Archives != demoSessions || summary.Verdicts == 0 || summary.Skipped {
+		t.Errorf("summary %+v", summary)
+	}
+	q := dbgen.New(s.DB)
+	if n, _ := q.CountCurrentEvents(ctx); n != demoCurrentEvents {
+		t.Errorf("%d current events", n)
+	}
+	for id := int64(1); id <= demoSessions; id++ {
+		a, err := q.GetArchiveByID(ctx, id)
+		if err != nil || a.EventCount != demoSessionEvents {
+			t.Errorf("archive %d: %+v %v", id, a, err)
+		}
+		stats, err := s.compareStats(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reviewed := id <= demoReviewed; reviewed != (stats.Fields["maker"].Incorrect+stats.Fields["color"].Incorrect > 0) ||
+			reviewed != (stats.Plates != nil && stats.Plates.Checked > 0) {
+			t.Errorf("archive %d stats %+v plates %+v", id, stats.Fields, stats.Plates)
+		}
+	}
+	if hits, _ := q.GetWatchlistHits(ctx, dbgen.GetWatchlistHitsParams{Limit: 10}); len(hits) != 1 {
+		t.Errorf("watchlist hits %+v", hits)
+	}
+
+	// A database with events is left alone
+	if summary, err := s.SeedDemo(ctx, now); err != nil || !summary.Skipped {
+		t.Errorf("second seed %+v %v", summary, err)
+	}
+}
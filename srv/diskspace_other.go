@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package srv
+
+import "errors"
+
+// diskSpace is only implemented on Linux and macOS
+func diskSpace(dir string) (total, free int64, err error) {
+	return 0, 0, errors.New("disk space is not available on this platform")
+}
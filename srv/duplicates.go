@@ -0,0 +1,61 @@
+package srv
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Cameras in store-and-forward mode resend messages whose delivery they
+// couldn't confirm. Every message is recorded with a dedup key: its camera,
+// carID, packetCounter and carState, or without a packetCounter a hash of
+// its JSON. A
+// message whose key was received within DedupWindow is a duplicate and is
+// counted on the original event. It is then ignored, answering with the
+// original event, or with DedupLink stored as an event of its own that
+// points to the original (duplicate_of). Journal replays after a crash are
+// caught the same way.
+
+// DefaultDedupWindow is how long a message's dedup key is remembered
+const DefaultDedupWindow = 24 * time.Hour
+
+// dedupKey identifies a message across resends; nil for image-only messages
+func dedupKey(p parsedEvent, rawJSON []byte) *string {
+	if p.Event.PacketCounter != "" {
+		carID := p.Params.CarID
+		if p.AutoCarID {
+			carID = ""
+		}
+		return ptr("packet:" + p.Camera + "\x00" + carID + "\x00" + p.Event.PacketCounter + "\x00" + deref(p.Params.CarState))
+	}
+	if len(rawJSON) == 0 {
+		return nil
+	}
+	sum := sha256.Sum256(rawJSON)
+	return ptr("sha256:" + hex.EncodeToString(sum[:]))
+}
+
+// findDuplicate returns the event a message was already stored as, and
+// counts the resend on it
+func (s *Server) findDuplicate(ctx context.Context, q *dbgen.Queries, key *string, now time.Time) (dbgen.FindDuplicateMessageRow, bool) {
+	if s.DedupWindow <= 0 || key == nil {
+		return dbgen.FindDuplicateMessageRow{}, false
+	}
+	orig, err := q.FindDuplicateMessage(ctx, dbgen.FindDuplicateMessageParams{DedupKey: key, Since: now.Add(-s.DedupWindow)})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Warn("look up duplicate message", "error", err)
+		}
+		return dbgen.FindDuplicateMessageRow{}, false
+	}
+	if err := q.CountEventDuplicate(ctx, orig.EventID); err != nil {
+		slog.Warn("count duplicate message", "id", orig.EventID, "error", err)
+	}
+	return orig, true
+}
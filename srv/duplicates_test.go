@@ -0,0 +1,86 @@
+package srv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestDuplicateMessages(t *testing.T) {
+	s := newTestServer(t)
+	s.MergeWindow = DefaultMergeWindow
+	s.DedupWindow = time.Hour
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+
+	ingest := func(body string, at time.Time) ingestResult {
+		t.Helper()
+		req := newIngestRequest([]byte(body), "", nil)
+		req.ReceivedAt = at
+		res, err := s.ingestEvent(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	count := func() int64 {
+		n, _ := q.CountEvents(ctx)
+		return n
+	}
+	now := time.Now()
+
+	// A resend with the same packetCounter, even with a different body, is ignored
+	first := ingest(`{"carID":"7","carState":"new","packetCounter":"41","sensorProviderID":"cam1","plateUTF8":"AB123"}`, now)
+	resend := ingest(`{"carID":"7","carState":"new","packetCounter":"41","sensorProviderID":"cam1","plateUTF8":"AB123","resent":true}`, now.Add(time.Minute))
+	if !resend.Duplicate || resend.ID != first.ID || resend.UID != first.UID || resend.Plate != "AB123" {
+		t.Errorf("resend = %+v, want the original event %d", resend, first.ID)
+	}
+	// The continuation of the same packet is not a duplicate, but its resend is
+	if update := ingest(`{"carID":"7","carState":"update","packetCounter":"41","sensorProviderID":"cam1"}`, now.Add(time.Minute)); update.Duplicate || !update.Merged {
+		t.Errorf("update = %+v, want merged", update)
+	}
+	ingest(`{"carID":"7","carState":"update","packetCounter":"41","sensorProviderID":"cam1"}`, now.Add(2*time.Minute))
+	// Another camera's packet 41 is a different event
+	if other := ingest(`{"carID":"7","carState":"new","packetCounter":"41","sensorProviderID":"cam2"}`, now); other.Duplicate {
+		t.Error("same packetCounter from another camera treated as duplicate")
+	}
+
+	// Without a packetCounter identical JSON is a duplicate
+	body := `{"plateUTF8":"CD456","capture_timestamp":"2026-10-16T08:00:00Z"}`
+	ingest(body, now)
+	if !ingest(body, now.Add(time.Minute)).Duplicate {
+		t.Error("identical JSON not treated as duplicate")
+	}
+	if n := count(); n != 3 {
+		t.Errorf("events = %d, want 3", n)
+	}
+	ev, _ := q.GetEventByID(ctx, first.ID)
+	if ev.Duplicates != 2 || ev.MessageCount != 2 {
+		t.Errorf("original counts %d duplicates, %d messages; want 2, 2", ev.Duplicates, ev.MessageCount)
+	}
+
+	// After the window a reused packetCounter is a new event
+	if late := ingest(`{"carID":"7","carState":"new","packetCounter":"41","sensorProviderID":"cam1"}`, now.Add(2*time.Hour)); late.Duplicate || late.ID == first.ID {
+		t.Errorf("late = %+v, want a new event", late)
+	}
+
+	// Linked duplicates are stored pointing to the original
+	s.DedupLink = true
+	linked := ingest(body, now.Add(2*time.Minute))
+	if !linked.Duplicate || linked.ID == 0 {
+		t.Fatalf("linked = %+v, want a new duplicate event", linked)
+	}
+	ev, _ = q.GetEventByID(ctx, linked.ID)
+	orig, _ := q.GetEventByID(ctx, *ev.DuplicateOf)
+	if deref(orig.PlateUtf8) != "CD456" || orig.DuplicateOf != nil || orig.Duplicates != 2 {
+		t.Errorf("linked to event %d (%q, %d duplicates)", orig.ID, deref(orig.PlateUtf8), orig.Duplicates)
+	}
+
+	// Off stores every message
+	s.DedupWindow = 0
+	if again := ingest(body, now.Add(3*time.Minute)); again.Duplicate {
+		t.Error("duplicate detected with the window off")
+	}
+}
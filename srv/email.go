@@ -0,0 +1,537 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// With -email-config alerts go out by email: a read whose plate is on the
+// watchlist (with the plate crop attached), and ingest requests failing
+// more often than a threshold:
+//
+//	{"smtp": {"host": "smtp.example.com", "port": 587, "username": "lpr", "password": "secret",
+//	          "from": "LPR <lpr@example.com>", "tls": "starttls"},
+//	 "to": ["security@example.com"],
+//	 "watchlist": [{"plate": "AB123CD", "reason": "stolen"}, {"plate": "B-X*", "to": ["fleet@example.com"]}],
+//	 "errors": {"rate": 0.2, "window": "5m", "min_requests": 10}}
+//
+// tls is starttls (default, port 587), tls (implicit, port 465) or none.
+// Watchlist plates are globs (* and ?) matched without spaces, dashes and
+// dots. They're copied into the database watchlists on startup (see
+// ImportEmailWatchlist), which match reads and send the emails; an entry
+// removed from the file stays until it's deleted on /watchlists.
+// The error alert compares failed ingest requests with all of them over
+// each window and mails once when the rate is exceeded and once when it
+// recovers. Emails are sent in the background; failures are logged. The
+// SMTP server also sends the email actions of the watchlists managed on
+// /watchlists.
+//
+//	GET  /api/email      counters and the last error
+//	POST /api/email/test send a test message to the recipients
+
+// Email defaults
+const (
+	emailTimeout       = 30 * time.Second
+	emailErrorWindow   = 5 * time.Minute
+	emailMinRequests   = 10
+	emailErrorExamples = 5 // distinct error messages quoted in an alert
+)
+
+// EmailConfig is the SMTP server and the alerts sent through it
+type EmailConfig struct {
+	SMTP      SMTPConfig        `json:"smtp"`
+	To        []string          `json:"to"`
+	Watchlist []*WatchlistEntry `json:"watchlist"`
+	Errors    *ErrorAlertConfig `json:"errors"` // off when absent
+
+	mu       sync.Mutex
+	sent     int64
+	failed   int64
+	lastSent time.Time
+	lastErr  error
+	ingest   ingestCounts
+}
+
+// SMTPConfig is how to reach the mail server
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"` // default 587, or 465 with tls
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+	TLS      string `json:"tls"` // starttls (default), tls or none
+}
+
+// WatchlistEntry is a plate, or plate pattern, to be alerted about
+type WatchlistEntry struct {
+	Plate  string   `json:"plate"`
+	Reason string   `json:"reason"`
+	To     []string `json:"to"` // default: the config's recipients
+}
+
+// ErrorAlertConfig is the ingest error rate that raises an alert
+type ErrorAlertConfig struct {
+	Rate        float64  `json:"rate"`         // failed fraction of ingest requests, e.g. 0.2
+	Window      string   `json:"window"`       // default 5m
+	MinRequests int      `json:"min_requests"` // fewer requests in a window never alert (default 10)
+	To          []string `json:"to"`           // default: the config's recipients
+
+	window time.Duration
+}
+
+// ingestCounts are the ingest requests of the current error window
+type ingestCounts struct {
+	requests int
+	failures int
+	examples []string
+	alerting bool
+}
+
+// LoadEmailConfig reads an email alerting config file
+func LoadEmailConfig(path string) (*EmailConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg EmailConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// compile checks the config and fills in defaults
+func (c *EmailConfig) compile() error {
+	if c.SMTP.Host == "" {
+		return errors.New("smtp host is required")
+	}
+	switch c.SMTP.TLS {
+	case "":
+		c.SMTP.TLS = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		return fmt.Errorf("smtp tls %q: use starttls, tls or none", c.SMTP.TLS)
+	}
+	if c.SMTP.Port == 0 {
+		c.SMTP.Port = 587
+		if c.SMTP.TLS == "tls" {
+			c.SMTP.Port = 465
+		}
+	}
+	if _, err := mail.ParseAddress(c.SMTP.From); err != nil {
+		return fmt.Errorf("smtp from %q: %w", c.SMTP.From, err)
+	}
+	if err := checkRecipients(c.To); err != nil {
+		return err
+	}
+	for i, e := range c.Watchlist {
+		if e == nil {
+			return fmt.Errorf("watchlist entry %d: plate is required", i+1)
+		}
+		if _, err := watchlistPattern(e.Plate); err != nil {
+			return fmt.Errorf("watchlist entry %d: %w", i+1, err)
+		}
+		if err := checkRecipients(e.To); err != nil {
+			return err
+		}
+		if len(e.To) == 0 && len(c.To) == 0 {
+			return fmt.Errorf("watchlist plate %q: no recipients", e.Plate)
+		}
+	}
+	if a := c.Errors; a != nil {
+		if a.Rate <= 0 || a.Rate > 1 {
+			return errors.New("errors rate must be above 0 and at most 1")
+		}
+		a.window = emailErrorWindow
+		if a.Window != "" {
+			d, err := time.ParseDuration(a.Window)
+			if err != nil || d < time.Minute {
+				return fmt.Errorf("errors window %q: want a duration of at least 1m", a.Window)
+			}
+			a.window = d
+		}
+		if a.MinRequests <= 0 {
+			a.MinRequests = emailMinRequests
+		}
+		if err := checkRecipients(a.To); err != nil {
+			return err
+		}
+		if len(a.To) == 0 && len(c.To) == 0 {
+			return errors.New("errors: no recipients")
+		}
+	}
+	return nil
+}
+
+func checkRecipients(to []string) error {
+	for _, addr := range to {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("recipient %q: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+// recipients are the given addresses, else the config's
+func (c *EmailConfig) recipients(to []string) []string {
+	if len(to) > 0 {
+		return to
+	}
+	return c.To
+}
+
+// emailWatchlistName is the database watchlist holding the -email-config
+// entries sent to to; the config's own recipients get the plain name
+func emailWatchlistName(to []string) string {
+	if len(to) == 0 {
+		return "Email watchlist"
+	}
+	return "Email watchlist (" + strings.Join(to, ", ") + ")"
+}
+
+// ImportEmailWatchlist copies the -email-config watchlist into the
+// database watchlists, one list with an email action per set of
+// recipients, so reads are matched and mailed in one place. Lists are
+// found by name and their recipients reset to the config's; entries are
+// added or updated with the reason as note.
+func (s *Server) ImportEmailWatchlist(ctx context.Context) error {
+	c := s.Email
+	if c == nil || len(c.Watchlist) == 0 {
+		return nil
+	}
+	lists, err := dbgen.New(s.DB).GetWatchlists(ctx)
+	if err != nil {
+		return err
+	}
+	ids := map[string]int64{}
+	for _, w := range lists {
+		ids[w.Name] = w.ID
+	}
+	for _, e := range c.Watchlist {
+		name := emailWatchlistName(e.To)
+		to := c.recipients(e.To)
+		id, ok := ids[name]
+		if !ok {
+			w, _, err := s.createWatchlist(ctx, watchlistChange{Name: &name, EmailTo: &to, Flag: ptr(false)})
+			if err != nil {
+				return fmt.Errorf("watchlist %q: %w", name, err)
+			}
+			id, ids[name] = w.ID, w.ID
+		} else if _, err := s.updateWatchlist(ctx, id, watchlistChange{EmailTo: &to}); err != nil {
+			return fmt.Errorf("watchlist %q: %w", name, err)
+		}
+		if _, _, err := s.addWatchlistEntry(ctx, id, e.Plate, e.Reason, "", "-email-config"); err != nil {
+			return fmt.Errorf("watchlist plate %q: %w", e.Plate, err)
+		}
+	}
+	return nil
+}
+
+// plateCrop is an event's plate crop as an attachment, nil without one
+func (s *Server) plateCrop(ctx context.Context, eventID int64, plate string) *emailAttachment {
+	q := dbgen.New(s.DB)
+	best, err := q.GetEventBestImages(ctx, eventID)
+	if err != nil || toInt64(best.PlateImageID) == 0 {
+		return nil
+	}
+	data, err := s.loadImage(ctx, q, toInt64(best.PlateImageID))
+	if err != nil {
+		return nil
+	}
+	return &emailAttachment{Name: sanitizeFilename(plate) + imageExt(data), Data: data}
+}
+
+// countIngest records the outcome of an ingest request for the error alert
+func (c *EmailConfig) countIngest(err error) {
+	if c == nil || c.Errors == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := &c.ingest
+	n.requests++
+	if err != nil {
+		n.failures++
+		if msg := err.Error(); len(n.examples) < emailErrorExamples && !slices.Contains(n.examples, msg) {
+			n.examples = append(n.examples, msg)
+		}
+	}
+}
+
+// runIngestErrorAlerts checks the ingest error rate every window until ctx
+// is done
+func (s *Server) runIngestErrorAlerts(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.Email.Errors.window):
+		}
+		s.checkIngestErrors(ctx)
+	}
+}
+
+// checkIngestErrors ends the current error window, mailing when the rate
+// was first exceeded or has recovered
+func (s *Server) checkIngestErrors(ctx context.Context) {
+	c := s.Email
+	a := c.Errors
+	c.mu.Lock()
+	n := c.ingest
+	// Windows with too few requests leave the state as it was
+	alerting := n.alerting
+	if n.requests >= a.MinRequests {
+		alerting = float64(n.failures) >= a.Rate*float64(n.requests)
+	}
+	c.ingest = ingestCounts{alerting: alerting}
+	c.mu.Unlock()
+
+	switch {
+	case alerting && !n.alerting:
+		slog.Warn("ingest error rate exceeded", "failures", n.failures, "requests", n.requests, "window", a.window)
+		body := fmt.Sprintf("%d of %d ingest requests failed in the last %s (alert at %.0f%%).\n\nErrors:\n",
+			n.failures, n.requests, a.window, a.Rate*100)
+		for _, e := range n.examples {
+			body += "  " + e + "\n"
+		}
+		s.sendEmail(ctx, c.recipients(a.To), fmt.Sprintf("Ingest errors: %d of %d requests failed", n.failures, n.requests), body, nil)
+	case !alerting && n.alerting:
+		slog.Info("ingest error rate recovered", "failures", n.failures, "requests", n.requests)
+		body := fmt.Sprintf("%d of %d ingest requests failed in the last %s.\n", n.failures, n.requests, a.window)
+		s.sendEmail(ctx, c.recipients(a.To), "Ingest errors recovered", body, nil)
+	}
+}
+
+// emailAttachment is a file attached to an email
+type emailAttachment struct {
+	Name string
+	Data []byte
+}
+
+// sendEmail sends a message and counts the outcome
+func (s *Server) sendEmail(ctx context.Context, to []string, subject, body string, attach *emailAttachment) error {
+	c := s.Email
+	err := c.send(ctx, to, subject, body, attach)
+	c.mu.Lock()
+	c.lastErr = err
+	if err != nil {
+		c.failed++
+	} else {
+		c.sent++
+		c.lastSent = time.Now()
+	}
+	c.mu.Unlock()
+	if err != nil {
+		slog.Warn("email failed", "to", strings.Join(to, ","), "subject", subject, "error", err)
+	} else {
+		slog.Info("email sent", "to", strings.Join(to, ","), "subject", subject)
+	}
+	return err
+}
+
+// send delivers a message over SMTP
+func (c *EmailConfig) send(ctx context.Context, to []string, subject, body string, attach *emailAttachment) error {
+	msg, err := c.message(to, subject, body, attach)
+	if err != nil {
+		return err
+	}
+	host := c.SMTP.Host
+	conn, err := (&net.Dialer{Timeout: emailTimeout}).DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(c.SMTP.Port)))
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(emailTimeout))
+	if c.SMTP.TLS == "tls" {
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if c.SMTP.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server doesn't offer STARTTLS (set tls to none to send unencrypted)")
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if c.SMTP.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.SMTP.Username, c.SMTP.Password, host)); err != nil {
+			return err
+		}
+	}
+	from, _ := mail.ParseAddress(c.SMTP.From)
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		addr, _ := mail.ParseAddress(rcpt)
+		if err := client.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("%s: %w", addr.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message formats a plain text email, multipart with an attachment
+func (c *EmailConfig) message(to []string, subject, body string, attach *emailAttachment) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", c.SMTP.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	text := func(w *bytes.Buffer) error {
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+			return err
+		}
+		return qp.Close()
+	}
+	if attach == nil {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := text(&b); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	var parts bytes.Buffer
+	mw := multipart.NewWriter(&parts)
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mw.Boundary())
+	tw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var tb bytes.Buffer
+	if err := text(&tb); err != nil {
+		return nil, err
+	}
+	tw.Write(tb.Bytes())
+	aw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {http.DetectContentType(attach.Data)},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attach.Name})},
+	})
+	if err != nil {
+		return nil, err
+	}
+	enc := base64.StdEncoding.EncodeToString(attach.Data)
+	for len(enc) > 76 {
+		aw.Write([]byte(enc[:76] + "\r\n"))
+		enc = enc[76:]
+	}
+	aw.Write([]byte(enc + "\r\n"))
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	b.Write(parts.Bytes())
+	return b.Bytes(), nil
+}
+
+// emailStatus is the GET /api/email response
+type emailStatus struct {
+	Watchlist   int        `json:"watchlist"`
+	ErrorAlert  bool       `json:"error_alert"`
+	Alerting    bool       `json:"alerting"` // the ingest error rate is exceeded
+	Sent        int64      `json:"sent"`     // since the server started
+	Failed      int64      `json:"failed"`
+	LastSentAt  *time.Time `json:"last_sent_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	WindowCount int        `json:"window_requests"` // ingest requests in the current error window
+	WindowFails int        `json:"window_failures"`
+}
+
+// HandleEmailAPI reports how email alerting is doing
+func (s *Server) HandleEmailAPI(w http.ResponseWriter, r *http.Request) {
+	c := s.Email
+	if c == nil {
+		s.jsonError(w, "email alerting is not configured (-email-config)", http.StatusNotFound)
+		return
+	}
+	c.mu.Lock()
+	st := emailStatus{Watchlist: len(c.Watchlist), ErrorAlert: c.Errors != nil, Alerting: c.ingest.alerting,
+		Sent: c.sent, Failed: c.failed, WindowCount: c.ingest.requests, WindowFails: c.ingest.failures}
+	if !c.lastSent.IsZero() {
+		st.LastSentAt = ptr(c.lastSent)
+	}
+	if c.lastErr != nil {
+		st.LastError = c.lastErr.Error()
+	}
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+// HandleEmailTestAPI sends a test message to every configured recipient
+func (s *Server) HandleEmailTestAPI(w http.ResponseWriter, r *http.Request) {
+	c := s.Email
+	if c == nil {
+		s.jsonError(w, "email alerting is not configured (-email-config)", http.StatusNotFound)
+		return
+	}
+	to := append([]string{}, c.To...)
+	lists := [][]string{}
+	for _, e := range c.Watchlist {
+		lists = append(lists, e.To)
+	}
+	if c.Errors != nil {
+		lists = append(lists, c.Errors.To)
+	}
+	for _, list := range lists {
+		for _, addr := range list {
+			if !slices.Contains(to, addr) {
+				to = append(to, addr)
+			}
+		}
+	}
+	body := fmt.Sprintf("Email alerting works (test sent by %s).\n", coalesce(sessionUser(r), "an anonymous user"))
+	if err := s.sendEmail(r.Context(), to, "LPR test email", body, nil); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "to": to})
+}
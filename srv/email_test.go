@@ -0,0 +1,217 @@
+package srv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSMTP is a mail server that hands received messages to a channel
+func testSMTP(t *testing.T) (port int, received chan *mail.Message) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	received = make(chan *mail.Message, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				io.WriteString(conn, "220 test ESMTP\r\n")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+					case "EHLO", "HELO":
+						io.WriteString(conn, "250-test\r\n250 8BITMIME\r\n")
+					case "DATA":
+						io.WriteString(conn, "354 go ahead\r\n")
+						var data bytes.Buffer
+						for {
+							l, err := r.ReadString('\n')
+							if err != nil || l == ".\r\n" {
+								break
+							}
+							data.WriteString(strings.TrimPrefix(l, "."))
+						}
+						if msg, err := mail.ReadMessage(&data); err == nil {
+							received <- msg
+						}
+						io.WriteString(conn, "250 queued\r\n")
+					case "QUIT":
+						io.WriteString(conn, "221 bye\r\n")
+						return
+					default:
+						io.WriteString(conn, "250 ok\r\n")
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, received
+}
+
+func TestEmailAlerts(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.DataDir
+	port, received := testSMTP(t)
+	cfgPath := filepath.Join(dir, "email.json")
+	cfg, _ := json.Marshal(map[string]any{
+		"smtp":      map[string]any{"host": "127.0.0.1", "port": port, "from": "LPR <lpr@example.com>", "tls": "none"},
+		"to":        []string{"security@example.com"},
+		"watchlist": []map[string]any{{"plate": "AB-123CD", "reason": "stolen"}, {"plate": "BX*", "to": []string{"fleet@example.com"}}},
+		"errors":    map[string]any{"rate": 0.2, "min_requests": 10},
+	})
+	os.WriteFile(cfgPath, cfg, 0o644)
+	email, err := LoadEmailConfig(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.PublicURL = "https://lpr.example.com"
+	s.Email = email
+	ctx := context.Background()
+	// Importing twice keeps one list per set of recipients
+	for range 2 {
+		if err := s.ImportEmailWatchlist(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var lists, entries int
+	s.DB.QueryRow("SELECT COUNT(*) FROM watchlists").Scan(&lists)
+	s.DB.QueryRow("SELECT COUNT(*) FROM watchlist_entries").Scan(&entries)
+	if lists != 2 || entries != 2 {
+		t.Errorf("%d lists, %d entries", lists, entries)
+	}
+	wait := func() *mail.Message {
+		t.Helper()
+		select {
+		case msg := <-received:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("no email")
+			return nil
+		}
+	}
+
+	var crop bytes.Buffer
+	jpeg.Encode(&crop, image.NewGray(image.Rect(0, 0, 32, 8)), nil)
+	images := []uploadedImage{{Filename: "plate.jpg", Data: crop.Bytes()}}
+	if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"AB 123 CD","sensorProviderID":"gate"}`), "", images)); err != nil {
+		t.Fatal(err)
+	}
+	msg := wait()
+	if msg.Header.Get("Subject") != "Email watchlist: AB 123 CD at gate" || msg.Header.Get("To") != "security@example.com" {
+		t.Errorf("headers %v", msg.Header)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	text, _ := mr.NextPart()
+	body, _ := io.ReadAll(text)
+	if !strings.Contains(string(body), "Note:      stolen") || !strings.Contains(string(body), "https://lpr.example.com/event/") {
+		t.Errorf("body %s", body)
+	}
+	attachment, err := mr.NextPart()
+	if err != nil || attachment.FileName() != "AB_123_CD.jpg" || attachment.Header.Get("Content-Type") != "image/jpeg" {
+		t.Errorf("attachment %v: %v", attachment, err)
+	}
+
+	// The car's next message is within the cooldown; other plates aren't on the list
+	s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"AB123CD","sensorProviderID":"gate"}`), "", nil))
+	s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"ZZ999","sensorProviderID":"gate"}`), "", nil))
+	s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"BX-42","sensorProviderID":"yard"}`), "", nil))
+	if msg := wait(); msg.Header.Get("Subject") != "Email watchlist (fleet@example.com): BX-42 at yard" || msg.Header.Get("To") != "fleet@example.com" {
+		t.Errorf("second email %v", msg.Header)
+	}
+	s.background.Wait()
+	if len(received) != 0 {
+		t.Errorf("%d more emails", len(received))
+	}
+
+	// Error rate: 3 of 10 fail
+	for i := range 10 {
+		var err error
+		if i < 3 {
+			err = errors.New("invalid JSON")
+		}
+		email.countIngest(err)
+	}
+	s.checkIngestErrors(ctx)
+	msg = wait()
+	if msg.Header.Get("Subject") != "Ingest errors: 3 of 10 requests failed" {
+		t.Errorf("error alert %v", msg.Header)
+	}
+	if body, _ := io.ReadAll(msg.Body); !strings.Contains(string(body), "invalid JSON") {
+		t.Errorf("error alert body %s", body)
+	}
+	// Still failing, and a quiet window: no news
+	for range 10 {
+		email.countIngest(errors.New("database error"))
+	}
+	s.checkIngestErrors(ctx)
+	email.countIngest(nil)
+	s.checkIngestErrors(ctx)
+	for range 10 {
+		email.countIngest(nil)
+	}
+	s.checkIngestErrors(ctx)
+	if msg := wait(); msg.Header.Get("Subject") != "Ingest errors recovered" {
+		t.Errorf("recovery %v", msg.Header)
+	}
+	if len(received) != 0 {
+		t.Errorf("%d more emails", len(received))
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleEmailAPI(w, httptest.NewRequest("GET", "/api/email", nil))
+	var st emailStatus
+	json.Unmarshal(w.Body.Bytes(), &st)
+	if st.Sent != 4 || st.Failed != 0 || st.Watchlist != 2 || st.Alerting {
+		t.Errorf("status %s", w.Body)
+	}
+	w = httptest.NewRecorder()
+	s.HandleEmailTestAPI(w, httptest.NewRequest("POST", "/api/email/test", nil))
+	if msg := wait(); w.Code != 200 || msg.Header.Get("To") != "security@example.com, fleet@example.com" {
+		t.Errorf("test email %d %v", w.Code, msg.Header)
+	}
+
+	for _, bad := range []string{
+		`{"smtp": {"from": "lpr@example.com"}, "to": ["a@example.com"], "watchlist": [{"plate": "AB1"}]}`,
+		`{"smtp": {"host": "mail", "from": "not an address"}, "to": ["a@example.com"], "watchlist": [{"plate": "AB1"}]}`,
+		`{"smtp": {"host": "mail", "from": "lpr@example.com", "tls": "ssl"}, "to": ["a@example.com"], "watchlist": [{"plate": "AB1"}]}`,
+		`{"smtp": {"host": "mail", "from": "lpr@example.com"}, "watchlist": [{"plate": "AB1"}]}`,
+		`{"smtp": {"host": "mail", "from": "lpr@example.com"}, "to": ["a@example.com"], "watchlist": [{"plate": "AB["}]}`,
+		`{"smtp": {"host": "mail", "from": "lpr@example.com"}, "to": ["a@example.com"], "errors": {"rate": 2}}`,
+	} {
+		os.WriteFile(cfgPath, []byte(bad), 0o644)
+		if _, err := LoadEmailConfig(cfgPath); err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// GET /api/event/{id} (ID or ULID) returns what the event page shows: the
+// event, its images including deleted ones, which of them are the best
+// plate and vehicle image, its message history and the camera's
+// neighbouring events images can be moved to. The event page re-renders
+// its images from it after changing one.
+
+// eventDetail is the JSON of an event page
+type eventDetail struct {
+	Event          dbgen.Event                   `json:"event"`
+	Images         []dbgen.GetImagesByEventIDRow `json:"images"`
+	PlateImageID   int64                         `json:"plate_image_id"`
+	VehicleImageID int64                         `json:"vehicle_image_id"`
+	Messages       []dbgen.EventMessage          `json:"messages"`
+	Neighbours     []dbgen.GetNeighbourEventsRow `json:"neighbours"`
+}
+
+// loadEventDetail reads everything the event page shows about an event
+func loadEventDetail(ctx context.Context, q *dbgen.Queries, event dbgen.Event) (eventDetail, error) {
+	d := eventDetail{Event: event}
+	var err error
+	if d.Images, err = q.GetImagesByEventID(ctx, event.ID); err != nil {
+		return d, err
+	}
+	best, err := q.GetEventBestImages(ctx, event.ID)
+	if err != nil {
+		return d, err
+	}
+	d.PlateImageID, d.VehicleImageID = toInt64(best.PlateImageID), toInt64(best.VehicleImageID)
+	if d.Messages, err = q.GetEventMessages(ctx, event.ID); err != nil {
+		return d, err
+	}
+	d.Neighbours, err = neighbourEvents(ctx, q, event)
+	return d, err
+}
+
+// HandleEventAPI returns an event with its images and messages
+func (s *Server) HandleEventAPI(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	event, err := lookupEvent(r.Context(), q, r.PathValue("id"))
+	if err != nil {
+		s.jsonError(w, "event not found", http.StatusNotFound)
+		return
+	}
+	d, err := loadEventDetail(r.Context(), q, event)
+	if err != nil {
+		slog.Error("failed to load event", "event", event.ID, "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
@@ -0,0 +1,61 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestEventAPI(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	first, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"AB123","carID":"7","sensorProviderID":"gate"}`), "", []uploadedImage{
+		{Filename: "plate.png", Data: pngOf(100, 20)},
+		{Filename: "vehicle.png", Data: pngOf(300, 200)},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"CD456","carID":"8","sensorProviderID":"gate"}`), "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.background.Wait()
+
+	get := func(id string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("GET", "/api/event/"+id, nil)
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		s.HandleEventAPI(w, r)
+		return w
+	}
+
+	w := get(strconv.FormatInt(first.ID, 10))
+	var d eventDetail
+	if err := json.NewDecoder(w.Body).Decode(&d); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("status %d, %v", w.Code, err)
+	}
+	if d.Event.ID != first.ID || len(d.Images) != 2 || len(d.Messages) != 1 {
+		t.Errorf("event %d, %d images, %d messages", d.Event.ID, len(d.Images), len(d.Messages))
+	}
+	var vehicle int64
+	for _, img := range d.Images {
+		if deref(img.Filename) == "vehicle.png" {
+			vehicle = img.ID
+		}
+	}
+	if d.VehicleImageID != vehicle || d.PlateImageID == 0 || d.PlateImageID == vehicle {
+		t.Errorf("best plate %d, vehicle %d; vehicle.png is %d", d.PlateImageID, d.VehicleImageID, vehicle)
+	}
+	if len(d.Neighbours) != 1 || d.Neighbours[0].ID != second.ID {
+		t.Errorf("neighbours %+v", d.Neighbours)
+	}
+
+	if w := get("999"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown event: status %d", w.Code)
+	}
+}
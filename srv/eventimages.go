@@ -0,0 +1,87 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Some cameras push the overview image seconds after the recognition, in a
+// second request. POST /api/event/{id}/images attaches such follow-up
+// images to the original event instead of recording an orphan. It takes the
+// same bodies as POST /api: multipart image files, a bare image/* body, or
+// JSON whose ImageArray holds base64 images (other JSON fields are ignored).
+
+// lookupEvent finds an event by local ID or ULID
+func lookupEvent(ctx context.Context, q *dbgen.Queries, idStr string) (dbgen.Event, error) {
+	if isULID(idStr) {
+		return q.GetEventByUID(ctx, &idStr)
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return dbgen.Event{}, err
+	}
+	return q.GetEventByID(ctx, id)
+}
+
+// HandleAddEventImages stores additional images for an existing event
+func (s *Server) HandleAddEventImages(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	event, err := lookupEvent(r.Context(), q, r.PathValue("id"))
+	if err != nil {
+		s.jsonError(w, "event not found", http.StatusNotFound)
+		return
+	}
+	if locked, err := s.eventLocked(r.Context(), event.ArchiveID); err != nil || locked {
+		s.jsonError(w, "event is in a locked archive", http.StatusConflict)
+		return
+	}
+
+	req, err := s.readIngestRequest(r)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var payload IncomingEvent
+	if len(req.RawJSON) > 0 {
+		if err := json.Unmarshal(req.RawJSON, &payload); err != nil {
+			s.jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if len(req.Images) == 0 && len(payload.ImageArray) == 0 {
+		s.jsonError(w, "no images provided", http.StatusBadRequest)
+		return
+	}
+
+	plate := coalesce(deref(event.PlateUtf8), deref(event.ManualPlate))
+	camera := coalesce(deref(event.CameraSerial), deref(event.SensorProviderID))
+	stored, rejected, err := s.storeEventImages(r.Context(), q, event.ID, plate, camera, req.Images, payload.ImageArray, req.ReceivedAt)
+	if err != nil {
+		slog.Error("failed to add event images", "id", event.ID, "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("event images added", "id", event.ID, "images", stored, "rejected", rejected)
+	if stored > 0 {
+		s.queueMeasure()
+	}
+
+	if event.Unrecognized && event.ManualPlate == nil && stored > 0 {
+		s.queueOCR(event.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  true,
+		"message":  "images added",
+		"id":       event.ID,
+		"uid":      deref(event.Uid),
+		"images":   stored,
+		"rejected": rejected,
+	})
+}
@@ -0,0 +1,50 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestAddEventImages(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	res, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"carID":"1","plateUTF8":"AB123"}`), "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var img bytes.Buffer
+	jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 64, 48)), nil)
+	post := func(id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/event/"+id+"/images", bytes.NewReader(img.Bytes()))
+		r.Header.Set("Content-Type", "image/jpeg")
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		s.HandleAddEventImages(w, r)
+		return w
+	}
+
+	// The follow-up push addresses the event by its ULID
+	if w := post(res.UID); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	images, err := dbgen.New(s.DB).GetImagesByEventID(ctx, res.ID)
+	if err != nil || len(images) != 1 {
+		t.Fatalf("images = %d, %v; want 1", len(images), err)
+	}
+	if n, _ := dbgen.New(s.DB).CountEvents(ctx); n != 1 {
+		t.Errorf("events = %d, want 1 (no orphan)", n)
+	}
+
+	if w := post("999"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown event: status = %d, want 404", w.Code)
+	}
+}
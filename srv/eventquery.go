@@ -0,0 +1,106 @@
+package srv
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// /api/events narrows the list in SQL so downstream systems don't pull the
+// whole session to filter it themselves. All filters combine:
+//
+//	plate=AB*12?           plate or manual plate, * and ? wildcards, case-insensitive
+//	camera=SERIAL          camera serial, sensor provider ID or camera IP (alias: camera_serial)
+//	country=D              plate country
+//	state=lost             carState (alias: car_state)
+//	from=2026-01-02        capture time (receive time if unknown) at or after; date or timestamp
+//	to=2026-01-03T12:00Z   capture time before; a date includes the whole day
+
+// eventQuery holds the /api/events filters; nil fields match everything
+type eventQuery struct {
+	Plate    *string // GLOB pattern on the upper-cased plate
+	Camera   *string
+	Country  *string
+	CarState *string
+	From     *time.Time
+	To       *time.Time
+}
+
+// parseEventQuery reads the filters; times without a zone are in the camera
+// time zone
+func (s *Server) parseEventQuery(q url.Values) (eventQuery, error) {
+	get := func(names ...string) *string {
+		for _, name := range names {
+			if v := strings.TrimSpace(q.Get(name)); v != "" {
+				return &v
+			}
+		}
+		return nil
+	}
+
+	f := eventQuery{
+		Camera:   get("camera", "camera_serial"),
+		Country:  get("country"),
+		CarState: get("state", "car_state"),
+	}
+	if p := get("plate"); p != nil {
+		f.Plate = ptr(strings.ToUpper(*p))
+	}
+	loc := s.CameraTZ
+	if loc == nil {
+		loc = time.Local
+	}
+	for _, bound := range []struct {
+		name string
+		dst  **time.Time
+		end  bool
+	}{
+		{"from", &f.From, false},
+		{"to", &f.To, true},
+	} {
+		v := get(bound.name)
+		if v == nil {
+			continue
+		}
+		t, err := parseFilterTime(*v, loc, bound.end)
+		if err != nil {
+			return f, fmt.Errorf("invalid %s %q: use a date (2006-01-02) or a timestamp", bound.name, *v)
+		}
+		// Stored times are in the server's zone and compare as text
+		*bound.dst = ptr(t.In(time.Local))
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return f, fmt.Errorf("from must be before to")
+	}
+	return f, nil
+}
+
+// parseFilterTime parses a date or timestamp. A date is midnight, or the
+// following midnight when it ends a range, so to=2026-01-02 includes that day.
+func parseFilterTime(v string, loc *time.Location, end bool) (time.Time, error) {
+	if d, err := time.ParseInLocation(time.DateOnly, v, loc); err == nil {
+		if end {
+			d = d.AddDate(0, 0, 1)
+		}
+		return d, nil
+	}
+	return parseTimestampFormat(v, loc)
+}
+
+// count returns how many events of the current session (archiveID nil) or
+// an archive match
+func (f eventQuery) count(ctx context.Context, q *dbgen.Queries, archiveID *int64) (int64, error) {
+	return q.CountMatchingEvents(ctx, dbgen.CountMatchingEventsParams{
+		ArchiveID:    archiveID,
+		Plate:        f.Plate,
+		Camera:       f.Camera,
+		Country:      f.Country,
+		CarState:     f.CarState,
+		CapturedFrom: f.From,
+		CapturedTo:   f.To,
+	})
+}
@@ -0,0 +1,61 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEventsFilter(t *testing.T) {
+	s := newTestServer(t)
+	s.CameraTZ = time.UTC
+	for _, body := range []string{
+		`{"carID":"1","plateUTF8":"AB123","plateCountry":"D","carState":"new","sensorProviderID":"gate1","capture_timestamp":"2026-01-02T08:00:00Z"}`,
+		`{"carID":"2","plateUTF8":"AB999","plateCountry":"NL","carState":"lost","sensorProviderID":"gate2","capture_timestamp":"2026-01-02T20:00:00Z"}`,
+		`{"carID":"3","plateUTF8":"XY123","plateCountry":"D","carState":"lost","sensorProviderID":"gate1","capture_timestamp":"2026-01-03T09:00:00Z"}`,
+	} {
+		if _, err := s.ingestEvent(context.Background(), newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"plate=ab*", []string{"2", "1"}},
+		{"plate=*123&country=d", []string{"3", "1"}},
+		{"camera_serial=GATE1&state=lost", []string{"3"}},
+		{"from=2026-01-02&to=2026-01-02", []string{"2", "1"}},
+		{"from=2026-01-02T12:00:00Z", []string{"3", "2"}},
+		{"since_id=0&plate=AB*", []string{"1", "2"}},
+	} {
+		w := httptest.NewRecorder()
+		s.HandleEventsAPI(w, httptest.NewRequest(http.MethodGet, "/api/events?"+tc.query, nil))
+		var events []struct {
+			CarID string `json:"car_id"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &events)
+		var got []string
+		for _, e := range events {
+			got = append(got, e.CarID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: car ids %v, want %v", tc.query, got, tc.want)
+		}
+		if total := w.Header().Get("X-Total-Count"); total != strconv.Itoa(len(tc.want)) {
+			t.Errorf("%s: X-Total-Count = %s, want %d", tc.query, total, len(tc.want))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleEventsAPI(w, httptest.NewRequest(http.MethodGet, "/api/events?from=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad from: status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,285 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// GET /api/events/stream pushes newly recorded events as Server-Sent
+// Events. Integrations pick what they want at subscribe time instead of
+// filtering the whole firehose themselves:
+//
+//	camera=SERIAL[,SERIAL]   camera serial, sensor provider ID or camera IP
+//	plate=AB*12?             plate pattern, * and ? wildcards, case-insensitive
+//	plate_prefix=AB          plates starting with AB (no wildcards)
+//	node=SITE[,SITE]         node ID (one instance per project; alias: project)
+//	unrecognized=1|0         only events without / with a plate read
+//	hotlist=1                only reads with a watchlist hit (listed in "watchlists")
+//
+// Slow consumers don't hold up ingest: when a subscriber's buffer is full,
+// events are dropped for it and it is told how many with a "dropped" event.
+
+const (
+	subscriberBuffer = 64
+	streamKeepAlive  = 30 * time.Second
+)
+
+// eventFilter selects the events a subscriber receives; empty fields match
+// everything
+type eventFilter struct {
+	Cameras      []string `json:"cameras,omitempty"`
+	Plate        string   `json:"plate,omitempty"` // path.Match pattern on the upper-cased plate
+	Nodes        []string `json:"nodes,omitempty"`
+	Unrecognized *bool    `json:"unrecognized,omitempty"`
+	Hotlist      bool     `json:"hotlist,omitempty"`
+}
+
+// parseEventFilter reads a filter from query parameters
+func parseEventFilter(q url.Values) (eventFilter, error) {
+	get := func(name string) string { return strings.TrimSpace(q.Get(name)) }
+	list := func(names ...string) []string {
+		var out []string
+		for _, name := range names {
+			for _, v := range strings.Split(get(name), ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					out = append(out, v)
+				}
+			}
+		}
+		return out
+	}
+
+	f := eventFilter{
+		Cameras: list("camera"),
+		Nodes:   list("node", "project"),
+		Plate:   strings.ToUpper(get("plate")),
+	}
+	if prefix := get("plate_prefix"); prefix != "" {
+		if f.Plate != "" {
+			return f, fmt.Errorf("plate and plate_prefix can't be combined")
+		}
+		f.Plate = escapePlatePattern(strings.ToUpper(prefix)) + "*"
+	}
+	if f.Plate != "" {
+		if _, err := path.Match(f.Plate, ""); err != nil {
+			return f, fmt.Errorf("invalid plate pattern %q", get("plate"))
+		}
+	}
+	switch get("unrecognized") {
+	case "":
+	case "1", "true":
+		f.Unrecognized = ptr(true)
+	case "0", "false":
+		f.Unrecognized = ptr(false)
+	default:
+		return f, fmt.Errorf("unrecognized must be 1 or 0")
+	}
+	switch get("hotlist") {
+	case "", "0", "false":
+	case "1", "true":
+		f.Hotlist = true
+	default:
+		return f, fmt.Errorf("hotlist must be 1 or 0")
+	}
+	return f, nil
+}
+
+// escapePlatePattern quotes the path.Match metacharacters in a literal plate
+func escapePlatePattern(plate string) string {
+	var b strings.Builder
+	for _, c := range plate {
+		if strings.ContainsRune(`*?[]\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (f eventFilter) match(e ExportEvent) bool {
+	if len(f.Cameras) > 0 && !matchAny(f.Cameras, e.Camera.Serial, e.SensorProviderID, e.Camera.IP) {
+		return false
+	}
+	if len(f.Nodes) > 0 && !matchAny(f.Nodes, e.NodeID) {
+		return false
+	}
+	if f.Unrecognized != nil && *f.Unrecognized != e.Unrecognized {
+		return false
+	}
+	if f.Hotlist && len(e.Watchlists) == 0 {
+		return false
+	}
+	if f.Plate != "" {
+		plate := e.Plate
+		if plate == nil {
+			plate = e.ManualPlate
+		}
+		if plate == nil {
+			return false
+		}
+		if ok, _ := path.Match(f.Plate, strings.ToUpper(*plate)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny reports whether one of the values is in want
+func matchAny(want []string, values ...*string) bool {
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		for _, w := range want {
+			if strings.EqualFold(w, *v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type subscriber struct {
+	filter  eventFilter
+	events  chan ExportEvent
+	dropped int // guarded by eventHub.mu
+}
+
+// eventHub fans recorded events out to stream subscribers; the zero value
+// has no subscribers
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+}
+
+func (h *eventHub) subscribe(f eventFilter) *subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs == nil {
+		h.subs = make(map[*subscriber]struct{})
+	}
+	sub := &subscriber{filter: f, events: make(chan ExportEvent, subscriberBuffer)}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// setFilter changes what a subscriber receives from now on
+func (h *eventHub) setFilter(sub *subscriber, f eventFilter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub.filter = f
+}
+
+func (h *eventHub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, sub)
+}
+
+func (h *eventHub) active() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs) > 0
+}
+
+func (h *eventHub) publish(e ExportEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if !sub.filter.match(e) {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// takeDropped returns and resets the number of events dropped for sub
+func (h *eventHub) takeDropped(sub *subscriber) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := sub.dropped
+	sub.dropped = 0
+	return n
+}
+
+// publishEvent sends a newly recorded event to the stream subscribers
+func (s *Server) publishEvent(ctx context.Context, id int64) {
+	if !s.subscribers.active() {
+		return
+	}
+	q := dbgen.New(s.DB)
+	e, err := q.GetEventByID(ctx, id)
+	if err != nil {
+		slog.Warn("load event for stream", "id", id, "error", err)
+		return
+	}
+	out := newExportEvent(e)
+	// Hits were recorded while the event was stored
+	if out.Watchlists, err = q.GetEventWatchlists(ctx, id); err != nil {
+		slog.Warn("load watchlist hits for stream", "id", id, "error", err)
+	}
+	s.subscribers.publish(out)
+}
+
+// HandleEventStream streams matching events to the client as they are
+// recorded
+func (s *Server) HandleEventStream(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r.URL.Query())
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	sub := s.subscribers.subscribe(filter)
+	defer s.subscribers.unsubscribe(sub)
+	slog.Info("event stream subscribed", "remote", r.RemoteAddr, "filter", filter)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, ": subscribed\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			slog.Info("event stream closed", "remote", r.RemoteAddr)
+			return
+		case <-s.stopping.done():
+			return
+		case e := <-sub.events:
+			if n := s.subscribers.takeDropped(sub); n > 0 {
+				fmt.Fprintf(w, "event: dropped\ndata: {\"count\":%d}\n\n", n)
+			}
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "event: event\nid: %d\ndata: %s\n\n", e.ID, data)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package srv
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventFilter(t *testing.T) {
+	e := ExportEvent{
+		Plate:  ptr("AB123C"),
+		NodeID: ptr("site-1"),
+		Camera: ExportCamera{Serial: ptr("CAM9")},
+	}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"plate=ab*", true},
+		{"plate=AB???C", true},
+		{"plate=X*", false},
+		{"camera=cam1,cam9", true},
+		{"camera=CAM1", false},
+		{"project=site-1", true},
+		{"node=site-2", false},
+		{"unrecognized=0&plate=*3C", true},
+		{"unrecognized=1", false},
+		{"hotlist=1", false},
+		{"hotlist=0", true},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		f, err := parseEventFilter(q)
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		if got := f.match(e); got != tt.want {
+			t.Errorf("%q matched %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	e.Watchlists = []string{"stolen"}
+	if f, _ := parseEventFilter(url.Values{"hotlist": {"1"}}); !f.match(e) {
+		t.Error("hotlist=1 skipped a watchlist hit")
+	}
+
+	for _, query := range []string{"plate=[", "unrecognized=maybe", "hotlist=maybe"} {
+		q, _ := url.ParseQuery(query)
+		if _, err := parseEventFilter(q); err == nil {
+			t.Errorf("%q accepted", query)
+		}
+	}
+}
+
+func TestHotlistStream(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	list, _, err := s.createWatchlist(ctx, watchlistChange{Name: ptr("stolen")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.addWatchlistEntry(ctx, list.ID, "AB1*", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	sub := s.subscribers.subscribe(eventFilter{Hotlist: true})
+	defer s.subscribers.unsubscribe(sub)
+	for _, body := range []string{`{"plateUTF8":"CD456"}`, `{"plateUTF8":"AB123"}`} {
+		res, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.publishEvent(ctx, res.ID)
+	}
+	s.background.Wait()
+
+	select {
+	case e := <-sub.events:
+		if deref(e.Plate) != "AB123" || !reflect.DeepEqual(e.Watchlists, []string{"stolen"}) {
+			t.Errorf("streamed %s with watchlists %v", deref(e.Plate), e.Watchlists)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watchlist hit not streamed")
+	}
+	select {
+	case e := <-sub.events:
+		t.Errorf("streamed %s without a hit", deref(e.Plate))
+	default:
+	}
+}
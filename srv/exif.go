@@ -0,0 +1,128 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// EXIF tags read for frame capture times
+const (
+	exifIFDPointer     = 0x8769
+	exifDateTime       = 0x0132
+	exifDateTimeOrig   = 0x9003
+	exifOffsetTimeOrig = 0x9011
+	exifSubSecOrig     = 0x9291
+)
+
+// exifTime reads the capture time of a JPEG from its EXIF data:
+// DateTimeOriginal with SubSecTimeOriginal and OffsetTimeOriginal, falling
+// back to DateTime. Times without an offset are in loc.
+func exifTime(data []byte, loc *time.Location) (time.Time, bool) {
+	tiff := exifSegment(data)
+	if len(tiff) < 8 {
+		return time.Time{}, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return time.Time{}, false
+	}
+
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+	tags := ifd0
+	if off, ok := ifd0[exifIFDPointer]; ok {
+		tags = readIFD(tiff, order, order.Uint32(off))
+	}
+	value := tags[exifDateTimeOrig]
+	if value == nil {
+		value = ifd0[exifDateTime]
+	}
+	stamp := exifString(value)
+	if stamp == "" {
+		return time.Time{}, false
+	}
+	if sub := exifString(tags[exifSubSecOrig]); sub != "" {
+		stamp += "." + sub
+	}
+	layout := "2006:01:02 15:04:05"
+	if offset := exifString(tags[exifOffsetTimeOrig]); offset != "" {
+		stamp += offset
+		layout += "Z07:00"
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation(layout, stamp, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// exifSegment returns the TIFF data of a JPEG's APP1 Exif segment
+func exifSegment(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return nil
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // image data follows
+			return nil
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + size
+		if size < 2 || end > len(data) {
+			return nil
+		}
+		if seg := data[i+4 : end]; marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return seg[6:]
+		}
+		i = end
+	}
+	return nil
+}
+
+// readIFD returns the raw values of an image file directory's entries:
+// the 4 value bytes when the value fits, else the referenced bytes. Only
+// ASCII values are dereferenced; others keep their 4 value bytes.
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16][]byte {
+	tags := make(map[uint16][]byte)
+	if int(offset)+2 > len(tiff) {
+		return tags
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < count; n++ {
+		e := int(offset) + 2 + n*12
+		if e+12 > len(tiff) {
+			break
+		}
+		tag, typ, size := order.Uint16(tiff[e:]), order.Uint16(tiff[e+2:]), order.Uint32(tiff[e+4:])
+		value := tiff[e+8 : e+12]
+		if typ == 2 && size > 4 { // ASCII stored elsewhere
+			start := order.Uint32(value)
+			if uint64(start)+uint64(size) > uint64(len(tiff)) {
+				continue
+			}
+			value = tiff[start : start+size]
+		} else if typ == 2 {
+			value = value[:size]
+		}
+		tags[tag] = value
+	}
+	return tags
+}
+
+// exifString trims the NUL terminator and padding of an ASCII value
+func exifString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,87 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+)
+
+// jpegWithExif builds a JPEG whose Exif IFD holds the given ASCII tags
+func jpegWithExif(t *testing.T, tags map[uint16]string) []byte {
+	t.Helper()
+	le := binary.LittleEndian
+	// TIFF header, IFD0 with the Exif IFD pointer at 8, Exif IFD at 26
+	tiff := []byte("II\x2a\x00\x08\x00\x00\x00")
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = le.AppendUint16(tiff, exifIFDPointer)
+	tiff = le.AppendUint16(tiff, 4)
+	tiff = le.AppendUint32(tiff, 1)
+	tiff = le.AppendUint32(tiff, 26)
+	tiff = le.AppendUint32(tiff, 0)
+
+	data := 26 + 2 + 12*len(tags) + 4
+	var entries, values []byte
+	for _, tag := range []uint16{exifDateTimeOrig, exifOffsetTimeOrig, exifSubSecOrig} {
+		v, ok := tags[tag]
+		if !ok {
+			continue
+		}
+		v += "\x00"
+		entries = le.AppendUint16(entries, tag)
+		entries = le.AppendUint16(entries, 2)
+		entries = le.AppendUint32(entries, uint32(len(v)))
+		if len(v) <= 4 {
+			entries = append(entries, []byte(v + "\x00\x00\x00\x00")[:4]...)
+			continue
+		}
+		entries = le.AppendUint32(entries, uint32(data+len(values)))
+		values = append(values, v...)
+	}
+	tiff = le.AppendUint16(tiff, uint16(len(tags)))
+	tiff = append(tiff, entries...)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(tiff, values...)
+
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(seg)+2))
+	out = append(out, seg...)
+	return append(out, img.Bytes()[2:]...)
+}
+
+func TestExifTime(t *testing.T) {
+	loc := time.FixedZone("camera", 2*3600)
+
+	data := jpegWithExif(t, map[uint16]string{exifDateTimeOrig: "2026:03:14 09:26:53", exifSubSecOrig: "123"})
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("test JPEG doesn't decode: %v", err)
+	}
+	got, ok := exifTime(data, loc)
+	want := time.Date(2026, 3, 14, 9, 26, 53, 123e6, loc)
+	if !ok || !got.Equal(want) {
+		t.Errorf("exifTime = %v, %v; want %v", got, ok, want)
+	}
+
+	data = jpegWithExif(t, map[uint16]string{exifDateTimeOrig: "2026:03:14 09:26:53", exifOffsetTimeOrig: "-05:00"})
+	got, ok = exifTime(data, loc)
+	want = time.Date(2026, 3, 14, 14, 26, 53, 0, time.UTC)
+	if !ok || !got.Equal(want) {
+		t.Errorf("exifTime with offset = %v, %v; want %v", got, ok, want)
+	}
+
+	var plain bytes.Buffer
+	jpeg.Encode(&plain, image.NewGray(image.Rect(0, 0, 8, 8)), nil)
+	if _, ok := exifTime(plain.Bytes(), loc); ok {
+		t.Error("time read from a JPEG without EXIF")
+	}
+	if _, ok := exifTime([]byte("not an image"), loc); ok {
+		t.Error("time read from garbage")
+	}
+}
@@ -0,0 +1,263 @@
+package srv
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	exportDefaultLimit = 1000
+	exportMaxLimit     = 10000
+)
+
+// ExportEvent is the normalized, warehouse-friendly form of an event
+type ExportEvent struct {
+	ID               int64           `json:"id"`
+	UID              *string         `json:"uid"`
+	NodeID           *string         `json:"node_id"`
+	OriginID         *int64          `json:"origin_id,omitempty"` // event ID on node_id when imported from another instance
+	CarID            string          `json:"car_id"`
+	Source           string          `json:"source"`
+	Plate            *string         `json:"plate"`
+	ManualPlate      *string         `json:"manual_plate"`
+	Unrecognized     bool            `json:"unrecognized"`
+	PlateCountry     *string         `json:"plate_country"`
+	PlateRegion      *string         `json:"plate_region"`
+	PlateRegionCode  *string         `json:"plate_region_code"`
+	PlateConfidence  *float64        `json:"plate_confidence"`
+	CarState         *string         `json:"car_state"`
+	EventDatetime    *string         `json:"event_datetime"`
+	CaptureTimestamp *string         `json:"capture_timestamp"`
+	CapturedAt       *time.Time      `json:"captured_at"`     // parsed from capture_timestamp or event_datetime
+	TimestampError   *string         `json:"timestamp_error"` // why neither could be parsed
+	SensorProviderID *string         `json:"sensor_provider_id"`
+	Lane             *int64          `json:"lane"`
+	Direction        *string         `json:"direction"`
+	Vehicle          ExportVehicle   `json:"vehicle"`
+	Camera           ExportCamera    `json:"camera"`
+	GeotagLat        *float64        `json:"geotag_lat"`
+	GeotagLon        *float64        `json:"geotag_lon"`
+	ArchiveID        *int64          `json:"archive_id"`
+	CreatedAt        time.Time       `json:"created_at"`
+	Images           []ExportImage   `json:"images,omitempty"`
+	RawJSON          json.RawMessage `json:"raw_json,omitempty"`
+	Watchlists       []string        `json:"watchlists,omitempty"` // lists the read hit, on the event stream
+}
+
+type ExportVehicle struct {
+	Make            *string `json:"make"`
+	Model           *string `json:"model"`
+	Color           *string `json:"color"`
+	Type            *string `json:"type"`
+	ConfidenceMMR   *string `json:"confidence_mmr"`
+	ConfidenceColor *string `json:"confidence_color"`
+}
+
+type ExportCamera struct {
+	Serial *string `json:"serial"`
+	IP     *string `json:"ip"`
+}
+
+type ExportImage struct {
+	ID       int64   `json:"id"`
+	Type     *string `json:"type"`
+	Filename *string `json:"filename"`
+	URL      string  `json:"url"`
+	ThumbURL string  `json:"thumb_url,omitempty"` // WebSocket clients with thumbnails=1
+}
+
+func newExportEvent(e dbgen.Event) ExportEvent {
+	return ExportEvent{
+		ID:               e.ID,
+		UID:              e.Uid,
+		NodeID:           e.NodeID,
+		OriginID:         e.OriginID,
+		CarID:            e.CarID,
+		Source:           e.Source,
+		Plate:            e.PlateUtf8,
+		ManualPlate:      e.ManualPlate,
+		Unrecognized:     e.Unrecognized,
+		PlateCountry:     e.PlateCountry,
+		PlateRegion:      e.PlateRegion,
+		PlateRegionCode:  e.PlateRegionCode,
+		PlateConfidence:  e.PlateConfidence,
+		CarState:         e.CarState,
+		EventDatetime:    e.EventDatetime,
+		CaptureTimestamp: e.CaptureTimestamp,
+		CapturedAt:       e.CapturedAt,
+		TimestampError:   e.TimestampError,
+		SensorProviderID: e.SensorProviderID,
+		Lane:             e.Lane,
+		Direction:        e.Direction,
+		Vehicle: ExportVehicle{
+			Make:            e.VehicleMake,
+			Model:           e.VehicleModel,
+			Color:           e.VehicleColor,
+			Type:            e.VehicleType,
+			ConfidenceMMR:   e.ConfidenceMmr,
+			ConfidenceColor: e.ConfidenceColor,
+		},
+		Camera: ExportCamera{
+			Serial: e.CameraSerial,
+			IP:     e.CameraIp,
+		},
+		GeotagLat: e.GeotagLat,
+		GeotagLon: e.GeotagLon,
+		ArchiveID: e.ArchiveID,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// parseExportTime accepts RFC 3339, a plain date, or unix seconds
+func parseExportTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (want RFC 3339, YYYY-MM-DD or unix seconds)", v)
+}
+
+// baseURL reconstructs the externally visible scheme and host of a request
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// HandleExportEvents streams events as NDJSON for bulk synchronization.
+//
+// Query parameters: since, until (received time window, compared in UTC so
+// it holds across DST changes), cursor (resume after this event ID), limit
+// (page size), images=1 (include image URLs), raw=1 (include the original
+// camera JSON).
+// The cursor for the next page is returned in the X-Next-Cursor header and
+// a rel="next" Link; both are absent on the last page.
+func (s *Server) HandleExportEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	params := dbgen.ExportEventsParams{
+		Until: time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC),
+		Limit: exportDefaultLimit,
+	}
+	if v := query.Get("since"); v != "" {
+		t, err := parseExportTime(v)
+		if err != nil {
+			s.jsonError(w, "since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		params.Since = t
+	}
+	if v := query.Get("until"); v != "" {
+		t, err := parseExportTime(v)
+		if err != nil {
+			s.jsonError(w, "until: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		params.Until = t
+	}
+	if v := query.Get("cursor"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			s.jsonError(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		params.Cursor = n
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			s.jsonError(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		params.Limit = min(n, exportMaxLimit)
+	}
+	withImages := query.Get("images") == "1" || query.Get("images") == "true"
+	withRaw := query.Get("raw") == "1" || query.Get("raw") == "true"
+
+	// Fetch one extra row to know whether another page follows
+	pageSize := params.Limit
+	params.Limit++
+
+	q := dbgen.New(s.DB)
+	events, err := q.ExportEvents(r.Context(), params)
+	if err != nil {
+		slog.Error("failed to export events", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	hasMore := int64(len(events)) > pageSize
+	if hasMore {
+		events = events[:pageSize]
+	}
+
+	images := make(map[int64][]ExportImage)
+	if withImages && len(events) > 0 {
+		ids := make([]int64, len(events))
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+		rows, err := q.GetImagesByEventIDs(r.Context(), ids)
+		if err != nil {
+			slog.Error("failed to export images", "error", err)
+			s.jsonError(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		base := baseURL(r)
+		for _, img := range rows {
+			images[img.EventID] = append(images[img.EventID], ExportImage{
+				ID:       img.ID,
+				Type:     img.ImageType,
+				Filename: img.Filename,
+				URL:      fmt.Sprintf("%s/image/%d", base, img.ID),
+			})
+		}
+	}
+
+	if hasMore {
+		next := strconv.FormatInt(events[len(events)-1].ID, 10)
+		nextQuery := url.Values{}
+		for k, v := range query {
+			nextQuery[k] = v
+		}
+		nextQuery.Set("cursor", next)
+		w.Header().Set("X-Next-Cursor", next)
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s?%s>; rel="next"`, baseURL(r), r.URL.Path, nextQuery.Encode()))
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Node-ID", s.NodeID)
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, e := range events {
+		out := newExportEvent(e)
+		out.Images = images[e.ID]
+		if withRaw {
+			if raw := s.eventJSON(r.Context(), e.RawJson, e.JsonFilename); raw != nil && json.Valid([]byte(*raw)) {
+				out.RawJSON = json.RawMessage(*raw)
+			}
+		}
+		if err := enc.Encode(out); err != nil {
+			slog.Warn("export stream aborted", "error", err)
+			return
+		}
+	}
+	bw.Flush()
+}
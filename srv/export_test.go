@@ -0,0 +1,163 @@
+package srv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestExportEvents(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	// Received the night clocks went back, in the order they happened
+	cest, cet := time.FixedZone("CEST", 2*3600), time.FixedZone("CET", 3600)
+	received := []time.Time{
+		time.Date(2026, 10, 25, 2, 5, 0, 0, cest),
+		time.Date(2026, 10, 25, 2, 30, 0, 0, cest),
+		time.Date(2026, 10, 25, 2, 10, 0, 0, cet),
+	}
+	for i, at := range received {
+		body := `{"plateUTF8":"EX` + string(rune('1'+i)) + `"}`
+		res, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", []uploadedImage{{Filename: "plate.png", Data: pngOf(100, 20)}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.DB.Exec("UPDATE events SET created_at = ? WHERE id = ?", at, res.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.background.Wait()
+
+	export := func(target string) ([]ExportEvent, *httptest.ResponseRecorder) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.HandleExportEvents(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", target, w.Code, w.Body)
+		}
+		var events []ExportEvent
+		sc := bufio.NewScanner(w.Body)
+		for sc.Scan() {
+			var e ExportEvent
+			if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, e)
+		}
+		return events, w
+	}
+	plates := func(events []ExportEvent) (out []string) {
+		for _, e := range events {
+			out = append(out, deref(e.Plate))
+		}
+		return out
+	}
+
+	// 00:00Z-01:00Z is the last hour of summer time: 02:05 and 02:30 CEST,
+	// but not 02:10 CET, which sorts between them as local text
+	events, _ := export("/api/export/events?since=2026-10-25T00:00:00Z&until=2026-10-25T01:00:00Z")
+	if got := plates(events); len(got) != 2 || got[0] != "EX1" || got[1] != "EX2" {
+		t.Errorf("window: %v", got)
+	}
+	if events, _ = export("/api/export/events?since=2026-10-25T02:05:00%2B01:00"); len(events) != 1 || deref(events[0].Plate) != "EX3" {
+		t.Errorf("since winter time: %v", plates(events))
+	}
+
+	// Pages resume after the cursor
+	events, w := export("/api/export/events?limit=2&images=1")
+	if len(events) != 2 || w.Header().Get("X-Next-Cursor") != "2" || len(events[0].Images) != 1 {
+		t.Fatalf("first page: %v, cursor %q", plates(events), w.Header().Get("X-Next-Cursor"))
+	}
+	events, w = export("/api/export/events?limit=2&cursor=2")
+	if len(events) != 1 || deref(events[0].Plate) != "EX3" || w.Header().Get("X-Next-Cursor") != "" || len(events[0].Images) != 0 {
+		t.Errorf("last page: %v, cursor %q", plates(events), w.Header().Get("X-Next-Cursor"))
+	}
+
+	for _, target := range []string{"/api/export/events?since=yesterday", "/api/export/events?cursor=-1", "/api/export/events?limit=0"} {
+		w := httptest.NewRecorder()
+		s.HandleExportEvents(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d", target, w.Code)
+		}
+	}
+}
+
+func TestNodeID(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"ND1"}`), "", nil)); err != nil {
+		t.Fatal(err)
+	}
+	// Events recorded before a node ID was configured are stamped once it is
+	s.NodeID = "site-a"
+	if n, err := dbgen.New(s.DB).StampNodeID(ctx, &s.NodeID); err != nil || n != 1 {
+		t.Fatalf("stamped %d, %v", n, err)
+	}
+	s.NodeID = "site-b"
+	if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"ND2"}`), "", nil)); err != nil {
+		t.Fatal(err)
+	}
+	s.background.Wait()
+
+	w := httptest.NewRecorder()
+	s.HandleExportEvents(w, httptest.NewRequest("GET", "/api/export/events", nil))
+	if w.Header().Get("X-Node-ID") != "site-b" {
+		t.Errorf("X-Node-ID %q", w.Header().Get("X-Node-ID"))
+	}
+	var nodes []string
+	for sc := bufio.NewScanner(w.Body); sc.Scan(); {
+		var e ExportEvent
+		json.Unmarshal(sc.Bytes(), &e)
+		nodes = append(nodes, deref(e.NodeID))
+	}
+	if len(nodes) != 2 || nodes[0] != "site-a" || nodes[1] != "site-b" {
+		t.Errorf("exported nodes %v", nodes)
+	}
+
+	r := httptest.NewRequest("GET", "/api/events/2", nil)
+	r.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	s.HandleEventAPI(w, r)
+	var detail struct {
+		Event struct {
+			NodeID string `json:"node_id"`
+		} `json:"event"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &detail)
+	if detail.Event.NodeID != "site-b" {
+		t.Errorf("event API: %s", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	s.HandleVersion(w, httptest.NewRequest("GET", "/api/version", nil))
+	var version map[string]any
+	json.Unmarshal(w.Body.Bytes(), &version)
+	if version["node_id"] != "site-b" {
+		t.Errorf("version: %v", version)
+	}
+
+	// A merged archive lists every node its events came from
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('merged')")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+	s.ExportImages = DefaultExportImageConfig()
+	r = httptest.NewRequest("GET", "/archive/1/export", nil)
+	r.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	s.HandleCompareExport(w, r)
+	f, err := excelize.OpenReader(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("%d %s: %v", w.Code, w.Body, err)
+	}
+	defer f.Close()
+	if v, _ := f.GetCellValue("Statistics", "B7"); v != "site-b, site-a" { // newest event first
+		t.Errorf("workbook nodes %q", v)
+	}
+}
@@ -0,0 +1,140 @@
+package srv
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// compareExportOptions are the per-request settings of a compare export
+type compareExportOptions struct {
+	Images  ExportImageConfig
+	Columns []exportColumn // optional columns after the fixed ones
+	BaseURL string         // server URL for event links
+}
+
+// exportColumn is an optional compare export column, picked with
+// ?columns=plate_confidence,camera or ?columns=all
+type exportColumn struct {
+	Key    string
+	Header string
+	Width  float64
+	value  func(e dbgen.GetArchivedEventsRow) any
+}
+
+var exportColumns = []exportColumn{
+	{"plate_confidence", "PLATE_CONFIDENCE", 12, func(e dbgen.GetArchivedEventsRow) any {
+		if e.PlateConfidence == nil {
+			return nil
+		}
+		return *e.PlateConfidence
+	}},
+	{"mmr_confidence", "MMR_CONFIDENCE", 12, func(e dbgen.GetArchivedEventsRow) any {
+		return confidenceValue(e.ConfidenceMmr)
+	}},
+	{"color_confidence", "COLOR_CONFIDENCE", 12, func(e dbgen.GetArchivedEventsRow) any {
+		return confidenceValue(e.ConfidenceColor)
+	}},
+	{"camera", "CAMERA_SERIAL", 16, func(e dbgen.GetArchivedEventsRow) any {
+		if e.CameraSerial != nil {
+			return *e.CameraSerial
+		}
+		return cellText(deref(e.SensorProviderID))
+	}},
+	{"camera_ip", "CAMERA_IP", 15, func(e dbgen.GetArchivedEventsRow) any {
+		return cellText(deref(e.CameraIp))
+	}},
+	{"country", "COUNTRY", 10, func(e dbgen.GetArchivedEventsRow) any {
+		country := deref(e.PlateCountry)
+		if e.PlateRegionCode != nil && *e.PlateRegionCode != "" {
+			country += " / " + *e.PlateRegionCode
+		}
+		return cellText(country)
+	}},
+	{"geotag", "GEOTAG", 22, func(e dbgen.GetArchivedEventsRow) any {
+		if e.GeotagLat == nil || e.GeotagLon == nil {
+			return nil
+		}
+		return fmt.Sprintf("%.6f, %.6f", *e.GeotagLat, *e.GeotagLon)
+	}},
+}
+
+// cellText leaves empty values out of the sheet
+func cellText(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// confidenceValue turns a camera confidence string into a number when it is one
+func confidenceValue(s *string) any {
+	if s == nil || *s == "" {
+		return nil
+	}
+	if f, err := strconv.ParseFloat(*s, 64); err == nil {
+		return f
+	}
+	return *s
+}
+
+// parseExportColumns reads a comma-separated column list
+func parseExportColumns(v string) ([]exportColumn, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if v == "all" {
+		return exportColumns, nil
+	}
+	var cols []exportColumn
+	for _, key := range strings.Split(v, ",") {
+		key = strings.TrimSpace(key)
+		found := false
+		for _, c := range exportColumns {
+			if c.Key == key {
+				cols = append(cols, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown export column %q", key)
+		}
+	}
+	return cols, nil
+}
+
+// exportOptions reads compare export options from the request
+func (s *Server) exportOptions(r *http.Request) (compareExportOptions, error) {
+	images, err := s.ExportImages.withQuery(r.URL.Query())
+	if err != nil {
+		return compareExportOptions{}, err
+	}
+	cols, err := parseExportColumns(r.URL.Query().Get("columns"))
+	if err != nil {
+		return compareExportOptions{}, err
+	}
+	return compareExportOptions{Images: images, Columns: cols, BaseURL: s.linkBaseURL(r)}, nil
+}
+
+// linkBaseURL is the server URL used in links that leave the browser, such
+// as exported spreadsheets: PublicURL if configured, else the request's host
+func (s *Server) linkBaseURL(r *http.Request) string {
+	if s.PublicURL != "" {
+		return strings.TrimSuffix(s.PublicURL, "/")
+	}
+	return baseURL(r)
+}
+
+// eventURL links to an event page
+func (o compareExportOptions) eventURL(id int64) string {
+	return eventLink(o.BaseURL, id)
+}
+
+// eventLink is the URL of an event page under base
+func eventLink(base string, id int64) string {
+	return fmt.Sprintf("%s/event/%d", base, id)
+}
@@ -0,0 +1,112 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// compareExportTestServer has one archive of two events, the first with
+// camera details
+func compareExportTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := newTestServer(t)
+	s.ExportImages = DefaultExportImageConfig()
+	ctx := context.Background()
+	for _, body := range []string{`{"plateUTF8":"XC1"}`, `{"plateUTF8":"XC2"}`} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.background.Wait()
+	s.DB.Exec(`UPDATE events SET plate_confidence = 0.87, confidence_mmr = '0.5', camera_serial = 'CAM7', camera_ip = '10.0.0.7',
+		plate_country = 'DEU', plate_region_code = 'BY', geotag_lat = 48.1, geotag_lon = 11.5 WHERE id = 1`)
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('trial')")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+	return s
+}
+
+// compareExport runs the XLSX export of archive 1
+func compareExport(t *testing.T, s *Server, target string) (*excelize.File, int) {
+	t.Helper()
+	r := httptest.NewRequest("GET", target, nil)
+	r.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	s.HandleCompareExport(w, r)
+	if w.Code != http.StatusOK {
+		return nil, w.Code
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f, w.Code
+}
+
+func TestExportColumns(t *testing.T) {
+	s := compareExportTestServer(t)
+
+	f, _ := compareExport(t, s, "/archive/1/compare/export?columns=plate_confidence,camera,country,geotag")
+	if f == nil {
+		t.Fatal("export failed")
+	}
+	sheet := f.GetSheetName(0)
+	rows, _ := f.GetRows(sheet)
+	want := map[string][]string{
+		"J": {"PLATE_CONFIDENCE", "", "0.87"},
+		"K": {"CAMERA_SERIAL", "", "CAM7"},
+		"L": {"COUNTRY", "", "DEU / BY"},
+		"M": {"GEOTAG", "", "48.100000, 11.500000"},
+	}
+	// Newest event first: row 3 is event 1, row 2 event 2 without details
+	for col, cells := range want {
+		for i, v := range cells {
+			cell := col + string(rune('1'+i))
+			if got, _ := f.GetCellValue(sheet, cell); got != v {
+				t.Errorf("%s = %q, want %q", cell, got, v)
+			}
+		}
+	}
+	if len(rows[0]) != 13 {
+		t.Errorf("%d columns: %v", len(rows[0]), rows[0])
+	}
+
+	if f, _ := compareExport(t, s, "/archive/1/compare/export?columns=all"); f == nil {
+		t.Error("columns=all failed")
+	} else if rows, _ := f.GetRows(f.GetSheetName(0)); len(rows[0]) != 9+len(exportColumns) {
+		t.Errorf("all columns: %v", rows[0])
+	}
+	if f, _ := compareExport(t, s, "/archive/1/compare/export"); f == nil {
+		t.Error("default export failed")
+	} else if rows, _ := f.GetRows(f.GetSheetName(0)); len(rows[0]) != 9 {
+		t.Errorf("default columns: %v", rows[0])
+	}
+	if _, code := compareExport(t, s, "/archive/1/compare/export?columns=plate_confidence,speed"); code != http.StatusBadRequest {
+		t.Errorf("unknown column: %d", code)
+	}
+}
+
+func TestExportEventLinks(t *testing.T) {
+	s := compareExportTestServer(t)
+
+	// Without -public-url links use the host the export was requested from
+	f, _ := compareExport(t, s, "/archive/1/compare/export")
+	sheet := f.GetSheetName(0)
+	if v, _ := f.GetCellValue(sheet, "I3"); v != "1" {
+		t.Errorf("EVENT_ID = %q", v)
+	}
+	if ok, link, _ := f.GetCellHyperLink(sheet, "I3"); !ok || link != "http://example.com/event/1" {
+		t.Errorf("link %v %q", ok, link)
+	}
+
+	s.PublicURL = "https://lpr.example.org/"
+	f, _ = compareExport(t, s, "/archive/1/compare/export")
+	if ok, link, _ := f.GetCellHyperLink(sheet, "I2"); !ok || link != "https://lpr.example.org/event/2" {
+		t.Errorf("public link %v %q", ok, link)
+	}
+}
@@ -0,0 +1,175 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/url"
+	"runtime"
+	"strconv"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// ExportImageConfig controls images embedded into XLSX exports. Images
+// larger than MaxPixels on their longest side are downscaled before
+// embedding, and their scale is raised to match, so they take the same
+// space on the sheet with a fraction of the bytes: a 4K overview frame per
+// row otherwise pushes large archives past 1 GB. Images are loaded and
+// downscaled by Workers goroutines ahead of the sheet writer, which adds
+// them in row order.
+type ExportImageConfig struct {
+	PlateScale   float64 // LP_CROP picture scale
+	VehicleScale float64 // VEHICLE picture scale
+	MaxPixels    int     // longest side of embedded images; 0 keeps originals
+	Workers      int     // rows whose images are prepared in parallel; 0 or 1 is one at a time
+}
+
+// DefaultExportImageConfig keeps the sheet layout of earlier exports
+func DefaultExportImageConfig() ExportImageConfig {
+	return ExportImageConfig{PlateScale: 0.3, VehicleScale: 0.15, MaxPixels: 1920, Workers: min(runtime.NumCPU(), 8)}
+}
+
+const maxExportScale = 4
+
+// Validate checks the scales and size limit
+func (c ExportImageConfig) Validate() error {
+	for _, sc := range []float64{c.PlateScale, c.VehicleScale} {
+		if sc <= 0 || sc > maxExportScale {
+			return fmt.Errorf("image scale must be between 0 and %d", maxExportScale)
+		}
+	}
+	if c.MaxPixels < 0 {
+		return fmt.Errorf("max pixels must not be negative")
+	}
+	if c.Workers < 0 || c.Workers > 64 {
+		return fmt.Errorf("workers must be between 0 and 64")
+	}
+	return nil
+}
+
+// withQuery overrides the config from ?plate_scale=, ?vehicle_scale= and
+// ?max_px= (0 keeps original images)
+func (c ExportImageConfig) withQuery(q url.Values) (ExportImageConfig, error) {
+	for name, dst := range map[string]*float64{"plate_scale": &c.PlateScale, "vehicle_scale": &c.VehicleScale} {
+		if v := q.Get(name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return c, fmt.Errorf("invalid %s", name)
+			}
+			*dst = f
+		}
+	}
+	if v := q.Get("max_px"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid max_px")
+		}
+		c.MaxPixels = n
+	}
+	return c, c.Validate()
+}
+
+// fit returns the image to embed and its picture scale: data downscaled to
+// MaxPixels with the scale raised by the same factor, or data as is
+func (c ExportImageConfig) fit(data []byte, scale float64) ([]byte, float64) {
+	if c.MaxPixels <= 0 {
+		return data, scale
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return data, scale
+	}
+	longest := max(cfg.Width, cfg.Height)
+	if longest <= c.MaxPixels {
+		return data, scale
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data, scale
+	}
+	width := cfg.Width * c.MaxPixels / longest
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(src, max(width, 1)), &jpeg.Options{Quality: 85}); err != nil {
+		return data, scale
+	}
+	return buf.Bytes(), scale * float64(cfg.Width) / float64(max(width, 1))
+}
+
+// exportPicture is an image ready to embed
+type exportPicture struct {
+	data  []byte
+	scale float64
+}
+
+// rowPictures are the LP_CROP and VEHICLE images of a row; nil if missing
+type rowPictures struct {
+	plate, vehicle *exportPicture
+}
+
+// picture loads an image and fits it for embedding
+func (s *Server) picture(ctx context.Context, q *dbgen.Queries, id int64, scale float64, c ExportImageConfig) *exportPicture {
+	if id <= 0 {
+		return nil
+	}
+	data, err := s.loadImage(ctx, q, id)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	data, scale = c.fit(data, scale)
+	return &exportPicture{data, scale}
+}
+
+// prefetchPictures prepares the images of the rows on Workers goroutines,
+// at most a few rows per worker ahead of the caller. next(i) returns the
+// images of row i and must be called in row order; it returns false once
+// ctx is done, which also stops the workers.
+func (s *Server) prefetchPictures(ctx context.Context, q *dbgen.Queries, events []dbgen.GetArchivedEventsRow, c ExportImageConfig) (next func(i int) (rowPictures, bool)) {
+	workers := max(c.Workers, 1)
+	results := make([]chan rowPictures, len(events))
+	for i := range results {
+		results[i] = make(chan rowPictures, 1)
+	}
+	ahead := make(chan struct{}, workers*4) // rows prepared but not yet taken
+	rows := make(chan int)
+	go func() {
+		defer close(rows)
+		for i := range events {
+			select {
+			case ahead <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case rows <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for range workers {
+		go func() {
+			for i := range rows {
+				e := events[i]
+				results[i] <- rowPictures{
+					plate:   s.picture(ctx, q, toInt64(e.PlateImageID), c.PlateScale, c),
+					vehicle: s.picture(ctx, q, toInt64(e.VehicleImageID), c.VehicleScale, c),
+				}
+			}
+		}()
+	}
+	return func(i int) (rowPictures, bool) {
+		if ctx.Err() != nil {
+			return rowPictures{}, false
+		}
+		select {
+		case p := <-results[i]:
+			<-ahead
+			return p, true
+		case <-ctx.Done():
+			return rowPictures{}, false
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+	"net/url"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestExportImageFit(t *testing.T) {
+	var src bytes.Buffer
+	jpeg.Encode(&src, image.NewRGBA(image.Rect(0, 0, 800, 400)), nil)
+
+	c := ExportImageConfig{PlateScale: 0.3, VehicleScale: 0.15, MaxPixels: 200}
+	data, scale := c.fit(src.Bytes(), 0.15)
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 200 || cfg.Height != 100 {
+		t.Errorf("downscaled to %dx%d, want 200x100", cfg.Width, cfg.Height)
+	}
+	// Same size on the sheet: 800 px × 0.15 = 200 px × 0.6
+	if math.Abs(scale-0.6) > 1e-9 {
+		t.Errorf("scale = %v, want 0.6", scale)
+	}
+
+	c.MaxPixels = 0
+	if data, scale := c.fit(src.Bytes(), 0.15); !bytes.Equal(data, src.Bytes()) || scale != 0.15 {
+		t.Error("max 0 should keep the original")
+	}
+
+	if _, err := c.withQuery(url.Values{"plate_scale": {"0"}}); err == nil {
+		t.Error("zero scale accepted")
+	}
+	got, err := c.withQuery(url.Values{"vehicle_scale": {"0.5"}, "max_px": {"1024"}})
+	if err != nil || got.VehicleScale != 0.5 || got.MaxPixels != 1024 || got.PlateScale != 0.3 {
+		t.Errorf("withQuery = %+v, %v", got, err)
+	}
+}
+
+func TestPrefetchPictures(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	const rows = 40
+	for i := range rows {
+		images := []uploadedImage{{Filename: "plate.png", Data: pngOf(100+i, 20)}, {Filename: "vehicle.png", Data: pngOf(300+i, 200)}}
+		if i%7 == 0 {
+			images = images[1:]
+		}
+		body := fmt.Sprintf(`{"carID":"%d","plateUTF8":"AB%d"}`, i, i)
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", images)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('a')")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+	q := dbgen.New(s.DB)
+	events, err := q.GetArchivedEvents(ctx, ptr(int64(1)))
+	if err != nil || len(events) != rows {
+		t.Fatalf("%d events, %v", len(events), err)
+	}
+
+	width := func(p *exportPicture) int {
+		if p == nil {
+			return 0
+		}
+		cfg, _, _ := image.DecodeConfig(bytes.NewReader(p.data))
+		return cfg.Width
+	}
+	// Rows come back in order however the workers finish
+	next := s.prefetchPictures(ctx, q, events, ExportImageConfig{PlateScale: 0.3, VehicleScale: 0.15, Workers: 4})
+	for i, e := range events {
+		p, ok := next(i)
+		if !ok {
+			t.Fatalf("row %d: not ok", i)
+		}
+		var n int
+		fmt.Sscan(e.CarID, &n)
+		wantPlate := 100 + n
+		if n%7 == 0 {
+			// Without a plate image the second image stands in, if any
+			wantPlate = 0
+		}
+		if got := width(p.vehicle); got != 300+n {
+			t.Errorf("row %d vehicle width %d, want %d", i, got, 300+n)
+		}
+		if got := width(p.plate); got != wantPlate {
+			t.Errorf("row %d plate width %d, want %d", i, got, wantPlate)
+		}
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	next = s.prefetchPictures(cctx, q, events, ExportImageConfig{PlateScale: 0.3, VehicleScale: 0.15, Workers: 2})
+	next(0)
+	cancel()
+	for i := 1; i < rows; i++ {
+		if _, ok := next(i); !ok {
+			return
+		}
+	}
+	t.Error("prefetch kept going after cancel")
+}
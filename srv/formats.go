@@ -0,0 +1,186 @@
+package srv
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Every camera platform shapes its uploads differently. GET /api/formats
+// describes what /api accepts, for integrators and their tooling: the body
+// types, the vendor formats, how multipart parts are recognized, the JSON
+// keys read into each column and the embedded image array.
+
+// DefaultJSONFields are the multipart form fields read as event JSON
+var DefaultJSONFields = []string{"json", "data"}
+
+// ingestField is an events column and the camera JSON keys it is read from,
+// in order of precedence. Dotted keys are nested objects.
+type ingestField struct {
+	Column string   `json:"column"`
+	Keys   []string `json:"keys"`
+	Type   string   `json:"type"`
+	Note   string   `json:"note,omitempty"`
+
+	value func(dbgen.InsertEventParams) any
+}
+
+var ingestFields = []ingestField{
+	{"car_id", []string{"carID", "carid", "carId"}, "string", "ties carState messages of one car together; generated when missing",
+		func(p dbgen.InsertEventParams) any { return p.CarID }},
+	{"plate_utf8", []string{"plateUTF8", "plateText"}, "string", "empty means unrecognized",
+		func(p dbgen.InsertEventParams) any { return p.PlateUtf8 }},
+	{"car_state", []string{"carState", "carstate"}, "string", "new, update or lost; other than new merges into the open event",
+		func(p dbgen.InsertEventParams) any { return p.CarState }},
+	{"sensor_provider_id", []string{"sensorProviderID"}, "string", "camera identity when camera_info.SerialNumber is missing",
+		func(p dbgen.InsertEventParams) any { return p.SensorProviderID }},
+	{"event_datetime", []string{"datetime"}, "timestamp", "capture time fallback",
+		func(p dbgen.InsertEventParams) any { return p.EventDatetime }},
+	{"capture_timestamp", []string{"capture_timestamp"}, "timestamp", "capture time: RFC 3339, local time or Unix seconds/ms/µs/ns",
+		func(p dbgen.InsertEventParams) any { return p.CaptureTimestamp }},
+	{"plate_country", []string{"plateCountry"}, "string", "",
+		func(p dbgen.InsertEventParams) any { return p.PlateCountry }},
+	{"plate_region", []string{"plateRegion"}, "string", "",
+		func(p dbgen.InsertEventParams) any { return p.PlateRegion }},
+	{"plate_region_code", []string{"plateRegionCode"}, "string", "",
+		func(p dbgen.InsertEventParams) any { return p.PlateRegionCode }},
+	{"plate_confidence", []string{"plateConfidence"}, "number as string", "dropped if not a number",
+		func(p dbgen.InsertEventParams) any { return p.PlateConfidence }},
+	{"geotag_lat", []string{"geotag.lat"}, "number", "",
+		func(p dbgen.InsertEventParams) any { return p.GeotagLat }},
+	{"geotag_lon", []string{"geotag.lon"}, "number", "",
+		func(p dbgen.InsertEventParams) any { return p.GeotagLon }},
+	{"vehicle_make", []string{"vehicle_info.make"}, "string", "",
+		func(p dbgen.InsertEventParams) any { return p.VehicleMake }},
+	{"vehicle_model", []string{"vehicle_info.model"}, "string", "",
+		func(p dbgen.InsertEventParams) any { return p.VehicleModel }},
+	{"vehicle_color", []string{"vehicle_info.color"}, "string", "",
+		func(p dbgen.InsertEventParams) any { return p.VehicleColor }},
+	{"vehicle_type", []string{"vehicle_info.type"}, "string", "",
+		func(p dbgen.InsertEventParams) any { return p.VehicleType }},
+	{"confidence_mmr", []string{"vehicle_info.confidenceMMR"}, "string", "",
+		func(p dbgen.InsertEventParams) any { return p.ConfidenceMmr }},
+	{"confidence_color", []string{"vehicle_info.confidenceColor"}, "string", "",
+		func(p dbgen.InsertEventParams) any { return p.ConfidenceColor }},
+	{"camera_serial", []string{"camera_info.SerialNumber"}, "string", "camera identity",
+		func(p dbgen.InsertEventParams) any { return p.CameraSerial }},
+	{"camera_ip", []string{"camera_info.IPAddress"}, "string", "",
+		func(p dbgen.InsertEventParams) any { return p.CameraIp }},
+	{"lane", []string{"lane"}, "integer as string", "lane number on multi-lane cameras; dropped if not a number",
+		func(p dbgen.InsertEventParams) any { return p.Lane }},
+	{"direction", []string{"direction"}, "string", "e.g. approaching or leaving",
+		func(p dbgen.InsertEventParams) any { return p.Direction }},
+}
+
+// jsonFields returns the multipart form fields read as event JSON
+func (s *Server) jsonFields() []string {
+	if len(s.JSONFields) > 0 {
+		return s.JSONFields
+	}
+	return DefaultJSONFields
+}
+
+// isJSONField reports whether a multipart form field name carries event JSON
+func (s *Server) isJSONField(name string) bool {
+	for _, f := range s.jsonFields() {
+		if strings.EqualFold(f, name) {
+			return true
+		}
+	}
+	return false
+}
+
+type formatBody struct {
+	ContentType string `json:"content_type"`
+	Description string `json:"description"`
+}
+
+type formatParts struct {
+	JSONFields       []string `json:"json_fields"`
+	JSONExtensions   []string `json:"json_extensions"`
+	JSONContentTypes []string `json:"json_content_types"`
+	ImageExtensions  []string `json:"image_extensions"`
+	ImageTypes       []string `json:"image_content_types"`
+	Rules            []string `json:"rules"`
+}
+
+type formatImage struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	Note string `json:"note,omitempty"`
+}
+
+type formatsDoc struct {
+	Endpoints  map[string]string `json:"endpoints"`
+	Vendors    []string          `json:"vendors"`
+	Bodies     []formatBody      `json:"bodies"`
+	Multipart  formatParts       `json:"multipart"`
+	Fields     []ingestField     `json:"fields"`
+	ImageArray []formatImage     `json:"image_array"`
+	ImageTypes map[string]string `json:"image_types"`
+	Compat     []string          `json:"compat_paths,omitempty"`
+	Mappings   []*FieldMapping   `json:"mappings,omitempty"`
+}
+
+// HandleFormats describes the upload formats /api accepts
+func (s *Server) HandleFormats(w http.ResponseWriter, r *http.Request) {
+	doc := formatsDoc{
+		Endpoints: map[string]string{
+			"POST /api":           "one event in any vendor format, picked by ?vendor= (see vendors) or recognized from the payload",
+			"POST /api/stream":    "NDJSON, one event per line",
+			"POST /api/validate":  "dry run: what /api would store, unknown fields and warnings",
+			"POST /api/hikvision": "Hikvision ISAPI alarms: anpr.xml with licensePlatePicture.jpg/detectionPicture.jpg; other alarms and heartbeats are acknowledged and dropped",
+			"POST /api/dahua":     "Dahua ITC uploads (JSON with Picture.Plate/Vehicle/SnapInfo and base64 pictures) and event manager pushes (Events[0].TrafficCar.* as form or JSON, pictures as files); keepalives are acknowledged and dropped",
+		},
+		Vendors: s.vendorNames(),
+		Bodies: []formatBody{
+			{"application/json", "the event JSON as the body"},
+			{"multipart/form-data", "event JSON and images as parts, in any order"},
+			{"application/x-www-form-urlencoded", "flat key/value pairs: the keys below (or their column names, any case, dotted for nested objects) become event JSON; a json_fields field holding a JSON object is used as is"},
+			{"application/xml, text/xml", "an XML event (Axis, Vaxtor): Axis SimpleItem Name/Value pairs become elements; leaf elements and attributes become keys, named like the keys below or common XML names (plate, country, make, serialNumber, ...); base64 JPEG/PNG in image elements go to ImageArray; also detected by a leading <"},
+			{"image/jpeg, image/png", "a bare image from a trigger without recognition, stored as unrecognized"},
+		},
+		Multipart: formatParts{
+			JSONFields:       s.jsonFields(),
+			JSONExtensions:   []string{".json", ".xml"},
+			JSONContentTypes: []string{"application/json", "application/xml", "text/xml"},
+			ImageExtensions:  []string{".jpg", ".jpeg", ".png"},
+			ImageTypes:       []string{"image/jpeg", "image/png"},
+			Rules: []string{
+				"a part is event JSON if its filename ends in .json, its Content-Type is application/json or its field name is one of json_fields; .xml and XML content types are XML events",
+				"a part is an image if its filename or Content-Type says so, or its content is a JPEG or PNG",
+				"without such a part, the first form field holding a JSON object or XML document is the event",
+				"images keep their part order, which breaks ties when picking the plate and vehicle image",
+				"an image's type comes from its filename, else its field name: lpup/plate means plate, roi/vehicle means vehicle",
+				"other parts are ignored",
+			},
+		},
+		Fields: ingestFields,
+		ImageArray: []formatImage{
+			{"ImageArray[].BinaryImage", "base64", "skipped when empty"},
+			{"ImageArray[].ImageType", "string", "plate, vehicle or any tag; default embedded"},
+			{"ImageArray[].ImageFormat", "string", "file extension; default jpg"},
+			{"ImageArray[].Timestamp", "timestamp", "frame capture time"},
+			{"ImageArray[].ChunkIndex", "number", "slice of a BinaryImage split across messages, from 0"},
+			{"ImageArray[].ChunkCount", "number", "slices of a split BinaryImage; messages with the same carID and packetCounter are joined"},
+		},
+		ImageTypes: map[string]string{
+			"plate":    "plate crop",
+			"vehicle":  "overview frame",
+			"uploaded": "untagged multipart image; classified by shape",
+			"embedded": "untagged ImageArray image; classified by shape",
+		},
+	}
+	if s.Mappings != nil {
+		doc.Mappings = s.Mappings.Mappings
+	}
+	if s.Compat != nil {
+		for _, route := range s.Compat.Routes {
+			doc.Compat = append(doc.Compat, route.Path)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
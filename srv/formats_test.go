@@ -0,0 +1,98 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/textproto"
+	"slices"
+	"testing"
+)
+
+func TestReadUploadParts(t *testing.T) {
+	var pngData bytes.Buffer
+	png.Encode(&pngData, image.NewGray(image.Rect(0, 0, 8, 8)))
+	// Parts are field, filename, content type and data
+	upload := func(parts ...[4]string) (*bytes.Buffer, string) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for _, p := range parts {
+			h := textproto.MIMEHeader{}
+			disposition := `form-data; name="` + p[0] + `"`
+			if p[1] != "" {
+				disposition += `; filename="` + p[1] + `"`
+			}
+			h.Set("Content-Disposition", disposition)
+			if p[2] != "" {
+				h.Set("Content-Type", p[2])
+			}
+			w, _ := mw.CreatePart(h)
+			w.Write([]byte(p[3]))
+		}
+		mw.Close()
+		return &body, mw.FormDataContentType()
+	}
+	img := pngData.String()
+
+	for _, tc := range []struct {
+		name    string
+		fields  []string
+		parts   [][4]string
+		json    string
+		images  []string // filename:type
+		ignored []string
+	}{
+		{"images before JSON, order kept", nil, [][4]string{
+			{"b", "roi.jpg", "", img}, {"a", "lpup.jpg", "", img}, {"json", "", "", `{"plateUTF8":"A1"}`},
+		}, `{"plateUTF8":"A1"}`, []string{"roi.jpg:vehicle", "lpup.jpg:plate"}, nil},
+		{"configured field name", []string{"metadata"}, [][4]string{
+			{"metadata", "", "", `{"plateUTF8":"A2"}`}, {"json", "", "", `{"plateUTF8":"no"}`},
+		}, `{"plateUTF8":"A2"}`, nil, nil},
+		{"content types and sniffing", nil, [][4]string{
+			{"event", "event.txt", "application/json; charset=utf-8", `{"plateUTF8":"A3"}`},
+			{"plateImage", "", "", img}, {"snapshot", "blob.bin", "application/octet-stream", img},
+			{"note", "readme.txt", "text/plain", "hi"},
+		}, `{"plateUTF8":"A3"}`, []string{"plateImage.png:plate", "blob.bin:uploaded"}, []string{"readme.txt"}},
+		{"JSON object under an unknown name", nil, [][4]string{
+			{"camera", "", "", "CAM1"}, {"payload", "", "", ` {"plateUTF8":"A4"}`},
+		}, ` {"plateUTF8":"A4"}`, nil, []string{"camera"}},
+	} {
+		s := &Server{JSONFields: tc.fields}
+		body, contentType := upload(tc.parts...)
+		r := httptest.NewRequest("POST", "/api", body)
+		r.Header.Set("Content-Type", contentType)
+		req, ignored, err := s.readUpload(r, "")
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		var images []string
+		for _, img := range req.Images {
+			images = append(images, img.Filename+":"+img.imageType())
+		}
+		if string(req.RawJSON) != tc.json || !slices.Equal(images, tc.images) || !slices.Equal(ignored, tc.ignored) {
+			t.Errorf("%s: json %s, images %v, ignored %v", tc.name, req.RawJSON, images, ignored)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	s := &Server{JSONFields: []string{"event"}}
+	w := httptest.NewRecorder()
+	s.HandleFormats(w, httptest.NewRequest("GET", "/api/formats", nil))
+	var doc struct {
+		Multipart struct {
+			JSONFields []string `json:"json_fields"`
+		} `json:"multipart"`
+		Fields []ingestField `json:"fields"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(doc.Multipart.JSONFields, []string{"event"}) || len(doc.Fields) != len(ingestFields) || doc.Fields[0].Column != "car_id" {
+		t.Errorf("formats = %s", w.Body)
+	}
+}
@@ -0,0 +1,78 @@
+package srv
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// eventFrame is one image in the event page's sequence viewer
+type eventFrame struct {
+	Number int // position in the sequence, from 1
+	ID     int64
+	Type   string // camera tag, or the classified type of untagged images
+	Time   *time.Time
+	Offset string // relative to the event's capture time, e.g. "-120 ms"
+	Size   string
+	Used   string // "plate" and/or "vehicle" when lists and exports show this frame
+}
+
+// eventFrames orders an event's images for the sequence viewer: by frame
+// capture time when every frame has one, else in upload order. Deleted
+// images are left out.
+func eventFrames(event dbgen.Event, images []dbgen.GetImagesByEventIDRow, plateID, vehicleID int64) []eventFrame {
+	frames := make([]eventFrame, 0, len(images))
+	timed := true
+	for _, img := range images {
+		if img.DeletedAt != nil {
+			continue
+		}
+		f := eventFrame{ID: img.ID, Type: deref(img.ImageType), Time: img.CapturedAt}
+		if !taggedType(img.ImageType) && img.ClassifiedType != nil {
+			f.Type = *img.ClassifiedType
+		}
+		if img.Width != nil && img.Height != nil {
+			f.Size = fmt.Sprintf("%d×%d", *img.Width, *img.Height)
+		}
+		var used []string
+		if img.ID == plateID {
+			used = append(used, "plate")
+		}
+		if img.ID == vehicleID {
+			used = append(used, "vehicle")
+		}
+		f.Used = strings.Join(used, ", ")
+		timed = timed && f.Time != nil
+		frames = append(frames, f)
+	}
+	if timed && len(frames) > 0 {
+		sort.SliceStable(frames, func(i, j int) bool { return frames[i].Time.Before(*frames[j].Time) })
+		ref := *frames[0].Time
+		if event.CapturedAt != nil {
+			ref = *event.CapturedAt
+		}
+		for i := range frames {
+			frames[i].Offset = frameOffset(frames[i].Time.Sub(ref))
+		}
+	}
+	for i := range frames {
+		frames[i].Number = i + 1
+	}
+	return frames
+}
+
+// frameOffset formats the time between frames in milliseconds, or seconds
+// past ten
+func frameOffset(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	if d < 10*time.Second {
+		return fmt.Sprintf("%s%d ms", sign, d.Milliseconds())
+	}
+	return fmt.Sprintf("%s%.1f s", sign, d.Seconds())
+}
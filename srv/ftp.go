@@ -0,0 +1,372 @@
+package srv
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The embedded FTP server only takes uploads into the inbox: cameras log in
+// with the configured account, may create directories, and STOR files,
+// which appear in the inbox under their final name once complete. Passive
+// (PASV/EPSV) and active (PORT, to the camera's own address only) data
+// connections are supported; TLS is not, so keep it on the camera network.
+
+// ftpMaxFile bounds the size of an uploaded file
+const ftpMaxFile = 64 << 20
+
+// ftpIdleTimeout closes control connections without a command for this long
+const ftpIdleTimeout = 5 * time.Minute
+
+// listenFTP opens the embedded FTP server's control port
+func (s *Server) listenFTP() (net.Listener, error) {
+	return net.Listen("tcp", s.Inbox.FTPAddr)
+}
+
+// runFTP serves FTP sessions until ctx is done
+func (s *Server) runFTP(ctx context.Context, ln net.Listener) {
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	slog.Info("ftp server listening", "addr", ln.Addr().String(), "inbox", s.Inbox.Dir)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("ftp accept failed", "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		s.background.Go(func() {
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stop()
+			defer conn.Close()
+			sess := &ftpSession{s: s, c: s.Inbox, conn: conn, r: bufio.NewReader(conn), cwd: "/"}
+			if err := sess.serve(); err != nil && !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				slog.Debug("ftp session ended", "remote", conn.RemoteAddr().String(), "error", err)
+			}
+			sess.closeData()
+		})
+	}
+}
+
+// ftpSession is one camera's control connection
+type ftpSession struct {
+	s    *Server
+	c    *InboxConfig
+	conn net.Conn
+	r    *bufio.Reader
+
+	user       string
+	loggedIn   bool
+	cwd        string       // slash path within the inbox
+	pasv       net.Listener // passive data listener, if any
+	active     string       // PORT address, if any
+	renameFrom string
+}
+
+func (f *ftpSession) reply(code int, msg string) error {
+	_, err := fmt.Fprintf(f.conn, "%d %s\r\n", code, msg)
+	return err
+}
+
+// serve answers commands until the camera quits or the connection fails
+func (f *ftpSession) serve() error {
+	if err := f.reply(220, "mmrapi inbox ready"); err != nil {
+		return err
+	}
+	for {
+		f.conn.SetReadDeadline(time.Now().Add(ftpIdleTimeout))
+		line, err := f.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		cmd = strings.ToUpper(cmd)
+		if cmd == "QUIT" {
+			return f.reply(221, "bye")
+		}
+		if err := f.command(cmd, arg); err != nil {
+			return err
+		}
+	}
+}
+
+// command handles one command; only write errors on the control
+// connection are returned
+func (f *ftpSession) command(cmd, arg string) error {
+	switch cmd {
+	case "USER":
+		f.user, f.loggedIn = arg, false
+		return f.reply(331, "password required")
+	case "PASS":
+		userOK := subtle.ConstantTimeCompare([]byte(f.user), []byte(f.c.Username)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(arg), []byte(f.c.Password)) == 1
+		if !userOK || !passOK {
+			slog.Warn("ftp login failed", "remote", f.conn.RemoteAddr().String(), "user", f.user)
+			return f.reply(530, "login incorrect")
+		}
+		f.loggedIn = true
+		return f.reply(230, "logged in")
+	case "SYST":
+		return f.reply(215, "UNIX Type: L8")
+	case "FEAT":
+		_, err := io.WriteString(f.conn, "211-Features:\r\n EPSV\r\n PASV\r\n SIZE\r\n UTF8\r\n211 End\r\n")
+		return err
+	case "NOOP":
+		return f.reply(200, "ok")
+	}
+	if !f.loggedIn {
+		return f.reply(530, "log in first")
+	}
+
+	switch cmd {
+	case "OPTS", "MODE", "STRU", "TYPE":
+		return f.reply(200, "ok")
+	case "ALLO":
+		return f.reply(202, "not needed")
+	case "PWD", "XPWD":
+		return f.reply(257, strconv.Quote(f.cwd))
+	case "CWD", "XCWD", "CDUP", "XCUP":
+		if cmd == "CDUP" || cmd == "XCUP" {
+			arg = ".."
+		}
+		p := f.clean(arg)
+		if info, err := os.Stat(f.local(p)); err != nil || !info.IsDir() {
+			return f.reply(550, "no such directory")
+		}
+		f.cwd = p
+		return f.reply(250, "ok")
+	case "MKD", "XMKD":
+		p := f.clean(arg)
+		if err := os.MkdirAll(f.local(p), 0755); err != nil {
+			return f.reply(550, "can't create directory")
+		}
+		return f.reply(257, strconv.Quote(p)+" created")
+	case "RMD", "XRMD":
+		if err := os.Remove(f.local(f.clean(arg))); err != nil {
+			return f.reply(550, "can't remove directory")
+		}
+		return f.reply(250, "ok")
+	case "DELE":
+		if err := os.Remove(f.local(f.clean(arg))); err != nil {
+			return f.reply(550, "can't delete file")
+		}
+		return f.reply(250, "ok")
+	case "SIZE":
+		info, err := os.Stat(f.local(f.clean(arg)))
+		if err != nil || info.IsDir() {
+			return f.reply(550, "no such file")
+		}
+		return f.reply(213, strconv.FormatInt(info.Size(), 10))
+	case "RNFR":
+		f.renameFrom = f.local(f.clean(arg))
+		if _, err := os.Stat(f.renameFrom); err != nil {
+			f.renameFrom = ""
+			return f.reply(550, "no such file")
+		}
+		return f.reply(350, "ready for RNTO")
+	case "RNTO":
+		from := f.renameFrom
+		f.renameFrom = ""
+		if from == "" {
+			return f.reply(503, "RNFR first")
+		}
+		if err := os.Rename(from, f.local(f.clean(arg))); err != nil {
+			return f.reply(550, "can't rename")
+		}
+		return f.reply(250, "ok")
+	case "PASV", "EPSV":
+		return f.passive(cmd == "EPSV")
+	case "PORT":
+		return f.port(arg)
+	case "STOR":
+		return f.store(arg)
+	case "LIST", "NLST":
+		return f.list(cmd == "LIST")
+	}
+	return f.reply(502, "command not implemented")
+}
+
+// clean resolves a path argument against the working directory
+func (f *ftpSession) clean(p string) string {
+	if !path.IsAbs(p) {
+		p = path.Join(f.cwd, p)
+	}
+	return path.Clean("/" + p)
+}
+
+// local is the inbox file of a cleaned path
+func (f *ftpSession) local(p string) string {
+	return filepath.Join(f.c.Dir, filepath.FromSlash(p))
+}
+
+func (f *ftpSession) closeData() {
+	if f.pasv != nil {
+		f.pasv.Close()
+		f.pasv = nil
+	}
+	f.active = ""
+}
+
+// passive opens a data listener in the configured port range
+func (f *ftpSession) passive(extended bool) error {
+	f.closeData()
+	host, _, _ := net.SplitHostPort(f.conn.LocalAddr().String())
+	ports := []int{0}
+	if f.c.portLow > 0 {
+		ports = ports[:0]
+		for p := f.c.portLow; p <= f.c.portHigh; p++ {
+			ports = append(ports, p)
+		}
+	}
+	for _, p := range ports {
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(p)))
+		if err == nil {
+			f.pasv = ln
+			break
+		}
+	}
+	if f.pasv == nil {
+		return f.reply(425, "no free passive port")
+	}
+	port := f.pasv.Addr().(*net.TCPAddr).Port
+	if extended {
+		return f.reply(229, fmt.Sprintf("entering extended passive mode (|||%d|)", port))
+	}
+	ip := net.ParseIP(coalesce(f.c.PublicIP, host)).To4()
+	if ip == nil {
+		f.closeData()
+		return f.reply(425, "use EPSV with IPv6")
+	}
+	return f.reply(227, fmt.Sprintf("entering passive mode (%d,%d,%d,%d,%d,%d)", ip[0], ip[1], ip[2], ip[3], port>>8, port&0xff))
+}
+
+// port sets the active mode address, which must be the camera's own
+func (f *ftpSession) port(arg string) error {
+	f.closeData()
+	var h [6]int
+	if _, err := fmt.Sscanf(arg, "%d,%d,%d,%d,%d,%d", &h[0], &h[1], &h[2], &h[3], &h[4], &h[5]); err != nil {
+		return f.reply(501, "bad PORT argument")
+	}
+	ip := net.IPv4(byte(h[0]), byte(h[1]), byte(h[2]), byte(h[3]))
+	remote, _, _ := net.SplitHostPort(f.conn.RemoteAddr().String())
+	if !ip.Equal(net.ParseIP(remote)) {
+		return f.reply(501, "PORT must be the client's own address")
+	}
+	f.active = net.JoinHostPort(ip.String(), strconv.Itoa(h[4]<<8|h[5]))
+	return f.reply(200, "ok")
+}
+
+// dataConn opens the data connection set up by PASV or PORT. Like PORT,
+// a passive connection must come from the camera's own address; others
+// are closed and the wait goes on.
+func (f *ftpSession) dataConn() (net.Conn, error) {
+	defer f.closeData()
+	switch {
+	case f.pasv != nil:
+		f.pasv.(*net.TCPListener).SetDeadline(time.Now().Add(30 * time.Second))
+		remote, _, _ := net.SplitHostPort(f.conn.RemoteAddr().String())
+		for {
+			conn, err := f.pasv.Accept()
+			if err != nil {
+				return nil, err
+			}
+			ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+			if net.ParseIP(ip).Equal(net.ParseIP(remote)) {
+				return conn, nil
+			}
+			slog.Warn("ftp data connection from another address", "remote", conn.RemoteAddr(), "control", f.conn.RemoteAddr())
+			conn.Close()
+		}
+	case f.active != "":
+		return net.DialTimeout("tcp", f.active, 30*time.Second)
+	}
+	return nil, errors.New("use PASV or PORT first")
+}
+
+// store receives a file into a hidden temp file and renames it into place
+// once complete, so the inbox scan never reads a partial upload
+func (f *ftpSession) store(arg string) error {
+	target := f.local(f.clean(arg))
+	if err := f.reply(150, "ready"); err != nil {
+		return err
+	}
+	data, err := f.dataConn()
+	if err != nil {
+		return f.reply(425, err.Error())
+	}
+	defer data.Close()
+	data.SetDeadline(time.Now().Add(ftpIdleTimeout))
+
+	tmp := filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".part")
+	out, err := os.Create(tmp)
+	if err != nil {
+		return f.reply(553, "can't create file")
+	}
+	n, err := io.Copy(out, io.LimitReader(data, ftpMaxFile+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > ftpMaxFile {
+		err = fmt.Errorf("file exceeds %d bytes", ftpMaxFile)
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		slog.Warn("ftp upload failed", "file", target, "error", err)
+		return f.reply(552, "upload failed")
+	}
+	f.c.mu.Lock()
+	f.c.uploads++
+	f.c.mu.Unlock()
+	return f.reply(226, "stored")
+}
+
+// list sends the names in the working directory
+func (f *ftpSession) list(long bool) error {
+	entries, _ := os.ReadDir(f.local(f.cwd))
+	if err := f.reply(150, "listing"); err != nil {
+		return err
+	}
+	data, err := f.dataConn()
+	if err != nil {
+		return f.reply(425, err.Error())
+	}
+	w := bufio.NewWriter(data)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if !long {
+			fmt.Fprintf(w, "%s\r\n", e.Name())
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		mode := "-rw-r--r--"
+		if e.IsDir() {
+			mode = "drwxr-xr-x"
+		}
+		fmt.Fprintf(w, "%s 1 ftp ftp %d %s %s\r\n", mode, info.Size(), info.ModTime().Format("Jan _2 15:04"), e.Name())
+	}
+	w.Flush()
+	data.Close()
+	return f.reply(226, "done")
+}
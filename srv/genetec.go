@@ -0,0 +1,419 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// With -genetec-config the server bridges FF Group cameras into Genetec
+// Security Center: every stored camera read is posted to the LPR ingestion
+// endpoint as one JSON read with its best plate crop and vehicle image.
+// Delivery follows event ids and the last delivered id is kept in the
+// database, so reads stored while Security Center is unreachable (or the
+// server is down) are sent once it is back, in order. Reads the endpoint
+// rejects with a 4xx are logged and skipped; other failures are retried
+// with backoff.
+//
+//	{"url": "https://genetec:4590/WebSdk/lpr/reads", "username": "mmrapi",
+//	 "password": "...", "application_id": "...", "source": "Gate 1"}
+//
+// With application_id the Web SDK login "username;application_id:password"
+// is sent as basic auth; token sends a bearer token instead. Forwarding
+// starts with reads stored after it is set up unless backfill is set.
+// Imported events, duplicates and manual events aren't forwarded.
+
+const (
+	genetecCursor     = "genetec"
+	genetecBatch      = 50
+	genetecInterval   = 5 * time.Second
+	genetecMaxBackoff = 5 * time.Minute
+)
+
+// Images sent with a read
+const (
+	GenetecImagesAll   = "all"   // plate crop and vehicle image
+	GenetecImagesPlate = "plate" // plate crop only
+	GenetecImagesNone  = "none"
+)
+
+// GenetecConfig is the Security Center endpoint reads are forwarded to
+type GenetecConfig struct {
+	URL           string `json:"url"` // LPR ingestion endpoint reads are POSTed to
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	ApplicationID string `json:"application_id"` // Web SDK application id
+	Token         string `json:"token"`          // bearer token instead of a username
+	Source        string `json:"source"`         // read source name in Security Center (default: camera serial)
+	Images        string `json:"images"`         // all (default), plate or none
+	Backfill      bool   `json:"backfill"`       // also forward reads stored before forwarding was set up
+	DelaySeconds  int    `json:"delay_seconds"`  // wait until a read is this old, so merged messages go out as one
+	Timeout       int    `json:"timeout"`        // seconds per request (default 15)
+	SkipVerify    bool   `json:"insecure_skip_verify"`
+
+	client *http.Client
+
+	mu        sync.Mutex
+	forwarded int64
+	rejected  int64
+	lastSent  time.Time
+	lastErr   error
+}
+
+// LoadGenetecConfig reads a Genetec forwarding config file
+func LoadGenetecConfig(path string) (*GenetecConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg GenetecConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// compile checks the config and sets up the HTTP client
+func (c *GenetecConfig) compile() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("url must be an http:// or https:// URL")
+	}
+	if c.Token != "" && c.Username != "" {
+		return fmt.Errorf("set username or token, not both")
+	}
+	switch c.Images {
+	case "":
+		c.Images = GenetecImagesAll
+	case GenetecImagesAll, GenetecImagesPlate, GenetecImagesNone:
+	default:
+		return fmt.Errorf("unknown images %q (want all, plate or none)", c.Images)
+	}
+	if c.DelaySeconds < 0 {
+		return fmt.Errorf("delay_seconds must not be negative")
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 15
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.SkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	c.client = &http.Client{Timeout: time.Duration(c.Timeout) * time.Second, Transport: transport}
+	return nil
+}
+
+// authorize sets the request's credentials
+func (c *GenetecConfig) authorize(req *http.Request) {
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "":
+		user := c.Username
+		if c.ApplicationID != "" {
+			user += ";" + c.ApplicationID
+		}
+		req.SetBasicAuth(user, c.Password)
+	}
+}
+
+// genetecRead is the JSON posted for one read
+type genetecRead struct {
+	ReadID       string   `json:"ReadId"` // event UID; the same read sent again has the same id
+	Plate        string   `json:"Plate"`
+	PlateState   string   `json:"PlateState,omitempty"`
+	Country      string   `json:"Country,omitempty"`
+	Confidence   *float64 `json:"Confidence,omitempty"`
+	Timestamp    string   `json:"Timestamp"` // RFC 3339, UTC
+	Source       string   `json:"Source"`
+	CameraSerial string   `json:"CameraSerial,omitempty"`
+	CameraIP     string   `json:"CameraIp,omitempty"`
+	Latitude     *float64 `json:"Latitude,omitempty"`
+	Longitude    *float64 `json:"Longitude,omitempty"`
+	Lane         *int64   `json:"Lane,omitempty"`
+	Direction    string   `json:"Direction,omitempty"`
+	VehicleMake  string   `json:"VehicleMake,omitempty"`
+	VehicleModel string   `json:"VehicleModel,omitempty"`
+	VehicleColor string   `json:"VehicleColor,omitempty"`
+	VehicleType  string   `json:"VehicleType,omitempty"`
+	PlateImage   string   `json:"PlateImage,omitempty"`   // base64 JPEG/PNG
+	ContextImage string   `json:"ContextImage,omitempty"` // base64 JPEG/PNG
+}
+
+// genetecRead builds the read for an event, with its images per config
+func (s *Server) genetecRead(ctx context.Context, e dbgen.Event) (genetecRead, error) {
+	c := s.Genetec
+	at := e.CreatedAt
+	if e.CapturedAt != nil {
+		at = *e.CapturedAt
+	}
+	read := genetecRead{
+		ReadID:       coalesce(deref(e.Uid), fmt.Sprintf("%s-%d", deref(e.NodeID), e.ID)),
+		Plate:        coalesce(deref(e.ManualPlate), deref(e.PlateUtf8)),
+		PlateState:   coalesce(deref(e.PlateRegionCode), deref(e.PlateRegion)),
+		Country:      deref(e.PlateCountry),
+		Confidence:   e.PlateConfidence,
+		Timestamp:    at.UTC().Format(time.RFC3339Nano),
+		Source:       coalesce(c.Source, deref(e.CameraSerial), deref(e.SensorProviderID), deref(e.CameraIp), deref(e.NodeID)),
+		CameraSerial: deref(e.CameraSerial),
+		CameraIP:     deref(e.CameraIp),
+		Latitude:     e.GeotagLat,
+		Longitude:    e.GeotagLon,
+		Lane:         e.Lane,
+		Direction:    deref(e.Direction),
+		VehicleMake:  deref(e.VehicleMake),
+		VehicleModel: deref(e.VehicleModel),
+		VehicleColor: deref(e.VehicleColor),
+		VehicleType:  deref(e.VehicleType),
+	}
+	if c.Images == GenetecImagesNone {
+		return read, nil
+	}
+	// Best image of each kind first
+	images, err := dbgen.New(s.DB).GetImagesForOCR(ctx, e.ID)
+	if err != nil {
+		return read, err
+	}
+	for _, img := range images {
+		target := &read.PlateImage
+		if deref(img.ImageType) != "plate" {
+			if c.Images == GenetecImagesPlate {
+				continue
+			}
+			target = &read.ContextImage
+		}
+		if *target != "" {
+			continue
+		}
+		data, err := s.imageBytes(ctx, img.ImageData, img.DiskFilename)
+		if err != nil {
+			slog.Warn("genetec image unavailable", "event_id", e.ID, "image_id", img.ID, "error", err)
+			continue
+		}
+		*target = base64.StdEncoding.EncodeToString(data)
+	}
+	return read, nil
+}
+
+// genetecRejected is a read the endpoint refused; sending it again won't help
+type genetecRejected struct {
+	status string
+	body   string
+}
+
+func (e *genetecRejected) Error() string {
+	return fmt.Sprintf("genetec rejected the read: %s: %s", e.status, e.body)
+}
+
+// send posts one read
+func (c *GenetecConfig) send(ctx context.Context, read genetecRead) error {
+	body, err := json.Marshal(read)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden,
+		resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		// Credentials or the server itself: every read would fail the same way
+		return fmt.Errorf("genetec returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return &genetecRejected{status: resp.Status, body: strings.TrimSpace(string(msg))}
+}
+
+// genetecStart is the cursor forwarding starts at the first time: 0 with
+// backfill, else the newest event
+func (s *Server) genetecStart(ctx context.Context) (int64, error) {
+	q := dbgen.New(s.DB)
+	cursor, err := q.GetForwardCursor(ctx, genetecCursor)
+	if err == nil {
+		return cursor, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if !s.Genetec.Backfill {
+		if cursor, err = q.GetMaxEventID(ctx); err != nil {
+			return 0, err
+		}
+	}
+	err = q.SetForwardCursor(ctx, dbgen.SetForwardCursorParams{Name: genetecCursor, LastEventID: cursor, UpdatedAt: time.Now()})
+	return cursor, err
+}
+
+// forwardToGenetec sends the reads stored since the last delivered one and
+// returns how many events it got past, sent or skipped
+func (s *Server) forwardToGenetec(ctx context.Context) (int, error) {
+	c := s.Genetec
+	q := dbgen.New(s.DB)
+	cursor, err := s.genetecStart(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for {
+		events, err := q.GetEventsToForward(ctx, dbgen.GetEventsToForwardParams{Cursor: cursor, Limit: genetecBatch})
+		if err != nil {
+			return sent, err
+		}
+		settled := time.Now().Add(-time.Duration(c.DelaySeconds) * time.Second)
+		for _, e := range events {
+			changed := e.CreatedAt
+			if e.UpdatedAt != nil {
+				changed = *e.UpdatedAt
+			}
+			if c.DelaySeconds > 0 && changed.After(settled) {
+				// Later reads wait too, so they stay in order
+				return sent, nil
+			}
+			if err := s.forwardGenetecEvent(ctx, e); err != nil {
+				return sent, fmt.Errorf("event %d: %w", e.ID, err)
+			}
+			cursor = e.ID
+			if err := q.SetForwardCursor(ctx, dbgen.SetForwardCursorParams{Name: genetecCursor, LastEventID: cursor, UpdatedAt: time.Now()}); err != nil {
+				return sent, err
+			}
+			sent++
+		}
+		if len(events) < genetecBatch {
+			return sent, nil
+		}
+	}
+}
+
+// forwardGenetecEvent sends one event, or skips it if it has no plate or
+// the endpoint rejects it
+func (s *Server) forwardGenetecEvent(ctx context.Context, e dbgen.Event) error {
+	c := s.Genetec
+	read, err := s.genetecRead(ctx, e)
+	if err != nil {
+		return err
+	}
+	if read.Plate == "" {
+		return nil
+	}
+	err = c.send(ctx, read)
+	var rejected *genetecRejected
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case errors.As(err, &rejected):
+		slog.Warn("genetec rejected read; skipping it", "event_id", e.ID, "plate", read.Plate, "error", err)
+		c.rejected++
+		return nil
+	case err != nil:
+		return err
+	}
+	c.forwarded++
+	c.lastSent = time.Now()
+	return nil
+}
+
+// runGenetec forwards new reads until ctx is done, backing off while
+// Security Center is unreachable
+func (s *Server) runGenetec(ctx context.Context) {
+	c := s.Genetec
+	slog.Info("forwarding reads to genetec", "url", c.URL, "images", c.Images)
+	wait := genetecInterval
+	for {
+		n, err := s.forwardToGenetec(ctx)
+		c.mu.Lock()
+		c.lastErr = err
+		c.mu.Unlock()
+		switch {
+		case err != nil && ctx.Err() == nil:
+			slog.Warn("genetec forwarding failed", "error", err, "retry_in", wait)
+			wait = min(wait*2, genetecMaxBackoff)
+		case err == nil:
+			if n > 0 {
+				slog.Debug("forwarded reads to genetec", "count", n)
+			}
+			wait = genetecInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// genetecStatus is the GET /api/genetec response
+type genetecStatus struct {
+	URL        string     `json:"url"`
+	Images     string     `json:"images"`
+	LastID     int64      `json:"last_event_id"` // last event delivered or skipped
+	Pending    int64      `json:"pending"`
+	Forwarded  int64      `json:"forwarded"` // since the server started
+	Rejected   int64      `json:"rejected"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// HandleGenetecAPI reports how far forwarding to Genetec has come
+func (s *Server) HandleGenetecAPI(w http.ResponseWriter, r *http.Request) {
+	c := s.Genetec
+	if c == nil {
+		s.jsonError(w, "genetec forwarding is not configured (-genetec-config)", http.StatusNotFound)
+		return
+	}
+	q := dbgen.New(s.DB)
+	st := genetecStatus{URL: c.URL, Images: c.Images}
+	last, err := q.GetForwardCursor(r.Context(), genetecCursor)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	st.LastID = last
+	if err == nil {
+		if st.Pending, err = q.CountEventsToForward(r.Context(), last); err != nil {
+			s.jsonError(w, "database error", http.StatusInternalServerError)
+			return
+		}
+	}
+	c.mu.Lock()
+	st.Forwarded, st.Rejected = c.forwarded, c.rejected
+	if !c.lastSent.IsZero() {
+		st.LastSentAt = ptr(c.lastSent)
+	}
+	if c.lastErr != nil {
+		st.LastError = c.lastErr.Error()
+	}
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,102 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestForwardToGenetec(t *testing.T) {
+	s := newTestServer(t)
+
+	var mu sync.Mutex
+	var reads []genetecRead
+	var auth string
+	down := true
+	sc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		var read genetecRead
+		json.NewDecoder(r.Body).Decode(&read)
+		if read.Plate == "BAD" {
+			http.Error(w, "invalid plate", http.StatusUnprocessableEntity)
+			return
+		}
+		user, pass, _ := r.BasicAuth()
+		auth = user + ":" + pass
+		reads = append(reads, read)
+	}))
+	defer sc.Close()
+	s.Genetec = &GenetecConfig{URL: sc.URL, Username: "mmrapi", ApplicationID: "app-1", Password: "secret"}
+	if err := s.Genetec.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	ingest := func(body string, images ...uploadedImage) {
+		t.Helper()
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", images)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Reads stored before forwarding was set up stay here
+	ingest(`{"plateUTF8":"OLD1"}`)
+	if _, err := s.genetecStart(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var pic bytes.Buffer
+	png.Encode(&pic, image.NewGray(image.Rect(0, 0, 8, 8)))
+	ingest(`{"plateUTF8":"GEN1","plateRegionCode":"WA","lane":"2","camera_info":{"SerialNumber":"CAM9"}}`,
+		uploadedImage{Filename: "plate.png", Data: pic.Bytes()},
+		uploadedImage{Filename: "vehicle.png", Data: pic.Bytes()})
+	ingest(`{"plateUTF8":"BAD"}`)
+	ingest(`{"plateUTF8":""}`)
+	ingest(`{"plateUTF8":"GEN2"}`)
+
+	// Security Center down: nothing is lost
+	if n, err := s.forwardToGenetec(ctx); err == nil || n != 0 {
+		t.Fatalf("while down: %d, %v", n, err)
+	}
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	if n, err := s.forwardToGenetec(ctx); err != nil || n != 4 {
+		t.Fatalf("forward: %d, %v", n, err)
+	}
+	if len(reads) != 2 || reads[0].Plate != "GEN1" || reads[1].Plate != "GEN2" {
+		t.Fatalf("reads: %+v", reads)
+	}
+	r := reads[0]
+	if r.Source != "CAM9" || r.PlateState != "WA" || r.Lane == nil || *r.Lane != 2 || r.ReadID == "" {
+		t.Errorf("read: %+v", r)
+	}
+	if data, err := base64.StdEncoding.DecodeString(r.PlateImage); err != nil || !bytes.Equal(data, pic.Bytes()) || r.ContextImage == "" {
+		t.Errorf("images: plate %d bytes, context %d bytes", len(r.PlateImage), len(r.ContextImage))
+	}
+	if auth != "mmrapi;app-1:secret" {
+		t.Errorf("auth %q", auth)
+	}
+	// Nothing is sent twice
+	if n, err := s.forwardToGenetec(ctx); err != nil || n != 0 {
+		t.Errorf("again: %d, %v", n, err)
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleGenetecAPI(w, httptest.NewRequest("GET", "/api/genetec", nil))
+	var st genetecStatus
+	json.Unmarshal(w.Body.Bytes(), &st)
+	if st.Forwarded != 2 || st.Rejected != 1 || st.Pending != 0 || st.LastID != 5 {
+		t.Errorf("status: %s", w.Body)
+	}
+}
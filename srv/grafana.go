@@ -0,0 +1,394 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Grafana charts the server through the simple-JSON datasource contract
+// (Simple JSON, Infinity): point the datasource at https://host/grafana.
+// POST /grafana/search lists the metrics, POST /grafana/query returns them
+// for the dashboard's time range bucketed by its interval, by capture time
+// across current and archived events. A metric followed by ":CAMERA" is
+// limited to that camera (serial or sensor provider ID), e.g.
+// "events:cam-north". "camera_health" is a table. With -require-login the
+// datasource sends an API key as a Bearer token or X-API-Key header.
+
+// grafanaMetrics are the time series; all but the counts leave buckets
+// without data empty
+var grafanaMetrics = []string{
+	"events",             // events per bucket
+	"unrecognized",       // events without a plate read
+	"unrecognized_pct",   // share of events without a plate read
+	"plate_accuracy_pct", // plate reads not marked incorrect in reviewed archives
+	"maker_accuracy_pct",
+	"model_accuracy_pct",
+	"color_accuracy_pct",
+	"arrival_delay_ms", // average capture → receive delay
+	"active_cameras",   // cameras that sent an event in the bucket
+}
+
+// grafanaCameraTable is the table target with per-camera health
+const grafanaCameraTable = "camera_health"
+
+// grafanaMaxPoints bounds the buckets per series; wider ranges get longer
+// intervals
+const grafanaMaxPoints = 10000
+
+type grafanaQueryRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs    int64 `json:"intervalMs"`
+	MaxDataPoints int64 `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Type   string `json:"type"`
+	} `json:"targets"`
+}
+
+type grafanaSeries struct {
+	Target     string  `json:"target"`
+	Datapoints [][]any `json:"datapoints"` // [value, unix ms]
+}
+
+type grafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type grafanaTable struct {
+	Type    string          `json:"type"`
+	Columns []grafanaColumn `json:"columns"`
+	Rows    [][]any         `json:"rows"`
+}
+
+// grafanaEvent is an event reduced to what the metrics need
+type grafanaEvent struct {
+	At        time.Time
+	Camera    string
+	NoRead    bool // unrecognized
+	Reviewed  bool // in an archive with compare results
+	Incorrect map[string]bool
+	Manual    bool // source manual: every field counts as incorrect
+	Corrected bool // unrecognized, with a plate entered by a reviewer
+	DelayMs   *int64
+}
+
+// grafanaBucket aggregates the events of one interval
+type grafanaBucket struct {
+	events, noRead     int64
+	delaySum, delayN   int64
+	cameras            map[string]bool
+	correct, incorrect map[string]int64 // per compare field
+	last               time.Time
+}
+
+func (b *grafanaBucket) add(e grafanaEvent) {
+	b.events++
+	if e.NoRead {
+		b.noRead++
+	}
+	if e.DelayMs != nil {
+		b.delaySum += *e.DelayMs
+		b.delayN++
+	}
+	if b.cameras == nil {
+		b.cameras = make(map[string]bool)
+		b.correct = make(map[string]int64)
+		b.incorrect = make(map[string]int64)
+	}
+	b.cameras[e.Camera] = true
+	if e.At.After(b.last) {
+		b.last = e.At
+	}
+	if !e.Reviewed {
+		return
+	}
+	for _, field := range compareFields {
+		switch fieldVerdict(e, field) {
+		case "incorrect":
+			b.incorrect[field]++
+		case "correct":
+			b.correct[field]++
+		}
+	}
+}
+
+// fieldVerdict is the verdict on one field of a reviewed event under the
+// compare statistics' rules (also the workbook's Statistics sheet and the
+// contact sheet): "correct", "incorrect", or "" while it isn't counted.
+// Manual entries count every field as incorrect; an unrecognized event's
+// plate counts as incorrect once a reviewer entered it.
+func fieldVerdict(e grafanaEvent, field string) string {
+	switch {
+	case field == "plate" && e.NoRead && !e.Corrected:
+		return "" // not counted until a reviewer enters the plate
+	case e.Incorrect[field] || e.Manual || (field == "plate" && e.NoRead):
+		return "incorrect"
+	}
+	return "correct"
+}
+
+// eventVerdict sums up a reviewed event: "pending" while its plate isn't
+// counted, "missed" when the camera read no plate, "incorrect" when a field
+// is, else "correct"
+func eventVerdict(e grafanaEvent) string {
+	switch {
+	case fieldVerdict(e, "plate") == "":
+		return "pending"
+	case e.Manual || e.NoRead:
+		return "missed"
+	}
+	for _, field := range compareFields {
+		if fieldVerdict(e, field) == "incorrect" {
+			return "incorrect"
+		}
+	}
+	return "correct"
+}
+
+// value returns the metric for the bucket, or nil if it has no data
+func (b *grafanaBucket) value(metric string) any {
+	pct := func(part, total int64) any {
+		if total == 0 {
+			return nil
+		}
+		return float64(part) * 100 / float64(total)
+	}
+	switch metric {
+	case "events":
+		return b.events
+	case "unrecognized":
+		return b.noRead
+	case "unrecognized_pct":
+		return pct(b.noRead, b.events)
+	case "arrival_delay_ms":
+		if b.delayN == 0 {
+			return nil
+		}
+		return b.delaySum / b.delayN
+	case "active_cameras":
+		return len(b.cameras)
+	}
+	field, _ := strings.CutSuffix(metric, "_accuracy_pct")
+	return pct(b.correct[field], b.correct[field]+b.incorrect[field])
+}
+
+// parseGrafanaTarget splits "metric:camera"
+func parseGrafanaTarget(target string) (metric, camera string, ok bool) {
+	metric, camera, _ = strings.Cut(target, ":")
+	return metric, camera, metric == grafanaCameraTable || slices.Contains(grafanaMetrics, metric)
+}
+
+// grafanaInterval picks the bucket size for a query
+func grafanaInterval(req grafanaQueryRequest) time.Duration {
+	span := req.Range.To.Sub(req.Range.From)
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+	if interval <= 0 && req.MaxDataPoints > 0 {
+		interval = span / time.Duration(req.MaxDataPoints)
+	}
+	interval = max(interval, time.Minute, span/grafanaMaxPoints)
+	return interval.Truncate(time.Second)
+}
+
+// loadGrafanaEvents reads the events captured in [from, to)
+func (s *Server) loadGrafanaEvents(ctx context.Context, from, to time.Time) ([]grafanaEvent, error) {
+	q := dbgen.New(s.DB)
+	// Stored times are in the server's zone and compare as text
+	from, to = from.In(time.Local), to.In(time.Local)
+	rows, err := q.GetGrafanaEvents(ctx, dbgen.GetGrafanaEventsParams{RangeFrom: &from, RangeTo: &to})
+	if err != nil {
+		return nil, err
+	}
+	reviewed, err := q.GetReviewedArchiveIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	marks, err := q.GetGrafanaIncorrectFields(ctx, dbgen.GetGrafanaIncorrectFieldsParams{RangeFrom: &from, RangeTo: &to})
+	if err != nil {
+		return nil, err
+	}
+	incorrect := make(map[int64]map[string]bool)
+	for _, m := range marks {
+		if incorrect[m.EventID] == nil {
+			incorrect[m.EventID] = make(map[string]bool)
+		}
+		incorrect[m.EventID][m.Field] = true
+	}
+
+	events := make([]grafanaEvent, len(rows))
+	for i, e := range rows {
+		at := e.CreatedAt
+		if e.CapturedAt != nil {
+			at = *e.CapturedAt
+		}
+		events[i] = grafanaEvent{
+			At:        at,
+			Camera:    coalesce(deref(e.CameraSerial), deref(e.SensorProviderID)),
+			NoRead:    e.Unrecognized,
+			Reviewed:  e.ArchiveID != nil && slices.Contains(reviewed, *e.ArchiveID),
+			Incorrect: incorrect[e.ID],
+			Manual:    e.Source == "manual",
+			Corrected: e.ManualPlate != nil,
+			DelayMs:   e.ArrivalDelayMs,
+		}
+	}
+	return events, nil
+}
+
+// grafanaTimeSeries buckets the events of one camera ("" for all) into a series
+func grafanaTimeSeries(target, metric, camera string, events []grafanaEvent, from, to time.Time, interval time.Duration) grafanaSeries {
+	start := from.Truncate(interval)
+	n := int((to.Sub(start) + interval - 1) / interval)
+	buckets := make([]grafanaBucket, n)
+	for _, e := range events {
+		if camera != "" && !strings.EqualFold(e.Camera, camera) {
+			continue
+		}
+		if i := int(e.At.Sub(start) / interval); i >= 0 && i < n {
+			buckets[i].add(e)
+		}
+	}
+	series := grafanaSeries{Target: target, Datapoints: [][]any{}}
+	for i := range buckets {
+		if v := buckets[i].value(metric); v != nil {
+			series.Datapoints = append(series.Datapoints, []any{v, start.Add(time.Duration(i) * interval).UnixMilli()})
+		}
+	}
+	return series
+}
+
+// grafanaCameraHealth totals the range per camera
+func grafanaCameraHealth(events []grafanaEvent, camera string) grafanaTable {
+	byCamera := make(map[string]*grafanaBucket)
+	for _, e := range events {
+		if camera != "" && !strings.EqualFold(e.Camera, camera) {
+			continue
+		}
+		b := byCamera[e.Camera]
+		if b == nil {
+			b = &grafanaBucket{}
+			byCamera[e.Camera] = b
+		}
+		b.add(e)
+	}
+	table := grafanaTable{
+		Type: "table",
+		Columns: []grafanaColumn{
+			{"Camera", "string"},
+			{"Last event", "time"},
+			{"Events", "number"},
+			{"Unrecognized %", "number"},
+			{"Avg arrival delay ms", "number"},
+			{"Plate accuracy %", "number"},
+		},
+		Rows: [][]any{},
+	}
+	cameras := make([]string, 0, len(byCamera))
+	for c := range byCamera {
+		cameras = append(cameras, c)
+	}
+	slices.Sort(cameras)
+	for _, c := range cameras {
+		b := byCamera[c]
+		table.Rows = append(table.Rows, []any{
+			c, b.last.UnixMilli(), b.events,
+			b.value("unrecognized_pct"), b.value("arrival_delay_ms"), b.value("plate_accuracy_pct"),
+		})
+	}
+	return table
+}
+
+// HandleGrafanaTest answers the datasource's connection test
+func (s *Server) HandleGrafanaTest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"status": "ok"})
+}
+
+// HandleGrafanaSearch lists the metrics matching the request's "target"
+// text, plus per-camera event counts
+func (s *Server) HandleGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Target string `json:"target"`
+	}
+	json.NewDecoder(r.Body).Decode(&req) // an empty body lists everything
+
+	names := append(slices.Clone(grafanaMetrics), grafanaCameraTable)
+	cameras, err := dbgen.New(s.DB).GetEventCameras(r.Context())
+	if err != nil {
+		slog.Warn("failed to list cameras", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	seen := make(map[string]bool)
+	for _, c := range cameras {
+		camera := coalesce(deref(c.CameraSerial), deref(c.SensorProviderID))
+		if !seen[camera] {
+			seen[camera] = true
+			names = append(names, "events:"+camera)
+		}
+	}
+	slices.Sort(names[len(grafanaMetrics)+1:])
+
+	filter := strings.ToLower(req.Target)
+	matches := []string{}
+	for _, name := range names {
+		if strings.Contains(strings.ToLower(name), filter) {
+			matches = append(matches, name)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(matches)
+}
+
+// HandleGrafanaQuery returns the requested series and tables
+func (s *Server) HandleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var req grafanaQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Range.From.IsZero() || !req.Range.From.Before(req.Range.To) {
+		s.jsonError(w, "range.from must be before range.to", http.StatusBadRequest)
+		return
+	}
+	for _, t := range req.Targets {
+		if _, _, ok := parseGrafanaTarget(t.Target); t.Target != "" && !ok {
+			s.jsonError(w, "unknown metric "+t.Target, http.StatusBadRequest)
+			return
+		}
+	}
+
+	events, err := s.loadGrafanaEvents(r.Context(), req.Range.From, req.Range.To)
+	if err != nil {
+		slog.Warn("failed to load Grafana metrics", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	interval := grafanaInterval(req)
+	results := []any{}
+	for _, t := range req.Targets {
+		if t.Target == "" {
+			continue // a panel whose metric hasn't been picked yet
+		}
+		metric, camera, _ := parseGrafanaTarget(t.Target)
+		if metric == grafanaCameraTable || t.Type == "table" {
+			results = append(results, grafanaCameraHealth(events, camera))
+			continue
+		}
+		results = append(results, grafanaTimeSeries(t.Target, metric, camera, events, req.Range.From, req.Range.To, interval))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
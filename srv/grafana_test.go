@@ -0,0 +1,130 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestGrafanaQuery(t *testing.T) {
+	s := newTestServer(t)
+	s.CameraTZ = time.UTC
+	ctx := context.Background()
+
+	for _, body := range []string{
+		`{"plateUTF8":"AB1","sensorProviderID":"north","capture_timestamp":"2026-03-01T10:00:10Z"}`,
+		`{"plateUTF8":"AB2","sensorProviderID":"north","capture_timestamp":"2026-03-01T10:00:50Z"}`,
+		`{"sensorProviderID":"south","capture_timestamp":"2026-03-01T10:01:30Z"}`,
+		`{"plateUTF8":"AB3","sensorProviderID":"south","capture_timestamp":"2026-03-01T10:05:00Z"}`,
+	} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Review the first two: one plate marked incorrect
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('reviewed')")
+	s.DB.Exec("UPDATE events SET archive_id = 1 WHERE id IN (1, 2)")
+	dbgen.New(s.DB).SetCompareResult(ctx, dbgen.SetCompareResultParams{ArchiveID: 1, EventID: 2, Field: "plate", IsIncorrect: true})
+
+	query := func(targets ...string) []json.RawMessage {
+		t.Helper()
+		var ts []string
+		for _, target := range targets {
+			ts = append(ts, `{"target":"`+target+`","refId":"A"}`)
+		}
+		body := `{"range":{"from":"2026-03-01T10:00:00Z","to":"2026-03-01T10:10:00Z"},"intervalMs":60000,"targets":[` + strings.Join(ts, ",") + `]}`
+		w := httptest.NewRecorder()
+		s.HandleGrafanaQuery(w, httptest.NewRequest("POST", "/grafana/query", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var res []json.RawMessage
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return res
+	}
+	series := func(raw json.RawMessage) map[int64]float64 {
+		var s grafanaSeries
+		json.Unmarshal(raw, &s)
+		points := make(map[int64]float64)
+		for _, p := range s.Datapoints {
+			points[int64(p[1].(float64))] = p[0].(float64)
+		}
+		return points
+	}
+	minute := func(m int) int64 { return time.Date(2026, 3, 1, 10, m, 0, 0, time.UTC).UnixMilli() }
+
+	res := query("events", "events:SOUTH", "plate_accuracy_pct", "unrecognized_pct", "camera_health")
+	if len(res) != 5 {
+		t.Fatalf("%d results, want 5", len(res))
+	}
+	if got := series(res[0]); len(got) != 10 || got[minute(0)] != 2 || got[minute(1)] != 1 || got[minute(5)] != 1 || got[minute(2)] != 0 {
+		t.Errorf("events = %v", got)
+	}
+	if got := series(res[1]); got[minute(0)] != 0 || got[minute(1)] != 1 {
+		t.Errorf("events:SOUTH = %v", got)
+	}
+	if got := series(res[2]); len(got) != 1 || got[minute(0)] != 50 {
+		t.Errorf("plate accuracy = %v, want 50%% in the first minute only", got)
+	}
+	if got := series(res[3]); len(got) != 3 || got[minute(1)] != 100 {
+		t.Errorf("unrecognized = %v", got)
+	}
+	var table grafanaTable
+	json.Unmarshal(res[4], &table)
+	if len(table.Rows) != 2 || table.Rows[0][0] != "north" || table.Rows[1][2] != float64(2) {
+		t.Errorf("camera health = %v", table.Rows)
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleGrafanaQuery(w, httptest.NewRequest("POST", "/grafana/query", strings.NewReader(`{"range":{"from":"2026-03-01T10:00:00Z","to":"2026-03-01T11:00:00Z"},"targets":[{"target":"bogus"}]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown metric: status %d, want 400", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.HandleGrafanaSearch(w, httptest.NewRequest("POST", "/grafana/search", strings.NewReader(`{"target":"events"}`)))
+	var names []string
+	json.Unmarshal(w.Body.Bytes(), &names)
+	if strings.Join(names, ",") != "events,events:north,events:south" {
+		t.Errorf("search = %v", names)
+	}
+}
+
+func TestGrafanaAPIKey(t *testing.T) {
+	s := newTestServer(t)
+	s.RequireLogin = true
+	s.SessionTTL = time.Hour
+	key, hash, prefix := newAPIKey()
+	dbgen.New(s.DB).CreateAPIKey(context.Background(), dbgen.CreateAPIKeyParams{Name: "grafana", KeyHash: hash, KeyPrefix: prefix, CreatedAt: time.Now()})
+
+	mux := http.NewServeMux()
+	s.keyRoute(mux, "GET /grafana/{$}", s.HandleGrafanaTest)
+	mux.HandleFunc("GET /api/events", s.HandleGrafanaTest)
+	h := s.requireLogin(mux)
+
+	for _, tc := range []struct {
+		path, key string
+		want      int
+	}{
+		{"/grafana/", "", http.StatusUnauthorized},
+		{"/grafana/", "mmr_wrong", http.StatusUnauthorized},
+		{"/grafana/", key, http.StatusOK},
+		{"/api/events", key, http.StatusUnauthorized}, // keys only open Grafana routes
+	} {
+		r := httptest.NewRequest("GET", tc.path, nil)
+		if tc.key != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s with key %q: status %d, want %d", tc.path, tc.key, w.Code, tc.want)
+		}
+	}
+}
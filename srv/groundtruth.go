@@ -0,0 +1,450 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Reviewing a session of 1000+ events by hand takes hours. When the truth
+// is known from elsewhere (a reference system, a spreadsheet kept at the
+// test site), it can be imported for an archive, and an auto-compare job
+// marks every field that has a truth correct or incorrect: the plate as a
+// true plate (see platematch.go, source "ground_truth"), make and model the
+// way a VIN's are compared (see vin.go), and the color by name.
+//
+// The import is a JSON array or a CSV with a header row. An event is given
+// by event_id or uid; empty or missing fields keep what an earlier import
+// gave. CSV columns other than these are ignored, "make" is read as maker:
+//
+//	[{"event_id": 12, "plate": "AB 123", "maker": "Skoda", "model": "Octavia", "color": "white"}, {"uid": "01J…", "color": "grey"}]
+//
+// Reviewers still override verdicts with the compare page's checkboxes or
+// the compare API. A verdict that differs from what the job set last time,
+// or was there before the job first ran (a reviewer's, a VIN's), is kept
+// when the job runs again unless ?overwrite=1 is given.
+//
+//	GET    /api/archive/{id}/ground-truth                     the imported truth
+//	POST   /api/archive/{id}/ground-truth                     import, then start the job
+//	DELETE /api/archive/{id}/ground-truth                     forget the truth, verdicts stay
+//	POST   /api/archive/{id}/compare/auto/jobs[?overwrite=1]  run the job again
+//
+// Job progress is GET /api/jobs/{id} (see jobs.go); once done, its result
+// counts the verdicts set and the overrides kept.
+
+// autoCompareBatch is how many events are saved per transaction
+const autoCompareBatch = 250
+
+// groundTruthRow is one event of an import
+type groundTruthRow struct {
+	EventID int64  `json:"event_id"`
+	UID     string `json:"uid"`
+	Plate   string `json:"plate"`
+	Maker   string `json:"maker"`
+	Model   string `json:"model"`
+	Color   string `json:"color"`
+}
+
+// parseGroundTruth reads a JSON or CSV import
+func parseGroundTruth(body []byte) ([]groundTruthRow, string, error) {
+	body = bytes.TrimPrefix(bytes.TrimSpace(body), []byte("\ufeff"))
+	if bytes.HasPrefix(body, []byte("[")) {
+		var rows []groundTruthRow
+		if err := json.Unmarshal(body, &rows); err != nil {
+			return nil, "json", fmt.Errorf("invalid json: %w", err)
+		}
+		return rows, "json", nil
+	}
+	rows, err := parseGroundTruthCSV(body)
+	return rows, "csv", err
+}
+
+// parseGroundTruthCSV reads a CSV import; spreadsheets saved with a
+// semicolon separator are read too
+func parseGroundTruthCSV(body []byte) ([]groundTruthRow, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	if header, _, _ := bytes.Cut(body, []byte("\n")); bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		r.Comma = ';'
+	}
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.New("expected a JSON array or a CSV with a header row")
+	}
+	cols := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "make" {
+			name = "maker"
+		}
+		cols[name] = i
+	}
+	if _, ok := cols["event_id"]; !ok {
+		if _, ok := cols["uid"]; !ok {
+			return nil, errors.New("csv header needs an event_id or uid column")
+		}
+	}
+	var rows []groundTruthRow
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		get := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		row := groundTruthRow{UID: get("uid"), Plate: get("plate"), Maker: get("maker"), Model: get("model"), Color: get("color")}
+		if id := get("event_id"); id != "" {
+			if row.EventID, err = strconv.ParseInt(id, 10, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid event_id %q", line, id)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// importGroundTruth stores the rows of an import; if any of them refers to
+// an event outside the archive, nothing is stored
+func (s *Server) importGroundTruth(ctx context.Context, archiveID int64, rows []groundTruthRow) error {
+	if locked, err := s.archiveLocked(ctx, archiveID); err != nil {
+		return err
+	} else if locked {
+		return errArchiveLocked
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(s.DB).WithTx(tx)
+
+	events, err := q.GetArchivedEventUIDs(ctx, &archiveID)
+	if err != nil {
+		return err
+	}
+	inArchive := make(map[int64]bool, len(events))
+	byUID := make(map[string]int64, len(events))
+	for _, e := range events {
+		inArchive[e.ID] = true
+		if e.Uid != nil {
+			byUID[*e.Uid] = e.ID
+		}
+	}
+	now := time.Now()
+	for i, row := range rows {
+		eventID := row.EventID
+		if eventID == 0 && row.UID != "" {
+			eventID = byUID[row.UID]
+		}
+		if !inArchive[eventID] {
+			ref := strconv.FormatInt(row.EventID, 10)
+			if row.EventID == 0 {
+				ref = fmt.Sprintf("uid %q", row.UID)
+			}
+			return fmt.Errorf("%w: entry %d (%s)", errNotInArchive, i, ref)
+		}
+		if err := q.UpsertGroundTruth(ctx, dbgen.UpsertGroundTruthParams{
+			ArchiveID:  archiveID,
+			EventID:    eventID,
+			Plate:      ptrIfNotEmpty(normalizePlate(row.Plate)),
+			Maker:      ptrIfNotEmpty(strings.TrimSpace(row.Maker)),
+			Model:      ptrIfNotEmpty(strings.TrimSpace(row.Model)),
+			Color:      ptrIfNotEmpty(strings.TrimSpace(row.Color)),
+			ImportedAt: now,
+		}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// colorAliases are spellings of one color
+var colorAliases = map[string]string{"gray": "grey", "golden": "gold", "violet": "purple"}
+
+// sameColor reports whether the camera's color names the true one
+func sameColor(camera, truth string) bool {
+	a, b := vehicleKey(camera), vehicleKey(truth)
+	if alias, ok := colorAliases[a]; ok {
+		a = alias
+	}
+	if alias, ok := colorAliases[b]; ok {
+		b = alias
+	}
+	return a != "" && a == b
+}
+
+// groundTruthVerdicts compares an event's reads with its ground truth. It
+// returns the update to save, with the plate given as a true plate, and
+// the verdict of each field that has a truth. Manually entered events and
+// plates of unrecognized events aren't judged, as on the compare page.
+func (s *Server) groundTruthVerdicts(e dbgen.GetGroundTruthEventsRow) (compareUpdate, map[string]bool) {
+	u := compareUpdate{EventID: e.ID}
+	verdicts := make(map[string]bool)
+	if e.Source == "manual" {
+		return u, verdicts
+	}
+	if e.TruePlate != nil && e.PlateUtf8 != nil && !e.Unrecognized {
+		u.TruePlate, u.truthSource = e.TruePlate, plateTruthGroundTruth
+		verdicts["plate"] = s.PlateMatch.distance(*e.PlateUtf8, *e.TruePlate) > 0
+	}
+	maker := true
+	if e.TrueMaker != nil {
+		maker = sameMake(deref(e.VehicleMake), *e.TrueMaker)
+		verdicts["maker"] = !maker
+	}
+	if e.TrueModel != nil {
+		verdicts["model"] = !maker || !sameModel(deref(e.VehicleModel), *e.TrueModel)
+	}
+	if e.TrueColor != nil {
+		verdicts["color"] = !sameColor(deref(e.VehicleColor), *e.TrueColor)
+	}
+	for _, field := range []string{"maker", "model", "color"} {
+		if v, ok := verdicts[field]; ok {
+			u.set(field, ptr(v))
+		}
+	}
+	return u, verdicts
+}
+
+// autoCompareSummary is the result of an auto-compare job
+type autoCompareSummary struct {
+	Events     int `json:"events"`     // events with ground truth
+	Verdicts   int `json:"verdicts"`   // fields set
+	Incorrect  int `json:"incorrect"`  // of them, marked incorrect
+	Overridden int `json:"overridden"` // reviewer verdicts kept
+}
+
+// autoCompare turns an archive's ground truth into compare verdicts,
+// keeping reviewer overrides unless overwrite is set. progress is called
+// after every batch of events.
+func (s *Server) autoCompare(ctx context.Context, archiveID int64, overwrite bool, progress func(done, total int)) (autoCompareSummary, error) {
+	var sum autoCompareSummary
+	q := dbgen.New(s.DB)
+	events, err := q.GetGroundTruthEvents(ctx, archiveID)
+	if err != nil {
+		return sum, err
+	}
+	results, err := q.GetCompareResultsDetail(ctx, archiveID)
+	if err != nil {
+		return sum, err
+	}
+	current := make(map[string]bool, len(results))
+	for _, r := range results {
+		current[fmt.Sprintf("%d_%s", r.EventID, r.Field)] = r.IsIncorrect
+	}
+	autoResults, err := q.GetAutoCompareResults(ctx, archiveID)
+	if err != nil {
+		return sum, err
+	}
+	previous := make(map[string]bool, len(autoResults))
+	for _, r := range autoResults {
+		previous[fmt.Sprintf("%d_%s", r.EventID, r.Field)] = r.IsIncorrect
+	}
+
+	sum.Events = len(events)
+	for start := 0; start < len(events); start += autoCompareBatch {
+		if err := ctx.Err(); err != nil {
+			return sum, err
+		}
+		var batch []compareUpdate
+		var auto []dbgen.UpsertAutoCompareResultParams
+		now := time.Now()
+		for _, e := range events[start:min(start+autoCompareBatch, len(events))] {
+			u, verdicts := s.groundTruthVerdicts(e)
+			set := 0
+			for _, field := range compareFields {
+				v, ok := verdicts[field]
+				if !ok {
+					continue
+				}
+				key := fmt.Sprintf("%d_%s", e.ID, field)
+				cur, reviewed := current[key]
+				last, ran := previous[key]
+				if reviewed && (!ran || cur != last) && !overwrite {
+					// The reviewer's verdict stands
+					u.set(field, nil)
+					if field == "plate" {
+						u.TruePlate = nil
+					}
+					sum.Overridden++
+					continue
+				}
+				auto = append(auto, dbgen.UpsertAutoCompareResultParams{ArchiveID: archiveID, EventID: e.ID, Field: field, IsIncorrect: v, ComparedAt: now})
+				set++
+				if v {
+					sum.Incorrect++
+				}
+			}
+			if set > 0 {
+				batch = append(batch, u)
+			}
+		}
+		if len(batch) > 0 {
+			if _, err := s.saveCompareUpdates(ctx, archiveID, batch, "", false); err != nil {
+				return sum, err
+			}
+			for _, a := range auto {
+				if err := q.UpsertAutoCompareResult(ctx, a); err != nil {
+					return sum, err
+				}
+			}
+			sum.Verdicts += len(auto)
+		}
+		if progress != nil {
+			progress(min(start+autoCompareBatch, len(events)), len(events))
+		}
+	}
+	return sum, nil
+}
+
+// startAutoCompare runs the auto-compare job of an archive in the background
+func (s *Server) startAutoCompare(archiveID int64, overwrite bool, user string, events int) *exportJob {
+	// The job outlives the request that started it
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &exportJob{
+		ID:        newJobID(),
+		Kind:      "auto_compare",
+		ArchiveID: archiveID,
+		state:     jobRunning,
+		total:     events,
+		startedAt: time.Now(),
+		user:      user,
+		cancel:    cancel,
+	}
+	s.jobs.add(job, 0)
+	slog.Info("auto-compare job started", "job", job.ID, "archive_id", archiveID, "events", events, "overwrite", overwrite)
+
+	s.background.Go(func() {
+		defer cancel()
+		sum, err := s.autoCompare(ctx, archiveID, overwrite, job.progress)
+		job.mu.Lock()
+		job.result = sum
+		job.mu.Unlock()
+		job.finish("", err)
+		st := job.status()
+		slog.Info("auto-compare job finished", "job", job.ID, "state", st.State, "events", sum.Events, "verdicts", sum.Verdicts,
+			"incorrect", sum.Incorrect, "overridden", sum.Overridden, "elapsed", time.Since(job.startedAt).Round(time.Millisecond), "error", st.Error)
+	})
+	return job
+}
+
+// HandleGroundTruth returns the imported ground truth of an archive
+func (s *Server) HandleGroundTruth(w http.ResponseWriter, r *http.Request) {
+	archiveID, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	rows, err := dbgen.New(s.DB).GetGroundTruth(r.Context(), archiveID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
+
+// HandleImportGroundTruth stores an archive's ground truth and starts the
+// auto-compare job
+func (s *Server) HandleImportGroundTruth(w http.ResponseWriter, r *http.Request) {
+	archiveID, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.jsonError(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	rows, format, err := parseGroundTruth(body)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.importGroundTruth(r.Context(), archiveID, rows); err != nil {
+		s.writeCompareError(w, err)
+		return
+	}
+	truth, err := dbgen.New(s.DB).GetGroundTruth(r.Context(), archiveID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("ground truth imported", "archive_id", archiveID, "format", format, "rows", len(rows), "events", len(truth))
+	job := s.startAutoCompare(archiveID, r.URL.Query().Get("overwrite") == "1", sessionUser(r), len(truth))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  true,
+		"message":  fmt.Sprintf("imported %d rows from %s, %d events have ground truth", len(rows), format, len(truth)),
+		"format":   format,
+		"imported": len(rows),
+		"events":   len(truth),
+		"job":      job.status(),
+	})
+}
+
+// HandleDeleteGroundTruth forgets an archive's ground truth; the verdicts
+// set from it stay
+func (s *Server) HandleDeleteGroundTruth(w http.ResponseWriter, r *http.Request) {
+	archiveID, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	n, err := dbgen.New(s.DB).DeleteGroundTruth(r.Context(), archiveID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("ground truth deleted", "archive_id", archiveID, "events", n)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "events": n})
+}
+
+// HandleStartAutoCompare runs the auto-compare job of an archive again,
+// e.g. after -plate-match-config changed
+func (s *Server) HandleStartAutoCompare(w http.ResponseWriter, r *http.Request) {
+	archiveID, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	if locked, err := s.archiveLocked(r.Context(), archiveID); err != nil {
+		s.writeCompareError(w, err)
+		return
+	} else if locked {
+		s.writeCompareError(w, errArchiveLocked)
+		return
+	}
+	truth, err := dbgen.New(s.DB).GetGroundTruth(r.Context(), archiveID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if len(truth) == 0 {
+		s.jsonError(w, "archive has no ground truth", http.StatusConflict)
+		return
+	}
+	job := s.startAutoCompare(archiveID, r.URL.Query().Get("overwrite") == "1", sessionUser(r), len(truth))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job.status())
+}
@@ -0,0 +1,132 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestParseGroundTruth(t *testing.T) {
+	rows, format, err := parseGroundTruth([]byte("\ufeffEvent_ID;Make;Model;Notes;Color\n3;Skoda;Octavia;lap 2;grey\n4;;;;\n"))
+	if err != nil || format != "csv" || len(rows) != 2 || rows[0] != (groundTruthRow{EventID: 3, Maker: "Skoda", Model: "Octavia", Color: "grey"}) {
+		t.Errorf("csv %q %+v %v", format, rows, err)
+	}
+	rows, format, err = parseGroundTruth([]byte(`[{"uid": "01J", "plate": "ab 12"}]`))
+	if err != nil || format != "json" || len(rows) != 1 || rows[0].UID != "01J" {
+		t.Errorf("json %q %+v %v", format, rows, err)
+	}
+	for _, bad := range []string{"plate,color\nAB1,red\n", "event_id\nx\n", "[{"} {
+		if _, _, err := parseGroundTruth([]byte(bad)); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestAutoCompare(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	for _, e := range []struct{ plate, make, model, color string }{
+		{"AB123", "VW", "Golf Variant", "gray"},
+		{"CD456", "Skoda", "Fabia", "white"},
+		{"EF789", "BMW", "X5", "black"},
+	} {
+		msg := fmt.Sprintf(`{"plateUTF8":%q,"vehicle_info":{"make":%q,"model":%q,"color":%q}}`, e.plate, e.make, e.model, e.color)
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(msg), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.HandleClean(httptest.NewRecorder(), httptest.NewRequest("POST", "/clean", nil))
+	q := dbgen.New(s.DB)
+
+	post := func(path, body string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("POST", path, strings.NewReader(body))
+		r.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		s.background.Wait()
+		return w
+	}
+	verdicts := func() map[string]bool {
+		t.Helper()
+		annotations, err := compareAnnotations(ctx, q, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]bool{}
+		for _, a := range annotations {
+			for _, f := range compareFields {
+				out[fmt.Sprintf("%d_%s", a.EventID, f)] = a.incorrect(f)
+			}
+		}
+		return out
+	}
+
+	// A verdict given before the import is the reviewer's
+	if _, err := s.saveCompareUpdates(ctx, 1, []compareUpdate{{EventID: 3, Color: ptr(true)}}, "", false); err != nil {
+		t.Fatal(err)
+	}
+	truth := "event_id,plate,make,model,color\n1,AB-123,Volkswagen,Golf,grey\n2,CD465,Skoda,Octavia,white\n3,,,,black\n"
+	w := post("/api/archive/1/ground-truth", truth, s.HandleImportGroundTruth)
+	var imported struct {
+		Events int       `json:"events"`
+		Job    jobStatus `json:"job"`
+	}
+	json.NewDecoder(w.Body).Decode(&imported)
+	if w.Code != http.StatusAccepted || imported.Events != 3 {
+		t.Fatalf("import %d %s", w.Code, w.Body)
+	}
+	st := s.jobs.get(imported.Job.ID).status()
+	sum, _ := st.Result.(autoCompareSummary)
+	if st.State != jobDone || st.DownloadURL != "" || sum != (autoCompareSummary{Events: 3, Verdicts: 8, Incorrect: 2, Overridden: 1}) {
+		t.Errorf("job %+v", st)
+	}
+	v := verdicts()
+	for key, want := range map[string]bool{"1_plate": false, "1_maker": false, "1_model": false, "1_color": false,
+		"2_plate": true, "2_maker": false, "2_model": true, "2_color": false, "3_color": true} {
+		if v[key] != want {
+			t.Errorf("%s incorrect %v, want %v", key, v[key], want)
+		}
+	}
+	plates, _ := q.GetComparePlates(ctx, 1)
+	if len(plates) != 2 || plates[1].Source != plateTruthGroundTruth || plates[1].TruePlate != "CD465" {
+		t.Errorf("true plates %+v", plates)
+	}
+
+	// A reviewer overrides a verdict, which the next run keeps
+	if _, err := s.saveCompareUpdates(ctx, 1, []compareUpdate{{EventID: 2, Model: ptr(false)}}, "", false); err != nil {
+		t.Fatal(err)
+	}
+	w = post("/api/archive/1/compare/auto/jobs", "", s.HandleStartAutoCompare)
+	var job jobStatus
+	json.NewDecoder(w.Body).Decode(&job)
+	sum, _ = s.jobs.get(job.ID).status().Result.(autoCompareSummary)
+	if v := verdicts(); sum.Overridden != 2 || sum.Verdicts != 7 || v["2_model"] || !v["2_plate"] {
+		t.Errorf("rerun %+v %v", sum, v)
+	}
+
+	r := httptest.NewRequest("GET", "/archive/1/compare", nil)
+	r.SetPathValue("id", "1")
+	page := httptest.NewRecorder()
+	s.HandleCompare(page, r)
+	if body := page.Body.String(); !strings.Contains(body, "GT: Octavia") || !strings.Contains(body, "Ground truth imported for 3 events") ||
+		strings.Count(body, "check-cell auto") != 7 {
+		t.Errorf("compare page:\n%s", body)
+	}
+
+	// overwrite=1 takes the truth's verdicts again
+	post("/api/archive/1/compare/auto/jobs?overwrite=1", "", s.HandleStartAutoCompare)
+	if v := verdicts(); !v["2_model"] || v["3_color"] {
+		t.Errorf("overwrite %v", v)
+	}
+
+	if w := post("/api/archive/1/ground-truth", `[{"event_id": 99, "color": "red"}]`, s.HandleImportGroundTruth); w.Code != http.StatusNotFound {
+		t.Errorf("unknown event: %d %s", w.Code, w.Body)
+	}
+}
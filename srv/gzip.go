@@ -0,0 +1,59 @@
+package srv
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Some cameras gzip large payloads and send Content-Encoding: gzip. Ingest
+// endpoints decompress such bodies before parsing. The decompressed size is
+// capped at GzipLimit, since a few kilobytes of gzip can expand to
+// gigabytes.
+
+// DefaultGzipLimit is the largest decompressed request body accepted
+const DefaultGzipLimit = 256 << 20
+
+// gunzipBody transparently decompresses gzip-encoded request bodies
+func (s *Server) gunzipBody(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if encoding != "gzip" && encoding != "x-gzip" {
+			h(w, r)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			s.jsonError(w, "invalid gzip body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		limit := s.GzipLimit
+		if limit <= 0 {
+			limit = DefaultGzipLimit
+		}
+		r.Body = inflateLimiter{http.MaxBytesReader(w, zr, limit), limit}
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		h(w, r)
+	}
+}
+
+// inflateLimiter names the decompressed size limit when a body exceeds it
+type inflateLimiter struct {
+	io.ReadCloser
+	limit int64
+}
+
+func (l inflateLimiter) Read(p []byte) (int, error) {
+	n, err := l.ReadCloser.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		err = fmt.Errorf("decompressed body exceeds %d bytes", l.limit)
+	}
+	return n, err
+}
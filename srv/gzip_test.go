@@ -0,0 +1,52 @@
+package srv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzipIngest(t *testing.T) {
+	s := newTestServer(t)
+	s.GzipLimit = 1 << 20
+	mux := http.NewServeMux()
+	s.ingestRoute(mux, "POST /api", s.HandleAPI)
+
+	gz := func(data string) *bytes.Buffer {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(data))
+		zw.Close()
+		return &buf
+	}
+	post := func(body *bytes.Buffer, encoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/api", body)
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Content-Encoding", encoding)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := post(gz(`{"carID":"1","plateUTF8":"AB123"}`), "gzip"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"plate":"AB123"`) {
+		t.Errorf("gzip body: %d %s", w.Code, w.Body)
+	}
+	if w := post(bytes.NewBufferString(`{"plateUTF8":"CD456"}`), ""); w.Code != http.StatusOK {
+		t.Errorf("plain body: %d %s", w.Code, w.Body)
+	}
+	if w := post(bytes.NewBufferString("not gzip"), "gzip"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid gzip: %d, want 400", w.Code)
+	}
+
+	// 2 MB of spaces compress to a few kilobytes
+	bomb := gz(`{"plateUTF8":"EF789"` + strings.Repeat(" ", 2<<20) + `}`)
+	if bomb.Len() > 16<<10 {
+		t.Fatalf("bomb is %d bytes compressed", bomb.Len())
+	}
+	if w := post(bomb, "x-gzip"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "decompressed body exceeds 1048576 bytes") {
+		t.Errorf("bomb: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,129 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Hikvision ANPR cameras report through ISAPI "HTTP listening": every alarm
+// is POSTed as an EventNotificationAlert, for plate reads a multipart body
+// with anpr.xml and the pictures it lists (licensePlatePicture.jpg,
+// detectionPicture.jpg). Point the camera's listening host at
+// /api/hikvision. The alert is mapped into event JSON; fields without an
+// events column are kept under "hikvision". Heartbeats and other alarm
+// types are acknowledged without storing anything. Retransmitted alarms
+// carry the same UUID and map to the same JSON, so they are caught as
+// duplicates.
+
+// hikvisionAlert is the ISAPI EventNotificationAlert document
+type hikvisionAlert struct {
+	IPAddress   string `xml:"ipAddress"`
+	MACAddress  string `xml:"macAddress"`
+	ChannelID   string `xml:"channelID"`
+	ChannelName string `xml:"channelName"`
+	DateTime    string `xml:"dateTime"`
+	EventType   string `xml:"eventType"`
+	DeviceID    string `xml:"DeviceID"`
+	UUID        string `xml:"UUID"`
+	ANPR        *struct {
+		LicensePlate         string `xml:"licensePlate"`
+		OriginalLicensePlate string `xml:"originalLicensePlate"`
+		ConfidenceLevel      string `xml:"confidenceLevel"`
+		Country              string `xml:"country"`
+		Line                 string `xml:"line"`
+		Direction            string `xml:"direction"`
+		PlateType            string `xml:"plateType"`
+		PlateColor           string `xml:"plateColor"`
+		VehicleType          string `xml:"vehicleType"`
+		VehicleListName      string `xml:"vehicleListName"`
+		VehicleInfo          *struct {
+			Color string `xml:"color"`
+			Logo  string `xml:"vehicleLogoRecog"`
+		} `xml:"vehicleInfo"`
+	} `xml:"ANPR"`
+}
+
+// isHikvisionAlert reports whether an XML event is an ISAPI alert
+func isHikvisionAlert(data []byte) bool {
+	return bytes.Contains(data, []byte("<EventNotificationAlert"))
+}
+
+// hikvisionUnknown is what Hikvision sends for values it couldn't read
+func hikvisionUnknown(v string) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+	return v == "" || v == "unknown" || v == "unknow"
+}
+
+// hikvisionJSON converts an ANPR alert into event JSON
+func hikvisionJSON(data []byte) ([]byte, error) {
+	var alert hikvisionAlert
+	dec := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+	dec.CharsetReader = xmlCharsetReader
+	if err := dec.Decode(&alert); err != nil {
+		return nil, &payloadError{"invalid XML: " + err.Error()}
+	}
+	if !strings.EqualFold(alert.EventType, "ANPR") || alert.ANPR == nil {
+		return nil, fmt.Errorf("%w: %s", errNotANPR, alert.EventType)
+	}
+	a := alert.ANPR
+	event := map[string]any{}
+	set := func(m map[string]any, key, v string) {
+		if !hikvisionUnknown(v) {
+			m[key] = strings.TrimSpace(v)
+		}
+	}
+	set(event, "carID", alert.UUID)
+	set(event, "plateUTF8", a.LicensePlate)
+	set(event, "plateConfidence", a.ConfidenceLevel)
+	set(event, "capture_timestamp", alert.DateTime)
+	set(event, "sensorProviderID", alert.ChannelName)
+	set(event, "lane", a.Line)
+	set(event, "direction", a.Direction)
+
+	camera := map[string]any{}
+	set(camera, "SerialNumber", coalesce(alert.DeviceID, strings.ToUpper(alert.MACAddress)))
+	set(camera, "IPAddress", alert.IPAddress)
+	vehicle := map[string]any{}
+	set(vehicle, "type", a.VehicleType)
+	// Hikvision's numeric country and logo codes have no events column
+	hik := map[string]any{}
+	set(hik, "macAddress", alert.MACAddress)
+	set(hik, "channelID", alert.ChannelID)
+	set(hik, "country", a.Country)
+	set(hik, "plateType", a.PlateType)
+	set(hik, "plateColor", a.PlateColor)
+	set(hik, "originalLicensePlate", a.OriginalLicensePlate)
+	set(hik, "vehicleListName", a.VehicleListName)
+	if a.VehicleInfo != nil {
+		set(vehicle, "color", a.VehicleInfo.Color)
+		set(hik, "vehicleLogoRecog", a.VehicleInfo.Logo)
+	}
+	for key, m := range map[string]map[string]any{"camera_info": camera, "vehicle_info": vehicle, "hikvision": hik} {
+		if len(m) > 0 {
+			event[key] = m
+		}
+	}
+	return json.Marshal(event)
+}
+
+// hikvisionPictureType is the image type of an ISAPI picture part
+func hikvisionPictureType(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+	switch {
+	case strings.HasPrefix(name, "licenseplate"), strings.HasPrefix(name, "platebinary"):
+		return "plate"
+	case strings.HasPrefix(name, "detection"), strings.HasPrefix(name, "vehicle"), strings.HasPrefix(name, "scene"):
+		return "vehicle"
+	}
+	return ""
+}
+
+// HandleHikvision ingests Hikvision ISAPI alarms
+func (s *Server) HandleHikvision(w http.ResponseWriter, r *http.Request) {
+	s.handleIngest(w, r, "hikvision")
+}
@@ -0,0 +1,119 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const hikvisionANPR = `<?xml version="1.0" encoding="UTF-8"?>
+<EventNotificationAlert version="2.0" xmlns="http://www.hikvision.com/ver20/XMLSchema">
+<ipAddress>192.168.1.64</ipAddress>
+<portNo>80</portNo>
+<macAddress>bc:ad:28:01:02:03</macAddress>
+<channelID>1</channelID>
+<dateTime>2026-05-03T14:11:04+02:00</dateTime>
+<eventType>ANPR</eventType>
+<eventState>active</eventState>
+<channelName>Gate North</channelName>
+<ANPR>
+<country>3</country>
+<licensePlate>HK1234</licensePlate>
+<line>1</line>
+<direction>forward</direction>
+<confidenceLevel>98</confidenceLevel>
+<plateType>unknown</plateType>
+<plateColor>white</plateColor>
+<vehicleType>vehicle</vehicleType>
+<vehicleInfo><color>white</color><vehicleLogoRecog>1036</vehicleLogoRecog></vehicleInfo>
+<pictureInfoList>
+<pictureInfo><fileName>licensePlatePicture.jpg</fileName><type>licensePlatePicture</type></pictureInfo>
+<pictureInfo><fileName>detectionPicture.jpg</fileName><type>detectionPicture</type></pictureInfo>
+</pictureInfoList>
+</ANPR>
+<UUID>a1b2c3d4-0001</UUID>
+<isDataRetransmission>%s</isDataRetransmission>
+</EventNotificationAlert>`
+
+func TestHikvisionJSON(t *testing.T) {
+	got, err := hikvisionJSON([]byte(strings.Replace(hikvisionANPR, "%s", "false", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"carID":"a1b2c3d4-0001","plateUTF8":"HK1234","plateConfidence":"98","capture_timestamp":"2026-05-03T14:11:04+02:00",
+		"sensorProviderID":"Gate North","lane":"1","direction":"forward","camera_info":{"SerialNumber":"BC:AD:28:01:02:03","IPAddress":"192.168.1.64"},
+		"vehicle_info":{"type":"vehicle","color":"white"},
+		"hikvision":{"macAddress":"bc:ad:28:01:02:03","channelID":"1","country":"3",
+		"plateColor":"white","vehicleLogoRecog":"1036"}}`
+	var a, b any
+	json.Unmarshal(got, &a)
+	json.Unmarshal([]byte(want), &b)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+	heartbeat := `<EventNotificationAlert><eventType>videoloss</eventType><eventState>inactive</eventState></EventNotificationAlert>`
+	if _, err := hikvisionJSON([]byte(heartbeat)); err == nil || !strings.Contains(err.Error(), "videoloss") {
+		t.Errorf("heartbeat: %v", err)
+	}
+}
+
+func TestHandleHikvision(t *testing.T) {
+	s := newTestServer(t)
+	s.DedupWindow = time.Hour
+	var pic bytes.Buffer
+	jpeg.Encode(&pic, image.NewGray(image.Rect(0, 0, 16, 16)), nil)
+
+	post := func(contentType string, body []byte) map[string]any {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/api/hikvision", bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		s.HandleHikvision(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST /api/hikvision = %d %s", w.Code, w.Body)
+		}
+		var res map[string]any
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return res
+	}
+	// Parts as the camera names them: form names without filenames
+	alarm := func(retransmission string) (string, []byte) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormField("anpr.xml")
+		fw.Write([]byte(strings.Replace(hikvisionANPR, "%s", retransmission, 1)))
+		for _, name := range []string{"licensePlatePicture.jpg", "detectionPicture.jpg"} {
+			fw, _ := mw.CreateFormField(name)
+			fw.Write(pic.Bytes())
+		}
+		mw.Close()
+		return mw.FormDataContentType(), body.Bytes()
+	}
+
+	if res := post(alarm("false")); res["plate"] != "HK1234" || res["images"] != 2.0 {
+		t.Errorf("alarm: %v", res)
+	}
+	if res := post(alarm("true")); res["duplicate"] != true {
+		t.Errorf("retransmission: %v", res)
+	}
+	if res := post("application/xml", []byte(`<EventNotificationAlert><eventType>videoloss</eventType></EventNotificationAlert>`)); res["message"] != "ignored" {
+		t.Errorf("heartbeat: %v", res)
+	}
+
+	var events int
+	var serial, types string
+	s.DB.QueryRow("SELECT COUNT(*) FROM events").Scan(&events)
+	s.DB.QueryRow("SELECT camera_serial FROM events").Scan(&serial)
+	s.DB.QueryRow("SELECT GROUP_CONCAT(image_type) FROM (SELECT image_type FROM images ORDER BY id)").Scan(&types)
+	if events != 1 || serial != "BC:AD:28:01:02:03" || types != "plate,vehicle" {
+		t.Errorf("%d events from %q, images %q", events, serial, types)
+	}
+}
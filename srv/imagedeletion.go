@@ -0,0 +1,168 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// A single image of an event can be wrong (a frame of the vehicle behind,
+// an image another camera attached) or must not be shown. Deleting it from
+// the event page (or the API) only marks it deleted: it stays stored, and
+// restoring it from the event page or the trash brings it back. A deleted
+// image is never picked as the event's best plate or vehicle image, so
+// lists, the compare page, XLSX exports, contact sheets and notifications
+// fall back to the next best one; JSON exports, bundles, OCR and similar
+// image search leave it out, and the image URLs answer 404 until it is
+// restored. Deleting or restoring an archived event's
+// image is recorded in the archive's audit log; locked archives refuse it.
+//
+//	DELETE /api/event/{id}/images/{imageID}[?reason=]   soft-delete
+//	POST   /api/event/{id}/images/{imageID}/restore     restore
+
+var errImageNotFound = errors.New("image not found")
+
+// setImageDeleted soft-deletes or restores an image of an event
+func (s *Server) setImageDeleted(ctx context.Context, event dbgen.Event, imageID int64, deleted bool, reason, actor string) error {
+	if locked, err := s.eventLocked(ctx, event.ArchiveID); err != nil {
+		return err
+	} else if locked {
+		return errArchiveLocked
+	}
+	q := dbgen.New(s.DB)
+	var n int64
+	var err error
+	if deleted {
+		n, err = q.SoftDeleteImage(ctx, dbgen.SoftDeleteImageParams{
+			DeletedAt:    ptr(time.Now()),
+			DeletedBy:    ptrIfNotEmpty(actor),
+			DeleteReason: ptrIfNotEmpty(reason),
+			ID:           imageID,
+			EventID:      event.ID,
+		})
+	} else {
+		n, err = q.RestoreImage(ctx, dbgen.RestoreImageParams{ID: imageID, EventID: event.ID})
+	}
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errImageNotFound
+	}
+	if deleted {
+		// Deleted images aren't served; drop their cached thumbnails too
+		s.removeThumbs(imageID)
+	}
+	action, detail := auditRestore, fmt.Sprintf("image %d of event %d restored", imageID, event.ID)
+	if deleted {
+		action, detail = auditDelete, fmt.Sprintf("image %d of event %d deleted", imageID, event.ID)
+		if reason != "" {
+			detail += ": " + reason
+		}
+	}
+	if event.ArchiveID != nil {
+		s.auditArchive(ctx, *event.ArchiveID, action, detail, actor)
+	} else {
+		slog.Info(detail, "actor", actor)
+	}
+	return nil
+}
+
+// imageRequest parses the event and image of an image URL
+func (s *Server) imageRequest(r *http.Request) (dbgen.Event, int64, error) {
+	imageID, err := strconv.ParseInt(r.PathValue("imageID"), 10, 64)
+	if err != nil {
+		return dbgen.Event{}, 0, errImageNotFound
+	}
+	event, err := lookupEvent(r.Context(), dbgen.New(s.DB), r.PathValue("id"))
+	if err != nil {
+		return dbgen.Event{}, 0, errImageNotFound
+	}
+	return event, imageID, nil
+}
+
+// imageErrorStatus is the HTTP status of a failed change to an image
+func imageErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, errImageNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, errMoveTarget), errors.Is(err, errMoveSame), errors.Is(err, errImageType):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, errArchiveLocked):
+		return http.StatusConflict, "event is in a locked archive"
+	}
+	slog.Error("failed to change image", "error", err)
+	return http.StatusInternalServerError, "database error"
+}
+
+// HandleDeleteImage soft-deletes an image from the event page
+func (s *Server) HandleDeleteImage(w http.ResponseWriter, r *http.Request) {
+	s.handleImageForm(w, r, true)
+}
+
+// HandleRestoreImage restores a deleted image from the event page or the
+// trash
+func (s *Server) HandleRestoreImage(w http.ResponseWriter, r *http.Request) {
+	s.handleImageForm(w, r, false)
+}
+
+func (s *Server) handleImageForm(w http.ResponseWriter, r *http.Request, deleted bool) {
+	event, imageID, err := s.imageRequest(r)
+	if err == nil {
+		err = s.setImageDeleted(r.Context(), event, imageID, deleted, strings.TrimSpace(r.FormValue("reason")), sessionUser(r))
+	}
+	if err != nil {
+		status, msg := imageErrorStatus(err)
+		http.Error(w, msg, status)
+		return
+	}
+	next := fmt.Sprintf("/event/%d", event.ID)
+	if r.FormValue("next") == "trash" {
+		next = "/trash"
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// HandleDeleteImageAPI soft-deletes an image
+func (s *Server) HandleDeleteImageAPI(w http.ResponseWriter, r *http.Request) {
+	s.handleImageAPI(w, r, true)
+}
+
+// HandleRestoreImageAPI restores a deleted image
+func (s *Server) HandleRestoreImageAPI(w http.ResponseWriter, r *http.Request) {
+	s.handleImageAPI(w, r, false)
+}
+
+func (s *Server) handleImageAPI(w http.ResponseWriter, r *http.Request, deleted bool) {
+	event, imageID, err := s.imageRequest(r)
+	if err == nil {
+		err = s.setImageDeleted(r.Context(), event, imageID, deleted, strings.TrimSpace(r.URL.Query().Get("reason")), sessionUser(r))
+	}
+	if err != nil {
+		status, msg := imageErrorStatus(err)
+		s.jsonError(w, msg, status)
+		return
+	}
+	best, err := dbgen.New(s.DB).GetEventBestImages(r.Context(), event.ID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":          true,
+		"event_id":         event.ID,
+		"image_id":         imageID,
+		"deleted":          deleted,
+		"plate_image_id":   toInt64(best.PlateImageID),
+		"vehicle_image_id": toInt64(best.VehicleImageID),
+	})
+}
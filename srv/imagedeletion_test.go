@@ -0,0 +1,144 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestImageDeletion(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+	res, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"AB123"}`), "", []uploadedImage{
+		{Filename: "plate.png", Data: pngOf(100, 20)},
+		{Filename: "vehicle.png", Data: pngOf(300, 200)},
+		{Filename: "vehicle_rear.png", Data: pngOf(300, 200)},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.background.Wait()
+	eventID := strconv.FormatInt(res.ID, 10)
+	before, _ := q.GetEventBestImages(ctx, res.ID)
+	vehicle := toInt64(before.VehicleImageID)
+
+	call := func(handler http.HandlerFunc, method, eventID string, imageID int64) *httptest.ResponseRecorder {
+		t.Helper()
+		id := strconv.FormatInt(imageID, 10)
+		r := httptest.NewRequest(method, "/api/event/"+eventID+"/images/"+id+"?reason=car+behind", nil)
+		r.SetPathValue("id", eventID)
+		r.SetPathValue("imageID", id)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+	w := call(s.HandleDeleteImageAPI, "DELETE", eventID, vehicle)
+	var out struct {
+		VehicleImageID int64 `json:"vehicle_image_id"`
+		PlateImageID   int64 `json:"plate_image_id"`
+	}
+	json.NewDecoder(w.Body).Decode(&out)
+	if w.Code != http.StatusOK || out.VehicleImageID == vehicle || out.VehicleImageID == 0 || out.PlateImageID != toInt64(before.PlateImageID) {
+		t.Fatalf("delete %d %s (vehicle was %d)", w.Code, w.Body, vehicle)
+	}
+	if imgs, _ := q.GetImagesByEventIDs(ctx, []int64{res.ID}); len(imgs) != 2 {
+		t.Errorf("export images %+v", imgs)
+	}
+	if w := call(s.HandleDeleteImageAPI, "DELETE", eventID, vehicle); w.Code != http.StatusNotFound {
+		t.Errorf("deleting twice: %d", w.Code)
+	}
+	if w := call(s.HandleDeleteImageAPI, "DELETE", "999", vehicle); w.Code != http.StatusNotFound {
+		t.Errorf("other event: %d", w.Code)
+	}
+
+	r := httptest.NewRequest("GET", "/event/"+eventID, nil)
+	r.SetPathValue("id", eventID)
+	page := httptest.NewRecorder()
+	s.HandleEvent(page, r)
+	if body := page.Body.String(); !strings.Contains(body, "🗑 Deleted") || !strings.Contains(body, "car behind") ||
+		!strings.Contains(body, "/images/"+strconv.FormatInt(vehicle, 10)+"/restore") {
+		t.Errorf("event page:\n%s", body)
+	}
+	trash := httptest.NewRecorder()
+	s.HandleTrash(trash, httptest.NewRequest("GET", "/trash", nil))
+	if !strings.Contains(trash.Body.String(), "Deleted images") {
+		t.Errorf("trash page:\n%s", trash.Body)
+	}
+
+	// Archived, the restore goes into the archive's audit log
+	s.HandleClean(httptest.NewRecorder(), httptest.NewRequest("POST", "/clean", nil))
+	if w := call(s.HandleRestoreImageAPI, "POST", eventID, vehicle); w.Code != http.StatusOK {
+		t.Fatalf("restore %d %s", w.Code, w.Body)
+	}
+	if after, _ := q.GetEventBestImages(ctx, res.ID); toInt64(after.VehicleImageID) != vehicle {
+		t.Errorf("best vehicle after restore %v, want %d", after.VehicleImageID, vehicle)
+	}
+	audit, _ := q.GetArchiveAudit(ctx, 1)
+	if len(audit) == 0 || audit[0].Action != auditRestore || !strings.Contains(deref(audit[0].Detail), "restored") {
+		t.Errorf("audit %+v", audit)
+	}
+}
+
+func TestDeletedImagesNotServed(t *testing.T) {
+	s := newTestServer(t)
+	s.ThumbWidth = 100
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+	res, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"AB123"}`), "", []uploadedImage{
+		{Filename: "vehicle.png", Data: pngOf(300, 200)},
+		{Filename: "plate.png", Data: pngOf(400, 80)},
+		{Filename: "plate_2.png", Data: pngOf(200, 40)},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.background.Wait()
+	best, _ := q.GetEventBestImages(ctx, res.ID)
+	plate := toInt64(best.PlateImageID)
+	event, _ := q.GetEventByID(ctx, res.ID)
+	if err := s.setImageDeleted(ctx, event, plate, true, "", "ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(handler http.HandlerFunc, path string) int {
+		t.Helper()
+		r := httptest.NewRequest("GET", path, nil)
+		r.SetPathValue("id", strconv.FormatInt(plate, 10))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+	id := strconv.FormatInt(plate, 10)
+	for path, handler := range map[string]http.HandlerFunc{
+		"/image/" + id:               s.HandleImage,
+		"/image/" + id + "/download": s.HandleImageDownload,
+		"/image/" + id + "/thumb":    s.HandleImageThumb,
+	} {
+		if code := get(handler, path); code != http.StatusNotFound {
+			t.Errorf("GET %s: %d, want 404", path, code)
+		}
+	}
+
+	// Search results point at the next best plate
+	w := httptest.NewRecorder()
+	s.HandleSearchAPI(w, httptest.NewRequest("GET", "/api/search?q=plate:AB123", nil))
+	var rows []dbgen.SearchEventsRow
+	json.NewDecoder(w.Body).Decode(&rows)
+	if len(rows) != 1 || toInt64(rows[0].PlateImageID) == plate || toInt64(rows[0].PlateImageID) == 0 {
+		t.Errorf("search %+v (deleted plate %d)", rows, plate)
+	}
+
+	if err := s.setImageDeleted(ctx, event, plate, false, "", "ana"); err != nil {
+		t.Fatal(err)
+	}
+	if code := get(s.HandleImageThumb, "/image/"+id+"/thumb"); code != http.StatusOK {
+		t.Errorf("restored thumbnail: %d", code)
+	}
+}
@@ -0,0 +1,257 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"log/slog"
+	"math/bits"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Every image gets a perceptual hash when it is measured. "Similar images"
+// (/image/{id}/similar, /api/image/{id}/similar) lists the images of the
+// same kind (plate crop or vehicle frame) whose hash differs in at most a
+// few bits: a camera sending the same cached frame again shows up at
+// distance 0, the same vehicle captured twice at a small distance.
+
+// Similarity search limits. The distance is the number of differing hash
+// bits out of 64.
+const (
+	defaultSimilarDistance = 6
+	maxSimilarDistance     = 20
+	defaultSimilarLimit    = 50
+	maxSimilarLimit        = 500
+)
+
+// dHash is the difference hash of an image: its grayscale scaled to 9×8,
+// one bit per pair of horizontal neighbours, set where the left one is
+// brighter. It survives scaling, recompression and small brightness
+// changes.
+func dHash(img image.Image) uint64 {
+	b := img.Bounds()
+	if b.Empty() {
+		return 0
+	}
+	var gray [8][9]float64
+	for y := range 8 {
+		y0 := b.Min.Y + y*b.Dy()/8
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/8, y0+1)
+		for x := range 9 {
+			x0 := b.Min.X + x*b.Dx()/9
+			x1 := max(b.Min.X+(x+1)*b.Dx()/9, x0+1)
+			var sum float64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, bl, _ := img.At(sx, sy).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+				}
+			}
+			gray[y][x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	var h uint64
+	for y := range 8 {
+		for x := range 8 {
+			if gray[y][x] > gray[y][x+1] {
+				h |= 1 << (y*8 + x)
+			}
+		}
+	}
+	return h
+}
+
+// hashExistingImages hashes the images measured before hashes were
+// recorded. It runs in the background at startup, after
+// measureExistingImages; images it can't load are retried next start.
+func (s *Server) hashExistingImages(ctx context.Context) {
+	q := dbgen.New(s.DB)
+	var after int64
+	hashed := 0
+	start := time.Now()
+	for {
+		rows, err := q.GetUnhashedImages(ctx, dbgen.GetUnhashedImagesParams{ID: after, Limit: 100})
+		if err != nil {
+			slog.Warn("load unhashed images", "error", err)
+			return
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, row := range rows {
+			after = row.ID
+			data, err := s.imageBytes(ctx, row.ImageData, row.DiskFilename)
+			if err != nil {
+				slog.Warn("load image", "id", row.ID, "error", err)
+				continue
+			}
+			m, err := measureImage(data)
+			if err != nil {
+				continue
+			}
+			if err := q.SetImageHash(ctx, dbgen.SetImageHashParams{Phash: ptr(int64(m.Hash)), ID: row.ID}); err != nil {
+				slog.Warn("record image hash", "id", row.ID, "error", err)
+				return
+			}
+			hashed++
+		}
+	}
+	if hashed > 0 {
+		slog.Info("hashed existing images", "count", hashed, "elapsed", time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// hashDistance is the number of bits two hashes differ in
+func hashDistance(a, b int64) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// similarImage is an image that looks like the one searched for
+type similarImage struct {
+	ImageID   int64     `json:"image_id"`
+	EventID   int64     `json:"event_id"`
+	Distance  int       `json:"distance"`
+	Plate     *string   `json:"plate"`
+	Camera    *string   `json:"camera"`
+	ArchiveID *int64    `json:"archive_id"` // null for the current session
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// similarResult is the /api/image/{id}/similar response
+type similarResult struct {
+	ImageID     int64          `json:"image_id"`
+	EventID     int64          `json:"event_id"`
+	Kind        string         `json:"kind"`
+	Hash        string         `json:"hash,omitempty"` // hex; empty if the image couldn't be hashed yet
+	MaxDistance int            `json:"max_distance"`
+	Matches     []similarImage `json:"matches"`
+	More        int            `json:"more"` // matches beyond the limit
+}
+
+// findSimilarImages lists the images of the same kind within maxDistance
+// of an image, closest first
+func (s *Server) findSimilarImages(ctx context.Context, id int64, maxDistance, limit int) (similarResult, error) {
+	q := dbgen.New(s.DB)
+	img, err := q.GetImageHash(ctx, id)
+	if err != nil {
+		return similarResult{}, err
+	}
+	res := similarResult{ImageID: id, EventID: img.EventID, Kind: img.Kind, MaxDistance: maxDistance, Matches: []similarImage{}}
+	if img.Phash == nil {
+		return res, nil
+	}
+	res.Hash = fmt.Sprintf("%016x", uint64(*img.Phash))
+	rows, err := q.GetHashedImages(ctx, dbgen.GetHashedImagesParams{ID: id, Kind: img.Kind})
+	if err != nil {
+		return res, err
+	}
+	for _, r := range rows {
+		d := hashDistance(*img.Phash, *r.Phash)
+		if d > maxDistance {
+			continue
+		}
+		res.Matches = append(res.Matches, similarImage{
+			ImageID:   r.ID,
+			EventID:   r.EventID,
+			Distance:  d,
+			Plate:     r.PlateUtf8,
+			Camera:    r.CameraSerial,
+			ArchiveID: r.ArchiveID,
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	// Closest first, newest first among equals
+	slices.SortStableFunc(res.Matches, func(a, b similarImage) int {
+		if a.Distance != b.Distance {
+			return a.Distance - b.Distance
+		}
+		return int(b.ImageID - a.ImageID)
+	})
+	if len(res.Matches) > limit {
+		res.Matches, res.More = res.Matches[:limit], len(res.Matches)-limit
+	}
+	return res, nil
+}
+
+// similarParams reads the image id, ?distance= and ?limit=
+func similarParams(r *http.Request) (id int64, distance, limit int, err error) {
+	id, err = strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, 0, 0, errors.New("invalid image id")
+	}
+	distance, limit = defaultSimilarDistance, defaultSimilarLimit
+	if v := r.URL.Query().Get("distance"); v != "" {
+		if distance, err = strconv.Atoi(v); err != nil || distance < 0 || distance > maxSimilarDistance {
+			return 0, 0, 0, fmt.Errorf("distance must be 0-%d", maxSimilarDistance)
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxSimilarLimit {
+			return 0, 0, 0, fmt.Errorf("limit must be 1-%d", maxSimilarLimit)
+		}
+	}
+	return id, distance, limit, nil
+}
+
+// HandleSimilarImages shows the images that look like an image
+func (s *Server) HandleSimilarImages(w http.ResponseWriter, r *http.Request) {
+	id, distance, limit, err := similarParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := s.findSimilarImages(r.Context(), id, distance, limit)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "image not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Warn("failed to find similar images", "image", id, "error", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	data := struct {
+		Hostname  string
+		Result    similarResult
+		Distances []int // offered as links
+	}{
+		Hostname:  s.Hostname,
+		Result:    res,
+		Distances: []int{0, 2, 4, 6, 10, 14, maxSimilarDistance},
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "similar_images.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleSimilarImagesAPI returns the images that look like an image as JSON.
+// Pass ?distance=N (0-20, default 6) and ?limit=N (default 50).
+func (s *Server) HandleSimilarImagesAPI(w http.ResponseWriter, r *http.Request) {
+	id, distance, limit, err := similarParams(r)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := s.findSimilarImages(r.Context(), id, distance, limit)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.jsonError(w, "image not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Warn("failed to find similar images", "image", id, "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,123 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testPattern draws a w×h grayscale image from f(x, y) in 0-1 coordinates
+func testPattern(w, h int, f func(x, y float64) float64) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetGray(x, y, color.Gray{uint8(255 * f(float64(x)/float64(w), float64(y)/float64(h)))})
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	blobs := func(x, y float64) float64 {
+		if (x < 0.3 || x > 0.7) != (y < 0.5) {
+			return 0.8 - 0.5*x
+		}
+		return 0.2 + 0.5*y
+	}
+	orig := testPattern(400, 100, blobs)
+	// The same picture smaller and recompressed
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, testPattern(200, 50, blobs), &jpeg.Options{Quality: 60})
+	again, _, err := image.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := testPattern(400, 100, func(x, y float64) float64 { return y })
+
+	if d := hashDistance(int64(dHash(orig)), int64(dHash(again))); d > 4 {
+		t.Errorf("rescaled copy differs in %d bits", d)
+	}
+	if d := hashDistance(int64(dHash(orig)), int64(dHash(other))); d < 16 {
+		t.Errorf("different picture differs in only %d bits", d)
+	}
+	if dHash(image.NewGray(image.Rect(0, 0, 3, 2))) != 0 {
+		t.Error("tiny flat image should hash to 0")
+	}
+}
+
+func TestSimilarImages(t *testing.T) {
+	s := newTestServer(t)
+	encode := func(img image.Image) []byte {
+		var buf bytes.Buffer
+		png.Encode(&buf, img)
+		return buf.Bytes()
+	}
+	same := encode(testPattern(200, 50, func(x, y float64) float64 { return x }))
+	other := encode(testPattern(200, 50, func(x, y float64) float64 { return 1 - x }))
+	ctx := context.Background()
+	for _, img := range []struct {
+		plate, file string
+		data        []byte
+	}{
+		{"SIM1", "plate.png", same},
+		{"SIM2", "plate.png", same},
+		{"SIM3", "plate.png", other},
+		{"SIM4", "vehicle.png", same},
+	} {
+		req := newIngestRequest([]byte(`{"plateUTF8":"`+img.plate+`"}`), "", []uploadedImage{{Filename: img.file, Data: img.data}})
+		if _, err := s.ingestEvent(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.background.Wait()
+
+	// Hashes recorded before the upgrade are filled in at startup
+	s.DB.Exec("UPDATE images SET phash = NULL WHERE id = 2")
+	s.hashExistingImages(ctx)
+	var n int
+	s.DB.QueryRow("SELECT COUNT(*) FROM images WHERE phash IS NULL").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d images not hashed", n)
+	}
+
+	similar := func(query string) (*httptest.ResponseRecorder, similarResult) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/image/1/similar"+query, nil)
+		r.SetPathValue("id", "1")
+		s.HandleSimilarImagesAPI(w, r)
+		var res similarResult
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w, res
+	}
+	w, res := similar("")
+	if w.Code != http.StatusOK || res.Kind != "plate" || res.Hash == "" {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	// The same crop matches, the vehicle frame with equal pixels is another kind
+	if len(res.Matches) != 1 || res.Matches[0].ImageID != 2 || res.Matches[0].Distance != 0 || *res.Matches[0].Plate != "SIM2" {
+		t.Errorf("matches: %+v", res.Matches)
+	}
+	if w, _ := similar("?distance=64"); w.Code != http.StatusBadRequest {
+		t.Errorf("distance=64: %d", w.Code)
+	}
+	// The mirrored crop is too far apart even at the widest distance
+	_, res = similar("?distance=20")
+	if len(res.Matches) != 1 || res.More != 0 {
+		t.Errorf("distance=20: %+v", res)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/image/1/similar", nil)
+	r.SetPathValue("id", "1")
+	s.HandleSimilarImages(w, r)
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(`href="/event/2"`)) {
+		t.Errorf("page: %d %s", w.Code, w.Body)
+	}
+}
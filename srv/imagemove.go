@@ -0,0 +1,230 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// When a camera's uploads arrive out of step, a frame can be attached to
+// the car ID before or after its own. The event page lists the camera's
+// neighbouring events so the image can be moved to the right one, and an
+// image whose type was tagged wrong can be retagged or handed back to the
+// shape classifier ("auto"), which measures it again. Both best-image picks
+// follow the change. Moves and retags of archived events' images are
+// recorded in the archives' audit logs; locked archives refuse them.
+//
+//	POST /api/event/{id}/images/{imageID}/move?to=         to: event ID or ULID
+//	POST /api/event/{id}/images/{imageID}/classify[?type=] type: auto, plate or vehicle
+
+// neighbourWindow is how many event IDs either side of an event are offered
+// as targets for its images
+const neighbourWindow = 10
+
+var (
+	errMoveTarget = errors.New("target event not found")
+	errMoveSame   = errors.New("image is already in this event")
+	errImageType  = errors.New("type must be auto, plate or vehicle")
+)
+
+// neighbourEvents lists the events of the same camera received around an
+// event
+func neighbourEvents(ctx context.Context, q *dbgen.Queries, event dbgen.Event) ([]dbgen.GetNeighbourEventsRow, error) {
+	return q.GetNeighbourEvents(ctx, dbgen.GetNeighbourEventsParams{
+		SensorProviderID: event.SensorProviderID,
+		FromID:           event.ID - neighbourWindow,
+		ToID:             event.ID + neighbourWindow,
+		ID:               event.ID,
+	})
+}
+
+// auditImageChange records a change to an image in the audit logs of the
+// archives involved, or the server log when no event is archived
+func (s *Server) auditImageChange(ctx context.Context, detail, actor string, archiveIDs ...*int64) {
+	seen := map[int64]bool{}
+	for _, id := range archiveIDs {
+		if id != nil && !seen[*id] {
+			seen[*id] = true
+			s.auditArchive(ctx, *id, auditImage, detail, actor)
+		}
+	}
+	if len(seen) == 0 {
+		slog.Info(detail, "actor", actor)
+	}
+}
+
+// moveImage moves an image of an event to another event
+func (s *Server) moveImage(ctx context.Context, from dbgen.Event, imageID int64, toID, actor string) (dbgen.Event, error) {
+	q := dbgen.New(s.DB)
+	to, err := lookupEvent(ctx, q, strings.TrimSpace(toID))
+	if err != nil {
+		return dbgen.Event{}, errMoveTarget
+	}
+	if to.ID == from.ID {
+		return dbgen.Event{}, errMoveSame
+	}
+	for _, archiveID := range []*int64{from.ArchiveID, to.ArchiveID} {
+		if locked, err := s.eventLocked(ctx, archiveID); err != nil {
+			return dbgen.Event{}, err
+		} else if locked {
+			return dbgen.Event{}, errArchiveLocked
+		}
+	}
+	n, err := q.MoveImage(ctx, dbgen.MoveImageParams{ToEventID: to.ID, ID: imageID, FromEventID: from.ID})
+	if err != nil {
+		return dbgen.Event{}, err
+	}
+	if n == 0 {
+		return dbgen.Event{}, errImageNotFound
+	}
+	s.auditImageChange(ctx, fmt.Sprintf("image %d moved from event %d to event %d", imageID, from.ID, to.ID), actor, from.ArchiveID, to.ArchiveID)
+	return to, nil
+}
+
+// reclassifyImage retags an image of an event and measures it again. "auto"
+// (or no type) clears the tag so the image's shape decides.
+func (s *Server) reclassifyImage(ctx context.Context, event dbgen.Event, imageID int64, imageType, actor string) error {
+	switch imageType {
+	case "", "auto":
+		imageType = "uploaded"
+	case "plate", "vehicle":
+	default:
+		return errImageType
+	}
+	if locked, err := s.eventLocked(ctx, event.ArchiveID); err != nil {
+		return err
+	} else if locked {
+		return errArchiveLocked
+	}
+	q := dbgen.New(s.DB)
+	n, err := q.SetImageType(ctx, dbgen.SetImageTypeParams{ImageType: &imageType, ID: imageID, EventID: event.ID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errImageNotFound
+	}
+	data, err := s.loadImage(ctx, q, imageID)
+	if err != nil {
+		return fmt.Errorf("load image %d: %w", imageID, err)
+	}
+	if err := s.recordImageQuality(ctx, q, imageID, &imageType, data, time.Now()); err != nil {
+		return err
+	}
+	s.auditImageChange(ctx, fmt.Sprintf("image %d of event %d retagged as %s", imageID, event.ID, imageType), actor, event.ArchiveID)
+	return nil
+}
+
+// HandleMoveImage moves an image to another event from the event page
+func (s *Server) HandleMoveImage(w http.ResponseWriter, r *http.Request) {
+	event, imageID, err := s.imageRequest(r)
+	if err == nil {
+		_, err = s.moveImage(r.Context(), event, imageID, r.FormValue("to"), sessionUser(r))
+	}
+	if err != nil {
+		status, msg := imageErrorStatus(err)
+		http.Error(w, msg, status)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/event/%d", event.ID), http.StatusSeeOther)
+}
+
+// HandleReclassifyImage retags an image from the event page
+func (s *Server) HandleReclassifyImage(w http.ResponseWriter, r *http.Request) {
+	event, imageID, err := s.imageRequest(r)
+	if err == nil {
+		err = s.reclassifyImage(r.Context(), event, imageID, r.FormValue("type"), sessionUser(r))
+	}
+	if err != nil {
+		status, msg := imageErrorStatus(err)
+		http.Error(w, msg, status)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/event/%d", event.ID), http.StatusSeeOther)
+}
+
+// HandleMoveImageAPI moves an image to another event
+func (s *Server) HandleMoveImageAPI(w http.ResponseWriter, r *http.Request) {
+	event, imageID, err := s.imageRequest(r)
+	var to dbgen.Event
+	if err == nil {
+		to, err = s.moveImage(r.Context(), event, imageID, r.URL.Query().Get("to"), sessionUser(r))
+	}
+	if err != nil {
+		status, msg := imageErrorStatus(err)
+		s.jsonError(w, msg, status)
+		return
+	}
+	q := dbgen.New(s.DB)
+	from, err := q.GetEventBestImages(r.Context(), event.ID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	target, err := q.GetEventBestImages(r.Context(), to.ID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  true,
+		"image_id": imageID,
+		"from": map[string]any{
+			"event_id":         event.ID,
+			"plate_image_id":   toInt64(from.PlateImageID),
+			"vehicle_image_id": toInt64(from.VehicleImageID),
+		},
+		"to": map[string]any{
+			"event_id":         to.ID,
+			"plate_image_id":   toInt64(target.PlateImageID),
+			"vehicle_image_id": toInt64(target.VehicleImageID),
+		},
+	})
+}
+
+// HandleReclassifyImageAPI retags an image and reports how it is classified
+func (s *Server) HandleReclassifyImageAPI(w http.ResponseWriter, r *http.Request) {
+	event, imageID, err := s.imageRequest(r)
+	if err == nil {
+		err = s.reclassifyImage(r.Context(), event, imageID, r.URL.Query().Get("type"), sessionUser(r))
+	}
+	if err != nil {
+		status, msg := imageErrorStatus(err)
+		s.jsonError(w, msg, status)
+		return
+	}
+	q := dbgen.New(s.DB)
+	images, err := q.GetImagesByEventID(r.Context(), event.ID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	best, err := q.GetEventBestImages(r.Context(), event.ID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	out := map[string]any{
+		"success":          true,
+		"event_id":         event.ID,
+		"image_id":         imageID,
+		"plate_image_id":   toInt64(best.PlateImageID),
+		"vehicle_image_id": toInt64(best.VehicleImageID),
+	}
+	for _, img := range images {
+		if img.ID == imageID {
+			out["image_type"] = img.ImageType
+			out["classified_type"] = img.ClassifiedType
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
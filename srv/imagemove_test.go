@@ -0,0 +1,105 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestImageMove(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+	first, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"AB123","carID":"7","sensorProviderID":"gate"}`), "", []uploadedImage{
+		{Filename: "plate.png", Data: pngOf(100, 20)},
+		{Filename: "vehicle.png", Data: pngOf(300, 200)},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"CD456","carID":"8","sensorProviderID":"gate"}`), "", []uploadedImage{
+		{Filename: "plate.png", Data: pngOf(100, 20)},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.background.Wait()
+	firstID, secondID := strconv.FormatInt(first.ID, 10), strconv.FormatInt(second.ID, 10)
+	before, _ := q.GetEventBestImages(ctx, first.ID)
+	vehicle := toInt64(before.VehicleImageID)
+
+	call := func(handler http.HandlerFunc, eventID string, imageID int64, query string) *httptest.ResponseRecorder {
+		t.Helper()
+		id := strconv.FormatInt(imageID, 10)
+		r := httptest.NewRequest("POST", "/api/event/"+eventID+"/images/"+id+"/x?"+query, nil)
+		r.SetPathValue("id", eventID)
+		r.SetPathValue("imageID", id)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	r := httptest.NewRequest("GET", "/event/"+firstID, nil)
+	r.SetPathValue("id", firstID)
+	page := httptest.NewRecorder()
+	s.HandleEvent(page, r)
+	if !strings.Contains(page.Body.String(), `<option value="`+secondID+`">8 CD456`) {
+		t.Errorf("event page has no move target:\n%s", page.Body)
+	}
+
+	for query, want := range map[string]int{"to=" + firstID: http.StatusBadRequest, "to=999": http.StatusBadRequest} {
+		if w := call(s.HandleMoveImageAPI, firstID, vehicle, query); w.Code != want {
+			t.Errorf("%s: %d, want %d", query, w.Code, want)
+		}
+	}
+	w := call(s.HandleMoveImageAPI, firstID, vehicle, "to="+secondID)
+	var moved struct {
+		From, To struct {
+			VehicleImageID int64 `json:"vehicle_image_id"`
+		}
+	}
+	json.NewDecoder(w.Body).Decode(&moved)
+	if w.Code != http.StatusOK || moved.To.VehicleImageID != vehicle || moved.From.VehicleImageID == vehicle {
+		t.Fatalf("move %d %s", w.Code, w.Body)
+	}
+	if w := call(s.HandleMoveImageAPI, firstID, vehicle, "to="+secondID); w.Code != http.StatusNotFound {
+		t.Errorf("moving from the old event: %d", w.Code)
+	}
+
+	// The plate crop tagged as a vehicle, then handed back to the classifier
+	plate := toInt64(before.PlateImageID)
+	if w := call(s.HandleReclassifyImageAPI, firstID, plate, "type=vehicle"); w.Code != http.StatusOK {
+		t.Fatalf("retag %d %s", w.Code, w.Body)
+	}
+	if best, _ := q.GetEventBestImages(ctx, first.ID); toInt64(best.VehicleImageID) != plate || toInt64(best.PlateImageID) != 0 {
+		t.Errorf("best after retag %+v", best)
+	}
+	w = call(s.HandleReclassifyImageAPI, firstID, plate, "")
+	var classified struct {
+		ImageType      string `json:"image_type"`
+		ClassifiedType string `json:"classified_type"`
+	}
+	json.NewDecoder(w.Body).Decode(&classified)
+	if w.Code != http.StatusOK || classified.ImageType != "uploaded" || classified.ClassifiedType != "plate" {
+		t.Errorf("auto %d %s", w.Code, w.Body)
+	}
+	if w := call(s.HandleReclassifyImageAPI, firstID, plate, "type=overview"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown type: %d", w.Code)
+	}
+
+	// Moving an archived event's image is audited
+	s.HandleClean(httptest.NewRecorder(), httptest.NewRequest("POST", "/clean", nil))
+	if w := call(s.HandleMoveImageAPI, secondID, vehicle, "to="+firstID); w.Code != http.StatusOK {
+		t.Fatalf("move back %d %s", w.Code, w.Body)
+	}
+	audit, _ := q.GetArchiveAudit(ctx, 1)
+	if len(audit) == 0 || audit[0].Action != auditImage || !strings.Contains(deref(audit[0].Detail), "moved from event "+secondID) {
+		t.Errorf("audit %+v", audit)
+	}
+}
@@ -0,0 +1,229 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Events often carry several frames. Each image is measured when stored:
+// pixel size, sharpness and a quality score combining both. The
+// event_best_images view picks the plate and vehicle image shown in lists
+// and exports by type tag first, then by quality, and only then by upload
+// order:
+//
+//   - plate: best 'plate' image, else the best untagged image classified
+//     as a plate crop, else the second upload
+//   - vehicle: best 'vehicle' image, else the best untagged image
+//     classified as a vehicle frame, else the first upload
+//
+// Untagged images are those whose camera filename or tag says neither
+// plate nor vehicle; classifyImage guesses from their shape and size.
+
+// sharpnessWidth is the width images are scaled to before measuring
+// sharpness, so frames of different resolutions are comparable
+const sharpnessWidth = 320
+
+// imageMeasure describes one decoded image
+type imageMeasure struct {
+	Width, Height int
+	Sharpness     float64 // variance of the Laplacian of the grayscale image
+	Hash          uint64  // perceptual hash, see dHash
+}
+
+// Quality is the sharpness weighted by the square root of the pixel count:
+// of two equally sharp frames, one twice as wide scores twice as high
+func (m imageMeasure) Quality() float64 {
+	return m.Sharpness * math.Sqrt(float64(m.Width*m.Height)) / 1000
+}
+
+// measureImage decodes an image and measures its size and sharpness, and
+// hashes it
+func measureImage(data []byte) (imageMeasure, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return imageMeasure{}, err
+	}
+	b := src.Bounds()
+	m := imageMeasure{Width: b.Dx(), Height: b.Dy()}
+	if m.Width > sharpnessWidth {
+		src = scaleDown(src, sharpnessWidth)
+	}
+	m.Sharpness = laplacianVariance(src)
+	m.Hash = dHash(src)
+	return m, nil
+}
+
+// laplacianVariance is a focus measure: blurry images have few strong
+// edges, so the response of the 4-neighbour Laplacian varies little
+func laplacianVariance(img image.Image) float64 {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w < 3 || h < 3 {
+		return 0
+	}
+	gray := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			gray[y*w+x] = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 257
+		}
+	}
+	var sum, sumSq float64
+	n := 0
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			v := gray[i-w] + gray[i+w] + gray[i-1] + gray[i+1] - 4*gray[i]
+			sum += v
+			sumSq += v * v
+			n++
+		}
+	}
+	mean := sum / float64(n)
+	return sumSq/float64(n) - mean*mean
+}
+
+// Plate crops are wide or small: single-row plates are 2:1 (US) to 4.7:1
+// (EU), two-row and motorcycle plates are small, while vehicle frames are
+// 4:3 or 16:9 at 640 px and up.
+const (
+	plateAspect      = 2.5 // wider than this is a plate crop at any size
+	plateAspectSmall = 1.8 // wider than this is a plate crop up to plateSmallWidth
+	plateSmallWidth  = 600
+	plateMaxSide     = 300 // images no larger than this are plate crops
+)
+
+// classifyImage guesses whether an image is a plate crop or a vehicle frame
+func classifyImage(m imageMeasure) string {
+	if m.Height == 0 {
+		return "vehicle"
+	}
+	aspect := float64(m.Width) / float64(m.Height)
+	switch {
+	case aspect >= plateAspect,
+		aspect >= plateAspectSmall && m.Width <= plateSmallWidth,
+		max(m.Width, m.Height) <= plateMaxSide:
+		return "plate"
+	}
+	return "vehicle"
+}
+
+// taggedType reports whether the camera told us what an image shows
+func taggedType(imageType *string) bool {
+	return imageType != nil && (*imageType == "plate" || *imageType == "vehicle")
+}
+
+// recordImageQuality measures an image and stores the result, classifying
+// it when it isn't tagged as plate or vehicle and reading its EXIF capture
+// time unless the camera sent one. Images that can't be decoded are marked
+// measured without values so they aren't retried.
+func (s *Server) recordImageQuality(ctx context.Context, q *dbgen.Queries, id int64, imageType *string, data []byte, now time.Time) error {
+	params := dbgen.SetImageQualityParams{MeasuredAt: &now, ID: id}
+	loc := s.CameraTZ
+	if loc == nil {
+		loc = time.Local
+	}
+	if t, ok := exifTime(data, loc); ok {
+		params.CapturedAt = ptr(t.In(time.Local))
+	}
+	if m, err := measureImage(data); err == nil {
+		params.Width = ptr(int64(m.Width))
+		params.Height = ptr(int64(m.Height))
+		params.Sharpness = ptr(m.Sharpness)
+		params.Quality = ptr(m.Quality())
+		params.Phash = ptr(int64(m.Hash))
+		if !taggedType(imageType) {
+			params.ClassifiedType = ptr(classifyImage(m))
+		}
+	}
+	return q.SetImageQuality(ctx, params)
+}
+
+// imageMeasurer runs one background pass over unmeasured images at a time.
+// Images stored during a pass start another once it ends.
+type imageMeasurer struct {
+	mu      sync.Mutex
+	running bool
+	again   bool
+}
+
+// queueMeasure measures and thumbnails newly stored images in the
+// background, so ingest doesn't wait for decoding them. Call it once the
+// images are committed; until they are measured, their events fall back
+// to upload order.
+func (s *Server) queueMeasure() {
+	m := &s.measurer
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		m.again = true
+		return
+	}
+	m.running = true
+	s.background.Go(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-s.stopping.done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		for {
+			s.measureImages(ctx)
+			m.mu.Lock()
+			if !m.again || ctx.Err() != nil {
+				m.running = false
+				m.mu.Unlock()
+				return
+			}
+			m.again = false
+			m.mu.Unlock()
+		}
+	})
+}
+
+// measureImages measures, classifies and thumbnails the images not measured
+// yet: new ones, and at startup those stored before image quality was
+// recorded. Images left when shutdown interrupts it are measured next start.
+func (s *Server) measureImages(ctx context.Context) {
+	q := dbgen.New(s.DB)
+	var after int64
+	measured := 0
+	start := time.Now()
+	for {
+		rows, err := q.GetUnmeasuredImages(ctx, dbgen.GetUnmeasuredImagesParams{ID: after, Limit: 100})
+		if err != nil {
+			slog.Warn("load unmeasured images", "error", err)
+			return
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, row := range rows {
+			after = row.ID
+			data, err := s.imageBytes(ctx, row.ImageData, row.DiskFilename)
+			if err != nil {
+				slog.Warn("load image", "id", row.ID, "error", err)
+				continue
+			}
+			if err := s.recordImageQuality(ctx, q, row.ID, row.ImageType, data, time.Now()); err != nil {
+				slog.Warn("record image quality", "id", row.ID, "error", err)
+				return
+			}
+			s.cacheIngestThumb(row.ID, data)
+			measured++
+		}
+	}
+	if measured > 0 {
+		slog.Debug("measured images", "count", measured, "elapsed", time.Since(start).Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,75 @@
+package srv
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// checkerboard draws squares of the given size; larger squares mean fewer
+// edges, like a blurred frame
+func checkerboard(w, h, square int) []byte {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x/square+y/square)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestMeasureImage(t *testing.T) {
+	sharp, err := measureImage(checkerboard(200, 100, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sharp.Width != 200 || sharp.Height != 100 {
+		t.Errorf("size = %dx%d, want 200x100", sharp.Width, sharp.Height)
+	}
+	blurry, _ := measureImage(checkerboard(200, 100, 50))
+	if sharp.Sharpness <= blurry.Sharpness {
+		t.Errorf("sharpness %v <= %v for fewer edges", sharp.Sharpness, blurry.Sharpness)
+	}
+
+	flat, _ := measureImage(checkerboard(200, 100, 1000))
+	if flat.Sharpness != 0 || flat.Quality() != 0 {
+		t.Errorf("flat image measured %+v", flat)
+	}
+
+	// A larger frame of the same content scores higher
+	large, _ := measureImage(checkerboard(1280, 640, 26))
+	small, _ := measureImage(checkerboard(320, 160, 6))
+	if large.Quality() <= small.Quality() {
+		t.Errorf("quality %v <= %v for a larger frame", large.Quality(), small.Quality())
+	}
+
+	if _, err := measureImage([]byte("not an image")); err == nil {
+		t.Error("garbage decoded")
+	}
+}
+
+func TestClassifyImage(t *testing.T) {
+	for _, tc := range []struct {
+		w, h int
+		want string
+	}{
+		{520, 110, "plate"},     // EU single-row crop
+		{1200, 250, "plate"},    // large single-row crop
+		{400, 200, "plate"},     // US crop
+		{280, 200, "plate"},     // two-row / motorcycle crop
+		{1920, 1080, "vehicle"}, // 16:9 overview
+		{1280, 960, "vehicle"},  // 4:3 overview
+		{640, 480, "vehicle"},
+		{1000, 500, "vehicle"}, // 2:1 but too large for a US crop
+	} {
+		if got := classifyImage(imageMeasure{Width: tc.w, Height: tc.h}); got != tc.want {
+			t.Errorf("classifyImage(%dx%d) = %s, want %s", tc.w, tc.h, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,642 @@
+package srv
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db"
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	importPageSize = 500
+	importMaxImage = 64 << 20 // bytes of one image pulled from a remote instance
+)
+
+// errBlobStoreBackup refuses a backup whose files live in a blob store (see
+// blobstore.go): its database holds only their names
+var errBlobStoreBackup = errors.New("images are in the source's blob store; import via its URL")
+
+// importSource yields pages of events from another instance
+type importSource interface {
+	// nextPage returns the next batch of events and whether more follow
+	nextPage(ctx context.Context) ([]ExportEvent, bool, error)
+	imageData(ctx context.Context, img ExportImage) ([]byte, error)
+}
+
+// ImportResult summarizes an import run
+type ImportResult struct {
+	Imported int
+	Skipped  int // already present locally
+	Failed   int
+	Images   int
+	Archives int // local archives the events were merged into
+	Errors   []string
+}
+
+const importMaxErrors = 20
+
+func (r *ImportResult) fail(e ExportEvent, err error) {
+	r.Failed++
+	if len(r.Errors) < importMaxErrors {
+		r.Errors = append(r.Errors, fmt.Sprintf("event %d: %v", e.ID, err))
+	}
+}
+
+// remoteSource pages through another instance's /api/export/events
+type remoteSource struct {
+	base   *url.URL
+	apiKey string // sent with every request when the instance requires a login
+	client *http.Client
+	cursor string
+	node   string // X-Node-ID of the remote instance
+}
+
+func newRemoteSource(base, apiKey string) (*remoteSource, error) {
+	u, err := url.Parse(strings.TrimRight(base, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid instance URL %q", base)
+	}
+	return &remoteSource{
+		base:   u,
+		apiKey: strings.TrimSpace(apiKey),
+		client: &http.Client{Timeout: 2 * time.Minute},
+	}, nil
+}
+
+// get requests a URL of the remote instance with the API key
+func (rs *remoteSource) get(ctx context.Context, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rs.apiKey != "" {
+		req.Header.Set("X-API-Key", rs.apiKey)
+		req.Header.Set("Authorization", "Bearer "+rs.apiKey)
+	}
+	return rs.client.Do(req)
+}
+
+func (rs *remoteSource) nextPage(ctx context.Context) ([]ExportEvent, bool, error) {
+	query := url.Values{}
+	query.Set("images", "1")
+	query.Set("raw", "1")
+	query.Set("limit", fmt.Sprint(importPageSize))
+	if rs.cursor != "" {
+		query.Set("cursor", rs.cursor)
+	}
+	resp, err := rs.get(ctx, rs.base.String()+"/api/export/events?"+query.Encode())
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, false, fmt.Errorf("export returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if rs.node == "" {
+		rs.node = resp.Header.Get("X-Node-ID")
+	}
+
+	var events []ExportEvent
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var e ExportEvent
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, false, fmt.Errorf("decode export: %w", err)
+		}
+		events = append(events, e)
+	}
+	rs.cursor = resp.Header.Get("X-Next-Cursor")
+	return events, rs.cursor != "", nil
+}
+
+// imageData fetches an image from the instance; URLs pointing at any other
+// host are refused, so an export can't make us fetch from elsewhere
+func (rs *remoteSource) imageData(ctx context.Context, img ExportImage) ([]byte, error) {
+	ref, err := url.Parse(img.URL)
+	if err != nil {
+		return nil, fmt.Errorf("image %d: invalid URL %q", img.ID, img.URL)
+	}
+	u := rs.base.ResolveReference(ref)
+	if u.Host != rs.base.Host {
+		return nil, fmt.Errorf("image %d: URL %q isn't on %s", img.ID, img.URL, rs.base.Host)
+	}
+	resp, err := rs.get(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image %d: %s", img.ID, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, importMaxImage+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > importMaxImage {
+		return nil, fmt.Errorf("image %d: larger than %d MiB", img.ID, importMaxImage>>20)
+	}
+	return data, nil
+}
+
+// backupSource reads events from another instance's database file
+type backupSource struct {
+	db     *sql.DB
+	q      *dbgen.Queries
+	cursor int64
+	dir    string // temporary extraction directory
+}
+
+// openBackup extracts the database from a backup and migrates it to the
+// current schema. The backup is a tar (optionally gzipped) containing the
+// instance's db.sqlite3, or the bare database file. Images are read from
+// the database, so the data directory need not be included; a backup of an
+// instance with a blob store is refused, as its files are not in it.
+func openBackup(r io.Reader) (*backupSource, error) {
+	dir, err := os.MkdirTemp("", "mmrapi-import-")
+	if err != nil {
+		return nil, err
+	}
+	dbPath, err := extractBackup(r, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	sqlDB, err := db.Open(dbPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("open backup database: %w", err)
+	}
+	if err := db.RunMigrations(sqlDB); err != nil {
+		sqlDB.Close()
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("migrate backup database: %w", err)
+	}
+	q := dbgen.New(sqlDB)
+	if n, err := q.CountBlobStoredFiles(context.Background()); err != nil || n > 0 {
+		sqlDB.Close()
+		os.RemoveAll(dir)
+		if err != nil {
+			return nil, fmt.Errorf("read backup database: %w", err)
+		}
+		return nil, errBlobStoreBackup
+	}
+	return &backupSource{db: sqlDB, q: q, dir: dir}, nil
+}
+
+func extractBackup(r io.Reader, dir string) (string, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(16)
+	switch {
+	case bytes.HasPrefix(head, []byte("SQLite format 3\x00")):
+		dbPath := filepath.Join(dir, "db.sqlite3")
+		return dbPath, writeFile(dbPath, br)
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return "", fmt.Errorf("read gzip: %w", err)
+		}
+		defer gz.Close()
+		return extractTar(gz, dir)
+	default:
+		return extractTar(br, dir)
+	}
+}
+
+// extractTar pulls the first database file (and its WAL) out of a tar stream
+func extractTar(r io.Reader, dir string) (string, error) {
+	tr := tar.NewReader(r)
+	var dbName string
+	var wal []byte
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("read tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Base(hdr.Name)
+		switch {
+		case dbName == "" && (strings.HasSuffix(name, ".sqlite3") || strings.HasSuffix(name, ".sqlite") || strings.HasSuffix(name, ".db")):
+			dbName = name
+			if err := writeFile(filepath.Join(dir, name), tr); err != nil {
+				return "", err
+			}
+		case strings.HasSuffix(name, "-wal"):
+			// The WAL may come before or after its database in the tar
+			if wal, err = io.ReadAll(tr); err != nil {
+				return "", fmt.Errorf("read tar: %w", err)
+			}
+		}
+	}
+	if dbName == "" {
+		return "", errors.New("no database file (*.sqlite3) found in backup")
+	}
+	if wal != nil {
+		if err := os.WriteFile(filepath.Join(dir, dbName+"-wal"), wal, 0600); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, dbName), nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("extract %s: %w", filepath.Base(path), err)
+	}
+	return f.Close()
+}
+
+func (b *backupSource) close() {
+	b.db.Close()
+	os.RemoveAll(b.dir)
+}
+
+func (b *backupSource) nextPage(ctx context.Context) ([]ExportEvent, bool, error) {
+	rows, err := b.q.ExportEvents(ctx, dbgen.ExportEventsParams{
+		Cursor: b.cursor,
+		Until:  time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC).In(time.Local),
+		Limit:  importPageSize + 1,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(rows) > importPageSize
+	if hasMore {
+		rows = rows[:importPageSize]
+	}
+	if len(rows) == 0 {
+		return nil, false, nil
+	}
+
+	ids := make([]int64, len(rows))
+	for i, e := range rows {
+		ids[i] = e.ID
+	}
+	imgs, err := b.q.GetImagesByEventIDs(ctx, ids)
+	if err != nil {
+		return nil, false, err
+	}
+	images := make(map[int64][]ExportImage)
+	for _, img := range imgs {
+		images[img.EventID] = append(images[img.EventID], ExportImage{
+			ID:       img.ID,
+			Type:     img.ImageType,
+			Filename: img.Filename,
+		})
+	}
+
+	events := make([]ExportEvent, len(rows))
+	for i, e := range rows {
+		events[i] = newExportEvent(e)
+		events[i].Images = images[e.ID]
+		if e.RawJson != nil && json.Valid([]byte(*e.RawJson)) {
+			events[i].RawJSON = json.RawMessage(*e.RawJson)
+		}
+	}
+	b.cursor = rows[len(rows)-1].ID
+	return events, hasMore, nil
+}
+
+func (b *backupSource) imageData(ctx context.Context, img ExportImage) ([]byte, error) {
+	row, err := b.q.GetImageData(ctx, img.ID)
+	if err == nil && len(row.ImageData) == 0 && row.DiskFilename != nil {
+		return nil, errBlobStoreBackup
+	}
+	return row.ImageData, err
+}
+
+// runImport merges events from src into the local database. Events keep
+// their node ID and ULID and remember their ID on that node, so importing
+// the same data twice (or data that originally came from this node) is a
+// no-op.
+// Each source archive, and the source's current session, becomes a local
+// archive. fallbackNode is used for events that carry no node ID.
+func (s *Server) runImport(ctx context.Context, src importSource, fallbackNode string) (ImportResult, error) {
+	var res ImportResult
+	q := dbgen.New(s.DB)
+	archives := make(map[string]int64) // source node + archive → local archive
+
+	for {
+		events, more, err := src.nextPage(ctx)
+		if err != nil {
+			return res, err
+		}
+		if rs, ok := src.(*remoteSource); ok && fallbackNode == "" {
+			fallbackNode = rs.node
+		}
+
+		for _, e := range events {
+			node := fallbackNode
+			if e.NodeID != nil && *e.NodeID != "" {
+				node = *e.NodeID
+			}
+			if node == "" {
+				return res, errors.New("source events have no node ID; pass node= to name the source")
+			}
+			if node == s.NodeID {
+				res.Skipped++ // recorded here originally
+				continue
+			}
+			originID := e.ID
+			if e.OriginID != nil {
+				originID = *e.OriginID
+			}
+			if e.UID != nil {
+				if _, err := q.FindEventByUID(ctx, e.UID); err == nil {
+					res.Skipped++
+					continue
+				} else if !errors.Is(err, sql.ErrNoRows) {
+					return res, err
+				}
+			}
+			if _, err := q.FindEventByOrigin(ctx, dbgen.FindEventByOriginParams{NodeID: &node, OriginID: &originID}); err == nil {
+				res.Skipped++
+				continue
+			} else if !errors.Is(err, sql.ErrNoRows) {
+				return res, err
+			}
+
+			key := node + "/current"
+			if e.ArchiveID != nil {
+				key = fmt.Sprintf("%s/%d", node, *e.ArchiveID)
+			}
+			archiveID, ok := archives[key]
+			if !ok {
+				archiveID, err = s.importArchive(ctx, q, node, e.ArchiveID)
+				if err != nil {
+					return res, fmt.Errorf("create archive: %w", err)
+				}
+				archives[key] = archiveID
+			}
+
+			n, err := s.importEvent(ctx, src, e, node, originID, archiveID)
+			if err != nil {
+				res.fail(e, err)
+				continue
+			}
+			res.Imported++
+			res.Images += n
+		}
+		if !more {
+			break
+		}
+	}
+
+	for _, id := range archives {
+		if err := q.RefreshArchiveEventCount(ctx, id); err != nil {
+			slog.Warn("failed to update archive event count", "archive_id", id, "error", err)
+		}
+	}
+	res.Archives = len(archives)
+	return res, nil
+}
+
+// importArchive finds or creates the local archive for a source archive.
+// A nil sourceID stands for the source's current session.
+func (s *Server) importArchive(ctx context.Context, q *dbgen.Queries, node string, sourceID *int64) (int64, error) {
+	id, err := q.GetImportedArchive(ctx, dbgen.GetImportedArchiveParams{OriginNode: node, OriginID: sourceID})
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	name := fmt.Sprintf("Import from %s (current session)", node)
+	if sourceID != nil {
+		name = fmt.Sprintf("Import from %s #%d", node, *sourceID)
+	}
+	id, err = q.CreateArchive(ctx, dbgen.CreateArchiveParams{
+		Name:      &name,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = q.CreateImportedArchive(ctx, dbgen.CreateImportedArchiveParams{
+		ArchiveID:  id,
+		OriginNode: node,
+		OriginID:   sourceID,
+	})
+	return id, err
+}
+
+// importEvent stores one source event with its images and returns the
+// number of images saved
+func (s *Server) importEvent(ctx context.Context, src importSource, e ExportEvent, node string, originID, archiveID int64) (int, error) {
+	// Fetch images before opening the transaction; the source may be remote
+	type imageFile struct {
+		imgType, filename string
+		data              []byte
+	}
+	var files []imageFile
+	for _, img := range e.Images {
+		data, err := src.imageData(ctx, img)
+		if err != nil {
+			return 0, err
+		}
+		imgType, filename := "uploaded", fmt.Sprintf("image_%d.jpg", img.ID)
+		if img.Type != nil {
+			imgType = *img.Type
+		}
+		if img.Filename != nil {
+			filename = *img.Filename
+		}
+		files = append(files, imageFile{imgType, filename, data})
+	}
+
+	var rawJSON *string
+	if len(e.RawJSON) > 0 {
+		rawJSON = ptr(string(e.RawJSON))
+	}
+	source := e.Source
+	if source == "" {
+		source = "camera"
+	}
+	uid := e.UID
+	if uid == nil {
+		uid = ptr(newULID(e.CreatedAt))
+	}
+	// Older exports don't carry the parsed capture time
+	capturedAt, timestampError := e.CapturedAt, e.TimestampError
+	if capturedAt == nil && timestampError == nil {
+		capturedAt, timestampError = s.captureTime(e.EventDatetime, e.CaptureTimestamp, time.Now())
+	} else if capturedAt != nil {
+		capturedAt = ptr(capturedAt.In(time.Local))
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(s.DB).WithTx(tx)
+
+	eventID, err := q.ImportEvent(ctx, dbgen.ImportEventParams{
+		CarID:            e.CarID,
+		PlateUtf8:        e.Plate,
+		CarState:         e.CarState,
+		SensorProviderID: e.SensorProviderID,
+		EventDatetime:    e.EventDatetime,
+		CaptureTimestamp: e.CaptureTimestamp,
+		PlateCountry:     e.PlateCountry,
+		PlateRegion:      e.PlateRegion,
+		PlateRegionCode:  e.PlateRegionCode,
+		PlateConfidence:  e.PlateConfidence,
+		GeotagLat:        e.GeotagLat,
+		GeotagLon:        e.GeotagLon,
+		VehicleMake:      e.Vehicle.Make,
+		VehicleModel:     e.Vehicle.Model,
+		VehicleColor:     e.Vehicle.Color,
+		VehicleType:      e.Vehicle.Type,
+		ConfidenceMmr:    e.Vehicle.ConfidenceMMR,
+		ConfidenceColor:  e.Vehicle.ConfidenceColor,
+		CameraSerial:     e.Camera.Serial,
+		CameraIp:         e.Camera.IP,
+		Lane:             e.Lane,
+		Direction:        e.Direction,
+		RawJson:          rawJSON,
+		Unrecognized:     e.Unrecognized,
+		ManualPlate:      e.ManualPlate,
+		Source:           source,
+		NodeID:           &node,
+		OriginID:         &originID,
+		Uid:              uid,
+		ArchiveID:        &archiveID,
+		CapturedAt:       capturedAt,
+		TimestampError:   timestampError,
+		ArrivalDelayMs:   arrivalDelay(capturedAt, e.CreatedAt),
+		CreatedAt:        e.CreatedAt.In(time.Local),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	plate := ""
+	if e.Plate != nil {
+		plate = *e.Plate
+	}
+	for _, f := range files {
+		if _, err := s.saveImage(ctx, q, eventID, f.imgType, f.filename, plate, f.data, e.CreatedAt.In(time.Local)); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	if len(files) > 0 {
+		s.queueMeasure()
+		s.resetQuotaUsage()
+	}
+
+	if rawJSON != nil {
+		safePlate := sanitizeFilename(plate)
+		if safePlate == "" {
+			safePlate = "unknown"
+		}
+		jsonFilename := fmt.Sprintf("%d_%s.json", eventID, safePlate)
+		s.storeEventJSON(ctx, dbgen.New(s.DB), eventID, jsonFilename, e.RawJSON)
+	}
+	return len(files), nil
+}
+
+// HandleImport merges events from another instance.
+//
+// With a JSON body {"url": "http://other-box:8000", "api_key": "mmr_..."}
+// the events and images are pulled from that instance's export API, the key
+// (needed when it requires a login) sent with each request. Any other body is read as a
+// backup: a tar/tar.gz containing db.sqlite3, or the database file itself.
+// ?node= names the source when its events carry no node ID.
+func (s *Server) HandleImport(w http.ResponseWriter, r *http.Request) {
+	node := r.URL.Query().Get("node")
+
+	var src importSource
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req struct {
+			URL    string `json:"url"`
+			APIKey string `json:"api_key"`
+			Node   string `json:"node"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		rs, err := newRemoteSource(req.URL, req.APIKey)
+		if err != nil {
+			s.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Node != "" {
+			node = req.Node
+		}
+		src = rs
+	} else {
+		bs, err := openBackup(r.Body)
+		if err != nil {
+			s.jsonError(w, "invalid backup: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer bs.close()
+		src = bs
+	}
+
+	res, err := s.runImport(r.Context(), src, node)
+	if err != nil {
+		slog.Error("import failed", "error", err, "imported", res.Imported)
+		w.Header().Set("Content-Type", "application/json")
+		status := http.StatusInternalServerError
+		if _, ok := src.(*remoteSource); ok {
+			status = http.StatusBadGateway
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success":  false,
+			"message":  fmt.Sprintf("import stopped after %d events: %v", res.Imported, err),
+			"imported": res.Imported,
+			"skipped":  res.Skipped,
+		})
+		return
+	}
+
+	slog.Info("import finished", "imported", res.Imported, "skipped", res.Skipped, "failed", res.Failed, "images", res.Images)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  res.Failed == 0,
+		"message":  fmt.Sprintf("imported %d events, skipped %d, failed %d", res.Imported, res.Skipped, res.Failed),
+		"imported": res.Imported,
+		"skipped":  res.Skipped,
+		"failed":   res.Failed,
+		"images":   res.Images,
+		"archives": res.Archives,
+		"errors":   res.Errors,
+	})
+}
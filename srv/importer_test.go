@@ -0,0 +1,166 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestImportBackup(t *testing.T) {
+	src := newTestServer(t)
+	src.NodeID = "site-a"
+	ctx := context.Background()
+	for _, body := range []string{`{"plateUTF8":"AB123","carID":"1"}`, `{"plateUTF8":"CD456","carID":"2"}`} {
+		if _, err := src.ingestEvent(ctx, newIngestRequest([]byte(body), "", []uploadedImage{
+			{Filename: "plate.png", Data: pngOf(100, 20)},
+			{Filename: "vehicle.png", Data: pngOf(300, 200)},
+		})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src.background.Wait()
+	backup := filepath.Join(t.TempDir(), "backup.sqlite3")
+	if _, err := src.DB.Exec("VACUUM INTO ?", backup); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t)
+	s.NodeID = "site-b"
+	// Local rows first, so imported image IDs differ from the source's
+	if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"EF789"}`), "", []uploadedImage{{Filename: "plate.png", Data: pngOf(90, 20)}})); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	s.HandleImport(w, httptest.NewRequest("POST", "/api/import", bytes.NewReader(data)))
+	var res struct {
+		Imported int `json:"imported"`
+		Images   int `json:"images"`
+	}
+	json.NewDecoder(w.Body).Decode(&res)
+	if w.Code != http.StatusOK || res.Imported != 2 || res.Images != 4 {
+		t.Fatalf("status %d: %+v", w.Code, res)
+	}
+
+	rows, err := s.DB.Query("SELECT id, filename, disk_filename, image_data FROM images WHERE event_id IN (SELECT id FROM events WHERE node_id = 'site-a') ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var id int64
+		var filename, disk string
+		var stored []byte
+		if err := rows.Scan(&id, &filename, &disk, &stored); err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if !strings.HasPrefix(disk, strconv.FormatInt(id, 10)+"_") {
+			t.Errorf("image %d (%s) is stored as %s", id, filename, disk)
+		}
+		file, err := os.ReadFile(filepath.Join(s.DataDir, "images", disk))
+		if err != nil || !bytes.Equal(file, stored) {
+			t.Errorf("image %d: file %s differs from its row (%v)", id, disk, err)
+		}
+	}
+	if n != 4 {
+		t.Errorf("%d imported images", n)
+	}
+}
+
+func TestImportBlobStoreBackup(t *testing.T) {
+	src := newTestServer(t)
+	src.NodeID = "site-a"
+	ctx := context.Background()
+	if _, err := src.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"AB123"}`), "", []uploadedImage{{Filename: "plate.png", Data: pngOf(100, 20)}})); err != nil {
+		t.Fatal(err)
+	}
+	src.background.Wait()
+
+	s := newTestServer(t)
+	s.NodeID = "site-b"
+	// With a blob store the database keeps only the file names
+	for _, moved := range []string{
+		"UPDATE events SET raw_json = NULL",
+		"UPDATE images SET image_data = X''",
+	} {
+		if _, err := src.DB.Exec(moved); err != nil {
+			t.Fatal(err)
+		}
+		backup := filepath.Join(t.TempDir(), "backup.sqlite3")
+		if _, err := src.DB.Exec("VACUUM INTO ?", backup); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(backup)
+		w := httptest.NewRecorder()
+		s.HandleImport(w, httptest.NewRequest("POST", "/api/import", bytes.NewReader(data)))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "blob store; import via its URL") {
+			t.Errorf("%s: %d %s", moved, w.Code, w.Body)
+		}
+	}
+	var n int
+	s.DB.QueryRow("SELECT COUNT(*) FROM events").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d events imported", n)
+	}
+}
+
+func TestImportRemote(t *testing.T) {
+	src := newTestServer(t)
+	src.NodeID = "site-a"
+	src.RequireLogin = true
+	src.SessionTTL = time.Hour
+	ctx := context.Background()
+	key, hash, prefix := newAPIKey()
+	dbgen.New(src.DB).CreateAPIKey(ctx, dbgen.CreateAPIKeyParams{Name: "import", KeyHash: hash, KeyPrefix: prefix, CreatedAt: time.Now()})
+	if _, err := src.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"RM123"}`), "", []uploadedImage{{Filename: "plate.png", Data: pngOf(100, 20)}})); err != nil {
+		t.Fatal(err)
+	}
+	src.background.Wait()
+	mux := http.NewServeMux()
+	src.keyRoute(mux, "GET /api/export/events", src.HandleExportEvents)
+	src.keyRoute(mux, "GET /image/{id}", src.HandleImage)
+	remote := httptest.NewServer(src.requireLogin(mux))
+	defer remote.Close()
+
+	s := newTestServer(t)
+	pull := func(body string) (int, map[string]any) {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/api/import", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.HandleImport(w, r)
+		var res map[string]any
+		json.NewDecoder(w.Body).Decode(&res)
+		return w.Code, res
+	}
+	if code, res := pull(`{"url": "` + remote.URL + `"}`); code != http.StatusBadGateway || !strings.Contains(res["message"].(string), "login required") {
+		t.Errorf("without key: %d %v", code, res)
+	}
+	if code, res := pull(`{"url": "` + remote.URL + `", "api_key": "` + key + `"}`); code != http.StatusOK || res["imported"] != 1.0 || res["images"] != 1.0 {
+		t.Errorf("with key: %d %v", code, res)
+	}
+
+	// Image URLs are only followed on the instance's own host
+	rs, _ := newRemoteSource(remote.URL, key)
+	if _, err := rs.imageData(ctx, ExportImage{ID: 1, URL: "http://elsewhere.example/image/1"}); err == nil || !strings.Contains(err.Error(), "isn't on") {
+		t.Errorf("other host: %v", err)
+	}
+	if data, err := rs.imageData(ctx, ExportImage{ID: 1, URL: "/image/1"}); err != nil || len(data) == 0 {
+		t.Errorf("relative URL: %d bytes, %v", len(data), err)
+	}
+}
@@ -0,0 +1,322 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cameras whose firmware can only push results as files drop them into an
+// inbox directory (-inbox-config), either through the embedded FTP server
+// (see ftp.go) or any FTP/SFTP server writing there, e.g. OpenSSH with
+// ChrootDirectory and ForceCommand internal-sftp:
+//
+//	{"dir": "/srv/mmrapi/inbox", "ftp_addr": ":2121", "username": "camera", "password": "..."}
+//
+// The directory is scanned every second. A JSON or XML file unchanged for
+// settle seconds is ingested like a POST /api upload, together with the
+// JPEG and PNG files next to it whose names start with its name (123.json,
+// 123_plate.jpg, 123-overview.png). Images without a JSON after image_wait
+// seconds are stored as image-only events. Stored files are removed;
+// files of rejected uploads move to failed/ for inspection. Hidden files
+// and names ending in .part or .tmp are uploads still in progress.
+
+// InboxConfig is the file drop receiver
+type InboxConfig struct {
+	Dir          string `json:"dir"`
+	Settle       int    `json:"settle"`     // seconds a file must be unchanged before it is read (default 2)
+	ImageWait    int    `json:"image_wait"` // seconds images wait for their JSON (default 30)
+	FTPAddr      string `json:"ftp_addr"`   // embedded FTP server, e.g. :2121; empty = none
+	Username     string `json:"username"`   // FTP login
+	Password     string `json:"password"`
+	PassivePorts string `json:"passive_ports"` // FTP data ports, e.g. 30000-30009 (default any)
+	PublicIP     string `json:"public_ip"`     // address announced for passive connections (default the one the camera connected to)
+
+	portLow, portHigh int
+
+	mu       sync.Mutex
+	received int64 // events stored
+	failed   int64 // uploads moved to failed/
+	uploads  int64 // files received over FTP
+	lastScan time.Time
+	lastErr  error
+}
+
+// LoadInboxConfig reads an inbox receiver config file
+func LoadInboxConfig(path string) (*InboxConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg InboxConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// compile checks the config and fills in defaults
+func (c *InboxConfig) compile() error {
+	if c.Dir == "" {
+		return fmt.Errorf("dir is required")
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	if c.Settle <= 0 {
+		c.Settle = 2
+	}
+	if c.ImageWait <= 0 {
+		c.ImageWait = 30
+	}
+	if c.FTPAddr == "" {
+		return nil
+	}
+	if c.Username == "" || c.Password == "" {
+		return fmt.Errorf("ftp_addr needs a username and password")
+	}
+	if c.PassivePorts != "" {
+		if _, err := fmt.Sscanf(c.PassivePorts, "%d-%d", &c.portLow, &c.portHigh); err != nil ||
+			c.portLow < 1024 || c.portHigh > 65535 || c.portLow > c.portHigh {
+			return fmt.Errorf("passive_ports must be a range like 30000-30009")
+		}
+	}
+	return nil
+}
+
+// inboxFile is a file waiting in the inbox
+type inboxFile struct {
+	path    string
+	modTime time.Time
+	image   bool
+}
+
+// runInbox ingests files dropped into the inbox until ctx is done
+func (s *Server) runInbox(ctx context.Context) {
+	c := s.Inbox
+	slog.Info("inbox enabled", "dir", c.Dir, "ftp", c.FTPAddr)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		err := s.scanInbox(ctx, time.Now())
+		c.mu.Lock()
+		c.lastScan, c.lastErr = time.Now(), err
+		c.mu.Unlock()
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("inbox scan failed", "dir", c.Dir, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scanInbox ingests the uploads in the inbox that are complete at now
+func (s *Server) scanInbox(ctx context.Context, now time.Time) error {
+	c := s.Inbox
+	failedDir := filepath.Join(c.Dir, "failed")
+	byDir := make(map[string][]inboxFile)
+	err := filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path == failedDir || (path != c.Dir && strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".part") || strings.HasSuffix(name, ".tmp") {
+			return nil
+		}
+		var image bool
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".json", ".xml":
+		case ".jpg", ".jpeg", ".png":
+			image = true
+		default:
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed meanwhile
+		}
+		dir := filepath.Dir(path)
+		byDir[dir] = append(byDir[dir], inboxFile{path: path, modTime: info.ModTime(), image: image})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	settled := func(f inboxFile, wait int) bool {
+		return now.Sub(f.modTime) >= time.Duration(wait)*time.Second
+	}
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		files := byDir[dir]
+		sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+		claimed := make(map[string]bool)
+		for _, f := range files {
+			if f.image {
+				continue
+			}
+			stem := strings.TrimSuffix(f.path, filepath.Ext(f.path))
+			group := []inboxFile{f}
+			ready := settled(f, c.Settle)
+			for _, img := range files {
+				if img.image && !claimed[img.path] && inboxBelongsTo(img.path, stem) {
+					group = append(group, img)
+					claimed[img.path] = true
+					ready = ready && settled(img, c.Settle)
+				}
+			}
+			if !ready {
+				continue
+			}
+			if err := s.ingestInboxGroup(ctx, group); err != nil {
+				return err
+			}
+		}
+		for _, f := range files {
+			if f.image && !claimed[f.path] && settled(f, c.ImageWait) {
+				if err := s.ingestInboxGroup(ctx, []inboxFile{f}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// inboxBelongsTo reports whether an image file is named after a JSON stem
+func inboxBelongsTo(image, stem string) bool {
+	rest, ok := strings.CutPrefix(image, stem)
+	return ok && rest != "" && strings.ContainsRune("_-.", rune(rest[0]))
+}
+
+// ingestInboxGroup stores a JSON file with its images, or a lone image.
+// Only server failures are returned; the files of rejected uploads are
+// moved to failed/.
+func (s *Server) ingestInboxGroup(ctx context.Context, group []inboxFile) error {
+	c := s.Inbox
+	var rawJSON []byte
+	var jsonFilename string
+	var images []uploadedImage
+	for _, f := range group {
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(f.path)
+		if !f.image {
+			rawJSON, jsonFilename = data, name
+			continue
+		}
+		images = append(images, uploadedImage{Filename: name, Data: data})
+	}
+	rawJSON, jsonFilename, err := s.vendorEvent("", rawJSON, jsonFilename, images)
+	var res ingestResult
+	if err == nil {
+		res, err = s.ingest(ctx, newIngestRequest(rawJSON, jsonFilename, images))
+	}
+	if errors.Is(err, errNotANPR) {
+		// Heartbeats and other alarms dropped by the camera are discarded
+		slog.Debug("inbox upload ignored", "file", group[0].path, "reason", err)
+		for _, f := range group {
+			if err := os.Remove(f.path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var pe *payloadError
+	if err != nil && !errors.As(err, &pe) {
+		return fmt.Errorf("ingest %s: %w", group[0].path, err)
+	}
+
+	c.mu.Lock()
+	if err != nil {
+		c.failed++
+	} else {
+		c.received++
+	}
+	c.mu.Unlock()
+	if err != nil {
+		slog.Warn("inbox upload rejected", "file", group[0].path, "error", pe.msg)
+		failedDir := filepath.Join(c.Dir, "failed")
+		if err := os.MkdirAll(failedDir, 0755); err != nil {
+			return err
+		}
+		for _, f := range group {
+			if err := os.Rename(f.path, filepath.Join(failedDir, filepath.Base(f.path))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	slog.Debug("inbox upload stored", "file", group[0].path, "id", res.ID)
+	for _, f := range group {
+		if err := os.Remove(f.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inboxStatus is the GET /api/inbox response
+type inboxStatus struct {
+	Dir      string     `json:"dir"`
+	FTPAddr  string     `json:"ftp_addr,omitempty"`
+	Received int64      `json:"received"`
+	Failed   int64      `json:"failed"`
+	Uploads  int64      `json:"ftp_uploads"`
+	LastScan *time.Time `json:"last_scan,omitempty"`
+	Error    string     `json:"last_error,omitempty"`
+}
+
+// HandleInboxAPI reports the file drop receiver's counts
+func (s *Server) HandleInboxAPI(w http.ResponseWriter, r *http.Request) {
+	c := s.Inbox
+	if c == nil {
+		s.jsonError(w, "no inbox is configured (-inbox-config)", http.StatusNotFound)
+		return
+	}
+	c.mu.Lock()
+	st := inboxStatus{
+		Dir:      c.Dir,
+		FTPAddr:  c.FTPAddr,
+		Received: c.received,
+		Failed:   c.failed,
+		Uploads:  c.uploads,
+	}
+	if !c.lastScan.IsZero() {
+		st.LastScan = ptr(c.lastScan)
+	}
+	if c.lastErr != nil {
+		st.Error = c.lastErr.Error()
+	}
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,185 @@
+package srv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInboxScan(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.DataDir
+	cfg := &InboxConfig{Dir: filepath.Join(dir, "inbox")}
+	if err := cfg.compile(); err != nil {
+		t.Fatal(err)
+	}
+	s.Inbox = cfg
+
+	var pic bytes.Buffer
+	png.Encode(&pic, image.NewGray(image.Rect(0, 0, 8, 8)))
+	now := time.Now()
+	drop := func(name, data string, age time.Duration) {
+		t.Helper()
+		path := filepath.Join(cfg.Dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, now.Add(-age), now.Add(-age))
+	}
+	drop("cam1/123.json", `{"plateUTF8":"IN123"}`, 5*time.Second)
+	drop("cam1/123_plate.png", pic.String(), 5*time.Second)
+	drop("cam1/1234_plate.png", pic.String(), 5*time.Second) // not 123's, and still waiting for its JSON
+	drop("cam1/124.json", `{"plateUTF8":"IN124"}`, 0)        // still being written
+	drop("cam2/bad.json", `not json`, time.Minute)
+	drop("cam2/trigger.png", pic.String(), time.Minute)
+	drop("cam2/.upload.json.part", `{}`, time.Minute)
+	drop("cam2/notes.txt", `hi`, time.Minute)
+
+	if err := s.scanInbox(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := s.DB.Query("SELECT COALESCE(e.plate_utf8, ''), COUNT(i.id) FROM events e LEFT JOIN images i ON i.event_id = e.id GROUP BY e.id ORDER BY e.id")
+	var got []string
+	for rows.Next() {
+		var plate string
+		var images int
+		rows.Scan(&plate, &images)
+		got = append(got, fmt.Sprintf("%s:%d", plate, images))
+	}
+	rows.Close()
+	if strings.Join(got, " ") != "IN123:1 :1" {
+		t.Errorf("stored %v, want IN123 with its image and the lone trigger image", got)
+	}
+
+	left := func(name string) bool {
+		_, err := os.Stat(filepath.Join(cfg.Dir, name))
+		return err == nil
+	}
+	for name, want := range map[string]bool{
+		"cam1/123.json": false, "cam1/123_plate.png": false, "cam2/trigger.png": false,
+		"cam1/1234_plate.png": true, "cam1/124.json": true, "cam2/.upload.json.part": true, "cam2/notes.txt": true,
+		"cam2/bad.json": false, "failed/bad.json": true,
+	} {
+		if left(name) != want {
+			t.Errorf("%s left: %v, want %v", name, !want, want)
+		}
+	}
+	if cfg.received != 2 || cfg.failed != 1 {
+		t.Errorf("received %d, failed %d", cfg.received, cfg.failed)
+	}
+}
+
+func TestFTPUpload(t *testing.T) {
+	cfg := &InboxConfig{Dir: t.TempDir(), FTPAddr: "127.0.0.1:0", Username: "cam", Password: "secret"}
+	if err := cfg.compile(); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Inbox: cfg}
+	ln, err := s.listenFTP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.background.Go(func() { s.runFTP(ctx, ln) })
+	defer func() {
+		cancel()
+		s.background.Wait()
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+	expect := func(code string) string {
+		t.Helper()
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, code+" ") {
+			t.Fatalf("reply %q, want %s", line, code)
+		}
+		return line
+	}
+	send := func(cmd, code string) string {
+		t.Helper()
+		fmt.Fprintf(conn, "%s\r\n", cmd)
+		return expect(code)
+	}
+	expect("220")
+	send("STOR x.json", "530")
+	send("USER cam", "331")
+	send("PASS wrong", "530")
+	send("USER cam", "331")
+	send("PASS secret", "230")
+	send("MKD CAM1", "257")
+	send("CWD CAM1", "250")
+	send("CWD ../../..", "250")
+	send("PWD", "257")
+	send("CWD /CAM1", "250")
+
+	reply := send("EPSV", "229")
+	var port int
+	fmt.Sscanf(reply[strings.Index(reply, "|||")+3:], "%d", &port)
+	data, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	send("STOR 77.json", "150")
+	data.Write([]byte(`{"plateUTF8":"FTP77"}`))
+	data.Close()
+	expect("226")
+
+	// A passive data connection from another address is dropped
+	reply = send("PASV", "227")
+	var h [6]int
+	fmt.Sscanf(reply[strings.Index(reply, "(")+1:], "%d,%d,%d,%d,%d,%d", &h[0], &h[1], &h[2], &h[3], &h[4], &h[5])
+	dataAddr := fmt.Sprintf("127.0.0.1:%d", h[4]<<8|h[5])
+	stranger, err := (&net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.2")}}).Dial("tcp", dataAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stranger.Close()
+	data, err = net.Dial("tcp", dataAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send("STOR 78.json", "150")
+	stranger.Write([]byte(`{"plateUTF8":"EVIL"}`))
+	data.Write([]byte(`{"plateUTF8":"FTP78"}`))
+	data.Close()
+	expect("226")
+	stranger.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := stranger.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("stranger's connection: %v", err)
+	}
+	send("QUIT", "221")
+
+	got, err := os.ReadFile(filepath.Join(cfg.Dir, "CAM1", "77.json"))
+	if err != nil || string(got) != `{"plateUTF8":"FTP77"}` {
+		t.Errorf("uploaded %q, %v", got, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(cfg.Dir, "CAM1", "78.json")); string(got) != `{"plateUTF8":"FTP78"}` {
+		t.Errorf("second upload %q", got)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(cfg.Dir, "CAM1")); len(entries) != 2 {
+		t.Errorf("%d files in the upload directory, want only the uploads", len(entries))
+	}
+	if cfg.uploads != 2 {
+		t.Errorf("uploads %d, want 2", cfg.uploads)
+	}
+}
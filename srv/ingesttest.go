@@ -0,0 +1,330 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// The ingest test page (/test) lets an engineer drop a camera JSON file and
+// images into the browser and see how the server would read them, field by
+// field, without a camera and without storing anything. POST
+// /api/test/parse runs the same parsing and normalization as /api and
+// reports the result. POST /api/validate is the same dry run for camera
+// integrators: it takes the API key /api takes and answers 400 when /api
+// would reject the payload.
+
+// parsedField is one events column as the upload would fill it
+type parsedField struct {
+	Column string `json:"column"`
+	Source string `json:"source,omitempty"` // JSON key the value came from; "derived" or "generated" otherwise
+	Raw    any    `json:"raw,omitempty"`    // value as sent
+	Value  any    `json:"value"`            // value as stored
+}
+
+// parsedImage is one image as the upload would store it
+type parsedImage struct {
+	Source     string     `json:"source"` // "multipart" or ImageArray[i]
+	Filename   string     `json:"filename"`
+	Type       string     `json:"type"`
+	Classified string     `json:"classified,omitempty"` // shape-based guess for images not tagged plate or vehicle
+	Bytes      int        `json:"bytes"`
+	Format     string     `json:"format,omitempty"`
+	Width      int        `json:"width,omitempty"`
+	Height     int        `json:"height,omitempty"`
+	Sharpness  float64    `json:"sharpness,omitempty"`
+	Quality    float64    `json:"quality,omitempty"`
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// parseReport is the dry-run result for one upload
+type parseReport struct {
+	Valid         bool          `json:"valid"`
+	JSONFilename  string        `json:"json_filename,omitempty"`
+	Error         string        `json:"error,omitempty"` // why the upload would be rejected
+	Fields        []parsedField `json:"fields"`
+	Images        []parsedImage `json:"images"`
+	Camera        string        `json:"camera,omitempty"`
+	Unrecognized  bool          `json:"unrecognized"`
+	Late          bool          `json:"late"`
+	MergeInto     *int64        `json:"merge_into,omitempty"` // open event a continuation would be merged into
+	UnknownFields []string      `json:"unknown_fields"`       // JSON keys no column is read from
+	Warnings      []string      `json:"warnings"`
+	JSON          any           `json:"json,omitempty"`
+}
+
+// HandleIngestTestPage shows the drag-and-drop ingest test page
+func (s *Server) HandleIngestTestPage(w http.ResponseWriter, r *http.Request) {
+	if err := s.renderTemplate(w, "ingest_test.html", nil); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleIngestTestParse parses an upload like /api does and reports how each
+// field would be stored, without storing it
+func (s *Server) HandleIngestTestParse(w http.ResponseWriter, r *http.Request) {
+	req, ignored, err := s.readUpload(r, r.URL.Query().Get("vendor"))
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report := s.parseReport(r.Context(), req, ignored)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// HandleValidate lints a camera payload: it accepts what /api accepts and
+// returns what would be stored, unknown fields and warnings, without
+// storing anything
+func (s *Server) HandleValidate(w http.ResponseWriter, r *http.Request) {
+	req, ignored, err := s.readUpload(r, r.URL.Query().Get("vendor"))
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report := s.parseReport(r.Context(), req, ignored)
+	report.JSON = nil // the caller sent it
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseReport describes how req would be ingested
+func (s *Server) parseReport(ctx context.Context, req ingestRequest, ignored []string) parseReport {
+	report := parseReport{JSONFilename: req.JSONFilename, Fields: []parsedField{}, Images: []parsedImage{}, UnknownFields: []string{}, Warnings: []string{}}
+	warn := func(format string, args ...any) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	for _, name := range ignored {
+		warn("part %s ignored: neither event JSON nor a JPEG or PNG image", name)
+	}
+
+	var raw map[string]any
+	if len(req.RawJSON) > 0 {
+		if err := json.Unmarshal(req.RawJSON, &raw); err == nil {
+			report.JSON = raw
+			report.UnknownFields = append(report.UnknownFields, unknownKeys(raw, reflect.TypeFor[IncomingEvent](), "")...)
+		}
+	} else {
+		warn("no JSON: the event would be stored as unrecognized with its images only")
+	}
+
+	p, err := s.parseEvent(req)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Valid = true
+	e, params := p.Event, p.Params
+	source := func(keys ...string) (string, any) {
+		for _, k := range keys {
+			if v, key, ok := lookupKey(raw, k); ok && v != "" && v != nil {
+				return key, v
+			}
+		}
+		return "", nil
+	}
+	derived := func(column string, value any) {
+		report.Fields = append(report.Fields, parsedField{Column: column, Source: "derived", Value: value})
+	}
+
+	for _, f := range ingestFields {
+		pf := parsedField{Column: f.Column, Value: f.value(params)}
+		pf.Source, pf.Raw = source(f.Keys...)
+		report.Fields = append(report.Fields, pf)
+		if f.Column != "capture_timestamp" {
+			continue
+		}
+		captured := parsedField{Column: "captured_at", Value: params.CapturedAt, Source: "derived"}
+		if params.CapturedAt != nil {
+			captured.Source = "datetime"
+			if t, _ := s.captureTime(nil, params.CaptureTimestamp, req.ReceivedAt); t != nil {
+				captured.Source = "capture_timestamp"
+			}
+		}
+		report.Fields = append(report.Fields, captured)
+		if params.TimestampError != nil {
+			derived("timestamp_error", params.TimestampError)
+		}
+		derived("arrival_delay_ms", params.ArrivalDelayMs)
+	}
+	derived("unrecognized", params.Unrecognized)
+
+	if p.AutoCarID {
+		report.Fields[0].Source = "generated"
+		warn("no carID: a generated ID is used and continuation messages can't be merged")
+	}
+	if params.TimestampError != nil {
+		warn("timestamp not understood: %s", *params.TimestampError)
+	} else if params.CapturedAt == nil {
+		warn("no capture_timestamp or datetime: the receive time is used")
+	}
+	if e.PlateConfidence != "" && params.PlateConfidence == nil {
+		warn("plateConfidence %q is not a number and is dropped", e.PlateConfidence)
+	}
+
+	report.Camera = p.Camera
+	report.Unrecognized = params.Unrecognized
+	report.Late = s.isLate(params.ArrivalDelayMs)
+	if report.Camera == "" {
+		warn("no camera_info.SerialNumber or sensorProviderID: per-camera quotas, health and filters can't tell this camera apart")
+	}
+	if !p.AutoCarID && isContinuation(deref(params.CarState)) {
+		if open, ok := s.findOpenEvent(ctx, dbgen.New(s.DB), params.CarID, p.Camera, params.CameraIp, req.ReceivedAt); ok {
+			report.MergeInto = &open.ID
+		} else {
+			warn("carState %q continues an earlier message, but no open event matches: it would be stored as a new event", deref(params.CarState))
+		}
+	}
+
+	for _, img := range req.Images {
+		report.Images = append(report.Images, describeImage(parsedImage{
+			Source:   coalesce(img.Field, "multipart"),
+			Filename: img.Filename,
+			Type:     img.imageType(),
+		}, img.Data))
+	}
+	for i, img := range e.ImageArray {
+		src := fmt.Sprintf("ImageArray[%d]", i)
+		if img.BinaryImage == "" {
+			warn("%s has no BinaryImage and is skipped", src)
+			continue
+		}
+		if img.ChunkCount > 1 {
+			warn("%s is chunk %d of %d of a split image: /api holds it until the rest of packetCounter %q arrives", src, img.ChunkIndex+1, img.ChunkCount, e.PacketCounter)
+			continue
+		}
+		typ := coalesce(img.ImageType, "embedded")
+		pi := parsedImage{Source: src, Type: typ, Filename: fmt.Sprintf("%s_%d.%s", typ, i, coalesce(img.ImageFormat, "jpg"))}
+		pi.CapturedAt, _ = s.captureTime(nil, ptrIfNotEmpty(img.Timestamp), req.ReceivedAt)
+		data, err := base64.StdEncoding.DecodeString(img.BinaryImage)
+		if err != nil {
+			pi.Error = "invalid base64: " + err.Error()
+			warn("%s is skipped: invalid base64", src)
+			report.Images = append(report.Images, pi)
+			continue
+		}
+		report.Images = append(report.Images, describeImage(pi, data))
+	}
+	return report
+}
+
+// describeImage adds what decoding an image reveals
+func describeImage(pi parsedImage, data []byte) parsedImage {
+	pi.Bytes = len(data)
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		pi.Error = "can't decode: " + err.Error()
+		return pi
+	}
+	pi.Format = format
+	m, err := measureImage(data)
+	if err != nil {
+		pi.Error = "can't decode: " + err.Error()
+		return pi
+	}
+	pi.Width, pi.Height, pi.Sharpness, pi.Quality = m.Width, m.Height, m.Sharpness, m.Quality()
+	if !taggedType(&pi.Type) {
+		pi.Classified = classifyImage(m)
+	}
+	return pi
+}
+
+// lookupKey finds a dotted key in decoded JSON, matching case-insensitively
+// like encoding/json does when there is no exact match. It returns the key
+// as sent.
+func lookupKey(raw map[string]any, path string) (any, string, bool) {
+	var v any = raw
+	var sent []string
+	for _, k := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, "", false
+		}
+		if v, ok = m[k]; ok {
+			sent = append(sent, k)
+			continue
+		}
+		found := false
+		for mk, mv := range m {
+			if strings.EqualFold(mk, k) {
+				v, found = mv, true
+				sent = append(sent, mk)
+				break
+			}
+		}
+		if !found {
+			return nil, "", false
+		}
+	}
+	return v, strings.Join(sent, "."), true
+}
+
+// unknownKeys lists the keys of decoded JSON that no field of t reads
+func unknownKeys(raw map[string]any, t reflect.Type, prefix string) []string {
+	var unknown []string
+	for k, v := range raw {
+		f, ok := jsonField(t, k)
+		if !ok {
+			unknown = append(unknown, prefix+k)
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft.Kind() == reflect.Struct:
+			if m, ok := v.(map[string]any); ok {
+				unknown = append(unknown, unknownKeys(m, ft, prefix+k+".")...)
+			}
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct:
+			items, _ := v.([]any)
+			for i, item := range items {
+				if m, ok := item.(map[string]any); ok {
+					unknown = append(unknown, unknownKeys(m, ft.Elem(), fmt.Sprintf("%s%s[%d].", prefix, k, i))...)
+				}
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// jsonField finds the struct field encoding/json would decode key into
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	var folded *reflect.StructField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		if name == key {
+			return f, true
+		}
+		if folded == nil && strings.EqualFold(name, key) {
+			folded = &f
+		}
+	}
+	if folded != nil {
+		return *folded, true
+	}
+	return reflect.StructField{}, false
+}
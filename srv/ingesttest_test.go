@@ -0,0 +1,120 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIngestTestParse(t *testing.T) {
+	s := newTestServer(t)
+
+	var crop bytes.Buffer
+	png.Encode(&crop, image.NewGray(image.Rect(0, 0, 120, 30)))
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "event.json")
+	fw.Write([]byte(`{"carid":"7","plateText":"AB123","plateConfidence":"high","datetime":"yesterday",
+		"vehicle_info":{"make":"VW","trim":"GTI"},"camera_info":{"SerialNumber":"CAM9"},"extra":1}`))
+	fw, _ = mw.CreateFormFile("file", "lpup.png")
+	fw.Write(crop.Bytes())
+	fw, _ = mw.CreateFormFile("file", "notes.txt")
+	fw.Write([]byte("x"))
+	mw.Close()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/test/parse", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	s.HandleIngestTestParse(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var report parseReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := map[string]parsedField{}
+	for _, f := range report.Fields {
+		fields[f.Column] = f
+	}
+	if f := fields["car_id"]; f.Source != "carid" || f.Value != "7" {
+		t.Errorf("car_id = %+v", f)
+	}
+	if f := fields["plate_utf8"]; f.Source != "plateText" || f.Value != "AB123" {
+		t.Errorf("plate_utf8 = %+v", f)
+	}
+	if f := fields["plate_confidence"]; f.Raw != "high" || f.Value != nil {
+		t.Errorf("plate_confidence = %+v", f)
+	}
+	if f := fields["vehicle_make"]; f.Source != "vehicle_info.make" || f.Value != "VW" {
+		t.Errorf("vehicle_make = %+v", f)
+	}
+	if _, ok := fields["timestamp_error"]; !ok || report.Camera != "CAM9" {
+		t.Errorf("timestamp_error missing or camera %q", report.Camera)
+	}
+	if !slices.Equal(report.UnknownFields, []string{"extra", "vehicle_info.trim"}) {
+		t.Errorf("unknown fields = %q", report.UnknownFields)
+	}
+	for _, want := range []string{"notes.txt ignored", "plateConfidence \"high\"", "timestamp not understood"} {
+		if !slices.ContainsFunc(report.Warnings, func(w string) bool { return strings.Contains(w, want) }) {
+			t.Errorf("no warning about %q in %q", want, report.Warnings)
+		}
+	}
+	if len(report.Images) != 1 || report.Images[0].Type != "plate" || report.Images[0].Format != "png" || report.Images[0].Width != 120 {
+		t.Errorf("images = %+v", report.Images)
+	}
+
+	// Dry run: nothing stored
+	var n int
+	s.DB.QueryRow("SELECT COUNT(*) FROM events").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d events stored", n)
+	}
+
+	// Malformed JSON is reported, not failed
+	w = httptest.NewRecorder()
+	s.HandleIngestTestParse(w, httptest.NewRequest("POST", "/api/test/parse", strings.NewReader(`{"plateConfidence":0.9}`)))
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil || !strings.Contains(report.Error, "plateConfidence") {
+		t.Errorf("type mismatch report = %s", w.Body)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, tc := range []struct {
+		body    string
+		status  int
+		unknown []string
+	}{
+		{`{"plateUTF8":"AB123","camera_info":{"SerialNumber":"C1","Firmware":"2.1"}}`, http.StatusOK, []string{"camera_info.Firmware"}},
+		{`{"plateUTF8":"AB123","plateConfidence":0.93}`, http.StatusBadRequest, []string{}},
+		{`[1,2]`, http.StatusBadRequest, []string{}},
+	} {
+		w := httptest.NewRecorder()
+		s.HandleValidate(w, httptest.NewRequest("POST", "/api/validate", strings.NewReader(tc.body)))
+		var report parseReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s: %v", tc.body, err)
+		}
+		if w.Code != tc.status || report.Valid != (tc.status == http.StatusOK) || !slices.Equal(report.UnknownFields, tc.unknown) {
+			t.Errorf("%s: status %d, report %+v", tc.body, w.Code, report)
+		}
+		if report.JSON != nil {
+			t.Errorf("%s: payload echoed back", tc.body)
+		}
+	}
+	var n int
+	s.DB.QueryRow("SELECT COUNT(*) FROM events").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d events stored", n)
+	}
+}
@@ -0,0 +1,345 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Large XLSX exports embed every image and can take minutes, so they can run
+// as background jobs: POST /api/archive/{id}/compare/export/jobs starts one,
+// GET /api/jobs/{id} reports events processed out of the total, POST
+// /api/jobs/{id}/cancel stops it, and GET /api/jobs/{id}/download returns
+// the file once done. The auto-compare job of groundtruth.go runs the same
+// way, without a file. Jobs live in memory and their files in the spool
+// directory; finished ones are forgotten after jobRetention.
+
+const jobRetention = time.Hour
+
+// maxExportJobs limits the exports running at once: each one builds its
+// workbook, images included, in memory
+const maxExportJobs = 2
+
+const (
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+)
+
+// exportJob is a background export, or another long job on an archive
+type exportJob struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	ArchiveID int64  `json:"archive_id"`
+
+	mu         sync.Mutex
+	state      string
+	processed  int
+	total      int
+	err        string
+	startedAt  time.Time
+	finishedAt time.Time
+	filename   string
+	user       string // who started it, notified when it ends
+	file       string // spooled file of a finished export
+	result     any    // summary of a job without a file
+	cancel     context.CancelFunc
+}
+
+type jobStatus struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	ArchiveID   int64      `json:"archive_id"`
+	State       string     `json:"state"`
+	Processed   int        `json:"processed"`
+	Total       int        `json:"total"`
+	Percent     float64    `json:"percent"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	Result      any        `json:"result,omitempty"`
+}
+
+func (j *exportJob) status() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := jobStatus{
+		ID:        j.ID,
+		Kind:      j.Kind,
+		ArchiveID: j.ArchiveID,
+		State:     j.state,
+		Processed: j.processed,
+		Total:     j.total,
+		Error:     j.err,
+		StartedAt: j.startedAt,
+		Result:    j.result,
+	}
+	if j.total > 0 {
+		st.Percent = float64(j.processed) / float64(j.total) * 100
+	}
+	if !j.finishedAt.IsZero() {
+		t := j.finishedAt
+		st.FinishedAt = &t
+	}
+	if j.state == jobDone {
+		st.Percent = 100
+		if j.filename != "" {
+			st.DownloadURL = "/api/jobs/" + j.ID + "/download"
+		}
+	}
+	return st
+}
+
+func (j *exportJob) progress(done, total int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.processed, j.total = done, total
+}
+
+func (j *exportJob) finish(file string, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finishedAt = time.Now()
+	switch {
+	case err == nil:
+		j.state, j.file = jobDone, file
+	case j.state == jobCanceled || err == context.Canceled:
+		j.state = jobCanceled
+	default:
+		j.state, j.err = jobFailed, err.Error()
+	}
+}
+
+// jobRegistry holds the export jobs; the zero value is empty
+type jobRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]*exportJob
+}
+
+// add registers a job unless limit jobs of its kind are running already
+// (0 = no limit)
+func (reg *jobRegistry) add(j *exportJob, limit int) bool {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.jobs == nil {
+		reg.jobs = make(map[string]*exportJob)
+	}
+	// Forget jobs that finished long ago
+	running := 0
+	for id, old := range reg.jobs {
+		old.mu.Lock()
+		expired := !old.finishedAt.IsZero() && time.Since(old.finishedAt) > jobRetention
+		if old.state == jobRunning && old.Kind == j.Kind {
+			running++
+		}
+		file := old.file
+		old.mu.Unlock()
+		if expired {
+			delete(reg.jobs, id)
+			if file != "" {
+				os.Remove(file)
+			}
+		}
+	}
+	if limit > 0 && running >= limit {
+		return false
+	}
+	reg.jobs[j.ID] = j
+	return true
+}
+
+func (reg *jobRegistry) get(id string) *exportJob {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.jobs[id]
+}
+
+func (reg *jobRegistry) list() []*exportJob {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	out := make([]*exportJob, 0, len(reg.jobs))
+	for _, j := range reg.jobs {
+		out = append(out, j)
+	}
+	return out
+}
+
+// cancelRunning cancels every running job and returns how many there were
+func (reg *jobRegistry) cancelRunning() int {
+	n := 0
+	for _, j := range reg.list() {
+		j.mu.Lock()
+		if j.state == jobRunning {
+			j.state = jobCanceled
+			j.cancel()
+			n++
+		}
+		j.mu.Unlock()
+	}
+	return n
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// HandleStartCompareExport starts a background XLSX export of an archive
+func (s *Server) HandleStartCompareExport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	archive, err := dbgen.New(s.DB).GetArchiveByID(r.Context(), id)
+	if err != nil {
+		s.jsonError(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	opts, err := s.exportOptions(r)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The job outlives the request that started it
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &exportJob{
+		ID:        newJobID(),
+		Kind:      "compare_xlsx",
+		ArchiveID: id,
+		state:     jobRunning,
+		total:     int(archive.EventCount),
+		startedAt: time.Now(),
+		filename:  compareExportFilename(archive),
+		user:      sessionUser(r),
+		cancel:    cancel,
+	}
+	if !s.jobs.add(job, maxExportJobs) {
+		cancel()
+		s.jsonError(w, fmt.Sprintf("%d exports are running already, try again when one has finished", maxExportJobs), http.StatusTooManyRequests)
+		return
+	}
+	slog.Info("export job started", "job", job.ID, "kind", job.Kind, "archive_id", id, "events", archive.EventCount)
+
+	s.background.Go(func() {
+		defer cancel()
+		file, size, err := s.spoolJobFile(func(w io.Writer) error {
+			return s.writeCompareWorkbook(ctx, w, archive, opts, job.progress)
+		})
+		job.finish(file, err)
+		st := job.status()
+		slog.Info("export job finished", "job", job.ID, "state", st.State, "processed", st.Processed, "total", st.Total,
+			"bytes", size, "elapsed", time.Since(job.startedAt).Round(time.Millisecond), "error", st.Error)
+		s.notifyExportFinished(job, st)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job.status())
+}
+
+// HandleJobs lists the export jobs, newest first
+func (s *Server) HandleJobs(w http.ResponseWriter, r *http.Request) {
+	out := []jobStatus{}
+	for _, j := range s.jobs.list() {
+		out = append(out, j.status())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.After(out[j].StartedAt) })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// HandleJob reports the progress of an export job
+func (s *Server) HandleJob(w http.ResponseWriter, r *http.Request) {
+	job := s.jobs.get(r.PathValue("id"))
+	if job == nil {
+		s.jsonError(w, "job not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job.status())
+}
+
+// HandleCancelJob stops a running export job
+func (s *Server) HandleCancelJob(w http.ResponseWriter, r *http.Request) {
+	job := s.jobs.get(r.PathValue("id"))
+	if job == nil {
+		s.jsonError(w, "job not found", http.StatusNotFound)
+		return
+	}
+	job.mu.Lock()
+	running := job.state == jobRunning
+	if running {
+		job.state = jobCanceled
+		job.cancel()
+	}
+	job.mu.Unlock()
+	if !running {
+		s.jsonError(w, "job is not running", http.StatusConflict)
+		return
+	}
+	slog.Info("export job canceled", "job", job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job.status())
+}
+
+// HandleJobDownload returns the file of a finished export job
+func (s *Server) HandleJobDownload(w http.ResponseWriter, r *http.Request) {
+	job := s.jobs.get(r.PathValue("id"))
+	if job == nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	job.mu.Lock()
+	state, file, filename := job.state, job.file, job.filename
+	job.mu.Unlock()
+	if state != jobDone {
+		http.Error(w, fmt.Sprintf("job is %s", state), http.StatusConflict)
+		return
+	}
+	if filename == "" {
+		http.Error(w, "job has no file", http.StatusNotFound)
+		return
+	}
+	s.serveExportFile(w, r, filename, xlsxContentType, file)
+}
+
+// spoolJobFile writes the file of a job to the spool directory, so finished
+// exports don't stay in memory until they are downloaded. It returns the
+// file and its size; nothing is left behind on error.
+func (s *Server) spoolJobFile(write func(io.Writer) error) (string, int64, error) {
+	if err := os.MkdirAll(s.spoolDir(), 0755); err != nil {
+		return "", 0, err
+	}
+	f, err := os.CreateTemp(s.spoolDir(), "job-*")
+	if err != nil {
+		return "", 0, err
+	}
+	err = write(f)
+	size, _ := f.Seek(0, io.SeekCurrent)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", 0, err
+	}
+	return f.Name(), size, nil
+}
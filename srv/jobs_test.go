@@ -0,0 +1,107 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportJobs(t *testing.T) {
+	s := newTestServer(t)
+	s.ExportImages = DefaultExportImageConfig()
+	ctx := context.Background()
+	for _, body := range []string{`{"plateUTF8":"AB1"}`, `{"plateUTF8":"AB2"}`} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", []uploadedImage{{Filename: "plate.png", Data: pngOf(100, 20)}})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.background.Wait()
+	s.DB.Exec("INSERT INTO archives (name, event_count) VALUES ('trial', 2)")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+
+	start := func() *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/api/archive/1/compare/export/jobs", nil)
+		r.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		s.HandleStartCompareExport(w, r)
+		return w
+	}
+	download := func(id string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("GET", "/api/jobs/"+id+"/download", nil)
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		s.HandleJobDownload(w, r)
+		return w
+	}
+
+	w := start()
+	var st jobStatus
+	json.NewDecoder(w.Body).Decode(&st)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("start: %d %+v", w.Code, st)
+	}
+	s.background.Wait()
+	job := s.jobs.get(st.ID)
+	if st = job.status(); st.State != jobDone || st.Processed != 2 || st.DownloadURL == "" {
+		t.Fatalf("job %+v", st)
+	}
+
+	// The finished workbook is kept in the spool directory, not in memory
+	spooled, err := os.ReadFile(job.file)
+	if err != nil {
+		t.Fatalf("spooled file: %v", err)
+	}
+	w = download(st.ID)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), spooled) || w.Header().Get("Content-Disposition") != `attachment; filename="compare_trial.xlsx"` {
+		t.Fatalf("download: %d %v", w.Code, w.Header())
+	}
+	if f, err := excelize.OpenReader(bytes.NewReader(w.Body.Bytes())); err != nil {
+		t.Errorf("not a workbook: %v", err)
+	} else {
+		f.Close()
+	}
+
+	// Signed downloads carry the signature of the spooled file
+	_, key, _ := ed25519.GenerateKey(nil)
+	s.Signer = newExportSigner(key)
+	if w = download(st.ID); !bytes.Equal(w.Body.Bytes(), spooled) || w.Header().Get("X-Signature") == "" {
+		t.Errorf("signed download: %d %v", w.Code, w.Header())
+	}
+	s.Signer = nil
+
+	// Only maxExportJobs exports run at once; other jobs don't count
+	s.jobs.add(&exportJob{ID: "compare", Kind: "auto_compare", state: jobRunning}, 0)
+	for i := range maxExportJobs {
+		s.jobs.add(&exportJob{ID: string(rune('a' + i)), Kind: "compare_xlsx", state: jobRunning}, 0)
+	}
+	if w = start(); w.Code != http.StatusTooManyRequests {
+		t.Errorf("export over the limit: %d %s", w.Code, w.Body)
+	}
+	s.jobs.get("a").finish("", nil)
+	if w = start(); w.Code != http.StatusAccepted {
+		t.Errorf("export after one finished: %d %s", w.Code, w.Body)
+	}
+	s.background.Wait()
+
+	// Expired jobs are forgotten along with their files
+	job.mu.Lock()
+	job.finishedAt = time.Now().Add(-2 * jobRetention)
+	job.mu.Unlock()
+	s.jobs.add(&exportJob{ID: "next", Kind: "auto_compare", state: jobRunning}, 0)
+	if s.jobs.get(st.ID) != nil {
+		t.Error("expired job still listed")
+	}
+	if _, err := os.Stat(job.file); !os.IsNotExist(err) {
+		t.Errorf("expired job's file: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package srv
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// The ingest journal makes ingest crash-safe: every request is written and
+// fsynced to DataDir/journal before it is processed, and removed once it
+// is stored or rejected for its content. Entries left behind by a crash,
+// a power cut or a failing database are replayed on the next start.
+
+func (s *Server) journalDir() string {
+	return filepath.Join(s.DataDir, "journal")
+}
+
+// journalWrite durably stores a request and returns the entry path
+func (s *Server) journalWrite(req ingestRequest) (string, error) {
+	dir := s.journalDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, req.UID+".gob")
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	if err := gob.NewEncoder(f).Encode(req); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	// Make the rename itself durable
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return path, nil
+}
+
+// ingest journals a request, processes it, and clears the journal entry
+func (s *Server) ingest(ctx context.Context, req ingestRequest) (ingestResult, error) {
+	var entry string
+	if s.Journal {
+		var err error
+		entry, err = s.journalWrite(req)
+		if err != nil {
+			// Still accept the event; losing durability beats losing the event
+			slog.Error("failed to journal ingest request", "uid", req.UID, "error", err)
+		}
+	}
+
+	if s.ChunkTimeout > 0 {
+		msg, err := readChunkMessage(req)
+		if err == nil && msg != nil {
+			// The entry is cleared with its packet
+			return s.ingestChunk(ctx, req, msg, entry)
+		}
+		if err != nil {
+			s.Email.countIngest(err)
+			s.settleJournalEntry(entry, err)
+			return ingestResult{}, err
+		}
+	}
+
+	res, err := s.ingestEvent(ctx, req)
+	if err == nil {
+		s.publishEvent(ctx, res.ID)
+	}
+	s.Email.countIngest(err)
+	s.settleJournalEntry(entry, err)
+	return res, err
+}
+
+// settleJournalEntry clears a journal entry once its request is stored or
+// rejected for its content. Other failures (a busy or full database, a
+// failed write) keep it for the next start to replay.
+func (s *Server) settleJournalEntry(entry string, err error) {
+	var pe *payloadError
+	if err == nil || errors.As(err, &pe) {
+		s.clearJournalEntry(entry)
+	} else if entry != "" {
+		slog.Warn("keeping journal entry for replay", "path", entry, "error", err)
+	}
+}
+
+// clearJournalEntry removes a journal entry
+func (s *Server) clearJournalEntry(entry string) {
+	if entry != "" {
+		if rmErr := os.Remove(entry); rmErr != nil {
+			slog.Warn("failed to clear journal entry", "path", entry, "error", rmErr)
+		}
+	}
+}
+
+// replayJournal processes requests that were journaled but never finished.
+// Events and merged messages that made it into the database before the
+// crash are recognized by their UID and not stored twice.
+func (s *Server) replayJournal(ctx context.Context) error {
+	dir := s.journalDir()
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names) // ULIDs: oldest first
+
+	q := dbgen.New(s.DB)
+	var replayed, skipped, failed int
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if strings.HasSuffix(name, ".tmp") {
+			// Crashed while journaling; the client never got an answer either
+			os.Remove(path)
+			continue
+		}
+		if !strings.HasSuffix(name, ".gob") {
+			continue
+		}
+
+		req, err := readJournalEntry(path)
+		if err != nil {
+			slog.Error("unreadable journal entry", "path", path, "error", err)
+			os.Rename(path, path+".bad")
+			failed++
+			continue
+		}
+
+		if _, err := q.FindEventByUID(ctx, &req.UID); err == nil {
+			os.Remove(path)
+			skipped++
+			continue
+		}
+		if _, err := q.FindEventMessageByUID(ctx, req.UID); err == nil {
+			// A continuation already merged into its event
+			os.Remove(path)
+			skipped++
+			continue
+		}
+
+		if s.ChunkTimeout > 0 {
+			if msg, err := readChunkMessage(req); err == nil && msg != nil {
+				// Rejoin its packet; the rest may still arrive
+				if _, err := s.ingestChunk(ctx, req, msg, path); err != nil {
+					slog.Warn("dropped split image message", "path", path, "error", err)
+				}
+				replayed++
+				continue
+			}
+		}
+
+		res, err := s.ingestEvent(ctx, req)
+		if err != nil {
+			var pe *payloadError
+			if !errors.As(err, &pe) {
+				// Keep it for the next start
+				slog.Error("failed to replay journal entry", "path", path, "error", err)
+				failed++
+				continue
+			}
+			slog.Warn("dropped invalid journal entry", "path", path, "error", err)
+		} else {
+			slog.Info("replayed journaled event", "id", res.ID, "uid", req.UID, "received_at", req.ReceivedAt)
+		}
+		os.Remove(path)
+		replayed++
+	}
+	if replayed+skipped+failed > 0 {
+		slog.Info("ingest journal replayed", "replayed", replayed, "already_stored", skipped, "failed", failed)
+	}
+	return nil
+}
+
+func readJournalEntry(path string) (ingestRequest, error) {
+	var req ingestRequest
+	f, err := os.Open(path)
+	if err != nil {
+		return req, err
+	}
+	defer f.Close()
+	if err := gob.NewDecoder(f).Decode(&req); err != nil {
+		return req, fmt.Errorf("decode: %w", err)
+	}
+	if req.UID == "" {
+		return req, errors.New("entry has no uid")
+	}
+	return req, nil
+}
@@ -0,0 +1,100 @@
+package srv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestReplayJournal(t *testing.T) {
+	s := newTestServer(t)
+	s.Journal = true
+	ctx := context.Background()
+
+	// A request journaled right before a crash
+	pending := newIngestRequest([]byte(`{"carID":"1","plateUTF8":"AB123"}`), "", nil)
+	if _, err := s.journalWrite(pending); err != nil {
+		t.Fatal(err)
+	}
+	// A request that was stored, but the crash came before its entry was removed
+	stored := newIngestRequest([]byte(`{"carID":"2","plateUTF8":"CD456"}`), "", nil)
+	if _, err := s.ingestEvent(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.journalWrite(stored); err != nil {
+		t.Fatal(err)
+	}
+	// A write interrupted mid-way
+	os.WriteFile(filepath.Join(s.journalDir(), "partial.gob.tmp"), []byte("x"), 0644)
+
+	if err := s.replayJournal(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	q := dbgen.New(s.DB)
+	if n, _ := q.CountEvents(ctx); n != 2 {
+		t.Errorf("events = %d, want 2", n)
+	}
+	ev, err := q.GetEventByUID(ctx, &pending.UID)
+	if err != nil {
+		t.Fatalf("replayed event not found: %v", err)
+	}
+	if !ev.CreatedAt.Equal(pending.ReceivedAt) {
+		t.Errorf("created_at = %v, want original receive time %v", ev.CreatedAt, pending.ReceivedAt)
+	}
+	if entries, _ := os.ReadDir(s.journalDir()); len(entries) != 0 {
+		t.Errorf("journal not cleared: %d entries left", len(entries))
+	}
+}
+
+func TestJournalKeepsFailedRequests(t *testing.T) {
+	s := newTestServer(t)
+	s.Journal = true
+	ctx := context.Background()
+	entries := func() int {
+		t.Helper()
+		e, _ := os.ReadDir(s.journalDir())
+		return len(e)
+	}
+
+	// Rejected for its content: nothing to replay
+	if _, err := s.ingest(ctx, newIngestRequest([]byte(`{"plateUTF8":`), "", nil)); err == nil {
+		t.Fatal("invalid JSON accepted")
+	}
+	if n := entries(); n != 0 {
+		t.Errorf("%d entries kept for an invalid request", n)
+	}
+
+	// The database fails on the image: the event isn't stored without it,
+	// and the request is kept
+	s.DB.Exec("CREATE TRIGGER fail_images BEFORE INSERT ON images BEGIN SELECT RAISE(ABORT, 'disk I/O error'); END")
+	req := newIngestRequest([]byte(`{"carID":"1","plateUTF8":"AB123"}`), "", []uploadedImage{{Filename: "plate.png", Data: pngOf(100, 20)}})
+	if _, err := s.ingest(ctx, req); err == nil {
+		t.Fatal("ingest succeeded without its image")
+	}
+	q := dbgen.New(s.DB)
+	if n, _ := q.CountEvents(ctx); n != 0 {
+		t.Errorf("%d events stored without their image", n)
+	}
+	if n := entries(); n != 1 {
+		t.Fatalf("%d journal entries, want the failed request", n)
+	}
+
+	s.DB.Exec("DROP TRIGGER fail_images")
+	if err := s.replayJournal(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ev, err := q.GetEventByUID(ctx, &req.UID)
+	if err != nil {
+		t.Fatalf("replayed event not found: %v", err)
+	}
+	if images, _ := q.GetImagesByEventID(ctx, ev.ID); len(images) != 1 {
+		t.Errorf("replayed event has %d images", len(images))
+	}
+	if n := entries(); n != 0 {
+		t.Errorf("journal not cleared: %d entries left", n)
+	}
+}
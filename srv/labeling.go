@@ -0,0 +1,483 @@
+package srv
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Archives can be handed to external labeling tools instead of the compare
+// page. Each event becomes one task with its vehicle image and the values
+// the camera recognized; reviewers mark every field correct or incorrect,
+// and the completed export is imported back into compare_results.
+//
+// Label Studio: create a project with GET /labeling/label-studio.xml as the
+// labeling config, import GET /archive/{id}/labeling/label-studio (tasks with
+// image URLs, so the tool must be able to reach this server), and when done
+// POST the project's JSON export to /api/archive/{id}/labeling/import.
+//
+// CVAT: GET /archive/{id}/labeling/cvat is a ZIP with the images and a
+// "CVAT for images 1.1" annotations.xml. Create a task from the images,
+// upload annotations.xml, and POST the exported annotations.xml (or the
+// export ZIP) to /api/archive/{id}/labeling/import. Each field is a
+// "<field>_incorrect" tag; images without the tag count as correct.
+
+const (
+	verdictCorrect   = "correct"
+	verdictIncorrect = "incorrect"
+)
+
+// labelingTask is one archived event to review
+type labelingTask struct {
+	EventID int64
+	UID     *string
+	ImageID int64
+	Values  map[string]string // recognized value per compare field
+	Verdict *compareAnnotation
+}
+
+func (s *Server) labelingTasks(r *http.Request, archiveID int64) ([]labelingTask, error) {
+	q := dbgen.New(s.DB)
+	events, err := q.GetArchivedEvents(r.Context(), &archiveID)
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := compareAnnotations(r.Context(), q, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	verdicts := make(map[int64]*compareAnnotation, len(annotations))
+	for _, a := range annotations {
+		verdicts[a.EventID] = a
+	}
+
+	tasks := make([]labelingTask, 0, len(events))
+	for _, e := range events {
+		imageID := toInt64(e.VehicleImageID)
+		if imageID == 0 {
+			imageID = toInt64(e.PlateImageID)
+		}
+		if imageID == 0 {
+			continue // nothing to look at
+		}
+		plate := e.PlateUtf8
+		if plate == nil || *plate == "" {
+			plate = e.ManualPlate
+		}
+		tasks = append(tasks, labelingTask{
+			EventID: e.ID,
+			UID:     e.Uid,
+			ImageID: imageID,
+			Values: map[string]string{
+				"plate": deref(plate),
+				"maker": deref(e.VehicleMake),
+				"model": deref(e.VehicleModel),
+				"color": deref(e.VehicleColor),
+			},
+			Verdict: verdicts[e.ID],
+		})
+	}
+	return tasks, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// labelingArchive parses the archive id and returns the archive's file name
+func (s *Server) labelingArchive(w http.ResponseWriter, r *http.Request) (int64, string, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return 0, "", false
+	}
+	archive, err := dbgen.New(s.DB).GetArchiveByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "archive not found", http.StatusNotFound)
+		return 0, "", false
+	}
+	name := fmt.Sprintf("archive_%d", id)
+	if archive.Name != nil && *archive.Name != "" {
+		name = sanitizeFilename(*archive.Name)
+	}
+	return id, name, true
+}
+
+// HandleLabelStudioConfig serves the Label Studio labeling config matching
+// the exported tasks
+func (s *Server) HandleLabelStudioConfig(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	b.WriteString("<View>\n  <Image name=\"image\" value=\"$image\" zoom=\"true\"/>\n")
+	for _, f := range compareFields {
+		fmt.Fprintf(&b, "  <Header value=\"%s: $%s\" size=\"5\"/>\n", strings.ToUpper(f[:1])+f[1:], f)
+		fmt.Fprintf(&b, "  <Choices name=\"%s\" toName=\"image\" choice=\"single\" showInline=\"true\" required=\"true\">\n", f)
+		fmt.Fprintf(&b, "    <Choice value=\"%s\"/>\n    <Choice value=\"%s\"/>\n  </Choices>\n", verdictCorrect, verdictIncorrect)
+	}
+	b.WriteString("</View>\n")
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(b.String()))
+}
+
+type labelStudioTask struct {
+	Data        map[string]any          `json:"data"`
+	Predictions []labelStudioAnnotation `json:"predictions,omitempty"`
+	Annotations []labelStudioAnnotation `json:"annotations,omitempty"`
+}
+
+type labelStudioAnnotation struct {
+	ModelVersion string              `json:"model_version,omitempty"`
+	WasCancelled bool                `json:"was_cancelled,omitempty"`
+	Result       []labelStudioResult `json:"result"`
+}
+
+type labelStudioResult struct {
+	FromName string `json:"from_name"`
+	ToName   string `json:"to_name"`
+	Type     string `json:"type"`
+	Value    struct {
+		Choices []string `json:"choices"`
+	} `json:"value"`
+}
+
+// HandleLabelStudioExport returns the archive as Label Studio tasks; saved
+// compare results are attached as predictions
+func (s *Server) HandleLabelStudioExport(w http.ResponseWriter, r *http.Request) {
+	id, name, ok := s.labelingArchive(w, r)
+	if !ok {
+		return
+	}
+	tasks, err := s.labelingTasks(r, id)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	base := s.linkBaseURL(r)
+	out := make([]labelStudioTask, 0, len(tasks))
+	for _, t := range tasks {
+		data := map[string]any{
+			"image":    fmt.Sprintf("%s/image/%d", base, t.ImageID),
+			"event_id": t.EventID,
+			"uid":      t.UID,
+		}
+		for f, v := range t.Values {
+			data[f] = v
+		}
+		task := labelStudioTask{Data: data}
+		if t.Verdict != nil && t.Verdict.UpdatedAt != nil {
+			pred := labelStudioAnnotation{ModelVersion: "compare"}
+			for _, f := range compareFields {
+				res := labelStudioResult{FromName: f, ToName: "image", Type: "choices"}
+				res.Value.Choices = []string{verdictCorrect}
+				if t.Verdict.incorrect(f) {
+					res.Value.Choices = []string{verdictIncorrect}
+				}
+				pred.Result = append(pred.Result, res)
+			}
+			task.Predictions = []labelStudioAnnotation{pred}
+		}
+		out = append(out, task)
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		http.Error(w, "failed to encode export", http.StatusInternalServerError)
+		return
+	}
+	s.writeExport(w, r, fmt.Sprintf("labelstudio_%s.json", name), "application/json", append(data, '\n'))
+}
+
+// CVAT for images 1.1
+type cvatAnnotations struct {
+	XMLName xml.Name    `xml:"annotations"`
+	Version string      `xml:"version"`
+	Meta    *cvatMeta   `xml:"meta,omitempty"`
+	Images  []cvatImage `xml:"image"`
+}
+
+type cvatMeta struct {
+	Task struct {
+		Name   string      `xml:"name"`
+		Size   int         `xml:"size"`
+		Labels []cvatLabel `xml:"labels>label"`
+	} `xml:"task"`
+}
+
+type cvatLabel struct {
+	Name       string          `xml:"name"`
+	Type       string          `xml:"type"`
+	Attributes []cvatAttribute `xml:"attributes>attribute"`
+}
+
+type cvatAttribute struct {
+	Name         string `xml:"name"`
+	Mutable      string `xml:"mutable"`
+	InputType    string `xml:"input_type"`
+	DefaultValue string `xml:"default_value"`
+	Values       string `xml:"values"`
+}
+
+type cvatImage struct {
+	ID     int       `xml:"id,attr"`
+	Name   string    `xml:"name,attr"`
+	Width  int       `xml:"width,attr,omitempty"`
+	Height int       `xml:"height,attr,omitempty"`
+	Tags   []cvatTag `xml:"tag"`
+}
+
+type cvatTag struct {
+	Label      string      `xml:"label,attr"`
+	Source     string      `xml:"source,attr,omitempty"`
+	Attributes []cvatValue `xml:"attribute"`
+}
+
+type cvatValue struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// HandleCVATExport returns a ZIP with the archive's images and a CVAT
+// annotations.xml holding the recognized values and saved verdicts
+func (s *Server) HandleCVATExport(w http.ResponseWriter, r *http.Request) {
+	id, name, ok := s.labelingArchive(w, r)
+	if !ok {
+		return
+	}
+	tasks, err := s.labelingTasks(r, id)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	doc := cvatAnnotations{Version: "1.1", Meta: &cvatMeta{}}
+	doc.Meta.Task.Name = name
+	doc.Meta.Task.Size = len(tasks)
+	recognized := cvatLabel{Name: "recognized", Type: "tag"}
+	for _, f := range compareFields {
+		recognized.Attributes = append(recognized.Attributes, cvatAttribute{Name: f, Mutable: "False", InputType: "text"})
+	}
+	doc.Meta.Task.Labels = append(doc.Meta.Task.Labels, recognized)
+	for _, f := range compareFields {
+		doc.Meta.Task.Labels = append(doc.Meta.Task.Labels, cvatLabel{Name: f + "_" + verdictIncorrect, Type: "tag"})
+	}
+
+	// Buffered so the archive can be signed as a whole
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	q := dbgen.New(s.DB)
+	for i, t := range tasks {
+		data, err := s.loadImage(r.Context(), q, t.ImageID)
+		if err != nil {
+			continue
+		}
+		img := cvatImage{ID: i, Name: fmt.Sprintf("%d%s", t.EventID, imageExt(data))}
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			img.Width, img.Height = cfg.Width, cfg.Height
+		}
+		tag := cvatTag{Label: "recognized", Source: "auto"}
+		for _, f := range compareFields {
+			tag.Attributes = append(tag.Attributes, cvatValue{Name: f, Value: t.Values[f]})
+		}
+		img.Tags = append(img.Tags, tag)
+		for _, f := range compareFields {
+			if t.Verdict != nil && t.Verdict.incorrect(f) {
+				img.Tags = append(img.Tags, cvatTag{Label: f + "_" + verdictIncorrect, Source: "manual"})
+			}
+		}
+		doc.Images = append(doc.Images, img)
+
+		fw, err := zw.Create("images/" + img.Name)
+		if err != nil {
+			slog.Warn("cvat export", "error", err)
+			http.Error(w, "failed to write zip", http.StatusInternalServerError)
+			return
+		}
+		fw.Write(data)
+	}
+	fw, err := zw.Create("annotations.xml")
+	if err == nil {
+		io.WriteString(fw, xml.Header)
+		enc := xml.NewEncoder(fw)
+		enc.Indent("", "  ")
+		err = enc.Encode(doc)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		slog.Warn("cvat export", "error", err)
+		http.Error(w, "failed to write zip", http.StatusInternalServerError)
+		return
+	}
+	s.writeExport(w, r, fmt.Sprintf("cvat_%s.zip", name), "application/zip", buf.Bytes())
+}
+
+// imageExt is the file extension for image data
+func imageExt(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	}
+	return ".jpg"
+}
+
+// HandleLabelingImport reads completed annotations from Label Studio (JSON
+// export) or CVAT (annotations.xml or export ZIP) into compare results
+func (s *Server) HandleLabelingImport(w http.ResponseWriter, r *http.Request) {
+	archiveID, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.jsonError(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	var updates []compareUpdate
+	var format string
+	switch trimmed := bytes.TrimSpace(body); {
+	case bytes.HasPrefix(trimmed, []byte("PK")):
+		format = "cvat"
+		updates, err = parseCVATZip(body)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		format = "cvat"
+		updates, err = parseCVAT(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		format = "label-studio"
+		updates, err = parseLabelStudio(trimmed)
+	default:
+		err = errors.New("expected a Label Studio JSON export, CVAT annotations.xml or CVAT ZIP")
+	}
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The verdicts were given in the labeling tool, so assignments aren't
+	// enforced; the events count as reviewed by whoever imports them
+	changed, err := s.saveCompareUpdates(r.Context(), archiveID, updates, sessionUser(r), true)
+	if err != nil {
+		s.writeCompareError(w, err)
+		return
+	}
+	slog.Info("labeling results imported", "archive_id", archiveID, "format", format, "events", len(updates), "fields", changed)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"message": fmt.Sprintf("imported %d fields of %d events from %s", changed, len(updates), format),
+		"format":  format,
+		"events":  len(updates),
+		"fields":  changed,
+	})
+}
+
+// parseLabelStudio takes the last completed annotation of each task
+func parseLabelStudio(data []byte) ([]compareUpdate, error) {
+	var tasks []labelStudioTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, fmt.Errorf("invalid Label Studio export: %w", err)
+	}
+	var updates []compareUpdate
+	for i, t := range tasks {
+		var ann *labelStudioAnnotation
+		for j := range t.Annotations {
+			if !t.Annotations[j].WasCancelled {
+				ann = &t.Annotations[j]
+			}
+		}
+		if ann == nil {
+			continue // not labeled yet
+		}
+		u := compareUpdate{}
+		switch v := t.Data["event_id"].(type) {
+		case float64:
+			u.EventID = int64(v)
+		case string:
+			u.EventID, _ = strconv.ParseInt(v, 10, 64)
+		}
+		if uid, ok := t.Data["uid"].(string); ok && u.EventID == 0 {
+			u.UID = uid
+		}
+		if u.EventID == 0 && u.UID == "" {
+			return nil, fmt.Errorf("task %d: data has no event_id", i)
+		}
+		for _, res := range ann.Result {
+			if len(res.Value.Choices) == 0 {
+				continue
+			}
+			incorrect := res.Value.Choices[0] == verdictIncorrect
+			u.set(res.FromName, &incorrect)
+		}
+		updates = append(updates, u)
+	}
+	return updates, nil
+}
+
+// parseCVAT reads image tags; the image name is the event id
+func parseCVAT(data []byte) ([]compareUpdate, error) {
+	var doc cvatAnnotations
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid CVAT annotations: %w", err)
+	}
+	var updates []compareUpdate
+	for _, img := range doc.Images {
+		base := path.Base(img.Name)
+		eventID, err := strconv.ParseInt(strings.TrimSuffix(base, path.Ext(base)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("image %q: name is not an event id", img.Name)
+		}
+		tagged := map[string]bool{}
+		for _, tag := range img.Tags {
+			tagged[tag.Label] = true
+		}
+		u := compareUpdate{EventID: eventID}
+		for _, f := range compareFields {
+			u.set(f, ptr(tagged[f+"_"+verdictIncorrect]))
+		}
+		updates = append(updates, u)
+	}
+	return updates, nil
+}
+
+func parseCVATZip(data []byte) ([]compareUpdate, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ZIP: %w", err)
+	}
+	for _, f := range zr.File {
+		if path.Base(f.Name) != "annotations.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		xmlData, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, err
+		}
+		return parseCVAT(xmlData)
+	}
+	return nil, errors.New("ZIP has no annotations.xml")
+}
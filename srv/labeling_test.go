@@ -0,0 +1,61 @@
+package srv
+
+import "testing"
+
+func TestParseLabelStudio(t *testing.T) {
+	data := []byte(`[
+	 {"data": {"event_id": 12}, "annotations": [
+	   {"was_cancelled": false, "result": [{"from_name": "plate", "value": {"choices": ["incorrect"]}}]},
+	   {"was_cancelled": false, "result": [
+	     {"from_name": "plate", "value": {"choices": ["correct"]}},
+	     {"from_name": "color", "value": {"choices": ["incorrect"]}}]},
+	   {"was_cancelled": true, "result": [{"from_name": "plate", "value": {"choices": ["incorrect"]}}]}]},
+	 {"data": {"event_id": 13}, "annotations": []},
+	 {"data": {"uid": "01ABC"}, "annotations": [{"result": [{"from_name": "maker", "value": {"choices": ["correct"]}}]}]}
+	]`)
+	updates, err := parseLabelStudio(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("got %d updates, want 2 (unlabeled task skipped)", len(updates))
+	}
+	u := updates[0]
+	if u.EventID != 12 || u.Plate == nil || *u.Plate || u.Color == nil || !*u.Color || u.Maker != nil {
+		t.Errorf("last completed annotation not used: %+v", u)
+	}
+	if updates[1].UID != "01ABC" || updates[1].Maker == nil || *updates[1].Maker {
+		t.Errorf("uid task: %+v", updates[1])
+	}
+
+	if _, err := parseLabelStudio([]byte(`[{"data": {}, "annotations": [{"result": []}]}]`)); err == nil {
+		t.Error("task without event_id accepted")
+	}
+}
+
+func TestParseCVAT(t *testing.T) {
+	data := []byte(`<annotations><version>1.1</version>
+	  <image id="0" name="images/12.jpg"><tag label="recognized"/><tag label="model_incorrect"/></image>
+	  <image id="1" name="13.png"></image>
+	</annotations>`)
+	updates, err := parseCVAT(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 2 || updates[0].EventID != 12 || updates[1].EventID != 13 {
+		t.Fatalf("unexpected updates: %+v", updates)
+	}
+	for _, f := range compareFields {
+		want := f == "model"
+		if got := updates[0].values()[f]; got == nil || *got != want {
+			t.Errorf("event 12 %s = %v, want %v", f, got, want)
+		}
+		if got := updates[1].values()[f]; got == nil || *got {
+			t.Errorf("untagged image: %s should be correct", f)
+		}
+	}
+
+	if _, err := parseCVAT([]byte(`<annotations><image name="car.jpg"/></annotations>`)); err == nil {
+		t.Error("image name that isn't an event id accepted")
+	}
+}
@@ -0,0 +1,328 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// LapBoard is the lap-by-lap detection state for the looping test vehicles
+type LapBoard struct {
+	SessionStart *time.Time   `json:"session_start"`
+	Now          time.Time    `json:"now"`
+	Laps         []LapSummary `json:"laps"`
+	Vehicles     []LapVehicle `json:"vehicles"`
+}
+
+type LapSummary struct {
+	Number    int64      `json:"number"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Expected  int64      `json:"expected"`
+	Detected  int64      `json:"detected"`
+}
+
+type LapVehicle struct {
+	ID             int64   `json:"id"`
+	Plate          string  `json:"plate"`
+	Label          *string `json:"label"`
+	ExpectedPerLap int64   `json:"expected_per_lap"`
+	Passes         []int64 `json:"passes"`      // detected passes per lap, same order as Laps
+	MissedLaps     int     `json:"missed_laps"` // finished laps only
+}
+
+type lapEvent struct {
+	CarID     string
+	Plate     string
+	CreatedAt time.Time // capture time when known, otherwise receive time
+}
+
+// normalizePlate strips separators so "ABC-123" and "abc 123" match
+func normalizePlate(plate string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '_':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(plate)))
+}
+
+// buildLapBoard counts, per test vehicle and lap, the distinct camera tracks
+// (car IDs) whose plate matches. Several events of one track (new, update,
+// lost) are a single pass.
+func buildLapBoard(vehicles []dbgen.TestVehicle, laps []dbgen.Lap, events []lapEvent, now time.Time) LapBoard {
+	board := LapBoard{
+		Now:      now,
+		Laps:     make([]LapSummary, len(laps)),
+		Vehicles: make([]LapVehicle, len(vehicles)),
+	}
+	if len(laps) > 0 {
+		board.SessionStart = &laps[0].StartedAt
+	}
+
+	byPlate := make(map[string]int, len(vehicles))
+	var expectedPerLap int64
+	for i, v := range vehicles {
+		byPlate[normalizePlate(v.Plate)] = i
+		expectedPerLap += v.ExpectedPerLap
+		board.Vehicles[i] = LapVehicle{
+			ID:             v.ID,
+			Plate:          v.Plate,
+			Label:          v.Label,
+			ExpectedPerLap: v.ExpectedPerLap,
+			Passes:         make([]int64, len(laps)),
+		}
+	}
+
+	for li, lap := range laps {
+		board.Laps[li] = LapSummary{
+			Number:    lap.Number,
+			StartedAt: lap.StartedAt,
+			EndedAt:   lap.EndedAt,
+			Expected:  expectedPerLap,
+		}
+		seen := make(map[string]bool)
+		for _, e := range events {
+			if e.CreatedAt.Before(lap.StartedAt) || (lap.EndedAt != nil && !e.CreatedAt.Before(*lap.EndedAt)) {
+				continue
+			}
+			vi, ok := byPlate[normalizePlate(e.Plate)]
+			if !ok || seen[e.CarID] {
+				continue
+			}
+			seen[e.CarID] = true
+			board.Vehicles[vi].Passes[li]++
+		}
+		for vi := range board.Vehicles {
+			v := &board.Vehicles[vi]
+			board.Laps[li].Detected += min(v.Passes[li], v.ExpectedPerLap)
+			if lap.EndedAt != nil && v.Passes[li] < v.ExpectedPerLap {
+				v.MissedLaps++
+			}
+		}
+	}
+	return board
+}
+
+// loadLapBoard builds the board for the current session (archiveID nil) or an archive
+func (s *Server) loadLapBoard(ctx context.Context, archiveID *int64) (LapBoard, error) {
+	q := dbgen.New(s.DB)
+	vehicles, err := q.GetTestVehicles(ctx)
+	if err != nil {
+		return LapBoard{}, err
+	}
+
+	var laps []dbgen.Lap
+	var events []lapEvent
+	if archiveID == nil {
+		laps, err = q.GetCurrentLaps(ctx)
+		if err != nil {
+			return LapBoard{}, err
+		}
+		rows, err := q.GetLapEventPlates(ctx)
+		if err != nil {
+			return LapBoard{}, err
+		}
+		for _, r := range rows {
+			events = append(events, lapEvent{CarID: r.CarID, Plate: *r.PlateUtf8, CreatedAt: seenAt(r.CapturedAt, r.CreatedAt)})
+		}
+	} else {
+		laps, err = q.GetArchiveLaps(ctx, archiveID)
+		if err != nil {
+			return LapBoard{}, err
+		}
+		rows, err := q.GetArchiveLapEventPlates(ctx, archiveID)
+		if err != nil {
+			return LapBoard{}, err
+		}
+		for _, r := range rows {
+			events = append(events, lapEvent{CarID: r.CarID, Plate: *r.PlateUtf8, CreatedAt: seenAt(r.CapturedAt, r.CreatedAt)})
+		}
+	}
+
+	return buildLapBoard(vehicles, laps, events, time.Now()), nil
+}
+
+// HandleLaps shows the live lap board for the current session
+func (s *Server) HandleLaps(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	vehicles, _ := q.GetTestVehicles(r.Context())
+
+	data := struct {
+		Hostname  string
+		Vehicles  []dbgen.TestVehicle
+		ArchiveID int64
+	}{
+		Hostname: s.Hostname,
+		Vehicles: vehicles,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "laps.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleArchiveLaps shows the lap board of an archived session
+func (s *Server) HandleArchiveLaps(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+
+	q := dbgen.New(s.DB)
+	if _, err := q.GetArchiveByID(r.Context(), id); err != nil {
+		http.Error(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	vehicles, _ := q.GetTestVehicles(r.Context())
+
+	data := struct {
+		Hostname  string
+		Vehicles  []dbgen.TestVehicle
+		ArchiveID int64
+	}{
+		Hostname:  s.Hostname,
+		Vehicles:  vehicles,
+		ArchiveID: id,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "laps.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleLapsAPI returns the lap board as JSON for live updates.
+// Pass ?archive=ID for an archived session.
+func (s *Server) HandleLapsAPI(w http.ResponseWriter, r *http.Request) {
+	var archiveID *int64
+	if a := r.URL.Query().Get("archive"); a != "" && a != "0" {
+		id, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			s.jsonError(w, "invalid archive id", http.StatusBadRequest)
+			return
+		}
+		archiveID = &id
+	}
+
+	board, err := s.loadLapBoard(r.Context(), archiveID)
+	if err != nil {
+		slog.Warn("failed to load lap board", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(board)
+}
+
+// HandleStartLap closes the running lap and starts the next one
+func (s *Server) HandleStartLap(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	tx, err := s.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	q := dbgen.New(s.DB).WithTx(tx)
+	if err := q.EndOpenLap(r.Context(), &now); err != nil {
+		slog.Error("failed to end lap", "error", err)
+		http.Error(w, "failed to end lap", http.StatusInternalServerError)
+		return
+	}
+	number, err := q.StartLap(r.Context(), now)
+	if err != nil {
+		slog.Error("failed to start lap", "error", err)
+		http.Error(w, "failed to start lap", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("lap started", "number", number)
+	http.Redirect(w, r, "/laps", http.StatusSeeOther)
+}
+
+// HandleEndLap closes the running lap without starting a new one
+func (s *Server) HandleEndLap(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	q := dbgen.New(s.DB)
+	if err := q.EndOpenLap(r.Context(), &now); err != nil {
+		slog.Error("failed to end lap", "error", err)
+		http.Error(w, "failed to end lap", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/laps", http.StatusSeeOther)
+}
+
+// HandleResetLaps discards the laps of the current session
+func (s *Server) HandleResetLaps(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	if err := q.DeleteCurrentLaps(r.Context()); err != nil {
+		slog.Error("failed to reset laps", "error", err)
+		http.Error(w, "failed to reset laps", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("laps reset")
+	http.Redirect(w, r, "/laps", http.StatusSeeOther)
+}
+
+// HandleAddTestVehicle adds or updates a looping test vehicle
+func (s *Server) HandleAddTestVehicle(w http.ResponseWriter, r *http.Request) {
+	plate := strings.ToUpper(strings.TrimSpace(r.FormValue("plate")))
+	if plate == "" {
+		http.Error(w, "plate is required", http.StatusBadRequest)
+		return
+	}
+	expected, err := strconv.ParseInt(r.FormValue("expected_per_lap"), 10, 64)
+	if err != nil || expected < 1 {
+		expected = 1
+	}
+
+	q := dbgen.New(s.DB)
+	if err := q.CreateTestVehicle(r.Context(), dbgen.CreateTestVehicleParams{
+		Plate:          plate,
+		Label:          ptrIfNotEmpty(strings.TrimSpace(r.FormValue("label"))),
+		ExpectedPerLap: expected,
+		CreatedAt:      time.Now(),
+	}); err != nil {
+		slog.Error("failed to add test vehicle", "error", err)
+		http.Error(w, "failed to add test vehicle", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/laps", http.StatusSeeOther)
+}
+
+// HandleDeleteTestVehicle removes a test vehicle
+func (s *Server) HandleDeleteTestVehicle(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid vehicle id", http.StatusBadRequest)
+		return
+	}
+
+	q := dbgen.New(s.DB)
+	if err := q.DeleteTestVehicle(r.Context(), id); err != nil {
+		slog.Error("failed to delete test vehicle", "error", err)
+		http.Error(w, "failed to delete test vehicle", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/laps", http.StatusSeeOther)
+}
@@ -0,0 +1,55 @@
+package srv
+
+import (
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestBuildLapBoard(t *testing.T) {
+	t0 := time.Date(2026, 1, 21, 16, 0, 0, 0, time.UTC)
+	t1 := t0.Add(5 * time.Minute)
+	label := "white van"
+	vehicles := []dbgen.TestVehicle{
+		{ID: 1, Plate: "ABC123", Label: &label, ExpectedPerLap: 1},
+		{ID: 2, Plate: "XYZ-9", ExpectedPerLap: 2},
+	}
+	laps := []dbgen.Lap{
+		{Number: 1, StartedAt: t0, EndedAt: &t1},
+		{Number: 2, StartedAt: t1},
+	}
+	events := []lapEvent{
+		// Lap 1: ABC123 tracked twice under one car ID, XYZ9 once
+		{CarID: "1", Plate: "ABC123", CreatedAt: t0.Add(time.Minute)},
+		{CarID: "1", Plate: "abc 123", CreatedAt: t0.Add(time.Minute + time.Second)},
+		{CarID: "2", Plate: "XYZ9", CreatedAt: t0.Add(2 * time.Minute)},
+		// Lap 2: XYZ9 twice, ABC123 missing
+		{CarID: "3", Plate: "XYZ9", CreatedAt: t1.Add(time.Minute)},
+		{CarID: "4", Plate: "XYZ 9", CreatedAt: t1.Add(2 * time.Minute)},
+		// Not a test vehicle
+		{CarID: "5", Plate: "OTHER", CreatedAt: t1.Add(3 * time.Minute)},
+	}
+
+	board := buildLapBoard(vehicles, laps, events, t1.Add(4*time.Minute))
+
+	if board.SessionStart == nil || !board.SessionStart.Equal(t0) {
+		t.Fatalf("session start = %v, want %v", board.SessionStart, t0)
+	}
+	if got := board.Vehicles[0].Passes; got[0] != 1 || got[1] != 0 {
+		t.Errorf("ABC123 passes = %v, want [1 0]", got)
+	}
+	if got := board.Vehicles[1].Passes; got[0] != 1 || got[1] != 2 {
+		t.Errorf("XYZ-9 passes = %v, want [1 2]", got)
+	}
+	// Lap 2 is still running, so only lap 1 can count as missed
+	if board.Vehicles[0].MissedLaps != 0 || board.Vehicles[1].MissedLaps != 1 {
+		t.Errorf("missed laps = %d, %d, want 0, 1", board.Vehicles[0].MissedLaps, board.Vehicles[1].MissedLaps)
+	}
+	if l := board.Laps[0]; l.Expected != 3 || l.Detected != 2 {
+		t.Errorf("lap 1 detected/expected = %d/%d, want 2/3", l.Detected, l.Expected)
+	}
+	if l := board.Laps[1]; l.Detected != 2 {
+		t.Errorf("lap 2 detected = %d, want 2", l.Detected)
+	}
+}
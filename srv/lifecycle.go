@@ -0,0 +1,273 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// LifecycleStats summarizes how camera tracks went from carState "new" to
+// "lost": how many ended without a plate, how many messages a vehicle took
+// and how long after "new" its best read arrived. Camera engineers tune
+// trigger zones and recognition settings against these.
+type LifecycleStats struct {
+	Total   LifecycleSummary   `json:"total"`
+	Cameras []LifecycleSummary `json:"cameras"`
+}
+
+type LifecycleSummary struct {
+	Camera           string  `json:"camera,omitempty"`
+	Vehicles         int64   `json:"vehicles"`
+	Lost             int64   `json:"lost"`               // tracks whose last state is "lost"
+	LostWithoutPlate int64   `json:"lost_without_plate"` // of those, tracks without any plate read
+	Messages         int64   `json:"messages"`
+	AvgMessages      float64 `json:"avg_messages"`
+	TimedReads       int64   `json:"timed_reads"` // tracks with a "new" message and a plate read
+	AvgBestReadMs    int64   `json:"avg_best_read_ms"`
+	MedianBestReadMs int64   `json:"median_best_read_ms"`
+	MaxBestReadMs    int64   `json:"max_best_read_ms"`
+
+	bestReads []int64
+}
+
+// LostWithoutPlatePct is the share of lost tracks that never got a plate
+func (l LifecycleSummary) LostWithoutPlatePct() float64 {
+	if l.Lost == 0 {
+		return 0
+	}
+	return float64(l.LostWithoutPlate) * 100 / float64(l.Lost)
+}
+
+type lifecycleTrack struct {
+	Camera   string
+	State    string
+	Plate    string
+	Messages int64
+	Reads    []lifecycleMessage // empty for events recorded before message history
+}
+
+type lifecycleMessage struct {
+	State      string
+	Plate      string
+	Confidence *float64
+	At         time.Time
+}
+
+// timeToBestRead returns how long after the "new" message the read with the
+// highest plate confidence (the earliest of equals) arrived
+func timeToBestRead(reads []lifecycleMessage) (time.Duration, bool) {
+	var start, best *lifecycleMessage
+	for i := range reads {
+		m := &reads[i]
+		if start == nil && strings.EqualFold(m.State, "new") {
+			start = m
+		}
+		if m.Plate == "" {
+			continue
+		}
+		if best == nil || confidence(m.Confidence) > confidence(best.Confidence) {
+			best = m
+		}
+	}
+	if start == nil || best == nil {
+		return 0, false
+	}
+	return max(best.At.Sub(start.At), 0), true
+}
+
+func confidence(c *float64) float64 {
+	if c == nil {
+		return -1
+	}
+	return *c
+}
+
+func (l *LifecycleSummary) add(t lifecycleTrack) {
+	l.Vehicles++
+	l.Messages += t.Messages
+	if strings.EqualFold(t.State, "lost") {
+		l.Lost++
+		if t.Plate == "" {
+			l.LostWithoutPlate++
+		}
+	}
+	if d, ok := timeToBestRead(t.Reads); ok {
+		l.bestReads = append(l.bestReads, d.Milliseconds())
+	}
+}
+
+func (l *LifecycleSummary) finish() {
+	if l.Vehicles > 0 {
+		l.AvgMessages = float64(l.Messages) / float64(l.Vehicles)
+	}
+	n := len(l.bestReads)
+	if n == 0 {
+		return
+	}
+	slices.Sort(l.bestReads)
+	var sum int64
+	for _, ms := range l.bestReads {
+		sum += ms
+	}
+	l.TimedReads = int64(n)
+	l.AvgBestReadMs = sum / int64(n)
+	l.MedianBestReadMs = l.bestReads[n/2]
+	if n%2 == 0 {
+		l.MedianBestReadMs = (l.bestReads[n/2-1] + l.bestReads[n/2]) / 2
+	}
+	l.MaxBestReadMs = l.bestReads[n-1]
+}
+
+// buildLifecycleStats totals the tracks overall and per camera
+func buildLifecycleStats(tracks []lifecycleTrack) LifecycleStats {
+	var stats LifecycleStats
+	byCamera := make(map[string]*LifecycleSummary)
+	for _, t := range tracks {
+		stats.Total.add(t)
+		c := byCamera[t.Camera]
+		if c == nil {
+			c = &LifecycleSummary{Camera: t.Camera}
+			byCamera[t.Camera] = c
+		}
+		c.add(t)
+	}
+	stats.Total.finish()
+	stats.Cameras = make([]LifecycleSummary, 0, len(byCamera))
+	for _, c := range byCamera {
+		c.finish()
+		stats.Cameras = append(stats.Cameras, *c)
+	}
+	slices.SortFunc(stats.Cameras, func(a, b LifecycleSummary) int { return strings.Compare(a.Camera, b.Camera) })
+	return stats
+}
+
+// loadLifecycleStats builds the stats for the current session (archiveID nil) or an archive
+func (s *Server) loadLifecycleStats(ctx context.Context, archiveID *int64) (LifecycleStats, error) {
+	q := dbgen.New(s.DB)
+	var tracks []lifecycleTrack
+	index := make(map[int64]int)
+	addEvent := func(id int64, serial, provider, state, plate *string, messages int64) {
+		index[id] = len(tracks)
+		tracks = append(tracks, lifecycleTrack{
+			Camera:   coalesce(deref(serial), deref(provider)),
+			State:    deref(state),
+			Plate:    deref(plate),
+			Messages: messages,
+		})
+	}
+	addMessage := func(eventID int64, state, plate *string, conf *float64, at time.Time) {
+		if i, ok := index[eventID]; ok {
+			tracks[i].Reads = append(tracks[i].Reads, lifecycleMessage{State: deref(state), Plate: deref(plate), Confidence: conf, At: at})
+		}
+	}
+
+	if archiveID == nil {
+		events, err := q.GetLifecycleEvents(ctx)
+		if err != nil {
+			return LifecycleStats{}, err
+		}
+		for _, e := range events {
+			addEvent(e.ID, e.CameraSerial, e.SensorProviderID, e.CarState, e.PlateUtf8, e.MessageCount)
+		}
+		messages, err := q.GetLifecycleMessages(ctx)
+		if err != nil {
+			return LifecycleStats{}, err
+		}
+		for _, m := range messages {
+			addMessage(m.EventID, m.CarState, m.PlateUtf8, m.PlateConfidence, m.ReceivedAt)
+		}
+	} else {
+		events, err := q.GetArchiveLifecycleEvents(ctx, archiveID)
+		if err != nil {
+			return LifecycleStats{}, err
+		}
+		for _, e := range events {
+			addEvent(e.ID, e.CameraSerial, e.SensorProviderID, e.CarState, e.PlateUtf8, e.MessageCount)
+		}
+		messages, err := q.GetArchiveLifecycleMessages(ctx, archiveID)
+		if err != nil {
+			return LifecycleStats{}, err
+		}
+		for _, m := range messages {
+			addMessage(m.EventID, m.CarState, m.PlateUtf8, m.PlateConfidence, m.ReceivedAt)
+		}
+	}
+
+	return buildLifecycleStats(tracks), nil
+}
+
+// HandleLifecycle shows the lifecycle stats of the current session
+func (s *Server) HandleLifecycle(w http.ResponseWriter, r *http.Request) {
+	s.renderLifecycle(w, r, nil)
+}
+
+// HandleArchiveLifecycle shows the lifecycle stats of an archived session
+func (s *Server) HandleArchiveLifecycle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	if _, err := dbgen.New(s.DB).GetArchiveByID(r.Context(), id); err != nil {
+		http.Error(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	s.renderLifecycle(w, r, &id)
+}
+
+func (s *Server) renderLifecycle(w http.ResponseWriter, r *http.Request, archiveID *int64) {
+	stats, err := s.loadLifecycleStats(r.Context(), archiveID)
+	if err != nil {
+		slog.Warn("failed to load lifecycle stats", "error", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	data := struct {
+		Hostname  string
+		Stats     LifecycleStats
+		ArchiveID int64
+	}{
+		Hostname: s.Hostname,
+		Stats:    stats,
+	}
+	if archiveID != nil {
+		data.ArchiveID = *archiveID
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "lifecycle.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleLifecycleAPI returns the lifecycle stats as JSON.
+// Pass ?archive=ID for an archived session.
+func (s *Server) HandleLifecycleAPI(w http.ResponseWriter, r *http.Request) {
+	var archiveID *int64
+	if a := r.URL.Query().Get("archive"); a != "" && a != "0" {
+		id, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			s.jsonError(w, "invalid archive id", http.StatusBadRequest)
+			return
+		}
+		archiveID = &id
+	}
+
+	stats, err := s.loadLifecycleStats(r.Context(), archiveID)
+	if err != nil {
+		slog.Warn("failed to load lifecycle stats", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,46 @@
+package srv
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildLifecycleStats(t *testing.T) {
+	t0 := time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)
+	conf := func(c float64) *float64 { return &c }
+	tracks := []lifecycleTrack{
+		// Best read (0.9) arrives 800ms after new; a later, weaker read doesn't count
+		{Camera: "cam1", State: "lost", Plate: "AB123", Messages: 3, Reads: []lifecycleMessage{
+			{State: "new", Plate: "AB12", Confidence: conf(0.5), At: t0},
+			{State: "update", Plate: "AB123", Confidence: conf(0.9), At: t0.Add(800 * time.Millisecond)},
+			{State: "lost", Plate: "AB123", Confidence: conf(0.7), At: t0.Add(2 * time.Second)},
+		}},
+		// Lost without ever reading a plate
+		{Camera: "cam1", State: "lost", Messages: 2, Reads: []lifecycleMessage{
+			{State: "new", At: t0},
+			{State: "lost", At: t0.Add(time.Second)},
+		}},
+		// Recorded before message history: counted, not timed
+		{Camera: "cam2", State: "new", Plate: "XY9", Messages: 1},
+	}
+
+	stats := buildLifecycleStats(tracks)
+
+	total := stats.Total
+	if total.Vehicles != 3 || total.Lost != 2 || total.LostWithoutPlate != 1 {
+		t.Errorf("total = %d vehicles, %d lost, %d without plate; want 3, 2, 1", total.Vehicles, total.Lost, total.LostWithoutPlate)
+	}
+	if total.AvgMessages != 2 || total.LostWithoutPlatePct() != 50 {
+		t.Errorf("avg messages = %v, lost without plate = %v%%; want 2, 50%%", total.AvgMessages, total.LostWithoutPlatePct())
+	}
+	if total.TimedReads != 1 || total.AvgBestReadMs != 800 || total.MaxBestReadMs != 800 {
+		t.Errorf("best read = %d timed, avg %dms, max %dms; want 1, 800, 800", total.TimedReads, total.AvgBestReadMs, total.MaxBestReadMs)
+	}
+
+	if len(stats.Cameras) != 2 || stats.Cameras[0].Camera != "cam1" || stats.Cameras[1].Vehicles != 1 {
+		t.Fatalf("cameras = %+v", stats.Cameras)
+	}
+	if c := stats.Cameras[1]; c.TimedReads != 0 || c.Lost != 0 {
+		t.Errorf("cam2 = %+v, want untimed and not lost", c)
+	}
+}
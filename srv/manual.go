@@ -0,0 +1,255 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// ManualEvent describes a vehicle recorded by hand, e.g. one the camera
+// missed entirely during a supervised test pass
+type ManualEvent struct {
+	Plate        string `json:"plate"`
+	PlateCountry string `json:"plate_country"`
+	PlateRegion  string `json:"plate_region_code"`
+	Make         string `json:"make"`
+	Model        string `json:"model"`
+	Color        string `json:"color"`
+	Type         string `json:"type"`
+	CameraSerial string `json:"camera_serial"`
+	DateTime     string `json:"datetime"`
+
+	Images []ManualImage `json:"images,omitempty"` // API only; the form uploads files
+}
+
+// ManualImage is an image sent with a manual event in a JSON body
+type ManualImage struct {
+	Filename string `json:"filename"`
+	Data     []byte `json:"data"` // base64
+}
+
+// saveImage stores an image for an event in the database and as a file.
+// Callers queue it for measuring once it is committed.
+func (s *Server) saveImage(ctx context.Context, q *dbgen.Queries, eventID int64, imgType, filename, plate string, data []byte, now time.Time) (int64, error) {
+	imgID, err := q.InsertImage(ctx, dbgen.InsertImageParams{
+		EventID:   eventID,
+		ImageType: &imgType,
+		Filename:  &filename,
+		ImageData: data,
+		SizeBytes: int64(len(data)),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	safePlate := sanitizeFilename(plate)
+	if safePlate == "" {
+		safePlate = "unknown"
+	}
+	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
+	if ext == "" {
+		ext = "jpg"
+	}
+	diskFilename := fmt.Sprintf("%d_%s_%s.%s", imgID, safePlate, imgType, sanitizeFilename(ext))
+	s.storeImageFile(ctx, q, imgID, diskFilename, data)
+	return imgID, nil
+}
+
+// detectImageType guesses plate vs vehicle from a camera filename
+func detectImageType(filename string) string {
+	lowerName := strings.ToLower(filename)
+	if strings.Contains(lowerName, "lpup") || strings.Contains(lowerName, "plate") {
+		return "plate"
+	} else if strings.Contains(lowerName, "roi") || strings.Contains(lowerName, "vehicle") {
+		return "vehicle"
+	}
+	return "uploaded"
+}
+
+// createManualEvent inserts a manually entered event into the current session
+func (s *Server) createManualEvent(ctx context.Context, m ManualEvent) (int64, error) {
+	plate := strings.ToUpper(strings.TrimSpace(m.Plate))
+	if plate == "" {
+		return 0, fmt.Errorf("plate is required")
+	}
+
+	now := time.Now()
+	eventDatetime := ptrIfNotEmpty(strings.TrimSpace(m.DateTime))
+	capturedAt, timestampError := s.captureTime(eventDatetime, nil, now)
+	q := dbgen.New(s.DB)
+	return q.InsertEvent(ctx, dbgen.InsertEventParams{
+		CarID:           fmt.Sprintf("manual-%d", now.UnixNano()),
+		PlateUtf8:       &plate,
+		EventDatetime:   eventDatetime,
+		PlateCountry:    ptrIfNotEmpty(strings.TrimSpace(m.PlateCountry)),
+		PlateRegionCode: ptrIfNotEmpty(strings.TrimSpace(m.PlateRegion)),
+		VehicleMake:     ptrIfNotEmpty(strings.TrimSpace(m.Make)),
+		VehicleModel:    ptrIfNotEmpty(strings.TrimSpace(m.Model)),
+		VehicleColor:    ptrIfNotEmpty(strings.TrimSpace(m.Color)),
+		VehicleType:     ptrIfNotEmpty(strings.TrimSpace(m.Type)),
+		CameraSerial:    ptrIfNotEmpty(strings.TrimSpace(m.CameraSerial)),
+		Source:          "manual",
+		NodeID:          ptrIfNotEmpty(s.NodeID),
+		Uid:             ptr(newULID(now)),
+		CapturedAt:      capturedAt,
+		TimestampError:  timestampError,
+		CreatedAt:       now,
+	})
+}
+
+// HandleNewEventForm shows the manual event entry form
+func (s *Server) HandleNewEventForm(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Hostname string
+		Error    string
+	}{
+		Hostname: s.Hostname,
+		Error:    r.URL.Query().Get("error"),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "new_event.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleNewEvent creates a manual event from the entry form, with an optional image
+func (s *Server) HandleNewEvent(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil && err != http.ErrNotMultipart {
+		http.Error(w, "failed to parse form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m := ManualEvent{
+		Plate:        r.FormValue("plate"),
+		PlateCountry: r.FormValue("plate_country"),
+		PlateRegion:  r.FormValue("plate_region_code"),
+		Make:         r.FormValue("make"),
+		Model:        r.FormValue("model"),
+		Color:        r.FormValue("color"),
+		Type:         r.FormValue("type"),
+		CameraSerial: r.FormValue("camera_serial"),
+		DateTime:     r.FormValue("datetime"),
+	}
+	if strings.TrimSpace(m.Plate) == "" {
+		http.Redirect(w, r, "/event/new?error=Plate+is+required", http.StatusSeeOther)
+		return
+	}
+
+	eventID, err := s.createManualEvent(r.Context(), m)
+	if err != nil {
+		slog.Error("failed to create manual event", "error", err)
+		http.Error(w, "failed to create event", http.StatusInternalServerError)
+		return
+	}
+
+	var images []uploadedImage
+	if r.MultipartForm != nil {
+		images = formImages(r.MultipartForm, "image")
+	}
+	imageCount := s.saveManualImages(r.Context(), eventID, m.Plate, images)
+
+	s.publishEvent(r.Context(), eventID)
+	slog.Info("manual event recorded", "id", eventID, "plate", m.Plate, "images", imageCount)
+	http.Redirect(w, r, fmt.Sprintf("/event/%d", eventID), http.StatusSeeOther)
+}
+
+// saveManualImages stores the images of a manual event and returns how
+// many were stored
+func (s *Server) saveManualImages(ctx context.Context, eventID int64, plate string, images []uploadedImage) int {
+	q := dbgen.New(s.DB)
+	now := time.Now()
+	stored := 0
+	for _, img := range images {
+		if len(img.Data) == 0 {
+			continue
+		}
+		if _, err := s.saveImage(ctx, q, eventID, detectImageType(img.Filename), img.Filename, plate, img.Data, now); err != nil {
+			slog.Warn("failed to save manual event image", "error", err)
+			continue
+		}
+		stored++
+	}
+	if stored > 0 {
+		s.queueMeasure()
+		s.resetQuotaUsage()
+	}
+	return stored
+}
+
+// formImages reads the files uploaded in a multipart form field
+func formImages(form *multipart.Form, field string) []uploadedImage {
+	var images []uploadedImage
+	for _, f := range form.File[field] {
+		file, err := f.Open()
+		if err != nil {
+			continue
+		}
+		data, _ := io.ReadAll(file)
+		file.Close()
+		images = append(images, uploadedImage{Filename: f.Filename, Field: field, Data: data})
+	}
+	return images
+}
+
+// HandleCreateEventAPI creates a manual event from a JSON body, whose
+// images carry base64 data, or from a multipart form with the JSON in its
+// json field and image files in image fields
+func (s *Server) HandleCreateEventAPI(w http.ResponseWriter, r *http.Request) {
+	var m ManualEvent
+	var images []uploadedImage
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			s.jsonError(w, "failed to parse multipart: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := json.Unmarshal([]byte(r.FormValue("json")), &m); err != nil {
+			s.jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		images = formImages(r.MultipartForm, "image")
+	} else if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		s.jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, img := range m.Images {
+		images = append(images, uploadedImage{Filename: img.Filename, Data: img.Data})
+	}
+	if strings.TrimSpace(m.Plate) == "" {
+		s.jsonError(w, "plate is required", http.StatusBadRequest)
+		return
+	}
+
+	eventID, err := s.createManualEvent(r.Context(), m)
+	if err != nil {
+		slog.Error("failed to create manual event", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	imageCount := s.saveManualImages(r.Context(), eventID, m.Plate, images)
+
+	s.publishEvent(r.Context(), eventID)
+	slog.Info("manual event recorded", "id", eventID, "plate", m.Plate, "images", imageCount)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"message": "event recorded",
+		"id":      eventID,
+		"source":  "manual",
+		"images":  imageCount,
+	})
+}
@@ -0,0 +1,116 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestCreateEventAPI(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+	create := func(r *http.Request) (int, map[string]any) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.HandleCreateEventAPI(w, r)
+		s.background.Wait()
+		var out map[string]any
+		json.NewDecoder(w.Body).Decode(&out)
+		return w.Code, out
+	}
+	images := func(id any) []dbgen.GetImagesByEventIDRow {
+		t.Helper()
+		n, _ := id.(float64)
+		imgs, err := q.GetImagesByEventID(ctx, int64(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return imgs
+	}
+
+	// JSON with a base64 image
+	plate := pngOf(120, 30)
+	body := `{"plate":"ab123","make":"Skoda","images":[{"filename":"lpup.png","data":"` + base64.StdEncoding.EncodeToString(plate) + `"}]}`
+	code, out := create(httptest.NewRequest("POST", "/api/events", strings.NewReader(body)))
+	if code != http.StatusCreated || out["images"] != float64(1) {
+		t.Fatalf("JSON: %d %v", code, out)
+	}
+	imgs := images(out["id"])
+	if len(imgs) != 1 || deref(imgs[0].ImageType) != "plate" || imgs[0].Width == nil {
+		t.Errorf("JSON images %+v", imgs)
+	}
+	if ev, _ := q.GetEventByID(ctx, int64(out["id"].(float64))); deref(ev.PlateUtf8) != "AB123" || ev.Source != "manual" {
+		t.Errorf("event %+v", ev)
+	}
+
+	// Multipart with the JSON in a field and image files
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("json", `{"plate":"CD456"}`)
+	fw, _ := mw.CreateFormFile("image", "roi.png")
+	fw.Write(pngOf(300, 200))
+	fw, _ = mw.CreateFormFile("image", "lpup.png")
+	fw.Write(plate)
+	mw.Close()
+	r := httptest.NewRequest("POST", "/api/events", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	code, out = create(r)
+	if code != http.StatusCreated || out["images"] != float64(2) {
+		t.Fatalf("multipart: %d %v", code, out)
+	}
+	if imgs := images(out["id"]); len(imgs) != 2 || deref(imgs[0].ImageType) != "vehicle" || deref(imgs[1].ImageType) != "plate" {
+		t.Errorf("multipart images %+v", imgs)
+	}
+
+	// Images stay optional; the plate doesn't
+	code, out = create(httptest.NewRequest("POST", "/api/events", strings.NewReader(`{"plate":"EF789"}`)))
+	if code != http.StatusCreated || out["images"] != float64(0) {
+		t.Errorf("without images: %d %v", code, out)
+	}
+	if code, _ := create(httptest.NewRequest("POST", "/api/events", strings.NewReader(`{"make":"VW"}`))); code != http.StatusBadRequest {
+		t.Errorf("without plate: %d", code)
+	}
+	if code, _ := create(httptest.NewRequest("POST", "/api/events", strings.NewReader(`{"plate":"X","images":[{"data":"%%"}]}`))); code != http.StatusBadRequest {
+		t.Errorf("invalid base64: %d", code)
+	}
+}
+
+func TestNewEventForm(t *testing.T) {
+	s := newTestServer(t)
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("plate", "gh012")
+	mw.WriteField("color", "red")
+	fw, _ := mw.CreateFormFile("image", "vehicle.png")
+	fw.Write(pngOf(300, 200))
+	mw.Close()
+	r := httptest.NewRequest("POST", "/event/new", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.HandleNewEvent(w, r)
+	s.background.Wait()
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/event/1" {
+		t.Fatalf("%d %v", w.Code, w.Header())
+	}
+	imgs, _ := dbgen.New(s.DB).GetImagesByEventID(context.Background(), 1)
+	if len(imgs) != 1 || deref(imgs[0].ImageType) != "vehicle" {
+		t.Errorf("images %+v", imgs)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/event/new", strings.NewReader("color=red"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.HandleNewEvent(w, r)
+	if w.Code != http.StatusSeeOther || !strings.Contains(w.Header().Get("Location"), "error=Plate") {
+		t.Errorf("without plate: %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,346 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Cameras without a built-in parser can be onboarded with field-mapping
+// rules instead of code. -mapping-config names a JSON file of mappings,
+// each a vendor name for ?vendor= (and compat routes) with the paths in the
+// camera's JSON payload that each event key is read from:
+//
+//	{"mappings": [{
+//	  "vendor": "acme",
+//	  "match": "$.result.plate",
+//	  "require": "$.result.plate.text",
+//	  "fields": {
+//	    "plateUTF8": "$.result.plate.text",
+//	    "plateConfidence": "$.result.plate.score",
+//	    "capture_timestamp": ["$.result.time", "$.timestamp"],
+//	    "camera_info.SerialNumber": "$.device.serial",
+//	    "vehicle_info.make": "$.result.vehicle.make",
+//	    "speed": "$.result.speed"
+//	  },
+//	  "images": {"$.result.plate.crop": "plate", "$.frames[*].jpeg": "vehicle"}
+//	}]}
+//
+// Paths are $ followed by .name, ["name"], [index] or [*] (every element).
+// A list of paths is tried in order; the first one found wins. Event keys
+// are the camera keys of /api/formats (or their column names), dotted for
+// nested objects; other keys are stored as they are. Images are base64
+// (data: URLs too) and go to ImageArray with the given type. A payload
+// with the match path is read by the mapping without a ?vendor= hint; one
+// without the require path (a heartbeat) is acknowledged and dropped.
+
+// MappingConfig holds field-mapping rules for payloads of other cameras
+type MappingConfig struct {
+	Mappings []*FieldMapping `json:"mappings"`
+}
+
+// FieldMapping reads the JSON payload of one camera model
+type FieldMapping struct {
+	Vendor  string                  `json:"vendor"`
+	Match   string                  `json:"match,omitempty"`   // sniffed when this path is found
+	Require string                  `json:"require,omitempty"` // not a plate read without this path
+	Fields  map[string]mappingPaths `json:"fields"`
+	Images  map[string]string       `json:"images,omitempty"` // path → image type
+
+	match, require jsonPath
+	fields         []mappedField
+	images         []mappedImage
+}
+
+// mappingPaths is one path or a list of paths tried in order
+type mappingPaths []string
+
+func (p *mappingPaths) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*p = mappingPaths{one}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("want a path or a list of paths")
+	}
+	*p = list
+	return nil
+}
+
+type mappedField struct {
+	key, typ string
+	paths    []jsonPath
+}
+
+type mappedImage struct {
+	path jsonPath
+	typ  string
+}
+
+// LoadMappingConfig reads a field-mapping configuration file
+func LoadMappingConfig(path string) (*MappingConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg MappingConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for _, p := range vendorParsers {
+		seen[p.name()] = true
+	}
+	for i, m := range cfg.Mappings {
+		name := strings.ToLower(m.Vendor)
+		if name == "" {
+			return nil, fmt.Errorf("mapping %d: vendor is required", i)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("mapping %d: vendor %q is already taken", i, m.Vendor)
+		}
+		seen[name] = true
+		if err := m.compile(); err != nil {
+			return nil, fmt.Errorf("mapping %s: %w", m.Vendor, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// compile parses the paths of a mapping
+func (m *FieldMapping) compile() error {
+	var err error
+	if m.Match != "" {
+		if m.match, err = parseJSONPath(m.Match); err != nil {
+			return fmt.Errorf("match: %w", err)
+		}
+	}
+	if m.Require != "" {
+		if m.require, err = parseJSONPath(m.Require); err != nil {
+			return fmt.Errorf("require: %w", err)
+		}
+	}
+	if len(m.Fields) == 0 && len(m.Images) == 0 {
+		return fmt.Errorf("no fields or images")
+	}
+	for _, key := range slices.Sorted(maps.Keys(m.Fields)) {
+		f := mappedField{key: key}
+		if k, ok := formKeys[strings.ToLower(key)]; ok {
+			f.key, f.typ = k.key, k.typ
+		}
+		if len(m.Fields[key]) == 0 {
+			return fmt.Errorf("field %s: no path", key)
+		}
+		for _, p := range m.Fields[key] {
+			jp, err := parseJSONPath(p)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", key, err)
+			}
+			f.paths = append(f.paths, jp)
+		}
+		m.fields = append(m.fields, f)
+	}
+	for _, p := range slices.Sorted(maps.Keys(m.Images)) {
+		jp, err := parseJSONPath(p)
+		if err != nil {
+			return fmt.Errorf("image %s: %w", p, err)
+		}
+		m.images = append(m.images, mappedImage{jp, coalesce(m.Images[p], "embedded")})
+	}
+	return nil
+}
+
+func (m *FieldMapping) name() string              { return m.Vendor }
+func (m *FieldMapping) pictureType(string) string { return "" }
+
+func (m *FieldMapping) sniff(data []byte) bool {
+	if m.match == nil || looksLikeXML(data) {
+		return false
+	}
+	doc, err := decodeMappedJSON(data)
+	return err == nil && len(m.match.find(doc)) > 0
+}
+
+// eventJSON builds event JSON from the mapped paths of a payload
+func (m *FieldMapping) eventJSON(data []byte) ([]byte, error) {
+	if looksLikeXML(data) {
+		return nil, &payloadError{fmt.Sprintf("vendor %s reads JSON payloads", m.Vendor)}
+	}
+	doc, err := decodeMappedJSON(data)
+	if err != nil {
+		return nil, &payloadError{"invalid JSON: " + err.Error()}
+	}
+	if m.require != nil && len(m.require.find(doc)) == 0 {
+		return nil, fmt.Errorf("%w: no %s", errNotANPR, m.Require)
+	}
+
+	event := map[string]any{}
+	for _, f := range m.fields {
+		for _, p := range f.paths {
+			if v, ok := mappedValue(p.find(doc), f.typ); ok {
+				setEventKey(event, f.key, v)
+				break
+			}
+		}
+	}
+	var images []any
+	for _, img := range m.images {
+		for _, v := range img.path.find(doc) {
+			s, _ := v.(string)
+			if _, b64, ok := strings.Cut(s, ";base64,"); ok && strings.HasPrefix(s, "data:") {
+				s = b64
+			}
+			if s != "" {
+				images = append(images, map[string]any{"BinaryImage": s, "ImageType": img.typ})
+			}
+		}
+	}
+	if len(images) > 0 {
+		event["ImageArray"] = images
+	}
+	if len(event) == 0 {
+		return nil, &payloadError{fmt.Sprintf("no %s fields found", m.Vendor)}
+	}
+	return json.Marshal(event)
+}
+
+// decodeMappedJSON decodes a payload keeping numbers as they were sent
+func decodeMappedJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	err := dec.Decode(&doc)
+	return doc, err
+}
+
+// mappedValue is the first usable value found at a path, as the event key's
+// type: numbers for number keys, strings for the other camera keys, and
+// anything for keys of its own
+func mappedValue(found []any, typ string) (any, bool) {
+	for _, v := range found {
+		switch v := v.(type) {
+		case nil:
+			continue
+		case string:
+			if v == "" {
+				continue
+			}
+			if typ == "number" {
+				if n, err := strconv.ParseFloat(v, 64); err == nil {
+					return n, true
+				}
+			}
+			return v, true
+		case json.Number:
+			if typ == "number" {
+				if n, err := v.Float64(); err == nil {
+					return n, true
+				}
+			}
+			if typ == "" {
+				return v, true
+			}
+			return v.String(), true
+		case bool:
+			if typ != "" {
+				return strconv.FormatBool(v), true
+			}
+			return v, true
+		default:
+			if typ != "" {
+				continue // an object where the camera key wants a value
+			}
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// jsonPath is a parsed path: names, indexes, and -1 for [*]
+type jsonPath []any
+
+// parseJSONPath parses $.a.b, $["a b"], $.a[0] and $.a[*].b
+func parseJSONPath(s string) (jsonPath, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), "$")
+	if !ok {
+		return nil, fmt.Errorf("path %q must start with $", s)
+	}
+	var p jsonPath
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("path %q: empty name", s)
+			}
+			p = append(p, name)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q: missing ]", s)
+			}
+			inner := rest[1:end]
+			switch {
+			case inner == "*":
+				p = append(p, -1)
+			case strings.HasPrefix(inner, `"`) || strings.HasPrefix(inner, "'"):
+				if len(inner) < 2 || inner[len(inner)-1] != inner[0] {
+					return nil, fmt.Errorf("path %q: unterminated name", s)
+				}
+				p = append(p, inner[1:len(inner)-1])
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("path %q: bad index %q", s, inner)
+				}
+				p = append(p, n)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("path %q: unexpected %q", s, rest[0])
+		}
+	}
+	return p, nil
+}
+
+// find returns the values at a path, several for [*]
+func (p jsonPath) find(doc any) []any {
+	vals := []any{doc}
+	for _, step := range p {
+		var next []any
+		for _, v := range vals {
+			switch step := step.(type) {
+			case string:
+				if obj, ok := v.(map[string]any); ok {
+					if c, ok := obj[step]; ok {
+						next = append(next, c)
+					}
+				}
+			case int:
+				arr, ok := v.([]any)
+				if !ok {
+					continue
+				}
+				if step < 0 {
+					next = append(next, arr...)
+				} else if step < len(arr) {
+					next = append(next, arr[step])
+				}
+			}
+		}
+		vals = next
+	}
+	return vals
+}
@@ -0,0 +1,122 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const acmeMapping = `{"mappings": [{
+  "vendor": "acme",
+  "match": "$.result.plate",
+  "require": "$.result.plate.text",
+  "fields": {
+    "plate_utf8": "$.result.plate.text",
+    "plateConfidence": "$.result.plate.score",
+    "capture_timestamp": ["$.result.time", "$.timestamp"],
+    "camera_info.SerialNumber": "$['device']['serial no']",
+    "geotag.lat": "$.gps[0]",
+    "geotag.lon": "$.gps[1]",
+    "vehicle_info.make": "$.result.vehicle.make",
+    "speed": "$.result.speed"
+  },
+  "images": {"$.result.plate.crop": "plate", "$.frames[*].jpeg": "vehicle"}
+}]}`
+
+func TestParseJSONPath(t *testing.T) {
+	for path, want := range map[string]jsonPath{
+		"$":                {},
+		"$.a.b":            {"a", "b"},
+		`$["a.b"][2]`:      {"a.b", 2},
+		"$.frames[*].jpeg": {"frames", -1, "jpeg"},
+	} {
+		got, err := parseJSONPath(path)
+		if err != nil || len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("%s = %v, %v; want %v", path, got, err, want)
+		}
+	}
+	for _, bad := range []string{"a.b", "$.", "$[x]", "$[1", `$["a]`} {
+		if _, err := parseJSONPath(bad); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+func TestFieldMapping(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "mappings.json")
+	os.WriteFile(cfgPath, []byte(acmeMapping), 0644)
+	cfg, err := LoadMappingConfig(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := cfg.Mappings[0]
+
+	got, err := m.eventJSON([]byte(`{"timestamp": 1714745464, "device": {"serial no": "AC-7"}, "gps": [52.5, "13.4"],
+		"result": {"plate": {"text": "AC123", "score": 0.93}, "vehicle": {"make": null}, "speed": 42}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a, b any
+	json.Unmarshal(got, &a)
+	json.Unmarshal([]byte(`{"plateUTF8":"AC123","plateConfidence":"0.93","capture_timestamp":"1714745464",
+		"camera_info":{"SerialNumber":"AC-7"},"geotag":{"lat":52.5,"lon":13.4},"speed":42}`), &b)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("got %s", got)
+	}
+
+	// Heartbeats lack the required path
+	if _, err := m.eventJSON([]byte(`{"result": {"status": "alive"}}`)); !errors.Is(err, errNotANPR) {
+		t.Errorf("heartbeat: %v", err)
+	}
+
+	for _, bad := range []string{
+		`{"mappings": [{"vendor": "dahua", "fields": {"plateUTF8": "$.p"}}]}`,
+		`{"mappings": [{"vendor": "x", "fields": {"plateUTF8": "p"}}]}`,
+		`{"mappings": [{"vendor": "x"}]}`,
+		`{"mappings": [{"vendor": "x", "fields": {"plateUTF8": 1}}]}`,
+	} {
+		os.WriteFile(cfgPath, []byte(bad), 0644)
+		if _, err := LoadMappingConfig(cfgPath); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+
+	// A payload with the match path is read by the mapping without a hint
+	s := newTestServer(t)
+	s.Mappings = cfg
+	var pic bytes.Buffer
+	png.Encode(&pic, image.NewGray(image.Rect(0, 0, 8, 8)))
+	b64 := base64.StdEncoding.EncodeToString(pic.Bytes())
+	body := `{"result": {"plate": {"text": "AC456", "crop": "data:image/png;base64,` + b64 + `"}},
+		"frames": [{"jpeg": "` + b64 + `"}, {"jpeg": ""}]}`
+	r := httptest.NewRequest("POST", "/api", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.HandleAPI(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /api: %d %s", w.Code, w.Body)
+	}
+	var plate, types string
+	s.DB.QueryRow(`SELECT e.plate_utf8, (SELECT GROUP_CONCAT(image_type) FROM (SELECT image_type FROM images WHERE event_id = e.id ORDER BY id))
+		FROM events e`).Scan(&plate, &types)
+	if plate != "AC456" || types != "vehicle,plate" {
+		t.Errorf("stored %s with %s images", plate, types)
+	}
+
+	w = httptest.NewRecorder()
+	s.HandleFormats(w, httptest.NewRequest("GET", "/api/formats", nil))
+	if !strings.Contains(w.Body.String(), `"vendors":["acme","hikvision"`) || !strings.Contains(w.Body.String(), `"vendor":"acme"`) {
+		t.Errorf("formats: %s", w.Body)
+	}
+}
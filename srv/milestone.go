@@ -0,0 +1,300 @@
+package srv
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With -milestone-config every camera read stored is sent to a Milestone
+// XProtect Event Server as an Analytics Event: one XML document per TCP
+// connection to the analytics events port (9090 by default). The message
+// must match an analytics event defined in the Management Client, where it
+// can trigger alarms, rules and bookmarks. The source is the XProtect
+// device the read is attributed to: a name from sources, else the camera
+// IP, which XProtect matches against its hardware.
+//
+//	{"address": "xprotect-es:9090", "message": "LPR read",
+//	 "sources": {"CAM123": "Gate 1 Camera"}, "filter": {"plate": "AB*"}}
+//
+// Reads go out as they are stored and are not queued on disk: reads stored
+// while the Event Server is unreachable are dropped after a few attempts.
+// Updates of an event already sent (merged messages) don't trigger again.
+
+const (
+	milestoneAttempts = 3
+	milestoneRetry    = time.Second
+)
+
+// MilestoneConfig is the XProtect Event Server reads are sent to
+type MilestoneConfig struct {
+	Address string            `json:"address"` // host:port of the analytics events receiver
+	Message string            `json:"message"` // analytics event name (default "LPR read")
+	Sources map[string]string `json:"sources"` // camera serial, sensor provider ID or IP -> XProtect source name
+	Vendor  string            `json:"vendor"`  // default "FF Group"
+	Filter  eventFilter       `json:"filter"`  // cameras, plate pattern, nodes or unrecognized, as on /api/events/stream
+	Timeout int               `json:"timeout"` // seconds per connection (default 5)
+
+	mu       sync.Mutex
+	lastID   int64 // highest event sent; lower ids are updates
+	sent     int64
+	failed   int64
+	lastSent time.Time
+	lastErr  error
+}
+
+// LoadMilestoneConfig reads a Milestone XProtect output config file
+func LoadMilestoneConfig(path string) (*MilestoneConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg MilestoneConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// compile checks the config and fills in defaults
+func (c *MilestoneConfig) compile() error {
+	host, port, err := net.SplitHostPort(c.Address)
+	if err != nil {
+		host, port = c.Address, "9090"
+	}
+	if host == "" {
+		return fmt.Errorf("address must be host[:port] of the XProtect analytics events receiver")
+	}
+	c.Address = net.JoinHostPort(host, port)
+	if c.Message == "" {
+		c.Message = "LPR read"
+	}
+	if c.Vendor == "" {
+		c.Vendor = "FF Group"
+	}
+	c.Filter.Plate = strings.ToUpper(c.Filter.Plate)
+	if c.Filter.Plate != "" {
+		if _, err := path.Match(c.Filter.Plate, ""); err != nil {
+			return fmt.Errorf("invalid filter plate pattern %q", c.Filter.Plate)
+		}
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5
+	}
+	return nil
+}
+
+// milestoneEvent is an XProtect Analytics Event document
+type milestoneEvent struct {
+	XMLName     xml.Name          `xml:"urn:milestone-systems AnalyticsEvent"`
+	Header      milestoneHeader   `xml:"EventHeader"`
+	Description string            `xml:"Description,omitempty"`
+	Location    string            `xml:"Location,omitempty"`
+	Vendor      string            `xml:"Vendor>Name"`
+	Objects     []milestoneObject `xml:"ObjectList>Object,omitempty"`
+}
+
+type milestoneHeader struct {
+	ID        string `xml:"ID"` // GUID derived from the event UID, the same on every attempt
+	Timestamp string `xml:"Timestamp"`
+	Type      string `xml:"Type"`
+	Message   string `xml:"Message"`
+	CustomTag string `xml:"CustomTag,omitempty"` // the plate, for rules filtering on it
+	Source    string `xml:"Source>Name"`
+}
+
+type milestoneObject struct {
+	Name       string   `xml:"Name"`
+	Type       string   `xml:"Type"`
+	Value      string   `xml:"Value"`
+	Confidence *float64 `xml:"Confidence,omitempty"`
+}
+
+// milestoneGUID derives a stable GUID from an event UID (name-based, like
+// a version 5 UUID)
+func milestoneGUID(uid string) string {
+	h := sha1.Sum([]byte("mmrapi:" + uid))
+	h[6] = h[6]&0x0f | 0x50
+	h[8] = h[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+// analyticsEvent converts a read into an Analytics Event
+func (c *MilestoneConfig) analyticsEvent(e ExportEvent) milestoneEvent {
+	at := e.CreatedAt
+	if e.CapturedAt != nil {
+		at = *e.CapturedAt
+	}
+	plate := coalesce(deref(e.ManualPlate), deref(e.Plate))
+	source := coalesce(deref(e.Camera.IP), deref(e.Camera.Serial), deref(e.SensorProviderID))
+	for _, k := range []string{deref(e.Camera.Serial), deref(e.SensorProviderID), deref(e.Camera.IP)} {
+		if name, ok := c.Sources[k]; ok && k != "" {
+			source = name
+			break
+		}
+	}
+	desc := "Plate " + coalesce(plate, "not read")
+	if v := coalesce(deref(e.PlateRegionCode), deref(e.PlateRegion), deref(e.PlateCountry)); v != "" {
+		desc += " (" + v + ")"
+	}
+	for _, v := range []string{deref(e.Vehicle.Color), deref(e.Vehicle.Make), deref(e.Vehicle.Model)} {
+		if v != "" {
+			desc += " " + v
+		}
+	}
+	if e.Lane != nil {
+		desc += ", lane " + strconv.FormatInt(*e.Lane, 10)
+	}
+	if e.Direction != nil {
+		desc += ", " + *e.Direction
+	}
+	ev := milestoneEvent{
+		Header: milestoneHeader{
+			ID:        milestoneGUID(coalesce(deref(e.UID), strconv.FormatInt(e.ID, 10))),
+			Timestamp: at.UTC().Format(time.RFC3339Nano),
+			Type:      "LPR",
+			Message:   c.Message,
+			CustomTag: plate,
+			Source:    source,
+		},
+		Description: desc,
+		Vendor:      c.Vendor,
+	}
+	if e.GeotagLat != nil && e.GeotagLon != nil {
+		ev.Location = fmt.Sprintf("%g,%g", *e.GeotagLat, *e.GeotagLon)
+	}
+	if plate != "" {
+		ev.Objects = append(ev.Objects, milestoneObject{Name: "LicensePlate", Type: "LicensePlate", Value: plate, Confidence: e.PlateConfidence})
+	}
+	return ev
+}
+
+// send delivers one Analytics Event on a new connection
+func (c *MilestoneConfig) send(ev milestoneEvent) error {
+	body, err := xml.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	timeout := time.Duration(c.Timeout) * time.Second
+	conn, err := net.DialTimeout("tcp", c.Address, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(append([]byte(xml.Header), body...)); err != nil {
+		return err
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		tc.CloseWrite()
+	}
+	// The Event Server answers and closes; its answer carries nothing we need
+	io.Copy(io.Discard, io.LimitReader(conn, 64<<10))
+	return nil
+}
+
+// deliver sends a read, retrying a few times
+func (c *MilestoneConfig) deliver(ctx context.Context, e ExportEvent) error {
+	ev := c.analyticsEvent(e)
+	var err error
+	for attempt := range milestoneAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(milestoneRetry):
+			}
+		}
+		if err = c.send(ev); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// runMilestone sends the reads stored from now on until ctx is done
+func (s *Server) runMilestone(ctx context.Context) {
+	c := s.Milestone
+	sub := s.subscribers.subscribe(c.Filter)
+	defer s.subscribers.unsubscribe(sub)
+	slog.Info("sending reads to milestone xprotect", "address", c.Address, "message", c.Message)
+	for {
+		var e ExportEvent
+		select {
+		case <-ctx.Done():
+			return
+		case e = <-sub.events:
+		}
+		if n := s.subscribers.takeDropped(sub); n > 0 {
+			slog.Warn("milestone queue full, reads dropped", "count", n)
+			c.mu.Lock()
+			c.failed += int64(n)
+			c.mu.Unlock()
+		}
+		c.mu.Lock()
+		update := e.ID <= c.lastID
+		c.mu.Unlock()
+		if update || e.Source != "camera" {
+			continue
+		}
+		err := c.deliver(ctx, e)
+		c.mu.Lock()
+		c.lastID = e.ID
+		c.lastErr = err
+		if err != nil {
+			c.failed++
+		} else {
+			c.sent++
+			c.lastSent = time.Now()
+		}
+		c.mu.Unlock()
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("milestone analytics event not delivered", "id", e.ID, "error", err)
+		}
+	}
+}
+
+// milestoneStatus is the GET /api/milestone response
+type milestoneStatus struct {
+	Address    string     `json:"address"`
+	Message    string     `json:"message"`
+	Sent       int64      `json:"sent"`   // since the server started
+	Failed     int64      `json:"failed"` // not delivered or dropped
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// HandleMilestoneAPI reports how many reads went to XProtect
+func (s *Server) HandleMilestoneAPI(w http.ResponseWriter, r *http.Request) {
+	c := s.Milestone
+	if c == nil {
+		s.jsonError(w, "milestone output is not configured (-milestone-config)", http.StatusNotFound)
+		return
+	}
+	c.mu.Lock()
+	st := milestoneStatus{Address: c.Address, Message: c.Message, Sent: c.sent, Failed: c.failed}
+	if !c.lastSent.IsZero() {
+		st.LastSentAt = ptr(c.lastSent)
+	}
+	if c.lastErr != nil {
+		st.LastError = c.lastErr.Error()
+	}
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
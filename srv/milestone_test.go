@@ -0,0 +1,125 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMilestoneAnalyticsEvent(t *testing.T) {
+	c := &MilestoneConfig{Address: "es", Sources: map[string]string{"CAM1": "Gate 1 Camera"}}
+	if err := c.compile(); err != nil || c.Address != "es:9090" || c.Message != "LPR read" {
+		t.Fatalf("compile: %v %+v", err, c)
+	}
+	at := time.Date(2026, 5, 3, 14, 11, 4, 0, time.UTC)
+	ev := c.analyticsEvent(ExportEvent{
+		ID: 7, UID: ptr("01HZX0000000000000000000AB"), Source: "camera", Plate: ptr("ABC123"),
+		PlateRegionCode: ptr("WA"), PlateConfidence: ptr(0.91), CapturedAt: &at,
+		Lane: ptr(int64(2)), Direction: ptr("leaving"),
+		Vehicle: ExportVehicle{Color: ptr("White"), Make: ptr("Toyota")},
+		Camera:  ExportCamera{Serial: ptr("CAM1"), IP: ptr("10.0.0.5")},
+	})
+	body, err := xml.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<AnalyticsEvent xmlns="urn:milestone-systems">`,
+		`<Timestamp>2026-05-03T14:11:04Z</Timestamp>`,
+		`<Message>LPR read</Message>`,
+		`<CustomTag>ABC123</CustomTag>`,
+		`<Source><Name>Gate 1 Camera</Name></Source>`,
+		`<Description>Plate ABC123 (WA) White Toyota, lane 2, leaving</Description>`,
+		`<Object><Name>LicensePlate</Name><Type>LicensePlate</Type><Value>ABC123</Value><Confidence>0.91</Confidence></Object>`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("missing %s in %s", want, body)
+		}
+	}
+	if id := ev.Header.ID; len(id) != 36 || id[14] != '5' || id != c.analyticsEvent(ExportEvent{UID: ptr("01HZX0000000000000000000AB")}).Header.ID {
+		t.Errorf("GUID %q", id)
+	}
+	// Without a configured name the camera IP identifies the device
+	if ev := c.analyticsEvent(ExportEvent{Camera: ExportCamera{Serial: ptr("CAM2"), IP: ptr("10.0.0.6")}}); ev.Header.Source != "10.0.0.6" {
+		t.Errorf("source %q", ev.Header.Source)
+	}
+}
+
+func TestRunMilestone(t *testing.T) {
+	s := newTestServer(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := io.ReadAll(conn)
+			conn.Write([]byte("OK"))
+			conn.Close()
+			received <- string(data)
+		}
+	}()
+
+	s.Milestone = &MilestoneConfig{Address: ln.Addr().String(), Filter: eventFilter{Plate: "gate*"}}
+	if err := s.Milestone.compile(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.runMilestone(ctx)
+	for !s.subscribers.active() {
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, plate := range []string{"GATE1", "OTHER", "GATE2"} {
+		res, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"`+plate+`"}`), "", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.publishEvent(ctx, res.ID)
+	}
+	s.publishEvent(ctx, 1) // an update of the first read
+	for _, want := range []string{"GATE1", "GATE2"} {
+		select {
+		case doc := <-received:
+			if !strings.HasPrefix(doc, "<?xml") || !strings.Contains(doc, "<CustomTag>"+want+"</CustomTag>") {
+				t.Errorf("got %s, want %s", doc, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s not sent", want)
+		}
+	}
+	select {
+	case doc := <-received:
+		t.Errorf("unexpected %s", doc)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The count goes up once the server has closed the connection
+	var st milestoneStatus
+	var w *httptest.ResponseRecorder
+	for range 100 {
+		w = httptest.NewRecorder()
+		s.HandleMilestoneAPI(w, httptest.NewRequest("GET", "/api/milestone", nil))
+		json.Unmarshal(w.Body.Bytes(), &st)
+		if st.Sent == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st.Sent != 2 || st.Failed != 0 || st.LastSentAt == nil {
+		t.Errorf("status: %s", w.Body)
+	}
+}
@@ -0,0 +1,505 @@
+package srv
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cameras that publish events over MQTT are read by subscribing to a broker
+// (-mqtt-config). Every message is ingested like a POST /api body: a JSON
+// payload is the event JSON, a JPEG or PNG payload an image-only event.
+// Messages are acknowledged once stored, so with QoS 1 and a persistent
+// session the broker redelivers what was in flight when the server
+// stopped; resends are caught by the dedup window. Retained messages are
+// skipped, as they would re-ingest an old event on every reconnect.
+//
+//	{"broker": "mqtts://broker:8883", "topics": ["lpr/+/events"],
+//	 "username": "mmr", "password": "...", "ca_file": "/etc/mmr/ca.pem"}
+//
+// With publish_topic set, every stored event is also published to the
+// broker (see mqttpublish.go); a config may only publish. Only the part of
+// MQTT 3.1.1 this needs is implemented: connect, subscribe, QoS 0/1
+// publishes, ping and disconnect.
+
+// MQTTConfig is the broker subscription and event publishing
+type MQTTConfig struct {
+	Broker       string   `json:"broker"`    // mqtt://, tcp://, mqtts://, ssl:// or tls://host[:port]
+	Topics       []string `json:"topics"`    // filters, with + and # wildcards
+	QoS          byte     `json:"qos"`       // 0 or 1 (default 1)
+	ClientID     string   `json:"client_id"` // default mmrapi-NODE
+	Username     string   `json:"username"`
+	Password     string   `json:"password"`
+	CleanSession bool     `json:"clean_session"` // don't keep the subscription and missed messages while disconnected
+	KeepAlive    int      `json:"keep_alive"`    // seconds (default 30)
+	CAFile       string   `json:"ca_file"`       // PEM roots for the broker certificate (default system roots)
+	CertFile     string   `json:"cert_file"`     // PEM client certificate and key for mutual TLS
+	KeyFile      string   `json:"key_file"`
+	SkipVerify   bool     `json:"insecure_skip_verify"`
+	PublishTopic string   `json:"publish_topic"` // e.g. mmrapi/events/{camera}; empty publishes nothing
+	PublishQoS   byte     `json:"publish_qos"`   // 0 or 1
+	Retain       bool     `json:"publish_retain"`
+
+	addr string
+	tls  *tls.Config
+
+	mu          sync.Mutex
+	connectedAt time.Time // zero while disconnected
+	received    int64
+	failed      int64
+	published   int64
+	packetID    uint16 // last PUBLISH packet id
+	lastErr     error
+}
+
+// LoadMQTTConfig reads an MQTT broker config file
+func LoadMQTTConfig(path string) (*MQTTConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := MQTTConfig{QoS: 1}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// compile checks the config and resolves the broker address and TLS setup
+func (c *MQTTConfig) compile() error {
+	u, err := url.Parse(c.Broker)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("broker must be a URL like mqtt://host:1883 or mqtts://host:8883")
+	}
+	port := "1883"
+	switch u.Scheme {
+	case "mqtt", "tcp":
+	case "mqtts", "ssl", "tls":
+		port = "8883"
+		c.tls = &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: c.SkipVerify}
+		if c.CAFile != "" {
+			pem, err := os.ReadFile(c.CAFile)
+			if err != nil {
+				return err
+			}
+			c.tls.RootCAs = x509.NewCertPool()
+			if !c.tls.RootCAs.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("%s: no PEM certificates", c.CAFile)
+			}
+		}
+		if c.CertFile != "" || c.KeyFile != "" {
+			cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+			if err != nil {
+				return fmt.Errorf("client certificate: %w", err)
+			}
+			c.tls.Certificates = []tls.Certificate{cert}
+		}
+	default:
+		return fmt.Errorf("unknown broker scheme %q (want mqtt, tcp, mqtts, ssl or tls)", u.Scheme)
+	}
+	c.addr = u.Host
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	if len(c.Topics) == 0 && c.PublishTopic == "" {
+		return fmt.Errorf("set topics to subscribe to, publish_topic or both")
+	}
+	for _, t := range c.Topics {
+		if t == "" || strings.Contains(strings.TrimSuffix(t, "#"), "#") {
+			return fmt.Errorf("invalid topic filter %q", t)
+		}
+	}
+	if c.QoS > 1 || c.PublishQoS > 1 {
+		return fmt.Errorf("qos and publish_qos must be 0 or 1")
+	}
+	if err := c.checkPublishTopic(); err != nil {
+		return err
+	}
+	if c.KeepAlive <= 0 {
+		c.KeepAlive = 30
+	}
+	return nil
+}
+
+// MQTT control packet types
+const (
+	mqttConnect    = 1
+	mqttConnAck    = 2
+	mqttPublish    = 3
+	mqttPubAck     = 4
+	mqttSubscribe  = 8
+	mqttSubAck     = 9
+	mqttPingReq    = 12
+	mqttPingResp   = 13
+	mqttDisconnect = 14
+)
+
+// mqttMaxPacket bounds the size of a received packet
+const mqttMaxPacket = 64 << 20
+
+// mqttPacket is one control packet
+type mqttPacket struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+func readMQTTPacket(r *bufio.Reader) (mqttPacket, error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return mqttPacket{}, err
+	}
+	n, mult := 0, 1
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return mqttPacket{}, err
+		}
+		n += int(b&0x7f) * mult
+		if b&0x80 == 0 {
+			break
+		}
+		if mult *= 128; i == 3 {
+			return mqttPacket{}, errors.New("mqtt: malformed remaining length")
+		}
+	}
+	if n > mqttMaxPacket {
+		return mqttPacket{}, fmt.Errorf("mqtt: %d byte packet exceeds %d", n, mqttMaxPacket)
+	}
+	p := mqttPacket{kind: h >> 4, flags: h & 0x0f, body: make([]byte, n)}
+	_, err = io.ReadFull(r, p.body)
+	return p, err
+}
+
+func writeMQTTPacket(w io.Writer, kind, flags byte, body []byte) error {
+	buf := []byte{kind<<4 | flags}
+	for n := len(body); ; {
+		b := byte(n % 128)
+		if n /= 128; n > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(buf, body...))
+	return err
+}
+
+// mqttString appends a length-prefixed UTF-8 string
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttPublishPacket is a received PUBLISH
+type mqttPublishPacket struct {
+	topic    string
+	id       uint16 // 0 for QoS 0
+	retained bool
+	payload  []byte
+}
+
+func parseMQTTPublish(p mqttPacket) (mqttPublishPacket, error) {
+	if len(p.body) < 2 {
+		return mqttPublishPacket{}, errors.New("mqtt: short publish")
+	}
+	n := int(binary.BigEndian.Uint16(p.body))
+	rest := p.body[2:]
+	if len(rest) < n {
+		return mqttPublishPacket{}, errors.New("mqtt: short publish topic")
+	}
+	m := mqttPublishPacket{topic: string(rest[:n]), retained: p.flags&1 != 0}
+	rest = rest[n:]
+	if p.flags&0x06 != 0 {
+		if len(rest) < 2 {
+			return m, errors.New("mqtt: publish without packet id")
+		}
+		m.id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	m.payload = rest
+	return m, nil
+}
+
+// mqttConnAckErrors are the CONNACK refusal reasons
+var mqttConnAckErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// runMQTT stays subscribed to the broker until ctx is done, reconnecting
+// with backoff
+func (s *Server) runMQTT(ctx context.Context) {
+	c := s.MQTT
+	if c.ClientID == "" {
+		// Stable, so a persistent session survives restarts
+		c.ClientID = "mmrapi-" + s.NodeID
+	}
+	slog.Info("mqtt enabled", "broker", c.Broker, "topics", c.Topics, "publish_topic", c.PublishTopic, "client_id", c.ClientID)
+	// Subscribed for good, so events stored during a reconnect are queued
+	var sub *subscriber
+	if c.PublishTopic != "" {
+		sub = s.subscribers.subscribe(eventFilter{})
+		defer s.subscribers.unsubscribe(sub)
+	}
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := s.mqttSession(ctx, sub)
+		c.mu.Lock()
+		c.connectedAt = time.Time{}
+		if ctx.Err() == nil {
+			c.lastErr = err
+		}
+		c.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		slog.Warn("mqtt connection lost", "broker", c.Broker, "error", err, "retry", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// mqttSession connects, subscribes and ingests messages, and publishes the
+// events sent to sub, until the connection fails or ctx is done
+func (s *Server) mqttSession(ctx context.Context, sub *subscriber) error {
+	c := s.MQTT
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if c.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: c.tls}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var wmu sync.Mutex
+	write := func(kind, flags byte, body []byte) error {
+		wmu.Lock()
+		defer wmu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return writeMQTTPacket(conn, kind, flags, body)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		write(mqttDisconnect, 0, nil)
+		conn.Close()
+	})
+	defer stop()
+	r := bufio.NewReader(conn)
+	keepAlive := time.Duration(c.KeepAlive) * time.Second
+
+	// CONNECT
+	body := mqttString(nil, "MQTT")
+	var flags byte
+	if c.CleanSession {
+		flags |= 0x02
+	}
+	if c.Username != "" {
+		flags |= 0x80
+		if c.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(c.KeepAlive))
+	body = mqttString(body, c.ClientID)
+	if c.Username != "" {
+		body = mqttString(body, c.Username)
+		if c.Password != "" {
+			body = mqttString(body, c.Password)
+		}
+	}
+	if err := write(mqttConnect, 0, body); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	ack, err := readMQTTPacket(r)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	if ack.kind != mqttConnAck || len(ack.body) < 2 {
+		return fmt.Errorf("connect: unexpected packet type %d", ack.kind)
+	}
+	if code := ack.body[1]; code != 0 {
+		if msg, ok := mqttConnAckErrors[code]; ok {
+			return fmt.Errorf("connect refused: %s", msg)
+		}
+		return fmt.Errorf("connect refused: code %d", code)
+	}
+	c.mu.Lock()
+	c.connectedAt = time.Now()
+	c.lastErr = nil
+	c.mu.Unlock()
+
+	// SUBSCRIBE, packet id 1
+	if len(c.Topics) > 0 {
+		body = binary.BigEndian.AppendUint16(nil, 1)
+		for _, t := range c.Topics {
+			body = append(mqttString(body, t), c.QoS)
+		}
+		if err := write(mqttSubscribe, 0x02, body); err != nil {
+			return err
+		}
+	}
+
+	// Ping when idle so the broker and we notice a dead connection
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(keepAlive / 2):
+				if write(mqttPingReq, 0, nil) != nil {
+					return
+				}
+			}
+		}
+	}()
+	if sub != nil {
+		go s.mqttPublishEvents(sub, write, conn, done)
+	}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(keepAlive * 3 / 2))
+		p, err := readMQTTPacket(r)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		switch p.kind {
+		case mqttSubAck:
+			for _, code := range p.body[min(2, len(p.body)):] {
+				if code == 0x80 {
+					return errors.New("subscribe refused")
+				}
+			}
+			slog.Info("mqtt subscribed", "broker", c.Broker, "topics", c.Topics)
+		case mqttPublish:
+			m, err := parseMQTTPublish(p)
+			if err != nil {
+				return err
+			}
+			if err := s.mqttIngest(ctx, m); err != nil {
+				// Not acknowledged: the broker redelivers after reconnecting
+				return fmt.Errorf("ingest %s: %w", m.topic, err)
+			}
+			if m.id != 0 {
+				if err := write(mqttPubAck, 0, binary.BigEndian.AppendUint16(nil, m.id)); err != nil {
+					return err
+				}
+			}
+		case mqttPingResp, mqttPubAck:
+		default:
+			return fmt.Errorf("unexpected packet type %d", p.kind)
+		}
+	}
+}
+
+// mqttIngest stores one message; only server failures are returned, so
+// malformed messages are acknowledged and dropped
+func (s *Server) mqttIngest(ctx context.Context, m mqttPublishPacket) error {
+	c := s.MQTT
+	if m.retained {
+		return nil
+	}
+	var req ingestRequest
+	if ext := imageExtension(m.payload); ext != "" {
+		req = newIngestRequest(nil, "", []uploadedImage{{Filename: "trigger" + ext, Data: m.payload}})
+	} else {
+		req = newIngestRequest(m.payload, "", nil)
+	}
+	res, err := s.ingest(ctx, req)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.failed++
+		var pe *payloadError
+		if errors.As(err, &pe) {
+			slog.Warn("mqtt message rejected", "topic", m.topic, "error", pe.msg)
+			return nil
+		}
+		return err
+	}
+	c.received++
+	slog.Debug("mqtt message stored", "topic", m.topic, "id", res.ID)
+	return nil
+}
+
+// mqttStatus is the GET /api/mqtt response
+type mqttStatus struct {
+	Broker      string     `json:"broker"`
+	Topics      []string   `json:"topics"`
+	PublishTo   string     `json:"publish_topic,omitempty"`
+	Connected   bool       `json:"connected"`
+	ConnectedAt *time.Time `json:"connected_at,omitempty"`
+	Received    int64      `json:"received"`
+	Failed      int64      `json:"failed"`
+	Published   int64      `json:"published"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// HandleMQTTAPI reports the broker connection and message counts
+func (s *Server) HandleMQTTAPI(w http.ResponseWriter, r *http.Request) {
+	c := s.MQTT
+	if c == nil {
+		s.jsonError(w, "no MQTT broker is configured (-mqtt-config)", http.StatusNotFound)
+		return
+	}
+	c.mu.Lock()
+	st := mqttStatus{
+		Broker:    c.Broker,
+		Topics:    c.Topics,
+		PublishTo: c.PublishTopic,
+		Connected: !c.connectedAt.IsZero(),
+		Received:  c.received,
+		Failed:    c.failed,
+		Published: c.published,
+	}
+	if st.Connected {
+		st.ConnectedAt = ptr(c.connectedAt)
+	}
+	if c.lastErr != nil {
+		st.LastError = c.lastErr.Error()
+	}
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,196 @@
+package srv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMQTTIngest(t *testing.T) {
+	s := newTestServer(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	cfg := &MQTTConfig{Broker: "mqtt://" + ln.Addr().String(), Topics: []string{"lpr/+/events"}, QoS: 1, Username: "cam", Password: "secret"}
+	if err := cfg.compile(); err != nil {
+		t.Fatal(err)
+	}
+	s.NodeID = "site1"
+	s.MQTT = cfg
+
+	// A broker that checks the login and subscription, then publishes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.runMQTT(ctx)
+	}()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+	read := func(kind byte) mqttPacket {
+		t.Helper()
+		p, err := readMQTTPacket(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.kind != kind {
+			t.Fatalf("packet type %d, want %d", p.kind, kind)
+		}
+		return p
+	}
+	connect := read(mqttConnect)
+	for _, want := range []string{"MQTT", "mmrapi-site1", "cam", "secret"} {
+		if !bytes.Contains(connect.body, []byte(want)) {
+			t.Errorf("connect packet lacks %q", want)
+		}
+	}
+	writeMQTTPacket(conn, mqttConnAck, 0, []byte{0, 0})
+	sub := read(mqttSubscribe)
+	if !bytes.Contains(sub.body, append(mqttString(nil, "lpr/+/events"), 1)) {
+		t.Errorf("subscribe packet %q", sub.body)
+	}
+	writeMQTTPacket(conn, mqttSubAck, 0, append(sub.body[:2:2], 1))
+
+	publish := func(id uint16, retain bool, payload string) {
+		body := binary.BigEndian.AppendUint16(mqttString(nil, "lpr/cam1/events"), id)
+		flags := byte(0x02)
+		if retain {
+			flags |= 1
+		}
+		writeMQTTPacket(conn, mqttPublish, flags, append(body, payload...))
+	}
+	publish(1, true, `{"plateUTF8":"OLD1"}`)
+	publish(2, false, `{"plateUTF8":"MQ123","sensorProviderID":"cam1"}`)
+	publish(3, false, `not json`)
+	for id := uint16(1); id <= 3; id++ {
+		if ack := read(mqttPubAck); binary.BigEndian.Uint16(ack.body) != id {
+			t.Errorf("puback %d, want %d", binary.BigEndian.Uint16(ack.body), id)
+		}
+	}
+
+	var n int
+	var plate string
+	s.DB.QueryRow("SELECT COUNT(*), MAX(plate_utf8) FROM events").Scan(&n, &plate)
+	if n != 1 || plate != "MQ123" {
+		t.Errorf("stored %d events (%s), want only MQ123", n, plate)
+	}
+	w := httptest.NewRecorder()
+	s.HandleMQTTAPI(w, httptest.NewRequest("GET", "/api/mqtt", nil))
+	var st mqttStatus
+	json.Unmarshal(w.Body.Bytes(), &st)
+	if !st.Connected || st.Received != 1 || st.Failed != 1 {
+		t.Errorf("status %s", w.Body)
+	}
+
+	cancel()
+	read(mqttDisconnect)
+	<-done
+}
+
+func TestMQTTConfig(t *testing.T) {
+	for _, tt := range []struct {
+		cfg  *MQTTConfig
+		addr string
+		ok   bool
+	}{
+		{&MQTTConfig{Broker: "mqtt://broker", Topics: []string{"lpr/#"}}, "broker:1883", true},
+		{&MQTTConfig{Broker: "mqtts://broker", Topics: []string{"a/+/b"}}, "broker:8883", true},
+		{&MQTTConfig{Broker: "tcp://10.0.0.5:1884", Topics: []string{"x"}}, "10.0.0.5:1884", true},
+		{&MQTTConfig{Broker: "http://broker", Topics: []string{"x"}}, "", false},
+		{&MQTTConfig{Broker: "mqtt://broker"}, "", false},
+		{&MQTTConfig{Broker: "mqtt://broker", Topics: []string{"a/#/b"}}, "", false},
+		{&MQTTConfig{Broker: "mqtt://broker", Topics: []string{"x"}, QoS: 2}, "", false},
+		{&MQTTConfig{Broker: "mqtt://broker", PublishTopic: "mmrapi/events/{camera}"}, "broker:1883", true},
+		{&MQTTConfig{Broker: "mqtt://broker", PublishTopic: "mmrapi/#"}, "", false},
+		{&MQTTConfig{Broker: "mqtt://broker", Topics: []string{"mmrapi/+/+"}, PublishTopic: "mmrapi/events/{camera}"}, "", false},
+		{&MQTTConfig{Broker: "mqtt://broker", Topics: []string{"lpr/#"}, PublishTopic: "mmrapi/events/{camera}"}, "broker:1883", true},
+	} {
+		err := tt.cfg.compile()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s %v: error %v", tt.cfg.Broker, tt.cfg.Topics, err)
+		}
+		if err == nil && tt.cfg.addr != tt.addr {
+			t.Errorf("%s: address %s, want %s", tt.cfg.Broker, tt.cfg.addr, tt.addr)
+		}
+	}
+}
+
+func TestMQTTPublish(t *testing.T) {
+	s := newTestServer(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	cfg := &MQTTConfig{Broker: "mqtt://" + ln.Addr().String(), PublishTopic: "mmrapi/events/{camera}", PublishQoS: 1, ClientID: "pub"}
+	if err := cfg.compile(); err != nil {
+		t.Fatal(err)
+	}
+	s.MQTT = cfg
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.runMQTT(ctx)
+	}()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+	if p, err := readMQTTPacket(r); err != nil || p.kind != mqttConnect {
+		t.Fatalf("connect: %v %v", p.kind, err)
+	}
+	writeMQTTPacket(conn, mqttConnAck, 0, []byte{0, 0})
+
+	for _, body := range []string{`{"plateUTF8":"PUB1","camera_info":{"SerialNumber":"CAM/9"}}`, `{"plateUTF8":"PUB2"}`} {
+		if _, err := s.ingest(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, want := range []struct{ topic, plate string }{{"mmrapi/events/CAM_9", "PUB1"}, {"mmrapi/events/unknown", "PUB2"}} {
+		p, err := readMQTTPacket(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.kind != mqttPublish || p.flags != 0x02 {
+			t.Fatalf("packet type %d flags %x, want a QoS 1 publish", p.kind, p.flags)
+		}
+		m, err := parseMQTTPublish(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e ExportEvent
+		json.Unmarshal(m.payload, &e)
+		if m.topic != want.topic || deref(e.Plate) != want.plate || m.id != uint16(i+2) {
+			t.Errorf("published %s #%d %s, want %s %s", m.topic, m.id, m.payload, want.topic, want.plate)
+		}
+		writeMQTTPacket(conn, mqttPubAck, 0, binary.BigEndian.AppendUint16(nil, m.id))
+	}
+
+	cancel()
+	<-done
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	if cfg.published != 2 {
+		t.Errorf("published %d, want 2", cfg.published)
+	}
+}
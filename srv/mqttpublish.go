@@ -0,0 +1,111 @@
+package srv
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+)
+
+// With publish_topic set, every stored event (new or updated) is published
+// to the broker in the /api/events/stream format, so gates and automation
+// react without polling. {camera} in the topic is the camera serial, else
+// the sensor provider ID or IP; {node} the node ID:
+//
+//	{"broker": "mqtt://broker", "publish_topic": "mmrapi/events/{camera}", "publish_qos": 1}
+//
+// Events stored while the broker is unreachable are queued up to the
+// stream subscriber buffer and dropped beyond it.
+
+// mqttTopicLevel makes a value safe to use as one topic level
+func mqttTopicLevel(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(v)
+}
+
+// eventTopic is the topic an event is published to
+func (c *MQTTConfig) eventTopic(e ExportEvent) string {
+	camera := coalesce(deref(e.Camera.Serial), deref(e.SensorProviderID), deref(e.Camera.IP))
+	return strings.NewReplacer(
+		"{camera}", mqttTopicLevel(camera),
+		"{node}", mqttTopicLevel(deref(e.NodeID)),
+	).Replace(c.PublishTopic)
+}
+
+// checkPublishTopic rejects wildcards, and topics we subscribe to, which
+// would ingest every published event again
+func (c *MQTTConfig) checkPublishTopic() error {
+	if c.PublishTopic == "" {
+		return nil
+	}
+	if strings.ContainsAny(c.PublishTopic, "+#") {
+		return fmt.Errorf("publish_topic must not contain wildcards")
+	}
+	sample := c.eventTopic(ExportEvent{Camera: ExportCamera{Serial: ptr("CAM")}, NodeID: ptr("NODE")})
+	for _, f := range c.Topics {
+		if mqttTopicMatch(f, sample) {
+			return fmt.Errorf("publish_topic %q is matched by subscribed topic %q, so published events would be ingested again", c.PublishTopic, f)
+		}
+	}
+	return nil
+}
+
+// mqttTopicMatch reports whether a topic filter matches a topic
+func mqttTopicMatch(filter, topic string) bool {
+	fl, tl := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, f := range fl {
+		if f == "#" {
+			return true
+		}
+		if i >= len(tl) || (f != "+" && f != tl[i]) {
+			return false
+		}
+	}
+	return len(fl) == len(tl)
+}
+
+// mqttPublishEvents publishes the events sent to sub until done is closed
+// or a write fails, which closes conn to end the session
+func (s *Server) mqttPublishEvents(sub *subscriber, write func(kind, flags byte, body []byte) error, conn net.Conn, done <-chan struct{}) {
+	c := s.MQTT
+	for {
+		var e ExportEvent
+		select {
+		case <-done:
+			return
+		case e = <-sub.events:
+		}
+		if n := s.subscribers.takeDropped(sub); n > 0 {
+			slog.Warn("mqtt publish queue full, events dropped", "count", n)
+		}
+		payload, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		body := mqttString(nil, c.eventTopic(e))
+		flags := c.PublishQoS << 1
+		if c.Retain {
+			flags |= 1
+		}
+		if c.PublishQoS > 0 {
+			c.mu.Lock()
+			if c.packetID++; c.packetID < 2 {
+				c.packetID = 2 // 1 is the subscription's
+			}
+			body = binary.BigEndian.AppendUint16(body, c.packetID)
+			c.mu.Unlock()
+		}
+		if err := write(mqttPublish, flags, append(body, payload...)); err != nil {
+			slog.Warn("mqtt publish failed", "id", e.ID, "error", err)
+			conn.Close()
+			return
+		}
+		c.mu.Lock()
+		c.published++
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,142 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// OCRProvider reads a plate from an image. It is used as a fallback for
+// events the camera could not recognize.
+type OCRProvider interface {
+	Recognize(ctx context.Context, image []byte) (plate string, confidence float64, err error)
+}
+
+// HTTPOCR posts the raw image to an external OCR service and expects a JSON
+// response of the form {"plate": "ABC123", "confidence": 0.92}
+type HTTPOCR struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+func NewHTTPOCR(endpoint string) *HTTPOCR {
+	return &HTTPOCR{
+		Endpoint: endpoint,
+		Client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (o *HTTPOCR) Recognize(ctx context.Context, image []byte) (string, float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.Endpoint, bytes.NewReader(image))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", http.DetectContentType(image))
+
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", 0, fmt.Errorf("ocr service returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Plate      string  `json:"plate"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, fmt.Errorf("decode ocr response: %w", err)
+	}
+	return strings.ToUpper(strings.TrimSpace(result.Plate)), result.Confidence, nil
+}
+
+// runOCR runs the stored images of an event through the OCR provider, best
+// image type first, and stores the first candidate plate as a suggestion
+func (s *Server) runOCR(ctx context.Context, eventID int64) error {
+	if s.OCR == nil {
+		return fmt.Errorf("ocr is not configured")
+	}
+
+	q := dbgen.New(s.DB)
+	images, err := q.GetImagesForOCR(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("load images: %w", err)
+	}
+	for _, img := range images {
+		data, err := s.imageBytes(ctx, img.ImageData, img.DiskFilename)
+		if err != nil {
+			slog.Warn("ocr image unavailable", "event_id", eventID, "image_id", img.ID, "error", err)
+			continue
+		}
+		plate, conf, err := s.OCR.Recognize(ctx, data)
+		if err != nil {
+			slog.Warn("ocr failed", "event_id", eventID, "image_id", img.ID, "error", err)
+			continue
+		}
+		if plate == "" {
+			continue
+		}
+		if err := q.SetOCRSuggestion(ctx, dbgen.SetOCRSuggestionParams{
+			OcrPlate:      &plate,
+			OcrConfidence: &conf,
+			ID:            eventID,
+		}); err != nil {
+			return fmt.Errorf("save suggestion: %w", err)
+		}
+		slog.Info("ocr suggestion stored", "event_id", eventID, "plate", plate, "confidence", conf)
+		return nil
+	}
+	return nil
+}
+
+// queueOCR runs OCR for an unrecognized event in the background
+func (s *Server) queueOCR(eventID int64) {
+	if s.OCR == nil {
+		return
+	}
+	s.background.Go(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := s.runOCR(ctx, eventID); err != nil {
+			slog.Warn("ocr fallback failed", "event_id", eventID, "error", err)
+		}
+	})
+}
+
+// HandleRunOCR re-runs the OCR fallback for a single event
+func (s *Server) HandleRunOCR(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid event id", http.StatusBadRequest)
+		return
+	}
+	if s.OCR == nil {
+		http.Error(w, "ocr is not configured", http.StatusNotFound)
+		return
+	}
+
+	if err := s.runOCR(r.Context(), id); err != nil {
+		slog.Warn("ocr fallback failed", "event_id", id, "error", err)
+		http.Error(w, "ocr failed", http.StatusBadGateway)
+		return
+	}
+
+	referer := r.Header.Get("Referer")
+	if referer == "" {
+		referer = "/unrecognized"
+	}
+	http.Redirect(w, r, referer, http.StatusSeeOther)
+}
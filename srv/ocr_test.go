@@ -0,0 +1,76 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOCRFallback(t *testing.T) {
+	s := newTestServer(t)
+	var plate atomic.Value
+	plate.Store(" ab 12 ")
+	ocr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "image/png" {
+			http.Error(w, "not an image", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"plate": plate.Load(), "confidence": 0.9})
+	}))
+	defer ocr.Close()
+
+	rerun := func(id string) int {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/event/"+id+"/ocr", nil)
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		s.HandleRunOCR(w, r)
+		return w.Code
+	}
+	if code := rerun("1"); code != http.StatusNotFound {
+		t.Errorf("without ocr: %d", code)
+	}
+
+	s.OCR = NewHTTPOCR(ocr.URL)
+	ctx := context.Background()
+	if _, err := s.ingestEvent(ctx, newIngestRequest(nil, "", []uploadedImage{{Filename: "trigger.png", Data: pngOf(300, 200)}})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"CD34"}`), "", []uploadedImage{{Filename: "vehicle.png", Data: pngOf(300, 200)}})); err != nil {
+		t.Fatal(err)
+	}
+	s.background.Wait()
+
+	suggestion := func(id int) (plate *string, conf *float64) {
+		t.Helper()
+		s.DB.QueryRow("SELECT ocr_plate, ocr_confidence FROM events WHERE id = ?", id).Scan(&plate, &conf)
+		return plate, conf
+	}
+	// Only the no-read goes to the OCR service
+	if p, c := suggestion(1); deref(p) != "AB 12" || c == nil || *c != 0.9 {
+		t.Errorf("suggestion %v %v", p, c)
+	}
+	if p, _ := suggestion(2); p != nil {
+		t.Errorf("recognized event got suggestion %q", *p)
+	}
+	w := httptest.NewRecorder()
+	s.HandleUnrecognized(w, httptest.NewRequest("GET", "/unrecognized", nil))
+	if !strings.Contains(w.Body.String(), "AB 12") {
+		t.Error("suggestion not shown to the reviewer")
+	}
+
+	plate.Store("AB 13")
+	if code := rerun("1"); code != http.StatusSeeOther {
+		t.Fatalf("rerun: %d", code)
+	}
+	if p, _ := suggestion(1); deref(p) != "AB 13" {
+		t.Errorf("rerun suggestion %v", p)
+	}
+	if code := rerun("x"); code != http.StatusBadRequest {
+		t.Errorf("invalid id: %d", code)
+	}
+}
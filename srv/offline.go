@@ -0,0 +1,136 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// With -camera-offline-after a camera that sent reads in the last week and
+// then none for that long is reported offline: a warning is logged and
+// subscribers of the "camera" notifications get one, and another when it
+// sends again. With a site calendar only working time counts, so a camera
+// at a gate closed overnight isn't offline by morning, and one silent since
+// Friday evening is reported after that long into Monday's hours.
+//
+//	GET /api/cameras/status
+
+const (
+	offlineLookback   = 7 * 24 * time.Hour // cameras silent longer are no longer watched
+	offlineCheckEvery = time.Minute
+)
+
+// cameraWatch is the result of the last offline check
+type cameraWatch struct {
+	mu        sync.Mutex
+	offline   map[string]time.Time // camera -> when it was reported offline
+	cameras   []cameraStatus
+	checkedAt time.Time
+}
+
+// cameraStatus is a watched camera in /api/cameras/status
+type cameraStatus struct {
+	Camera       string     `json:"camera"`
+	LastRead     time.Time  `json:"last_read"`
+	SilentFor    string     `json:"silent_for"` // working time since the last read
+	Offline      bool       `json:"offline"`
+	OfflineSince *time.Time `json:"offline_since,omitempty"`
+}
+
+// runCameraWatch checks for silent cameras until ctx is done
+func (s *Server) runCameraWatch(ctx context.Context) {
+	for {
+		if err := s.checkCameras(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			slog.Warn("camera offline check failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(offlineCheckEvery):
+		}
+	}
+}
+
+// checkCameras reports cameras that went silent or came back since the
+// last check
+func (s *Server) checkCameras(ctx context.Context, now time.Time) error {
+	reads, err := dbgen.New(s.DB).GetCameraLastReads(ctx, now.Add(-offlineLookback))
+	if err != nil {
+		return err
+	}
+	w := &s.cameraWatch
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.offline == nil {
+		w.offline = make(map[string]time.Time)
+	}
+	seen := make(map[string]bool, len(reads))
+	w.cameras = make([]cameraStatus, 0, len(reads))
+	for _, r := range reads {
+		seen[r.Camera] = true
+		silent := s.Calendar.openFor(r.CreatedAt, now)
+		st := cameraStatus{Camera: r.Camera, LastRead: r.CreatedAt, SilentFor: silent.Round(time.Second).String(), Offline: silent >= s.OfflineAfter}
+		since, reported := w.offline[r.Camera]
+		switch {
+		case st.Offline && !reported:
+			since = now
+			w.offline[r.Camera] = now
+			slog.Warn("camera offline", "camera", r.Camera, "last_read", r.CreatedAt, "silent_for", st.SilentFor)
+			s.notifyCamera(r.Camera, "Camera offline", fmt.Sprintf("No reads from %s since %s", r.Camera, r.CreatedAt.Local().Format("Jan 2 15:04")))
+		case !st.Offline && reported:
+			delete(w.offline, r.Camera)
+			slog.Info("camera back online", "camera", r.Camera, "offline_for", now.Sub(since).Round(time.Second))
+			s.notifyCamera(r.Camera, "Camera back online", fmt.Sprintf("%s is sending reads again", r.Camera))
+		}
+		if st.Offline {
+			st.OfflineSince = &since
+		}
+		w.cameras = append(w.cameras, st)
+	}
+	// Cameras past the lookback are forgotten
+	for camera := range w.offline {
+		if !seen[camera] {
+			delete(w.offline, camera)
+		}
+	}
+	w.checkedAt = now
+	return nil
+}
+
+// notifyCamera tells subscribers of camera notifications about a camera
+func (s *Server) notifyCamera(camera, title, body string) {
+	s.notify(pushCamera, "", pushMessage{
+		Title: title,
+		Body:  body,
+		URL:   "/search?q=" + url.QueryEscape("camera:"+camera),
+		Tag:   "camera-" + camera,
+	})
+}
+
+// HandleCameraStatusAPI returns the cameras watched by the last offline
+// check and whether the site is working
+func (s *Server) HandleCameraStatusAPI(w http.ResponseWriter, r *http.Request) {
+	if s.OfflineAfter <= 0 {
+		s.jsonError(w, "camera offline alerting is off: start the server with -camera-offline-after", http.StatusNotFound)
+		return
+	}
+	now := time.Now()
+	s.cameraWatch.mu.Lock()
+	out := map[string]any{
+		"offline_after": s.OfflineAfter.String(),
+		"open":          s.Calendar.open(now),
+		"holiday":       s.Calendar.holiday(now),
+		"checked_at":    s.cameraWatch.checkedAt,
+		"cameras":       append([]cameraStatus{}, s.cameraWatch.cameras...),
+	}
+	s.cameraWatch.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,75 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCameraOffline(t *testing.T) {
+	s := newTestServer(t)
+	s.OfflineAfter = 30 * time.Minute
+	s.Calendar = loadTestCalendar(t)
+	ctx := context.Background()
+	at := func(v string) time.Time {
+		d, _ := time.ParseInLocation("2006-01-02 15:04", v, time.Local)
+		return d
+	}
+	read := func(camera, receivedAt string) {
+		t.Helper()
+		res, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"OF1","sensorProviderID":"`+camera+`"}`), "", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.DB.Exec("UPDATE events SET created_at = ? WHERE id = ?", at(receivedAt), res.ID)
+	}
+	offline := func(now string) map[string]bool {
+		t.Helper()
+		if err := s.checkCameras(ctx, at(now)); err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]bool{}
+		for _, c := range s.cameraWatch.cameras {
+			out[c.Camera] = c.Offline
+		}
+		return out
+	}
+
+	read("gate", "2026-03-29 05:50") // Sunday before the night shift ends
+	read("yard", "2026-03-30 07:10") // Monday
+	read("old", "2026-03-01 10:00")  // past the lookback
+	if got := offline("2026-03-30 07:15"); len(got) != 2 || got["gate"] || got["yard"] {
+		t.Errorf("Monday 07:15: %v", got)
+	}
+	// 10 minutes on Sunday and 25 on Monday
+	if got := offline("2026-03-30 07:25"); !got["gate"] || got["yard"] {
+		t.Errorf("Monday 07:25: %v", got)
+	}
+	read("gate", "2026-03-30 07:30")
+	if got := offline("2026-03-30 07:31"); got["gate"] || len(s.cameraWatch.offline) != 0 {
+		t.Errorf("after a new read: %v", got)
+	}
+
+	// Without a calendar the weekend counts
+	s.Calendar = nil
+	if got := offline("2026-03-30 07:55"); got["gate"] || !got["yard"] {
+		t.Errorf("without calendar: %v", got)
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleCameraStatusAPI(w, httptest.NewRequest("GET", "/api/cameras/status", nil))
+	var status struct {
+		OfflineAfter string         `json:"offline_after"`
+		Open         bool           `json:"open"`
+		Cameras      []cameraStatus `json:"cameras"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.OfflineAfter != "30m0s" || !status.Open || len(status.Cameras) != 2 || status.Cameras[1].Camera != "yard" ||
+		status.Cameras[1].OfflineSince == nil || status.Cameras[1].SilentFor != "45m0s" {
+		t.Errorf("status %s", w.Body)
+	}
+}
@@ -0,0 +1,341 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db"
+)
+
+// Custom panels are saved SQL queries defined by an admin in a JSON file and
+// shown on /panels, so site-specific questions don't need code changes:
+//
+//	{"panels": [{
+//	  "name": "per_camera", "title": "Events per camera (last day)",
+//	  "query": "SELECT sensor_provider_id AS camera, COUNT(*) AS n FROM events WHERE created_at >= :since GROUP BY 1 ORDER BY 2 DESC",
+//	  "params": [{"name": "since", "type": "since", "default": "24h"}],
+//	  "chart": "bar", "label": "camera", "value": "n"
+//	}]}
+//
+// Queries run on a separate query_only connection, so they can't modify data.
+
+// Panel chart types
+const (
+	PanelTable = "table"
+	PanelBar   = "bar"
+	PanelLine  = "line"
+)
+
+// Panel parameter types
+const (
+	ParamText  = "text"  // passed as is
+	ParamInt   = "int"   // parsed as an integer
+	ParamSince = "since" // a duration like 24h, passed as the time that long ago
+)
+
+// panelMaxRows caps the rows returned when a panel doesn't set a limit
+const panelMaxRows = 1000
+
+// panelTimeout bounds how long a panel query may run
+const panelTimeout = 10 * time.Second
+
+// errBadParam marks a parameter value that can't be converted
+var errBadParam = errors.New("invalid value")
+
+var panelNameRe = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// PanelParam is a named query parameter (:name in the SQL) filled from the
+// URL query string
+type PanelParam struct {
+	Name    string `json:"name"`
+	Label   string `json:"label,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Default string `json:"default,omitempty"`
+}
+
+// Panel is one saved query and how to render it
+type Panel struct {
+	Name        string       `json:"name"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	Query       string       `json:"query"`
+	Params      []PanelParam `json:"params,omitempty"`
+	Chart       string       `json:"chart,omitempty"` // table (default), bar or line
+	Label       string       `json:"label,omitempty"` // column used for bar labels / x axis
+	Value       string       `json:"value,omitempty"` // numeric column used for bar length / y axis
+	Limit       int          `json:"limit,omitempty"`
+}
+
+// PanelConfig holds the custom dashboard panels
+type PanelConfig struct {
+	Panels []*Panel `json:"panels"`
+
+	db *sql.DB
+}
+
+// PanelResult is the outcome of running a panel
+type PanelResult struct {
+	Name      string   `json:"name"`
+	Title     string   `json:"title"`
+	Chart     string   `json:"chart"`
+	Label     string   `json:"label,omitempty"`
+	Value     string   `json:"value,omitempty"`
+	Columns   []string `json:"columns"`
+	Rows      [][]any  `json:"rows"`
+	Truncated bool     `json:"truncated"`
+	ElapsedMS int64    `json:"elapsed_ms"`
+}
+
+// LoadPanelConfig reads a panel configuration file
+func LoadPanelConfig(path string) (*PanelConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg PanelConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	seen := make(map[string]bool)
+	for i, p := range cfg.Panels {
+		if !panelNameRe.MatchString(p.Name) {
+			return nil, fmt.Errorf("panel %d: name must match %s", i, panelNameRe)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("panel %s: duplicate name", p.Name)
+		}
+		seen[p.Name] = true
+		if p.Title == "" {
+			p.Title = p.Name
+		}
+		if !isReadQuery(p.Query) {
+			return nil, fmt.Errorf("panel %s: query must be a single SELECT or WITH statement", p.Name)
+		}
+		switch p.Chart {
+		case "":
+			p.Chart = PanelTable
+		case PanelTable:
+		case PanelBar, PanelLine:
+			if p.Label == "" || p.Value == "" {
+				return nil, fmt.Errorf("panel %s: %s chart needs label and value columns", p.Name, p.Chart)
+			}
+		default:
+			return nil, fmt.Errorf("panel %s: unknown chart %q (want table, bar or line)", p.Name, p.Chart)
+		}
+		if p.Limit <= 0 {
+			p.Limit = panelMaxRows
+		}
+		for j := range p.Params {
+			param := &p.Params[j]
+			if param.Type == "" {
+				param.Type = ParamText
+			}
+			if _, err := param.value(param.Default); err != nil && param.Default != "" {
+				return nil, fmt.Errorf("panel %s: param %s: default: %w", p.Name, param.Name, err)
+			}
+		}
+	}
+	return &cfg, nil
+}
+
+// isReadQuery reports whether q is a single SELECT/WITH statement
+func isReadQuery(q string) bool {
+	q = strings.TrimSpace(q)
+	q = strings.TrimSuffix(q, ";")
+	if strings.Contains(q, ";") {
+		return false
+	}
+	words := strings.Fields(strings.ToUpper(q))
+	return len(words) > 0 && (words[0] == "SELECT" || words[0] == "WITH")
+}
+
+// Open connects the panels to the database read-only and checks that every
+// query compiles
+func (c *PanelConfig) Open(dbPath string) error {
+	rdb, err := db.OpenReadOnly(dbPath)
+	if err != nil {
+		return err
+	}
+	for _, p := range c.Panels {
+		// EXPLAIN compiles the statement without running it
+		var args []any
+		for _, param := range p.Params {
+			args = append(args, sql.Named(param.Name, nil))
+		}
+		rows, err := rdb.Query("EXPLAIN "+p.Query, args...)
+		if err != nil {
+			rdb.Close()
+			return fmt.Errorf("panel %s: %w", p.Name, err)
+		}
+		rows.Close()
+	}
+	c.db = rdb
+	return nil
+}
+
+func (c *PanelConfig) panel(name string) *Panel {
+	if c == nil {
+		return nil
+	}
+	for _, p := range c.Panels {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// value converts a raw parameter value to its SQL argument
+func (p PanelParam) value(raw string) (any, error) {
+	switch p.Type {
+	case ParamInt:
+		return strconv.ParseInt(raw, 10, 64)
+	case ParamSince:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, err
+		}
+		return time.Now().Add(-d).In(time.Local), nil
+	default:
+		return raw, nil
+	}
+}
+
+// run executes a panel with parameters taken from the query string
+func (c *PanelConfig) run(ctx context.Context, p *Panel, query map[string][]string) (*PanelResult, error) {
+	var args []any
+	for _, param := range p.Params {
+		raw := param.Default
+		if v, ok := query[param.Name]; ok && len(v) > 0 && v[0] != "" {
+			raw = v[0]
+		}
+		v, err := param.value(raw)
+		if err != nil {
+			return nil, fmt.Errorf("param %s: %w", param.Name, errBadParam)
+		}
+		args = append(args, sql.Named(param.Name, v))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, panelTimeout)
+	defer cancel()
+	start := time.Now()
+	rows, err := c.db.QueryContext(ctx, p.Query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &PanelResult{
+		Name:    p.Name,
+		Title:   p.Title,
+		Chart:   p.Chart,
+		Label:   p.Label,
+		Value:   p.Value,
+		Columns: cols,
+		Rows:    [][]any{},
+	}
+	for rows.Next() {
+		if len(res.Rows) == p.Limit {
+			res.Truncated = true
+			break
+		}
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range vals {
+			if b, ok := v.([]byte); ok {
+				vals[i] = string(b)
+			}
+		}
+		res.Rows = append(res.Rows, vals)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	res.ElapsedMS = time.Since(start).Milliseconds()
+	return res, nil
+}
+
+// HandlePanels shows the custom panels page
+func (s *Server) HandlePanels(w http.ResponseWriter, r *http.Request) {
+	var panels []*Panel
+	if s.Panels != nil {
+		panels = s.Panels.Panels
+	}
+	data := struct {
+		Hostname string
+		Panels   []*Panel
+	}{
+		Hostname: s.Hostname,
+		Panels:   panels,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "panels.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandlePanelsAPI lists the configured panels and their parameters
+func (s *Server) HandlePanelsAPI(w http.ResponseWriter, r *http.Request) {
+	type panelInfo struct {
+		Name        string       `json:"name"`
+		Title       string       `json:"title"`
+		Description string       `json:"description,omitempty"`
+		Chart       string       `json:"chart"`
+		Params      []PanelParam `json:"params"`
+	}
+	out := []panelInfo{}
+	if s.Panels != nil {
+		for _, p := range s.Panels.Panels {
+			params := p.Params
+			if params == nil {
+				params = []PanelParam{}
+			}
+			out = append(out, panelInfo{p.Name, p.Title, p.Description, p.Chart, params})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// HandlePanelAPI runs a panel query, e.g. GET /api/panels/per_camera?since=2h
+func (s *Server) HandlePanelAPI(w http.ResponseWriter, r *http.Request) {
+	p := s.Panels.panel(r.PathValue("name"))
+	if p == nil {
+		s.jsonError(w, "panel not found", http.StatusNotFound)
+		return
+	}
+	res, err := s.Panels.run(r.Context(), p, r.URL.Query())
+	if err != nil {
+		if errors.Is(err, errBadParam) {
+			s.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Warn("panel query", "panel", p.Name, "error", err)
+		s.jsonError(w, "query failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,113 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPanels(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	for _, body := range []string{
+		`{"plateUTF8":"AB1","sensorProviderID":"cam1"}`,
+		`{"plateUTF8":"AB2","sensorProviderID":"cam1"}`,
+		`{"plateUTF8":"AB3","sensorProviderID":"cam2"}`,
+	} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.background.Wait()
+
+	load := func(config string) (*PanelConfig, error) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "panels.json")
+		os.WriteFile(path, []byte(config), 0o644)
+		return LoadPanelConfig(path)
+	}
+	panels, err := load(`{"panels": [
+	  {"name": "per_camera", "title": "Events per camera",
+	   "query": "SELECT sensor_provider_id AS camera, COUNT(*) AS n FROM events WHERE created_at >= :since GROUP BY 1 ORDER BY 2 DESC",
+	   "params": [{"name": "since", "type": "since", "default": "24h"}],
+	   "chart": "bar", "label": "camera", "value": "n"},
+	  {"name": "plates", "query": "SELECT plate_utf8 FROM events WHERE id > :after ORDER BY id",
+	   "params": [{"name": "after", "type": "int", "default": "0"}], "limit": 2}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := panels.Open(filepath.Join(s.DataDir, "db.sqlite3")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { panels.db.Close() })
+	s.Panels = panels
+
+	w := httptest.NewRecorder()
+	s.HandlePanelsAPI(w, httptest.NewRequest("GET", "/api/panels", nil))
+	var list []struct {
+		Name   string       `json:"name"`
+		Title  string       `json:"title"`
+		Chart  string       `json:"chart"`
+		Params []PanelParam `json:"params"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list) != 2 || list[0].Chart != PanelBar || list[1].Title != "plates" || list[1].Chart != PanelTable || list[1].Params[0].Type != ParamInt {
+		t.Errorf("list: %s", w.Body)
+	}
+
+	run := func(target string) (int, PanelResult) {
+		t.Helper()
+		r := httptest.NewRequest("GET", target, nil)
+		r.SetPathValue("name", strings.TrimPrefix(strings.SplitN(target, "?", 2)[0], "/api/panels/"))
+		w := httptest.NewRecorder()
+		s.HandlePanelAPI(w, r)
+		var res PanelResult
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w.Code, res
+	}
+	if code, res := run("/api/panels/per_camera"); code != http.StatusOK || len(res.Rows) != 2 || res.Rows[0][0] != "cam1" || res.Rows[0][1] != 2.0 {
+		t.Errorf("per_camera: %d %+v", code, res)
+	}
+	if code, res := run("/api/panels/plates"); code != http.StatusOK || len(res.Rows) != 2 || !res.Truncated {
+		t.Errorf("limit: %d %+v", code, res)
+	}
+	if code, res := run("/api/panels/plates?after=2"); code != http.StatusOK || len(res.Rows) != 1 || res.Rows[0][0] != "AB3" || res.Truncated {
+		t.Errorf("after=2: %d %+v", code, res)
+	}
+	if code, _ := run("/api/panels/plates?after=two"); code != http.StatusBadRequest {
+		t.Errorf("bad param: %d", code)
+	}
+	if code, _ := run("/api/panels/nope"); code != http.StatusNotFound {
+		t.Errorf("unknown panel: %d", code)
+	}
+
+	w = httptest.NewRecorder()
+	s.HandlePanels(w, httptest.NewRequest("GET", "/panels", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Events per camera") {
+		t.Errorf("page %d:\n%s", w.Code, w.Body)
+	}
+
+	// Only single read statements that compile are accepted
+	for _, config := range []string{
+		`{"panels": [{"name": "wipe", "query": "DELETE FROM events"}]}`,
+		`{"panels": [{"name": "two", "query": "SELECT 1; DELETE FROM events"}]}`,
+		`{"panels": [{"name": "chart", "query": "SELECT 1", "chart": "bar"}]}`,
+		`{"panels": [{"name": "Bad Name", "query": "SELECT 1"}]}`,
+	} {
+		if _, err := load(config); err == nil {
+			t.Errorf("accepted %s", config)
+		}
+	}
+	broken, err := load(`{"panels": [{"name": "typo", "query": "SELECT nope FROM events"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := broken.Open(filepath.Join(s.DataDir, "db.sqlite3")); err == nil || !strings.Contains(err.Error(), "typo") {
+		t.Errorf("Open = %v", err)
+	}
+}
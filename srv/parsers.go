@@ -0,0 +1,194 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Every camera vendor has its own event payload. A vendorParser turns one
+// into event JSON, the FF Group format that parseEvent reads into
+// IncomingEvent and that is stored as the event's JSON. readUpload picks
+// the parser named by the ?vendor= query parameter (the vendor routes
+// /api/hikvision and /api/dahua, and compat routes with a vendor, fix it),
+// else the first whose sniff recognizes the payload: the field mappings of
+// -mapping-config (mapping.go), then vendorParsers. A new camera format is
+// a field mapping, or a new vendorParser in that list; HandleAPI and
+// readUpload stay as they are.
+
+// vendorParser converts one vendor's event payload
+type vendorParser interface {
+	// name is the ?vendor= value selecting the parser
+	name() string
+	// sniff reports whether a payload (JSON or XML text) is in this format
+	sniff(data []byte) bool
+	// eventJSON converts a payload into event JSON. errNotANPR means it
+	// isn't a plate read (heartbeat, other alarm) and is acknowledged
+	// without storing anything.
+	eventJSON(data []byte) ([]byte, error)
+	// pictureType is the image type of a picture file or form field named
+	// the vendor's way, or "" to classify it as usual
+	pictureType(name string) string
+}
+
+// vendorParsers in sniffing order: specific formats before the generic XML
+// and FF Group JSON that accept anything of their kind
+var vendorParsers = []vendorParser{
+	hikvisionParser{},
+	axisParser{},
+	vaxtorParser{},
+	xmlParser{},
+	dahuaParser{},
+	ffgroupParser{},
+}
+
+// errNotANPR is returned for camera messages that aren't plate reads
+var errNotANPR = errors.New("not an ANPR alert")
+
+// parsers lists the configured field mappings, then the built-in parsers
+func (s *Server) parsers() []vendorParser {
+	if s.Mappings == nil {
+		return vendorParsers
+	}
+	var ps []vendorParser
+	for _, m := range s.Mappings.Mappings {
+		ps = append(ps, m)
+	}
+	return append(ps, vendorParsers...)
+}
+
+// vendorNames lists the ?vendor= values
+func (s *Server) vendorNames() []string {
+	var names []string
+	for _, p := range s.parsers() {
+		names = append(names, p.name())
+	}
+	return names
+}
+
+// vendorParser returns the parser for a ?vendor= value
+func (s *Server) vendorParser(vendor string) (vendorParser, error) {
+	for _, p := range s.parsers() {
+		if strings.EqualFold(p.name(), vendor) {
+			return p, nil
+		}
+	}
+	return nil, &payloadError{fmt.Sprintf("unknown vendor %q (want %s)", vendor, strings.Join(s.vendorNames(), ", "))}
+}
+
+// sniffParser returns the parser of the first format a payload matches
+func (s *Server) sniffParser(data []byte) vendorParser {
+	for _, p := range s.parsers() {
+		if p.sniff(data) {
+			return p
+		}
+	}
+	return ffgroupParser{}
+}
+
+// sniffHead is the start of a payload, where the root element and its
+// namespaces are, without the images embedded further on
+func sniffHead(data []byte) []byte {
+	return data[:min(len(data), 1024)]
+}
+
+// vendorEvent converts a camera payload into event JSON with the parser
+// named by vendor, else the one that recognizes it. A converted .xml file
+// is renamed .json, and pictures named the vendor's way get their types.
+func (s *Server) vendorEvent(vendor string, data []byte, filename string, images []uploadedImage) ([]byte, string, error) {
+	p := s.sniffParser(data)
+	if vendor != "" {
+		var err error
+		if p, err = s.vendorParser(vendor); err != nil {
+			return nil, "", err
+		}
+	}
+	for i, img := range images {
+		if t := coalesce(p.pictureType(img.Filename), p.pictureType(img.Field)); t != "" {
+			images[i].Field = t
+		}
+	}
+	if len(data) == 0 {
+		// Image-only events have nothing to convert
+		return data, filename, nil
+	}
+	rawJSON, err := p.eventJSON(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if ext := filepath.Ext(filename); strings.EqualFold(ext, ".xml") {
+		filename = strings.TrimSuffix(filename, ext) + ".json"
+	}
+	return rawJSON, filename, nil
+}
+
+// ffgroupParser reads FF Group camera JSON, the native format: it is
+// stored as sent
+type ffgroupParser struct{}
+
+func (ffgroupParser) name() string                          { return "ffgroup" }
+func (ffgroupParser) sniff(data []byte) bool                { return !looksLikeXML(data) }
+func (ffgroupParser) eventJSON(data []byte) ([]byte, error) { return data, nil }
+func (ffgroupParser) pictureType(string) string             { return "" }
+
+// hikvisionParser reads Hikvision ISAPI EventNotificationAlert XML
+type hikvisionParser struct{}
+
+func (hikvisionParser) name() string { return "hikvision" }
+func (hikvisionParser) sniff(data []byte) bool {
+	return looksLikeXML(data) && isHikvisionAlert(data)
+}
+func (hikvisionParser) eventJSON(data []byte) ([]byte, error) { return hikvisionJSON(data) }
+func (hikvisionParser) pictureType(name string) string        { return hikvisionPictureType(name) }
+
+// dahuaParser reads Dahua ITC uploads and event manager pushes
+type dahuaParser struct{}
+
+func (dahuaParser) name() string { return "dahua" }
+func (dahuaParser) sniff(data []byte) bool {
+	if looksLikeXML(data) || !bytes.Contains(data, []byte(`"Picture"`)) && !bytes.Contains(data, []byte(`"Events`)) {
+		return false
+	}
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(data, &doc) != nil {
+		return false
+	}
+	_, picture := doc["Picture"]
+	_, events := doc["Events"]
+	_, form := doc["Events[0]"]
+	return picture || events || form
+}
+func (dahuaParser) eventJSON(data []byte) ([]byte, error) { return dahuaJSON(data) }
+func (dahuaParser) pictureType(name string) string        { return dahuaPictureType(name) }
+
+// axisParser reads Axis VAPIX/ONVIF event notifications, whose values are
+// SimpleItem Name/Value pairs
+type axisParser struct{}
+
+func (axisParser) name() string { return "axis" }
+func (axisParser) sniff(data []byte) bool {
+	return looksLikeXML(data) && (bytes.Contains(data, []byte("SimpleItem")) || bytes.Contains(sniffHead(data), []byte("axis.com")))
+}
+func (axisParser) eventJSON(data []byte) ([]byte, error) { return axisJSON(data) }
+func (axisParser) pictureType(string) string             { return "" }
+
+// vaxtorParser reads Vaxtor ALPR XML; its element names are among xmlKeys
+type vaxtorParser struct{}
+
+func (vaxtorParser) name() string { return "vaxtor" }
+func (vaxtorParser) sniff(data []byte) bool {
+	return looksLikeXML(data) && bytes.Contains(bytes.ToLower(sniffHead(data)), []byte("vaxtor"))
+}
+func (vaxtorParser) eventJSON(data []byte) ([]byte, error) { return xmlJSON(data) }
+func (vaxtorParser) pictureType(string) string             { return "" }
+
+// xmlParser reads any other XML event by its element names
+type xmlParser struct{}
+
+func (xmlParser) name() string                          { return "xml" }
+func (xmlParser) sniff(data []byte) bool                { return looksLikeXML(data) }
+func (xmlParser) eventJSON(data []byte) ([]byte, error) { return xmlJSON(data) }
+func (xmlParser) pictureType(string) string             { return "" }
@@ -0,0 +1,121 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const axisLPR = `<?xml version="1.0" encoding="UTF-8"?>
+<wsnt:NotificationMessage xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2" xmlns:tt="http://www.onvif.org/ver10/schema">
+<wsnt:Topic>tns1:CameraApplicationPlatform/LicensePlate/%s</wsnt:Topic>
+<wsnt:Message><tt:Message UtcTime="2026-05-03T14:11:04Z">
+<tt:Source><tt:SimpleItem Name="SerialNumber" Value="ACCC8E012345"/></tt:Source>
+<tt:Data><tt:SimpleItem Name="Plate" Value="AX123"/><tt:SimpleItem Name="Country" Value="SWE"/>
+<tt:SimpleItem Name="Make" Value="Volvo"/></tt:Data>
+</tt:Message></wsnt:Message>
+</wsnt:NotificationMessage>`
+
+func TestSniffParser(t *testing.T) {
+	s := &Server{}
+	for _, tt := range []struct {
+		payload string
+		want    string
+	}{
+		{`{"plateUTF8":"A1"}`, "ffgroup"},
+		{fmt.Sprintf(hikvisionANPR, "false"), "hikvision"},
+		{strings.ReplaceAll(dahuaITC, "%s", ""), "dahua"},
+		{`{"Events":[{"Code":"HeartBeat"}]}`, "dahua"},
+		{fmt.Sprintf(axisLPR, "Read"), "axis"},
+		{`<ALPR xmlns="http://www.vaxtor.com/alpr"><Plate>V1</Plate></ALPR>`, "vaxtor"},
+		{`<read><plate>X1</plate></read>`, "xml"},
+	} {
+		if got := s.sniffParser([]byte(tt.payload)).name(); got != tt.want {
+			t.Errorf("%.40s: sniffed %s, want %s", tt.payload, got, tt.want)
+		}
+	}
+	if _, err := s.vendorParser("Hikvision"); err != nil {
+		t.Error(err)
+	}
+	if _, err := s.vendorParser("acme"); err == nil || !strings.Contains(err.Error(), "ffgroup") {
+		t.Errorf("unknown vendor: %v", err)
+	}
+}
+
+func TestAxisJSON(t *testing.T) {
+	got, err := axisJSON([]byte(fmt.Sprintf(axisLPR, "Read")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a, b any
+	json.Unmarshal(got, &a)
+	json.Unmarshal([]byte(`{"plateUTF8":"AX123","plateCountry":"SWE","vehicle_info":{"make":"Volvo"},
+		"camera_info":{"SerialNumber":"ACCC8E012345"},"Topic":"tns1:CameraApplicationPlatform/LicensePlate/Read","capture_timestamp":"2026-05-03T14:11:04Z"}`), &b)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("got %s", got)
+	}
+
+	// Other topics, e.g. motion, are dropped
+	motion := strings.Replace(axisLPR, "CameraApplicationPlatform/LicensePlate/%s", "VideoAnalytics/MotionDetection", 1)
+	if _, err := axisJSON([]byte(motion)); !errors.Is(err, errNotANPR) {
+		t.Errorf("motion: %v", err)
+	}
+}
+
+func TestVendorHint(t *testing.T) {
+	s := newTestServer(t)
+
+	post := func(target, body string) (int, string) {
+		r := httptest.NewRequest("POST", target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.HandleAPI(w, r)
+		return w.Code, w.Body.String()
+	}
+
+	// Sniffed without a hint
+	if code, body := post("/api", fmt.Sprintf(axisLPR, "Read")); code != http.StatusOK {
+		t.Fatalf("axis: %d %s", code, body)
+	}
+	if code, body := post("/api", `{"Events":[{"Code":"HeartBeat"}]}`); code != http.StatusOK || !strings.Contains(body, "ignored") {
+		t.Errorf("keepalive: %d %s", code, body)
+	}
+	// A hint picks the parser; ffgroup keeps a Picture key from reading as Dahua
+	if code, body := post("/api?vendor=dahua", strings.ReplaceAll(dahuaITC, "%s", "")); code != http.StatusOK {
+		t.Fatalf("dahua: %d %s", code, body)
+	}
+	if code, body := post("/api?vendor=ffgroup", `{"plateUTF8":"FF1","Picture":{"x":1}}`); code != http.StatusOK {
+		t.Fatalf("ffgroup: %d %s", code, body)
+	}
+	if code, _ := post("/api?vendor=acme", `{"plateUTF8":"X"}`); code != http.StatusBadRequest {
+		t.Errorf("unknown vendor: %d", code)
+	}
+
+	rows, err := s.DB.Query(`SELECT plate_utf8 FROM events ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var plate string
+		rows.Scan(&plate)
+		got = append(got, plate)
+	}
+	if want := []string{"AX123", "DH5678", "FF1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored %q, want %q", got, want)
+	}
+
+	// The formats document lists the hints
+	w := httptest.NewRecorder()
+	s.HandleFormats(w, httptest.NewRequest("GET", "/api/formats", nil))
+	if !bytes.Contains(w.Body.Bytes(), []byte(`"vendors":["hikvision","axis","vaxtor","xml","dahua","ffgroup"]`)) {
+		t.Errorf("formats: %s", w.Body)
+	}
+}
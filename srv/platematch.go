@@ -0,0 +1,321 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// A reviewer can enter the plate an archived event really had (compare
+// page, or "true_plate" in a compare API PUT). The read is compared with it
+// by a weighted Levenshtein distance over the normalized plates: inserting
+// or deleting a character costs 1, substituting one costs 1 or the cost of
+// a substitution rule. Distance 0 marks the plate as correct. Otherwise the
+// plate is incorrect, and a near miss within the maximum distance is also
+// classified as partially correct: it is shown in orange and counted in a
+// lenient plate accuracy next to the strict one. The distance is stored per
+// event (compare_plates) and summed up on the compare page and the
+// workbook's Statistics sheet.
+//
+// -plate-match-config sets the maximum distance (0 without the file: exact
+// matches only) and the substitution rules, symmetric pairs of characters
+// cameras confuse. With test_vehicles set, reads of an archive without a
+// verdict get the unique nearest test vehicle plate (see laps.go) within
+// the maximum distance as their true plate when it is archived:
+//
+//	{"max_distance": 1, "substitutions": {"0O": 0.5, "1I": 0.5, "8B": 0.5}, "test_vehicles": true}
+//
+//	POST /api/archive/{id}/compare/plates   reclassifies the archive's plates with the current config
+
+// Where a true plate came from
+const (
+	plateTruthReviewer    = "reviewer"
+	plateTruthTestVehicle = "test_vehicle"
+	plateTruthGroundTruth = "ground_truth" // imported, see groundtruth.go
+)
+
+// PlateMatchConfig is how near a read has to be to the true plate to count
+// as partially correct
+type PlateMatchConfig struct {
+	MaxDistance   float64            `json:"max_distance"`
+	Substitutions map[string]float64 `json:"substitutions"` // two characters -> cost in [0, 1]
+	TestVehicles  bool               `json:"test_vehicles"` // test vehicle plates are the truth of matching reads
+
+	costs map[[2]rune]float64
+}
+
+// LoadPlateMatchConfig reads a plate matching config file
+func LoadPlateMatchConfig(path string) (*PlateMatchConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg PlateMatchConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// compile checks the rules and indexes them by character pair
+func (c *PlateMatchConfig) compile() error {
+	if c.MaxDistance < 0 {
+		return fmt.Errorf("max_distance %g: want 0 or more", c.MaxDistance)
+	}
+	c.costs = make(map[[2]rune]float64, 2*len(c.Substitutions))
+	for pair, cost := range c.Substitutions {
+		chars := []rune(strings.ToUpper(pair))
+		if len(chars) != 2 || chars[0] == chars[1] {
+			return fmt.Errorf("substitution %q: want two different characters", pair)
+		}
+		if cost < 0 || cost > 1 {
+			return fmt.Errorf("substitution %q: cost %g not in [0, 1]", pair, cost)
+		}
+		c.costs[[2]rune{chars[0], chars[1]}] = cost
+		c.costs[[2]rune{chars[1], chars[0]}] = cost
+	}
+	return nil
+}
+
+func (c *PlateMatchConfig) maxDistance() float64 {
+	if c == nil {
+		return 0
+	}
+	return c.MaxDistance
+}
+
+// substitution is the cost of reading a as b
+func (c *PlateMatchConfig) substitution(a, b rune) float64 {
+	if a == b {
+		return 0
+	}
+	if c != nil {
+		if cost, ok := c.costs[[2]rune{a, b}]; ok {
+			return cost
+		}
+	}
+	return 1
+}
+
+// distance is the weighted edit distance between a read and the true
+// plate, both normalized
+func (c *PlateMatchConfig) distance(read, truth string) float64 {
+	a, b := []rune(normalizePlate(read)), []rune(normalizePlate(truth))
+	prev := make([]float64, len(b)+1)
+	cur := make([]float64, len(b)+1)
+	for j := range prev {
+		prev[j] = float64(j)
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = float64(i)
+		for j := 1; j <= len(b); j++ {
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+c.substitution(a[i-1], b[j-1]))
+		}
+		prev, cur = cur, prev
+	}
+	// Fractional costs shouldn't leave 0.30000000000000004 behind
+	return math.Round(prev[len(b)]*1000) / 1000
+}
+
+// partial reports whether a read at distance d is a near miss
+func (c *PlateMatchConfig) partial(d float64) bool {
+	return d > 0 && d <= c.maxDistance()
+}
+
+// nearestTestPlate returns the test vehicle plate nearest to a read, if
+// exactly one is within the maximum distance
+func (c *PlateMatchConfig) nearestTestPlate(read string, vehicles []dbgen.TestVehicle) (string, bool) {
+	best, bestDistance, ties := "", math.Inf(1), 0
+	for _, v := range vehicles {
+		d := c.distance(read, v.Plate)
+		switch {
+		case d < bestDistance:
+			best, bestDistance, ties = normalizePlate(v.Plate), d, 0
+		case d == bestDistance && normalizePlate(v.Plate) != best:
+			ties++
+		}
+	}
+	if best == "" || ties > 0 || bestDistance > c.maxDistance() {
+		return "", false
+	}
+	return best, true
+}
+
+// classifyTruePlate compares an update's true plate with the event's read
+// and sets the plate verdict from it. It returns the row to store, nil
+// without a true plate or for an empty one.
+func (s *Server) classifyTruePlate(ctx context.Context, q *dbgen.Queries, archiveID, eventID int64, u *compareUpdate) (*dbgen.UpsertComparePlateParams, error) {
+	if u.TruePlate == nil {
+		return nil, nil
+	}
+	truth := normalizePlate(*u.TruePlate)
+	if truth == "" {
+		return nil, nil
+	}
+	event, err := q.GetEventByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	d := s.PlateMatch.distance(deref(event.PlateUtf8), truth)
+	u.Plate = ptr(d > 0)
+	return &dbgen.UpsertComparePlateParams{
+		ArchiveID: archiveID,
+		EventID:   eventID,
+		TruePlate: truth,
+		Source:    coalesce(u.truthSource, plateTruthReviewer),
+		Distance:  d,
+		Partial:   s.PlateMatch.partial(d),
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
+// saveComparePlate stores or removes an event's true plate along with its
+// verdicts. A plate verdict given without a true plate overrides the
+// classification, so it removes the stored one too.
+func saveComparePlate(ctx context.Context, q *dbgen.Queries, archiveID, eventID int64, u compareUpdate, row *dbgen.UpsertComparePlateParams) error {
+	if row != nil {
+		return q.UpsertComparePlate(ctx, *row)
+	}
+	if u.TruePlate != nil || u.Plate != nil {
+		return q.DeleteComparePlate(ctx, dbgen.DeleteComparePlateParams{ArchiveID: archiveID, EventID: eventID})
+	}
+	return nil
+}
+
+// applyPlateTruth classifies an archive's plates again: true plates keep
+// their source and are compared with the current rules, and with
+// test_vehicles set, reads without a verdict of their own get the nearest
+// test vehicle plate. It returns how many events were classified.
+func (s *Server) applyPlateTruth(ctx context.Context, archiveID int64) (int, error) {
+	q := dbgen.New(s.DB)
+	reads, err := q.GetArchivePlateReads(ctx, &archiveID)
+	if err != nil {
+		return 0, err
+	}
+	plates, err := q.GetComparePlates(ctx, archiveID)
+	if err != nil {
+		return 0, err
+	}
+	stored := make(map[int64]dbgen.ComparePlate, len(plates))
+	for _, p := range plates {
+		stored[p.EventID] = p
+	}
+	judged := make(map[int64]bool)
+	var vehicles []dbgen.TestVehicle
+	if s.PlateMatch != nil && s.PlateMatch.TestVehicles {
+		if vehicles, err = q.GetTestVehicles(ctx); err != nil {
+			return 0, err
+		}
+		results, err := q.GetCompareResultsDetail(ctx, archiveID)
+		if err != nil {
+			return 0, err
+		}
+		for _, r := range results {
+			if r.Field == "plate" {
+				judged[r.EventID] = true
+			}
+		}
+	}
+
+	var updates []compareUpdate
+	for _, e := range reads {
+		p, ok := stored[e.ID]
+		switch {
+		case ok && p.Source != plateTruthTestVehicle:
+			updates = append(updates, compareUpdate{EventID: e.ID, TruePlate: ptr(p.TruePlate), truthSource: p.Source})
+		case len(vehicles) > 0 && (ok || !judged[e.ID]):
+			// Reads judged by a reviewer keep their verdict
+			truth, found := s.PlateMatch.nearestTestPlate(deref(e.PlateUtf8), vehicles)
+			if found || ok {
+				updates = append(updates, compareUpdate{EventID: e.ID, TruePlate: ptr(truth), truthSource: plateTruthTestVehicle})
+			}
+		case ok:
+			// A test vehicle match without test_vehicles any more
+			updates = append(updates, compareUpdate{EventID: e.ID, TruePlate: ptr("")})
+		}
+	}
+	if len(updates) == 0 {
+		return 0, nil
+	}
+	if _, err := s.saveCompareUpdates(ctx, archiveID, updates, "", false); err != nil {
+		return 0, err
+	}
+	slog.Info("plates classified", "archive", archiveID, "events", len(updates))
+	return len(updates), nil
+}
+
+// archivePlateTruth matches a new archive's reads with the test vehicles
+func (s *Server) archivePlateTruth(ctx context.Context, archiveID int64) {
+	if s.PlateMatch == nil || !s.PlateMatch.TestVehicles {
+		return
+	}
+	if _, err := s.applyPlateTruth(ctx, archiveID); err != nil {
+		slog.Warn("apply test vehicle plates", "archive", archiveID, "error", err)
+	}
+}
+
+// plateMatchStats sum up an archive's true plates
+type plateMatchStats struct {
+	MaxDistance  float64  `json:"max_distance"`
+	Checked      int64    `json:"checked"` // events with a true plate
+	Exact        int64    `json:"exact"`
+	Partial      int64    `json:"partial"`
+	Incorrect    int64    `json:"incorrect"`
+	MeanDistance float64  `json:"mean_distance"`
+	Lenient      accuracy `json:"lenient"` // plate accuracy counting partially correct reads as correct
+}
+
+// archivePlateStats counts an archive's true plates, nil if it has none.
+// plate is the archive's strict plate accuracy.
+func (s *Server) archivePlateStats(ctx context.Context, q *dbgen.Queries, archiveID int64, plate accuracy) (*plateMatchStats, error) {
+	plates, err := q.GetComparePlates(ctx, archiveID)
+	if err != nil || len(plates) == 0 {
+		return nil, err
+	}
+	st := &plateMatchStats{MaxDistance: s.PlateMatch.maxDistance(), Checked: int64(len(plates))}
+	var sum float64
+	for _, p := range plates {
+		sum += p.Distance
+		switch {
+		case p.Distance == 0:
+			st.Exact++
+		case p.Partial:
+			st.Partial++
+		default:
+			st.Incorrect++
+		}
+	}
+	st.MeanDistance = math.Round(sum/float64(len(plates))*100) / 100
+	partial := min(st.Partial, plate.Incorrect)
+	st.Lenient = newAccuracy(plate.Correct+partial, plate.Incorrect-partial)
+	return st, nil
+}
+
+// HandleApplyPlateTruth reclassifies an archive's plates with the current
+// plate matching config
+func (s *Server) HandleApplyPlateTruth(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	n, err := s.applyPlateTruth(r.Context(), id)
+	if err != nil {
+		s.writeCompareError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "events": n})
+}
@@ -0,0 +1,176 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestPlateDistance(t *testing.T) {
+	cfg := &PlateMatchConfig{MaxDistance: 1, Substitutions: map[string]float64{"0o": 0.5, "8B": 0.3, "1I": 0}}
+	if err := cfg.compile(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		read, truth string
+		want        float64
+		partial     bool
+	}{
+		{"AB 123", "ab-123", 0, false},
+		{"AB123", "AB124", 1, true},
+		{"AB123", "AB12", 1, true},
+		{"A0123", "AO123", 0.5, true},
+		{"O8123", "0B123", 0.8, true},
+		{"1B123", "IB123", 0, false},
+		{"AB123", "XY123", 2, false},
+		{"", "AB1", 3, false},
+	} {
+		d := cfg.distance(tc.read, tc.truth)
+		if d != tc.want || cfg.partial(d) != tc.partial {
+			t.Errorf("%q vs %q: %g partial %v, want %g %v", tc.read, tc.truth, d, cfg.partial(d), tc.want, tc.partial)
+		}
+	}
+	// Without a config only exact reads are right
+	var none *PlateMatchConfig
+	if d := none.distance("A0123", "AO123"); d != 1 || none.partial(d) {
+		t.Errorf("no config: %g", d)
+	}
+
+	for _, bad := range []string{`{"max_distance": -1}`, `{"substitutions": {"0": 0.5}}`, `{"substitutions": {"AA": 0.5}}`, `{"substitutions": {"0O": 2}}`} {
+		path := filepath.Join(t.TempDir(), "plate_match.json")
+		os.WriteFile(path, []byte(bad), 0o644)
+		if _, err := LoadPlateMatchConfig(path); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+func TestPlateTruth(t *testing.T) {
+	s := newTestServer(t)
+	cfg := &PlateMatchConfig{MaxDistance: 1, Substitutions: map[string]float64{"0O": 0.5}, TestVehicles: true}
+	if err := cfg.compile(); err != nil {
+		t.Fatal(err)
+	}
+	s.PlateMatch = cfg
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+	if err := q.CreateTestVehicle(ctx, dbgen.CreateTestVehicleParams{Plate: "TEST01", ExpectedPerLap: 1, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	for _, plate := range []string{"TESTO1", "AB123", "XY999", "CD456"} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"`+plate+`"}`), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Archiving matches the near miss of the test vehicle
+	s.HandleClean(httptest.NewRecorder(), httptest.NewRequest("POST", "/clean", nil))
+
+	put := func(eventID, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("PUT", "/api/archive/1/compare/"+eventID, strings.NewReader(body))
+		r.SetPathValue("id", "1")
+		r.SetPathValue("eventID", eventID)
+		w := httptest.NewRecorder()
+		s.HandlePutCompareResultAPI(w, r)
+		return w
+	}
+	if w := put("2", `{"true_plate": "AB-124"}`); w.Code != http.StatusOK {
+		t.Fatalf("true plate: %d %s", w.Code, w.Body)
+	}
+	if w := put("3", `{"true_plate": "QQ111"}`); w.Code != http.StatusOK {
+		t.Fatalf("true plate: %d %s", w.Code, w.Body)
+	}
+	w := put("4", `{"true_plate": "cd 456"}`)
+	var a compareAnnotation
+	json.NewDecoder(w.Body).Decode(&a)
+	if a.Plate || deref(a.TruePlate) != "CD456" || a.PlateDistance == nil || *a.PlateDistance != 0 {
+		t.Errorf("exact read %+v", a)
+	}
+
+	annotations, err := compareAnnotations(ctx, q, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range annotations {
+		want := map[int64]struct {
+			truth    string
+			distance float64
+			partial  bool
+		}{1: {"TEST01", 0.5, true}, 2: {"AB124", 1, true}, 3: {"QQ111", 5, false}, 4: {"CD456", 0, false}}[a.EventID]
+		if deref(a.TruePlate) != want.truth || a.PlateDistance == nil || *a.PlateDistance != want.distance || a.PlatePartial != want.partial ||
+			a.Plate != (want.distance > 0) {
+			t.Errorf("event %d: %+v", a.EventID, a)
+		}
+	}
+
+	stats, err := s.compareStats(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := stats.Plates
+	if p == nil || p.Checked != 4 || p.Exact != 1 || p.Partial != 2 || p.Incorrect != 1 || p.MeanDistance != 1.63 ||
+		p.Lenient.Pct == nil || *p.Lenient.Pct != 75 || stats.Fields["plate"].Correct != 1 || stats.Fields["plate"].Incorrect != 3 {
+		t.Errorf("plate stats %+v, plate %+v", p, stats.Fields["plate"])
+	}
+
+	archive, _ := q.GetArchiveByID(ctx, 1)
+	data, err := s.compareWorkbook(ctx, archive, compareExportOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if v, _ := f.GetCellValue("Statistics", "B13"); v != "2" {
+		t.Errorf("partial count %q", v)
+	}
+	if v, _ := f.GetCellValue("Statistics", "B16"); v != "75.0%" {
+		t.Errorf("lenient accuracy %q", v)
+	}
+	partial, _ := f.GetCellStyle("Compare Results", "C5") // newest first
+	wrong, _ := f.GetCellStyle("Compare Results", "C3")
+	if partial == wrong || partial == 0 {
+		t.Errorf("partial plate styled %d, incorrect %d", partial, wrong)
+	}
+
+	r := httptest.NewRequest("GET", "/archive/1/compare", nil)
+	r.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	s.HandleCompare(w, r)
+	if !strings.Contains(w.Body.String(), "→ AB124") || !strings.Contains(w.Body.String(), "Plate matching (4 true plates") {
+		t.Errorf("compare page doesn't show the true plates:\n%s", w.Body)
+	}
+
+	// A verdict without a true plate overrides the classification, and
+	// reclassifying leaves it alone
+	if _, err := s.saveCompareUpdates(ctx, 1, []compareUpdate{{EventID: 1, Plate: ptr(false)}}, "", false); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.applyPlateTruth(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plates, _ := q.GetComparePlates(ctx, 1)
+	if n != 3 || len(plates) != 3 || plates[0].EventID != 2 {
+		t.Errorf("reclassified %d: %+v", n, plates)
+	}
+	if w := put("2", `{"true_plate": ""}`); w.Code != http.StatusOK {
+		t.Fatalf("remove true plate: %d %s", w.Code, w.Body)
+	}
+	if plates, _ := q.GetComparePlates(ctx, 1); len(plates) != 2 {
+		t.Errorf("after removing: %+v", plates)
+	}
+}
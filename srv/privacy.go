@@ -0,0 +1,296 @@
+package srv
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// The privacy policy (-privacy-config) anonymizes events older than
+// after_days for data minimization. Plates are hashed (the same plate keeps
+// the same hash, so repeat visits still line up) or stripped, the original
+// camera JSON is dropped, and overview images are deleted. Event rows,
+// recognition confidences, vehicle attributes and compare verdicts stay, so
+// statistics and accuracy history are unaffected. Archives under legal hold
+// are skipped; locked archives too with exempt_locked, since anonymizing
+// them makes verification report every event as changed.
+//
+//	{"after_days": 30, "plates": "hash", "salt": "long random secret", "images": "overview"}
+
+// Plate handling
+const (
+	PlatesHash  = "hash"  // replace with #<HMAC-SHA256 prefix>
+	PlatesStrip = "strip" // remove
+)
+
+// Image handling
+const (
+	ImagesOverview = "overview" // delete vehicle frames, keep plate crops
+	ImagesAll      = "all"
+	ImagesNone     = "none"
+)
+
+const (
+	privacySweepInterval = time.Hour
+	privacyBatch         = 500
+)
+
+// PrivacyConfig is the anonymization policy
+type PrivacyConfig struct {
+	AfterDays    int    `json:"after_days"`
+	Plates       string `json:"plates"`
+	Salt         string `json:"salt"` // HMAC key for hashed plates; keep it secret
+	Images       string `json:"images"`
+	ExemptLocked bool   `json:"exempt_locked"`
+
+	mu       sync.Mutex
+	lastRun  time.Time
+	lastErr  error
+	lastDone int
+}
+
+// LoadPrivacyConfig reads a privacy policy file
+func LoadPrivacyConfig(path string) (*PrivacyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg PrivacyConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cfg.AfterDays < 1 {
+		return nil, fmt.Errorf("%s: after_days must be at least 1", path)
+	}
+	switch cfg.Plates {
+	case "":
+		cfg.Plates = PlatesHash
+	case PlatesHash, PlatesStrip:
+	default:
+		return nil, fmt.Errorf("%s: unknown plates %q (want hash or strip)", path, cfg.Plates)
+	}
+	if cfg.Plates == PlatesHash && len(cfg.Salt) < 16 {
+		return nil, fmt.Errorf("%s: hashed plates need a salt of at least 16 characters, or short plates can be brute-forced", path)
+	}
+	switch cfg.Images {
+	case "":
+		cfg.Images = ImagesOverview
+	case ImagesOverview, ImagesAll, ImagesNone:
+	default:
+		return nil, fmt.Errorf("%s: unknown images %q (want overview, all or none)", path, cfg.Images)
+	}
+	return &cfg, nil
+}
+
+// cutoff is the capture time before which events are anonymized
+func (p *PrivacyConfig) cutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -p.AfterDays).In(time.Local)
+}
+
+// plate anonymizes one plate value
+func (p *PrivacyConfig) plate(v *string) *string {
+	if v == nil || p.Plates == PlatesStrip {
+		return nil
+	}
+	key := strings.NewReplacer(" ", "", "-", "").Replace(strings.ToUpper(*v))
+	mac := hmac.New(sha256.New, []byte(p.Salt))
+	mac.Write([]byte(key))
+	return ptr("#" + strings.ToUpper(hex.EncodeToString(mac.Sum(nil))[:12]))
+}
+
+// deletesImage reports whether an image of this kind is deleted
+func (p *PrivacyConfig) deletesImage(kind string) bool {
+	switch p.Images {
+	case ImagesAll:
+		return true
+	case ImagesOverview:
+		return kind != "plate"
+	}
+	return false
+}
+
+// runPrivacy applies the privacy policy periodically
+func (s *Server) runPrivacy(ctx context.Context) {
+	p := s.Privacy
+	slog.Info("privacy policy enabled", "after_days", p.AfterDays, "plates", p.Plates, "images", p.Images)
+	for {
+		if n, err := s.anonymizeExpired(ctx); err != nil {
+			slog.Warn("anonymization failed", "error", err)
+		} else if n > 0 {
+			slog.Info("anonymized events", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(privacySweepInterval):
+		}
+	}
+}
+
+// anonymizeExpired anonymizes every event past the policy's age
+func (s *Server) anonymizeExpired(ctx context.Context) (int, error) {
+	p := s.Privacy
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var done int
+	err := func() error {
+		q := dbgen.New(s.DB)
+		cutoff := p.cutoff(time.Now())
+		for {
+			events, err := q.GetEventsToAnonymize(ctx, dbgen.GetEventsToAnonymizeParams{
+				Cutoff:       &cutoff,
+				ExemptLocked: p.ExemptLocked,
+				Limit:        privacyBatch,
+			})
+			if err != nil {
+				return err
+			}
+			for _, e := range events {
+				if err := s.anonymizeEvent(ctx, e); err != nil {
+					return fmt.Errorf("event %d: %w", e.ID, err)
+				}
+				done++
+			}
+			if len(events) < privacyBatch {
+				return nil
+			}
+		}
+	}()
+	p.lastRun, p.lastErr, p.lastDone = time.Now(), err, done
+	return done, err
+}
+
+// anonymizeEvent strips an event's plates, camera JSON and images per policy
+func (s *Server) anonymizeEvent(ctx context.Context, e dbgen.GetEventsToAnonymizeRow) error {
+	p := s.Privacy
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(s.DB).WithTx(tx)
+
+	messages, err := q.GetEventMessagePlates(ctx, e.ID)
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		if err := q.AnonymizeEventMessage(ctx, dbgen.AnonymizeEventMessageParams{PlateUtf8: p.plate(m.PlateUtf8), ID: m.ID}); err != nil {
+			return err
+		}
+	}
+	images, err := q.GetEventImagesToAnonymize(ctx, e.ID)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, img := range images {
+		if !p.deletesImage(img.Kind) {
+			continue
+		}
+		if err := q.DeleteImage(ctx, img.ID); err != nil {
+			return err
+		}
+		s.removeThumbs(img.ID)
+		if img.DiskFilename != nil && *img.DiskFilename != "" {
+			files = append(files, blobImagePrefix+*img.DiskFilename)
+		}
+	}
+	if e.JsonFilename != nil && *e.JsonFilename != "" {
+		files = append(files, blobJSONPrefix+*e.JsonFilename)
+	}
+	err = q.AnonymizeEvent(ctx, dbgen.AnonymizeEventParams{
+		PlateUtf8:    p.plate(e.PlateUtf8),
+		ManualPlate:  p.plate(e.ManualPlate),
+		OcrPlate:     p.plate(e.OcrPlate),
+		AnonymizedAt: ptr(time.Now()),
+		ID:           e.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.resetQuotaUsage()
+
+	// Files go once the database no longer points at them
+	for _, f := range files {
+		s.removeBlob(ctx, f)
+	}
+	return nil
+}
+
+// privacyStatus reports the policy and its progress
+type privacyStatus struct {
+	AfterDays    int        `json:"after_days"`
+	Plates       string     `json:"plates"`
+	Images       string     `json:"images"`
+	ExemptLocked bool       `json:"exempt_locked"`
+	Cutoff       time.Time  `json:"cutoff"`
+	Anonymized   int64      `json:"anonymized"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastCount    int        `json:"last_count"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// HandlePrivacyAPI returns the privacy policy and how many events it has
+// anonymized
+func (s *Server) HandlePrivacyAPI(w http.ResponseWriter, r *http.Request) {
+	p := s.Privacy
+	if p == nil {
+		s.jsonError(w, "no privacy policy is configured (-privacy-config)", http.StatusNotFound)
+		return
+	}
+	n, err := dbgen.New(s.DB).CountAnonymizedEvents(r.Context())
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	st := privacyStatus{
+		AfterDays:    p.AfterDays,
+		Plates:       p.Plates,
+		Images:       p.Images,
+		ExemptLocked: p.ExemptLocked,
+		Cutoff:       p.cutoff(time.Now()),
+		Anonymized:   n,
+	}
+	p.mu.Lock()
+	if !p.lastRun.IsZero() {
+		st.LastRun = ptr(p.lastRun)
+		st.LastCount = p.lastDone
+	}
+	if p.lastErr != nil {
+		st.LastError = p.lastErr.Error()
+	}
+	p.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+// HandleRunPrivacy applies the privacy policy now instead of waiting for the
+// next sweep
+func (s *Server) HandleRunPrivacy(w http.ResponseWriter, r *http.Request) {
+	if s.Privacy == nil {
+		s.jsonError(w, "no privacy policy is configured (-privacy-config)", http.StatusNotFound)
+		return
+	}
+	if _, err := s.anonymizeExpired(r.Context()); err != nil {
+		slog.Warn("anonymization failed", "error", err)
+		s.jsonError(w, "anonymization failed", http.StatusInternalServerError)
+		return
+	}
+	s.HandlePrivacyAPI(w, r)
+}
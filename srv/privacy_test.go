@@ -0,0 +1,77 @@
+package srv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestLoadPrivacyConfig(t *testing.T) {
+	dir := t.TempDir()
+	for body, ok := range map[string]bool{
+		`{"after_days":30,"salt":"0123456789abcdef"}`:       true,
+		`{"after_days":30,"plates":"strip","images":"all"}`: true,
+		`{"after_days":30}`:                 false, // hashing without a salt
+		`{"after_days":0,"plates":"strip"}`: false,
+		`{"after_days":30,"plates":"blur"}`: false,
+	} {
+		path := filepath.Join(dir, "privacy.json")
+		os.WriteFile(path, []byte(body), 0644)
+		if _, err := LoadPrivacyConfig(path); (err == nil) != ok {
+			t.Errorf("%s: err = %v", body, err)
+		}
+	}
+}
+
+func TestAnonymize(t *testing.T) {
+	s := newTestServer(t)
+	s.Privacy = &PrivacyConfig{AfterDays: 30, Plates: PlatesHash, Salt: "0123456789abcdef", Images: ImagesOverview}
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+
+	for _, body := range []string{
+		`{"plateUTF8":"AB 123","capture_timestamp":"2020-01-01T10:00:00Z"}`,
+		`{"plateUTF8":"AB-123","capture_timestamp":"2020-01-02T10:00:00Z"}`,
+		`{"plateUTF8":"CD456","capture_timestamp":"2020-01-03T10:00:00Z"}`, // held
+		`{"plateUTF8":"EF789"}`, // recent
+	} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO images (event_id, image_type, image_data) VALUES (1, 'vehicle', x'00'), (1, 'plate', x'00')")
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('disputed')")
+	s.DB.Exec("UPDATE events SET archive_id = 1 WHERE id = 3")
+	s.placeHold(ctx, 1, "case 7", "ana")
+	q.SetCompareResult(ctx, dbgen.SetCompareResultParams{ArchiveID: 1, EventID: 3, Field: "plate", IsIncorrect: true})
+
+	n, err := s.anonymizeExpired(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("anonymized %d, err %v; want 2", n, err)
+	}
+	e1, _ := q.GetEventByID(ctx, 1)
+	e2, _ := q.GetEventByID(ctx, 2)
+	if e1.PlateUtf8 == nil || (*e1.PlateUtf8)[0] != '#' || *e1.PlateUtf8 != deref(e2.PlateUtf8) {
+		t.Errorf("hashed plates %v, %v: want the same #hash", deref(e1.PlateUtf8), deref(e2.PlateUtf8))
+	}
+	if e1.RawJson != nil || e1.JsonFilename != nil || e1.AnonymizedAt == nil {
+		t.Errorf("anonymized event = %+v", e1)
+	}
+	if images, _ := q.GetEventImagesToAnonymize(ctx, 1); len(images) != 1 || images[0].Kind != "plate" {
+		t.Errorf("remaining images = %+v, want the plate crop", images)
+	}
+	if e3, _ := q.GetEventByID(ctx, 3); deref(e3.PlateUtf8) != "CD456" || e3.AnonymizedAt != nil {
+		t.Error("held archive anonymized")
+	}
+	if e4, _ := q.GetEventByID(ctx, 4); deref(e4.PlateUtf8) != "EF789" {
+		t.Error("recent event anonymized")
+	}
+
+	// Nothing left to do on the next sweep
+	if n, _ := s.anonymizeExpired(ctx); n != 0 {
+		t.Errorf("second sweep anonymized %d", n)
+	}
+}
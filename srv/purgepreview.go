@@ -0,0 +1,216 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// The purge preview (/archive/{id}/purge-preview, /api/archive/{id}/purge-preview)
+// is a dry run of purgeArchive: what purging an archive would delete and
+// how much space it would give back. Nothing is changed. Files in the data
+// dir are measured on disk, so missing files show up; with a blob store the
+// recorded sizes are used.
+
+// purgePageFiles bounds the file list on the preview page; the API lists all
+const purgePageFiles = 1000
+
+// Kinds of files a purge deletes
+const (
+	purgeFileJSON  = "json"
+	purgeFileImage = "image"
+	purgeFileThumb = "thumb"
+)
+
+// purgePreview is the GET /api/archive/{id}/purge-preview response
+type purgePreview struct {
+	ArchiveID  int64       `json:"archive_id"`
+	Name       *string     `json:"name"`
+	Trashed    bool        `json:"trashed"`
+	Blocked    string      `json:"blocked,omitempty"` // why it can't be purged now
+	Events     int64       `json:"events"`
+	Images     int64       `json:"images"`
+	Messages   int64       `json:"messages"`    // continuation messages
+	DBBytes    int64       `json:"db_bytes"`    // camera JSON and images kept in the database
+	FileBytes  int64       `json:"file_bytes"`  // camera JSON and image files
+	ThumbBytes int64       `json:"thumb_bytes"` // cached thumbnails
+	FilesIn    string      `json:"files_in"`    // "data dir" or "blob store"
+	Missing    int         `json:"missing"`     // recorded files not found in the data dir
+	Files      []purgeFile `json:"files,omitempty"`
+}
+
+// TotalBytes is everything the purge gives back, apart from page overhead
+func (p purgePreview) TotalBytes() int64 {
+	return p.DBBytes + p.FileBytes + p.ThumbBytes
+}
+
+// purgeFile is a file the purge deletes
+type purgeFile struct {
+	Key     string `json:"key"` // relative to the data dir or blob store
+	Kind    string `json:"kind"`
+	EventID int64  `json:"event_id"`
+	Bytes   int64  `json:"bytes"`
+	Missing bool   `json:"missing,omitempty"`
+}
+
+// loadPurgePreview measures what purging an archive would delete; the file
+// list is only kept with listFiles
+func (s *Server) loadPurgePreview(ctx context.Context, id int64, listFiles bool) (purgePreview, error) {
+	q := dbgen.New(s.DB)
+	a, err := q.GetArchiveByID(ctx, id)
+	if err != nil {
+		return purgePreview{}, err
+	}
+	p := purgePreview{ArchiveID: id, Name: a.Name, FilesIn: "data dir"}
+	if s.Blobs != nil {
+		p.FilesIn = "blob store"
+	}
+	if _, err := q.GetArchiveTrash(ctx, id); err == nil {
+		p.Trashed = true
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return p, err
+	}
+	held, err := s.archiveHeld(ctx, id)
+	if err != nil {
+		return p, err
+	}
+	locked, err := s.archiveLocked(ctx, id)
+	if err != nil {
+		return p, err
+	}
+	switch {
+	case held:
+		p.Blocked = "under legal hold"
+	case locked:
+		p.Blocked = "locked"
+	}
+
+	msgs, err := q.GetArchivePurgeMessages(ctx, &id)
+	if err != nil {
+		return p, err
+	}
+	p.Messages, p.DBBytes = msgs.Messages, msgs.JsonBytes
+
+	rows, err := q.GetArchivePurgeFiles(ctx, &id)
+	if err != nil {
+		return p, err
+	}
+	add := func(f purgeFile, recorded int64) {
+		f.Bytes = recorded
+		if s.Blobs == nil {
+			if info, err := os.Stat(filepath.Join(s.DataDir, filepath.FromSlash(f.Key))); err == nil {
+				f.Bytes = info.Size()
+			} else {
+				f.Bytes, f.Missing = 0, true
+				p.Missing++
+			}
+		}
+		p.FileBytes += f.Bytes
+		if listFiles {
+			p.Files = append(p.Files, f)
+		}
+	}
+	// The rows are one per image, ordered by event
+	var lastEvent int64
+	for _, r := range rows {
+		if p.Events == 0 || r.ID != lastEvent {
+			lastEvent = r.ID
+			p.Events++
+			p.DBBytes += r.JsonBytes
+			if r.JsonFilename != nil && *r.JsonFilename != "" {
+				add(purgeFile{Key: blobJSONPrefix + *r.JsonFilename, Kind: purgeFileJSON, EventID: r.ID}, r.JsonBytes)
+			}
+		}
+		if r.ImageID == nil {
+			continue
+		}
+		p.Images++
+		p.DBBytes += r.ImageDbBytes
+		if r.DiskFilename != nil && *r.DiskFilename != "" {
+			add(purgeFile{Key: blobImagePrefix + *r.DiskFilename, Kind: purgeFileImage, EventID: r.ID}, *r.SizeBytes)
+		}
+		// Thumbnails are always cached in the data dir
+		thumbs, _ := filepath.Glob(filepath.Join(s.DataDir, "thumbs", fmt.Sprintf("%d-*.jpg", *r.ImageID)))
+		for _, t := range thumbs {
+			info, err := os.Stat(t)
+			if err != nil {
+				continue
+			}
+			p.ThumbBytes += info.Size()
+			if listFiles {
+				p.Files = append(p.Files, purgeFile{Key: "thumbs/" + filepath.Base(t), Kind: purgeFileThumb, EventID: r.ID, Bytes: info.Size()})
+			}
+		}
+	}
+	return p, nil
+}
+
+// HandlePurgePreview shows what purging an archive would delete, with the
+// file list
+func (s *Server) HandlePurgePreview(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	p, err := s.loadPurgePreview(r.Context(), id, true)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Warn("failed to load purge preview", "archive", id, "error", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	data := struct {
+		Hostname string
+		Preview  purgePreview
+		Files    []purgeFile
+		More     int // files not shown
+	}{
+		Hostname: s.Hostname,
+		Preview:  p,
+		Files:    p.Files,
+	}
+	if len(data.Files) > purgePageFiles {
+		data.Files, data.More = data.Files[:purgePageFiles], len(data.Files)-purgePageFiles
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "purge_preview.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandlePurgePreviewAPI returns what purging an archive would delete as
+// JSON. Pass ?files=1 for the list of files.
+func (s *Server) HandlePurgePreviewAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	listFiles := r.URL.Query().Get("files") == "1"
+	p, err := s.loadPurgePreview(r.Context(), id, listFiles)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.jsonError(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Warn("failed to load purge preview", "archive", id, "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
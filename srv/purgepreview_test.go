@@ -0,0 +1,92 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPurgePreview(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.DataDir
+	os.MkdirAll(filepath.Join(dir, "thumbs"), 0755)
+	var pic bytes.Buffer
+	png.Encode(&pic, image.NewGray(image.Rect(0, 0, 8, 8)))
+	ctx := context.Background()
+	const body = `{"plateUTF8":"PP1"}`
+	for range 2 {
+		req := newIngestRequest([]byte(body), "", []uploadedImage{{Filename: "plate.png", Data: pic.Bytes()}})
+		if _, err := s.ingestEvent(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('old')")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+	os.WriteFile(filepath.Join(dir, "thumbs", "1-320.jpg"), []byte("thumb"), 0644)
+	var gone string
+	s.DB.QueryRow("SELECT json_filename FROM events WHERE id = 2").Scan(&gone)
+	os.Remove(filepath.Join(dir, "json", gone))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/archive/1/purge-preview?files=1", nil)
+	r.SetPathValue("id", "1")
+	s.HandlePurgePreviewAPI(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var p purgePreview
+	json.Unmarshal(w.Body.Bytes(), &p)
+	if p.Events != 2 || p.Images != 2 || p.Trashed || p.Blocked != "" {
+		t.Errorf("preview: %+v", p)
+	}
+	perEvent := int64(len(body) + pic.Len())
+	if p.DBBytes != 2*perEvent || p.FileBytes != perEvent+int64(pic.Len()) || p.ThumbBytes != 5 {
+		t.Errorf("db %d, files %d, thumbs %d bytes", p.DBBytes, p.FileBytes, p.ThumbBytes)
+	}
+	// 2 JSON files, one of them missing, 2 images and the thumbnail
+	if p.Missing != 1 || len(p.Files) != 5 {
+		t.Errorf("%d missing, files %+v", p.Missing, p.Files)
+	}
+
+	// Nothing was deleted, and the page offers the purge only from the trash
+	var events int
+	s.DB.QueryRow("SELECT COUNT(*) FROM events").Scan(&events)
+	if _, err := os.Stat(filepath.Join(dir, "thumbs", "1-320.jpg")); err != nil || events != 2 {
+		t.Errorf("dry run deleted data: %d events, thumbnail %v", events, err)
+	}
+	page := func() string {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/archive/1/purge-preview", nil)
+		r.SetPathValue("id", "1")
+		s.HandlePurgePreview(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("page status %d: %s", w.Code, w.Body)
+		}
+		return w.Body.String()
+	}
+	if body := page(); strings.Contains(body, `action="/archive/1/purge"`) || !strings.Contains(body, "missing") {
+		t.Errorf("page before trashing: %s", body)
+	}
+	if err := s.trashArchive(ctx, 1, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if body := page(); !strings.Contains(body, `action="/archive/1/purge"`) {
+		t.Errorf("page in trash has no purge button: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/api/archive/9/purge-preview", nil)
+	r.SetPathValue("id", "9")
+	s.HandlePurgePreviewAPI(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown archive: %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,487 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Reviewers can get browser notifications when a session ends, an export
+// job finishes or an archive is assigned to them for review, without
+// keeping the dashboard open. This is Web Push: the dashboard's 🔔 button
+// registers /static/push-sw.js and subscribes the browser with its push
+// service (Google, Mozilla, Apple), and the server sends each notification
+// to that service encrypted for the browser (RFC 8291), identified by the
+// VAPID key given with -push-key (RFC 8292). Browsers only offer push on
+// pages served over HTTPS or from localhost.
+//
+// A subscription belongs to the signed-in user and picks topics: "session",
+// "export", "camera" and "accuracy" notifications go to every subscriber of
+// the topic (an export only to the user who started it when logins are
+// required), "review" ones only to the assigned reviewer.
+
+const (
+	pushSession  = "session"  // the current session was archived
+	pushExport   = "export"   // an export job is done or failed
+	pushReview   = "review"   // an archive was assigned to the user for review
+	pushCamera   = "camera"   // a camera went offline or came back
+	pushAccuracy = "accuracy" // a reviewed archive's accuracy dropped below its camera's baseline
+)
+
+// pushTopics are the topics a subscription can pick, all by default
+var pushTopics = []string{pushSession, pushExport, pushReview, pushCamera, pushAccuracy}
+
+const (
+	pushTTL         = 24 * time.Hour // push services drop notifications undelivered this long
+	pushRecordSize  = 4096           // aes128gcm record size; payloads must fit one record
+	pushMaxFailures = 5              // subscriptions failing this often in a row are dropped
+)
+
+// errPushGone means the push service no longer knows the subscription
+var errPushGone = errors.New("subscription expired or unsubscribed")
+
+// PushSender sends Web Push notifications with a VAPID key
+type PushSender struct {
+	key       *ecdsa.PrivateKey
+	PublicKey string // uncompressed P-256 point, base64url, the browsers' applicationServerKey
+	Contact   string // mailto: or https: URL push services can reach the operator at
+
+	client *http.Client
+}
+
+// LoadPushSender reads a PEM PKCS#8 ECDSA P-256 private key. A missing file
+// is created with a new key; changing the key later invalidates every
+// browser subscription.
+func LoadPushSender(path, contact string) (*PushSender, error) {
+	if contact == "" {
+		host, _ := os.Hostname()
+		contact = "mailto:mmrapi@" + coalesce(host, "localhost")
+	}
+	if !strings.HasPrefix(contact, "mailto:") && !strings.HasPrefix(contact, "https:") {
+		return nil, fmt.Errorf("push contact must be a mailto: or https: URL")
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+			return nil, err
+		}
+		slog.Info("created push notification key", "path", path)
+		return newPushSender(key, contact)
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%s: not an ECDSA P-256 key", path)
+	}
+	return newPushSender(key, contact)
+}
+
+func newPushSender(key *ecdsa.PrivateKey, contact string) (*PushSender, error) {
+	ek, err := key.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	return &PushSender{
+		key:       key,
+		PublicKey: base64.RawURLEncoding.EncodeToString(ek.PublicKey().Bytes()),
+		Contact:   contact,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// vapid returns the Authorization header for a push service: a JWT for the
+// endpoint's origin signed with ES256, and the public key to check it with
+func (p *PushSender) vapid(endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(12 * time.Hour).Unix(),
+		"sub": p.Contact,
+	})
+	if err != nil {
+		return "", err
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	unsigned := b64([]byte(`{"typ":"JWT","alg":"ES256"}`)) + "." + b64(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, p.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return "vapid t=" + unsigned + "." + b64(sig) + ", k=" + p.PublicKey, nil
+}
+
+// pushEncrypt encrypts a payload for a browser's P-256 key and auth secret
+// as a single aes128gcm record (RFC 8291, RFC 8188)
+func pushEncrypt(uaPublic, authSecret, plaintext []byte) ([]byte, error) {
+	if len(plaintext)+1+16 > pushRecordSize {
+		return nil, fmt.Errorf("payload of %d bytes doesn't fit a push message", len(plaintext))
+	}
+	ua, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	as, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := as.ECDH(ua)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := as.PublicKey().Bytes()
+	prkKey, err := hkdf.Extract(sha256.New, shared, authSecret)
+	if err != nil {
+		return nil, err
+	}
+	ikm, err := hkdf.Expand(sha256.New, prkKey, "WebPush: info\x00"+string(uaPublic)+string(asPublic), 32)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// Header: salt, record size, key id (our public key); then the one
+	// record, its plaintext ended by the last-record delimiter
+	out := append(salt, binary.BigEndian.AppendUint32(nil, pushRecordSize)...)
+	out = append(out, byte(len(asPublic)))
+	out = append(out, asPublic...)
+	return gcm.Seal(out, nonce, slices.Concat(plaintext, []byte{2}), nil), nil
+}
+
+// send delivers a payload to one subscription
+func (p *PushSender) send(ctx context.Context, sub dbgen.PushSubscription, payload []byte) error {
+	uaPublic, err := decodeBase64URL(sub.P256dh)
+	if err != nil {
+		return fmt.Errorf("p256dh: %w", err)
+	}
+	authSecret, err := decodeBase64URL(sub.Auth)
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	body, err := pushEncrypt(uaPublic, authSecret, payload)
+	if err != nil {
+		return err
+	}
+	auth, err := p.vapid(sub.Endpoint, time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprint(int(pushTTL.Seconds())))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errPushGone
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("push service: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// decodeBase64URL decodes the base64url keys browsers hand out, with or
+// without padding
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// pushMessage is the notification payload push-sw.js shows
+type pushMessage struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url"`           // opened when the notification is clicked
+	Tag   string `json:"tag,omitempty"` // a newer notification with the same tag replaces the older
+}
+
+// notify sends a notification in the background to the subscribers of a
+// topic, only to the given user's browsers unless user is empty
+func (s *Server) notify(topic, user string, msg pushMessage) {
+	if s.Push == nil || s.ReadOnly {
+		return
+	}
+	s.background.Go(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		s.pushToSubscribers(ctx, topic, user, msg)
+	})
+}
+
+// pushToSubscribers sends a notification to every matching subscription
+// and returns how many push services accepted it
+func (s *Server) pushToSubscribers(ctx context.Context, topic, user string, msg pushMessage) int {
+	subs, err := dbgen.New(s.DB).GetPushSubscriptions(ctx)
+	if err != nil {
+		slog.Warn("failed to load push subscriptions", "error", err)
+		return 0
+	}
+	payload, _ := json.Marshal(msg)
+	sent := 0
+	for _, sub := range subs {
+		if !slices.Contains(strings.Split(sub.Topics, ","), topic) || (user != "" && deref(sub.Username) != user) {
+			continue
+		}
+		if s.pushTo(ctx, sub, payload) == nil {
+			sent++
+		}
+	}
+	return sent
+}
+
+// pushTo sends a payload to a subscription and keeps its record: gone
+// subscriptions and ones failing too often are removed
+func (s *Server) pushTo(ctx context.Context, sub dbgen.PushSubscription, payload []byte) error {
+	q := dbgen.New(s.DB)
+	err := s.Push.send(ctx, sub, payload)
+	if err == nil {
+		if err := q.RecordPushSent(ctx, dbgen.RecordPushSentParams{LastSentAt: ptr(time.Now()), ID: sub.ID}); err != nil {
+			slog.Warn("failed to record push notification", "error", err)
+		}
+		return nil
+	}
+	failures, ferr := q.RecordPushFailure(ctx, sub.ID)
+	if errors.Is(err, errPushGone) || ferr == nil && failures >= pushMaxFailures {
+		slog.Info("removing push subscription", "id", sub.ID, "user", deref(sub.Username), "error", err)
+		q.DeletePushSubscription(ctx, sub.Endpoint)
+	} else {
+		slog.Warn("push notification not delivered", "id", sub.ID, "user", deref(sub.Username), "error", err)
+	}
+	return err
+}
+
+// notifySessionEnded tells subscribers the current session was archived
+func (s *Server) notifySessionEnded(archiveID int64, name string, count int64) {
+	s.notify(pushSession, "", pushMessage{
+		Title: "Session ended",
+		Body:  fmt.Sprintf("%d events archived as %s", count, name),
+		URL:   fmt.Sprintf("/archive/%d", archiveID),
+		Tag:   fmt.Sprintf("session-%d", archiveID),
+	})
+}
+
+// notifyExportFinished tells the user who started an export job it is done
+// or failed; canceled jobs were stopped by someone looking at them
+func (s *Server) notifyExportFinished(j *exportJob, st jobStatus) {
+	var msg pushMessage
+	switch st.State {
+	case jobDone:
+		msg = pushMessage{Title: "Export ready", Body: fmt.Sprintf("%s (%d events)", j.filename, st.Total), URL: st.DownloadURL}
+	case jobFailed:
+		msg = pushMessage{Title: "Export failed", Body: j.filename + ": " + st.Error, URL: fmt.Sprintf("/archive/%d/compare", j.ArchiveID)}
+	default:
+		return
+	}
+	msg.Tag = "export-" + j.ID
+	s.notify(pushExport, j.user, msg)
+}
+
+// pushSubscriptionRequest is a browser PushSubscription as JSON, with the
+// topics it wants
+type pushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+	Topics []string `json:"topics"`
+}
+
+// check validates the subscription and fills in the default topics
+func (req *pushSubscriptionRequest) check() error {
+	u, err := url.Parse(req.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("endpoint must be an https URL")
+	}
+	key, err := decodeBase64URL(req.Keys.P256dh)
+	if err == nil {
+		_, err = ecdh.P256().NewPublicKey(key)
+	}
+	if err != nil {
+		return fmt.Errorf("keys.p256dh must be a base64url P-256 public key")
+	}
+	if secret, err := decodeBase64URL(req.Keys.Auth); err != nil || len(secret) != 16 {
+		return fmt.Errorf("keys.auth must be a base64url 16-byte secret")
+	}
+	if len(req.Topics) == 0 {
+		req.Topics = pushTopics
+	}
+	for _, t := range req.Topics {
+		if !slices.Contains(pushTopics, t) {
+			return fmt.Errorf("unknown topic %q (want %s)", t, strings.Join(pushTopics, ", "))
+		}
+	}
+	return nil
+}
+
+// HandlePushKeyAPI returns the VAPID public key browsers subscribe with
+func (s *Server) HandlePushKeyAPI(w http.ResponseWriter, r *http.Request) {
+	if s.Push == nil {
+		s.jsonError(w, "push notifications are not configured (-push-key)", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"public_key": s.Push.PublicKey, "topics": pushTopics})
+}
+
+// HandlePushSubscribeAPI stores a browser's push subscription for the
+// signed-in user; subscribing again updates its keys and topics
+func (s *Server) HandlePushSubscribeAPI(w http.ResponseWriter, r *http.Request) {
+	if s.Push == nil {
+		s.jsonError(w, "push notifications are not configured (-push-key)", http.StatusNotFound)
+		return
+	}
+	var req pushSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.check(); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user := sessionUser(r)
+	if err := dbgen.New(s.DB).UpsertPushSubscription(r.Context(), dbgen.UpsertPushSubscriptionParams{
+		Endpoint:  req.Endpoint,
+		P256dh:    req.Keys.P256dh,
+		Auth:      req.Keys.Auth,
+		Username:  ptrIfNotEmpty(user),
+		Topics:    strings.Join(req.Topics, ","),
+		CreatedAt: time.Now(),
+	}); err != nil {
+		slog.Warn("failed to store push subscription", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("push subscription added", "user", user, "topics", req.Topics)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"endpoint": req.Endpoint, "topics": req.Topics, "user": user})
+}
+
+// HandlePushUnsubscribeAPI removes the subscription with the endpoint in
+// the JSON body
+func (s *Server) HandlePushUnsubscribeAPI(w http.ResponseWriter, r *http.Request) {
+	var req pushSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Endpoint == "" {
+		s.jsonError(w, "endpoint is required", http.StatusBadRequest)
+		return
+	}
+	n, err := dbgen.New(s.DB).DeletePushSubscription(r.Context(), req.Endpoint)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		s.jsonError(w, "subscription not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePushTestAPI sends a test notification to the subscription with the
+// endpoint in the JSON body, so a browser can check the whole path
+func (s *Server) HandlePushTestAPI(w http.ResponseWriter, r *http.Request) {
+	if s.Push == nil {
+		s.jsonError(w, "push notifications are not configured (-push-key)", http.StatusNotFound)
+		return
+	}
+	var req pushSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Endpoint == "" {
+		s.jsonError(w, "endpoint is required", http.StatusBadRequest)
+		return
+	}
+	sub, err := dbgen.New(s.DB).GetPushSubscriptionByEndpoint(r.Context(), req.Endpoint)
+	if err != nil {
+		s.jsonError(w, "subscription not found", http.StatusNotFound)
+		return
+	}
+	payload, _ := json.Marshal(pushMessage{
+		Title: "Notifications are on",
+		Body:  fmt.Sprintf("%s will notify you about: %s", s.Hostname, strings.ReplaceAll(sub.Topics, ",", ", ")),
+		URL:   "/",
+		Tag:   "test",
+	})
+	if err := s.pushTo(r.Context(), sub, payload); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
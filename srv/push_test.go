@@ -0,0 +1,236 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// pushDecrypt is the browser side of pushEncrypt
+func pushDecrypt(t *testing.T, ua *ecdh.PrivateKey, authSecret, body []byte) []byte {
+	t.Helper()
+	salt, rs, idlen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	asPublic, record := body[21:21+idlen], body[21+idlen:]
+	if rs != pushRecordSize || idlen != 65 {
+		t.Fatalf("header: rs %d, idlen %d", rs, idlen)
+	}
+	as, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, _ := ua.ECDH(as)
+	prkKey, _ := hkdf.Extract(sha256.New, shared, authSecret)
+	ikm, _ := hkdf.Expand(sha256.New, prkKey, "WebPush: info\x00"+string(ua.PublicKey().Bytes())+string(asPublic), 32)
+	prk, _ := hkdf.Extract(sha256.New, ikm, salt)
+	cek, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plain, err := gcm.Open(nil, nonce, record, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain[len(plain)-1] != 2 {
+		t.Fatalf("no last-record delimiter: %q", plain)
+	}
+	return plain[:len(plain)-1]
+}
+
+// checkVAPID verifies the JWT of a VAPID Authorization header
+func checkVAPID(t *testing.T, header, aud string) {
+	t.Helper()
+	var token, key string
+	for _, part := range strings.Split(strings.TrimPrefix(header, "vapid "), ", ") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			token = v
+		case "k":
+			key = v
+		}
+	}
+	pub, _ := base64.RawURLEncoding.DecodeString(key)
+	pk, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), pub)
+	if err != nil {
+		t.Fatalf("bad k in %q: %v", header, err)
+	}
+	parts := strings.Split(token, ".")
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(pk, digest[:], r, s) {
+		t.Fatalf("VAPID signature doesn't verify")
+	}
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var c struct {
+		Aud string
+		Exp int64
+		Sub string
+	}
+	json.Unmarshal(claims, &c)
+	if c.Aud != aud || c.Sub != "mailto:ops@example.com" || c.Exp < time.Now().Unix() {
+		t.Errorf("claims %s, want aud %s", claims, aud)
+	}
+}
+
+func TestPushNotifications(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+	q.CreateUser(ctx, dbgen.CreateUserParams{Username: "alice", PasswordHash: "x", CreatedAt: time.Now()})
+
+	type delivery struct {
+		path, auth string
+		body       []byte
+	}
+	var mu sync.Mutex
+	var got []delivery
+	ps := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") == "" {
+			http.Error(w, "bad headers", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(r.Body)
+		mu.Lock()
+		got = append(got, delivery{r.URL.Path, r.Header.Get("Authorization"), buf.Bytes()})
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ps.Close()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	push, err := newPushSender(key, "mailto:ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	push.client = ps.Client()
+	s.Hostname = "lpr"
+	s.Push = push
+
+	// Three browsers: alice with every topic, bob only sessions, and one the
+	// push service has forgotten
+	ua, _ := ecdh.P256().GenerateKey(rand.Reader)
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+	subscribe := func(user, path string, topics []string) int {
+		req := pushSubscriptionRequest{Endpoint: ps.URL + path, Topics: topics}
+		req.Keys.P256dh = base64.RawURLEncoding.EncodeToString(ua.PublicKey().Bytes())
+		req.Keys.Auth = base64.RawURLEncoding.EncodeToString(authSecret)
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest("POST", "/api/push/subscriptions", bytes.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+		w := httptest.NewRecorder()
+		s.HandlePushSubscribeAPI(w, r)
+		return w.Code
+	}
+	if code := subscribe("alice", "/alice", nil); code != http.StatusCreated {
+		t.Fatalf("subscribe: %d", code)
+	}
+	subscribe("bob", "/bob", []string{pushSession})
+	subscribe("carol", "/gone", nil)
+	if code := subscribe("bob", "/bob", []string{"weather"}); code != http.StatusBadRequest {
+		t.Errorf("unknown topic: %d", code)
+	}
+
+	received := func() map[string]pushMessage {
+		t.Helper()
+		s.background.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		out := map[string]pushMessage{}
+		for _, d := range got {
+			checkVAPID(t, d.auth, ps.URL)
+			var msg pushMessage
+			if err := json.Unmarshal(pushDecrypt(t, ua, authSecret, d.body), &msg); err != nil {
+				t.Fatal(err)
+			}
+			out[d.path] = msg
+		}
+		got = nil
+		return out
+	}
+
+	// A session ending goes to everyone who picked the topic
+	s.notifySessionEnded(7, "2026-05-03 18:00:00", 42)
+	msgs := received()
+	if len(msgs) != 2 || msgs["/bob"].Body != "42 events archived as 2026-05-03 18:00:00" || msgs["/alice"].URL != "/archive/7" {
+		t.Errorf("session: %+v", msgs)
+	}
+	// The forgotten subscription is gone
+	if _, err := q.GetPushSubscriptionByEndpoint(ctx, ps.URL+"/gone"); err == nil {
+		t.Error("gone subscription kept")
+	}
+
+	// A review assignment only goes to the reviewer
+	archiveID, _ := q.CreateArchive(ctx, dbgen.CreateArchiveParams{Name: ptr("Morning"), EventCount: 3, CreatedAt: time.Now()})
+	form := url.Values{"username": {"alice"}}
+	r := httptest.NewRequest("POST", "/archive/1/reviewer", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetPathValue("id", "1")
+	r = r.WithContext(context.WithValue(r.Context(), userKey{}, "bob"))
+	w := httptest.NewRecorder()
+	s.HandleAssignReviewer(w, r)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("assign: %d %s", w.Code, w.Body)
+	}
+	msgs = received()
+	if m, ok := msgs["/alice"]; len(msgs) != 1 || !ok || m.Body != "Morning (3 events), assigned by bob" || m.URL != "/archive/1/compare" {
+		t.Errorf("review: %+v", msgs)
+	}
+	if rv, err := q.GetArchiveReviewer(ctx, archiveID); err != nil || rv.Username != "alice" || deref(rv.AssignedBy) != "bob" {
+		t.Errorf("reviewer: %+v %v", rv, err)
+	}
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/archive/1", nil)
+	r.SetPathValue("id", "1")
+	s.HandleArchive(w, r)
+	if !strings.Contains(w.Body.String(), "Assigned to alice for review by bob") {
+		t.Error("archive page doesn't show the reviewer")
+	}
+	if err := s.assignReviewer(ctx, archiveID, "mallory", "bob"); err == nil {
+		t.Error("assigned to an unknown user")
+	}
+
+	// An export job notifies whoever started it
+	s.notifyExportFinished(&exportJob{ID: "j1", ArchiveID: archiveID, filename: "compare.xlsx", user: "alice"},
+		jobStatus{State: jobDone, Total: 3, DownloadURL: "/api/jobs/j1/download"})
+	if msgs := received(); len(msgs) != 1 || msgs["/alice"].Title != "Export ready" || msgs["/alice"].URL != "/api/jobs/j1/download" {
+		t.Errorf("export: %+v", msgs)
+	}
+
+	// Unsubscribing stops notifications
+	w = httptest.NewRecorder()
+	s.HandlePushUnsubscribeAPI(w, httptest.NewRequest("DELETE", "/api/push/subscriptions", strings.NewReader(`{"endpoint":"`+ps.URL+`/bob"}`)))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("unsubscribe: %d", w.Code)
+	}
+	s.notifySessionEnded(8, "later", 1)
+	if msgs := received(); len(msgs) != 1 {
+		t.Errorf("after unsubscribe: %+v", msgs)
+	}
+}
@@ -0,0 +1,327 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// QuotaPolicy decides what happens to an incoming image that would exceed a quota
+type QuotaPolicy string
+
+const (
+	QuotaReject     QuotaPolicy = "reject"      // store the event without the image
+	QuotaDropOldest QuotaPolicy = "drop_oldest" // delete the oldest images in scope to make room
+	QuotaAlert      QuotaPolicy = "alert"       // keep the image and log a warning
+)
+
+// QuotaRule limits image storage in gigabytes
+type QuotaRule struct {
+	GB     float64     `json:"gb"`
+	Policy QuotaPolicy `json:"policy"`
+}
+
+func (r QuotaRule) limit() int64 { return int64(r.GB * (1 << 30)) }
+
+// QuotaConfig holds the storage quotas enforced at ingest. Total covers the
+// whole instance (one project per instance); Camera is the default for
+// every camera and Cameras overrides it by serial or sensor provider ID.
+type QuotaConfig struct {
+	Total   *QuotaRule           `json:"total"`
+	Camera  *QuotaRule           `json:"camera"`
+	Cameras map[string]QuotaRule `json:"cameras"`
+
+	mu     sync.Mutex
+	alerts map[string]time.Time // scope → last warning
+	used   imageBytes
+}
+
+// imageBytes is the running size of the stored images, in total and per
+// camera. It is summed from the images table once and then kept up to date
+// by admitImage and the images it drops, so admitting an image doesn't scan
+// the table. Code that stores or deletes images otherwise calls
+// resetQuotaUsage to have it summed again.
+type imageBytes struct {
+	mu      sync.Mutex // held across admitting an image
+	loaded  bool
+	total   int64
+	cameras map[string]int64
+}
+
+func (u *imageBytes) load(ctx context.Context, q *dbgen.Queries) error {
+	if u.loaded {
+		return nil
+	}
+	total, err := q.GetImageBytes(ctx)
+	if err != nil {
+		return err
+	}
+	rows, err := q.GetImageBytesByCamera(ctx)
+	if err != nil {
+		return err
+	}
+	u.total, u.cameras = total, make(map[string]int64, len(rows))
+	for _, row := range rows {
+		u.cameras[row.Camera] = row.Bytes
+	}
+	u.loaded = true
+	return nil
+}
+
+func (u *imageBytes) add(camera string, n int64) {
+	u.total += n
+	u.cameras[camera] += n
+}
+
+// resetQuotaUsage has the image sizes summed again before the next image is
+// admitted, after images were stored or deleted without admitImage
+func (s *Server) resetQuotaUsage() {
+	if s.Quotas == nil {
+		return
+	}
+	s.Quotas.used.mu.Lock()
+	defer s.Quotas.used.mu.Unlock()
+	s.Quotas.used.loaded = false
+}
+
+// quotaAlertInterval rate-limits warnings for the alert policy
+const quotaAlertInterval = 10 * time.Minute
+
+// LoadQuotaConfig reads a quota configuration file
+func LoadQuotaConfig(path string) (*QuotaConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg QuotaConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	check := func(name string, r *QuotaRule) error {
+		if r == nil {
+			return nil
+		}
+		if r.GB <= 0 {
+			return fmt.Errorf("%s: gb must be positive", name)
+		}
+		switch r.Policy {
+		case "":
+			r.Policy = QuotaReject
+		case QuotaReject, QuotaDropOldest, QuotaAlert:
+		default:
+			return fmt.Errorf("%s: unknown policy %q (want reject, drop_oldest or alert)", name, r.Policy)
+		}
+		return nil
+	}
+	if err := check("total", cfg.Total); err != nil {
+		return nil, err
+	}
+	if err := check("camera", cfg.Camera); err != nil {
+		return nil, err
+	}
+	for name, r := range cfg.Cameras {
+		if err := check("camera "+name, &r); err != nil {
+			return nil, err
+		}
+		cfg.Cameras[name] = r
+	}
+	cfg.alerts = make(map[string]time.Time)
+	return &cfg, nil
+}
+
+// cameraRule returns the quota for a camera, if any
+func (c *QuotaConfig) cameraRule(camera string) *QuotaRule {
+	if c == nil || camera == "" {
+		return nil
+	}
+	if r, ok := c.Cameras[camera]; ok {
+		return &r
+	}
+	return c.Camera
+}
+
+// alert logs a quota warning at most once per interval and scope
+func (c *QuotaConfig) alert(scope string, used, limit int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.alerts[scope]) < quotaAlertInterval {
+		return
+	}
+	c.alerts[scope] = time.Now()
+	slog.Warn("storage quota exceeded", "scope", scope, "used_bytes", used, "limit_bytes", limit)
+}
+
+func (c *QuotaConfig) lastAlert(scope string) *time.Time {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := c.alerts[scope]; ok {
+		return &t
+	}
+	return nil
+}
+
+// admitImage checks an incoming image of the given size against the camera
+// and total quotas and applies their policies. It reports whether the
+// image may be stored, and counts it as stored if so. q is the queries the
+// image will be stored with, so images dropped to make room go with the
+// caller's transaction; a caller whose transaction fails resets the usage.
+func (s *Server) admitImage(ctx context.Context, q *dbgen.Queries, camera string, size int64) bool {
+	if s.Quotas == nil {
+		return true
+	}
+	used := &s.Quotas.used
+	used.mu.Lock()
+	defer used.mu.Unlock()
+	if err := used.load(ctx, q); err != nil {
+		slog.Warn("failed to check storage quota", "error", err)
+		return true
+	}
+
+	if rule := s.Quotas.cameraRule(camera); rule != nil {
+		scope := "camera " + camera
+		if !s.applyQuota(ctx, q, scope, rule, used.cameras[camera], size, func(limit int64) ([]dbgen.GetOldestImagesRow, error) {
+			rows, err := q.GetOldestCameraImages(ctx, dbgen.GetOldestCameraImagesParams{Camera: &camera, Limit: limit})
+			out := make([]dbgen.GetOldestImagesRow, len(rows))
+			for i, r := range rows {
+				out[i] = dbgen.GetOldestImagesRow(r)
+			}
+			return out, err
+		}) {
+			return false
+		}
+	}
+
+	if rule := s.Quotas.Total; rule != nil {
+		if !s.applyQuota(ctx, q, "total", rule, used.total, size, func(limit int64) ([]dbgen.GetOldestImagesRow, error) {
+			return q.GetOldestImages(ctx, limit)
+		}) {
+			return false
+		}
+	}
+	used.add(camera, size)
+	return true
+}
+
+// applyQuota enforces one rule. oldest lists the oldest images in scope for drop_oldest.
+func (s *Server) applyQuota(ctx context.Context, q *dbgen.Queries, scope string, rule *QuotaRule, used, size int64, oldest func(int64) ([]dbgen.GetOldestImagesRow, error)) bool {
+	limit := rule.limit()
+	if used+size <= limit {
+		return true
+	}
+
+	switch rule.Policy {
+	case QuotaAlert:
+		s.Quotas.alert(scope, used+size, limit)
+		return true
+	case QuotaDropOldest:
+		if size > limit {
+			slog.Warn("image larger than storage quota rejected", "scope", scope, "size", size, "limit_bytes", limit)
+			return false
+		}
+		freed, err := s.dropOldestImages(ctx, q, used+size-limit, oldest)
+		if err != nil {
+			slog.Warn("failed to free storage", "scope", scope, "error", err)
+			return false
+		}
+		slog.Info("dropped oldest images for storage quota", "scope", scope, "freed_bytes", freed)
+		return used+size-freed <= limit
+	default:
+		slog.Warn("image rejected by storage quota", "scope", scope, "used_bytes", used, "size", size, "limit_bytes", limit)
+		return false
+	}
+}
+
+// dropOldestImages deletes images, oldest first, until at least need bytes
+// are freed. It is called by admitImage, with the usage locked.
+func (s *Server) dropOldestImages(ctx context.Context, q *dbgen.Queries, need int64, oldest func(int64) ([]dbgen.GetOldestImagesRow, error)) (int64, error) {
+	var freed int64
+	for freed < need {
+		rows, err := oldest(100)
+		if err != nil {
+			return freed, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, img := range rows {
+			if err := q.DeleteImage(ctx, img.ID); err != nil {
+				return freed, err
+			}
+			if img.DiskFilename != nil && *img.DiskFilename != "" {
+				s.removeBlob(ctx, blobImagePrefix+*img.DiskFilename)
+			}
+			s.removeThumbs(img.ID)
+			s.Quotas.used.add(img.Camera, -img.SizeBytes)
+			freed += img.SizeBytes
+			if freed >= need {
+				break
+			}
+		}
+	}
+	return freed, nil
+}
+
+type quotaUsage struct {
+	Camera     string      `json:"camera,omitempty"`
+	UsedBytes  int64       `json:"used_bytes"`
+	Images     int64       `json:"images,omitempty"`
+	LimitBytes int64       `json:"limit_bytes,omitempty"`
+	Policy     QuotaPolicy `json:"policy,omitempty"`
+	Over       bool        `json:"over"`
+	LastAlert  *time.Time  `json:"last_alert,omitempty"`
+}
+
+func (c *QuotaConfig) usage(scope string, rule *QuotaRule, used int64) quotaUsage {
+	u := quotaUsage{UsedBytes: used}
+	if rule != nil {
+		u.LimitBytes = rule.limit()
+		u.Policy = rule.Policy
+		u.Over = used > u.LimitBytes
+		u.LastAlert = c.lastAlert(scope)
+	}
+	return u
+}
+
+// HandleQuotaAPI reports image storage per camera against the configured quotas
+func (s *Server) HandleQuotaAPI(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	total, err := q.GetImageBytes(r.Context())
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	rows, err := q.GetImageBytesByCamera(r.Context())
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	var totalRule *QuotaRule
+	if s.Quotas != nil {
+		totalRule = s.Quotas.Total
+	}
+	cameras := make([]quotaUsage, 0, len(rows))
+	for _, row := range rows {
+		u := s.Quotas.usage("camera "+row.Camera, s.Quotas.cameraRule(row.Camera), row.Bytes)
+		u.Camera = row.Camera
+		u.Images = row.Images
+		cameras = append(cameras, u)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"enabled": s.Quotas != nil,
+		"total":   s.Quotas.usage("total", totalRule, total),
+		"cameras": cameras,
+	})
+}
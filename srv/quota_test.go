@@ -0,0 +1,111 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// quotaGB is a quota of n bytes
+func quotaGB(n int64) float64 { return float64(n) / (1 << 30) }
+
+func TestQuotaRunningTotal(t *testing.T) {
+	s := newTestServer(t)
+	s.Quotas = &QuotaConfig{Camera: &QuotaRule{GB: quotaGB(1000), Policy: QuotaReject}}
+	ctx := context.Background()
+	ingest := func(camera string, sizes ...int) ingestResult {
+		t.Helper()
+		var images []uploadedImage
+		for i, n := range sizes {
+			images = append(images, uploadedImage{Filename: fmt.Sprintf("plate%d.jpg", i), Data: bytes.Repeat([]byte{1}, n)})
+		}
+		res, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"AB123","sensorProviderID":"`+camera+`"}`), "", images))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	used := func() (int64, map[string]int64) {
+		s.Quotas.used.mu.Lock()
+		defer s.Quotas.used.mu.Unlock()
+		return s.Quotas.used.total, s.Quotas.used.cameras
+	}
+
+	if res := ingest("gate", 400, 400); res.Images != 2 || res.Rejected != 0 {
+		t.Fatalf("under the quota: %+v", res)
+	}
+	if res := ingest("gate", 400); res.Images != 0 || res.Rejected != 1 {
+		t.Errorf("over the quota: %+v", res)
+	}
+	if res := ingest("exit", 400); res.Images != 1 {
+		t.Errorf("other camera: %+v", res)
+	}
+	if total, cameras := used(); total != 1200 || cameras["gate"] != 800 || cameras["exit"] != 400 {
+		t.Errorf("running total %d, %v", total, cameras)
+	}
+
+	// The total is summed once, not per image: images deleted behind its
+	// back count until it is reset
+	s.DB.Exec("DELETE FROM images")
+	if res := ingest("gate", 400); res.Rejected != 1 {
+		t.Errorf("total summed again: %+v", res)
+	}
+	s.resetQuotaUsage()
+	if res := ingest("gate", 400); res.Images != 1 {
+		t.Errorf("after reset: %+v", res)
+	}
+
+	// A failed ingest doesn't leave its images counted
+	s.DB.Exec("CREATE TRIGGER fail_images BEFORE INSERT ON images BEGIN SELECT RAISE(ABORT, 'disk I/O error'); END")
+	if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"sensorProviderID":"gate"}`), "", []uploadedImage{{Filename: "p.jpg", Data: make([]byte, 100)}})); err == nil {
+		t.Fatal("ingest succeeded")
+	}
+	s.DB.Exec("DROP TRIGGER fail_images")
+	if res := ingest("gate", 600); res.Images != 1 {
+		t.Errorf("after a failed ingest: %+v", res)
+	}
+}
+
+func TestQuotaDropOldest(t *testing.T) {
+	s := newTestServer(t)
+	s.Quotas = &QuotaConfig{Total: &QuotaRule{GB: quotaGB(1000), Policy: QuotaDropOldest}}
+	ctx := context.Background()
+	for _, camera := range []string{"gate", "gate", "exit"} {
+		req := newIngestRequest([]byte(`{"sensorProviderID":"`+camera+`"}`), "", []uploadedImage{{Filename: "p.jpg", Data: make([]byte, 400)}})
+		if res, err := s.ingestEvent(ctx, req); err != nil || res.Images != 1 {
+			t.Fatalf("%+v, %v", res, err)
+		}
+	}
+	var n, size int64
+	s.DB.QueryRow("SELECT COUNT(*), SUM(size_bytes) FROM images").Scan(&n, &size)
+	if n != 2 || size != 800 {
+		t.Errorf("%d images, %d bytes kept", n, size)
+	}
+	if s.Quotas.used.total != 800 || s.Quotas.used.cameras["gate"] != 400 || s.Quotas.used.cameras["exit"] != 400 {
+		t.Errorf("running total %d, %v", s.Quotas.used.total, s.Quotas.used.cameras)
+	}
+}
+
+func TestQuotaConcurrentIngest(t *testing.T) {
+	s := newTestServer(t)
+	s.Quotas = &QuotaConfig{Total: &QuotaRule{GB: quotaGB(1000), Policy: QuotaReject}}
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := newIngestRequest([]byte(fmt.Sprintf(`{"carID":"%d"}`, i)), "", []uploadedImage{{Filename: "p.jpg", Data: make([]byte, 300)}})
+			if _, err := s.ingestEvent(context.Background(), req); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	var size int64
+	s.DB.QueryRow("SELECT SUM(size_bytes) FROM images").Scan(&size)
+	if size != 900 {
+		t.Errorf("%d bytes stored under a 1000 byte quota, want 900", size)
+	}
+}
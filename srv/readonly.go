@@ -0,0 +1,111 @@
+package srv
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db"
+)
+
+// Read-only mode serves the dashboard, APIs and exports from a copied
+// database without accepting ingest or any other mutation. Analysts use it
+// to review a snapshot on their laptops.
+
+// SetReadOnly reopens the database with writes disabled and switches the
+// server to read-only mode. New has already brought the schema of the copy
+// up to date, so this must be called after New.
+func (s *Server) SetReadOnly(dbPath string) error {
+	rdb, err := db.OpenReadOnly(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open db read-only: %w", err)
+	}
+	if s.DB != nil {
+		s.DB.Close()
+	}
+	s.DB = rdb
+	s.ReadOnly = true
+	s.Journal = false
+	return nil
+}
+
+// rejectMutations refuses every request that could change state while the
+// server is read-only
+func (s *Server) rejectMutations(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.ReadOnly {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if exportJobPath(r.URL.Path) {
+			// Export jobs only read the database
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path == "/api/test/parse" || r.URL.Path == "/api/validate" {
+			// Dry-run uploads are parsed, not stored
+			next.ServeHTTP(w, r)
+			return
+		}
+		const msg = "server is in read-only mode"
+		if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+			s.jsonError(w, msg, http.StatusForbidden)
+			return
+		}
+		http.Error(w, msg, http.StatusForbidden)
+	})
+}
+
+// exportJobPath reports whether a POST starts or cancels an export job
+func exportJobPath(path string) bool {
+	return strings.HasSuffix(path, "/compare/export/jobs") ||
+		strings.HasPrefix(path, "/api/jobs/") && strings.HasSuffix(path, "/cancel")
+}
+
+// templateFuncs are available to every page template
+func (s *Server) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"readOnly":  func() bool { return s.ReadOnly },
+		"demo":      func() bool { return s.Demo },
+		"hasPanels": func() bool { return s.Panels != nil && len(s.Panels.Panels) > 0 },
+		"hasPush":   func() bool { return s.Push != nil && !s.ReadOnly },
+		"late":      s.isLate,
+		"lateAfterMs": func() int64 {
+			return s.LateAfter.Milliseconds()
+		},
+		"delay": func(ms *int64) string {
+			if ms == nil {
+				return ""
+			}
+			return (time.Duration(*ms) * time.Millisecond).Round(time.Second).String()
+		},
+		"quality": func(v *float64) string {
+			if v == nil {
+				return ""
+			}
+			return strconv.FormatFloat(*v, 'f', 0, 64)
+		},
+		"seconds": func(ms int64) string {
+			return strconv.FormatFloat(float64(ms)/1000, 'f', 1, 64) + "s"
+		},
+		"bytes": func(n int64) string {
+			const unit = 1024
+			if n < unit {
+				return strconv.FormatInt(n, 10) + " B"
+			}
+			v, i := float64(n)/unit, 0
+			for ; v >= unit && i < 4; i++ {
+				v /= unit
+			}
+			return strconv.FormatFloat(v, 'f', 1, 64) + " " + "KMGTP"[i:i+1] + "iB"
+		},
+	}
+}
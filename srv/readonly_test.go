@@ -0,0 +1,67 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	s := newTestServer(t)
+	if _, err := s.ingestEvent(context.Background(), newIngestRequest([]byte(`{"plateUTF8":"RO1"}`), "", nil)); err != nil {
+		t.Fatal(err)
+	}
+	s.background.Wait()
+	if err := s.SetReadOnly(filepath.Join(s.DataDir, "db.sqlite3")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.DB.Close() })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.HandleRoot)
+	mux.HandleFunc("GET /api/event/{id}", s.HandleEventAPI)
+	mux.HandleFunc("POST /api", s.HandleAPI)
+	mux.HandleFunc("POST /api/validate", s.HandleValidate)
+	mux.HandleFunc("POST /clean", s.HandleClean)
+	h := s.rejectMutations(mux)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("GET", "/", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "🔒 Read-only") || strings.Contains(w.Body.String(), `action="/clean"`) {
+		t.Errorf("dashboard %d:\n%s", w.Code, w.Body)
+	}
+	if w := do("GET", "/api/event/1", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "RO1") {
+		t.Errorf("event API %d: %s", w.Code, w.Body)
+	}
+	// Dry runs are parsed, not stored
+	if w := do("POST", "/api/validate", `{"plateUTF8":"RO2"}`); w.Code != http.StatusOK {
+		t.Errorf("validate %d: %s", w.Code, w.Body)
+	}
+
+	if w := do("POST", "/api", `{"plateUTF8":"RO3"}`); w.Code != http.StatusForbidden || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("ingest %d: %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/clean", ""); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "read-only") {
+		t.Errorf("clean %d: %s", w.Code, w.Body)
+	}
+
+	// The connection itself refuses writes, whatever gets past the routes
+	if _, err := s.DB.Exec("DELETE FROM events"); err == nil {
+		t.Error("database accepts writes")
+	}
+	var n int
+	s.DB.QueryRow("SELECT COUNT(*) FROM events").Scan(&n)
+	if n != 1 {
+		t.Errorf("%d events, want 1", n)
+	}
+}
@@ -0,0 +1,530 @@
+package srv
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replicaTarget stores replica objects under slash-separated names
+type replicaTarget interface {
+	put(ctx context.Context, name string, r io.Reader, size int64) error
+	get(ctx context.Context, name string) (io.ReadCloser, error)
+	list(ctx context.Context, prefix string) ([]replicaObject, error)
+	remove(ctx context.Context, name string) error
+}
+
+// replicaObject is a listed replica object
+type replicaObject struct {
+	Name string
+	Size int64
+}
+
+// objectNames returns the names of objects, sorted
+func objectNames(objects []replicaObject) []string {
+	names := make([]string, len(objects))
+	for i, o := range objects {
+		names[i] = o.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dirTarget replicates to a directory, e.g. a USB disk or network mount
+type dirTarget struct {
+	root string
+}
+
+func (d dirTarget) put(ctx context.Context, name string, r io.Reader, size int64) error {
+	dst := filepath.Join(d.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a partial object
+	tmp := dst + ".tmp"
+	if err := writeFile(tmp, r); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func (d dirTarget) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(d.root, filepath.FromSlash(name)))
+}
+
+func (d dirTarget) list(ctx context.Context, prefix string) ([]replicaObject, error) {
+	var objects []replicaObject
+	err := filepath.WalkDir(filepath.Join(d.root, filepath.FromSlash(prefix)), func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if e.IsDir() || strings.HasSuffix(p, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.root, p)
+		if err != nil {
+			return err
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, replicaObject{Name: filepath.ToSlash(rel), Size: info.Size()})
+		return nil
+	})
+	return objects, err
+}
+
+func (d dirTarget) remove(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(d.root, filepath.FromSlash(name)))
+}
+
+// s3Target replicates to a bucket under a key prefix
+type s3Target struct {
+	client *s3Client
+	prefix string
+}
+
+func (t s3Target) key(name string) string {
+	if t.prefix == "" {
+		return name
+	}
+	return t.prefix + "/" + name
+}
+
+func (t s3Target) put(ctx context.Context, name string, r io.Reader, size int64) error {
+	return t.client.put(ctx, t.key(name), r, size)
+}
+
+func (t s3Target) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return t.client.get(ctx, t.key(name))
+}
+
+func (t s3Target) list(ctx context.Context, prefix string) ([]replicaObject, error) {
+	objects, err := t.client.list(ctx, t.key(prefix))
+	if err != nil {
+		return nil, err
+	}
+	for i, o := range objects {
+		if t.prefix != "" {
+			objects[i].Name = strings.TrimPrefix(o.Name, t.prefix+"/")
+		}
+	}
+	return objects, nil
+}
+
+func (t s3Target) remove(ctx context.Context, name string) error {
+	return t.client.remove(ctx, t.key(name))
+}
+
+func openReplicaTarget(dest string) (replicaTarget, error) {
+	if strings.HasPrefix(dest, "s3://") {
+		client, prefix, err := newS3Client(dest)
+		if err != nil {
+			return nil, err
+		}
+		return s3Target{client: client, prefix: prefix}, nil
+	}
+	if dest == "" {
+		return nil, errors.New("no replica destination")
+	}
+	return dirTarget{root: dest}, nil
+}
+
+// Replica layout:
+//
+//	db/<UTC timestamp>.sqlite3.gz   consistent database snapshots, newest last
+//	data/json/..., data/images/...  copies of the data directory
+const (
+	replicaDBPrefix   = "db/"
+	replicaDataPrefix = "data/"
+)
+
+// Replicator keeps a warm standby copy of the database and data directory
+// as periodic snapshots, not a continuous log: every interval it uploads a
+// snapshot of the database if it changed, keeps the newest Keep snapshots,
+// and copies data files that are new or changed since they were copied. A
+// restore can lose up to one interval of events.
+type Replicator struct {
+	Dest     string
+	Interval time.Duration
+	Keep     int
+
+	target replicaTarget
+	dbPath string
+
+	mu           sync.Mutex
+	lastSync     time.Time
+	lastErr      error
+	lastSnapshot string
+	dbMark       string
+	files        map[string]replicaFile // data files present in the replica
+}
+
+// replicaFile is the version of a data file in the replica. modTime is zero
+// for a file only known from listing the replica after a restart.
+type replicaFile struct {
+	size    int64
+	modTime time.Time
+}
+
+func NewReplicator(dest, dbPath string) (*Replicator, error) {
+	target, err := openReplicaTarget(dest)
+	if err != nil {
+		return nil, err
+	}
+	return &Replicator{
+		Dest:     dest,
+		Interval: 5 * time.Minute,
+		Keep:     3,
+		target:   target,
+		dbPath:   dbPath,
+	}, nil
+}
+
+// changeMark identifies the database state cheaply from file sizes and times
+func (rp *Replicator) changeMark() string {
+	var mark strings.Builder
+	for _, p := range []string{rp.dbPath, rp.dbPath + "-wal"} {
+		if fi, err := os.Stat(p); err == nil {
+			fmt.Fprintf(&mark, "%d:%d;", fi.Size(), fi.ModTime().UnixNano())
+		}
+	}
+	return mark.String()
+}
+
+// runReplication replicates until ctx is done
+func (s *Server) runReplication(ctx context.Context) {
+	rp := s.Replica
+	slog.Info("replication enabled", "dest", rp.Dest, "interval", rp.Interval)
+	for {
+		if err := s.replicateOnce(ctx); err != nil {
+			slog.Warn("replication failed", "dest", rp.Dest, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rp.Interval):
+		}
+	}
+}
+
+// replicateOnce uploads a database snapshot (if changed) and new data files
+func (s *Server) replicateOnce(ctx context.Context) error {
+	rp := s.Replica
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	err := s.replicateDB(ctx)
+	if err == nil {
+		err = s.replicateData(ctx)
+	}
+	rp.lastSync = time.Now()
+	rp.lastErr = err
+	return err
+}
+
+func (s *Server) replicateDB(ctx context.Context) error {
+	rp := s.Replica
+	mark := rp.changeMark()
+	if mark != "" && mark == rp.dbMark {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "mmrapi-snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// VACUUM INTO writes a consistent, compacted copy while the server keeps running
+	snapshot := filepath.Join(dir, "db.sqlite3")
+	if _, err := s.DB.ExecContext(ctx, "VACUUM INTO ?", snapshot); err != nil {
+		return fmt.Errorf("snapshot database: %w", err)
+	}
+	gzPath := snapshot + ".gz"
+	if err := gzipFile(snapshot, gzPath); err != nil {
+		return fmt.Errorf("compress snapshot: %w", err)
+	}
+
+	name := replicaDBPrefix + time.Now().UTC().Format("20060102T150405Z") + ".sqlite3.gz"
+	if err := putFile(ctx, rp.target, name, gzPath); err != nil {
+		return fmt.Errorf("upload snapshot: %w", err)
+	}
+	rp.dbMark = mark
+	rp.lastSnapshot = name
+	slog.Info("database snapshot replicated", "name", name)
+
+	// Prune old snapshots
+	objects, err := rp.target.list(ctx, replicaDBPrefix)
+	if err != nil {
+		return fmt.Errorf("list snapshots: %w", err)
+	}
+	names := objectNames(objects)
+	for len(names) > max(rp.Keep, 1) {
+		if err := rp.target.remove(ctx, names[0]); err != nil {
+			slog.Warn("failed to prune snapshot", "name", names[0], "error", err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// isDatabaseFile reports whether p is the live database or one of its
+// WAL, shared-memory or journal files, which sit in the data directory when
+// the database does. Copying them while in use gives a torn copy.
+func (rp *Replicator) isDatabaseFile(p string) bool {
+	db, err := filepath.Abs(rp.dbPath)
+	if err != nil {
+		return false
+	}
+	p, err = filepath.Abs(p)
+	if err != nil {
+		return false
+	}
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		if p == db+suffix {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) replicateData(ctx context.Context) error {
+	rp := s.Replica
+	if rp.files == nil {
+		objects, err := rp.target.list(ctx, replicaDataPrefix)
+		if err != nil {
+			return fmt.Errorf("list replica data: %w", err)
+		}
+		rp.files = make(map[string]replicaFile, len(objects))
+		for _, o := range objects {
+			rp.files[o.Name] = replicaFile{size: o.Size}
+		}
+	}
+
+	copied, updated := 0, 0
+	err := filepath.WalkDir(s.DataDir, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			if p == s.journalDir() {
+				return filepath.SkipDir // transient
+			}
+			if p == filepath.Join(s.DataDir, "thumbs") {
+				return filepath.SkipDir // regenerated on demand
+			}
+			if p == s.spoolDir() {
+				return filepath.SkipDir // transient
+			}
+			return nil
+		}
+		if rp.isDatabaseFile(p) {
+			return nil // covered by the snapshots
+		}
+		rel, err := filepath.Rel(s.DataDir, p)
+		if err != nil {
+			return err
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		name := replicaDataPrefix + filepath.ToSlash(rel)
+		have, ok := rp.files[name]
+		if ok && have.size == info.Size() && (have.modTime.IsZero() || have.modTime.Equal(info.ModTime())) {
+			// A file listed after a restart is taken to be current when
+			// its size matches; later changes are caught by time
+			rp.files[name] = replicaFile{size: info.Size(), modTime: info.ModTime()}
+			return nil
+		}
+		if err := putFile(ctx, rp.target, name, p); err != nil {
+			return err
+		}
+		rp.files[name] = replicaFile{size: info.Size(), modTime: info.ModTime()}
+		if ok {
+			updated++
+		} else {
+			copied++
+		}
+		return nil
+	})
+	if copied+updated > 0 {
+		slog.Info("data files replicated", "new", copied, "changed", updated)
+	}
+	return err
+}
+
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func putFile(ctx context.Context, target replicaTarget, name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return target.put(ctx, name, f, fi.Size())
+}
+
+// RestoreReplica restores the newest database snapshot (or the one named)
+// from dest to dbPath. It refuses to overwrite an existing database.
+// Data files are restored separately with RestoreReplicaData once the
+// server, and so its data directory, exists.
+func RestoreReplica(ctx context.Context, dest, snapshot, dbPath string) (string, error) {
+	target, err := openReplicaTarget(dest)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dbPath); err == nil {
+		return "", fmt.Errorf("%s already exists; move it away before restoring", dbPath)
+	}
+
+	if snapshot == "" {
+		objects, err := target.list(ctx, replicaDBPrefix)
+		if err != nil {
+			return "", fmt.Errorf("list snapshots: %w", err)
+		}
+		if len(objects) == 0 {
+			return "", errors.New("no database snapshots in replica")
+		}
+		names := objectNames(objects)
+		snapshot = names[len(names)-1]
+	} else if !strings.HasPrefix(snapshot, replicaDBPrefix) {
+		snapshot = replicaDBPrefix + snapshot
+	}
+
+	rc, err := target.get(ctx, snapshot)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		return "", fmt.Errorf("read snapshot: %w", err)
+	}
+	tmp := dbPath + ".restore"
+	if err := writeFile(tmp, gz); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		return "", err
+	}
+	return snapshot, nil
+}
+
+// RestoreReplicaData copies replicated data files missing from dataDir
+func RestoreReplicaData(ctx context.Context, dest, dataDir string) (int, error) {
+	target, err := openReplicaTarget(dest)
+	if err != nil {
+		return 0, err
+	}
+	objects, err := target.list(ctx, replicaDataPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("list replica data: %w", err)
+	}
+	restored := 0
+	for _, name := range objectNames(objects) {
+		rel := path.Clean(strings.TrimPrefix(name, replicaDataPrefix))
+		if rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		dst := filepath.Join(dataDir, filepath.FromSlash(rel))
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return restored, err
+		}
+		rc, err := target.get(ctx, name)
+		if err != nil {
+			return restored, err
+		}
+		err = writeFile(dst, rc)
+		rc.Close()
+		if err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// HandleReplicaStatus reports the state of replication
+func (s *Server) HandleReplicaStatus(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]any{"enabled": s.Replica != nil}
+	if rp := s.Replica; rp != nil {
+		rp.mu.Lock()
+		resp["dest"] = rp.Dest
+		resp["interval"] = rp.Interval.String()
+		resp["keep"] = rp.Keep
+		resp["last_snapshot"] = rp.lastSnapshot
+		resp["files"] = len(rp.files)
+		if !rp.lastSync.IsZero() {
+			resp["last_sync"] = rp.lastSync
+		}
+		if rp.lastErr != nil {
+			resp["error"] = rp.lastErr.Error()
+		}
+		rp.mu.Unlock()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleReplicaSync replicates immediately, e.g. before swapping an SD card
+func (s *Server) HandleReplicaSync(w http.ResponseWriter, r *http.Request) {
+	if s.Replica == nil {
+		s.jsonError(w, "replication is not configured", http.StatusNotFound)
+		return
+	}
+	if err := s.replicateOnce(r.Context()); err != nil {
+		slog.Warn("replication failed", "error", err)
+		s.jsonError(w, "replication failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "message": "replica up to date"})
+}
@@ -0,0 +1,139 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db"
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestReplicate(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"AB123"}`), "", []uploadedImage{{Filename: "plate.png", Data: pngOf(100, 20)}})); err != nil {
+		t.Fatal(err)
+	}
+	s.background.Wait()
+	for _, dir := range []string{s.journalDir(), s.spoolDir()} {
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "transient"), []byte("x"), 0644)
+	}
+	dest := t.TempDir()
+	newReplicator := func() {
+		t.Helper()
+		rp, err := NewReplicator(dest, filepath.Join(s.DataDir, "db.sqlite3"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Replica = rp
+	}
+	newReplicator()
+	if err := s.replicateOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	objects, _ := s.Replica.target.list(ctx, "")
+	var snapshots, images int
+	for _, o := range objects {
+		switch filepath.Dir(o.Name) {
+		case "db":
+			snapshots++
+		case "data/images":
+			images++
+		case "data/journal", "data/spool":
+			t.Errorf("transient file replicated: %s", o.Name)
+		}
+	}
+	if snapshots != 1 || images != 1 {
+		t.Fatalf("%d snapshots, %d images in %v", snapshots, images, objects)
+	}
+
+	// Changed files are sent again, also when their size stays the same
+	file := filepath.Join(s.DataDir, "json", "config.json")
+	replicated := filepath.Join(dest, "data", "json", "config.json")
+	write := func(content string, at time.Time) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(file, at, at)
+	}
+	check := func(want string) {
+		t.Helper()
+		if err := s.replicateOnce(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(replicated); string(got) != want {
+			t.Errorf("replica has %q, want %q", got, want)
+		}
+	}
+	now := time.Now()
+	write("v1", now.Add(-time.Hour))
+	check("v1")
+	write("v2", now.Add(-time.Minute))
+	check("v2")
+	write("version 3", now)
+	check("version 3")
+
+	// After a restart the replica's listing is trusted for files of the
+	// same size, and sizes catch what changed meanwhile
+	newReplicator()
+	os.WriteFile(replicated, []byte("replica 3"), 0644)
+	check("replica 3")
+	write("version 4 ", now)
+	check("version 4 ")
+	write("version 5 ", now.Add(time.Minute))
+	check("version 5 ")
+}
+
+func TestReplicaRestore(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	res, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"AB123"}`), "", []uploadedImage{{Filename: "plate.png", Data: pngOf(100, 20)}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.background.Wait()
+	dest := t.TempDir()
+	if s.Replica, err = NewReplicator(dest, filepath.Join(s.DataDir, "db.sqlite3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.replicateOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new SD card: restore the database, then the data files
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "db.sqlite3")
+	if _, err := RestoreReplica(ctx, dest, "", dbPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreReplica(ctx, dest, "", dbPath); err == nil {
+		t.Error("restore overwrote an existing database")
+	}
+	if n, err := RestoreReplicaData(ctx, dest, dir); err != nil || n != 2 {
+		t.Fatalf("restored %d data files: %v", n, err)
+	}
+	sqlDB, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	restored := &Server{DB: sqlDB, DataDir: dir}
+	imgs, err := dbgen.New(sqlDB).GetImagesByEventID(ctx, res.ID)
+	if err != nil || len(imgs) != 1 {
+		t.Fatalf("restored images %v: %v", imgs, err)
+	}
+	r := httptest.NewRequest("GET", "/image/1", nil)
+	r.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	restored.HandleImage(w, r)
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("restored image: %d", w.Code)
+	}
+}
@@ -0,0 +1,445 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Deleting an archive moves it to the trash (/trash), where it can be
+// restored or purged; with -trash-retention, trashed archives are purged
+// automatically after that long. A legal hold keeps an archive through all
+// of this, and its events are skipped by drop_oldest quota pruning and the
+// privacy policy, until the hold is released. Holds, deletions, restores, purges and blocked
+// attempts are written to the archive's audit log.
+
+// DefaultTrashTTL is how long deleted archives stay in the trash
+const DefaultTrashTTL = 30 * 24 * time.Hour
+
+const trashSweepInterval = time.Hour
+
+// trashImageLimit is how many deleted images the trash page lists
+const trashImageLimit = 200
+
+// Audit log actions
+const (
+	auditHold    = "hold"
+	auditRelease = "release"
+	auditDelete  = "delete"
+	auditRestore = "restore"
+	auditPurge   = "purge"
+	auditCompact = "compact"
+	auditReview  = "review"  // reviewer assigned or unassigned
+	auditBlocked = "blocked" // a deletion, purge or compaction refused because of a hold
+	auditImage   = "image"   // an image moved to another event or retagged
+)
+
+var errArchiveHeld = errors.New("archive is under legal hold")
+
+// auditArchive records an action on an archive
+func (s *Server) auditArchive(ctx context.Context, archiveID int64, action, detail, actor string) {
+	slog.Info("archive audit", "archive", archiveID, "action", action, "detail", detail, "actor", actor)
+	err := dbgen.New(s.DB).InsertArchiveAudit(ctx, dbgen.InsertArchiveAuditParams{
+		ArchiveID: archiveID,
+		Action:    action,
+		Detail:    ptrIfNotEmpty(detail),
+		Actor:     ptrIfNotEmpty(actor),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		slog.Error("failed to write archive audit log", "archive", archiveID, "action", action, "error", err)
+	}
+}
+
+// archiveHeld reports whether an archive is under legal hold
+func (s *Server) archiveHeld(ctx context.Context, id int64) (bool, error) {
+	_, err := dbgen.New(s.DB).GetArchiveHold(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// heldArchives returns the IDs of archives under legal hold
+func (s *Server) heldArchives(ctx context.Context) map[int64]bool {
+	ids, err := dbgen.New(s.DB).GetHeldArchiveIDs(ctx)
+	if err != nil {
+		slog.Warn("failed to list held archives", "error", err)
+	}
+	held := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		held[id] = true
+	}
+	return held
+}
+
+// trashArchive moves an archive to the trash
+func (s *Server) trashArchive(ctx context.Context, id int64, actor string) error {
+	if held, err := s.archiveHeld(ctx, id); err != nil || held {
+		if held {
+			s.auditArchive(ctx, id, auditBlocked, "delete refused", actor)
+			return errArchiveHeld
+		}
+		return err
+	}
+	if locked, err := s.archiveLocked(ctx, id); err != nil || locked {
+		if locked {
+			return errArchiveLocked
+		}
+		return err
+	}
+	err := dbgen.New(s.DB).TrashArchive(ctx, dbgen.TrashArchiveParams{ArchiveID: id, DeletedAt: time.Now(), DeletedBy: ptrIfNotEmpty(actor)})
+	if err != nil {
+		return err
+	}
+	s.auditArchive(ctx, id, auditDelete, "moved to trash", actor)
+	return nil
+}
+
+// purgeArchive permanently deletes an archive with its events and files
+func (s *Server) purgeArchive(ctx context.Context, id int64, actor string) error {
+	if held, err := s.archiveHeld(ctx, id); err != nil || held {
+		if held {
+			s.auditArchive(ctx, id, auditBlocked, "purge refused", actor)
+			return errArchiveHeld
+		}
+		return err
+	}
+	if locked, err := s.archiveLocked(ctx, id); err != nil || locked {
+		if locked {
+			return errArchiveLocked
+		}
+		return err
+	}
+
+	q := dbgen.New(s.DB)
+
+	// Get files to delete
+	files, err := q.GetArchivedEventFiles(ctx, &id)
+	if err != nil {
+		slog.Warn("failed to get archive files", "error", err)
+	}
+
+	// Delete files
+	for _, f := range files {
+		if f.JsonFilename != nil && *f.JsonFilename != "" {
+			s.removeBlob(ctx, blobJSONPrefix+*f.JsonFilename)
+		}
+		if f.DiskFilename != nil && *f.DiskFilename != "" {
+			s.removeBlob(ctx, blobImagePrefix+*f.DiskFilename)
+		}
+		if f.ImageID != nil {
+			s.removeThumbs(*f.ImageID)
+		}
+	}
+
+	// Delete from database
+	if err := q.DeleteArchiveMessages(ctx, &id); err != nil {
+		slog.Warn("failed to delete archive event messages", "error", err)
+	}
+	if err := q.DeleteArchiveImages(ctx, &id); err != nil {
+		slog.Warn("failed to delete archive images", "error", err)
+	}
+	if err := q.DeleteArchiveEvents(ctx, &id); err != nil {
+		slog.Warn("failed to delete archive events", "error", err)
+	}
+	if err := q.DeleteArchiveTrash(ctx, id); err != nil {
+		slog.Warn("failed to delete archive trash entry", "error", err)
+	}
+	if err := q.DeleteArchive(ctx, id); err != nil {
+		return err
+	}
+	s.resetQuotaUsage()
+	// The file list has a row per image
+	events := make(map[int64]bool)
+	for _, f := range files {
+		events[f.ID] = true
+	}
+	s.auditArchive(ctx, id, auditPurge, fmt.Sprintf("%d events deleted", len(events)), actor)
+	return nil
+}
+
+// runTrashRetention purges archives that have been in the trash longer than
+// TrashTTL
+func (s *Server) runTrashRetention(ctx context.Context) {
+	slog.Info("trash retention enabled", "retention", s.TrashTTL)
+	for {
+		s.purgeExpiredTrash(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(trashSweepInterval):
+		}
+	}
+}
+
+// purgeExpiredTrash purges the expired archives that aren't held
+func (s *Server) purgeExpiredTrash(ctx context.Context) {
+	ids, err := dbgen.New(s.DB).GetExpiredTrash(ctx, time.Now().Add(-s.TrashTTL))
+	if err != nil {
+		slog.Warn("failed to list expired trash", "error", err)
+		return
+	}
+	for _, id := range ids {
+		if err := s.purgeArchive(ctx, id, "retention"); err != nil {
+			slog.Warn("failed to purge expired archive", "archive", id, "error", err)
+		}
+	}
+}
+
+// writeArchiveError maps trash and hold errors to HTTP responses
+func (s *Server) writeArchiveError(w http.ResponseWriter, r *http.Request, err error) {
+	api := strings.HasPrefix(r.URL.Path, "/api/")
+	status, msg := http.StatusInternalServerError, "database error"
+	switch {
+	case errors.Is(err, errArchiveHeld):
+		status, msg = http.StatusConflict, "archive is under legal hold and can't be deleted"
+	case errors.Is(err, errArchiveLocked):
+		status, msg = http.StatusConflict, "archive is locked and can't be deleted"
+	default:
+		slog.Error("archive retention", "path", r.URL.Path, "error", err)
+	}
+	if api {
+		s.jsonError(w, msg, status)
+		return
+	}
+	http.Error(w, msg, status)
+}
+
+// HandleTrash lists deleted archives with restore and purge buttons
+func (s *Server) HandleTrash(w http.ResponseWriter, r *http.Request) {
+	archives, err := dbgen.New(s.DB).GetTrashedArchives(r.Context())
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	retention := s.TrashTTL.String()
+	if s.TrashTTL%(24*time.Hour) == 0 {
+		retention = fmt.Sprintf("%d days", s.TrashTTL/(24*time.Hour))
+	}
+	// Sizes come from the storage report's per-archive totals; the purge
+	// preview has the exact figures
+	sizes, err := dbgen.New(s.DB).GetStorageByArchive(r.Context())
+	if err != nil {
+		slog.Warn("failed to measure archives", "error", err)
+	}
+	images, err := dbgen.New(s.DB).GetDeletedImages(r.Context(), trashImageLimit)
+	if err != nil {
+		slog.Warn("failed to list deleted images", "error", err)
+	}
+	data := struct {
+		Hostname  string
+		Archives  []dbgen.GetTrashedArchivesRow
+		Frees     map[int64]int64 // bytes of database and files by archive
+		Retention string          // empty without automatic purging
+		Images    []dbgen.GetDeletedImagesRow
+	}{
+		Hostname: s.Hostname,
+		Archives: archives,
+		Frees:    make(map[int64]int64),
+		Images:   images,
+	}
+	for _, a := range sizes {
+		if a.ArchiveID != nil {
+			data.Frees[*a.ArchiveID] = a.DbBytes + a.FileBytes
+		}
+	}
+	if s.TrashTTL > 0 {
+		data.Retention = retention
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "trash.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleRestoreArchive takes an archive out of the trash
+func (s *Server) HandleRestoreArchive(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	n, err := dbgen.New(s.DB).RestoreArchive(r.Context(), id)
+	if err != nil {
+		s.writeArchiveError(w, r, err)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "archive is not in the trash", http.StatusNotFound)
+		return
+	}
+	s.auditArchive(r.Context(), id, auditRestore, "restored from trash", sessionUser(r))
+	http.Redirect(w, r, fmt.Sprintf("/archive/%d", id), http.StatusSeeOther)
+}
+
+// HandlePurgeArchive permanently deletes an archive from the trash
+func (s *Server) HandlePurgeArchive(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	if _, err := dbgen.New(s.DB).GetArchiveTrash(r.Context(), id); err != nil {
+		http.Error(w, "archive is not in the trash", http.StatusNotFound)
+		return
+	}
+	if err := s.purgeArchive(r.Context(), id, sessionUser(r)); err != nil {
+		s.writeArchiveError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+// HandlePlaceHold places a legal hold on an archive from the archive page
+func (s *Server) HandlePlaceHold(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	if err := s.placeHold(r.Context(), id, r.FormValue("reason"), sessionUser(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/archive/%d", id), http.StatusSeeOther)
+}
+
+// HandleReleaseHold releases the legal hold of an archive
+func (s *Server) HandleReleaseHold(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	if err := s.releaseHold(r.Context(), id, r.FormValue("reason"), sessionUser(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/archive/%d", id), http.StatusSeeOther)
+}
+
+// placeHold places or updates a hold; a reason is required for the record
+func (s *Server) placeHold(ctx context.Context, id int64, reason, actor string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return errors.New("a reason is required for a legal hold")
+	}
+	q := dbgen.New(s.DB)
+	if _, err := q.GetArchiveByID(ctx, id); err != nil {
+		return errors.New("archive not found")
+	}
+	err := q.PlaceArchiveHold(ctx, dbgen.PlaceArchiveHoldParams{ArchiveID: id, Reason: reason, PlacedAt: time.Now(), PlacedBy: ptrIfNotEmpty(actor)})
+	if err != nil {
+		return err
+	}
+	s.auditArchive(ctx, id, auditHold, reason, actor)
+	return nil
+}
+
+// releaseHold removes a hold; the optional reason goes to the audit log
+func (s *Server) releaseHold(ctx context.Context, id int64, reason, actor string) error {
+	n, err := dbgen.New(s.DB).ReleaseArchiveHold(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("archive is not under legal hold")
+	}
+	s.auditArchive(ctx, id, auditRelease, strings.TrimSpace(reason), actor)
+	return nil
+}
+
+// archiveHoldStatus is the hold state and audit log of an archive
+type archiveHoldStatus struct {
+	Held     bool                 `json:"held"`
+	Hold     *dbgen.ArchiveHold   `json:"hold,omitempty"`
+	Trashed  *dbgen.ArchiveTrash  `json:"trashed,omitempty"`
+	AuditLog []dbgen.ArchiveAudit `json:"audit_log"`
+}
+
+func (s *Server) archiveHoldStatus(ctx context.Context, id int64) (archiveHoldStatus, error) {
+	q := dbgen.New(s.DB)
+	var st archiveHoldStatus
+	if h, err := q.GetArchiveHold(ctx, id); err == nil {
+		st.Held, st.Hold = true, &h
+	}
+	if t, err := q.GetArchiveTrash(ctx, id); err == nil {
+		st.Trashed = &t
+	}
+	audit, err := q.GetArchiveAudit(ctx, id)
+	if err != nil {
+		return st, err
+	}
+	st.AuditLog = audit
+	if st.AuditLog == nil {
+		st.AuditLog = []dbgen.ArchiveAudit{}
+	}
+	return st, nil
+}
+
+// HandleArchiveHoldAPI returns the hold state and audit log of an archive
+func (s *Server) HandleArchiveHoldAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	if _, err := dbgen.New(s.DB).GetArchiveByID(r.Context(), id); err != nil {
+		s.jsonError(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	st, err := s.archiveHoldStatus(r.Context(), id)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+// HandlePutArchiveHoldAPI places a hold: {"reason": "..."}
+func (s *Server) HandlePutArchiveHoldAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := s.placeHold(r.Context(), id, req.Reason, sessionUser(r)); err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.HandleArchiveHoldAPI(w, r)
+}
+
+// HandleDeleteArchiveHoldAPI releases a hold; ?reason= is logged
+func (s *Server) HandleDeleteArchiveHoldAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	if err := s.releaseHold(r.Context(), id, r.URL.Query().Get("reason"), sessionUser(r)); err != nil {
+		s.jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s.HandleArchiveHoldAPI(w, r)
+}
@@ -0,0 +1,96 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestArchiveLegalHold(t *testing.T) {
+	s := newTestServer(t)
+	s.TrashTTL = time.Hour
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+
+	// Two archives of one event with an image each
+	for _, plate := range []string{"AB1", "AB2"} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"`+plate+`"}`), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name, event_count) VALUES ('disputed', 1), ('routine', 1)")
+	s.DB.Exec("UPDATE events SET archive_id = id")
+	s.DB.Exec("INSERT INTO images (event_id, image_data) VALUES (1, x'00'), (2, x'00')")
+
+	if err := s.placeHold(ctx, 1, " ", "ana"); err == nil {
+		t.Error("hold without a reason accepted")
+	}
+	if err := s.placeHold(ctx, 1, "case 2026-114", "ana"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Held: deletion, purge and quota pruning leave it alone
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/archive/1/delete", nil)
+	r.SetPathValue("id", "1")
+	s.HandleDeleteArchive(w, r)
+	if w.Code != http.StatusConflict {
+		t.Errorf("delete held archive: status %d, want 409", w.Code)
+	}
+	if err := s.purgeArchive(ctx, 1, "ana"); !errors.Is(err, errArchiveHeld) {
+		t.Errorf("purge held archive: err = %v", err)
+	}
+	if oldest, _ := q.GetOldestImages(ctx, 10); len(oldest) != 1 || oldest[0].ID != 2 {
+		t.Errorf("quota pruning candidates = %+v, want only image 2", oldest)
+	}
+
+	// Not held: deletion moves it to the trash and hides it from the archive list
+	if err := s.trashArchive(ctx, 2, "ben"); err != nil {
+		t.Fatal(err)
+	}
+	if archives, _ := q.GetArchives(ctx); len(archives) != 1 || archives[0].ID != 1 {
+		t.Errorf("archives = %+v, want only the held one", archives)
+	}
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/archive/2/restore", nil)
+	r.SetPathValue("id", "2")
+	s.HandleRestoreArchive(w, r)
+	if archives, _ := q.GetArchives(ctx); w.Code != http.StatusSeeOther || len(archives) != 2 {
+		t.Errorf("restore: status %d, %d archives listed", w.Code, len(archives))
+	}
+
+	// Expired trash is purged unless a hold was placed in the meantime
+	s.trashArchive(ctx, 2, "ben")
+	s.releaseHold(ctx, 1, "settled", "ana")
+	s.trashArchive(ctx, 1, "ana")
+	s.placeHold(ctx, 1, "appeal", "ana")
+	s.DB.Exec("UPDATE archive_trash SET deleted_at = ?", time.Now().Add(-2*time.Hour))
+	s.purgeExpiredTrash(ctx)
+	if _, err := q.GetArchiveByID(ctx, 2); err == nil {
+		t.Error("expired archive not purged")
+	}
+	if _, err := q.GetArchiveByID(ctx, 1); err != nil {
+		t.Error("held archive purged from the trash")
+	}
+
+	st, err := s.archiveHoldStatus(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, a := range st.AuditLog {
+		actions = append(actions, a.Action)
+	}
+	if got := strings.Join(actions, ","); got != "hold,delete,release,blocked,blocked,hold" {
+		t.Errorf("audit log = %s", got)
+	}
+	if audit, _ := q.GetArchiveAudit(ctx, 2); len(audit) == 0 || audit[0].Action != auditPurge || deref(audit[0].Actor) != "retention" {
+		t.Errorf("purge not audited: %+v", audit)
+	}
+}
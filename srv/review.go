@@ -0,0 +1,637 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// An archive can be assigned to a user for review from the archive page.
+// The assignment goes to the archive's audit log, and the reviewer gets a
+// browser notification if they subscribed to "review" (see push.go).
+//
+// Larger archives are split into slices: ranges of event ids, each assigned
+// to one reviewer, either one at a time or distributed evenly over several
+// reviewers. The archive's reviewer has the events outside every slice.
+// Saving a verdict records who reviewed the event; a verdict by someone else
+// on an event another reviewer owns or has already reviewed is refused with
+// 409 unless the request is sent again with force=1, so nobody annotates a
+// colleague's events without knowing.
+//
+// An overlap slice deliberately gives events that someone else reviews to a
+// second reviewer. Their verdicts don't change the archive's: they are kept
+// as second opinions, shown only to them, and measure how well reviewers
+// agree (see agreement.go).
+
+// errReviewConflict is returned for a verdict on someone else's events
+var errReviewConflict = errors.New("review conflict")
+
+// reviewPlan is who reviews which events of an archive, and who did
+type reviewPlan struct {
+	Reviewer   string // the archive's reviewer, owner of events outside slices
+	Slices     []dbgen.ReviewSlice
+	events     []int64                       // archived event ids, ascending
+	reviewedBy map[int64]string              // event id → first reviewer
+	opinions   map[int64]dbgen.SecondOpinion // event id → second reviewer's verdicts
+}
+
+// loadReviewPlan reads the assignments and reviews of an archive
+func loadReviewPlan(ctx context.Context, q *dbgen.Queries, archiveID int64) (*reviewPlan, error) {
+	p := &reviewPlan{reviewedBy: map[int64]string{}, opinions: map[int64]dbgen.SecondOpinion{}}
+	if rv, err := q.GetArchiveReviewer(ctx, archiveID); err == nil {
+		p.Reviewer = rv.Username
+	}
+	var err error
+	if p.Slices, err = q.GetReviewSlices(ctx, archiveID); err != nil {
+		return nil, err
+	}
+	events, err := q.GetArchivedEventUIDs(ctx, &archiveID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		p.events = append(p.events, e.ID)
+	}
+	reviews, err := q.GetEventReviews(ctx, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rv := range reviews {
+		p.reviewedBy[rv.EventID] = rv.Username
+	}
+	opinions, err := q.GetSecondOpinions(ctx, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range opinions {
+		if p.second(op.EventID) == op.Username {
+			p.opinions[op.EventID] = op
+		}
+	}
+	return p, nil
+}
+
+// assigned reports whether anyone has been given events to review
+func (p *reviewPlan) assigned() bool {
+	return p.Reviewer != "" || len(p.Slices) > 0
+}
+
+// owner returns the reviewer an event is assigned to, or ""
+func (p *reviewPlan) owner(eventID int64) string {
+	for _, sl := range p.Slices {
+		if !sl.Overlap && eventID >= sl.FirstEventID && eventID <= sl.LastEventID {
+			return sl.Username
+		}
+	}
+	return p.Reviewer
+}
+
+// second returns the reviewer of an overlap slice holding an event, or ""
+func (p *reviewPlan) second(eventID int64) string {
+	for _, sl := range p.Slices {
+		if sl.Overlap && eventID >= sl.FirstEventID && eventID <= sl.LastEventID {
+			return sl.Username
+		}
+	}
+	return ""
+}
+
+// secondOpinion reports whether user's verdicts on an event are a second
+// opinion rather than the archive's
+func (p *reviewPlan) secondOpinion(eventID int64, user string) bool {
+	return user != "" && p.second(eventID) == user && p.owner(eventID) != user
+}
+
+// check refuses a verdict by user on an event assigned to or already
+// reviewed by someone else
+func (p *reviewPlan) check(eventID int64, user string) error {
+	if p.secondOpinion(eventID, user) {
+		return nil
+	}
+	if owner := p.owner(eventID); owner != "" && owner != user {
+		return fmt.Errorf("%w: event %d is assigned to %s", errReviewConflict, eventID, owner)
+	}
+	if by := p.reviewedBy[eventID]; by != "" && by != user {
+		return fmt.Errorf("%w: event %d was already reviewed by %s", errReviewConflict, eventID, by)
+	}
+	return nil
+}
+
+// owners maps every assigned event to its reviewer, for the compare page
+func (p *reviewPlan) owners() map[int64]string {
+	m := make(map[int64]string)
+	for _, id := range p.events {
+		if owner := p.owner(id); owner != "" {
+			m[id] = owner
+		}
+	}
+	return m
+}
+
+// reviewProgress counts assigned and reviewed events
+type reviewProgress struct {
+	Username string `json:"username,omitempty"`
+	Events   int    `json:"events"`
+	Reviewed int    `json:"reviewed"`
+}
+
+// sliceProgress is a slice with its progress
+type sliceProgress struct {
+	dbgen.ReviewSlice
+	Events   int `json:"events"`
+	Reviewed int `json:"reviewed"`
+}
+
+// reviewStatus is the GET /api/archive/{id}/review response
+type reviewStatus struct {
+	Reviewer   string           `json:"reviewer,omitempty"`
+	Slices     []sliceProgress  `json:"slices"`
+	Reviewers  []reviewProgress `json:"reviewers"`
+	Unassigned int              `json:"unassigned"`
+	Total      reviewProgress   `json:"total"`
+}
+
+// status counts the events and reviews of each slice and reviewer; events
+// of overlap slices count for both reviewers, done for the second once they
+// gave their opinion
+func (p *reviewPlan) status() reviewStatus {
+	st := reviewStatus{Reviewer: p.Reviewer, Slices: []sliceProgress{}, Reviewers: []reviewProgress{}}
+	for _, sl := range p.Slices {
+		st.Slices = append(st.Slices, sliceProgress{ReviewSlice: sl})
+	}
+	byUser := map[string]*reviewProgress{}
+	count := func(user string, reviewed bool) {
+		rp := byUser[user]
+		if rp == nil {
+			rp = &reviewProgress{Username: user}
+			byUser[user] = rp
+		}
+		rp.Events++
+		if reviewed {
+			rp.Reviewed++
+		}
+	}
+	for _, id := range p.events {
+		reviewed := p.reviewedBy[id] != ""
+		_, opinion := p.opinions[id]
+		st.Total.Events++
+		if reviewed {
+			st.Total.Reviewed++
+		}
+		for i, sl := range p.Slices {
+			if id >= sl.FirstEventID && id <= sl.LastEventID {
+				st.Slices[i].Events++
+				if (sl.Overlap && opinion) || (!sl.Overlap && reviewed) {
+					st.Slices[i].Reviewed++
+				}
+			}
+		}
+		if second := p.second(id); second != "" && second != p.owner(id) {
+			count(second, opinion)
+		}
+		owner := p.owner(id)
+		if owner == "" {
+			st.Unassigned++
+			continue
+		}
+		count(owner, reviewed)
+	}
+	for _, rp := range byUser {
+		st.Reviewers = append(st.Reviewers, *rp)
+	}
+	sort.Slice(st.Reviewers, func(i, j int) bool { return st.Reviewers[i].Username < st.Reviewers[j].Username })
+	return st
+}
+
+// saveSecondOpinion merges the fields of u into user's second opinion on
+// an event and returns how many were set
+func (p *reviewPlan) saveSecondOpinion(ctx context.Context, q *dbgen.Queries, archiveID, eventID int64, user string, u compareUpdate) (int, error) {
+	op := p.opinions[eventID]
+	a := compareAnnotation{Plate: op.Plate, Maker: op.Maker, Model: op.Model, Color: op.Color}
+	changed := 0
+	for _, field := range compareFields {
+		if v := u.values()[field]; v != nil {
+			a.set(field, *v)
+			changed++
+		}
+	}
+	op = dbgen.SecondOpinion{
+		ArchiveID:  archiveID,
+		EventID:    eventID,
+		Username:   user,
+		Plate:      a.Plate,
+		Maker:      a.Maker,
+		Model:      a.Model,
+		Color:      a.Color,
+		ReviewedAt: time.Now(),
+	}
+	if err := q.UpsertSecondOpinion(ctx, dbgen.UpsertSecondOpinionParams(op)); err != nil {
+		return 0, err
+	}
+	p.opinions[eventID] = op
+	return changed, nil
+}
+
+// HandleAssignReviewer assigns an archive to the user in the form, or
+// clears the assignment when the username is empty
+func (s *Server) HandleAssignReviewer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	if err := s.assignReviewer(r.Context(), id, r.FormValue("username"), sessionUser(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/archive/%d", id), http.StatusSeeOther)
+}
+
+// assignReviewer records the reviewer of an archive and notifies them
+func (s *Server) assignReviewer(ctx context.Context, id int64, username, actor string) error {
+	q := dbgen.New(s.DB)
+	archive, err := q.GetArchiveByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("archive not found")
+	}
+	username = strings.TrimSpace(username)
+	if username == "" {
+		n, err := q.ClearArchiveReviewer(ctx, id)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			s.auditArchive(ctx, id, auditReview, "unassigned", actor)
+		}
+		return nil
+	}
+	if _, err := q.GetUserByName(ctx, username); err != nil {
+		return fmt.Errorf("unknown user %q", username)
+	}
+	if err := q.SetArchiveReviewer(ctx, dbgen.SetArchiveReviewerParams{
+		ArchiveID:  id,
+		Username:   username,
+		AssignedBy: ptrIfNotEmpty(actor),
+		AssignedAt: time.Now(),
+	}); err != nil {
+		return err
+	}
+	s.auditArchive(ctx, id, auditReview, "assigned to "+username, actor)
+
+	body := fmt.Sprintf("%s (%d events)", coalesce(deref(archive.Name), fmt.Sprintf("Archive %d", id)), archive.EventCount)
+	if actor != "" {
+		body += ", assigned by " + actor
+	}
+	s.notify(pushReview, username, pushMessage{
+		Title: "Archive to review",
+		Body:  body,
+		URL:   fmt.Sprintf("/archive/%d/compare", id),
+		Tag:   fmt.Sprintf("review-%d", id),
+	})
+	return nil
+}
+
+// HandleAddReviewSlice assigns the events from first to last (event ids) to
+// the user in the form; with overlap set, for a second opinion
+func (s *Server) HandleAddReviewSlice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	first, err1 := strconv.ParseInt(r.FormValue("first"), 10, 64)
+	last, err2 := strconv.ParseInt(r.FormValue("last"), 10, 64)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "first and last must be event ids", http.StatusBadRequest)
+		return
+	}
+	overlap := r.FormValue("overlap") != ""
+	if err := s.addReviewSlice(r.Context(), id, r.FormValue("username"), first, last, overlap, sessionUser(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/archive/%d", id), http.StatusSeeOther)
+}
+
+// HandleDistributeReview replaces an archive's slices with one per user in
+// the form, splitting the events nobody has reviewed yet evenly
+func (s *Server) HandleDistributeReview(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	r.ParseForm()
+	if err := s.distributeReview(r.Context(), id, r.Form["username"], sessionUser(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/archive/%d", id), http.StatusSeeOther)
+}
+
+// HandleDeleteReviewSlice removes a slice; its events go back to the
+// archive's reviewer
+func (s *Server) HandleDeleteReviewSlice(w http.ResponseWriter, r *http.Request) {
+	id, err1 := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	sliceID, err2 := strconv.ParseInt(r.PathValue("slice"), 10, 64)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	q := dbgen.New(s.DB)
+	slices, err := q.GetReviewSlices(r.Context(), id)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	for _, sl := range slices {
+		if sl.ID != sliceID {
+			continue
+		}
+		if _, err := q.DeleteReviewSlice(r.Context(), dbgen.DeleteReviewSliceParams{ID: sliceID, ArchiveID: id}); err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		s.auditArchive(r.Context(), id, auditReview, fmt.Sprintf("events %d–%d of %s unassigned", sl.FirstEventID, sl.LastEventID, sl.Username), sessionUser(r))
+		http.Redirect(w, r, fmt.Sprintf("/archive/%d", id), http.StatusSeeOther)
+		return
+	}
+	http.Error(w, "slice not found", http.StatusNotFound)
+}
+
+// addReviewSlice assigns a range of an archive's events to a reviewer; it
+// may not overlap another slice of its kind. An overlap slice may not hold
+// events its reviewer owns.
+func (s *Server) addReviewSlice(ctx context.Context, id int64, username string, first, last int64, overlap bool, actor string) error {
+	q := dbgen.New(s.DB)
+	archive, err := q.GetArchiveByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("archive not found")
+	}
+	username = strings.TrimSpace(username)
+	if _, err := q.GetUserByName(ctx, username); err != nil {
+		return fmt.Errorf("unknown user %q", username)
+	}
+	if first > last {
+		first, last = last, first
+	}
+	plan, err := loadReviewPlan(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	events := 0
+	for _, e := range plan.events {
+		if e < first || e > last {
+			continue
+		}
+		if overlap && plan.owner(e) == username {
+			return fmt.Errorf("%s reviews event %d already; a second opinion comes from someone else", username, e)
+		}
+		events++
+	}
+	if events == 0 {
+		return fmt.Errorf("no events of the archive between %d and %d", first, last)
+	}
+	for _, sl := range plan.Slices {
+		if sl.Overlap == overlap && first <= sl.LastEventID && last >= sl.FirstEventID {
+			return fmt.Errorf("events %d–%d overlap events %d–%d assigned to %s", first, last, sl.FirstEventID, sl.LastEventID, sl.Username)
+		}
+	}
+	if _, err := q.CreateReviewSlice(ctx, dbgen.CreateReviewSliceParams{
+		ArchiveID:    id,
+		Username:     username,
+		FirstEventID: first,
+		LastEventID:  last,
+		Overlap:      overlap,
+		AssignedBy:   ptrIfNotEmpty(actor),
+		AssignedAt:   time.Now(),
+	}); err != nil {
+		return err
+	}
+	detail := fmt.Sprintf("events %d–%d assigned to %s", first, last, username)
+	if overlap {
+		detail += " for a second opinion"
+	}
+	s.auditArchive(ctx, id, auditReview, detail, actor)
+	s.notifySlice(archive, username, first, events, actor)
+	return nil
+}
+
+// distributeReview splits an archive into one slice per user, each with
+// about the same number of events left to review. Slices are contiguous
+// and together cover the whole archive; overlap slices are kept.
+func (s *Server) distributeReview(ctx context.Context, id int64, usernames []string, actor string) error {
+	q := dbgen.New(s.DB)
+	archive, err := q.GetArchiveByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("archive not found")
+	}
+	var users []string
+	for _, u := range usernames {
+		if u = strings.TrimSpace(u); u == "" {
+			continue
+		}
+		if _, err := q.GetUserByName(ctx, u); err != nil {
+			return fmt.Errorf("unknown user %q", u)
+		}
+		users = append(users, u)
+	}
+	if len(users) == 0 {
+		return fmt.Errorf("pick at least one reviewer")
+	}
+	plan, err := loadReviewPlan(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	var open []int // indexes of events nobody reviewed
+	for i, e := range plan.events {
+		if plan.reviewedBy[e] == "" {
+			open = append(open, i)
+		}
+	}
+	if len(open) == 0 {
+		return fmt.Errorf("every event of the archive has been reviewed")
+	}
+	users = users[:min(len(users), len(open))]
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := q.WithTx(tx)
+	if _, err := qtx.DeleteReviewSlices(ctx, id); err != nil {
+		return err
+	}
+	type share struct {
+		user        string
+		first, last int64
+		open        int
+	}
+	var shares []share
+	for k, u := range users {
+		// Share k starts at its first open event (the first share at the
+		// archive's first event) and ends before the next share
+		lo, hi := k*len(open)/len(users), (k+1)*len(open)/len(users)
+		start, end := open[lo], len(plan.events)-1
+		if k == 0 {
+			start = 0
+		}
+		if k+1 < len(users) {
+			end = open[hi] - 1
+		}
+		sh := share{u, plan.events[start], plan.events[end], hi - lo}
+		if _, err := qtx.CreateReviewSlice(ctx, dbgen.CreateReviewSliceParams{
+			ArchiveID:    id,
+			Username:     sh.user,
+			FirstEventID: sh.first,
+			LastEventID:  sh.last,
+			AssignedBy:   ptrIfNotEmpty(actor),
+			AssignedAt:   time.Now(),
+		}); err != nil {
+			return err
+		}
+		shares = append(shares, sh)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.auditArchive(ctx, id, auditReview, fmt.Sprintf("%d open events distributed to %s", len(open), strings.Join(users, ", ")), actor)
+	for _, sh := range shares {
+		s.notifySlice(archive, sh.user, sh.first, sh.open, actor)
+	}
+	return nil
+}
+
+// notifySlice tells a reviewer about the events they were given
+func (s *Server) notifySlice(archive dbgen.Archive, username string, first int64, events int, actor string) {
+	body := fmt.Sprintf("%d events of %s", events, coalesce(deref(archive.Name), fmt.Sprintf("Archive %d", archive.ID)))
+	if actor != "" {
+		body += ", assigned by " + actor
+	}
+	s.notify(pushReview, username, pushMessage{
+		Title: "Events to review",
+		Body:  body,
+		URL:   fmt.Sprintf("/archive/%d/compare#event-%d", archive.ID, first),
+		Tag:   fmt.Sprintf("review-%d-%d", archive.ID, first),
+	})
+}
+
+// HandleMarkReviewed records the signed-in user as reviewer of their
+// events no one has reviewed yet: the ones they left correct. Events of
+// their overlap slices without an opinion get one with every field correct.
+func (s *Server) HandleMarkReviewed(w http.ResponseWriter, r *http.Request) {
+	archiveID, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	user := sessionUser(r)
+	if user == "" {
+		s.jsonError(w, "sign in to record reviews", http.StatusBadRequest)
+		return
+	}
+	if locked, err := s.archiveLocked(r.Context(), archiveID); err != nil || locked {
+		s.jsonError(w, "archive is locked", http.StatusConflict)
+		return
+	}
+	q := dbgen.New(s.DB)
+	plan, err := loadReviewPlan(r.Context(), q, archiveID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	marked := 0
+	now := time.Now()
+	for _, e := range plan.events {
+		if _, ok := plan.opinions[e]; !ok && plan.secondOpinion(e, user) {
+			if _, err := plan.saveSecondOpinion(r.Context(), q, archiveID, e, user, compareUpdate{}); err != nil {
+				s.jsonError(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			marked++
+			continue
+		}
+		if plan.owner(e) != user || plan.reviewedBy[e] != "" {
+			continue
+		}
+		if err := q.RecordEventReview(r.Context(), dbgen.RecordEventReviewParams{
+			ArchiveID:  archiveID,
+			EventID:    e,
+			Username:   user,
+			ReviewedAt: now,
+		}); err != nil {
+			s.jsonError(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		marked++
+	}
+	slog.Info("events marked reviewed", "archive_id", archiveID, "user", user, "events", marked)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "marked": marked})
+}
+
+// HandleReviewAPI reports an archive's slices and each reviewer's progress
+func (s *Server) HandleReviewAPI(w http.ResponseWriter, r *http.Request) {
+	archiveID, ok := s.compareArchiveID(w, r)
+	if !ok {
+		return
+	}
+	plan, err := loadReviewPlan(r.Context(), dbgen.New(s.DB), archiveID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan.status())
+}
+
+// reviewerWorkload is a reviewer's assignments over all archives
+type reviewerWorkload struct {
+	reviewProgress
+	Archives []int64 `json:"archives"`
+}
+
+// HandleReviewersAPI reports each reviewer's assigned and reviewed events
+// over all archives, to balance the workload
+func (s *Server) HandleReviewersAPI(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	ids, err := q.GetAssignedArchiveIDs(r.Context())
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	byUser := map[string]*reviewerWorkload{}
+	for _, id := range ids {
+		plan, err := loadReviewPlan(r.Context(), q, id)
+		if err != nil {
+			s.jsonError(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		for _, rp := range plan.status().Reviewers {
+			wl := byUser[rp.Username]
+			if wl == nil {
+				wl = &reviewerWorkload{reviewProgress: reviewProgress{Username: rp.Username}, Archives: []int64{}}
+				byUser[rp.Username] = wl
+			}
+			wl.Events += rp.Events
+			wl.Reviewed += rp.Reviewed
+			wl.Archives = append(wl.Archives, id)
+		}
+	}
+	out := []reviewerWorkload{}
+	for _, wl := range byUser {
+		out = append(out, *wl)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Username < out[j].Username })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
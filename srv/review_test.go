@@ -0,0 +1,150 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestReviewSlices(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+	for _, u := range []string{"alice", "bob", "carol"} {
+		q.CreateUser(ctx, dbgen.CreateUserParams{Username: u, PasswordHash: "x", CreatedAt: time.Now()})
+	}
+	for i := 0; i < 7; i++ {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"RV`+string(rune('0'+i))+`"}`), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name, event_count) VALUES ('Morning', 7)")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+
+	as := func(user string, r *http.Request) *http.Request {
+		r.SetPathValue("id", "1")
+		return r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+	}
+	toggle := func(user string, eventID int64, force bool) int {
+		body, _ := json.Marshal(map[string]any{"event_id": eventID, "field": "plate", "incorrect": true})
+		target := "/archive/1/compare/toggle"
+		if force {
+			target += "?force=1"
+		}
+		w := httptest.NewRecorder()
+		s.HandleCompareToggle(w, as(user, httptest.NewRequest("POST", target, strings.NewReader(string(body)))))
+		return w.Code
+	}
+	status := func() reviewStatus {
+		t.Helper()
+		plan, err := loadReviewPlan(ctx, q, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return plan.status()
+	}
+
+	// carol reviews event 1 before the work is split
+	if code := toggle("carol", 1, false); code != http.StatusOK {
+		t.Fatalf("unassigned toggle: %d", code)
+	}
+	s.assignReviewer(ctx, 1, "carol", "admin")
+
+	// The six open events go three each to alice and bob; the slices cover
+	// the archive without gaps
+	form := url.Values{"username": {"alice", "bob"}}
+	r := httptest.NewRequest("POST", "/archive/1/slices/distribute", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.HandleDistributeReview(w, as("admin", r))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("distribute: %d %s", w.Code, w.Body)
+	}
+	st := status()
+	if len(st.Slices) != 2 || st.Slices[0].FirstEventID != 1 || st.Slices[0].LastEventID != 4 ||
+		st.Slices[1].FirstEventID != 5 || st.Slices[1].LastEventID != 7 {
+		t.Fatalf("slices: %+v", st.Slices)
+	}
+	if st.Reviewers[0].Events != 4 || st.Reviewers[0].Reviewed != 1 || st.Reviewers[1].Events != 3 || st.Unassigned != 0 {
+		t.Errorf("progress: %+v", st.Reviewers)
+	}
+
+	// Overlapping slices are refused
+	if err := s.addReviewSlice(ctx, 1, "carol", 6, 9, false, "admin"); err == nil || !strings.Contains(err.Error(), "overlap") {
+		t.Errorf("overlap: %v", err)
+	}
+
+	// alice can't annotate bob's events, or event 1 carol already reviewed,
+	// until she confirms
+	if code := toggle("alice", 6, false); code != http.StatusConflict {
+		t.Errorf("bob's event: %d", code)
+	}
+	if code := toggle("alice", 1, false); code != http.StatusConflict {
+		t.Errorf("reviewed event: %d", code)
+	}
+	if code := toggle("alice", 2, false); code != http.StatusOK {
+		t.Errorf("own event: %d", code)
+	}
+	if code := toggle("alice", 6, true); code != http.StatusOK {
+		t.Errorf("forced: %d", code)
+	}
+
+	// The bulk API refuses the whole batch
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/api/archive/1/compare", strings.NewReader(`[{"event_id": 5, "plate": false}, {"event_id": 3, "plate": true}]`))
+	s.HandleBulkCompareAPI(w, as("bob", r))
+	if w.Code != http.StatusConflict {
+		t.Errorf("bulk: %d %s", w.Code, w.Body)
+	}
+	if res, _ := q.GetCompareResults(ctx, 1); len(res) != 3 {
+		t.Errorf("bulk saved part of the batch: %+v", res)
+	}
+
+	// bob marks the rest of his events reviewed
+	w = httptest.NewRecorder()
+	s.HandleMarkReviewed(w, as("bob", httptest.NewRequest("POST", "/archive/1/compare/reviewed", nil)))
+	if !strings.Contains(w.Body.String(), `"marked":2`) {
+		t.Errorf("mark reviewed: %s", w.Body)
+	}
+	st = status()
+	if st.Total.Reviewed != 5 || st.Slices[1].Reviewed != 3 || st.Reviewers[1].Reviewed != 3 {
+		t.Errorf("after review: %+v", st)
+	}
+
+	// Workload over all archives
+	w = httptest.NewRecorder()
+	s.HandleReviewersAPI(w, httptest.NewRequest("GET", "/api/reviewers", nil))
+	var workload []reviewerWorkload
+	json.Unmarshal(w.Body.Bytes(), &workload)
+	if len(workload) != 2 || workload[0].Username != "alice" || workload[0].Reviewed != 2 || len(workload[1].Archives) != 1 {
+		t.Errorf("workload: %s", w.Body)
+	}
+
+	// The compare page greys out the other reviewer's events
+	w = httptest.NewRecorder()
+	s.HandleCompare(w, as("alice", httptest.NewRequest("GET", "/archive/1/compare", nil)))
+	if body := w.Body.String(); !strings.Contains(body, `data-owner="bob" title="Assigned to bob" class="theirs"`) || !strings.Contains(body, "2 of 4 reviewed") {
+		t.Errorf("compare page doesn't show the assignments")
+	}
+
+	w = httptest.NewRecorder()
+	s.HandleArchive(w, as("admin", httptest.NewRequest("GET", "/archive/1", nil)))
+	if body := w.Body.String(); !strings.Contains(body, "Events 5–7") || !strings.Contains(body, "3 of 3 reviewed") {
+		t.Errorf("archive page doesn't show the slices")
+	}
+
+	if err := s.distributeReview(ctx, 1, []string{"mallory"}, "admin"); err == nil {
+		t.Error("distributed to an unknown user")
+	}
+	if plan, _ := loadReviewPlan(ctx, q, 1); !errors.Is(plan.check(7, "carol"), errReviewConflict) {
+		t.Error("carol may annotate bob's event")
+	}
+}
@@ -0,0 +1,444 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Charts over months of events would scan millions of rows, so analytics
+// read rollup tables kept up to date in the background every RollupEvery:
+// events and unrecognized reads per camera per capture hour, and the
+// accuracy of each reviewed archive. A run rebuilds every day that received
+// events since the previous run, so late store-and-forward arrivals land in
+// the right hour, and every archive whose compare results or manual plates
+// changed. The first run builds everything.
+//
+//	GET /api/analytics/hourly?from=2026-01-02&to=2026-01-03&camera=CAM1
+//	GET /api/analytics/daily?from=2026-01-01&to=2026-01-31
+//	GET /api/analytics/accuracy
+//	GET /api/analytics/schedule?from=2026-01-01&to=2026-01-31 (with -calendar-config)
+
+// DefaultRollupEvery is how often rollups are brought up to date
+const DefaultRollupEvery = 5 * time.Minute
+
+// rollupHour is the format of rollup_camera_hours.hour, in local time
+const rollupHour = "2006-01-02 15"
+
+// runRollups keeps the rollups up to date until ctx is done
+func (s *Server) runRollups(ctx context.Context) {
+	for {
+		if err := s.buildRollups(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("rollup build failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.RollupEvery):
+		}
+	}
+}
+
+// buildRollups rebuilds the days that received events since the last run
+// and the accuracy of changed archives
+func (s *Server) buildRollups(ctx context.Context) error {
+	q := dbgen.New(s.DB)
+	start := time.Now()
+	since, err := q.GetLastRollupBuild(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	days, err := q.GetReceivedDays(ctx, since)
+	if err != nil {
+		return err
+	}
+	for _, day := range days {
+		if err := s.buildRollupDay(ctx, day, start); err != nil {
+			return fmt.Errorf("day %s: %w", day, err)
+		}
+	}
+	archives, err := s.buildAccuracyRollups(ctx, start)
+	if err != nil {
+		return fmt.Errorf("accuracy: %w", err)
+	}
+	if len(days) > 0 || archives > 0 {
+		slog.Info("rollups built", "days", len(days), "archives", archives, "took", time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// buildRollupDay replaces the camera hours of one local day
+func (s *Server) buildRollupDay(ctx context.Context, day string, builtAt time.Time) error {
+	start, err := time.ParseInLocation(time.DateOnly, day, time.Local)
+	if err != nil {
+		return err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(s.DB).WithTx(tx)
+
+	if err := q.DeleteRollupDay(ctx, day); err != nil {
+		return err
+	}
+	if err := q.InsertRollupDay(ctx, dbgen.InsertRollupDayParams{DayStart: start, DayEnd: start.AddDate(0, 0, 1)}); err != nil {
+		return err
+	}
+	if err := q.MarkRollupDay(ctx, dbgen.MarkRollupDayParams{Day: day, BuiltAt: builtAt}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// buildAccuracyRollups recomputes the archives whose compare results or
+// manual plates changed and returns how many there were
+func (s *Server) buildAccuracyRollups(ctx context.Context, builtAt time.Time) (int, error) {
+	q := dbgen.New(s.DB)
+	built, err := q.GetAccuracySignatures(ctx)
+	if err != nil {
+		return 0, err
+	}
+	signatures := make(map[int64]string, len(built))
+	for _, b := range built {
+		signatures[b.ArchiveID] = b.Signature
+	}
+	sources, err := q.GetAccuracySources(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, src := range sources {
+		if signatures[src.ArchiveID] == src.Signature {
+			continue
+		}
+		if _, err := s.computeArchiveStats(ctx, src.ArchiveID, builtAt); err != nil {
+			return n, fmt.Errorf("archive %d: %w", src.ArchiveID, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// archiveAccuracy totals an archive's reads with the Grafana accuracy rules,
+// over all and per camera
+func (s *Server) archiveAccuracy(ctx context.Context, q *dbgen.Queries, archiveID int64) (*grafanaBucket, map[string]*grafanaBucket, error) {
+	events, err := q.GetArchiveAccuracyEvents(ctx, &archiveID)
+	if err != nil {
+		return nil, nil, err
+	}
+	marks, err := q.GetArchiveIncorrectFields(ctx, archiveID)
+	if err != nil {
+		return nil, nil, err
+	}
+	incorrect := make(map[int64]map[string]bool)
+	for _, m := range marks {
+		if incorrect[m.EventID] == nil {
+			incorrect[m.EventID] = make(map[string]bool)
+		}
+		incorrect[m.EventID][m.Field] = true
+	}
+	b := &grafanaBucket{}
+	cameras := make(map[string]*grafanaBucket)
+	for _, e := range events {
+		ge := grafanaEvent{
+			Camera:    e.Camera,
+			NoRead:    e.Unrecognized,
+			Reviewed:  true,
+			Incorrect: incorrect[e.ID],
+			Manual:    e.Source == "manual",
+			Corrected: e.ManualPlate != nil,
+		}
+		b.add(ge)
+		if cameras[e.Camera] == nil {
+			cameras[e.Camera] = &grafanaBucket{}
+		}
+		cameras[e.Camera].add(ge)
+	}
+	return b, cameras, nil
+}
+
+// analyticsRange reads ?from= and ?to= as local hours; a date includes its
+// whole day. Without them the range is the last `days` days.
+func analyticsRange(r *http.Request, days int) (from, to string, err error) {
+	end := time.Now().Add(time.Hour)
+	start := end.AddDate(0, 0, -days)
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+		end  bool
+	}{
+		{"from", &start, false},
+		{"to", &end, true},
+	} {
+		v := strings.TrimSpace(r.URL.Query().Get(bound.name))
+		if v == "" {
+			continue
+		}
+		t, err := parseFilterTime(v, time.Local, bound.end)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid %s %q: use a date (2006-01-02) or a timestamp", bound.name, v)
+		}
+		*bound.dst = t.In(time.Local)
+	}
+	if !start.Before(end) {
+		return "", "", fmt.Errorf("from must be before to")
+	}
+	return start.Format(rollupHour), end.Format(rollupHour), nil
+}
+
+// cameraCounts is one row of the hourly and daily analytics
+type cameraCounts struct {
+	Time            string   `json:"time"`
+	Camera          string   `json:"camera"`
+	Events          int64    `json:"events"`
+	Unrecognized    int64    `json:"unrecognized"`
+	UnrecognizedPct *float64 `json:"unrecognized_pct"`
+	ArrivalDelayMs  *int64   `json:"arrival_delay_ms"`
+	Period          string   `json:"period,omitempty"`  // hourly rows with a site calendar: in_hours, out_of_hours or holiday
+	Holiday         string   `json:"holiday,omitempty"` // daily rows with a site calendar
+}
+
+func newCameraCounts(at time.Time, camera string, events, unrecognized, delaySum, delayCount int64) cameraCounts {
+	c := cameraCounts{Time: at.Format(time.RFC3339), Camera: camera, Events: events, Unrecognized: unrecognized}
+	if events > 0 {
+		c.UnrecognizedPct = ptr(float64(unrecognized) * 100 / float64(events))
+	}
+	if delayCount > 0 {
+		c.ArrivalDelayMs = ptr(delaySum / delayCount)
+	}
+	return c
+}
+
+// writeAnalytics sends rows with the time the rollups were last built
+func (s *Server) writeAnalytics(w http.ResponseWriter, r *http.Request, rows any) {
+	var builtAt *time.Time
+	if t, err := dbgen.New(s.DB).GetLastRollupBuild(r.Context()); err == nil {
+		builtAt = &t
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"built_at": builtAt, "rows": rows})
+}
+
+// HandleAnalyticsHourly returns events per camera per capture hour,
+// by default for the last two days
+func (s *Server) HandleAnalyticsHourly(w http.ResponseWriter, r *http.Request) {
+	from, to, err := analyticsRange(r, 2)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hours, err := dbgen.New(s.DB).GetCameraHours(r.Context(), dbgen.GetCameraHoursParams{
+		FromHour: from,
+		ToHour:   to,
+		Camera:   ptrIfNotEmpty(strings.TrimSpace(r.URL.Query().Get("camera"))),
+	})
+	if err != nil {
+		slog.Warn("failed to load hourly rollups", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	rows := make([]cameraCounts, 0, len(hours))
+	for _, h := range hours {
+		at, _ := time.ParseInLocation(rollupHour, h.Hour, time.Local)
+		c := newCameraCounts(at, h.Camera, h.Events, h.Unrecognized, h.DelaySumMs, h.DelayCount)
+		if s.Calendar != nil {
+			c.Period = s.Calendar.period(at)
+		}
+		rows = append(rows, c)
+	}
+	s.writeAnalytics(w, r, rows)
+}
+
+// HandleAnalyticsDaily returns events per camera per capture day, by
+// default for the last 30 days
+func (s *Server) HandleAnalyticsDaily(w http.ResponseWriter, r *http.Request) {
+	from, to, err := analyticsRange(r, 30)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days, err := dbgen.New(s.DB).GetCameraDays(r.Context(), dbgen.GetCameraDaysParams{
+		FromHour: from,
+		ToHour:   to,
+		Camera:   ptrIfNotEmpty(strings.TrimSpace(r.URL.Query().Get("camera"))),
+	})
+	if err != nil {
+		slog.Warn("failed to load daily rollups", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	rows := make([]cameraCounts, 0, len(days))
+	for _, d := range days {
+		at, _ := time.ParseInLocation(time.DateOnly, d.Day, time.Local)
+		c := newCameraCounts(at, d.Camera, d.Events, d.Unrecognized, d.DelaySumMs, d.DelayCount)
+		c.Holiday = s.Calendar.holiday(at)
+		rows = append(rows, c)
+	}
+	s.writeAnalytics(w, r, rows)
+}
+
+// scheduleCounts totals one camera's hours of a schedule period
+type scheduleCounts struct {
+	Events          int64    `json:"events"`
+	Unrecognized    int64    `json:"unrecognized"`
+	UnrecognizedPct *float64 `json:"unrecognized_pct"`
+	ArrivalDelayMs  *int64   `json:"arrival_delay_ms"`
+	PerHour         *float64 `json:"per_hour"` // events per hour of the period in the range
+
+	delaySum, delayCount int64
+}
+
+func (c *scheduleCounts) finish(hours int) {
+	if c.Events > 0 {
+		c.UnrecognizedPct = ptr(float64(c.Unrecognized) * 100 / float64(c.Events))
+	}
+	if c.delayCount > 0 {
+		c.ArrivalDelayMs = ptr(c.delaySum / c.delayCount)
+	}
+	if hours > 0 {
+		c.PerHour = ptr(float64(c.Events) / float64(hours))
+	}
+}
+
+// cameraSchedule is one camera's traffic split by the site calendar
+type cameraSchedule struct {
+	Camera     string          `json:"camera"`
+	InHours    *scheduleCounts `json:"in_hours"`
+	OutOfHours *scheduleCounts `json:"out_of_hours"`
+	Holiday    *scheduleCounts `json:"holiday"`
+}
+
+// counts returns the totals of a period
+func (cs *cameraSchedule) counts(period string) *scheduleCounts {
+	switch period {
+	case periodInHours:
+		return cs.InHours
+	case periodHoliday:
+		return cs.Holiday
+	default:
+		return cs.OutOfHours
+	}
+}
+
+// HandleAnalyticsSchedule returns events per camera in hours, out of hours
+// and on holidays of the site calendar, by default for the last 30 days
+func (s *Server) HandleAnalyticsSchedule(w http.ResponseWriter, r *http.Request) {
+	if s.Calendar == nil {
+		s.jsonError(w, "no site calendar: start the server with -calendar-config", http.StatusNotFound)
+		return
+	}
+	from, to, err := analyticsRange(r, 30)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hours, err := dbgen.New(s.DB).GetCameraHours(r.Context(), dbgen.GetCameraHoursParams{
+		FromHour: from,
+		ToHour:   to,
+		Camera:   ptrIfNotEmpty(strings.TrimSpace(r.URL.Query().Get("camera"))),
+	})
+	if err != nil {
+		slog.Warn("failed to load hourly rollups", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Hours of each period in the range, for traffic per hour
+	periodHours := map[string]int{periodInHours: 0, periodOutOfHours: 0, periodHoliday: 0}
+	start, _ := time.ParseInLocation(rollupHour, from, time.Local)
+	end, _ := time.ParseInLocation(rollupHour, to, time.Local)
+	for h := start; h.Before(end); h = h.Add(time.Hour) {
+		periodHours[s.Calendar.period(h)]++
+	}
+
+	rows := []*cameraSchedule{}
+	byCamera := map[string]*cameraSchedule{}
+	for _, h := range hours {
+		row := byCamera[h.Camera]
+		if row == nil {
+			row = &cameraSchedule{Camera: h.Camera, InHours: &scheduleCounts{}, OutOfHours: &scheduleCounts{}, Holiday: &scheduleCounts{}}
+			byCamera[h.Camera] = row
+			rows = append(rows, row)
+		}
+		at, _ := time.ParseInLocation(rollupHour, h.Hour, time.Local)
+		c := row.counts(s.Calendar.period(at))
+		c.Events += h.Events
+		c.Unrecognized += h.Unrecognized
+		c.delaySum += h.DelaySumMs
+		c.delayCount += h.DelayCount
+	}
+	slices.SortFunc(rows, func(a, b *cameraSchedule) int { return strings.Compare(a.Camera, b.Camera) })
+	for _, row := range rows {
+		row.InHours.finish(periodHours[periodInHours])
+		row.OutOfHours.finish(periodHours[periodOutOfHours])
+		row.Holiday.finish(periodHours[periodHoliday])
+	}
+
+	var builtAt *time.Time
+	if t, err := dbgen.New(s.DB).GetLastRollupBuild(r.Context()); err == nil {
+		builtAt = &t
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"built_at": builtAt, "hours": periodHours, "rows": rows})
+}
+
+// archiveAccuracyRow is one reviewed archive in /api/analytics/accuracy
+type archiveAccuracyRow struct {
+	ArchiveID int64               `json:"archive_id"`
+	Name      string              `json:"name"`
+	Events    int64               `json:"events"`
+	Fields    map[string]accuracy `json:"fields"`
+	BuiltAt   time.Time           `json:"built_at"`
+}
+
+type accuracy struct {
+	Correct   int64    `json:"correct"`
+	Incorrect int64    `json:"incorrect"`
+	Pct       *float64 `json:"pct"`
+}
+
+func newAccuracy(correct, incorrect int64) accuracy {
+	a := accuracy{Correct: correct, Incorrect: incorrect}
+	if total := correct + incorrect; total > 0 {
+		a.Pct = ptr(float64(correct) * 100 / float64(total))
+	}
+	return a
+}
+
+// HandleAnalyticsAccuracy returns the plate, maker, model and color
+// accuracy of every reviewed archive
+func (s *Server) HandleAnalyticsAccuracy(w http.ResponseWriter, r *http.Request) {
+	archives, err := dbgen.New(s.DB).GetArchiveAccuracy(r.Context())
+	if err != nil {
+		slog.Warn("failed to load accuracy rollups", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	rows := make([]archiveAccuracyRow, 0, len(archives))
+	for _, a := range archives {
+		rows = append(rows, archiveAccuracyRow{
+			ArchiveID: a.ArchiveID,
+			Name:      coalesce(deref(a.Name), fmt.Sprintf("Archive %d", a.ArchiveID)),
+			Events:    a.Events,
+			Fields: map[string]accuracy{
+				"plate": newAccuracy(a.PlateCorrect, a.PlateIncorrect),
+				"maker": newAccuracy(a.MakerCorrect, a.MakerIncorrect),
+				"model": newAccuracy(a.ModelCorrect, a.ModelIncorrect),
+				"color": newAccuracy(a.ColorCorrect, a.ColorIncorrect),
+			},
+			BuiltAt: a.BuiltAt,
+		})
+	}
+	s.writeAnalytics(w, r, rows)
+}
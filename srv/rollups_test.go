@@ -0,0 +1,127 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestRollups(t *testing.T) {
+	s := newTestServer(t)
+	s.CameraTZ = time.UTC
+	ctx := context.Background()
+	ingest := func(bodies ...string) {
+		t.Helper()
+		for _, body := range bodies {
+			if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	ingest(
+		`{"plateUTF8":"AB1","sensorProviderID":"north","capture_timestamp":"2026-03-01T10:00:10Z"}`,
+		`{"plateUTF8":"AB2","sensorProviderID":"north","capture_timestamp":"2026-03-01T10:20:00Z"}`,
+		`{"sensorProviderID":"south","capture_timestamp":"2026-03-01T10:30:00Z"}`,
+		`{"plateUTF8":"AB3","sensorProviderID":"south","capture_timestamp":"2026-03-01T11:05:00Z"}`,
+	)
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('reviewed')")
+	s.DB.Exec("UPDATE events SET archive_id = 1 WHERE id IN (1, 2)")
+	dbgen.New(s.DB).SetCompareResult(ctx, dbgen.SetCompareResultParams{ArchiveID: 1, EventID: 2, Field: "plate", IsIncorrect: true})
+	if err := s.buildRollups(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	type response struct {
+		BuiltAt *time.Time        `json:"built_at"`
+		Rows    []json.RawMessage `json:"rows"`
+	}
+	get := func(handler http.HandlerFunc, url string) response {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", url, w.Code, w.Body)
+		}
+		var res response
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return res
+	}
+	counts := func(res response) []cameraCounts {
+		var rows []cameraCounts
+		for _, raw := range res.Rows {
+			var c cameraCounts
+			json.Unmarshal(raw, &c)
+			rows = append(rows, c)
+		}
+		return rows
+	}
+	const day = "from=2026-03-01T00:00:00Z&to=2026-03-02T00:00:00Z"
+
+	res := get(s.HandleAnalyticsHourly, "/api/analytics/hourly?"+day)
+	if res.BuiltAt == nil {
+		t.Error("built_at missing")
+	}
+	hours := counts(res)
+	if len(hours) != 3 {
+		t.Fatalf("hourly: %+v, want north 10h, south 10h and 11h", hours)
+	}
+	if h := hours[0]; h.Camera != "north" || h.Events != 2 || h.Unrecognized != 0 {
+		t.Errorf("north 10h: %+v", h)
+	}
+	if h := hours[1]; h.Camera != "south" || h.Events != 1 || h.UnrecognizedPct == nil || *h.UnrecognizedPct != 100 {
+		t.Errorf("south 10h: %+v", h)
+	}
+	if at, _ := time.Parse(time.RFC3339, hours[2].Time); !at.Equal(time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("south hour %s, want 11:00Z", hours[2].Time)
+	}
+	if got := counts(get(s.HandleAnalyticsHourly, "/api/analytics/hourly?camera=SOUTH&"+day)); len(got) != 2 {
+		t.Errorf("camera filter: %+v", got)
+	}
+
+	// A late arrival rebuilds its day
+	ingest(`{"plateUTF8":"AB4","sensorProviderID":"south","capture_timestamp":"2026-03-01T11:40:00Z"}`)
+	if err := s.buildRollups(ctx); err != nil {
+		t.Fatal(err)
+	}
+	days := counts(get(s.HandleAnalyticsDaily, "/api/analytics/daily?"+day))
+	total := int64(0)
+	for _, d := range days {
+		total += d.Events
+	}
+	if total != 5 {
+		t.Errorf("daily: %+v, want 5 events", days)
+	}
+
+	accuracyOf := func() archiveAccuracyRow {
+		t.Helper()
+		res := get(s.HandleAnalyticsAccuracy, "/api/analytics/accuracy")
+		if len(res.Rows) != 1 {
+			t.Fatalf("accuracy: %d archives, want 1", len(res.Rows))
+		}
+		var a archiveAccuracyRow
+		json.Unmarshal(res.Rows[0], &a)
+		return a
+	}
+	if a := accuracyOf(); a.Name != "reviewed" || a.Events != 2 || a.Fields["plate"].Pct == nil || *a.Fields["plate"].Pct != 50 {
+		t.Errorf("accuracy: %+v", a)
+	}
+	// Changed compare results are picked up
+	dbgen.New(s.DB).SetCompareResult(ctx, dbgen.SetCompareResultParams{ArchiveID: 1, EventID: 2, Field: "plate", IsIncorrect: false})
+	if err := s.buildRollups(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a := accuracyOf(); a.Fields["plate"].Incorrect != 0 || *a.Fields["plate"].Pct != 100 {
+		t.Errorf("accuracy after review: %+v", a.Fields["plate"])
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleAnalyticsHourly(w, httptest.NewRequest("GET", "/api/analytics/hourly?from=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid from: %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,114 @@
+package srv
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Plate search looks through the current session and every archive at once.
+// Queries are GLOB patterns on the normalized plate (upper case, spaces and
+// dashes removed) or the manual plate: "ABC*" finds plates starting with
+// ABC, "?BC123" any first character, "*123*" plates containing 123, and a
+// query without wildcards an exact plate. Patterns with a literal prefix are
+// answered from the plate_key indexes. Queries with field terms are
+// described in searchquery.go.
+
+var errEmptySearch = errors.New("search needs at least one plate character")
+
+// searchPattern normalizes a query like the plate_key columns
+func searchPattern(query string) (string, error) {
+	p := strings.NewReplacer(" ", "", "-", "").Replace(strings.ToUpper(strings.TrimSpace(query)))
+	if strings.Trim(p, "*?[]") == "" {
+		return "", errEmptySearch
+	}
+	return p, nil
+}
+
+// searchEvents runs a search and returns the matches with the total count
+func (s *Server) searchEvents(r *http.Request, f eventSearch, limit int64) ([]dbgen.SearchEventsRow, int64, error) {
+	if f.where != nil {
+		return s.queryEvents(r.Context(), f, limit)
+	}
+	pattern := f.Pattern
+	q := dbgen.New(s.DB)
+	events, err := q.SearchEvents(r.Context(), dbgen.SearchEventsParams{Pattern: pattern, Limit: limit})
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := q.CountSearchEvents(r.Context(), pattern)
+	if err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+// HandleSearchAPI returns events matching ?q= across current and archived
+// sessions, newest first. ?limit= caps the result (default 100, max 1000);
+// X-Total-Count is the number of matches.
+func (s *Server) HandleSearchAPI(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	f, err := s.parseSearch(query)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, ok := cursorLimit(r.URL.Query().Get("limit"))
+	if !ok {
+		s.jsonError(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+	events, total, err := s.searchEvents(r, f, limit)
+	if err != nil {
+		slog.Warn("plate search failed", "query", query, "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []dbgen.SearchEventsRow{}
+	}
+
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// HandleSearch shows the dashboard plate search
+func (s *Server) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Hostname string
+		Query    string
+		Pattern  string
+		Error    string
+		Events   []dbgen.SearchEventsRow
+		Total    int64
+	}{
+		Hostname: s.Hostname,
+		Query:    strings.TrimSpace(r.URL.Query().Get("q")),
+	}
+	if data.Query != "" {
+		f, err := s.parseSearch(data.Query)
+		if err != nil {
+			data.Error = err.Error()
+		} else {
+			data.Pattern = coalesce(f.Pattern, data.Query)
+			data.Events, data.Total, err = s.searchEvents(r, f, maxPageRows)
+			if err != nil {
+				slog.Warn("plate search failed", "query", data.Query, "error", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "search.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,95 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestSearchPattern(t *testing.T) {
+	for query, want := range map[string]string{
+		"abc*":      "ABC*",
+		" AB-12 3 ": "AB123",
+		"?bc123":    "?BC123",
+	} {
+		if got, err := searchPattern(query); err != nil || got != want {
+			t.Errorf("searchPattern(%q) = %q, %v; want %q", query, got, err, want)
+		}
+	}
+	for _, query := range []string{"", "*", " ?* ", "--"} {
+		if _, err := searchPattern(query); err == nil {
+			t.Errorf("searchPattern(%q) accepted", query)
+		}
+	}
+}
+
+func TestSearchAPI(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	q := dbgen.New(s.DB)
+
+	ingest := func(plate string) int64 {
+		t.Helper()
+		res, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"`+plate+`"}`), "", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.ID
+	}
+	archived := ingest("ABC 123")
+	ingest("XBC123")
+	if _, err := s.DB.Exec("INSERT INTO archives (name) VALUES ('old')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.Exec("UPDATE events SET archive_id = 1 WHERE id = ?", archived); err != nil {
+		t.Fatal(err)
+	}
+	manual := ingest("ZZ999")
+	if err := q.SetManualPlate(ctx, dbgen.SetManualPlateParams{ManualPlate: ptr("abc-124"), ID: manual}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"abc*", 2},      // archived and manual plate
+		{"abc123", 1},    // exact, spaces ignored
+		{"?bc123", 2},    // across sessions
+		{"*99*", 1},      // contains
+		{"ABC12", 0},     // no wildcard is exact
+		{"[AX]BC123", 2}, // character class
+	} {
+		w := httptest.NewRecorder()
+		s.HandleSearchAPI(w, httptest.NewRequest("GET", "/api/search?q="+tc.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tc.query, w.Code, w.Body)
+		}
+		var rows []dbgen.SearchEventsRow
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != tc.want || w.Header().Get("X-Total-Count") != fmt.Sprint(tc.want) {
+			t.Errorf("%s: %d results (total %s), want %d", tc.query, len(rows), w.Header().Get("X-Total-Count"), tc.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleSearchAPI(w, httptest.NewRequest("GET", "/api/search?q=ABC123", nil))
+	var rows []dbgen.SearchEventsRow
+	json.Unmarshal(w.Body.Bytes(), &rows)
+	if len(rows) != 1 || rows[0].ArchiveID == nil || deref(rows[0].ArchiveName) != "old" {
+		t.Errorf("archived match = %+v, want archive \"old\"", rows)
+	}
+
+	w = httptest.NewRecorder()
+	s.HandleSearchAPI(w, httptest.NewRequest("GET", "/api/search?q=*", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("q=* status = %d, want 400", w.Code)
+	}
+}
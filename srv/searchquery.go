@@ -0,0 +1,291 @@
+package srv
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Power users narrow the search box with field terms, all of which must
+// match:
+//
+//	plate:ABC* camera:1234 color:red conf>90 date:2024-05-01..2024-05-03
+//
+// A term is field, operator (: = > >= < <=) and value; "quoted values" may
+// hold spaces and a leading - negates a term. Text fields compare ignoring
+// case, with * and ? wildcards; conf and date take comparisons and lo..hi
+// ranges, a date covering its whole day. Words without a field are plate
+// patterns. A query without field terms is a plain plate search.
+// Conditions are built here into SQL with every value a parameter.
+
+// Search field kinds
+const (
+	searchText = iota
+	searchPlate
+	searchNumber
+	searchTime
+)
+
+type searchField struct {
+	kind int
+	cols []string // a text term matches any of them
+}
+
+var searchFields = map[string]searchField{
+	"plate":     {searchPlate, nil},
+	"camera":    {searchText, []string{"e.camera_serial", "e.sensor_provider_id", "e.camera_ip"}},
+	"country":   {searchText, []string{"e.plate_country"}},
+	"region":    {searchText, []string{"e.plate_region", "e.plate_region_code"}},
+	"state":     {searchText, []string{"e.car_state"}},
+	"make":      {searchText, []string{"e.vehicle_make"}},
+	"model":     {searchText, []string{"e.vehicle_model"}},
+	"color":     {searchText, []string{"e.vehicle_color"}},
+	"type":      {searchText, []string{"e.vehicle_type"}},
+	"direction": {searchText, []string{"e.direction"}},
+	"conf":      {searchNumber, []string{"e.plate_confidence"}},
+	"lane":      {searchNumber, []string{"e.lane"}},
+	"date":      {searchTime, []string{"COALESCE(e.captured_at, e.created_at)"}},
+}
+
+// searchFieldNames lists the fields for error messages
+const searchFieldNames = "plate, camera, country, region, state, make, model, color, type, direction, conf, lane, date"
+
+var searchTermRe = regexp.MustCompile(`^(-?)([a-zA-Z_]+)(:|>=|<=|=|>|<)(.*)$`)
+
+// eventSearch is a parsed search box query
+type eventSearch struct {
+	Pattern string // plate pattern of a plain search
+	where   []string
+	args    []any
+}
+
+// parseSearch reads a plain plate pattern or a query of field terms
+func (s *Server) parseSearch(query string) (eventSearch, error) {
+	words, err := splitSearch(query)
+	if err != nil {
+		return eventSearch{}, err
+	}
+	structured := false
+	for _, w := range words {
+		if searchTermRe.MatchString(w) {
+			structured = true
+		}
+	}
+	if !structured {
+		pattern, err := searchPattern(query)
+		return eventSearch{Pattern: pattern}, err
+	}
+
+	var f eventSearch
+	for _, w := range words {
+		m := searchTermRe.FindStringSubmatch(w)
+		if m == nil {
+			m = []string{w, "", "plate", ":", w}
+		}
+		negate, name, op, value := m[1] == "-", strings.ToLower(m[2]), m[3], strings.Trim(m[4], `"`)
+		field, ok := searchFields[name]
+		if !ok {
+			return f, fmt.Errorf("unknown field %q (use %s)", name, searchFieldNames)
+		}
+		if value == "" {
+			return f, fmt.Errorf("%s needs a value", name)
+		}
+		cond, args, err := s.searchCondition(field, name, op, value)
+		if err != nil {
+			return f, err
+		}
+		if negate {
+			cond = "NOT COALESCE(" + cond + ", 0)"
+		}
+		f.where = append(f.where, cond)
+		f.args = append(f.args, args...)
+	}
+	return f, nil
+}
+
+// splitSearch splits a query at spaces outside double quotes
+func splitSearch(query string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	quoted := false
+	for _, c := range query {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			word.WriteRune(c)
+		case c == ' ' && !quoted:
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		default:
+			word.WriteRune(c)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// searchCondition builds the SQL condition of one term
+func (s *Server) searchCondition(field searchField, name, op, value string) (string, []any, error) {
+	equality := op == ":" || op == "="
+	switch field.kind {
+	case searchPlate:
+		if !equality {
+			return "", nil, fmt.Errorf("plate takes : or =")
+		}
+		pattern, err := searchPattern(value)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(e.plate_key GLOB ? OR e.manual_plate_key GLOB ?)", []any{pattern, pattern}, nil
+
+	case searchText:
+		if !equality {
+			return "", nil, fmt.Errorf("%s takes : or =", name)
+		}
+		match := "%s = ? COLLATE NOCASE"
+		if strings.ContainsAny(value, "*?[") {
+			match, value = "UPPER(%s) GLOB ?", strings.ToUpper(value)
+		}
+		var conds []string
+		var args []any
+		for _, col := range field.cols {
+			conds = append(conds, fmt.Sprintf(match, col))
+			args = append(args, value)
+		}
+		return "(" + strings.Join(conds, " OR ") + ")", args, nil
+
+	case searchNumber:
+		col := field.cols[0]
+		lo, hi, isRange := strings.Cut(value, "..")
+		if isRange {
+			if !equality {
+				return "", nil, fmt.Errorf("%s range takes :", name)
+			}
+			a, errA := strconv.ParseFloat(lo, 64)
+			b, errB := strconv.ParseFloat(hi, 64)
+			if errA != nil || errB != nil {
+				return "", nil, fmt.Errorf("invalid %s range %q", name, value)
+			}
+			return col + " BETWEEN ? AND ?", []any{a, b}, nil
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid %s %q: not a number", name, value)
+		}
+		if equality {
+			op = "="
+		}
+		return col + " " + op + " ?", []any{n}, nil
+
+	default: // searchTime
+		col := field.cols[0]
+		loc := s.CameraTZ
+		if loc == nil {
+			loc = time.Local
+		}
+		bound := func(v string, end bool) (time.Time, error) {
+			t, err := parseFilterTime(v, loc, end)
+			if err != nil {
+				return t, fmt.Errorf("invalid %s %q: use a date (2006-01-02) or a timestamp", name, v)
+			}
+			// Stored times are in the server's zone and compare as text
+			return t.In(time.Local), nil
+		}
+		lo, hi, isRange := strings.Cut(value, "..")
+		if !isRange && equality {
+			hi = lo
+		}
+		switch {
+		case equality:
+			from, err := bound(lo, false)
+			if err != nil {
+				return "", nil, err
+			}
+			to, err := bound(hi, true)
+			if err != nil {
+				return "", nil, err
+			}
+			if !from.Before(to) {
+				return "", nil, fmt.Errorf("%s range ends before it starts", name)
+			}
+			return col + " >= ? AND " + col + " < ?", []any{from, to}, nil
+		case isRange:
+			return "", nil, fmt.Errorf("%s range takes :", name)
+		}
+		// A date after ends the day; at or before includes it
+		t, err := bound(value, op == ">" || op == "<=")
+		if err != nil {
+			return "", nil, err
+		}
+		switch op {
+		case ">":
+			op = ">="
+		case "<=":
+			op = "<"
+		}
+		return col + " " + op + " ?", []any{t}, nil
+	}
+}
+
+// searchColumns are the columns of dbgen.SearchEventsRow
+const searchColumns = `e.id, e.plate_utf8, e.manual_plate, e.car_state, e.sensor_provider_id, e.camera_serial,
+    e.plate_country, e.vehicle_make, e.vehicle_model, e.plate_confidence,
+    e.created_at, e.captured_at, e.archive_id, a.name AS archive_name,
+    COALESCE(best.plate_image_id, 0) as plate_image_id`
+
+// queryEvents runs a structured search, newest first
+func (s *Server) queryEvents(ctx context.Context, f eventSearch, limit int64) ([]dbgen.SearchEventsRow, int64, error) {
+	where := strings.Join(f.where, " AND ")
+	var total int64
+	if err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM events e WHERE "+where, f.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+searchColumns+`
+FROM events e
+JOIN event_best_images best ON best.event_id = e.id
+LEFT JOIN archives a ON a.id = e.archive_id
+WHERE `+where+`
+ORDER BY COALESCE(e.captured_at, e.created_at) DESC, e.id DESC
+LIMIT ?`, append(f.args, limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	events := []dbgen.SearchEventsRow{}
+	for rows.Next() {
+		var i dbgen.SearchEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PlateUtf8,
+			&i.ManualPlate,
+			&i.CarState,
+			&i.SensorProviderID,
+			&i.CameraSerial,
+			&i.PlateCountry,
+			&i.VehicleMake,
+			&i.VehicleModel,
+			&i.PlateConfidence,
+			&i.CreatedAt,
+			&i.CapturedAt,
+			&i.ArchiveID,
+			&i.ArchiveName,
+			&i.PlateImageID,
+		); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, i)
+	}
+	return events, total, rows.Err()
+}
@@ -0,0 +1,96 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestParseSearch(t *testing.T) {
+	s := &Server{}
+	if f, err := s.parseSearch("ab-c*"); err != nil || f.Pattern != "ABC*" || f.where != nil {
+		t.Errorf("plain query = %+v, %v; want a plate pattern", f, err)
+	}
+	f, err := s.parseSearch(`plate:ABC* -color:red make:"Land Rover" conf>90 date:2024-05-01..2024-05-03`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.where) != 5 || len(f.args) != 7 {
+		t.Errorf("conditions = %q with %d args", f.where, len(f.args))
+	}
+	for _, query := range []string{
+		"plate:ABC* speed>100", // unknown field
+		"camera:",              // no value
+		"conf>high",            // not a number
+		"color>red",            // text compares
+		"date:2024-05-03..2024-05-01",
+		"date>yesterday",
+		`make:"Land Rover`,
+		"plate:* country:D",
+	} {
+		if _, err := s.parseSearch(query); err == nil {
+			t.Errorf("%s accepted", query)
+		}
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	for _, body := range []string{
+		`{"plateUTF8":"ABC123","sensorProviderID":"cam1234","plateConfidence":"95","capture_timestamp":"2024-05-01T10:00:00",
+			"vehicle_info":{"make":"Land Rover","color":"RED"}}`,
+		`{"plateUTF8":"ABC999","sensorProviderID":"cam1234","plateConfidence":"85","capture_timestamp":"2024-05-03T23:00:00",
+			"vehicle_info":{"make":"VW","color":"blue"}}`,
+		`{"plateUTF8":"XYZ123","sensorProviderID":"cam9","plateConfidence":"99","capture_timestamp":"2024-05-04T08:00:00",
+			"vehicle_info":{"make":"VW","color":"red"}}`,
+	} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"plate:ABC* camera:cam1234 color:red conf>90 date:2024-05-01..2024-05-03", []string{"ABC123"}},
+		{"color:red", []string{"XYZ123", "ABC123"}},
+		{"-color:red", []string{"ABC999"}},
+		{`make:"land rover"`, []string{"ABC123"}},
+		{"make:V* conf:80..90", []string{"ABC999"}},
+		{"date:2024-05-03", []string{"ABC999"}},
+		{"date>2024-05-03", []string{"XYZ123"}},
+		{"date<=2024-05-03 *123", []string{"ABC123"}},
+		{"camera:cam* conf>=99", []string{"XYZ123"}},
+		{"ABC123", []string{"ABC123"}},
+	} {
+		w := httptest.NewRecorder()
+		s.HandleSearchAPI(w, httptest.NewRequest("GET", "/api/search?q="+url.QueryEscape(tc.query), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tc.query, w.Code, w.Body)
+		}
+		var rows []dbgen.SearchEventsRow
+		json.Unmarshal(w.Body.Bytes(), &rows)
+		var got []string
+		for _, row := range rows {
+			got = append(got, deref(row.PlateUtf8))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) || w.Header().Get("X-Total-Count") != fmt.Sprint(len(tc.want)) {
+			t.Errorf("%s = %v (total %s), want %v", tc.query, got, w.Header().Get("X-Total-Count"), tc.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleSearchAPI(w, httptest.NewRequest("GET", "/api/search?q="+url.QueryEscape("speed>100"), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown field status = %d, want 400", w.Code)
+	}
+}
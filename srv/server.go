@@ -6,19 +6,23 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/xuri/excelize/v2"
@@ -27,11 +31,101 @@ import (
 )
 
 type Server struct {
-	DB           *sql.DB
-	Hostname     string
-	TemplatesDir string
-	StaticDir    string
-	DataDir      string // For storing JSON and images on disk
+	DB                 *sql.DB
+	Hostname           string
+	TemplatesDir       string
+	StaticDir          string
+	DataDir            string                // For storing JSON and images on disk
+	OCR                OCRProvider           // Optional fallback for unrecognized events
+	Acks               *AckConfig            // Optional camera-specific ingest acknowledgments
+	HTTP               HTTPConfig            // Connection timeouts and limits
+	Updates            *UpdateChecker        // Optional release server for /api/version
+	NodeID             string                // Site/node identifier stamped on every event
+	Quotas             *QuotaConfig          // Optional image storage quotas
+	Replica            *Replicator           // Optional warm standby copy of the database and data dir
+	BI                 *BISnapshot           // Optional stripped database copy for BI tools
+	Journal            bool                  // Write ingest requests to disk before processing them
+	ReadOnly           bool                  // Serve a copied database without accepting ingest or mutations
+	Panels             *PanelConfig          // Optional admin-defined dashboard panels
+	Compat             *CompatConfig         // Optional legacy vendor receiver routes
+	Mappings           *MappingConfig        // Optional field-mapping rules for payloads of other cameras
+	CameraTZ           *time.Location        // Zone of camera timestamps without an offset (default local)
+	LateAfter          time.Duration         // Flag events received this long after capture (0 = never)
+	ExportImages       ExportImageConfig     // Scale and size of images embedded into XLSX exports
+	PublicURL          string                // External base URL for links in exports (default: request host)
+	RequireAPIKey      bool                  // Reject ingest requests without an enabled API key
+	MergeWindow        time.Duration         // Merge carState update/lost messages into an event this recent (0 = never)
+	RequireLogin       bool                  // Require a signed-in user for everything but camera ingest
+	SessionTTL         time.Duration         // Sessions expire after this long unused
+	TrashTTL           time.Duration         // Purge deleted archives after this long in the trash (0 = keep)
+	Signer             *ExportSigner         // Optional key signing exported files
+	Privacy            *PrivacyConfig        // Optional anonymization of old events
+	TLS                *TLSConfig            // Optional HTTPS from certificate files or Let's Encrypt
+	JSONFields         []string              // Multipart form fields read as event JSON (default json, data)
+	ChunkTimeout       time.Duration         // Ingest split images still incomplete after this without them (0 = don't reassemble)
+	GzipLimit          int64                 // Largest gzip-encoded ingest body after decompression (0 = DefaultGzipLimit)
+	Blobs              *BlobStore            // Optional store for camera JSON and images instead of the data dir and database
+	DedupWindow        time.Duration         // Resends of a message received this recently are duplicates (0 = store them)
+	DedupLink          bool                  // Store duplicates as events linked to the original instead of ignoring them
+	ThumbWidth         int                   // Width of thumbnails made when images are stored (0 = only on request, DefaultThumbWidth)
+	RollupEvery        time.Duration         // Bring analytics rollups up to date this often (0 = never)
+	MQTT               *MQTTConfig           // Optional broker subscription for cameras publishing over MQTT
+	Inbox              *InboxConfig          // Optional file drop directory and FTP server for cameras that push files
+	UploadMemory       int64                 // Upload bytes held in memory across concurrent ingest requests (0 = DefaultUploadMemory)
+	IdleGap            time.Duration         // Suggest splitting the current session at traffic gaps this long (0 = never)
+	Genetec            *GenetecConfig        // Optional Security Center endpoint stored reads are forwarded to
+	Milestone          *MilestoneConfig      // Optional XProtect Event Server reads are sent to as analytics events
+	Push               *PushSender           // Optional VAPID key for browser notifications to reviewers
+	CCTV               *CCTVConfig           // Optional NVR playback links for the video of co-located CCTV cameras
+	Snapshots          *SnapshotConfig       // Optional overview cameras grabbed for a scene image of each new read
+	VIN                *VINConfig            // Optional additions to the built-in VIN decoding tables
+	Calendar           *SiteCalendar         // Optional working hours and holidays for analytics and offline alerting
+	OfflineAfter       time.Duration         // Report cameras silent this long (of working time) as offline (0 = never)
+	ExportSchedule     *ExportScheduleConfig // Optional exports written to a directory, SFTP or S3 on a schedule
+	Email              *EmailConfig          // Optional SMTP alerts on watchlist hits and ingest errors
+	AccuracyAlarmDelta float64               // Alarm when a camera's accuracy in an archive is this many points below its baseline (0 = never)
+	PlateMatch         *PlateMatchConfig     // Optional near-miss plate matching in the compare workflow (nil = exact only)
+	Demo               bool                  // Mark the dashboard as showing synthetic demo data (-demo)
+
+	subscribers eventHub        // Live event stream consumers
+	jobs        jobRegistry     // Background exports
+	chunks      chunkBuffer     // Messages of split images still arriving
+	uploads     uploadBudget    // Upload bytes loaded by ingest requests in flight
+	openRoutes  map[string]bool // Route patterns reachable without a login
+	keyRoutes   map[string]bool // Route patterns that also accept an API key instead of a login
+	stopping    stopSignal      // Closed when shutdown starts
+	background  sync.WaitGroup  // Goroutines shutdown waits for
+	cameraWatch cameraWatch     // Cameras reported offline
+	watchlists  watchlistCache  // Watchlist entries new reads are matched against
+	measurer    imageMeasurer   // Background measuring of stored images
+}
+
+// HTTPConfig tunes the HTTP server. Some camera HTTP stacks open connections
+// and never close them, which exhausts file descriptors without timeouts.
+// A zero duration means no timeout.
+type HTTPConfig struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration // whole request including body; large NDJSON streams need a generous value
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration // idle keep-alive connections are closed after this
+	MaxHeaderBytes    int
+	DisableKeepAlives bool
+	DrainTimeout      time.Duration // how long shutdown waits for in-flight requests
+}
+
+// DefaultDrainTimeout is how long shutdown waits for in-flight requests
+const DefaultDrainTimeout = 30 * time.Second
+
+// DefaultHTTPConfig returns timeouts suited to cameras on a local network
+func DefaultHTTPConfig() HTTPConfig {
+	return HTTPConfig{
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       5 * time.Minute,
+		WriteTimeout:      5 * time.Minute,
+		IdleTimeout:       60 * time.Second,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		DrainTimeout:      DefaultDrainTimeout,
+	}
 }
 
 // Event JSON structures (flexible to handle different field naming conventions)
@@ -61,6 +155,10 @@ type IncomingEvent struct {
 	SensorProviderID string `json:"sensorProviderID"`
 	PacketCounter    string `json:"packetCounter"`
 
+	// Multi-lane cameras
+	Lane      string `json:"lane"`
+	Direction string `json:"direction"`
+
 	// Geotag
 	Geotag *struct {
 		Lat float64 `json:"lat"`
@@ -75,8 +173,12 @@ type IncomingEvent struct {
 		Type            string `json:"type"`
 		ConfidenceMMR   string `json:"confidenceMMR"`
 		ConfidenceColor string `json:"confidenceColor"`
+		VIN             string `json:"vin"`
 	} `json:"vehicle_info"`
 
+	// VIN from access control integrations
+	VIN string `json:"vin"`
+
 	// Camera info
 	CameraInfo *struct {
 		SerialNumber string `json:"SerialNumber"`
@@ -88,13 +190,76 @@ type IncomingEvent struct {
 	ImageFile2 string `json:"imageFile2"` // Usually plate/lpup image
 
 	// Images embedded in JSON
-	ImageArray []struct {
-		ImageType   string `json:"ImageType"`
-		ImageFormat string `json:"ImageFormat"`
-		BinaryImage string `json:"BinaryImage"`
-	} `json:"ImageArray"`
+	ImageArray []embeddedImage `json:"ImageArray"`
+}
+
+// embeddedImage is a base64 image in an event's ImageArray
+type embeddedImage struct {
+	ImageType   string `json:"ImageType"`
+	ImageFormat string `json:"ImageFormat"`
+	BinaryImage string `json:"BinaryImage"`
+	Timestamp   string `json:"Timestamp"`  // frame capture time, when the camera sends one
+	ChunkIndex  int    `json:"ChunkIndex"` // position of this slice of a split BinaryImage
+	ChunkCount  int    `json:"ChunkCount"` // number of slices a split BinaryImage was sent in
+}
+
+type uploadedImage struct {
+	Filename string
+	Field    string // multipart form field name
+	Data     []byte
+}
+
+// imageType guesses plate vs vehicle from the filename, else the form field
+// name
+func (img uploadedImage) imageType() string {
+	t := detectImageType(img.Filename)
+	if t == "uploaded" && img.Field != "" {
+		t = detectImageType(img.Field)
+	}
+	return t
+}
+
+// ingestRequest is one received event before processing. It is what the
+// ingest journal stores.
+type ingestRequest struct {
+	RawJSON      []byte // empty for image-only events
+	JSONFilename string // original filename from multipart
+	Images       []uploadedImage
+	UID          string // assigned on receipt, so a replayed request keeps its identity
+	ReceivedAt   time.Time
 }
 
+func newIngestRequest(rawJSON []byte, jsonFilename string, images []uploadedImage) ingestRequest {
+	now := time.Now()
+	return ingestRequest{
+		RawJSON:      rawJSON,
+		JSONFilename: jsonFilename,
+		Images:       images,
+		UID:          newULID(now),
+		ReceivedAt:   now,
+	}
+}
+
+type ingestResult struct {
+	ID           int64
+	UID          string
+	Plate        string
+	Images       int
+	Unrecognized bool
+	Camera       string // serial number, or sensor provider ID when there is none
+	Rejected     int    // images dropped by storage quotas
+	Merged       bool   // continuation message merged into an earlier event
+	Pending      bool   // part of a split image, held until the rest arrives
+	Duplicate    bool   // resend of a message already received
+}
+
+// payloadError is an ingest failure caused by the request content rather than the server
+type payloadError struct {
+	msg string
+}
+
+func (e *payloadError) Error() string { return e.msg }
+
 // Helper to get first non-empty value
 func coalesce(vals ...string) string {
 	for _, v := range vals {
@@ -120,16 +285,28 @@ func New(dbPath, hostname string) (*Server, error) {
 	_, thisFile, _, _ := runtime.Caller(0)
 	baseDir := filepath.Dir(thisFile)
 	dataDir := filepath.Join(filepath.Dir(baseDir), "data")
-	
+
 	// Create data directories
 	os.MkdirAll(filepath.Join(dataDir, "json"), 0755)
 	os.MkdirAll(filepath.Join(dataDir, "images"), 0755)
-	
+
 	srv := &Server{
 		Hostname:     hostname,
 		TemplatesDir: filepath.Join(baseDir, "templates"),
 		StaticDir:    filepath.Join(baseDir, "static"),
 		DataDir:      dataDir,
+		HTTP:         DefaultHTTPConfig(),
+		NodeID:       hostname,
+		Journal:      true,
+		LateAfter:    2 * time.Minute,
+		MergeWindow:  DefaultMergeWindow,
+		ChunkTimeout: DefaultChunkTimeout,
+		ThumbWidth:   DefaultThumbWidth,
+		DedupWindow:  DefaultDedupWindow,
+		RollupEvery:  DefaultRollupEvery,
+		SessionTTL:   DefaultSessionTTL,
+		ExportImages: DefaultExportImageConfig(),
+		IdleGap:      DefaultIdleGap,
 	}
 	if err := srv.setUpDatabase(dbPath); err != nil {
 		return nil, err
@@ -137,18 +314,6 @@ func New(dbPath, hostname string) (*Server, error) {
 	return srv, nil
 }
 
-// insertImageWithID inserts an image and returns its ID
-func (s *Server) insertImageWithID(ctx context.Context, q *dbgen.Queries, params dbgen.InsertImageParams) (int64, error) {
-	err := q.InsertImage(ctx, params)
-	if err != nil {
-		return 0, err
-	}
-	// Get the last inserted ID
-	var id int64
-	err = s.DB.QueryRowContext(ctx, "SELECT last_insert_rowid()").Scan(&id)
-	return id, err
-}
-
 func (s *Server) setUpDatabase(dbPath string) error {
 	wdb, err := db.Open(dbPath)
 	if err != nil {
@@ -183,84 +348,280 @@ func toInt64(v interface{}) int64 {
 	}
 }
 
-// HandleAPI processes incoming car events
-func (s *Server) HandleAPI(w http.ResponseWriter, r *http.Request) {
-	var event IncomingEvent
+// readIngestRequest reads a camera upload: multipart with a JSON part and
+// images, a bare image, or a plain JSON body, in the format of the
+// ?vendor= hint or the one it looks like
+func (s *Server) readIngestRequest(r *http.Request) (ingestRequest, error) {
+	req, _, err := s.readUpload(r, r.URL.Query().Get("vendor"))
+	return req, err
+}
+
+// readUpload reads a camera upload, converting the event with the parser
+// of vendor (empty to sniff it), and also returns the multipart parts that
+// were ignored
+func (s *Server) readUpload(r *http.Request, vendor string) (ingestRequest, []string, error) {
 	var rawJSON []byte
 	var jsonFilename string // Original filename from multipart
-	var uploadedImages []struct {
-		Filename string
-		Data     []byte
-	}
+	var uploadedImages []uploadedImage
+	var ignored []string
 
 	contentType := r.Header.Get("Content-Type")
 
 	if strings.HasPrefix(contentType, "multipart/") {
-		// Parse multipart (max 32MB)
-		if err := r.ParseMultipartForm(32 << 20); err != nil {
-			s.jsonError(w, "failed to parse multipart: "+err.Error(), http.StatusBadRequest)
-			return
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return ingestRequest{}, nil, &payloadError{"failed to parse multipart: " + err.Error()}
 		}
-
-		// Process all files
-		if r.MultipartForm != nil && r.MultipartForm.File != nil {
-			for _, files := range r.MultipartForm.File {
-				for _, f := range files {
-					file, err := f.Open()
-					if err != nil {
-						continue
-					}
-					data, _ := io.ReadAll(file)
-					file.Close()
-
-					lowerName := strings.ToLower(f.Filename)
-					if strings.HasSuffix(lowerName, ".json") {
-						rawJSON = data
-						jsonFilename = f.Filename
-					} else if strings.HasSuffix(lowerName, ".jpg") ||
-						strings.HasSuffix(lowerName, ".jpeg") ||
-						strings.HasSuffix(lowerName, ".png") {
-						uploadedImages = append(uploadedImages, struct {
-							Filename string
-							Data     []byte
-						}{Filename: f.Filename, Data: data})
-					}
+		// Parts are spooled to disk as they arrive and loaded once all are in
+		type keptPart struct {
+			kind            int
+			field, filename string
+		}
+		var kept []keptPart
+		var parts []*spooledPart
+		defer func() {
+			for _, p := range parts {
+				p.discard()
+			}
+		}()
+		// A form field holding a JSON object or XML document under an
+		// unknown name is the event if no part is marked as JSON
+		jsonPart, fallbackPart := -1, -1
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return ingestRequest{}, nil, &payloadError{"failed to parse multipart: " + err.Error()}
+			}
+			sp, err := s.spoolPart(part)
+			part.Close()
+			if err != nil {
+				return ingestRequest{}, nil, &payloadError{"failed to read multipart: " + err.Error()}
+			}
+			field, filename := part.FormName(), part.FileName()
+			kind := s.partKind(field, filename, part.Header.Get("Content-Type"), sp.head)
+			switch kind {
+			case partJSON:
+				if jsonPart >= 0 {
+					sp.discard()
+					continue
+				}
+				jsonPart = len(kept)
+			case partImage:
+			default:
+				if trimmed := bytes.TrimSpace(sp.head); filename == "" && fallbackPart < 0 && (bytes.HasPrefix(trimmed, []byte("{")) || looksLikeXML(trimmed)) {
+					fallbackPart = len(kept)
+					break
 				}
+				sp.discard()
+				ignored = append(ignored, coalesce(filename, field))
+				continue
 			}
+			kept = append(kept, keptPart{kind, field, filename})
+			parts = append(parts, sp)
 		}
-
-		// Try form fields if no JSON file
-		if rawJSON == nil {
-			if jsonStr := r.FormValue("json"); jsonStr != "" {
-				rawJSON = []byte(jsonStr)
-			} else if jsonStr := r.FormValue("data"); jsonStr != "" {
-				rawJSON = []byte(jsonStr)
+		if jsonPart >= 0 && fallbackPart >= 0 {
+			parts[fallbackPart].discard()
+			fallbackPart = -1
+		}
+		data, err := s.loadParts(r.Context(), parts)
+		if err != nil {
+			return ingestRequest{}, nil, err
+		}
+		for i, k := range kept {
+			switch {
+			case i == jsonPart:
+				rawJSON, jsonFilename = data[i], k.filename
+			case i == fallbackPart:
+				rawJSON = data[i]
+			case k.kind == partImage:
+				filename := k.filename
+				if filename == "" {
+					filename = k.field + imageExtension(data[i])
+				}
+				uploadedImages = append(uploadedImages, uploadedImage{Filename: filename, Field: k.field, Data: data[i]})
 			}
 		}
 	} else {
-		// Plain JSON body
-		var err error
-		rawJSON, err = io.ReadAll(r.Body)
+		data, err := s.readBody(r)
 		if err != nil {
-			s.jsonError(w, "failed to read body: "+err.Error(), http.StatusBadRequest)
-			return
+			return ingestRequest{}, nil, err
+		}
+		switch {
+		case strings.HasPrefix(contentType, "image/"):
+			// Bare image body from a trigger without recognition
+			ext := ".jpg"
+			if contentType == "image/png" {
+				ext = ".png"
+			}
+			uploadedImages = append(uploadedImages, uploadedImage{Filename: "trigger" + ext, Data: data})
+		case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+			// Flat key/value event from an older device
+			if rawJSON, err = s.formJSON(data); err != nil {
+				return ingestRequest{}, nil, err
+			}
+		default:
+			// Plain JSON or XML body
+			rawJSON = data
 		}
 	}
 
-	if len(rawJSON) == 0 {
-		s.jsonError(w, "no JSON data provided", http.StatusBadRequest)
+	// Vendor formats are stored as the event JSON they map to
+	var err error
+	if rawJSON, jsonFilename, err = s.vendorEvent(vendor, rawJSON, jsonFilename, uploadedImages); err != nil {
+		return ingestRequest{}, ignored, err
+	}
+
+	// Image-only events are accepted without JSON and stored as unrecognized
+	if len(rawJSON) == 0 && len(uploadedImages) == 0 {
+		return ingestRequest{}, ignored, &payloadError{"no JSON data provided"}
+	}
+	return newIngestRequest(rawJSON, jsonFilename, uploadedImages), ignored, nil
+}
+
+// Multipart part kinds
+const (
+	partOther = iota
+	partJSON
+	partImage
+)
+
+// partKind tells event JSON and images apart by filename, content type,
+// form field name and finally content, since camera platforms name their
+// parts differently
+func (s *Server) partKind(field, filename, contentType string, data []byte) int {
+	lowerName := strings.ToLower(filename)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasSuffix(lowerName, ".json"), mediaType == "application/json",
+		strings.HasSuffix(lowerName, ".xml"), isXMLType(contentType):
+		return partJSON
+	case strings.HasSuffix(lowerName, ".jpg"), strings.HasSuffix(lowerName, ".jpeg"), strings.HasSuffix(lowerName, ".png"),
+		mediaType == "image/jpeg", mediaType == "image/png":
+		return partImage
+	case filename == "" && s.isJSONField(field):
+		return partJSON
+	case imageExtension(data) != "":
+		return partImage
+	}
+	return partOther
+}
+
+// imageExtension returns the file extension of JPEG or PNG data
+func imageExtension(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	}
+	return ""
+}
+
+// HandleAPI processes incoming car events in any vendor format, picked by
+// ?vendor= or by sniffing
+func (s *Server) HandleAPI(w http.ResponseWriter, r *http.Request) {
+	s.handleIngest(w, r, r.URL.Query().Get("vendor"))
+}
+
+// handleIngest stores an upload in a vendor's format (empty to sniff it).
+// Messages that aren't plate reads are acknowledged and dropped.
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request, vendor string) {
+	req, _, err := s.readUpload(r, vendor)
+	if errors.Is(err, errNotANPR) {
+		// Heartbeats and other alarms; the camera only needs a 200
+		slog.Debug("camera message ignored", "remote", r.RemoteAddr, "vendor", vendor, "reason", err)
+		if !s.writeAck(w, r, ingestResult{}, nil) {
+			writeIgnored(w)
+		}
+		return
+	}
+	if err != nil {
+		if !s.writeAck(w, r, ingestResult{}, err) {
+			s.jsonError(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
-	if err := json.Unmarshal(rawJSON, &event); err != nil {
-		s.jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+	res, err := s.ingest(r.Context(), req)
+	if err != nil {
+		var pe *payloadError
+		if !errors.As(err, &pe) {
+			slog.Error("failed to insert event", "error", err)
+		}
+	}
+	s.writeIngestAck(w, r, req, res, err)
+}
+
+// writeIgnored acknowledges a camera message that isn't a plate read
+func writeIgnored(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "message": "ignored"})
+}
+
+// writeIngestResult sends the default JSON response for an ingest request
+func (s *Server) writeIngestResult(w http.ResponseWriter, res ingestResult, err error) {
+	if err != nil {
+		var pe *payloadError
+		if errors.As(err, &pe) {
+			s.jsonError(w, pe.msg, http.StatusBadRequest)
+			return
+		}
+		s.jsonError(w, "database error", http.StatusInternalServerError)
 		return
 	}
 
+	message := "event recorded"
+	if res.Merged {
+		message = "event updated"
+	} else if res.Pending {
+		message = "chunk received"
+	} else if res.Duplicate && res.Images == 0 {
+		message = "duplicate ignored"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":      true,
+		"message":      message,
+		"id":           res.ID,
+		"uid":          res.UID,
+		"plate":        res.Plate,
+		"images":       res.Images,
+		"unrecognized": res.Unrecognized,
+		"rejected":     res.Rejected,
+		"merged":       res.Merged,
+		"pending":      res.Pending,
+		"duplicate":    res.Duplicate,
+	})
+}
+
+// parsedEvent is a camera event normalized into an events row
+type parsedEvent struct {
+	Event     IncomingEvent
+	Params    dbgen.InsertEventParams
+	Plate     string
+	Camera    string  // serial number, or sensor provider ID when there is none
+	AutoCarID bool    // the camera sent no car ID, so one was generated
+	DedupKey  *string // identifies the message across resends
+}
+
+// parseEvent normalizes a camera event without storing anything
+func (s *Server) parseEvent(req ingestRequest) (parsedEvent, error) {
+	rawJSON := req.RawJSON
+	var event IncomingEvent
+	if len(rawJSON) > 0 {
+		if err := json.Unmarshal(rawJSON, &event); err != nil {
+			return parsedEvent{}, &payloadError{"invalid JSON: " + err.Error()}
+		}
+	}
+
 	// Normalize fields
 	carID := coalesce(event.CarID, event.CarId, event.CarId2)
-	if carID == "" {
-		carID = fmt.Sprintf("auto-%d", time.Now().UnixNano())
+	autoCarID := carID == ""
+	if autoCarID {
+		carID = fmt.Sprintf("auto-%d", req.ReceivedAt.UnixNano())
 	}
 	carState := coalesce(event.CarState, event.CarState2)
 	plate := coalesce(event.PlateUTF8, event.PlateText)
@@ -273,6 +634,14 @@ func (s *Server) HandleAPI(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Lane number
+	var lane *int64
+	if event.Lane != "" {
+		if n, err := strconv.ParseInt(event.Lane, 10, 64); err == nil {
+			lane = &n
+		}
+	}
+
 	// Geotag
 	var geoLat, geoLon *float64
 	if event.Geotag != nil {
@@ -298,18 +667,24 @@ func (s *Server) HandleAPI(w http.ResponseWriter, r *http.Request) {
 		camIP = ptrIfNotEmpty(event.CameraInfo.IPAddress)
 	}
 
-	now := time.Now()
-	rawJSONStr := string(rawJSON)
+	now := req.ReceivedAt
+	uid := req.UID
+	var rawJSONStr *string
+	if len(rawJSON) > 0 {
+		rawJSONStr = ptr(string(rawJSON))
+	}
 
-	// Insert event
-	q := dbgen.New(s.DB)
-	eventID, err := q.InsertEvent(r.Context(), dbgen.InsertEventParams{
+	eventDatetime := ptrIfNotEmpty(event.DateTime)
+	captureTimestamp := ptrIfNotEmpty(event.CaptureTimestamp)
+	capturedAt, timestampError := s.captureTime(eventDatetime, captureTimestamp, now)
+
+	params := dbgen.InsertEventParams{
 		CarID:            carID,
 		PlateUtf8:        ptrIfNotEmpty(plate),
 		CarState:         ptrIfNotEmpty(carState),
 		SensorProviderID: ptrIfNotEmpty(event.SensorProviderID),
-		EventDatetime:    ptrIfNotEmpty(event.DateTime),
-		CaptureTimestamp: ptrIfNotEmpty(event.CaptureTimestamp),
+		EventDatetime:    eventDatetime,
+		CaptureTimestamp: captureTimestamp,
 		PlateCountry:     ptrIfNotEmpty(event.PlateCountry),
 		PlateRegion:      ptrIfNotEmpty(event.PlateRegion),
 		PlateRegionCode:  ptrIfNotEmpty(event.PlateRegionCode),
@@ -324,63 +699,154 @@ func (s *Server) HandleAPI(w http.ResponseWriter, r *http.Request) {
 		ConfidenceColor:  confColor,
 		CameraSerial:     camSerial,
 		CameraIp:         camIP,
-		RawJson:          &rawJSONStr,
+		Lane:             lane,
+		Direction:        ptrIfNotEmpty(normalizeDirection(event.Direction)),
+		RawJson:          rawJSONStr,
+		Unrecognized:     plate == "",
+		Source:           "camera",
+		NodeID:           ptrIfNotEmpty(s.NodeID),
+		Uid:              &uid,
+		CapturedAt:       capturedAt,
+		TimestampError:   timestampError,
+		ArrivalDelayMs:   arrivalDelay(capturedAt, now),
 		CreatedAt:        now,
-	})
+	}
+
+	// Camera identity for quotas and acknowledgments
+	camera := event.SensorProviderID
+	if camSerial != nil {
+		camera = *camSerial
+	}
+	p := parsedEvent{Event: event, Params: params, Plate: plate, Camera: camera, AutoCarID: autoCarID}
+	p.DedupKey = dedupKey(p, rawJSON)
+	return p, nil
+}
+
+// normalizeDirection maps the direction words cameras use to approaching
+// or leaving; anything else is kept as sent
+func normalizeDirection(v string) string {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "approaching", "approach", "obverse", "forward", "front", "towards", "toward", "in", "incoming":
+		return "approaching"
+	case "leaving", "leave", "reverse", "away", "back", "rear", "out", "outgoing", "going":
+		return "leaving"
+	}
+	return strings.TrimSpace(v)
+}
+
+// ingestEvent normalizes a camera event and stores it with its images
+func (s *Server) ingestEvent(ctx context.Context, req ingestRequest) (ingestResult, error) {
+	p, err := s.parseEvent(req)
 	if err != nil {
-		slog.Error("failed to insert event", "error", err)
-		s.jsonError(w, "database error", http.StatusInternalServerError)
-		return
+		return ingestResult{}, err
 	}
+	rawJSON, jsonFilename, uploadedImages := req.RawJSON, req.JSONFilename, req.Images
+	event, params, plate, camera := p.Event, p.Params, p.Plate, p.Camera
+	now, uid := req.ReceivedAt, req.UID
 
-	imageCount := 0
+	q := dbgen.New(s.DB)
+	orig, duplicate := s.findDuplicate(ctx, q, p.DedupKey, now)
+	if duplicate && !s.DedupLink {
+		slog.Info("duplicate message ignored", "id", orig.EventID, "camera", camera, "packet", event.PacketCounter)
+		return ingestResult{ID: orig.EventID, UID: deref(orig.Uid), Plate: deref(orig.PlateUtf8), Unrecognized: orig.Unrecognized,
+			Camera: camera, Duplicate: true}, nil
+	}
+	if !duplicate && !p.AutoCarID && isContinuation(deref(params.CarState)) {
+		if open, ok := s.findOpenEvent(ctx, q, params.CarID, camera, params.CameraIp, now); ok {
+			return s.mergeMessage(ctx, q, open, p, req)
+		}
+	}
 
-	// Save JSON to disk
-	if jsonFilename == "" {
-		// Generate filename: id_plate.json
-		safePlate := sanitizeFilename(plate)
-		if safePlate == "" {
-			safePlate = "unknown"
+	// The event, its images and its first message are stored together: a
+	// replayed journal entry is skipped once its UID is in the database
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ingestResult{}, err
+	}
+	defer tx.Rollback()
+	qtx := q.WithTx(tx)
+
+	eventID, err := qtx.InsertEvent(ctx, params)
+	if err != nil {
+		return ingestResult{}, err
+	}
+	if duplicate {
+		if err := qtx.LinkDuplicateEvent(ctx, dbgen.LinkDuplicateEventParams{DuplicateOf: &orig.EventID, ID: eventID}); err != nil {
+			slog.Warn("link duplicate event", "id", eventID, "original", orig.EventID, "error", err)
 		}
-		jsonFilename = fmt.Sprintf("%d_%s.json", eventID, safePlate)
-	} else {
-		// Prefix with event ID to ensure uniqueness
-		jsonFilename = fmt.Sprintf("%d_%s", eventID, sanitizeFilename(jsonFilename))
 	}
-	jsonPath := filepath.Join(s.DataDir, "json", jsonFilename)
-	if err := os.WriteFile(jsonPath, rawJSON, 0644); err != nil {
-		slog.Warn("failed to save JSON to disk", "error", err)
-	} else {
-		q.UpdateEventJsonFilename(r.Context(), dbgen.UpdateEventJsonFilenameParams{
-			JsonFilename: &jsonFilename,
-			ID:           eventID,
-		})
+
+	// Save JSON to disk (image-only events have none)
+	if len(rawJSON) > 0 {
+		if jsonFilename == "" {
+			// Generate filename: id_plate.json
+			safePlate := sanitizeFilename(plate)
+			if safePlate == "" {
+				safePlate = "unknown"
+			}
+			jsonFilename = fmt.Sprintf("%d_%s.json", eventID, safePlate)
+		} else {
+			// Prefix with event ID to ensure uniqueness
+			jsonFilename = fmt.Sprintf("%d_%s", eventID, sanitizeFilename(jsonFilename))
+		}
+		s.storeEventJSON(ctx, qtx, eventID, jsonFilename, rawJSON)
+	}
+
+	imageCount, rejected, err := s.storeEventImages(ctx, qtx, eventID, plate, camera, uploadedImages, event.ImageArray, now)
+	if err != nil {
+		return ingestResult{}, err
+	}
+	s.recordMessage(ctx, qtx, eventID, req, p, nil, imageCount)
+	s.ingestVIN(ctx, qtx, eventID, event)
+	if err := tx.Commit(); err != nil {
+		s.resetQuotaUsage() // its images were counted
+		return ingestResult{}, err
 	}
+	if imageCount > 0 {
+		s.queueMeasure()
+	}
+
+	slog.Info("event recorded", "id", eventID, "plate", plate, "images", imageCount, "unrecognized", plate == "", "duplicate", duplicate)
+
+	if plate == "" && imageCount > 0 {
+		s.queueOCR(eventID)
+	}
+	if !duplicate {
+		s.queueSnapshot(eventID, params, plate, camera)
+		s.matchWatchlists(ctx, q, eventID, plate, camera, now)
+	}
+
+	return ingestResult{ID: eventID, UID: uid, Plate: plate, Images: imageCount, Unrecognized: plate == "", Camera: camera, Rejected: rejected,
+		Duplicate: duplicate}, nil
+}
 
+// storeEventImages saves multipart and base64 images of an event to the
+// database and disk, subject to storage quotas. It returns the number of
+// images stored and rejected; a database error stops it, so the caller's
+// transaction can be rolled back rather than committing a partial event.
+func (s *Server) storeEventImages(ctx context.Context, q *dbgen.Queries, eventID int64, plate, camera string, uploadedImages []uploadedImage, embedded []embeddedImage, now time.Time) (imageCount, rejected int, err error) {
 	// Save uploaded images
 	for i, img := range uploadedImages {
-		// Detect image type from filename
-		imgType := "uploaded"
-		lowerName := strings.ToLower(img.Filename)
-		if strings.Contains(lowerName, "lpup") || strings.Contains(lowerName, "plate") {
-			imgType = "plate"
-		} else if strings.Contains(lowerName, "roi") || strings.Contains(lowerName, "vehicle") {
-			imgType = "vehicle"
-		}
-		
-		imgID, err := s.insertImageWithID(r.Context(), q, dbgen.InsertImageParams{
+		if !s.admitImage(ctx, q, camera, int64(len(img.Data))) {
+			rejected++
+			continue
+		}
+		imgType := img.imageType()
+
+		imgID, err := q.InsertImage(ctx, dbgen.InsertImageParams{
 			EventID:   eventID,
 			ImageType: ptr(imgType),
 			Filename:  &img.Filename,
 			ImageData: img.Data,
+			SizeBytes: int64(len(img.Data)),
 			CreatedAt: now,
 		})
 		if err != nil {
-			slog.Warn("failed to save uploaded image", "error", err)
-			continue
+			s.resetQuotaUsage()
+			return imageCount, rejected, fmt.Errorf("save uploaded image %s: %w", img.Filename, err)
 		}
 		imageCount++
-		
+
 		// Save to disk
 		diskFilename := fmt.Sprintf("%d_%s", imgID, sanitizeFilename(img.Filename))
 		if diskFilename == fmt.Sprintf("%d_", imgID) {
@@ -390,19 +856,11 @@ func (s *Server) HandleAPI(w http.ResponseWriter, r *http.Request) {
 			}
 			diskFilename = fmt.Sprintf("%d_%s_%d.jpg", imgID, safePlate, i)
 		}
-		imgPath := filepath.Join(s.DataDir, "images", diskFilename)
-		if err := os.WriteFile(imgPath, img.Data, 0644); err != nil {
-			slog.Warn("failed to save image to disk", "error", err)
-		} else {
-			q.UpdateImageDiskFilename(r.Context(), dbgen.UpdateImageDiskFilenameParams{
-				DiskFilename: &diskFilename,
-				ID:           imgID,
-			})
-		}
+		s.storeImageFile(ctx, q, imgID, diskFilename, img.Data)
 	}
 
 	// Extract and save base64 images from JSON
-	for i, img := range event.ImageArray {
+	for i, img := range embedded {
 		if img.BinaryImage == "" {
 			continue
 		}
@@ -411,6 +869,10 @@ func (s *Server) HandleAPI(w http.ResponseWriter, r *http.Request) {
 			slog.Warn("failed to decode base64 image", "index", i, "error", err)
 			continue
 		}
+		if !s.admitImage(ctx, q, camera, int64(len(decoded))) {
+			rejected++
+			continue
+		}
 		imgType := img.ImageType
 		if imgType == "" {
 			imgType = "embedded"
@@ -420,47 +882,32 @@ func (s *Server) HandleAPI(w http.ResponseWriter, r *http.Request) {
 			ext = "jpg"
 		}
 		filename := fmt.Sprintf("%s_%d.%s", imgType, i, ext)
-
-		imgID, err := s.insertImageWithID(r.Context(), q, dbgen.InsertImageParams{
-			EventID:   eventID,
-			ImageType: &imgType,
-			Filename:  &filename,
-			ImageData: decoded,
-			CreatedAt: now,
+		frameTime, _ := s.captureTime(nil, ptrIfNotEmpty(img.Timestamp), now)
+
+		imgID, err := q.InsertImage(ctx, dbgen.InsertImageParams{
+			EventID:    eventID,
+			ImageType:  &imgType,
+			Filename:   &filename,
+			ImageData:  decoded,
+			SizeBytes:  int64(len(decoded)),
+			CreatedAt:  now,
+			CapturedAt: frameTime,
 		})
 		if err != nil {
-			slog.Warn("failed to save embedded image", "error", err)
-			continue
+			s.resetQuotaUsage()
+			return imageCount, rejected, fmt.Errorf("save embedded image %d: %w", i, err)
 		}
 		imageCount++
-		
+
 		// Save to disk
 		safePlate := sanitizeFilename(plate)
 		if safePlate == "" {
 			safePlate = "unknown"
 		}
 		diskFilename := fmt.Sprintf("%d_%s_%s.%s", imgID, safePlate, imgType, ext)
-		imgPath := filepath.Join(s.DataDir, "images", diskFilename)
-		if err := os.WriteFile(imgPath, decoded, 0644); err != nil {
-			slog.Warn("failed to save image to disk", "error", err)
-		} else {
-			q.UpdateImageDiskFilename(r.Context(), dbgen.UpdateImageDiskFilenameParams{
-				DiskFilename: &diskFilename,
-				ID:           imgID,
-			})
-		}
+		s.storeImageFile(ctx, q, imgID, diskFilename, decoded)
 	}
-
-	slog.Info("event recorded", "id", eventID, "plate", plate, "images", imageCount)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"success": true,
-		"message": "event recorded",
-		"id":      eventID,
-		"plate":   plate,
-		"images":  imageCount,
-	})
+	return imageCount, rejected, nil
 }
 
 func (s *Server) jsonError(w http.ResponseWriter, msg string, status int) {
@@ -476,21 +923,41 @@ func (s *Server) jsonError(w http.ResponseWriter, msg string, status int) {
 func (s *Server) HandleRoot(w http.ResponseWriter, r *http.Request) {
 	q := dbgen.New(s.DB)
 	count, _ := q.CountCurrentEvents(r.Context())
-	events, _ := q.GetRecentEvents(r.Context(), 1000)
 	archives, _ := q.GetArchives(r.Context())
+	unrecognized, _ := q.CountUnrecognizedEvents(r.Context())
+	var gaps []sessionGap
+	if count > 0 && s.IdleGap > 0 {
+		gaps, _ = s.sessionGaps(r.Context(), s.IdleGap)
+	}
+	_, hits := s.flaggedHits(r.Context(), q, recentLimit(r))
+	if len(hits) > 5 {
+		hits = hits[:5]
+	}
 
 	data := struct {
-		Hostname   string
-		EventCount int64
-		Events     []dbgen.GetRecentEventsRow
-		Archives   []dbgen.Archive
-		ArchiveID  int64
+		Hostname     string
+		EventCount   int64
+		Archives     []dbgen.Archive
+		Held         map[int64]bool
+		ArchiveID    int64
+		Unrecognized int64
+		Gaps         []sessionGap
+		Order        string
+		ShowAllURL   string
+		User         string
+		Hits         []dbgen.GetWatchlistHitsRow
 	}{
-		Hostname:   s.Hostname,
-		EventCount: count,
-		Events:     events,
-		Archives:   archives,
-		ArchiveID:  0,
+		Hostname:     s.Hostname,
+		EventCount:   count,
+		Archives:     archives,
+		Held:         s.heldArchives(r.Context()),
+		ArchiveID:    0,
+		Unrecognized: unrecognized,
+		Gaps:         gaps,
+		Order:        r.URL.Query().Get("order"),
+		ShowAllURL:   showAllURL(r),
+		User:         sessionUser(r),
+		Hits:         hits,
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -500,6 +967,45 @@ func (s *Server) HandleRoot(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxPageRows is the soft cap on events rendered into a page; ?limit=N or
+// ?limit=all overrides it
+const maxPageRows = 500
+
+// pageLimit returns the number of events to render, 0 for all
+func pageLimit(r *http.Request) int {
+	v := r.URL.Query().Get("limit")
+	if v == "all" {
+		return 0
+	}
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return n
+	}
+	return maxPageRows
+}
+
+// showAllURL is the current page without the row cap
+func showAllURL(r *http.Request) string {
+	q := r.URL.Query()
+	q.Set("limit", "all")
+	return "?" + q.Encode()
+}
+
+// recentLimit is the SQL limit for the dashboard event list (-1 for all)
+func recentLimit(r *http.Request) int64 {
+	if n := pageLimit(r); n > 0 {
+		return int64(n)
+	}
+	return -1
+}
+
+// capRows trims rows to the page limit
+func capRows[T any](rows []T, limit int) []T {
+	if limit > 0 && len(rows) > limit {
+		return rows[:limit]
+	}
+	return rows
+}
+
 // HandleArchive shows archived events
 func (s *Server) HandleArchive(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
@@ -517,7 +1023,38 @@ func (s *Server) HandleArchive(w http.ResponseWriter, r *http.Request) {
 	}
 
 	events, _ := q.GetArchivedEvents(r.Context(), &id)
+	if r.URL.Query().Get("order") == "received" {
+		sortByReceived(events, func(e dbgen.GetArchivedEventsRow) time.Time { return e.CreatedAt })
+	}
 	archives, _ := q.GetArchives(r.Context())
+	var lock *dbgen.ArchiveLock
+	var verified string // last verification: "", "ok" or "altered"
+	if l, err := q.GetArchiveLock(r.Context(), id); err == nil {
+		lock = &l
+		if l.VerifiedOk != nil {
+			verified = map[bool]string{true: "ok", false: "altered"}[*l.VerifiedOk]
+		}
+	}
+
+	hold, _ := s.archiveHoldStatus(r.Context(), id)
+	var reviewer *dbgen.ArchiveReviewer
+	if rv, err := q.GetArchiveReviewer(r.Context(), id); err == nil {
+		reviewer = &rv
+	}
+	var review reviewStatus
+	if plan, err := loadReviewPlan(r.Context(), q, id); err == nil {
+		review = plan.status()
+	}
+	var users []string
+	if all, err := q.GetUsers(r.Context()); err == nil {
+		for _, u := range all {
+			users = append(users, u.Username)
+		}
+	}
+	var compaction *dbgen.ArchiveCompaction
+	if c, err := q.GetArchiveCompaction(r.Context(), id); err == nil {
+		compaction = &c
+	}
 
 	data := struct {
 		Hostname   string
@@ -526,13 +1063,33 @@ func (s *Server) HandleArchive(w http.ResponseWriter, r *http.Request) {
 		Archives   []dbgen.Archive
 		ArchiveID  int64
 		Archive    dbgen.Archive
+		Total      int
+		ShowAllURL string
+		Lock       *dbgen.ArchiveLock
+		Verified   string
+		Held       map[int64]bool
+		Hold       archiveHoldStatus
+		Reviewer   *dbgen.ArchiveReviewer
+		Review     reviewStatus // slices and progress per reviewer
+		Users      []string     // candidates for review
+		Compaction *dbgen.ArchiveCompaction
 	}{
 		Hostname:   s.Hostname,
 		EventCount: archive.EventCount,
-		Events:     events,
+		Events:     capRows(events, pageLimit(r)),
 		Archives:   archives,
 		ArchiveID:  id,
 		Archive:    archive,
+		Total:      len(events),
+		ShowAllURL: showAllURL(r),
+		Lock:       lock,
+		Verified:   verified,
+		Held:       s.heldArchives(r.Context()),
+		Hold:       hold,
+		Reviewer:   reviewer,
+		Review:     review,
+		Users:      users,
+		Compaction: compaction,
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -570,14 +1127,100 @@ func (s *Server) HandleCompare(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// True plates entered or matched with a test vehicle
+	plates := make(map[int64]*dbgen.ComparePlate)
+	if rows, err := q.GetComparePlates(r.Context(), id); err == nil {
+		for _, p := range rows {
+			plates[p.EventID] = &p
+		}
+	}
+
+	// Imported ground truth, and the verdicts the auto-compare job set
+	// that no reviewer changed since
+	groundTruth := make(map[int64]*dbgen.GroundTruth)
+	if rows, err := q.GetGroundTruth(r.Context(), id); err == nil {
+		for _, g := range rows {
+			groundTruth[g.EventID] = &g
+		}
+	}
+	auto := make(map[string]bool) // key: "eventID_field"
+	if rows, err := q.GetAutoCompareResults(r.Context(), id); err == nil {
+		for _, a := range rows {
+			key := fmt.Sprintf("%d_%s", a.EventID, a.Field)
+			auto[key] = incorrectMap[key] == a.IsIncorrect
+		}
+	}
+
+	locked, _ := s.archiveLocked(r.Context(), id)
+
+	stats, err := s.compareStats(r.Context(), id)
+	if err != nil {
+		slog.Warn("failed to load archive stats", "archive_id", id, "error", err)
+	}
+
+	// Review assignments: whose each event is, and how far the user is
+	user := sessionUser(r)
+	var owners map[int64]string
+	var mine *reviewProgress
+	second := make(map[int64]bool)
+	truth := make(map[int64]string) // second opinion event → incorrect fields of the archive
+	if plan, err := loadReviewPlan(r.Context(), q, id); err == nil && plan.assigned() {
+		owners = plan.owners()
+		for _, rp := range plan.status().Reviewers {
+			if rp.Username == user {
+				mine = &rp
+			}
+		}
+		// Second opinions are given blind: the user sees their own verdicts
+		for _, e := range events {
+			if !plan.secondOpinion(e.ID, user) {
+				continue
+			}
+			second[e.ID] = true
+			op := plan.opinions[e.ID]
+			own := compareAnnotation{Plate: op.Plate, Maker: op.Maker, Model: op.Model, Color: op.Color}
+			var fields []string
+			for _, f := range compareFields {
+				key := fmt.Sprintf("%d_%s", e.ID, f)
+				if incorrectMap[key] {
+					fields = append(fields, f)
+				}
+				incorrectMap[key] = own.incorrect(f)
+			}
+			truth[e.ID] = strings.Join(fields, " ")
+		}
+	}
+
 	data := struct {
-		Archive     dbgen.Archive
-		Events      []dbgen.GetArchivedEventsRow
-		Incorrect   map[string]bool
+		Archive       dbgen.Archive
+		Events        []dbgen.GetArchivedEventsRow
+		Incorrect     map[string]bool
+		ExportColumns []exportColumn
+		Locked        bool
+		Stats         compareStats
+		User          string
+		Owners        map[int64]string // event id → assigned reviewer
+		Mine          *reviewProgress  // the user's assigned events
+		Second        map[int64]bool   // events the user gives a second opinion on
+		Truth         map[int64]string
+		Plates        map[int64]*dbgen.ComparePlate // event id → true plate
+		GroundTruth   map[int64]*dbgen.GroundTruth  // event id → imported truth
+		Auto          map[string]bool               // verdicts set by the auto-compare job
 	}{
-		Archive:   archive,
-		Events:    events,
-		Incorrect: incorrectMap,
+		Archive:       archive,
+		Events:        events,
+		Incorrect:     incorrectMap,
+		ExportColumns: exportColumns,
+		Locked:        locked,
+		Stats:         stats,
+		User:          user,
+		Owners:        owners,
+		Mine:          mine,
+		Second:        second,
+		Truth:         truth,
+		Plates:        plates,
+		GroundTruth:   groundTruth,
+		Auto:          auto,
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -607,13 +1250,44 @@ func (s *Server) HandleCompareToggle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate field
-	validFields := map[string]bool{"plate": true, "maker": true, "model": true, "color": true}
-	if !validFields[req.Field] {
+	if !slices.Contains(compareFields, req.Field) {
 		http.Error(w, "invalid field", http.StatusBadRequest)
 		return
 	}
 
+	if locked, err := s.archiveLocked(r.Context(), archiveID); err != nil || locked {
+		http.Error(w, "archive is locked", http.StatusConflict)
+		return
+	}
+
 	q := dbgen.New(s.DB)
+	user := sessionUser(r)
+	if user != "" {
+		plan, err := loadReviewPlan(r.Context(), q, archiveID)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		// Someone else's events are only changed once the user confirms
+		if r.URL.Query().Get("force") != "1" {
+			if err := plan.check(req.EventID, user); err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+		}
+		if plan.secondOpinion(req.EventID, user) {
+			var u compareUpdate
+			u.set(req.Field, &req.Incorrect)
+			if _, err := plan.saveSecondOpinion(r.Context(), q, archiveID, req.EventID, user, u); err != nil {
+				slog.Warn("failed to save second opinion", "error", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok":true}`))
+			return
+		}
+	}
 	err = q.SetCompareResult(r.Context(), dbgen.SetCompareResultParams{
 		ArchiveID:   archiveID,
 		EventID:     req.EventID,
@@ -625,6 +1299,23 @@ func (s *Server) HandleCompareToggle(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "database error", http.StatusInternalServerError)
 		return
 	}
+	// The verdict overrides the one classified from a true plate
+	if req.Field == "plate" {
+		if err := q.DeleteComparePlate(r.Context(), dbgen.DeleteComparePlateParams{ArchiveID: archiveID, EventID: req.EventID}); err != nil {
+			slog.Warn("failed to remove true plate", "error", err)
+		}
+	}
+	if user != "" {
+		if err := q.RecordEventReview(r.Context(), dbgen.RecordEventReviewParams{
+			ArchiveID:  archiveID,
+			EventID:    req.EventID,
+			Username:   user,
+			ReviewedAt: time.Now(),
+		}); err != nil {
+			slog.Warn("failed to record review", "error", err)
+		}
+	}
+	s.invalidateCompareStats(r.Context(), q, &archiveID)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"ok":true}`))
@@ -646,10 +1337,58 @@ func (s *Server) HandleCompareExport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	events, _ := q.GetArchivedEvents(r.Context(), &id)
+	opts, err := s.exportOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := s.compareWorkbook(r.Context(), archive, opts, nil)
+	if err != nil {
+		slog.Warn("failed to write xlsx", "error", err)
+		http.Error(w, "failed to generate xlsx", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeExport(w, r, compareExportFilename(archive), xlsxContentType, data)
+}
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+func compareExportFilename(archive dbgen.Archive) string {
+	archiveName := "export"
+	if archive.Name != nil {
+		archiveName = sanitizeFilename(*archive.Name)
+	}
+	return fmt.Sprintf("compare_%s.xlsx", archiveName)
+}
+
+// compareWorkbook builds the compare XLSX of an archive. progress, if set, is
+// called after each event row; cancelling ctx stops the export.
+func (s *Server) compareWorkbook(ctx context.Context, archive dbgen.Archive, opts compareExportOptions, progress func(done, total int)) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := s.writeCompareWorkbook(ctx, &buf, archive, opts, progress); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeCompareWorkbook writes the XLSX export of an archive to w
+func (s *Server) writeCompareWorkbook(ctx context.Context, w io.Writer, archive dbgen.Archive, opts compareExportOptions, progress func(done, total int)) error {
+	q := dbgen.New(s.DB)
+	id := archive.ID
+	events, err := q.GetArchivedEvents(ctx, &id)
+	if err != nil {
+		return err
+	}
+
+	stats, err := s.compareStats(ctx, id)
+	if err != nil {
+		return err
+	}
 
 	// Load saved compare results from database
-	results, _ := q.GetCompareResults(r.Context(), id)
+	results, _ := q.GetCompareResults(ctx, id)
 	incorrectPlates := make(map[int64]bool)
 	incorrectMakers := make(map[int64]bool)
 	incorrectModels := make(map[int64]bool)
@@ -670,6 +1409,16 @@ func (s *Server) HandleCompareExport(w http.ResponseWriter, r *http.Request) {
 			incorrectColors[r.EventID] = true
 		}
 	}
+	plates, err := q.GetComparePlates(ctx, id)
+	if err != nil {
+		return err
+	}
+	partialPlates := make(map[int64]bool)
+	for _, p := range plates {
+		if p.Partial {
+			partialPlates[p.EventID] = true
+		}
+	}
 
 	// Create Excel file
 	f := excelize.NewFile()
@@ -690,6 +1439,17 @@ func (s *Server) HandleCompareExport(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
+	orangeStyle, _ := f.NewStyle(&excelize.Style{
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"FFE5B4"}, Pattern: 1},
+		Font: &excelize.Font{Color: "8A4B08"},
+		Border: []excelize.Border{
+			{Type: "left", Color: "E0E0E0", Style: 1},
+			{Type: "top", Color: "E0E0E0", Style: 1},
+			{Type: "bottom", Color: "E0E0E0", Style: 1},
+			{Type: "right", Color: "E0E0E0", Style: 1},
+		},
+	})
+
 	headerStyle, _ := f.NewStyle(&excelize.Style{
 		Fill: excelize.Fill{Type: "pattern", Color: []string{"F8F9FA"}, Pattern: 1},
 		Font: &excelize.Font{Bold: true},
@@ -701,8 +1461,15 @@ func (s *Server) HandleCompareExport(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
+	linkStyle, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "0563C1", Underline: "single"},
+	})
+
 	// Headers
-	headers := []string{"TIMESTAMP", "CAR_ID", "LPR_UTF8", "LP_CROP", "VEHICLE", "CAR_MAKER", "CAR_MODEL", "CAR_COLOR"}
+	headers := []string{"TIMESTAMP", "CAR_ID", "LPR_UTF8", "LP_CROP", "VEHICLE", "CAR_MAKER", "CAR_MODEL", "CAR_COLOR", "EVENT_ID"}
+	for _, c := range opts.Columns {
+		headers = append(headers, c.Header)
+	}
 	for i, h := range headers {
 		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
 		f.SetCellValue(sheetName, cell, h)
@@ -718,15 +1485,20 @@ func (s *Server) HandleCompareExport(w http.ResponseWriter, r *http.Request) {
 	f.SetColWidth(sheetName, "F", "F", 15) // CAR_MAKER
 	f.SetColWidth(sheetName, "G", "G", 25) // CAR_MODEL
 	f.SetColWidth(sheetName, "H", "H", 12) // CAR_COLOR
+	f.SetColWidth(sheetName, "I", "I", 10) // EVENT_ID
+	for i, c := range opts.Columns {
+		col, _ := excelize.ColumnNumberToName(10 + i)
+		f.SetColWidth(sheetName, col, col, c.Width)
+	}
 
-	// Statistics counters
-	var plateCorrect, plateIncorrect int
-	var makerCorrect, makerIncorrect int
-	var modelCorrect, modelIncorrect int
-	var colorCorrect, colorIncorrect int
-
-	// Data rows
+	// Data rows; images are loaded and downscaled in parallel ahead of them
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	nextPictures := s.prefetchPictures(ctx, q, events, opts.Images)
 	for i, e := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		row := i + 2
 
 		// Set row height for images
@@ -744,40 +1516,40 @@ func (s *Server) HandleCompareExport(w http.ResponseWriter, r *http.Request) {
 		// CAR_ID
 		f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), e.CarID)
 
-		// LPR_UTF8
+		// LPR_UTF8 - unrecognized events are marked as missed reads once a
+		// plate has been entered manually
 		plateCell := fmt.Sprintf("C%d", row)
-		if e.PlateUtf8 != nil {
+		switch {
+		case e.PlateUtf8 != nil:
 			f.SetCellValue(sheetName, plateCell, *e.PlateUtf8)
+		case e.ManualPlate != nil:
+			f.SetCellValue(sheetName, plateCell, "no read ("+*e.ManualPlate+")")
+		case e.Unrecognized:
+			f.SetCellValue(sheetName, plateCell, "no read")
 		}
-		if incorrectPlates[e.ID] {
+		switch {
+		case e.Unrecognized && e.ManualPlate == nil:
+			// Not marked until a reviewer enters the plate
+		case incorrectPlates[e.ID] && partialPlates[e.ID]:
+			f.SetCellStyle(sheetName, plateCell, plateCell, orangeStyle)
+		case incorrectPlates[e.ID] || e.Unrecognized || e.Source == "manual":
 			f.SetCellStyle(sheetName, plateCell, plateCell, redStyle)
-			plateIncorrect++
-		} else {
-			plateCorrect++
 		}
 
-		// LP_CROP image - handle various integer types from SQLite
-		plateImgID := toInt64(e.PlateImageID)
-		if plateImgID > 0 {
-			imgData, err := q.GetImageData(r.Context(), plateImgID)
-			if err == nil && len(imgData) > 0 {
-				f.AddPictureFromBytes(sheetName, fmt.Sprintf("D%d", row), &excelize.Picture{
-					Extension: ".jpg",
-					File:      imgData,
-					Format:    &excelize.GraphicOptions{ScaleX: 0.3, ScaleY: 0.3, Positioning: "oneCell"},
-				})
-			}
+		// LP_CROP and VEHICLE images, prepared by the prefetch workers
+		pictures, ok := nextPictures(i)
+		if !ok {
+			return ctx.Err()
 		}
-
-		// VEHICLE image
-		vehicleImgID := toInt64(e.VehicleImageID)
-		if vehicleImgID > 0 {
-			imgData, err := q.GetImageData(r.Context(), vehicleImgID)
-			if err == nil && len(imgData) > 0 {
-				f.AddPictureFromBytes(sheetName, fmt.Sprintf("E%d", row), &excelize.Picture{
+		for _, p := range []struct {
+			cell    string
+			picture *exportPicture
+		}{{"D", pictures.plate}, {"E", pictures.vehicle}} {
+			if p.picture != nil {
+				f.AddPictureFromBytes(sheetName, fmt.Sprintf("%s%d", p.cell, row), &excelize.Picture{
 					Extension: ".jpg",
-					File:      imgData,
-					Format:    &excelize.GraphicOptions{ScaleX: 0.15, ScaleY: 0.15, Positioning: "oneCell"},
+					File:      p.picture.data,
+					Format:    &excelize.GraphicOptions{ScaleX: p.picture.scale, ScaleY: p.picture.scale, Positioning: "oneCell"},
 				})
 			}
 		}
@@ -787,11 +1559,8 @@ func (s *Server) HandleCompareExport(w http.ResponseWriter, r *http.Request) {
 		if e.VehicleMake != nil {
 			f.SetCellValue(sheetName, makerCell, *e.VehicleMake)
 		}
-		if incorrectMakers[e.ID] {
+		if incorrectMakers[e.ID] || e.Source == "manual" {
 			f.SetCellStyle(sheetName, makerCell, makerCell, redStyle)
-			makerIncorrect++
-		} else {
-			makerCorrect++
 		}
 
 		// CAR_MODEL
@@ -799,11 +1568,8 @@ func (s *Server) HandleCompareExport(w http.ResponseWriter, r *http.Request) {
 		if e.VehicleModel != nil {
 			f.SetCellValue(sheetName, modelCell, *e.VehicleModel)
 		}
-		if incorrectModels[e.ID] {
+		if incorrectModels[e.ID] || e.Source == "manual" {
 			f.SetCellStyle(sheetName, modelCell, modelCell, redStyle)
-			modelIncorrect++
-		} else {
-			modelCorrect++
 		}
 
 		// CAR_COLOR
@@ -811,11 +1577,27 @@ func (s *Server) HandleCompareExport(w http.ResponseWriter, r *http.Request) {
 		if e.VehicleColor != nil {
 			f.SetCellValue(sheetName, colorCell, *e.VehicleColor)
 		}
-		if incorrectColors[e.ID] {
+		if incorrectColors[e.ID] || e.Source == "manual" {
 			f.SetCellStyle(sheetName, colorCell, colorCell, redStyle)
-			colorIncorrect++
-		} else {
-			colorCorrect++
+		}
+
+		// EVENT_ID - links back to the event page with the full-resolution images
+		idCell := fmt.Sprintf("I%d", row)
+		f.SetCellValue(sheetName, idCell, e.ID)
+		f.SetCellHyperLink(sheetName, idCell, opts.eventURL(e.ID), "External",
+			excelize.HyperlinkOpts{Tooltip: ptr("Open event")})
+		f.SetCellStyle(sheetName, idCell, idCell, linkStyle)
+
+		// Optional columns
+		for j, c := range opts.Columns {
+			cell, _ := excelize.CoordinatesToCellName(10+j, row)
+			if v := c.value(e); v != nil {
+				f.SetCellValue(sheetName, cell, v)
+			}
+		}
+
+		if progress != nil {
+			progress(i+1, len(events))
 		}
 	}
 
@@ -830,8 +1612,9 @@ func (s *Server) HandleCompareExport(w http.ResponseWriter, r *http.Request) {
 	f.SetCellValue(statsSheet, "E1", "Accuracy %")
 	f.SetCellStyle(statsSheet, "A1", "E1", headerStyle)
 
-	total := len(events)
-	writeStatRow := func(row int, field string, correct, incorrect int) {
+	writeStatRow := func(row int, field string, a accuracy) {
+		correct, incorrect := a.Correct, a.Incorrect
+		total := correct + incorrect
 		pct := 0.0
 		if total > 0 {
 			pct = float64(correct) / float64(total) * 100
@@ -843,36 +1626,83 @@ func (s *Server) HandleCompareExport(w http.ResponseWriter, r *http.Request) {
 		f.SetCellValue(statsSheet, fmt.Sprintf("E%d", row), fmt.Sprintf("%.1f%%", pct))
 	}
 
-	writeStatRow(2, "LPR (Plate)", plateCorrect, plateIncorrect)
-	writeStatRow(3, "CAR_MAKER", makerCorrect, makerIncorrect)
-	writeStatRow(4, "CAR_MODEL", modelCorrect, modelIncorrect)
-	writeStatRow(5, "CAR_COLOR", colorCorrect, colorIncorrect)
+	writeStatRow(2, "LPR (Plate)", stats.Fields["plate"])
+	writeStatRow(3, "CAR_MAKER", stats.Fields["maker"])
+	writeStatRow(4, "CAR_MODEL", stats.Fields["model"])
+	writeStatRow(5, "CAR_COLOR", stats.Fields["color"])
 
-	f.SetColWidth(statsSheet, "A", "A", 15)
-	f.SetColWidth(statsSheet, "B", "E", 12)
-
-	// Write to buffer
-	var buf bytes.Buffer
-	if err := f.Write(&buf); err != nil {
-		slog.Warn("failed to write xlsx", "error", err)
-		http.Error(w, "failed to generate xlsx", http.StatusInternalServerError)
-		return
+	// Record which node(s) the events came from so merged workbooks stay attributable
+	var nodes []string
+	seenNodes := make(map[string]bool)
+	for _, e := range events {
+		if e.NodeID != nil && !seenNodes[*e.NodeID] {
+			seenNodes[*e.NodeID] = true
+			nodes = append(nodes, *e.NodeID)
+		}
+	}
+	f.SetCellValue(statsSheet, "A7", "Node")
+	f.SetCellValue(statsSheet, "B7", strings.Join(nodes, ", "))
+	f.SetCellValue(statsSheet, "A8", "Computed")
+	f.SetCellValue(statsSheet, "B8", stats.ComputedAt.Format(time.DateTime))
+
+	// Agreement of the reviewers on events reviewed twice
+	if a := stats.Agreement; a != nil {
+		f.SetCellValue(statsSheet, "A10", "Agreement")
+		f.SetCellValue(statsSheet, "B10", "Events")
+		f.SetCellValue(statsSheet, "C10", "Agreed")
+		f.SetCellValue(statsSheet, "D10", "Agreed %")
+		f.SetCellValue(statsSheet, "E10", "Cohen's κ")
+		f.SetCellStyle(statsSheet, "A10", "E10", headerStyle)
+		for i, fa := range a.Fields {
+			row := 11 + i
+			f.SetCellValue(statsSheet, fmt.Sprintf("A%d", row), fa.Label())
+			f.SetCellValue(statsSheet, fmt.Sprintf("B%d", row), fa.Events)
+			f.SetCellValue(statsSheet, fmt.Sprintf("C%d", row), fa.Agreed)
+			f.SetCellValue(statsSheet, fmt.Sprintf("D%d", row), fmt.Sprintf("%.1f%%", fa.Percent))
+			f.SetCellValue(statsSheet, fmt.Sprintf("E%d", row), fa.KappaText())
+		}
 	}
 
-	// Send response
-	archiveName := "export"
-	if archive.Name != nil {
-		archiveName = sanitizeFilename(*archive.Name)
+	// Reads compared with the true plate, below the agreement if any
+	if p := stats.Plates; p != nil {
+		top := 10
+		if stats.Agreement != nil {
+			top += len(stats.Agreement.Fields) + 2
+		}
+		f.SetCellValue(statsSheet, fmt.Sprintf("A%d", top), "Plate matching")
+		f.SetCellValue(statsSheet, fmt.Sprintf("B%d", top), "Events")
+		f.SetCellStyle(statsSheet, fmt.Sprintf("A%d", top), fmt.Sprintf("B%d", top), headerStyle)
+		lenient := 0.0
+		if p.Lenient.Pct != nil {
+			lenient = *p.Lenient.Pct
+		}
+		for i, r := range []struct {
+			label string
+			value any
+		}{
+			{"True plate known", p.Checked},
+			{"Exact", p.Exact},
+			{fmt.Sprintf("Partial (distance ≤ %g)", p.MaxDistance), p.Partial},
+			{"Incorrect", p.Incorrect},
+			{"Mean distance", p.MeanDistance},
+			{"Accuracy incl. partial %", fmt.Sprintf("%.1f%%", lenient)},
+		} {
+			row := top + 1 + i
+			f.SetCellValue(statsSheet, fmt.Sprintf("A%d", row), r.label)
+			f.SetCellValue(statsSheet, fmt.Sprintf("B%d", row), r.value)
+		}
 	}
-	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="compare_%s.xlsx"`, archiveName))
-	w.Write(buf.Bytes())
+
+	f.SetColWidth(statsSheet, "A", "A", 22)
+	f.SetColWidth(statsSheet, "B", "E", 12)
+
+	return f.Write(w)
 }
 
 // HandleClean archives current events
 func (s *Server) HandleClean(w http.ResponseWriter, r *http.Request) {
 	q := dbgen.New(s.DB)
-	
+
 	// Count current events
 	count, err := q.CountCurrentEvents(r.Context())
 	if err != nil || count == 0 {
@@ -902,7 +1732,18 @@ func (s *Server) HandleClean(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Laps of this session go with it
+	if err := q.ArchiveCurrentLaps(r.Context(), dbgen.ArchiveCurrentLapsParams{
+		ArchiveID: &archiveID,
+		EndedAt:   &now,
+	}); err != nil {
+		slog.Warn("failed to archive laps", "error", err)
+	}
+
 	slog.Info("archived events", "archive_id", archiveID, "count", count)
+	s.archiveVINTruth(r.Context(), archiveID)
+	s.archivePlateTruth(r.Context(), archiveID)
+	s.notifySessionEnded(archiveID, name, count)
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
@@ -934,8 +1775,7 @@ func (s *Server) HandleJsonFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jsonPath := filepath.Join(s.DataDir, "json", *event.JsonFilename)
-	data, err := os.ReadFile(jsonPath)
+	data, err := s.getBlob(r.Context(), blobJSONPrefix+*event.JsonFilename)
 	if err != nil {
 		// Fallback to database
 		if event.RawJson != nil {
@@ -969,6 +1809,7 @@ func (s *Server) HandleRawJson(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	event.RawJson = s.eventJSON(r.Context(), event.RawJson, event.JsonFilename)
 	if event.RawJson == nil {
 		http.Error(w, "no JSON available", http.StatusNotFound)
 		return
@@ -1000,27 +1841,60 @@ func (s *Server) HandleRawJson(w http.ResponseWriter, r *http.Request) {
 // HandleEvent shows a single event
 func (s *Server) HandleEvent(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		http.Error(w, "invalid event id", http.StatusBadRequest)
-		return
+	q := dbgen.New(s.DB)
+
+	// Events are addressable by local ID or by their ULID, which stays the same after merges
+	var event dbgen.Event
+	if isULID(idStr) {
+		ev, err := q.GetEventByUID(r.Context(), &idStr)
+		if err != nil {
+			http.Error(w, "event not found", http.StatusNotFound)
+			return
+		}
+		event = ev
+	} else {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid event id", http.StatusBadRequest)
+			return
+		}
+		ev, err := q.GetEventByID(r.Context(), id)
+		if err != nil {
+			http.Error(w, "event not found", http.StatusNotFound)
+			return
+		}
+		event = ev
 	}
 
-	q := dbgen.New(s.DB)
-	event, err := q.GetEventByID(r.Context(), id)
+	images, _ := q.GetImagesByEventID(r.Context(), event.ID)
+	best, _ := q.GetEventBestImages(r.Context(), event.ID)
+	messages, _ := q.GetEventMessages(r.Context(), event.ID)
+	neighbours, _ := neighbourEvents(r.Context(), q, event)
+
+	plateID, vehicleID := toInt64(best.PlateImageID), toInt64(best.VehicleImageID)
+	video, err := s.CCTV.videoLink(event)
 	if err != nil {
-		http.Error(w, "event not found", http.StatusNotFound)
-		return
+		slog.Warn("cctv video link", "event", event.ID, "error", err)
 	}
 
-	images, _ := q.GetImagesByEventID(r.Context(), id)
-
 	data := struct {
-		Event  dbgen.Event
-		Images []dbgen.GetImagesByEventIDRow
+		Event          dbgen.Event
+		Images         []dbgen.GetImagesByEventIDRow
+		Frames         []eventFrame
+		Messages       []dbgen.EventMessage
+		Neighbours     []dbgen.GetNeighbourEventsRow
+		PlateImageID   int64
+		VehicleImageID int64
+		Video          *videoLink
 	}{
-		Event:  event,
-		Images: images,
+		Event:          event,
+		Images:         images,
+		Frames:         eventFrames(event, images, plateID, vehicleID),
+		Messages:       messages,
+		Neighbours:     neighbours,
+		PlateImageID:   plateID,
+		VehicleImageID: vehicleID,
+		Video:          video,
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -1040,7 +1914,7 @@ func (s *Server) HandleImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	q := dbgen.New(s.DB)
-	data, err := q.GetImageData(r.Context(), id)
+	data, err := s.loadImage(r.Context(), q, id)
 	if err != nil {
 		http.Error(w, "image not found", http.StatusNotFound)
 		return
@@ -1068,7 +1942,7 @@ func (s *Server) HandleImageDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := q.GetImageData(r.Context(), id)
+	data, err := s.loadImage(r.Context(), q, id)
 	if err != nil {
 		http.Error(w, "image data not found", http.StatusNotFound)
 		return
@@ -1086,9 +1960,9 @@ func (s *Server) HandleImageDownload(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
-func (s *Server) renderTemplate(w http.ResponseWriter, name string, data any) error {
+func (s *Server) renderTemplate(w io.Writer, name string, data any) error {
 	path := filepath.Join(s.TemplatesDir, name)
-	tmpl, err := template.ParseFiles(path)
+	tmpl, err := template.New(name).Funcs(s.templateFuncs()).ParseFiles(path)
 	if err != nil {
 		return fmt.Errorf("parse template %q: %w", name, err)
 	}
@@ -1098,7 +1972,7 @@ func (s *Server) renderTemplate(w http.ResponseWriter, name string, data any) er
 	return nil
 }
 
-// HandleDeleteArchive deletes an archive and its files
+// HandleDeleteArchive moves an archive to the trash
 func (s *Server) HandleDeleteArchive(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -1107,38 +1981,12 @@ func (s *Server) HandleDeleteArchive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	q := dbgen.New(s.DB)
-	
-	// Get files to delete
-	files, err := q.GetArchivedEventFiles(r.Context(), &id)
-	if err != nil {
-		slog.Warn("failed to get archive files", "error", err)
-	}
-
-	// Delete files from disk
-	for _, f := range files {
-		if f.JsonFilename != nil && *f.JsonFilename != "" {
-			jsonPath := filepath.Join(s.DataDir, "json", *f.JsonFilename)
-			os.Remove(jsonPath)
-		}
-		if f.DiskFilename != nil && *f.DiskFilename != "" {
-			imgPath := filepath.Join(s.DataDir, "images", *f.DiskFilename)
-			os.Remove(imgPath)
-		}
-	}
-
-	// Delete from database
-	if err := q.DeleteArchiveImages(r.Context(), &id); err != nil {
-		slog.Warn("failed to delete archive images", "error", err)
-	}
-	if err := q.DeleteArchiveEvents(r.Context(), &id); err != nil {
-		slog.Warn("failed to delete archive events", "error", err)
-	}
-	if err := q.DeleteArchive(r.Context(), id); err != nil {
-		slog.Warn("failed to delete archive", "error", err)
+	if err := s.trashArchive(r.Context(), id, sessionUser(r)); err != nil {
+		s.writeArchiveError(w, r, err)
+		return
 	}
 
-	slog.Info("deleted archive", "id", id)
+	slog.Info("moved archive to trash", "id", id)
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
@@ -1168,7 +2016,7 @@ func (s *Server) HandleRenameArchive(w http.ResponseWriter, r *http.Request) {
 	}
 
 	slog.Info("renamed archive", "id", id, "name", name)
-	
+
 	// Redirect back to where they came from
 	referer := r.Header.Get("Referer")
 	if referer == "" {
@@ -1177,38 +2025,320 @@ func (s *Server) HandleRenameArchive(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, referer, http.StatusSeeOther)
 }
 
-// HandleEventsAPI returns recent events as JSON for live updates
+// HandleEventsAPI returns recent events as JSON for live updates, narrowed
+// by the eventQuery filters. With ?since_id= or ?archive= it pages through
+// events by ID instead.
 func (s *Server) HandleEventsAPI(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, err := s.parseEventQuery(query)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.Has("since_id") || query.Has("archive") {
+		s.serveEventsPage(w, r, filter)
+		return
+	}
 	q := dbgen.New(s.DB)
-	events, err := q.GetRecentEvents(r.Context(), 1000)
+	events, err := q.GetRecentEvents(r.Context(), dbgen.GetRecentEventsParams{
+		Plate:        filter.Plate,
+		Camera:       filter.Camera,
+		Country:      filter.Country,
+		CarState:     filter.CarState,
+		CapturedFrom: filter.From,
+		CapturedTo:   filter.To,
+		ByReceived:   r.URL.Query().Get("order") == "received",
+		Limit:        recentLimit(r),
+	})
 	if err != nil {
 		s.jsonError(w, "database error", http.StatusInternalServerError)
 		return
 	}
+	if count, err := filter.count(r.Context(), q, nil); err == nil {
+		w.Header().Set("X-Total-Count", strconv.FormatInt(count, 10))
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(events)
 }
 
-// Serve starts the HTTP server
-func (s *Server) Serve(addr string) error {
+// Serve starts the HTTP server and background tasks and runs until ctx is
+// done (see shutdown.go)
+func (s *Server) Serve(ctx context.Context, addr string) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /{$}", s.HandleRoot)
-	mux.HandleFunc("POST /api", s.HandleAPI)
+	s.ingestRoute(mux, "POST /api", s.HandleAPI)
+	s.ingestRoute(mux, "POST /api/stream", s.HandleStream)
+	s.ingestRoute(mux, "POST /api/validate", s.HandleValidate)
+	s.ingestRoute(mux, "POST /api/hikvision", s.HandleHikvision)
+	s.ingestRoute(mux, "POST /api/dahua", s.HandleDahua)
+	mux.HandleFunc("GET /api/version", s.HandleVersion)
+	mux.HandleFunc("GET /api/formats", s.HandleFormats)
+	mux.HandleFunc("GET /login", s.HandleLoginForm)
+	mux.HandleFunc("POST /login", s.HandleLogin)
+	mux.HandleFunc("POST /logout", s.HandleLogout)
+	for _, pattern := range []string{"GET /api/version", "GET /api/formats", "GET /login", "POST /login", "/static/"} {
+		s.publicRoute(pattern)
+	}
+	mux.HandleFunc("GET /api/quota", s.HandleQuotaAPI)
+	mux.HandleFunc("GET /api/compat", s.HandleCompatStatus)
+	mux.HandleFunc("GET /api/replica", s.HandleReplicaStatus)
+	mux.HandleFunc("POST /api/replica/sync", s.HandleReplicaSync)
 	mux.HandleFunc("GET /api/events", s.HandleEventsAPI)
+	mux.HandleFunc("GET /api/events/stream", s.HandleEventStream)
+	mux.HandleFunc("GET /api/events/export.csv", s.HandleEventsCSV)
+	s.keyRoute(mux, "GET /ws", s.HandleWebSocket)
+	mux.HandleFunc("GET /api/search", s.HandleSearchAPI)
+	mux.HandleFunc("GET /search", s.HandleSearch)
+	mux.HandleFunc("GET /api/analytics/hourly", s.HandleAnalyticsHourly)
+	mux.HandleFunc("GET /api/analytics/daily", s.HandleAnalyticsDaily)
+	mux.HandleFunc("GET /api/analytics/accuracy", s.HandleAnalyticsAccuracy)
+	mux.HandleFunc("GET /api/analytics/accuracy/alarms", s.HandleAccuracyAlarmsAPI)
+	mux.HandleFunc("GET /api/analytics/schedule", s.HandleAnalyticsSchedule)
+	mux.HandleFunc("GET /api/cameras/status", s.HandleCameraStatusAPI)
+	mux.HandleFunc("GET /api/keys", s.HandleAPIKeysAPI)
+	mux.HandleFunc("POST /api/keys", s.HandleCreateAPIKeyAPI)
+	mux.HandleFunc("PATCH /api/keys/{id}", s.HandleUpdateAPIKeyAPI)
+	mux.HandleFunc("DELETE /api/keys/{id}", s.HandleDeleteAPIKeyAPI)
+	mux.HandleFunc("GET /api-keys", s.HandleAPIKeys)
+	mux.HandleFunc("POST /api-keys", s.HandleCreateAPIKey)
+	mux.HandleFunc("POST /api-keys/{id}/{action}", s.HandleAPIKeyAction)
+	mux.HandleFunc("GET /cameras/{id}/setup", s.HandleCameraSetup)
+	mux.HandleFunc("GET /api/cameras/{id}/setup", s.HandleCameraSetupAPI)
+	mux.HandleFunc("GET /timeline", s.HandleTimeline)
+	mux.HandleFunc("GET /cameras/{camera}/timeline", s.HandleTimeline)
+	mux.HandleFunc("GET /api/cameras/{camera}/timeline", s.HandleTimelineAPI)
+	mux.HandleFunc("GET /api/watchlists", s.HandleWatchlistsAPI)
+	mux.HandleFunc("POST /api/watchlists", s.HandleCreateWatchlistAPI)
+	mux.HandleFunc("GET /api/watchlists/hits", s.HandleWatchlistHitsAPI)
+	mux.HandleFunc("GET /api/watchlists/{id}", s.HandleWatchlistAPI)
+	mux.HandleFunc("PATCH /api/watchlists/{id}", s.HandleUpdateWatchlistAPI)
+	mux.HandleFunc("DELETE /api/watchlists/{id}", s.HandleDeleteWatchlistAPI)
+	mux.HandleFunc("POST /api/watchlists/{id}/entries", s.HandleAddWatchlistEntryAPI)
+	mux.HandleFunc("DELETE /api/watchlists/{id}/entries/{entry}", s.HandleDeleteWatchlistEntryAPI)
+	mux.HandleFunc("GET /watchlists", s.HandleWatchlists)
+	mux.HandleFunc("POST /watchlists", s.HandleCreateWatchlist)
+	mux.HandleFunc("POST /watchlists/{id}/{action}", s.HandleWatchlistAction)
+	mux.HandleFunc("POST /watchlists/{id}/entries/add", s.HandleAddWatchlistEntry)
+	mux.HandleFunc("POST /watchlists/{id}/entries/{entry}/delete", s.HandleDeleteWatchlistEntry)
+	mux.HandleFunc("POST /api/events", s.HandleCreateEventAPI)
+	s.keyRoute(mux, "GET /api/export/events", s.HandleExportEvents)
+	mux.HandleFunc("POST /api/import", s.HandleImport)
+	mux.HandleFunc("GET /event/new", s.HandleNewEventForm)
+	mux.HandleFunc("POST /event/new", s.HandleNewEvent)
+	mux.HandleFunc("GET /test", s.HandleIngestTestPage)
+	mux.HandleFunc("POST /api/test/parse", s.HandleIngestTestParse)
 	mux.HandleFunc("GET /event/{id}", s.HandleEvent)
-	mux.HandleFunc("GET /image/{id}", s.HandleImage)
+	mux.HandleFunc("POST /event/{id}/plate", s.HandleSetManualPlate)
+	mux.HandleFunc("POST /event/{id}/vin", s.HandleSetEventVIN)
+	mux.HandleFunc("POST /event/{id}/ocr", s.HandleRunOCR)
+	mux.HandleFunc("POST /event/{id}/images/{imageID}/delete", s.HandleDeleteImage)
+	mux.HandleFunc("POST /event/{id}/images/{imageID}/restore", s.HandleRestoreImage)
+	mux.HandleFunc("POST /event/{id}/images/{imageID}/move", s.HandleMoveImage)
+	mux.HandleFunc("POST /event/{id}/images/{imageID}/classify", s.HandleReclassifyImage)
+	mux.HandleFunc("GET /api/event/{id}", s.HandleEventAPI)
+	mux.HandleFunc("DELETE /api/event/{id}/images/{imageID}", s.HandleDeleteImageAPI)
+	mux.HandleFunc("POST /api/event/{id}/images/{imageID}/restore", s.HandleRestoreImageAPI)
+	mux.HandleFunc("POST /api/event/{id}/images/{imageID}/move", s.HandleMoveImageAPI)
+	mux.HandleFunc("POST /api/event/{id}/images/{imageID}/classify", s.HandleReclassifyImageAPI)
+	s.ingestRoute(mux, "POST /api/event/{id}/images", s.HandleAddEventImages)
+	mux.HandleFunc("GET /unrecognized", s.HandleUnrecognized)
+	s.keyRoute(mux, "GET /image/{id}", s.HandleImage)
 	mux.HandleFunc("GET /image/{id}/download", s.HandleImageDownload)
+	mux.HandleFunc("GET /image/{id}/thumb", s.HandleImageThumb)
+	mux.HandleFunc("GET /image/{id}/similar", s.HandleSimilarImages)
+	mux.HandleFunc("GET /api/image/{id}/similar", s.HandleSimilarImagesAPI)
 	mux.HandleFunc("GET /archive/{id}", s.HandleArchive)
 	mux.HandleFunc("GET /archive/{id}/compare", s.HandleCompare)
+	mux.HandleFunc("GET /archive/{id}/export.csv", s.HandleArchiveCSV)
+	mux.HandleFunc("GET /archive/{id}/download.zip", s.HandleArchiveBundle)
 	mux.HandleFunc("GET /archive/{id}/compare/export", s.HandleCompareExport)
+	mux.HandleFunc("POST /api/archive/{id}/compare/export/jobs", s.HandleStartCompareExport)
+	mux.HandleFunc("GET /api/jobs", s.HandleJobs)
+	mux.HandleFunc("GET /api/jobs/{id}", s.HandleJob)
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", s.HandleCancelJob)
+	mux.HandleFunc("GET /api/jobs/{id}/download", s.HandleJobDownload)
+	mux.HandleFunc("GET /api/signing-key", s.HandleSigningKey)
+	s.publicRoute("GET /api/signing-key")
+	mux.HandleFunc("GET /api/exports/signatures", s.HandleExportSignatures)
+	mux.HandleFunc("GET /api/exports/{sha256}/signature", s.HandleExportSignature)
+	mux.HandleFunc("POST /api/exports/verify", s.HandleVerifyExport)
+	mux.HandleFunc("GET /api/exports/schedules", s.HandleExportSchedulesAPI)
+	mux.HandleFunc("POST /api/exports/schedules/{name}/run", s.HandleRunExportScheduleAPI)
 	mux.HandleFunc("POST /archive/{id}/compare/toggle", s.HandleCompareToggle)
+	mux.HandleFunc("GET /archive/{id}/contact-sheet", s.HandleContactSheet)
+	mux.HandleFunc("GET /api/archive/{id}/compare", s.HandleCompareResultsAPI)
+	mux.HandleFunc("GET /api/archive/{id}/compare/stats", s.HandleCompareStatsAPI)
+	mux.HandleFunc("PUT /api/archive/{id}/compare", s.HandleBulkCompareAPI)
+	mux.HandleFunc("GET /api/archive/{id}/compare/{eventID}", s.HandleCompareResultAPI)
+	mux.HandleFunc("PUT /api/archive/{id}/compare/{eventID}", s.HandlePutCompareResultAPI)
+	mux.HandleFunc("GET /labeling/label-studio.xml", s.HandleLabelStudioConfig)
+	mux.HandleFunc("GET /archive/{id}/labeling/label-studio", s.HandleLabelStudioExport)
+	mux.HandleFunc("GET /archive/{id}/labeling/cvat", s.HandleCVATExport)
+	mux.HandleFunc("POST /api/archive/{id}/labeling/import", s.HandleLabelingImport)
 	mux.HandleFunc("POST /archive/{id}/delete", s.HandleDeleteArchive)
+	mux.HandleFunc("POST /archive/{id}/restore", s.HandleRestoreArchive)
+	mux.HandleFunc("POST /archive/{id}/purge", s.HandlePurgeArchive)
+	mux.HandleFunc("GET /archive/{id}/purge-preview", s.HandlePurgePreview)
+	mux.HandleFunc("GET /api/archive/{id}/purge-preview", s.HandlePurgePreviewAPI)
+	mux.HandleFunc("POST /archive/{id}/compact", s.HandleCompactArchive)
+	mux.HandleFunc("GET /api/archive/{id}/compact", s.HandleCompactArchiveAPI)
+	mux.HandleFunc("POST /api/archive/{id}/compact", s.HandleCompactArchiveAPI)
+	mux.HandleFunc("POST /archive/{id}/hold", s.HandlePlaceHold)
+	mux.HandleFunc("POST /archive/{id}/release", s.HandleReleaseHold)
+	mux.HandleFunc("POST /archive/{id}/reviewer", s.HandleAssignReviewer)
+	mux.HandleFunc("POST /archive/{id}/slices", s.HandleAddReviewSlice)
+	mux.HandleFunc("POST /archive/{id}/slices/distribute", s.HandleDistributeReview)
+	mux.HandleFunc("POST /archive/{id}/slices/{slice}/delete", s.HandleDeleteReviewSlice)
+	mux.HandleFunc("POST /archive/{id}/compare/reviewed", s.HandleMarkReviewed)
+	mux.HandleFunc("POST /api/archive/{id}/compare/vin", s.HandleApplyVINTruth)
+	mux.HandleFunc("POST /api/archive/{id}/compare/plates", s.HandleApplyPlateTruth)
+	mux.HandleFunc("GET /api/archive/{id}/ground-truth", s.HandleGroundTruth)
+	mux.HandleFunc("POST /api/archive/{id}/ground-truth", s.HandleImportGroundTruth)
+	mux.HandleFunc("DELETE /api/archive/{id}/ground-truth", s.HandleDeleteGroundTruth)
+	mux.HandleFunc("POST /api/archive/{id}/compare/auto/jobs", s.HandleStartAutoCompare)
+	mux.HandleFunc("GET /api/archive/{id}/review", s.HandleReviewAPI)
+	mux.HandleFunc("GET /api/reviewers", s.HandleReviewersAPI)
+	mux.HandleFunc("GET /api/archive/{id}/hold", s.HandleArchiveHoldAPI)
+	mux.HandleFunc("PUT /api/archive/{id}/hold", s.HandlePutArchiveHoldAPI)
+	mux.HandleFunc("DELETE /api/archive/{id}/hold", s.HandleDeleteArchiveHoldAPI)
+	mux.HandleFunc("GET /trash", s.HandleTrash)
+	mux.HandleFunc("GET /api/privacy", s.HandlePrivacyAPI)
+	mux.HandleFunc("GET /api/mqtt", s.HandleMQTTAPI)
+	mux.HandleFunc("GET /api/genetec", s.HandleGenetecAPI)
+	mux.HandleFunc("GET /api/milestone", s.HandleMilestoneAPI)
+	mux.HandleFunc("GET /api/snapshots", s.HandleSnapshotsAPI)
+	mux.HandleFunc("GET /api/email", s.HandleEmailAPI)
+	mux.HandleFunc("POST /api/email/test", s.HandleEmailTestAPI)
+	mux.HandleFunc("GET /api/push/key", s.HandlePushKeyAPI)
+	mux.HandleFunc("POST /api/push/subscriptions", s.HandlePushSubscribeAPI)
+	mux.HandleFunc("DELETE /api/push/subscriptions", s.HandlePushUnsubscribeAPI)
+	mux.HandleFunc("POST /api/push/test", s.HandlePushTestAPI)
+	mux.HandleFunc("GET /api/inbox", s.HandleInboxAPI)
+	mux.HandleFunc("POST /api/privacy/run", s.HandleRunPrivacy)
 	mux.HandleFunc("POST /archive/{id}/rename", s.HandleRenameArchive)
+	mux.HandleFunc("POST /archive/{id}/lock", s.HandleLockArchive)
+	mux.HandleFunc("POST /archive/{id}/verify", s.HandleVerifyArchive)
+	mux.HandleFunc("POST /api/archive/{id}/lock", s.HandleLockArchiveAPI)
+	mux.HandleFunc("GET /api/archive/{id}/verify", s.HandleVerifyArchiveAPI)
 	mux.HandleFunc("POST /clean", s.HandleClean)
+	mux.HandleFunc("POST /clean/split", s.HandleSplitSession)
+	mux.HandleFunc("GET /api/session/gaps", s.HandleSessionGapsAPI)
+	mux.HandleFunc("GET /panels", s.HandlePanels)
+	mux.HandleFunc("GET /api/panels", s.HandlePanelsAPI)
+	mux.HandleFunc("GET /api/panels/{name}", s.HandlePanelAPI)
+	mux.HandleFunc("GET /laps", s.HandleLaps)
+	mux.HandleFunc("GET /api/laps", s.HandleLapsAPI)
+	mux.HandleFunc("POST /laps/start", s.HandleStartLap)
+	mux.HandleFunc("POST /laps/end", s.HandleEndLap)
+	mux.HandleFunc("POST /laps/reset", s.HandleResetLaps)
+	mux.HandleFunc("POST /laps/vehicles", s.HandleAddTestVehicle)
+	mux.HandleFunc("POST /laps/vehicles/{id}/delete", s.HandleDeleteTestVehicle)
+	mux.HandleFunc("GET /archive/{id}/laps", s.HandleArchiveLaps)
+	mux.HandleFunc("GET /lifecycle", s.HandleLifecycle)
+	mux.HandleFunc("GET /storage", s.HandleStorage)
+	mux.HandleFunc("GET /api/storage", s.HandleStorageAPI)
+	mux.HandleFunc("GET /api/lifecycle", s.HandleLifecycleAPI)
+	mux.HandleFunc("GET /api/schema", s.HandleSchemaAPI)
+	mux.HandleFunc("GET /bi", s.HandleBI)
+	s.keyRoute(mux, "GET /grafana/{$}", s.HandleGrafanaTest)
+	s.keyRoute(mux, "POST /grafana/search", s.HandleGrafanaSearch)
+	s.keyRoute(mux, "POST /grafana/query", s.HandleGrafanaQuery)
+	mux.HandleFunc("GET /archive/{id}/lifecycle", s.HandleArchiveLifecycle)
 	mux.HandleFunc("GET /json/{id}", s.HandleRawJson)
 	mux.HandleFunc("GET /json/{id}/download", s.HandleJsonFile)
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(s.StaticDir))))
-	slog.Info("starting server", "addr", addr)
-	return http.ListenAndServe(addr, mux)
+	if err := s.registerCompatRoutes(mux); err != nil {
+		return err
+	}
+
+	if s.ReadOnly {
+		slog.Info("read-only mode: ingest and mutations are disabled")
+		return s.listen(ctx, addr, mux)
+	}
+	if s.NodeID != "" {
+		n, err := dbgen.New(s.DB).StampNodeID(ctx, &s.NodeID)
+		if err != nil {
+			return fmt.Errorf("stamp node id: %w", err)
+		}
+		if n > 0 {
+			slog.Info("stamped existing events with node id", "node_id", s.NodeID, "count", n)
+		}
+	}
+
+	if err := s.assignMissingUIDs(ctx); err != nil {
+		return fmt.Errorf("assign event uids: %w", err)
+	}
+	if err := s.parseMissingCaptureTimes(ctx); err != nil {
+		return fmt.Errorf("parse capture times: %w", err)
+	}
+	if err := s.fillArrivalDelays(ctx); err != nil {
+		return fmt.Errorf("fill arrival delays: %w", err)
+	}
+	s.queueMeasure()
+	s.background.Go(func() { s.hashExistingImages(ctx) })
+
+	if s.RequireAPIKey {
+		if n, err := dbgen.New(s.DB).CountEnabledAPIKeys(ctx); err == nil && n == 0 {
+			slog.Warn("API keys are required but none is enabled: all ingest will be rejected until one is created on /api-keys")
+		}
+	}
+	if s.RequireLogin {
+		if n, err := dbgen.New(s.DB).CountUsers(ctx); err == nil && n == 0 {
+			slog.Warn("login is required but there are no users: create one with `srv user add NAME`")
+		}
+	}
+	s.clearSpool()
+	if err := s.replayJournal(ctx); err != nil {
+		return fmt.Errorf("replay ingest journal: %w", err)
+	}
+	if s.Replica != nil {
+		s.background.Go(func() { s.runReplication(ctx) })
+	}
+	if s.BI != nil {
+		s.background.Go(func() { s.runBISnapshots(ctx) })
+	}
+	if s.TrashTTL > 0 {
+		s.background.Go(func() { s.runTrashRetention(ctx) })
+	}
+	if s.Privacy != nil {
+		s.background.Go(func() { s.runPrivacy(ctx) })
+	}
+	if s.ChunkTimeout > 0 {
+		s.background.Go(func() { s.runChunkExpiry(ctx) })
+	}
+	if s.RollupEvery > 0 {
+		s.background.Go(func() { s.runRollups(ctx) })
+	}
+	if s.OfflineAfter > 0 {
+		s.background.Go(func() { s.runCameraWatch(ctx) })
+	}
+	if s.ExportSchedule != nil {
+		for _, x := range s.ExportSchedule.Exports {
+			s.background.Go(func() { s.runExportSchedule(ctx, x) })
+		}
+	}
+	if s.MQTT != nil {
+		s.background.Go(func() { s.runMQTT(ctx) })
+	}
+	if s.Genetec != nil {
+		s.background.Go(func() { s.runGenetec(ctx) })
+	}
+	if s.Milestone != nil {
+		s.background.Go(func() { s.runMilestone(ctx) })
+	}
+	if s.Email != nil && s.Email.Errors != nil {
+		s.background.Go(func() { s.runIngestErrorAlerts(ctx) })
+	}
+	if s.Inbox != nil {
+		if s.Inbox.FTPAddr != "" {
+			ln, err := s.listenFTP()
+			if err != nil {
+				return fmt.Errorf("ftp server: %w", err)
+			}
+			s.background.Go(func() { s.runFTP(ctx, ln) })
+		}
+		s.background.Go(func() { s.runInbox(ctx) })
+	}
+	return s.listen(ctx, addr, mux)
 }
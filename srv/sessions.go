@@ -0,0 +1,203 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Unattended sites collect several days of traffic in the current session
+// when nobody presses Clean. A traffic gap of at least IdleGap between two
+// current events (by capture time) is offered on the dashboard as a place to
+// split: "no traffic 02:00–06:00 — split here?" archives the events before
+// the gap and leaves the ones after it current.
+
+// DefaultIdleGap is the shortest traffic gap suggested as a session boundary
+const DefaultIdleGap = 2 * time.Hour
+
+// sessionGap is a stretch without traffic in the current session
+type sessionGap struct {
+	Start        time.Time `json:"start"` // last event before the gap
+	End          time.Time `json:"end"`   // first event after it
+	Seconds      int64     `json:"seconds"`
+	EventsBefore int       `json:"events_before"`
+	EventsAfter  int       `json:"events_after"`
+	BoundaryID   int64     `json:"boundary_id"` // first event after the gap; pass to /clean/split
+	Label        string    `json:"label"`
+}
+
+// findSessionGaps lists the gaps of at least minGap between consecutive
+// events, which are ordered by capture time
+func findSessionGaps(events []dbgen.GetCurrentEventTimesRow, minGap time.Duration) []sessionGap {
+	gaps := []sessionGap{}
+	if minGap <= 0 {
+		return gaps
+	}
+	for i := 1; i < len(events); i++ {
+		start, end := eventTime(events[i-1]), eventTime(events[i])
+		if end.Sub(start) < minGap {
+			continue
+		}
+		gaps = append(gaps, sessionGap{
+			Start:        start,
+			End:          end,
+			Seconds:      int64(end.Sub(start).Seconds()),
+			EventsBefore: i,
+			EventsAfter:  len(events) - i,
+			BoundaryID:   events[i].ID,
+			Label:        gapLabel(start, end),
+		})
+	}
+	return gaps
+}
+
+// eventTime is when an event was captured, or received if the camera
+// didn't say
+func eventTime(e dbgen.GetCurrentEventTimesRow) time.Time {
+	if e.CapturedAt != nil {
+		return *e.CapturedAt
+	}
+	return e.CreatedAt
+}
+
+// gapLabel describes a gap in local time: "no traffic 02:00–06:00 (4h)",
+// with dates when it spans midnight
+func gapLabel(start, end time.Time) string {
+	start, end = start.Local(), end.Local()
+	layout := "15:04"
+	if start.YearDay() != end.YearDay() || start.Year() != end.Year() {
+		layout = "Jan 2 15:04"
+	}
+	d := strings.TrimSuffix(end.Sub(start).Round(time.Minute).String(), "0s")
+	if strings.HasSuffix(d, "h0m") {
+		d = strings.TrimSuffix(d, "0m")
+	}
+	return fmt.Sprintf("no traffic %s–%s (%s)", start.Format(layout), end.Format(layout), d)
+}
+
+// sessionGaps lists the traffic gaps in the current session of at least
+// minGap
+func (s *Server) sessionGaps(ctx context.Context, minGap time.Duration) ([]sessionGap, error) {
+	events, err := dbgen.New(s.DB).GetCurrentEventTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return findSessionGaps(events, minGap), nil
+}
+
+// errNoSplit is returned when nothing in the current session comes before
+// the boundary event
+var errNoSplit = errors.New("no current events before that event")
+
+// splitSession archives the current events captured before the boundary
+// event, with the laps started before it. The archive is named for the last
+// event it holds.
+func (s *Server) splitSession(ctx context.Context, boundaryID int64) (archiveID, count int64, err error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(s.DB).WithTx(tx)
+
+	count, err = q.CountCurrentEventsBefore(ctx, boundaryID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if count == 0 {
+		return 0, 0, errNoSplit
+	}
+	events, err := q.GetCurrentEventTimes(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Events with the boundary's time stay current, so the boundary
+	// event follows the archived ones in capture order
+	end, before := eventTime(events[count-1]), eventTime(events[count])
+	name := end.Local().Format("2006-01-02 15:04:05")
+	archiveID, err = q.CreateArchive(ctx, dbgen.CreateArchiveParams{
+		Name:       &name,
+		EventCount: count,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := q.ArchiveCurrentEventsBefore(ctx, dbgen.ArchiveCurrentEventsBeforeParams{
+		ArchiveID:  &archiveID,
+		BoundaryID: boundaryID,
+	}); err != nil {
+		return 0, 0, err
+	}
+	// Laps of the archived part go with it
+	if err := q.ArchiveCurrentLapsBefore(ctx, dbgen.ArchiveCurrentLapsBeforeParams{
+		ArchiveID: &archiveID,
+		EndedAt:   &end,
+		Before:    before,
+	}); err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	s.archiveVINTruth(ctx, archiveID)
+	s.archivePlateTruth(ctx, archiveID)
+	s.notifySessionEnded(archiveID, name, count)
+	return archiveID, count, nil
+}
+
+// HandleSplitSession archives the current events before a suggested gap.
+// The form's "before" is the gap's boundary event.
+func (s *Server) HandleSplitSession(w http.ResponseWriter, r *http.Request) {
+	boundaryID, err := strconv.ParseInt(r.FormValue("before"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid boundary event", http.StatusBadRequest)
+		return
+	}
+	archiveID, count, err := s.splitSession(r.Context(), boundaryID)
+	if errors.Is(err, errNoSplit) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to split session", "boundary", boundaryID, "error", err)
+		http.Error(w, "failed to archive events", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("archived events before gap", "archive_id", archiveID, "count", count, "boundary", boundaryID)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// HandleSessionGapsAPI returns the traffic gaps in the current session.
+// Pass ?min= (a duration such as 90m, default -idle-gap) to look for shorter
+// or longer ones.
+func (s *Server) HandleSessionGapsAPI(w http.ResponseWriter, r *http.Request) {
+	minGap := s.IdleGap
+	if v := r.URL.Query().Get("min"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute {
+			s.jsonError(w, "min must be a duration of at least 1m", http.StatusBadRequest)
+			return
+		}
+		minGap = d
+	}
+	gaps, err := s.sessionGaps(r.Context(), minGap)
+	if err != nil {
+		slog.Warn("failed to find session gaps", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"min_gap_seconds": int64(minGap.Seconds()),
+		"gaps":            gaps,
+	})
+}
@@ -0,0 +1,106 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGapLabel(t *testing.T) {
+	day := func(d, h, m int) time.Time { return time.Date(2026, 5, d, h, m, 0, 0, time.Local) }
+	for _, tc := range []struct {
+		start, end time.Time
+		want       string
+	}{
+		{day(3, 2, 0), day(3, 6, 0), "no traffic 02:00–06:00 (4h)"},
+		{day(3, 22, 10), day(4, 6, 0), "no traffic May 3 22:10–May 4 06:00 (7h50m)"},
+		{day(3, 12, 0), day(3, 12, 45), "no traffic 12:00–12:45 (45m)"},
+	} {
+		if got := gapLabel(tc.start, tc.end); got != tc.want {
+			t.Errorf("gapLabel = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestSplitSession(t *testing.T) {
+	s := newTestServer(t)
+	s.IdleGap = DefaultIdleGap
+	ctx := context.Background()
+	base := time.Now().Add(-12 * time.Hour).Truncate(time.Minute)
+	// Two events, 4h without traffic, two events, 4h40m, one event
+	for i, offset := range []time.Duration{0, 10 * time.Minute, 250 * time.Minute, 260 * time.Minute, 540 * time.Minute} {
+		req := newIngestRequest([]byte(`{"plateUTF8":"GAP`+strconv.Itoa(i+1)+`"}`), "", nil)
+		res, err := s.ingestEvent(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.DB.Exec("UPDATE events SET captured_at = ? WHERE id = ?", base.Add(offset), res.ID)
+	}
+	s.DB.Exec("INSERT INTO laps (number, started_at) VALUES (1, ?), (2, ?)", base.Add(5*time.Minute), base.Add(255*time.Minute))
+
+	gapsAPI := func(query string) (int, []sessionGap) {
+		w := httptest.NewRecorder()
+		s.HandleSessionGapsAPI(w, httptest.NewRequest("GET", "/api/session/gaps"+query, nil))
+		var res struct{ Gaps []sessionGap }
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w.Code, res.Gaps
+	}
+	code, gaps := gapsAPI("")
+	if code != http.StatusOK || len(gaps) != 2 {
+		t.Fatalf("gaps: %d %+v", code, gaps)
+	}
+	if g := gaps[0]; g.EventsBefore != 2 || g.EventsAfter != 3 || g.Seconds != 4*3600 || !strings.Contains(g.Label, "(4h)") {
+		t.Errorf("first gap: %+v", g)
+	}
+	if _, gaps := gapsAPI("?min=5h"); len(gaps) != 0 {
+		t.Errorf("min=5h: %+v", gaps)
+	}
+	if code, _ := gapsAPI("?min=soon"); code != http.StatusBadRequest {
+		t.Errorf("min=soon: %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleRoot(w, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(w.Body.String(), "✂ Split here") {
+		t.Error("dashboard doesn't offer the split")
+	}
+
+	split := func(before int64) int {
+		form := url.Values{"before": {strconv.FormatInt(before, 10)}}
+		r := httptest.NewRequest("POST", "/clean/split", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.HandleSplitSession(w, r)
+		return w.Code
+	}
+	if code := split(gaps[0].BoundaryID); code != http.StatusSeeOther {
+		t.Fatalf("split: %d", code)
+	}
+	var current, archived, lapsArchived int
+	var name string
+	s.DB.QueryRow("SELECT COUNT(*) FROM events WHERE archive_id IS NULL").Scan(&current)
+	s.DB.QueryRow("SELECT event_count, name FROM archives").Scan(&archived, &name)
+	s.DB.QueryRow("SELECT COUNT(*) FROM laps WHERE archive_id IS NOT NULL").Scan(&lapsArchived)
+	if current != 3 || archived != 2 || lapsArchived != 1 {
+		t.Errorf("after split: %d current, %d archived, %d laps archived", current, archived, lapsArchived)
+	}
+	if want := base.Add(10 * time.Minute).Local().Format("2006-01-02 15:04:05"); name != want {
+		t.Errorf("archive name %q, want %q", name, want)
+	}
+	if _, gaps := gapsAPI(""); len(gaps) != 1 || gaps[0].EventsBefore != 2 {
+		t.Errorf("gaps after split: %+v", gaps)
+	}
+	// The boundary of the split gap is the first current event now
+	if code := split(gaps[0].BoundaryID); code != http.StatusBadRequest {
+		t.Errorf("nothing before: %d", code)
+	}
+	if code := split(1); code != http.StatusBadRequest {
+		t.Errorf("archived boundary: %d", code)
+	}
+}
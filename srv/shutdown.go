@@ -0,0 +1,160 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Serve runs until its context is canceled (SIGINT or SIGTERM in the
+// command) and then shuts down in order: the listener closes so nothing new
+// arrives, live event streams are ended, in-flight requests get
+// HTTPConfig.DrainTimeout to finish so no camera upload is cut off halfway,
+// export jobs are canceled and background loops are waited for. The caller
+// closes the database afterwards with Close.
+
+// stopSignal is closed when the server starts shutting down; the zero value
+// is open
+type stopSignal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+// done returns a channel closed on shutdown
+func (sig *stopSignal) done() <-chan struct{} {
+	sig.mu.Lock()
+	defer sig.mu.Unlock()
+	if sig.ch == nil {
+		sig.ch = make(chan struct{})
+	}
+	return sig.ch
+}
+
+func (sig *stopSignal) stop() {
+	sig.mu.Lock()
+	defer sig.mu.Unlock()
+	if sig.ch == nil {
+		sig.ch = make(chan struct{})
+	}
+	select {
+	case <-sig.ch:
+	default:
+		close(sig.ch)
+	}
+}
+
+// listen runs the HTTP server with the configured timeouts and limits until
+// ctx is done
+func (s *Server) listen(ctx context.Context, addr string, mux *http.ServeMux) error {
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s.rejectMutations(s.requireLogin(mux)),
+		ReadHeaderTimeout: s.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       s.HTTP.ReadTimeout,
+		WriteTimeout:      s.HTTP.WriteTimeout,
+		IdleTimeout:       s.HTTP.IdleTimeout,
+		MaxHeaderBytes:    s.HTTP.MaxHeaderBytes,
+	}
+	httpServer.SetKeepAlivesEnabled(!s.HTTP.DisableKeepAlives)
+	servers := []*http.Server{httpServer}
+	if s.TLS != nil {
+		cfg, plain, err := s.tlsSetup(addr)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		httpServer.TLSConfig = cfg
+		if s.TLS.HTTPAddr != "" {
+			servers = append(servers, &http.Server{
+				Addr:              s.TLS.HTTPAddr,
+				Handler:           plain,
+				ReadHeaderTimeout: s.HTTP.ReadHeaderTimeout,
+				IdleTimeout:       s.HTTP.IdleTimeout,
+			})
+		}
+	}
+	slog.Info("starting server", "addr", addr, "version", Version, "tls", s.TLS != nil, "read_timeout", s.HTTP.ReadTimeout, "idle_timeout", s.HTTP.IdleTimeout, "keep_alives", !s.HTTP.DisableKeepAlives, "read_only", s.ReadOnly)
+
+	errc := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() {
+			if srv.TLSConfig != nil {
+				errc <- srv.ListenAndServeTLS("", "")
+			} else {
+				errc <- srv.ListenAndServe()
+			}
+		}()
+	}
+	if len(servers) > 1 {
+		slog.Info("serving ACME challenges and HTTPS redirects", "addr", s.TLS.HTTPAddr)
+	}
+	var err error
+	select {
+	case err = <-errc:
+		// A listener failed; stop the others
+		s.stopping.stop()
+		for _, srv := range servers {
+			srv.Close()
+		}
+		return err
+	case <-ctx.Done():
+	}
+	s.shutdown(servers)
+	for range servers {
+		if e := <-errc; err == nil && !errors.Is(e, http.ErrServerClosed) {
+			err = e
+		}
+	}
+	return err
+}
+
+// shutdown stops accepting requests and waits for in-flight work
+func (s *Server) shutdown(servers []*http.Server) {
+	start := time.Now()
+	timeout := s.HTTP.DrainTimeout
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+	slog.Info("shutting down", "drain_timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Streams never finish on their own, so they would hold Shutdown until
+	// the deadline
+	s.stopping.stop()
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Warn("requests still running at the drain deadline; closing their connections", "addr", srv.Addr, "error", err)
+			srv.Close()
+		}
+	}
+	if n := s.jobs.cancelRunning(); n > 0 {
+		slog.Info("canceled running export jobs", "count", n)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("background tasks still running at the drain deadline")
+	}
+	slog.Info("server stopped", "elapsed", time.Since(start).Round(time.Millisecond))
+}
+
+// Close checkpoints the write-ahead log into the database file and closes
+// the database
+func (s *Server) Close() error {
+	if !s.ReadOnly {
+		if _, err := s.DB.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+			slog.Warn("checkpoint database", "error", err)
+		}
+	}
+	return s.DB.Close()
+}
@@ -0,0 +1,143 @@
+package srv
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.DataDir
+	s.HTTP = DefaultHTTPConfig()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, addr) }()
+	var stream *http.Response
+	for range 50 {
+		if stream, err = http.Get("http://" + addr + "/api/events/stream"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	bufio.NewReader(stream.Body).ReadString('\n') // subscribed
+
+	// An upload still sending its body when shutdown starts is finished
+	body, send := io.Pipe()
+	uploaded := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Post("http://"+addr+"/api", "application/json", body)
+		if err != nil {
+			t.Error(err)
+		}
+		uploaded <- resp
+	}()
+	io.WriteString(send, `{"plateUTF8":`)
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	io.WriteString(send, `"AB123"}`)
+	send.Close()
+
+	if resp := <-uploaded; resp == nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("in-flight upload = %v", resp)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("Serve = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return; the event stream held up shutdown")
+	}
+	if rest, _ := io.ReadAll(stream.Body); strings.Contains(string(rest), "keep-alive") {
+		t.Errorf("stream kept going: %q", rest)
+	}
+	if _, err := http.Get("http://" + addr + "/api/version"); err == nil {
+		t.Error("server still accepting connections")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	check, _ := db.Open(filepath.Join(dir, "db.sqlite3"))
+	defer check.Close()
+	var plate string
+	if err := check.QueryRow("SELECT plate_utf8 FROM events").Scan(&plate); err != nil || plate != "AB123" {
+		t.Errorf("stored plate %q, err %v", plate, err)
+	}
+}
+
+func TestHTTPLimits(t *testing.T) {
+	s := newTestServer(t)
+	s.HTTP = DefaultHTTPConfig()
+	s.HTTP.ReadHeaderTimeout = 200 * time.Millisecond
+	s.HTTP.MaxHeaderBytes = 1 << 10
+	s.HTTP.DisableKeepAlives = true
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Serve(ctx, addr)
+
+	var resp *http.Response
+	for range 50 {
+		if resp, err = http.Get("http://" + addr + "/api/version"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !resp.Close {
+		t.Error("keep-alive not disabled")
+	}
+
+	// A client that never finishes its headers is disconnected
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "POST /api HTTP/1.1\r\nHost: x\r\n")
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Errorf("connection held open: %v", err)
+	}
+
+	// net/http allows 4 KiB on top of MaxHeaderBytes
+	req, _ := http.NewRequest("GET", "http://"+addr+"/api/version", nil)
+	req.Header.Set("X-Padding", strings.Repeat("a", 6<<10))
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("oversized headers: %s", resp.Status)
+	}
+}
@@ -0,0 +1,290 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// For trials where the evidentiary integrity of results matters, exported
+// files (XLSX, contact sheets, labeling exports) can be signed with an
+// Ed25519 key. The detached signature is the raw 64-byte signature of the
+// file, so third parties can check it with the public key alone:
+//
+//	curl -o key.pem https://lpr.example.com/api/signing-key
+//	openssl pkeyutl -verify -pubin -inkey key.pem -rawin -in compare.xlsx -sigfile compare.xlsx.sig
+//
+// Every signature is also recorded, so POST /api/exports/verify can tell
+// whether a file handed over later is one this server exported.
+
+// maxVerifyBytes limits files uploaded for verification
+const maxVerifyBytes = 512 << 20
+
+// ExportSigner signs exported files
+type ExportSigner struct {
+	key   ed25519.PrivateKey
+	KeyID string // hex SHA-256 of the public key, first 16 characters
+}
+
+// LoadExportSigner reads a PEM PKCS#8 Ed25519 private key. A missing file is
+// created with a new key so a deployment only has to pick the path.
+func LoadExportSigner(path string) (*ExportSigner, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+			return nil, err
+		}
+		signer := newExportSigner(key)
+		slog.Info("created export signing key", "path", path, "key_id", signer.KeyID)
+		return signer, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return newExportSigner(key), nil
+}
+
+func newExportSigner(key ed25519.PrivateKey) *ExportSigner {
+	sum := sha256.Sum256(key.Public().(ed25519.PublicKey))
+	return &ExportSigner{key: key, KeyID: hex.EncodeToString(sum[:])[:16]}
+}
+
+// PublicKeyPEM is the PEM PKIX public key for verifying signatures
+func (e *ExportSigner) PublicKeyPEM() []byte {
+	der, _ := x509.MarshalPKIXPublicKey(e.key.Public())
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// Sign returns the Ed25519 signature of data
+func (e *ExportSigner) Sign(data []byte) []byte {
+	return ed25519.Sign(e.key, data)
+}
+
+// Verify checks a signature of data
+func (e *ExportSigner) Verify(data, sig []byte) bool {
+	return ed25519.Verify(e.key.Public().(ed25519.PublicKey), data, sig)
+}
+
+// writeExport sends an exported file. With a signing key the file is signed,
+// the signature recorded, and its hash and signature returned in headers.
+func (s *Server) writeExport(w http.ResponseWriter, r *http.Request, filename, contentType string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if s.Signer != nil {
+		hash, sig, err := s.signExport(r.Context(), filename, data, sessionUser(r))
+		if err != nil {
+			slog.Warn("record export signature", "file", filename, "error", err)
+			http.Error(w, "failed to sign export", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Content-SHA256", hash)
+		w.Header().Set("X-Signature", sig)
+		w.Header().Set("X-Signature-Key", s.Signer.KeyID)
+		if !s.ReadOnly {
+			w.Header().Set("Link", fmt.Sprintf(`</api/exports/%s/signature>; rel="signature"`, hash))
+		}
+	}
+	w.Write(data)
+}
+
+// serveExportFile sends an export spooled to path. Unsigned files are
+// streamed from disk; a signature covers the whole file, so signed ones are
+// read first.
+func (s *Server) serveExportFile(w http.ResponseWriter, r *http.Request, filename, contentType, path string) {
+	if s.Signer != nil {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			slog.Warn("read spooled export", "file", path, "error", err)
+			http.Error(w, "export file missing", http.StatusInternalServerError)
+			return
+		}
+		s.writeExport(w, r, filename, contentType, data)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		slog.Warn("open spooled export", "file", path, "error", err)
+		http.Error(w, "export file missing", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "export file missing", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// signExport signs an exported file and records the signature; it returns
+// the file's SHA-256 and the base64 signature
+func (s *Server) signExport(ctx context.Context, filename string, data []byte, user string) (hash, sig string, err error) {
+	sum := sha256.Sum256(data)
+	hash = hex.EncodeToString(sum[:])
+	sig = base64.StdEncoding.EncodeToString(s.Signer.Sign(data))
+	// A read-only copy can't record the signature; the headers and
+	// the public key still verify the file
+	if !s.ReadOnly {
+		err = dbgen.New(s.DB).CreateExportSignature(ctx, dbgen.CreateExportSignatureParams{
+			Sha256:    hash,
+			Filename:  filename,
+			Size:      int64(len(data)),
+			KeyID:     s.Signer.KeyID,
+			Signature: sig,
+			SignedAt:  time.Now(),
+			SignedBy:  ptrIfNotEmpty(user),
+		})
+		if err != nil {
+			return "", "", err
+		}
+	}
+	slog.Info("signed export", "file", filename, "sha256", hash)
+	return hash, sig, nil
+}
+
+// requireSigner answers 404 when export signing is not configured
+func (s *Server) requireSigner(w http.ResponseWriter) bool {
+	if s.Signer == nil {
+		s.jsonError(w, "export signing is not configured (-signing-key)", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// HandleSigningKey returns the public key that verifies export signatures
+func (s *Server) HandleSigningKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSigner(w) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("X-Signature-Key", s.Signer.KeyID)
+	w.Write(s.Signer.PublicKeyPEM())
+}
+
+// HandleExportSignatures lists the most recently signed exports
+func (s *Server) HandleExportSignatures(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSigner(w) {
+		return
+	}
+	limit, ok := cursorLimit(r.URL.Query().Get("limit"))
+	if !ok {
+		s.jsonError(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+	sigs, err := dbgen.New(s.DB).ListExportSignatures(r.Context(), limit)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if sigs == nil {
+		sigs = []dbgen.ExportSignature{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sigs)
+}
+
+// HandleExportSignature returns the detached signature of an exported file
+// by its SHA-256
+func (s *Server) HandleExportSignature(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSigner(w) {
+		return
+	}
+	rec, err := dbgen.New(s.DB).GetExportSignature(r.Context(), r.PathValue("sha256"))
+	if err != nil {
+		s.jsonError(w, "no export with this hash was signed", http.StatusNotFound)
+		return
+	}
+	sig, err := base64.StdEncoding.DecodeString(rec.Signature)
+	if err != nil {
+		s.jsonError(w, "stored signature is corrupt", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.sig"`, rec.Filename))
+	w.Header().Set("X-Signature-Key", rec.KeyID)
+	w.Write(sig)
+}
+
+// exportVerification is the result of checking a file against the recorded
+// signatures
+type exportVerification struct {
+	SHA256   string                 `json:"sha256"`
+	Signed   bool                   `json:"signed"`    // a signature for this exact file was recorded
+	KeyMatch bool                   `json:"key_match"` // by the current key
+	Valid    bool                   `json:"valid"`     // and it verifies
+	Export   *dbgen.ExportSignature `json:"export,omitempty"`
+}
+
+// HandleVerifyExport checks a file posted as the request body against the
+// recorded export signatures
+func (s *Server) HandleVerifyExport(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSigner(w) {
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxVerifyBytes))
+	if err != nil {
+		s.jsonError(w, "failed to read file", http.StatusBadRequest)
+		return
+	}
+	sum := sha256.Sum256(data)
+	res := exportVerification{SHA256: hex.EncodeToString(sum[:])}
+	if rec, err := dbgen.New(s.DB).GetExportSignature(r.Context(), res.SHA256); err == nil {
+		res.Signed = true
+		res.Export = &rec
+		res.KeyMatch = rec.KeyID == s.Signer.KeyID
+		if sig, err := base64.StdEncoding.DecodeString(rec.Signature); err == nil && res.KeyMatch {
+			res.Valid = s.Signer.Verify(data, sig)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// renderExport renders a template into an exported file
+func (s *Server) renderExport(w http.ResponseWriter, r *http.Request, filename, name string, data any) error {
+	var buf bytes.Buffer
+	if err := s.renderTemplate(&buf, name, data); err != nil {
+		return err
+	}
+	s.writeExport(w, r, filename, "text/html; charset=utf-8", buf.Bytes())
+	return nil
+}
@@ -0,0 +1,89 @@
+package srv
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportSigning(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.DataDir
+
+	keyPath := filepath.Join(dir, "signing.pem")
+	signer, err := LoadExportSigner(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadExportSigner(keyPath)
+	if err != nil || reloaded.KeyID != signer.KeyID {
+		t.Fatalf("reloaded key %v, err %v; want the created one", reloaded, err)
+	}
+	s.Signer = signer
+
+	file := []byte("plate,verdict\nAB1,correct\n")
+	w := httptest.NewRecorder()
+	s.writeExport(w, httptest.NewRequest("GET", "/export", nil), "compare_trial.csv", "text/csv", file)
+	hash := w.Header().Get("X-Content-SHA256")
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), file) || len(hash) != 64 {
+		t.Fatalf("export: status %d, sha256 %q", w.Code, hash)
+	}
+
+	// The detached signature verifies with the published public key
+	w = httptest.NewRecorder()
+	s.HandleSigningKey(w, httptest.NewRequest("GET", "/api/signing-key", nil))
+	block, _ := pem.Decode(w.Body.Bytes())
+	if block == nil {
+		t.Fatal("no PEM public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/exports/"+hash+"/signature", nil)
+	r.SetPathValue("sha256", hash)
+	s.HandleExportSignature(w, r)
+	if !ed25519.Verify(pub.(ed25519.PublicKey), file, w.Body.Bytes()) {
+		t.Error("detached signature does not verify")
+	}
+	if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, "compare_trial.csv.sig") {
+		t.Errorf("signature filename = %q", got)
+	}
+
+	verify := func(body []byte) exportVerification {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.HandleVerifyExport(w, httptest.NewRequest("POST", "/api/exports/verify", bytes.NewReader(body)))
+		var v exportVerification
+		if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	if v := verify(file); !v.Signed || !v.Valid || v.Export.Filename != "compare_trial.csv" {
+		t.Errorf("exported file: %+v", v)
+	}
+	if v := verify(append(file, "AB2,correct\n"...)); v.Signed || v.Valid {
+		t.Errorf("altered file: %+v", v)
+	}
+
+	s.Signer = nil
+	w = httptest.NewRecorder()
+	s.writeExport(w, httptest.NewRequest("GET", "/export", nil), "plain.csv", "text/csv", file)
+	if w.Header().Get("X-Signature") != "" {
+		t.Error("signed without a key")
+	}
+	w = httptest.NewRecorder()
+	s.HandleSigningKey(w, httptest.NewRequest("GET", "/api/signing-key", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("signing key without signer: status %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,382 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// With -snapshot-config every new read gets a wide-angle scene image from
+// an overview camera next to the ANPR camera, grabbed when the read is
+// stored and attached to the event as image type "scene". Each ANPR camera
+// (by serial number, sensor provider ID or IP) names the overview camera's
+// snapshot URL:
+//
+//	{"cameras": {"CAM123": {"url": "http://10.0.0.21/ISAPI/Streaming/channels/101/picture",
+//	                        "username": "admin", "password": "secret"},
+//	             "gate":   {"url": "rtsp://10.0.0.22:554/stream1", "delay": 300}},
+//	 "ffmpeg": "/usr/bin/ffmpeg", "timeout": 5}
+//
+// http(s) URLs are a JPEG snapshot endpoint, fetched with basic or digest
+// authentication; rtsp URLs are read by ffmpeg, one frame over TCP. delay
+// is milliseconds to wait before grabbing, for an overview camera that
+// sees the vehicle later than the ANPR camera. Grabs run in the background
+// and never hold up the camera's acknowledgment; a grab that fails is
+// logged and the event stays without a scene image. Storage quotas apply.
+
+// Snapshot defaults
+const (
+	snapshotImageType   = "scene"
+	snapshotTimeout     = 5 * time.Second
+	snapshotConcurrency = 4 // grabs in flight per overview camera; further reads go without
+	snapshotMaxBytes    = 20 << 20
+)
+
+// SnapshotConfig maps ANPR cameras to the overview cameras grabbed for them
+type SnapshotConfig struct {
+	Cameras map[string]*SnapshotSource `json:"cameras"` // camera serial, sensor provider ID or IP -> overview camera
+	FFmpeg  string                     `json:"ffmpeg"`  // ffmpeg binary for rtsp URLs (default "ffmpeg" on the PATH)
+	Timeout int                        `json:"timeout"` // seconds per grab (default 5)
+
+	client *http.Client
+
+	mu       sync.Mutex
+	grabbed  int64
+	failed   int64
+	skipped  int64
+	lastAt   time.Time
+	lastErr  error
+	lastFrom string
+}
+
+// SnapshotSource is the snapshot URL of one overview camera
+type SnapshotSource struct {
+	URL      string `json:"url"`
+	Username string `json:"username"` // default: from the URL
+	Password string `json:"password"`
+	Delay    int    `json:"delay"` // milliseconds to wait before grabbing
+
+	busy chan struct{}
+}
+
+// LoadSnapshotConfig reads a scene snapshot config file
+func LoadSnapshotConfig(path string) (*SnapshotConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg SnapshotConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// compile checks the config and fills in defaults
+func (c *SnapshotConfig) compile() error {
+	if len(c.Cameras) == 0 {
+		return errors.New("no cameras")
+	}
+	rtsp := false
+	for name, src := range c.Cameras {
+		if src == nil {
+			return fmt.Errorf("camera %s: no url", name)
+		}
+		u, err := url.Parse(src.URL)
+		if err != nil {
+			return fmt.Errorf("camera %s: %w", name, err)
+		}
+		switch u.Scheme {
+		case "http", "https":
+		case "rtsp", "rtsps":
+			rtsp = true
+		default:
+			return fmt.Errorf("camera %s: url must be http(s) or rtsp", name)
+		}
+		if u.User != nil && src.Username == "" {
+			src.Username = u.User.Username()
+			src.Password, _ = u.User.Password()
+		}
+		if src.Delay < 0 {
+			return fmt.Errorf("camera %s: negative delay", name)
+		}
+		src.busy = make(chan struct{}, snapshotConcurrency)
+	}
+	if c.FFmpeg == "" {
+		c.FFmpeg = "ffmpeg"
+	}
+	if rtsp {
+		if _, err := exec.LookPath(c.FFmpeg); err != nil {
+			return fmt.Errorf("rtsp cameras need ffmpeg: %w", err)
+		}
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = int(snapshotTimeout / time.Second)
+	}
+	c.client = &http.Client{Timeout: c.timeout()}
+	return nil
+}
+
+func (c *SnapshotConfig) timeout() time.Duration {
+	return time.Duration(c.Timeout) * time.Second
+}
+
+// source is the overview camera of an event's camera
+func (c *SnapshotConfig) source(params dbgen.InsertEventParams) (string, *SnapshotSource) {
+	for _, k := range []string{deref(params.CameraSerial), deref(params.SensorProviderID), deref(params.CameraIp)} {
+		if src, ok := c.Cameras[k]; ok && k != "" {
+			return k, src
+		}
+	}
+	return "", nil
+}
+
+// queueSnapshot grabs the scene of a new event in the background
+func (s *Server) queueSnapshot(eventID int64, params dbgen.InsertEventParams, plate, camera string) {
+	c := s.Snapshots
+	if c == nil {
+		return
+	}
+	from, src := c.source(params)
+	if src == nil {
+		return
+	}
+	select {
+	case src.busy <- struct{}{}:
+	default:
+		c.mu.Lock()
+		c.skipped++
+		c.mu.Unlock()
+		slog.Warn("scene snapshot skipped; overview camera busy", "event_id", eventID, "camera", from)
+		return
+	}
+	s.background.Go(func() {
+		defer func() { <-src.busy }()
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout()+time.Duration(src.Delay)*time.Millisecond)
+		defer cancel()
+		err := s.attachSnapshot(ctx, eventID, src, plate, camera)
+		c.mu.Lock()
+		c.lastErr, c.lastFrom = err, from
+		if err != nil {
+			c.failed++
+		} else {
+			c.grabbed++
+			c.lastAt = time.Now()
+		}
+		c.mu.Unlock()
+		if err != nil {
+			slog.Warn("scene snapshot failed", "event_id", eventID, "camera", from, "error", err)
+		}
+	})
+}
+
+// attachSnapshot grabs a frame of the overview camera and stores it with the event
+func (s *Server) attachSnapshot(ctx context.Context, eventID int64, src *SnapshotSource, plate, camera string) error {
+	if src.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(src.Delay) * time.Millisecond):
+		}
+	}
+	at := time.Now()
+	data, err := s.Snapshots.grab(ctx, src)
+	if err != nil {
+		return err
+	}
+	if ct := http.DetectContentType(data); !strings.HasPrefix(ct, "image/") {
+		return fmt.Errorf("not an image: %s", ct)
+	}
+	// Admitted and stored in one transaction, so no other image is admitted
+	// in between
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(s.DB).WithTx(tx)
+	if !s.admitImage(ctx, q, camera, int64(len(data))) {
+		return errors.New("storage quota exceeded")
+	}
+	if _, err := s.saveImage(ctx, q, eventID, snapshotImageType, "scene.jpg", plate, data, at); err != nil {
+		s.resetQuotaUsage()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		s.resetQuotaUsage()
+		return err
+	}
+	s.queueMeasure()
+	return nil
+}
+
+// grab reads one frame of an overview camera
+func (c *SnapshotConfig) grab(ctx context.Context, src *SnapshotSource) ([]byte, error) {
+	if strings.HasPrefix(src.URL, "rtsp") {
+		return c.grabRTSP(ctx, src)
+	}
+	resp, err := c.get(ctx, src, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && src.Username != "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if !strings.HasPrefix(strings.ToLower(challenge), "digest ") {
+			return nil, errors.New("snapshot: 401 Unauthorized")
+		}
+		auth, err := digestAuth(challenge, src.Username, src.Password, http.MethodGet, src.URL)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = c.get(ctx, src, auth); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("snapshot: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, snapshotMaxBytes))
+}
+
+// get requests a snapshot URL, with basic auth unless auth is given
+func (c *SnapshotConfig) get(ctx context.Context, src *SnapshotSource, auth string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case auth != "":
+		req.Header.Set("Authorization", auth)
+	case src.Username != "":
+		req.SetBasicAuth(src.Username, src.Password)
+	}
+	return c.client.Do(req)
+}
+
+// grabRTSP has ffmpeg decode one frame of an RTSP stream as JPEG
+func (c *SnapshotConfig) grabRTSP(ctx context.Context, src *SnapshotSource) ([]byte, error) {
+	u, _ := url.Parse(src.URL)
+	if src.Username != "" {
+		u.User = url.UserPassword(src.Username, src.Password)
+	}
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.FFmpeg, "-nostdin", "-loglevel", "error", "-rtsp_transport", "tcp",
+		"-i", u.String(), "-frames:v", "1", "-f", "image2", "-c:v", "mjpeg", "-q:v", "3", "pipe:1")
+	cmd.Stdout, cmd.Stderr = &out, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ffmpeg: %s", strings.ReplaceAll(msg, u.String(), src.URL))
+		}
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// digestAuth answers an HTTP digest challenge (RFC 7616, MD5), as most IP
+// cameras require for their snapshot endpoint
+func digestAuth(challenge, username, password, method, rawURL string) (string, error) {
+	params := map[string]string{}
+	for _, part := range splitDigestParams(challenge[len("digest "):]) {
+		k, v, _ := strings.Cut(part, "=")
+		params[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(v), `"`)
+	}
+	if alg := params["algorithm"]; alg != "" && !strings.EqualFold(alg, "MD5") {
+		return "", fmt.Errorf("unsupported digest algorithm %s", alg)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	uri := u.RequestURI()
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := md5hex(username + ":" + params["realm"] + ":" + password)
+	ha2 := md5hex(method + ":" + uri)
+	auth := fmt.Sprintf(`Digest username=%q, realm=%q, nonce=%q, uri=%q`, username, params["realm"], params["nonce"], uri)
+	if qop := params["qop"]; qop != "" {
+		b := make([]byte, 8)
+		rand.Read(b)
+		cnonce := hex.EncodeToString(b)
+		auth += fmt.Sprintf(`, qop=auth, nc=00000001, cnonce=%q, response=%q`, cnonce,
+			md5hex(ha1+":"+params["nonce"]+":00000001:"+cnonce+":auth:"+ha2))
+	} else {
+		auth += fmt.Sprintf(`, response=%q`, md5hex(ha1+":"+params["nonce"]+":"+ha2))
+	}
+	if params["opaque"] != "" {
+		auth += fmt.Sprintf(`, opaque=%q`, params["opaque"])
+	}
+	if params["algorithm"] != "" {
+		auth += ", algorithm=MD5"
+	}
+	return auth, nil
+}
+
+// splitDigestParams splits a challenge at commas outside quotes
+func splitDigestParams(s string) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// snapshotStatus is the GET /api/snapshots response
+type snapshotStatus struct {
+	Cameras    int        `json:"cameras"`
+	Grabbed    int64      `json:"grabbed"` // since the server started
+	Failed     int64      `json:"failed"`
+	Skipped    int64      `json:"skipped"` // overview camera busy
+	LastAt     *time.Time `json:"last_grabbed_at,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	LastCamera string     `json:"last_camera,omitempty"`
+}
+
+// HandleSnapshotsAPI reports how scene snapshots are doing
+func (s *Server) HandleSnapshotsAPI(w http.ResponseWriter, r *http.Request) {
+	c := s.Snapshots
+	if c == nil {
+		s.jsonError(w, "scene snapshots are not configured (-snapshot-config)", http.StatusNotFound)
+		return
+	}
+	c.mu.Lock()
+	st := snapshotStatus{Cameras: len(c.Cameras), Grabbed: c.grabbed, Failed: c.failed, Skipped: c.skipped, LastCamera: c.lastFrom}
+	if !c.lastAt.IsZero() {
+		st.LastAt = ptr(c.lastAt)
+	}
+	if c.lastErr != nil {
+		st.LastError = c.lastErr.Error()
+	}
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
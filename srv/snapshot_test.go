@@ -0,0 +1,103 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// digestCamera serves a JPEG snapshot behind digest authentication
+func digestCamera(jpg []byte) (*httptest.Server, *atomic.Int64) {
+	var served atomic.Int64
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := map[string]string{}
+		auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Digest ")
+		for _, part := range splitDigestParams(auth) {
+			k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			params[k] = strings.Trim(v, `"`)
+		}
+		h := func(s string) string { return fmt.Sprintf("%x", md5.Sum([]byte(s))) }
+		want := h(h("admin:cam:secret") + ":n0nce:" + params["nc"] + ":" + params["cnonce"] + ":auth:" + h("GET:"+r.URL.RequestURI()))
+		if !ok || params["response"] != want || params["uri"] != r.URL.RequestURI() {
+			w.Header().Set("WWW-Authenticate", `Digest realm="cam", qop="auth", nonce="n0nce", opaque="x"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		served.Add(1)
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(jpg)
+	})), &served
+}
+
+func TestSceneSnapshot(t *testing.T) {
+	var jpg bytes.Buffer
+	jpeg.Encode(&jpg, image.NewGray(image.Rect(0, 0, 64, 48)), nil)
+	cam, served := digestCamera(jpg.Bytes())
+	defer cam.Close()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "snapshots.json")
+	os.WriteFile(cfgPath, []byte(`{"cameras": {
+		"CAM-1": {"url": "`+cam.URL+`/ISAPI/Streaming/channels/101/picture?x=1", "username": "admin", "password": "secret"},
+		"gate":  {"url": "`+strings.Replace(cam.URL, "http://", "http://admin:wrong@", 1)+`/snap.jpg"}
+	}}`), 0o644)
+	cfg, err := LoadSnapshotConfig(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t)
+	s.Snapshots = cfg
+	ctx := context.Background()
+	for _, body := range []string{
+		`{"carID":"1","plateUTF8":"SN1","camera_info":{"SerialNumber":"CAM-1"}}`,
+		`{"carID":"2","plateUTF8":"SN2","sensorProviderID":"gate"}`,
+		`{"carID":"3","plateUTF8":"SN3","sensorProviderID":"other"}`,
+	} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+		s.background.Wait()
+	}
+
+	q := dbgen.New(s.DB)
+	for id, want := range map[int64]int{1: 1, 2: 0, 3: 0} {
+		images, _ := q.GetImagesByEventID(ctx, id)
+		if len(images) != want || want > 0 && deref(images[0].ImageType) != "scene" {
+			t.Errorf("event %d: %d images %+v, want %d scene", id, len(images), images, want)
+		}
+	}
+	if served.Load() != 1 {
+		t.Errorf("camera served %d snapshots", served.Load())
+	}
+
+	w := httptest.NewRecorder()
+	s.HandleSnapshotsAPI(w, httptest.NewRequest("GET", "/api/snapshots", nil))
+	if body := w.Body.String(); !strings.Contains(body, `"grabbed":1,"failed":1`) || !strings.Contains(body, "401") {
+		t.Errorf("status: %s", body)
+	}
+
+	for _, bad := range []string{
+		`{"cameras": {}}`,
+		`{"cameras": {"CAM-1": {"url": "ftp://cam/snap.jpg"}}}`,
+		`{"cameras": {"CAM-1": {"url": "http://cam/snap.jpg", "delay": -5}}}`,
+		`{"cameras": {"CAM-1": {"url": "rtsp://cam/stream"}}, "ffmpeg": "/nonexistent/ffmpeg"}`,
+	} {
+		os.WriteFile(cfgPath, []byte(bad), 0o644)
+		if _, err := LoadSnapshotConfig(cfgPath); err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Lazy image loading: <img class="lazy" data-src="..."> gets its src when it
+// scrolls near the viewport, so long event tables don't fetch every image
+// at once. Call lazyImages(root) after adding rows.
+(function () {
+  var observer = null;
+  if ('IntersectionObserver' in window) {
+    observer = new IntersectionObserver(function (entries) {
+      entries.forEach(function (entry) {
+        if (entry.isIntersecting) {
+          load(entry.target);
+          observer.unobserve(entry.target);
+        }
+      });
+    }, { rootMargin: '300px 0px' });
+  }
+
+  function load(img) {
+    if (img.dataset.src) {
+      img.src = img.dataset.src;
+      delete img.dataset.src;
+    }
+  }
+
+  window.lazyImages = function (root) {
+    (root || document).querySelectorAll('img.lazy[data-src]').forEach(function (img) {
+      if (observer) {
+        observer.observe(img);
+      } else {
+        load(img);
+      }
+    });
+  };
+
+  if (document.readyState === 'loading') {
+    document.addEventListener('DOMContentLoaded', function () { window.lazyImages(); });
+  } else {
+    window.lazyImages();
+  }
+})();
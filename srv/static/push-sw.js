@@ -0,0 +1,31 @@
+// Service worker for browser notifications (see push.js): shows each push
+// message from the server and opens its page when the notification is
+// clicked, reusing a tab that already shows it.
+self.addEventListener('push', function (event) {
+  var msg = {};
+  if (event.data) {
+    try {
+      msg = event.data.json();
+    } catch (e) {
+      msg = { body: event.data.text() };
+    }
+  }
+  event.waitUntil(self.registration.showNotification(msg.title || 'Car API', {
+    body: msg.body || '',
+    tag: msg.tag,
+    data: { url: msg.url || '/' }
+  }));
+});
+
+self.addEventListener('notificationclick', function (event) {
+  event.notification.close();
+  var url = new URL(event.notification.data.url, self.location.origin).href;
+  event.waitUntil(self.clients.matchAll({ type: 'window', includeUncontrolled: true }).then(function (tabs) {
+    for (var i = 0; i < tabs.length; i++) {
+      if (tabs[i].url === url && 'focus' in tabs[i]) {
+        return tabs[i].focus();
+      }
+    }
+    return self.clients.openWindow(url);
+  }));
+});
@@ -0,0 +1,76 @@
+// Browser notifications: the 🔔 button registers the push service worker and
+// subscribes this browser with the server's VAPID key to session, export and
+// review notifications; pressing it again unsubscribes. Push is only offered
+// on pages served over HTTPS or from localhost.
+(function () {
+  var btn = document.getElementById('push-btn');
+  if (!btn || !('serviceWorker' in navigator) || !('PushManager' in window)) {
+    return;
+  }
+  btn.hidden = false;
+
+  function keyBytes(s) {
+    var raw = atob(s.replace(/-/g, '+').replace(/_/g, '/'));
+    var out = new Uint8Array(raw.length);
+    for (var i = 0; i < raw.length; i++) {
+      out[i] = raw.charCodeAt(i);
+    }
+    return out;
+  }
+
+  function show(sub) {
+    btn.disabled = false;
+    btn.textContent = sub ? '🔔 Notifications on' : '🔕 Notifications off';
+    btn.title = sub
+      ? 'This browser is notified when a session ends, an export is ready or an archive is assigned to you. Click to turn off.'
+      : 'Get browser notifications when a session ends, an export is ready or an archive is assigned to you';
+  }
+
+  function post(method, url, body) {
+    return fetch(url, {
+      method: method,
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(body)
+    }).then(function (resp) {
+      if (!resp.ok && resp.status !== 404) {
+        return resp.json().then(function (e) { throw new Error(e.error || resp.statusText); });
+      }
+      return resp;
+    });
+  }
+
+  var registration = navigator.serviceWorker.register('/static/push-sw.js', { scope: '/static/' });
+  registration.then(function (reg) { return reg.pushManager.getSubscription(); }).then(show, function () {
+    btn.hidden = true;
+  });
+
+  function subscribe(reg) {
+    return fetch('/api/push/key').then(function (resp) { return resp.json(); }).then(function (key) {
+      return reg.pushManager.subscribe({ userVisibleOnly: true, applicationServerKey: keyBytes(key.public_key) }).then(function (sub) {
+        var body = sub.toJSON();
+        body.topics = key.topics;
+        return post('POST', '/api/push/subscriptions', body)
+          .then(function () { return post('POST', '/api/push/test', { endpoint: sub.endpoint }); })
+          .then(function () { return sub; });
+      });
+    });
+  }
+
+  function unsubscribe(sub) {
+    return post('DELETE', '/api/push/subscriptions', { endpoint: sub.endpoint })
+      .then(function () { return sub.unsubscribe(); })
+      .then(function () { return null; });
+  }
+
+  window.togglePush = function () {
+    btn.disabled = true;
+    registration.then(function (reg) {
+      return reg.pushManager.getSubscription().then(function (sub) {
+        return sub ? unsubscribe(sub) : subscribe(reg);
+      });
+    }).then(show, function (err) {
+      alert('Notifications: ' + err.message);
+      registration.then(function (reg) { return reg.pushManager.getSubscription(); }).then(show);
+    });
+  };
+})();
@@ -0,0 +1,192 @@
+// The small frontend layer the dashboard, event and compare pages share:
+// calls to the JSON APIs, building elements without HTML injection,
+// accessible modals, keyboard navigation of table rows and single-key
+// shortcuts. Everything is on window.ui.
+(function () {
+  var ui = {};
+
+  // request calls a JSON endpoint and resolves with {data, headers}. It
+  // rejects with an Error carrying the server's message and the HTTP status.
+  ui.request = function (method, path, body) {
+    var opts = { method: method, headers: { 'Accept': 'application/json' } };
+    if (body !== undefined) {
+      opts.headers['Content-Type'] = 'application/json';
+      opts.body = JSON.stringify(body);
+    }
+    return fetch(path, opts).then(function (resp) {
+      return resp.text().then(function (text) {
+        var data = null;
+        try {
+          data = text ? JSON.parse(text) : null;
+        } catch (e) {
+          data = null;
+        }
+        if (!resp.ok || (data && data.success === false)) {
+          var err = new Error((data && data.message) || text.trim() || resp.statusText);
+          err.status = resp.status;
+          throw err;
+        }
+        return { data: data, headers: resp.headers };
+      });
+    });
+  };
+
+  // api is request for callers that only need the body
+  ui.api = function (method, path, body) {
+    return ui.request(method, path, body).then(function (r) { return r.data; });
+  };
+
+  // el builds an element. attrs sets properties and attributes: "class",
+  // "text", "data-*" and "aria-*" attributes, on* listeners (functions);
+  // null and false values are skipped. Children are nodes or strings
+  // (inserted as text), nested arrays are flattened.
+  ui.el = function (tag, attrs) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (key) {
+      var value = attrs[key];
+      if (value === null || value === undefined || value === false) return;
+      if (key === 'class') {
+        node.className = value;
+      } else if (key === 'text') {
+        node.textContent = value;
+      } else if (key.slice(0, 2) === 'on' && typeof value === 'function') {
+        node.addEventListener(key.slice(2), value);
+      } else if (key.indexOf('-') >= 0 || key === 'role' || key === 'title' || key === 'tabindex' || key === 'list') {
+        node.setAttribute(key, value === true ? '' : value);
+      } else {
+        node[key] = value;
+      }
+    });
+    append(node, Array.prototype.slice.call(arguments, 2));
+    return node;
+  };
+
+  function append(node, children) {
+    children.forEach(function (child) {
+      if (child === null || child === undefined || child === false) return;
+      if (Array.isArray(child)) {
+        append(node, child);
+      } else {
+        node.appendChild(typeof child === 'object' ? child : document.createTextNode(String(child)));
+      }
+    });
+  }
+
+  // empty is the grey dash of a missing value
+  ui.empty = function () {
+    return ui.el('span', { class: 'empty', 'aria-label': 'none' }, '-');
+  };
+
+  // announce reads a message out to screen readers
+  ui.announce = function (message) {
+    var region = document.getElementById('ui-status');
+    if (!region) {
+      region = ui.el('div', { id: 'ui-status', class: 'sr-only', role: 'status', 'aria-live': 'polite' });
+      document.body.appendChild(region);
+    }
+    region.textContent = message;
+  };
+
+  // Modals: openModal shows one, moves focus into it and keeps Tab inside;
+  // Escape or closeModal hides it and returns focus to where it was.
+  var openModals = [];
+  var focusable = 'a[href], button:not([disabled]), input:not([disabled]), select, textarea, [tabindex]:not([tabindex="-1"])';
+
+  ui.openModal = function (modal) {
+    if (typeof modal === 'string') modal = document.getElementById(modal);
+    if (modal.classList.contains('active')) return;
+    openModals.push({ modal: modal, returnTo: document.activeElement });
+    modal.classList.add('active');
+    modal.setAttribute('aria-hidden', 'false');
+    var first = modal.querySelector(focusable);
+    if (first) first.focus();
+  };
+
+  ui.closeModal = function (modal) {
+    if (typeof modal === 'string') modal = document.getElementById(modal);
+    if (!modal.classList.contains('active')) return;
+    modal.classList.remove('active');
+    modal.setAttribute('aria-hidden', 'true');
+    for (var i = openModals.length - 1; i >= 0; i--) {
+      if (openModals[i].modal === modal) {
+        var returnTo = openModals[i].returnTo;
+        openModals.splice(i, 1);
+        if (returnTo && returnTo.focus) returnTo.focus();
+        break;
+      }
+    }
+  };
+
+  document.addEventListener('keydown', function (e) {
+    var top = openModals[openModals.length - 1];
+    if (!top) return;
+    if (e.key === 'Escape') {
+      ui.closeModal(top.modal);
+      e.preventDefault();
+    } else if (e.key === 'Tab') {
+      var items = top.modal.querySelectorAll(focusable);
+      if (!items.length) return;
+      var first = items[0], last = items[items.length - 1];
+      if (e.shiftKey && document.activeElement === first) {
+        last.focus();
+        e.preventDefault();
+      } else if (!e.shiftKey && document.activeElement === last) {
+        first.focus();
+        e.preventDefault();
+      }
+    }
+  }, true);
+
+  // typing reports whether a key press goes into a form field
+  function typing(e) {
+    return e.target.closest && e.target.closest('input, textarea, select, [contenteditable]');
+  }
+
+  // rowNav makes the rows of a table body focusable: ↓/j and ↑/k move
+  // between them, Home/End go to the first and last, Enter opens one.
+  // Call it again after replacing the rows; the focused row (by
+  // data-event-id) keeps the focus.
+  ui.rowNav = function (tbody, open) {
+    Array.prototype.forEach.call(tbody.rows, function (tr) { tr.tabIndex = 0; });
+    if (tbody.dataset.rowNav) return;
+    tbody.dataset.rowNav = '1';
+    tbody.addEventListener('keydown', function (e) {
+      var tr = e.target.closest('tr');
+      if (!tr || e.target !== tr || e.altKey || e.ctrlKey || e.metaKey) return;
+      var rows = tbody.rows, next = null;
+      switch (e.key) {
+        case 'ArrowDown': case 'j': next = tr.nextElementSibling; break;
+        case 'ArrowUp': case 'k': next = tr.previousElementSibling; break;
+        case 'Home': next = rows[0]; break;
+        case 'End': next = rows[rows.length - 1]; break;
+        case 'Enter': case ' ': open(tr); e.preventDefault(); return;
+        default: return;
+      }
+      if (next) {
+        next.focus();
+        next.scrollIntoView({ block: 'nearest' });
+      }
+      e.preventDefault();
+    });
+  };
+
+  // focusedRow is the table row that has the keyboard focus
+  ui.focusedRow = function (tbody) {
+    var tr = document.activeElement && document.activeElement.closest && document.activeElement.closest('tr');
+    return tr && tbody.contains(tr) ? tr : null;
+  };
+
+  // shortcuts binds single keys, e.g. {'/': focusSearch}, outside form fields
+  ui.shortcuts = function (keys) {
+    document.addEventListener('keydown', function (e) {
+      if (typing(e) || e.altKey || e.ctrlKey || e.metaKey || openModals.length) return;
+      var fn = keys[e.key];
+      if (fn) {
+        fn(e);
+        e.preventDefault();
+      }
+    });
+  };
+
+  window.ui = ui;
+})();
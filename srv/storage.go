@@ -0,0 +1,186 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// The storage report (/storage, /api/storage) is for planning retention:
+// what each archive occupies, how fast the data dir's disk fills and when
+// it will be full at that pace. Sizes come from the database (stored bytes
+// of camera JSON and images), so it stays cheap on millions of files; page
+// overhead, thumbnails and indexes only show in the database file size.
+
+// storageGrowthDays is the window the daily growth is averaged over
+const storageGrowthDays = 7
+
+// storageReport is the GET /api/storage response
+type storageReport struct {
+	DBBytes     int64                          `json:"db_bytes"`      // database file, without the WAL
+	DBFreeBytes int64                          `json:"db_free_bytes"` // free pages a VACUUM would return
+	FilesIn     string                         `json:"files_in"`      // "data dir" or "blob store"
+	Disk        *diskUsage                     `json:"disk,omitempty"`
+	Archives    []dbgen.GetStorageByArchiveRow `json:"archives"` // archive_id null is the current session
+	Days        []dbgen.GetStorageByDayRow     `json:"days"`     // bytes stored per day received
+	GrowthBytes int64                          `json:"growth_bytes_per_day"`
+	DaysToFull  *float64                       `json:"days_until_full,omitempty"`
+}
+
+// diskUsage is the filesystem holding the data dir
+type diskUsage struct {
+	TotalBytes int64 `json:"total_bytes"`
+	FreeBytes  int64 `json:"free_bytes"`
+}
+
+// UsedPct is the share of the disk in use
+func (d diskUsage) UsedPct() float64 {
+	if d.TotalBytes == 0 {
+		return 0
+	}
+	return float64(d.TotalBytes-d.FreeBytes) * 100 / float64(d.TotalBytes)
+}
+
+// loadStorageReport measures storage, with days received since days ago
+func (s *Server) loadStorageReport(ctx context.Context, days int) (storageReport, error) {
+	rep := storageReport{FilesIn: "data dir"}
+	if s.Blobs != nil {
+		rep.FilesIn = "blob store"
+	}
+	var pages, pageSize, free int64
+	for pragma, v := range map[string]*int64{"page_count": &pages, "page_size": &pageSize, "freelist_count": &free} {
+		if err := s.DB.QueryRowContext(ctx, "PRAGMA "+pragma).Scan(v); err != nil {
+			return rep, err
+		}
+	}
+	rep.DBBytes, rep.DBFreeBytes = pages*pageSize, free*pageSize
+
+	q := dbgen.New(s.DB)
+	var err error
+	if rep.Archives, err = q.GetStorageByArchive(ctx); err != nil {
+		return rep, err
+	}
+	today := time.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+	if rep.Days, err = q.GetStorageByDay(ctx, today.AddDate(0, 0, -days)); err != nil {
+		return rep, err
+	}
+
+	// Growth of the local disk over the last full days: the database, and
+	// the files unless they go to a blob store
+	since := today.AddDate(0, 0, -storageGrowthDays).Format(time.DateOnly)
+	var added int64
+	for _, d := range rep.Days {
+		if d.Day < since || d.Day >= today.Format(time.DateOnly) {
+			continue
+		}
+		added += d.DbBytes
+		if s.Blobs == nil {
+			added += d.FileBytes
+		}
+	}
+	rep.GrowthBytes = added / storageGrowthDays
+
+	if total, free, err := diskSpace(s.DataDir); err == nil {
+		rep.Disk = &diskUsage{TotalBytes: total, FreeBytes: free}
+		if rep.GrowthBytes > 0 {
+			rep.DaysToFull = ptr(float64(free) / float64(rep.GrowthBytes))
+		}
+	} else {
+		slog.Debug("disk space unavailable", "dir", s.DataDir, "error", err)
+	}
+	return rep, nil
+}
+
+// storageDays reads ?days=, the days of daily growth to list (default 30)
+func storageDays(r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("days")
+	if v == "" {
+		return 30, true
+	}
+	days, err := strconv.Atoi(v)
+	return days, err == nil && days >= storageGrowthDays && days <= 366
+}
+
+// HandleStorage shows the storage report
+func (s *Server) HandleStorage(w http.ResponseWriter, r *http.Request) {
+	days, ok := storageDays(r)
+	if !ok {
+		http.Error(w, "days must be 7-366", http.StatusBadRequest)
+		return
+	}
+	rep, err := s.loadStorageReport(r.Context(), days)
+	if err != nil {
+		slog.Warn("failed to load storage report", "error", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	type archiveRow struct {
+		dbgen.GetStorageByArchiveRow
+		Total int64
+	}
+	type dayRow struct {
+		dbgen.GetStorageByDayRow
+		Total  int64
+		BarPct float64
+	}
+	data := struct {
+		Hostname   string
+		Report     storageReport
+		Days       int
+		Archives   []archiveRow
+		DayRows    []dayRow
+		DaysToFull float64 // -1 when unknown
+	}{
+		Hostname:   s.Hostname,
+		Report:     rep,
+		Days:       days,
+		DaysToFull: -1,
+	}
+	for _, a := range rep.Archives {
+		data.Archives = append(data.Archives, archiveRow{a, a.DbBytes + a.FileBytes})
+	}
+	var maxDay int64
+	for _, d := range rep.Days {
+		maxDay = max(maxDay, d.DbBytes+d.FileBytes)
+	}
+	for _, d := range rep.Days {
+		row := dayRow{GetStorageByDayRow: d, Total: d.DbBytes + d.FileBytes}
+		if maxDay > 0 {
+			row.BarPct = float64(row.Total) * 100 / float64(maxDay)
+		}
+		data.DayRows = append(data.DayRows, row)
+	}
+	if rep.DaysToFull != nil {
+		data.DaysToFull = *rep.DaysToFull
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "storage.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleStorageAPI returns the storage report as JSON.
+// Pass ?days=N (7-366) for more or fewer days of growth.
+func (s *Server) HandleStorageAPI(w http.ResponseWriter, r *http.Request) {
+	days, ok := storageDays(r)
+	if !ok {
+		s.jsonError(w, "days must be 7-366", http.StatusBadRequest)
+		return
+	}
+	rep, err := s.loadStorageReport(r.Context(), days)
+	if err != nil {
+		slog.Warn("failed to load storage report", "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rep)
+}
@@ -0,0 +1,73 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStorageReport(t *testing.T) {
+	s := newTestServer(t)
+	var pic bytes.Buffer
+	png.Encode(&pic, image.NewGray(image.Rect(0, 0, 8, 8)))
+	ctx := context.Background()
+	const body = `{"plateUTF8":"ST1"}`
+	for _, ago := range []int{3, 1, 0} {
+		req := newIngestRequest([]byte(body), "", []uploadedImage{{Filename: "plate.png", Data: pic.Bytes()}})
+		req.ReceivedAt = req.ReceivedAt.AddDate(0, 0, -ago)
+		if _, err := s.ingestEvent(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.DB.Exec("INSERT INTO archives (name) VALUES ('week 1')")
+	s.DB.Exec("UPDATE events SET archive_id = 1 WHERE id = 1")
+
+	w := httptest.NewRecorder()
+	s.HandleStorageAPI(w, httptest.NewRequest("GET", "/api/storage", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var rep storageReport
+	json.Unmarshal(w.Body.Bytes(), &rep)
+	if rep.DBBytes == 0 || rep.FilesIn != "data dir" {
+		t.Errorf("database %d bytes, files in %q", rep.DBBytes, rep.FilesIn)
+	}
+	// Each event holds its JSON and image in the database and as files
+	perEvent := int64(len(body) + pic.Len())
+	if len(rep.Archives) != 2 {
+		t.Fatalf("archives: %+v", rep.Archives)
+	}
+	if a := rep.Archives[0]; a.ArchiveID != nil || a.Events != 2 || a.Images != 2 || a.DbBytes != 2*perEvent || a.FileBytes != 2*perEvent {
+		t.Errorf("current session: %+v", a)
+	}
+	if a := rep.Archives[1]; a.ArchiveID == nil || a.Name != "week 1" || a.Events != 1 || a.DbBytes != perEvent {
+		t.Errorf("archive: %+v", a)
+	}
+	if len(rep.Days) != 3 {
+		t.Errorf("days: %+v", rep.Days)
+	}
+	// Today doesn't count towards growth until it is over
+	if want := 2 * 2 * perEvent / storageGrowthDays; rep.GrowthBytes != want {
+		t.Errorf("growth %d bytes/day, want %d", rep.GrowthBytes, want)
+	}
+	if rep.Disk != nil && (rep.DaysToFull == nil || *rep.DaysToFull <= 0) {
+		t.Errorf("days until full: %v", rep.DaysToFull)
+	}
+
+	w = httptest.NewRecorder()
+	s.HandleStorage(w, httptest.NewRequest("GET", "/storage", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `href="/archive/1">week 1</a>`) {
+		t.Errorf("page: %d %s", w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	s.HandleStorageAPI(w, httptest.NewRequest("GET", "/api/storage?days=2", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("days=2: %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,93 @@
+package srv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// Lines may carry base64 images, so allow them to be large
+const streamMaxLineSize = 64 << 20
+
+type streamLineResult struct {
+	Line    int    `json:"line"`
+	Success bool   `json:"success"`
+	ID      int64  `json:"id,omitempty"`
+	UID     string `json:"uid,omitempty"`
+	Plate   string `json:"plate,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// HandleStream ingests an NDJSON body, one camera event per line, for
+// high-rate backfills. Every non-empty line is processed independently and
+// reported in the response; ?errors_only=1 omits successful lines.
+func (s *Server) HandleStream(w http.ResponseWriter, r *http.Request) {
+	errorsOnly := r.URL.Query().Get("errors_only") == "1"
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), streamMaxLineSize)
+
+	results := []streamLineResult{}
+	var lineNo, recorded, failed int
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		res, err := s.ingest(r.Context(), newIngestRequest(bytes.Clone(line), "", nil))
+		if err != nil {
+			failed++
+			msg := "database error"
+			var pe *payloadError
+			if errors.As(err, &pe) {
+				msg = pe.msg
+			} else {
+				slog.Error("failed to insert streamed event", "line", lineNo, "error", err)
+			}
+			results = append(results, streamLineResult{Line: lineNo, Message: msg})
+			continue
+		}
+		recorded++
+		if !errorsOnly {
+			results = append(results, streamLineResult{Line: lineNo, Success: true, ID: res.ID, UID: res.UID, Plate: res.Plate})
+		}
+	}
+
+	status := http.StatusOK
+	message := "stream processed"
+	if err := scanner.Err(); err != nil {
+		// Lines before the failure are already stored; report what we have
+		status = http.StatusBadRequest
+		message = fmt.Sprintf("stream aborted at line %d: %v", lineNo+1, err)
+	}
+
+	slog.Info("stream ingested", "lines", lineNo, "recorded", recorded, "failed", failed)
+
+	// Lines may come from several cameras, so only the endpoint's or the
+	// sender's acknowledgment applies, failed if any line was
+	var streamErr error
+	if status != http.StatusOK {
+		streamErr = errors.New(message)
+	} else if failed > 0 {
+		streamErr = fmt.Errorf("%d of %d lines failed", failed, recorded+failed)
+	}
+	if s.writeAck(w, r, ingestResult{}, streamErr) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  failed == 0 && status == http.StatusOK,
+		"message":  message,
+		"recorded": recorded,
+		"failed":   failed,
+		"results":  results,
+	})
+}
@@ -0,0 +1,55 @@
+package srv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStreamIngest(t *testing.T) {
+	s := newTestServer(t)
+	type response struct {
+		Success  bool               `json:"success"`
+		Message  string             `json:"message"`
+		Recorded int                `json:"recorded"`
+		Failed   int                `json:"failed"`
+		Results  []streamLineResult `json:"results"`
+	}
+	post := func(target, body string) (int, response) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.HandleStream(w, httptest.NewRequest("POST", target, strings.NewReader(body)))
+		var res response
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, res
+	}
+
+	code, res := post("/api/stream", "{\"plateUTF8\":\"NS1\"}\n\nnot json\n  {\"plateUTF8\":\"NS2\"}  \n")
+	if code != http.StatusOK || res.Success || res.Recorded != 2 || res.Failed != 1 || len(res.Results) != 3 {
+		t.Fatalf("%d %+v", code, res)
+	}
+	if r := res.Results[0]; !r.Success || r.Line != 1 || r.Plate != "NS1" || r.ID == 0 || r.UID == "" {
+		t.Errorf("line 1: %+v", r)
+	}
+	// Blank lines count but aren't reported; a bad line doesn't stop the rest
+	if r := res.Results[1]; r.Success || r.Line != 3 || !strings.HasPrefix(r.Message, "invalid JSON") {
+		t.Errorf("line 3: %+v", r)
+	}
+	if r := res.Results[2]; !r.Success || r.Line != 4 || r.Plate != "NS2" {
+		t.Errorf("line 4: %+v", r)
+	}
+
+	if code, res = post("/api/stream?errors_only=1", "{\"plateUTF8\":\"NS3\"}\n"); code != http.StatusOK || !res.Success || len(res.Results) != 0 {
+		t.Errorf("errors_only: %d %+v", code, res)
+	}
+
+	// A line over the limit aborts the stream; earlier lines are kept
+	long := "{\"plateUTF8\":\"NS4\"}\n{\"x\":\"" + strings.Repeat("a", streamMaxLineSize) + "\"}\n"
+	if code, res = post("/api/stream", long); code != http.StatusBadRequest || res.Recorded != 1 || !strings.Contains(res.Message, "line 2") {
+		t.Errorf("oversized line: %d %+v", code, res)
+	}
+}
@@ -0,0 +1,160 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Event lists show small thumbnails instead of full camera frames, which
+// are often several megabytes each. Thumbnails of ThumbWidth are made when
+// an image is stored, other widths on first request, and all are cached in
+// DataDir/thumbs.
+
+// DefaultThumbWidth is the width of thumbnails made at ingest
+const DefaultThumbWidth = 160
+
+const (
+	maxThumbWidth = 640
+	thumbQuality  = 75
+)
+
+func (s *Server) thumbPath(id int64, width int) string {
+	return filepath.Join(s.DataDir, "thumbs", fmt.Sprintf("%d-%d.jpg", id, width))
+}
+
+// removeThumbs deletes the cached thumbnails of an image
+func (s *Server) removeThumbs(id int64) {
+	matches, _ := filepath.Glob(filepath.Join(s.DataDir, "thumbs", fmt.Sprintf("%d-*.jpg", id)))
+	for _, m := range matches {
+		os.Remove(m)
+	}
+}
+
+// HandleImageThumb serves a downscaled JPEG of an image, e.g.
+// /image/42/thumb?w=320, by default ThumbWidth wide. Images that can't be
+// decoded are served as is.
+func (s *Server) HandleImageThumb(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid image id", http.StatusBadRequest)
+		return
+	}
+	width := s.ThumbWidth
+	if width <= 0 {
+		width = DefaultThumbWidth
+	}
+	if v := r.URL.Query().Get("w"); v != "" {
+		width, err = strconv.Atoi(v)
+		if err != nil || width < 16 || width > maxThumbWidth {
+			http.Error(w, fmt.Sprintf("invalid width (16-%d)", maxThumbWidth), http.StatusBadRequest)
+			return
+		}
+	}
+
+	data, contentType, err := s.thumbnail(r.Context(), id, width)
+	if err != nil {
+		http.Error(w, "image not found", http.StatusNotFound)
+		return
+	}
+	writeThumb(w, data, contentType)
+}
+
+// thumbnail returns a cached or freshly scaled thumbnail of an image, or the
+// image itself when it can't be decoded or is already narrow enough
+func (s *Server) thumbnail(ctx context.Context, id int64, width int) ([]byte, string, error) {
+	path := s.thumbPath(id, width)
+	if data, err := os.ReadFile(path); err == nil {
+		return data, "image/jpeg", nil
+	}
+
+	data, err := s.loadImage(ctx, dbgen.New(s.DB), id)
+	if err != nil {
+		return nil, "", err
+	}
+	if thumb := s.makeThumb(id, data, width); thumb != nil {
+		return thumb, "image/jpeg", nil
+	}
+	return data, http.DetectContentType(data), nil
+}
+
+// cacheIngestThumb makes the ThumbWidth thumbnail of a newly stored image so
+// the first page showing it doesn't have to decode the original
+func (s *Server) cacheIngestThumb(id int64, data []byte) {
+	if s.ThumbWidth > 0 {
+		s.makeThumb(id, data, s.ThumbWidth)
+	}
+}
+
+// makeThumb scales an image to the given width and caches the JPEG. It
+// returns nil when the image can't be decoded or is already narrow enough.
+func (s *Server) makeThumb(id int64, data []byte, width int) []byte {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil || src.Bounds().Dx() <= width {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(src, width), &jpeg.Options{Quality: thumbQuality}); err != nil {
+		slog.Warn("encode thumbnail", "image_id", id, "error", err)
+		return nil
+	}
+	path := s.thumbPath(id, width)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			slog.Warn("cache thumbnail", "image_id", id, "error", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func writeThumb(w http.ResponseWriter, data []byte, contentType string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(data)
+}
+
+// scaleDown resizes src to the given width with a box filter, keeping the
+// aspect ratio
+func scaleDown(src image.Image, width int) *image.RGBA {
+	b := src.Bounds()
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := max(b.Min.X+(x+1)*b.Dx()/width, x0+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
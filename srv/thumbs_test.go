@@ -0,0 +1,143 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestIngestThumbnails(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.DataDir
+	s.ThumbWidth = 100
+	ctx := context.Background()
+
+	wide := pngOf(400, 200)
+	body := `{"carID":"1","plateUTF8":"AB123","ImageArray":[{"ImageType":"vehicle","ImageFormat":"png","BinaryImage":"` +
+		base64.StdEncoding.EncodeToString(wide) + `"},{"ImageType":"plate","ImageFormat":"png","BinaryImage":"` +
+		base64.StdEncoding.EncodeToString(pngOf(60, 20)) + `"}]}`
+	res, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.background.Wait()
+	images, _ := dbgen.New(s.DB).GetImagesByEventID(ctx, res.ID)
+	if len(images) != 2 {
+		t.Fatalf("images = %d, want 2", len(images))
+	}
+	vehicle, plate := images[0].ID, images[1].ID
+
+	// The wide image has its thumbnail, made in the background with its
+	// measurements, before anyone asks; the narrow one is served as is
+	if _, err := os.Stat(s.thumbPath(vehicle, 100)); err != nil {
+		t.Errorf("no thumbnail cached at ingest: %v", err)
+	}
+	if _, err := os.Stat(s.thumbPath(plate, 100)); err == nil {
+		t.Error("thumbnail cached for an image narrower than ThumbWidth")
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/image/1/thumb", nil)
+	r.SetPathValue("id", "1")
+	s.HandleImageThumb(w, r)
+	thumb, err := jpeg.DecodeConfig(w.Body)
+	if err != nil {
+		t.Fatalf("GET /image/1/thumb: %d %v", w.Code, err)
+	}
+	if thumb.Width != 100 || thumb.Height != 50 {
+		t.Errorf("thumbnail is %dx%d, want 100x50", thumb.Width, thumb.Height)
+	}
+
+	// Off means on request only
+	s.ThumbWidth = 0
+	s.cacheIngestThumb(plate+1, wide)
+	if matches, _ := filepath.Glob(filepath.Join(dir, "thumbs", "*")); len(matches) != 1 {
+		t.Errorf("thumbs dir has %d files, want 1", len(matches))
+	}
+}
+
+func TestLazyEventPages(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	for _, plate := range []string{"LZ1", "LZ2", "LZ3"} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"`+plate+`"}`), "", []uploadedImage{{Filename: "vehicle.png", Data: pngOf(400, 200)}})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.background.Wait()
+
+	w := httptest.NewRecorder()
+	s.HandleEventsAPI(w, httptest.NewRequest("GET", "/api/events?limit=2", nil))
+	var events []dbgen.GetRecentEventsRow
+	json.Unmarshal(w.Body.Bytes(), &events)
+	if len(events) != 2 || w.Header().Get("X-Total-Count") != "3" {
+		t.Errorf("events API: %d events, total %q", len(events), w.Header().Get("X-Total-Count"))
+	}
+	w = httptest.NewRecorder()
+	s.HandleRoot(w, httptest.NewRequest("GET", "/?limit=2", nil))
+	if !strings.Contains(w.Body.String(), `href="?limit=all"`) {
+		t.Errorf("dashboard has no show-all link:\n%s", w.Body)
+	}
+
+	s.DB.Exec("INSERT INTO archives (name, event_count) VALUES ('big', 3)")
+	s.DB.Exec("UPDATE events SET archive_id = 1")
+	archive := func(target string) string {
+		t.Helper()
+		r := httptest.NewRequest("GET", target, nil)
+		r.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		s.HandleArchive(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", target, w.Code, w.Body)
+		}
+		return w.Body.String()
+	}
+	page := archive("/archive/1?limit=2")
+	if strings.Count(page, `data-src="/image/`) != 2 || !strings.Contains(page, "Showing 2 of 3 events") {
+		t.Errorf("capped archive:\n%s", page)
+	}
+	// Images load from thumbnails once scrolled into view, never eagerly
+	if strings.Contains(page, ` src="/image/`) {
+		t.Error("archive page loads full images")
+	}
+	if page := archive("/archive/1?limit=all"); strings.Count(page, `data-src="/image/`) != 3 || strings.Contains(page, "Showing") {
+		t.Error("limit=all still capped")
+	}
+
+	thumb := func(target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		r.SetPathValue("id", strings.Split(target, "/")[2])
+		w := httptest.NewRecorder()
+		s.HandleImageThumb(w, r)
+		return w
+	}
+	if w := thumb("/image/1/thumb?w=320"); w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("thumb: %d %v", w.Code, w.Header())
+	} else if c, _ := jpeg.DecodeConfig(w.Body); c.Width != 320 {
+		t.Errorf("thumb width %d, want 320", c.Width)
+	}
+	if w := thumb("/image/1/thumb?w=8"); w.Code != http.StatusBadRequest {
+		t.Errorf("tiny width: %d", w.Code)
+	}
+	if w := thumb("/image/99/thumb"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown image: %d", w.Code)
+	}
+}
+
+func pngOf(w, h int) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)))
+	return buf.Bytes()
+}
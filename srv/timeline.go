@@ -0,0 +1,326 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// /cameras/{camera}/timeline draws one camera's events, current and
+// archived, as a strip over time with each event's vehicle thumbnail, so
+// gaps, bursts and retrigger storms stand out while a camera is
+// commissioned. {camera} is a camera serial, sensor provider ID or IP as in
+// the search filter. The window is ?from=&to= (dates or timestamps in the
+// camera time zone, the last hour by default); the page zooms and pans by
+// changing it. Silences longer than ?gap= (5m) are gaps; runs of at least 3
+// events at most ?burst= (2s) apart are bursts, and a burst of one plate (or
+// none) is a retrigger storm.
+//
+//	GET /timeline                        cameras to pick from
+//	GET /api/cameras/{camera}/timeline   the same window as JSON
+
+const (
+	timelineLimit     = 2000 // events drawn at most; zoom in for the rest
+	timelineThumbs    = 150  // thumbnails are drawn up to this many events
+	timelineBurstMin  = 3
+	defaultTimeline   = time.Hour
+	defaultTimeGap    = 5 * time.Minute
+	defaultBurstSpace = 2 * time.Second
+)
+
+// timelineEvent is an event on the strip; Pos is its place in the window in
+// percent
+type timelineEvent struct {
+	ID        int64     `json:"id"`
+	CarID     string    `json:"car_id"`
+	Plate     string    `json:"plate,omitempty"`
+	CarState  string    `json:"car_state,omitempty"`
+	ArchiveID *int64    `json:"archive_id,omitempty"`
+	At        time.Time `json:"at"` // capture time, receive time if unknown
+	ImageID   int64     `json:"image_id,omitempty"`
+	Pos       float64   `json:"-"`
+}
+
+// timelineSpan is a stretch of the window; Pos and Width are in percent
+type timelineSpan struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Seconds float64   `json:"seconds"`
+	Pos     float64   `json:"-"`
+	Width   float64   `json:"-"`
+}
+
+type timelineBurst struct {
+	timelineSpan
+	Events    int  `json:"events"`
+	Plates    int  `json:"plates"`
+	Retrigger bool `json:"retrigger"` // a single plate, or none, triggered it
+}
+
+type timelineTick struct {
+	Pos   float64
+	Label string
+}
+
+// timeline is one camera's events in a window
+type timeline struct {
+	Camera    string          `json:"camera"`
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	Events    []timelineEvent `json:"events"`
+	Gaps      []timelineSpan  `json:"gaps"`
+	Bursts    []timelineBurst `json:"bursts"`
+	Truncated bool            `json:"truncated"` // more than timelineLimit events; zoom in
+	Ticks     []timelineTick  `json:"-"`
+}
+
+// buildTimeline places events (in time order) in the window from-to and
+// finds the gaps and bursts among them
+func buildTimeline(events []timelineEvent, from, to time.Time, gap, burst time.Duration) timeline {
+	t := timeline{From: from, To: to, Events: events, Gaps: []timelineSpan{}, Bursts: []timelineBurst{}}
+	span := to.Sub(from)
+	pos := func(at time.Time) float64 {
+		if span <= 0 {
+			return 0
+		}
+		return float64(at.Sub(from)) / float64(span) * 100
+	}
+	stretch := func(a, b time.Time) timelineSpan {
+		return timelineSpan{From: a, To: b, Seconds: b.Sub(a).Seconds(), Pos: pos(a), Width: pos(b) - pos(a)}
+	}
+	for i := range t.Events {
+		t.Events[i].Pos = pos(t.Events[i].At)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].At.Sub(events[i-1].At) > gap {
+			t.Gaps = append(t.Gaps, stretch(events[i-1].At, events[i].At))
+		}
+	}
+	for start := 0; start < len(events); {
+		end := start + 1
+		for end < len(events) && events[end].At.Sub(events[end-1].At) <= burst {
+			end++
+		}
+		if end-start >= timelineBurstMin {
+			plates := map[string]bool{}
+			for _, e := range events[start:end] {
+				if p := normalizePlate(e.Plate); p != "" {
+					plates[p] = true
+				}
+			}
+			t.Bursts = append(t.Bursts, timelineBurst{
+				timelineSpan: stretch(events[start].At, events[end-1].At),
+				Events:       end - start,
+				Plates:       len(plates),
+				Retrigger:    len(plates) <= 1,
+			})
+		}
+		start = end
+	}
+	t.Ticks = timelineTicks(from, to)
+	return t
+}
+
+// timelineSteps are the spacings the axis labels can have
+var timelineSteps = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// timelineTicks labels the axis of a window with at most 8 round times
+func timelineTicks(from, to time.Time) []timelineTick {
+	span := to.Sub(from)
+	if span <= 0 {
+		return nil
+	}
+	step := timelineSteps[len(timelineSteps)-1]
+	for _, d := range timelineSteps {
+		if span/d <= 8 {
+			step = d
+			break
+		}
+	}
+	layout := "15:04"
+	switch {
+	case span > 24*time.Hour:
+		layout = "01-02 15:04"
+	case step < time.Minute:
+		layout = "15:04:05"
+	}
+	var ticks []timelineTick
+	// Round in the window's zone so hour and day ticks fall on its midnight
+	_, offset := from.Zone()
+	shift := time.Duration(offset) * time.Second
+	for at := from.Add(shift).Truncate(step).Add(-shift); at.Before(to); at = at.Add(step) {
+		if !at.Before(from) {
+			ticks = append(ticks, timelineTick{Pos: float64(at.Sub(from)) / float64(span) * 100, Label: at.Format(layout)})
+		}
+	}
+	return ticks
+}
+
+// timelineQuery is the window and thresholds of a timeline request
+type timelineQuery struct {
+	From, To   time.Time
+	Gap, Burst time.Duration
+}
+
+// parseTimelineQuery reads the window and thresholds; times without a zone
+// are in the camera time zone
+func (s *Server) parseTimelineQuery(q url.Values) (timelineQuery, error) {
+	loc := s.CameraTZ
+	if loc == nil {
+		loc = time.Local
+	}
+	tq := timelineQuery{To: time.Now().In(loc), Gap: defaultTimeGap, Burst: defaultBurstSpace}
+	if v := strings.TrimSpace(q.Get("to")); v != "" {
+		t, err := parseFilterTime(v, loc, true)
+		if err != nil {
+			return tq, fmt.Errorf("invalid to %q: use a date (2006-01-02) or a timestamp", v)
+		}
+		tq.To = t.In(loc)
+	}
+	tq.From = tq.To.Add(-defaultTimeline)
+	if v := strings.TrimSpace(q.Get("from")); v != "" {
+		t, err := parseFilterTime(v, loc, false)
+		if err != nil {
+			return tq, fmt.Errorf("invalid from %q: use a date (2006-01-02) or a timestamp", v)
+		}
+		tq.From = t.In(loc)
+	}
+	if !tq.From.Before(tq.To) {
+		return tq, fmt.Errorf("from must be before to")
+	}
+	for _, d := range []struct {
+		name string
+		dst  *time.Duration
+	}{{"gap", &tq.Gap}, {"burst", &tq.Burst}} {
+		if v := q.Get(d.name); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil || parsed <= 0 {
+				return tq, fmt.Errorf("invalid %s %q: use a duration like 30s or 5m", d.name, v)
+			}
+			*d.dst = parsed
+		}
+	}
+	return tq, nil
+}
+
+// cameraTimeline builds the timeline of a camera
+func (s *Server) cameraTimeline(ctx context.Context, camera string, tq timelineQuery) (timeline, error) {
+	rows, err := dbgen.New(s.DB).GetCameraTimeline(ctx, dbgen.GetCameraTimelineParams{
+		Camera: &camera,
+		// Stored times are in the server's zone and compare as text
+		CapturedFrom: tq.From.In(time.Local),
+		CapturedTo:   tq.To.In(time.Local),
+		Limit:        timelineLimit + 1,
+	})
+	if err != nil {
+		return timeline{}, err
+	}
+	truncated := len(rows) > timelineLimit
+	if truncated {
+		rows = rows[:timelineLimit]
+	}
+	events := make([]timelineEvent, 0, len(rows))
+	for _, row := range rows {
+		at := row.CreatedAt
+		if row.CapturedAt != nil {
+			at = *row.CapturedAt
+		}
+		events = append(events, timelineEvent{
+			ID:        row.ID,
+			CarID:     row.CarID,
+			Plate:     deref(row.PlateUtf8),
+			CarState:  deref(row.CarState),
+			ArchiveID: row.ArchiveID,
+			At:        at.In(tq.From.Location()),
+			ImageID:   toInt64(row.VehicleImageID),
+		})
+	}
+	t := buildTimeline(events, tq.From, tq.To, tq.Gap, tq.Burst)
+	t.Camera = camera
+	t.Truncated = truncated
+	return t, nil
+}
+
+// timelineWindow is the URL of the timeline with another window, keeping
+// the other parameters
+func timelineWindow(r *http.Request, from, to time.Time) string {
+	query := r.URL.Query()
+	query.Set("from", from.Format(time.RFC3339))
+	query.Set("to", to.Format(time.RFC3339))
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// HandleTimeline shows a camera's timeline, or the cameras to pick from
+func (s *Server) HandleTimeline(w http.ResponseWriter, r *http.Request) {
+	cameras, err := dbgen.New(s.DB).GetTimelineCameras(r.Context())
+	if err != nil {
+		slog.Error("failed to list cameras", "error", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	data := struct {
+		Cameras                         []dbgen.GetTimelineCamerasRow
+		Camera                          string
+		Query                           url.Values
+		Timeline                        timeline
+		ZoomIn, ZoomOut, Earlier, Later string
+		Thumbs                          bool
+		APIURL                          string
+	}{Cameras: cameras, Camera: r.PathValue("camera"), Query: r.URL.Query()}
+	if data.Camera != "" {
+		tq, err := s.parseTimelineQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		t, err := s.cameraTimeline(r.Context(), data.Camera, tq)
+		if err != nil {
+			slog.Error("failed to build timeline", "camera", data.Camera, "error", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		span := t.To.Sub(t.From)
+		mid := t.From.Add(span / 2)
+		data.Timeline = t
+		data.ZoomIn = timelineWindow(r, mid.Add(-span/4), mid.Add(span/4))
+		data.ZoomOut = timelineWindow(r, mid.Add(-span), mid.Add(span))
+		data.Earlier = timelineWindow(r, t.From.Add(-span/2), t.To.Add(-span/2))
+		data.Later = timelineWindow(r, t.From.Add(span/2), t.To.Add(span/2))
+		data.Thumbs = len(t.Events) <= timelineThumbs
+		data.APIURL = "/api/cameras/" + url.PathEscape(data.Camera) + "/timeline?" + r.URL.RawQuery
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "timeline.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleTimelineAPI returns a camera's timeline as JSON
+func (s *Server) HandleTimelineAPI(w http.ResponseWriter, r *http.Request) {
+	tq, err := s.parseTimelineQuery(r.URL.Query())
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, err := s.cameraTimeline(r.Context(), r.PathValue("camera"), tq)
+	if err != nil {
+		slog.Error("failed to build timeline", "camera", r.PathValue("camera"), "error", err)
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
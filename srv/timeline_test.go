@@ -0,0 +1,79 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	from := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	at := func(sec float64, plate string) timelineEvent {
+		return timelineEvent{At: from.Add(time.Duration(sec * float64(time.Second))), Plate: plate}
+	}
+	events := []timelineEvent{
+		at(60, "AB123"), at(61, "AB-123"), at(61.5, ""), at(62, "ab 123"), // one car retriggering
+		at(70, "CD456"), at(71, "EF789"), at(72, "GH012"), // a queue
+		at(900, "IJ345"), // after a 13+ minute silence
+	}
+	tl := buildTimeline(events, from, from.Add(time.Hour), 5*time.Minute, 2*time.Second)
+	if len(tl.Gaps) != 1 || tl.Gaps[0].Seconds != 828 {
+		t.Errorf("gaps %+v", tl.Gaps)
+	}
+	if len(tl.Bursts) != 2 || tl.Bursts[0].Events != 4 || !tl.Bursts[0].Retrigger ||
+		tl.Bursts[1].Events != 3 || tl.Bursts[1].Plates != 3 || tl.Bursts[1].Retrigger {
+		t.Errorf("bursts %+v", tl.Bursts)
+	}
+	if tl.Events[7].Pos != 25 {
+		t.Errorf("position %v, want 25", tl.Events[7].Pos)
+	}
+	if len(tl.Ticks) != 6 || tl.Ticks[1].Label != "10:10" {
+		t.Errorf("ticks %+v", tl.Ticks)
+	}
+}
+
+func TestTimelineHandlers(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	for _, msg := range []string{
+		`{"plateUTF8":"AB123","sensorProviderID":"gate"}`,
+		`{"plateUTF8":"AB123","sensorProviderID":"gate"}`,
+		`{"plateUTF8":"AB123","sensorProviderID":"gate"}`,
+		`{"plateUTF8":"CD456","sensorProviderID":"exit"}`,
+	} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(msg), "", []uploadedImage{{Filename: "vehicle.png", Data: pngOf(300, 200)}})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.background.Wait()
+
+	get := func(handler http.HandlerFunc, path, camera string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("GET", path, nil)
+		r.SetPathValue("camera", camera)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+	w := get(s.HandleTimelineAPI, "/api/cameras/GATE/timeline?burst=1m", "GATE")
+	var tl timeline
+	json.NewDecoder(w.Body).Decode(&tl)
+	if w.Code != http.StatusOK || len(tl.Events) != 3 || tl.Events[0].ImageID == 0 || len(tl.Bursts) != 1 || !tl.Bursts[0].Retrigger {
+		t.Errorf("api %d %s", w.Code, w.Body)
+	}
+	if w := get(s.HandleTimelineAPI, "/api/cameras/gate/timeline?from=2026-01-02&to=2026-01-01", "gate"); w.Code != http.StatusBadRequest {
+		t.Errorf("reversed window: %d", w.Code)
+	}
+
+	w = get(s.HandleTimeline, "/cameras/gate/timeline", "gate")
+	if body := w.Body.String(); w.Code != http.StatusOK || strings.Count(body, `class="thumb"`) != 3 || !strings.Contains(body, "exit (1 events)") {
+		t.Errorf("page %d:\n%s", w.Code, body)
+	}
+	if w := get(s.HandleTimeline, "/timeline", ""); !strings.Contains(w.Body.String(), `href="/cameras/gate/timeline"`) {
+		t.Errorf("camera list:\n%s", w.Body)
+	}
+}
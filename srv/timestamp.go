@@ -0,0 +1,259 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Cameras send datetime and capture_timestamp in many formats: epoch
+// seconds/ms/µs/ns, ISO 8601 / RFC 3339 (as used by CEN/TS 16157), and
+// compact "YYYYMMDD HHMMSS" with optional milliseconds. The originals are
+// kept as received; the parsed time goes into events.captured_at, and
+// events.timestamp_error records why a value couldn't be used.
+
+var (
+	errTimestampFormat = errors.New("unrecognized format")
+	errTimestampRange  = errors.New("out of range")
+)
+
+// minCaptureTime and maxClockSkew bound plausible capture times, so a
+// camera with a reset clock (1970) or a wrong year is flagged
+var minCaptureTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const maxClockSkew = 24 * time.Hour
+
+// compactTimestampRe matches YYYYMMDD[ T_-]HHMMSS[fraction][zone], e.g.
+// "20260121 163817135" or "20260121T163817Z"
+var compactTimestampRe = regexp.MustCompile(`^(\d{8})[ T_-]?(\d{6})\.?(\d{0,9})(Z|[+-]\d{2}:?\d{2})?$`)
+
+var epochRe = regexp.MustCompile(`^\d{9,19}(\.\d+)?$`)
+
+// timestampLayouts are tried in order for everything that isn't compact or
+// an epoch; layouts without a zone are read in the camera time zone
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006/01/02 15:04:05.999999999",
+	"02.01.2006 15:04:05.999999999",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// parseTimestamp parses a camera timestamp; values without a zone are in
+// loc. now bounds how far in the future a value may be.
+func parseTimestamp(s string, loc *time.Location, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, errTimestampFormat
+	}
+	t, err := parseTimestampFormat(s, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if t.Before(minCaptureTime) || t.After(now.Add(maxClockSkew)) {
+		return time.Time{}, fmt.Errorf("%w: %s", errTimestampRange, t.UTC().Format(time.RFC3339))
+	}
+	return t, nil
+}
+
+func parseTimestampFormat(s string, loc *time.Location) (time.Time, error) {
+	if m := compactTimestampRe.FindStringSubmatch(s); m != nil {
+		layout := "20060102150405"
+		value := m[1] + m[2]
+		if m[3] != "" {
+			// ".000" style layouts need the exact number of digits
+			layout += "." + strings.Repeat("0", len(m[3]))
+			value += "." + m[3]
+		}
+		if m[4] != "" {
+			zone := strings.Replace(m[4], ":", "", 1)
+			if zone == "Z" {
+				zone = "+0000"
+			}
+			layout += "-0700"
+			value += zone
+		}
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	if epochRe.MatchString(s) {
+		return parseEpoch(s)
+	}
+	s = strings.Replace(s, ",", ".", 1) // ISO 8601 allows a decimal comma
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errTimestampFormat
+}
+
+// parseEpoch reads a Unix time, inferring the unit from the number of
+// integer digits: 10 seconds, 13 milliseconds, 16 microseconds, 19 nanoseconds
+func parseEpoch(s string) (time.Time, error) {
+	intPart, frac, _ := strings.Cut(s, ".")
+	var digits int // fraction digits down to nanoseconds
+	switch n := len(intPart); {
+	case n <= 11:
+		digits = 9
+	case n <= 14:
+		digits = 6
+	case n <= 17:
+		digits = 3
+	}
+	if len(frac) > digits {
+		frac = frac[:digits]
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+	ns, err := strconv.ParseInt(intPart+frac, 10, 64)
+	if err != nil {
+		return time.Time{}, errTimestampRange
+	}
+	return time.Unix(0, ns), nil
+}
+
+// captureTime picks the capture time of an event from capture_timestamp,
+// falling back to datetime. It returns nil when neither is set, and an
+// error description when none of the values could be parsed.
+func (s *Server) captureTime(datetime, captureTimestamp *string, now time.Time) (*time.Time, *string) {
+	loc := s.CameraTZ
+	if loc == nil {
+		loc = time.Local
+	}
+	var problems []string
+	for _, f := range []struct {
+		name  string
+		value *string
+	}{
+		{"capture_timestamp", captureTimestamp},
+		{"datetime", datetime},
+	} {
+		if f.value == nil || *f.value == "" {
+			continue
+		}
+		t, err := parseTimestamp(*f.value, loc, now)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %q: %v", f.name, *f.value, err))
+			continue
+		}
+		return ptr(t.In(time.Local)), nil
+	}
+	if len(problems) == 0 {
+		return nil, nil
+	}
+	return nil, ptr(strings.Join(problems, "; "))
+}
+
+// seenAt is when an event happened: its capture time if known, otherwise
+// when it was received
+func seenAt(capturedAt *time.Time, createdAt time.Time) time.Time {
+	if capturedAt != nil {
+		return *capturedAt
+	}
+	return createdAt
+}
+
+// arrivalDelay is how long after capture an event was received, in ms
+func arrivalDelay(capturedAt *time.Time, receivedAt time.Time) *int64 {
+	if capturedAt == nil {
+		return nil
+	}
+	return ptr(receivedAt.Sub(*capturedAt).Milliseconds())
+}
+
+// isLate reports whether an event arrived later after capture than the
+// late-arrival threshold, e.g. from a store-and-forward camera catching up
+func (s *Server) isLate(delayMs *int64) bool {
+	return s.LateAfter > 0 && delayMs != nil && *delayMs > s.LateAfter.Milliseconds()
+}
+
+// sortByReceived reorders events by receive time, newest first, for lists
+// that default to capture time order and are read in full (a limited list
+// has to be ordered in SQL instead)
+func sortByReceived[T any](rows []T, receivedAt func(T) time.Time) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return receivedAt(rows[i]).After(receivedAt(rows[j]))
+	})
+}
+
+// parseMissingCaptureTimes fills captured_at for events stored before
+// timestamps were parsed
+func (s *Server) parseMissingCaptureTimes(ctx context.Context) error {
+	q := dbgen.New(s.DB)
+	now := time.Now()
+	parsed, failed := 0, 0
+	for {
+		rows, err := q.GetEventsWithoutCaptureTime(ctx, 1000)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, row := range rows {
+			at, problem := s.captureTime(row.EventDatetime, row.CaptureTimestamp, now)
+			if problem != nil {
+				failed++
+			} else {
+				parsed++
+			}
+			if err := q.SetEventCaptureTime(ctx, dbgen.SetEventCaptureTimeParams{
+				CapturedAt:     at,
+				TimestampError: problem,
+				ArrivalDelayMs: arrivalDelay(at, row.CreatedAt),
+				ID:             row.ID,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	if parsed+failed > 0 {
+		slog.Info("parsed capture times of existing events", "parsed", parsed, "unparseable", failed)
+	}
+	return nil
+}
+
+// fillArrivalDelays records the arrival delay of events whose capture time
+// was parsed before delays were kept
+func (s *Server) fillArrivalDelays(ctx context.Context) error {
+	q := dbgen.New(s.DB)
+	filled := 0
+	for {
+		rows, err := q.GetEventsWithoutArrivalDelay(ctx, 1000)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, row := range rows {
+			if err := q.SetEventArrivalDelay(ctx, dbgen.SetEventArrivalDelayParams{
+				ArrivalDelayMs: arrivalDelay(row.CapturedAt, row.CreatedAt),
+				ID:             row.ID,
+			}); err != nil {
+				return err
+			}
+			filled++
+		}
+	}
+	if filled > 0 {
+		slog.Info("filled arrival delays of existing events", "count", filled)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	berlin := time.FixedZone("CET", 3600)
+	now := time.Date(2026, 1, 22, 0, 0, 0, 0, time.UTC)
+	want := time.Date(2026, 1, 21, 16, 38, 17, 135_000_000, time.UTC)
+
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"1769013497135", want},
+		{"1769013497", want.Truncate(time.Second)},
+		{"1769013497.135", want},
+		{"1769013497135000", want},
+		{"1769013497135000000", want},
+		{"2026-01-21T16:38:17.135Z", want},
+		{"2026-01-21T17:38:17.135+01:00", want},
+		{"2026-01-21T17:38:17,135+0100", want},
+		{"2026-01-21 17:38:17.135", want}, // no zone: camera zone
+		{"20260121 173817135", want},      // compact with milliseconds
+		{"20260121 173817", want.Truncate(time.Second)},
+		{"20260121173817.135", want},
+		{"20260121T163817Z", want.Truncate(time.Second)},
+		{"2026/01/21 17:38:17", want.Truncate(time.Second)},
+		{"21.01.2026 17:38:17.135", want},
+	}
+	for _, tt := range tests {
+		got, err := parseTimestamp(tt.in, berlin, now)
+		if err != nil {
+			t.Errorf("parseTimestamp(%q): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTimestamp(%q) = %v, want %v", tt.in, got.UTC(), tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "yesterday", "2026-13-01 00:00:00", "20261341 999999"} {
+		if _, err := parseTimestamp(in, berlin, now); !errors.Is(err, errTimestampFormat) {
+			t.Errorf("parseTimestamp(%q) error = %v, want unrecognized format", in, err)
+		}
+	}
+	// Reset camera clocks and far-future values are flagged
+	for _, in := range []string{"19700101 000012", "0", "2027-01-01T00:00:00Z"} {
+		if _, err := parseTimestamp(in, berlin, now); err == nil {
+			t.Errorf("parseTimestamp(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestCaptureTime(t *testing.T) {
+	s := &Server{CameraTZ: time.UTC}
+	now := time.Date(2026, 1, 22, 0, 0, 0, 0, time.UTC)
+
+	at, problem := s.captureTime(ptr("garbage"), ptr("20260121 163817"), now)
+	if at == nil || problem != nil || !at.Equal(time.Date(2026, 1, 21, 16, 38, 17, 0, time.UTC)) {
+		t.Errorf("capture_timestamp should win: %v %v", at, problem)
+	}
+	at, problem = s.captureTime(ptr("20260121 163817"), ptr("garbage"), now)
+	if at == nil || problem != nil {
+		t.Errorf("should fall back to datetime: %v %v", at, problem)
+	}
+	at, problem = s.captureTime(ptr("garbage"), nil, now)
+	if at != nil || problem == nil {
+		t.Errorf("unparseable value not flagged: %v %v", at, problem)
+	}
+	if at, problem = s.captureTime(nil, nil, now); at != nil || problem != nil {
+		t.Errorf("missing values: %v %v", at, problem)
+	}
+}
+
+func TestLateArrivals(t *testing.T) {
+	s := newTestServer(t)
+	s.CameraTZ = time.UTC
+	s.LateAfter = 2 * time.Minute
+	ctx := context.Background()
+	now := time.Now().UTC()
+	// onTime is captured last but received first; late was buffered by its
+	// camera for ten minutes
+	var ids []int64
+	for _, captured := range []time.Time{now.Add(-time.Minute), now.Add(-10 * time.Minute)} {
+		body := fmt.Sprintf(`{"plateUTF8":"AB123","capture_timestamp":%q}`, captured.Format(time.RFC3339))
+		res, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, res.ID)
+	}
+	s.background.Wait()
+	onTime, late := ids[0], ids[1]
+
+	delays := func() map[int64]*int64 {
+		t.Helper()
+		m := map[int64]*int64{}
+		for _, id := range ids {
+			ev, err := dbgen.New(s.DB).GetEventByID(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m[id] = ev.ArrivalDelayMs
+		}
+		return m
+	}
+	stored := delays()
+	if stored[onTime] == nil || stored[late] == nil {
+		t.Fatalf("no arrival delays: %v", stored)
+	}
+	if s.isLate(stored[onTime]) || !s.isLate(stored[late]) {
+		t.Errorf("delays %d, %d ms; only the second is late", *stored[onTime], *stored[late])
+	}
+	if s.LateAfter = 0; s.isLate(stored[late]) {
+		t.Error("flagged with the threshold off")
+	}
+
+	list := func(query string) []int64 {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.HandleEventsAPI(w, httptest.NewRequest("GET", "/api/events?"+query, nil))
+		var rows []dbgen.GetRecentEventsRow
+		if err := json.NewDecoder(w.Body).Decode(&rows); err != nil {
+			t.Fatal(err)
+		}
+		var got []int64
+		for _, row := range rows {
+			got = append(got, row.ID)
+		}
+		return got
+	}
+	for query, want := range map[string][]int64{
+		"":                       {onTime, late},
+		"limit=1":                {onTime},
+		"order=received":         {late, onTime},
+		"order=received&limit=1": {late},
+	} {
+		if got := list(query); !slices.Equal(got, want) {
+			t.Errorf("?%s: %v, want %v", query, got, want)
+		}
+	}
+
+	// Delays of events parsed before they were recorded are filled at startup
+	if _, err := s.DB.Exec("UPDATE events SET arrival_delay_ms = NULL"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.fillArrivalDelays(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for id, delay := range delays() {
+		if delay == nil || *delay != *stored[id] {
+			t.Errorf("event %d: delay %v, want %d", id, delay, *stored[id])
+		}
+	}
+}
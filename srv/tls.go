@@ -0,0 +1,155 @@
+package srv
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Many camera fleets may only push events over HTTPS. With a TLSConfig the
+// server listens with TLS itself instead of behind a reverse proxy, using
+// either certificate files or a Let's Encrypt certificate obtained with ACME
+// for the server's external host name (the -public-url host, else the
+// hostname). Certificate files are re-read when they change, so renewal by
+// an outside tool needs no restart. An optional plain HTTP listener answers
+// ACME http-01 challenges and redirects everything else to HTTPS.
+
+// TLSConfig selects how the server gets its certificate
+type TLSConfig struct {
+	CertFile string // PEM certificate chain
+	KeyFile  string // PEM private key
+	Autocert bool   // obtain certificates from Let's Encrypt instead
+	Email    string // optional ACME account contact
+	CacheDir string // where ACME keys and certificates are kept (default DataDir/autocert)
+	HTTPAddr string // optional plain HTTP listener for ACME challenges and redirects
+}
+
+// autocertHosts are the names a Let's Encrypt certificate is requested for
+func (s *Server) autocertHosts() ([]string, error) {
+	host := s.Hostname
+	if s.PublicURL != "" {
+		if u, err := url.Parse(s.PublicURL); err == nil {
+			host = u.Hostname()
+		}
+	}
+	if !strings.Contains(host, ".") || net.ParseIP(host) != nil {
+		return nil, fmt.Errorf("autocert needs a public DNS name, not %q; set -public-url to the external URL", host)
+	}
+	return []string{host}, nil
+}
+
+// tlsSetup returns the TLS configuration for the HTTPS listener and the
+// handler for the plain HTTP listener
+func (s *Server) tlsSetup(httpsAddr string) (*tls.Config, http.Handler, error) {
+	redirect := httpsRedirect(httpsAddr)
+	if !s.TLS.Autocert {
+		certs, err := newCertReloader(s.TLS.CertFile, s.TLS.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: certs.getCertificate}, redirect, nil
+	}
+
+	hosts, err := s.autocertHosts()
+	if err != nil {
+		return nil, nil, err
+	}
+	dir := s.TLS.CacheDir
+	if dir == "" {
+		dir = filepath.Join(s.DataDir, "autocert")
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(hosts...),
+		Cache:      autocert.DirCache(dir),
+		Email:      s.TLS.Email,
+	}
+	if s.TLS.HTTPAddr == "" {
+		// Without port 80 only the TLS-ALPN challenge on the HTTPS port works
+		_, port, _ := net.SplitHostPort(httpsAddr)
+		if port != "443" {
+			return nil, nil, fmt.Errorf("autocert needs the HTTPS listener on port 443 or a plain HTTP listener on port 80 for ACME challenges")
+		}
+	}
+	cfg := m.TLSConfig()
+	cfg.MinVersion = tls.VersionTLS12
+	return cfg, m.HTTPHandler(redirect), nil
+}
+
+// httpsRedirect sends plain HTTP requests to the HTTPS listener. 308 keeps
+// the method and body, so cameras that follow redirects still deliver.
+func httpsRedirect(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// certReloader serves a certificate from files, re-reading them when they
+// change
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// certCheckInterval limits how often the files are checked for changes
+const certCheckInterval = 10 * time.Second
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) load() error {
+	info, err := os.Stat(c.certFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	c.cert, c.modTime = &cert, info.ModTime()
+	return nil
+}
+
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checked) >= certCheckInterval {
+		c.checked = time.Now()
+		if info, err := os.Stat(c.certFile); err == nil && !info.ModTime().Equal(c.modTime) {
+			// Keep the old certificate if the new files are half written
+			if err := c.load(); err != nil {
+				slog.Warn("reload TLS certificate; keeping the previous one", "cert", c.certFile, "error", err)
+			} else {
+				slog.Info("reloaded TLS certificate", "cert", c.certFile)
+			}
+		}
+	}
+	return c.cert, nil
+}
@@ -0,0 +1,156 @@
+package srv
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for localhost
+func writeTestCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(key)
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestServeTLS(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.DataDir
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, 1)
+	httpsAddr, httpAddr := freeAddr(t), freeAddr(t)
+	s.HTTP = DefaultHTTPConfig()
+	s.TLS = &TLSConfig{CertFile: certFile, KeyFile: keyFile, HTTPAddr: httpAddr}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, httpsAddr) }()
+	defer func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("Serve = %v", err)
+		}
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	var (
+		resp *http.Response
+		err  error
+	)
+	for range 50 {
+		if resp, err = client.Get("https://" + httpsAddr + "/api/version"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS.PeerCertificates[0].SerialNumber.Int64() != 1 {
+		t.Fatalf("https: status %d", resp.StatusCode)
+	}
+
+	// Plain HTTP is redirected, keeping the method
+	resp, err = client.Post("http://"+httpAddr+"/api?x=1", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, port, _ := net.SplitHostPort(httpsAddr); resp.StatusCode != http.StatusPermanentRedirect ||
+		resp.Header.Get("Location") != "https://127.0.0.1:"+port+"/api?x=1" {
+		t.Errorf("redirect: %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+func TestCertReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, 1)
+	c, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := func() int64 {
+		cert, _ := c.getCertificate(nil)
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf.SerialNumber.Int64()
+	}
+	if serial() != 1 {
+		t.Fatal("wrong initial certificate")
+	}
+
+	// A renewed certificate is picked up; a broken one is ignored
+	writeTestCert(t, certFile, keyFile, 2)
+	os.Chtimes(certFile, time.Now(), time.Now().Add(time.Minute))
+	c.checked = time.Time{}
+	if serial() != 2 {
+		t.Error("renewed certificate not loaded")
+	}
+	os.WriteFile(keyFile, []byte("garbage"), 0600)
+	os.Chtimes(certFile, time.Now(), time.Now().Add(2*time.Minute))
+	c.checked = time.Time{}
+	if serial() != 2 {
+		t.Error("broken certificate replaced the working one")
+	}
+}
+
+func TestAutocertHosts(t *testing.T) {
+	for _, tc := range []struct {
+		hostname, publicURL, want string
+	}{
+		{"lpr.example.com", "", "lpr.example.com"},
+		{"box", "https://cams.example.org:8443/x", "cams.example.org"},
+		{"box", "", ""},
+		{"box", "https://10.0.0.5", ""},
+	} {
+		s := &Server{Hostname: tc.hostname, PublicURL: tc.publicURL}
+		hosts, err := s.autocertHosts()
+		switch {
+		case tc.want == "" && err == nil:
+			t.Errorf("%s %s: accepted %v", tc.hostname, tc.publicURL, hosts)
+		case tc.want != "" && (err != nil || hosts[0] != tc.want):
+			t.Errorf("%s %s: hosts %v, err %v; want %s", tc.hostname, tc.publicURL, hosts, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"log/slog"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// ULIDs (https://github.com/ulid/spec) identify events across instances.
+// They sort by creation time, so merged datasets keep their order, and
+// unlike rowids they survive an import unchanged.
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var ulidState struct {
+	sync.Mutex
+	lastMS  uint64
+	lastHi  uint16 // top 16 bits of the 80-bit random part
+	lastLo  uint64 // bottom 64 bits
+	entropy [10]byte
+}
+
+// newULID returns a ULID for t. IDs generated within the same millisecond
+// increase monotonically.
+func newULID(t time.Time) string {
+	ms := uint64(t.UnixMilli())
+
+	ulidState.Lock()
+	if ms == ulidState.lastMS {
+		ulidState.lastLo++
+		if ulidState.lastLo == 0 {
+			ulidState.lastHi++
+		}
+	} else {
+		rand.Read(ulidState.entropy[:])
+		ulidState.lastMS = ms
+		ulidState.lastHi = binary.BigEndian.Uint16(ulidState.entropy[:2])
+		ulidState.lastLo = binary.BigEndian.Uint64(ulidState.entropy[2:])
+	}
+	var b [16]byte
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	binary.BigEndian.PutUint16(b[6:], ulidState.lastHi)
+	binary.BigEndian.PutUint64(b[8:], ulidState.lastLo)
+	ulidState.Unlock()
+
+	return encodeULID(b)
+}
+
+// encodeULID writes 128 bits as 26 Crockford base32 characters
+func encodeULID(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// isULID reports whether s looks like a ULID rather than a numeric event ID
+func isULID(s string) bool {
+	if len(s) != 26 || s[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z' && c != 'I' && c != 'L' && c != 'O' && c != 'U') {
+			return false
+		}
+	}
+	return true
+}
+
+// assignMissingUIDs gives events recorded before ULIDs existed a uid based
+// on their received time
+func (s *Server) assignMissingUIDs(ctx context.Context) error {
+	q := dbgen.New(s.DB)
+	total := 0
+	for {
+		rows, err := q.GetEventsWithoutUID(ctx, 1000)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, row := range rows {
+			if err := q.SetEventUID(ctx, dbgen.SetEventUIDParams{Uid: ptr(newULID(row.CreatedAt)), ID: row.ID}); err != nil {
+				return err
+			}
+		}
+		total += len(rows)
+	}
+	if total > 0 {
+		slog.Info("assigned uids to existing events", "count", total)
+	}
+	return nil
+}
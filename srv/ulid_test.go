@@ -0,0 +1,36 @@
+package srv
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewULID(t *testing.T) {
+	t0 := time.UnixMilli(1_700_000_000_000)
+	a := newULID(t0)
+	b := newULID(t0)
+	c := newULID(t0.Add(time.Millisecond))
+
+	for _, id := range []string{a, b, c} {
+		if !isULID(id) {
+			t.Errorf("%q is not a valid ULID", id)
+		}
+	}
+	if !(a < b && b < c) {
+		t.Errorf("ULIDs not monotonic: %s %s %s", a, b, c)
+	}
+	if a[:10] != b[:10] {
+		t.Errorf("same millisecond should share the time prefix: %s %s", a, b)
+	}
+
+	// Known timestamp encoding from the spec's reference implementation
+	if got := encodeULID([16]byte{0x01, 0x8b, 0xcf, 0xe5, 0x68, 0x00})[:10]; got != "01HF7YAT00" {
+		t.Errorf("time prefix = %s, want 01HF7YAT00", got)
+	}
+
+	for _, s := range []string{"123", "01HF7YAT00XXXXXXXXXXXXXXXI", "81HF7YAT000000000000000000"} {
+		if isULID(s) {
+			t.Errorf("isULID(%q) = true", s)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package srv
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// HandleUnrecognized lists image-only events that came in without a recognition result
+func (s *Server) HandleUnrecognized(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	events, err := q.GetUnrecognizedEvents(r.Context(), 1000)
+	if err != nil {
+		slog.Warn("failed to load unrecognized events", "error", err)
+	}
+	pending, _ := q.CountUnrecognizedEvents(r.Context())
+
+	data := struct {
+		Hostname   string
+		Pending    int64
+		Events     []dbgen.GetUnrecognizedEventsRow
+		OCREnabled bool
+	}{
+		Hostname:   s.Hostname,
+		Pending:    pending,
+		Events:     events,
+		OCREnabled: s.OCR != nil,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "unrecognized.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// HandleSetManualPlate stores a reviewer-entered plate for an unrecognized event.
+// An empty plate clears the manual entry.
+func (s *Server) HandleSetManualPlate(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid event id", http.StatusBadRequest)
+		return
+	}
+
+	plate := strings.ToUpper(strings.TrimSpace(r.FormValue("plate")))
+
+	q := dbgen.New(s.DB)
+	event, err := q.GetEventByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "event not found", http.StatusNotFound)
+		return
+	}
+	if locked, err := s.eventLocked(r.Context(), event.ArchiveID); err != nil || locked {
+		http.Error(w, "event is in a locked archive", http.StatusConflict)
+		return
+	}
+	if err := q.SetManualPlate(r.Context(), dbgen.SetManualPlateParams{
+		ManualPlate: ptrIfNotEmpty(plate),
+		ID:          id,
+	}); err != nil {
+		slog.Error("failed to set manual plate", "error", err)
+		http.Error(w, "failed to set manual plate", http.StatusInternalServerError)
+		return
+	}
+	s.invalidateCompareStats(r.Context(), q, event.ArchiveID)
+
+	slog.Info("manual plate set", "id", id, "plate", plate)
+
+	referer := r.Header.Get("Referer")
+	if referer == "" {
+		referer = "/unrecognized"
+	}
+	http.Redirect(w, r, referer, http.StatusSeeOther)
+}
@@ -0,0 +1,79 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestImageOnlyEvent(t *testing.T) {
+	s := newTestServer(t)
+
+	// A trigger upload with an image and no recognition result
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("image", "trigger.png")
+	fw.Write(pngOf(300, 200))
+	mw.Close()
+	r := httptest.NewRequest("POST", "/api", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.HandleAPI(w, r)
+	var res struct {
+		ID           int64 `json:"id"`
+		Images       int   `json:"images"`
+		Unrecognized bool  `json:"unrecognized"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if w.Code != http.StatusOK || res.ID != 1 || res.Images != 1 || !res.Unrecognized {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	s.background.Wait()
+
+	list := func() string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.HandleUnrecognized(w, httptest.NewRequest("GET", "/unrecognized", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("list: %d %s", w.Code, w.Body)
+		}
+		return w.Body.String()
+	}
+	if page := list(); !strings.Contains(page, "<span>1</span> awaiting plate entry") || !strings.Contains(page, `id="plate-1"`) {
+		t.Errorf("list: %s", page)
+	}
+
+	setPlate := func(id, plate string) int {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/event/"+id+"/plate", strings.NewReader(url.Values{"plate": {plate}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		s.HandleSetManualPlate(w, r)
+		return w.Code
+	}
+	if code := setPlate("1", " ab 123 "); code != http.StatusSeeOther {
+		t.Fatalf("set plate: %d", code)
+	}
+	var manual string
+	s.DB.QueryRow("SELECT manual_plate FROM events WHERE id = 1").Scan(&manual)
+	if manual != "AB 123" {
+		t.Errorf("manual plate %q", manual)
+	}
+	// Entered events stay listed but no longer count as pending
+	if page := list(); !strings.Contains(page, "<span>0</span> awaiting plate entry") || !strings.Contains(page, `value="AB 123"`) {
+		t.Errorf("list after entry: %s", page)
+	}
+
+	if code := setPlate("x", "AB1"); code != http.StatusBadRequest {
+		t.Errorf("invalid id: %d", code)
+	}
+	if code := setPlate("9", "AB1"); code != http.StatusNotFound {
+		t.Errorf("unknown event: %d", code)
+	}
+}
@@ -0,0 +1,202 @@
+package srv
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Camera bursts arrive as many concurrent uploads, and edge boxes have 1GB
+// of RAM. While an upload arrives only the first uploadHead bytes of each
+// part are kept in memory; the rest is streamed to DataDir/spool (not /tmp,
+// which is often RAM-backed). Once the whole request is received it waits
+// for its share of the UploadMemory budget before its parts are loaded for
+// ingest, so concurrent requests never hold more than the budget between
+// them. A request larger than the whole budget waits until it runs alone.
+
+// DefaultUploadMemory is the upload data held in memory across concurrent
+// ingest requests
+const DefaultUploadMemory = 64 << 20
+
+// uploadHead is how much of each part is read into memory while receiving,
+// enough to tell JSON and images apart
+const uploadHead = 64 << 10
+
+// uploadBudget counts the upload bytes loaded by requests in flight
+type uploadBudget struct {
+	mu   sync.Mutex
+	used int64
+	wake chan struct{} // closed when bytes are released
+}
+
+// acquire waits until n bytes fit into capacity
+func (b *uploadBudget) acquire(ctx context.Context, n, capacity int64) error {
+	n = min(n, capacity)
+	for {
+		b.mu.Lock()
+		if b.used+n <= capacity {
+			b.used += n
+			b.mu.Unlock()
+			return nil
+		}
+		if b.wake == nil {
+			b.wake = make(chan struct{})
+		}
+		wake := b.wake
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+// release returns n bytes and wakes the waiting requests
+func (b *uploadBudget) release(n int64) {
+	if n == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+	if b.wake != nil {
+		close(b.wake)
+		b.wake = nil
+	}
+}
+
+// uploadReservation is the budget held by one ingest request
+type uploadReservation struct {
+	bytes int64
+}
+
+type uploadReservationKey struct{}
+
+// limitUploads releases the upload memory a request acquired once it is
+// answered
+func (s *Server) limitUploads(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res := &uploadReservation{}
+		h(w, r.WithContext(context.WithValue(r.Context(), uploadReservationKey{}, res)))
+		s.uploads.release(res.bytes)
+	}
+}
+
+func (s *Server) uploadMemory() int64 {
+	if s.UploadMemory > 0 {
+		return s.UploadMemory
+	}
+	return DefaultUploadMemory
+}
+
+func (s *Server) spoolDir() string {
+	return filepath.Join(s.DataDir, "spool")
+}
+
+// clearSpool removes spool files left behind by a crash
+func (s *Server) clearSpool() {
+	if err := os.RemoveAll(s.spoolDir()); err != nil {
+		slog.Warn("failed to clear upload spool", "error", err)
+	}
+}
+
+// spooledPart is an upload part: its head in memory, and the whole part
+// in a spool file if it is larger
+type spooledPart struct {
+	head []byte
+	path string
+	size int64
+}
+
+// spoolPart reads a part, streaming everything past the head to disk
+func (s *Server) spoolPart(r io.Reader) (*spooledPart, error) {
+	head, err := io.ReadAll(io.LimitReader(r, uploadHead))
+	if err != nil {
+		return nil, err
+	}
+	p := &spooledPart{head: head, size: int64(len(head))}
+	if len(head) < uploadHead {
+		return p, nil
+	}
+	if err := os.MkdirAll(s.spoolDir(), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(s.spoolDir(), "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Write(head)
+	var n int64
+	if err == nil {
+		n, err = io.Copy(f, r)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || n == 0 {
+		os.Remove(f.Name())
+		return p, err
+	}
+	p.path, p.size = f.Name(), p.size+n
+	return p, nil
+}
+
+// discard removes the spool file of a part that isn't needed
+func (p *spooledPart) discard() {
+	if p.path != "" {
+		os.Remove(p.path)
+		p.path = ""
+	}
+}
+
+// loadParts waits for the request's share of the upload memory budget,
+// then reads the spooled parts and removes their files
+func (s *Server) loadParts(ctx context.Context, parts []*spooledPart) ([][]byte, error) {
+	defer func() {
+		for _, p := range parts {
+			p.discard()
+		}
+	}()
+	var spooled int64
+	for _, p := range parts {
+		if p.path != "" {
+			spooled += p.size
+		}
+	}
+	if res, ok := ctx.Value(uploadReservationKey{}).(*uploadReservation); ok && spooled > 0 {
+		if err := s.uploads.acquire(ctx, spooled, s.uploadMemory()); err != nil {
+			return nil, err
+		}
+		res.bytes += min(spooled, s.uploadMemory())
+	}
+	data := make([][]byte, len(parts))
+	for i, p := range parts {
+		if p.path == "" {
+			data[i] = p.head
+			continue
+		}
+		var err error
+		if data[i], err = os.ReadFile(p.path); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// readBody reads a non-multipart upload body within the memory budget
+func (s *Server) readBody(r *http.Request) ([]byte, error) {
+	sp, err := s.spoolPart(r.Body)
+	if err != nil {
+		return nil, &payloadError{"failed to read body: " + err.Error()}
+	}
+	data, err := s.loadParts(r.Context(), []*spooledPart{sp})
+	if err != nil {
+		return nil, err
+	}
+	return data[0], nil
+}
@@ -0,0 +1,91 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSpooledUpload(t *testing.T) {
+	s := newTestServer(t)
+	s.UploadMemory = 100 << 10
+	mux := http.NewServeMux()
+	s.ingestRoute(mux, "POST /api", s.HandleAPI)
+
+	// A noisy PNG larger than the in-memory head and the whole budget
+	img := image.NewGray(image.Rect(0, 0, 600, 600))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	var pic bytes.Buffer
+	png.Encode(&pic, img)
+	if pic.Len() <= 100<<10 {
+		t.Fatalf("image is only %d bytes", pic.Len())
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormField("event")
+	fw.Write([]byte(`{"plateUTF8":"SPOOL1"}`))
+	fw, _ = mw.CreateFormFile("plate", "plate.png")
+	fw.Write(pic.Bytes())
+	fw, _ = mw.CreateFormFile("notes", "notes.txt")
+	fw.Write(bytes.Repeat([]byte("x"), 200<<10))
+	mw.Close()
+	r := httptest.NewRequest("POST", "/api", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var plate string
+	var size int64
+	s.DB.QueryRow("SELECT e.plate_utf8, i.size_bytes FROM events e JOIN images i ON i.event_id = e.id").Scan(&plate, &size)
+	if plate != "SPOOL1" || size != int64(pic.Len()) {
+		t.Errorf("stored %s with a %d byte image, want SPOOL1 and %d", plate, size, pic.Len())
+	}
+	if files, _ := os.ReadDir(s.spoolDir()); len(files) != 0 {
+		t.Errorf("%d spool files left", len(files))
+	}
+	if s.uploads.used != 0 {
+		t.Errorf("%d upload bytes still held", s.uploads.used)
+	}
+}
+
+func TestUploadBudget(t *testing.T) {
+	var b uploadBudget
+	ctx := context.Background()
+	if err := b.acquire(ctx, 80, 100); err != nil {
+		t.Fatal(err)
+	}
+	// Beyond the budget, requests wait for bytes to be released
+	acquired := make(chan error)
+	go func() { acquired <- b.acquire(ctx, 500, 100) }()
+	select {
+	case <-acquired:
+		t.Fatal("acquired beyond the budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.release(80)
+	if err := <-acquired; err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := b.acquire(canceled, 1, 100); err == nil {
+		t.Error("acquired while the budget was held")
+	}
+	b.release(100)
+	if b.used != 0 {
+		t.Errorf("%d bytes still held", b.used)
+	}
+}
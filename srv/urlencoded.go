@@ -0,0 +1,94 @@
+package srv
+
+import (
+	"encoding/json"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Some older devices can only post flat application/x-www-form-urlencoded
+// key/value pairs. Such a body is turned into event JSON: a form field named
+// in JSONFields that holds a JSON object is used as is; otherwise every key
+// becomes a JSON key, with the camera keys and column names in ingestFields
+// (matched ignoring case, e.g. plateutf8 or plate_utf8) mapped to their
+// canonical spelling and dotted keys nested (vehicle_info.make). Fields
+// without images are stored like any other event JSON; images need
+// multipart or ImageArray.
+
+// formKey is the JSON key and type a form key is read as
+type formKey struct {
+	key, typ string
+}
+
+// formKeys maps lower-cased form keys to their JSON key
+var formKeys = func() map[string]formKey {
+	keys := map[string]formKey{}
+	for _, f := range ingestFields {
+		keys[strings.ToLower(f.Column)] = formKey{f.Keys[0], f.Type}
+		for _, k := range f.Keys {
+			if _, ok := keys[strings.ToLower(k)]; !ok {
+				keys[strings.ToLower(k)] = formKey{k, f.Type}
+			}
+		}
+	}
+	return keys
+}()
+
+// formJSON converts a URL-encoded body into event JSON
+func (s *Server) formJSON(body []byte) ([]byte, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, &payloadError{"failed to parse form: " + err.Error()}
+	}
+	for _, field := range s.jsonFields() {
+		for key, v := range values {
+			if strings.EqualFold(key, field) && strings.HasPrefix(strings.TrimSpace(v[0]), "{") {
+				return []byte(v[0]), nil
+			}
+		}
+	}
+
+	event := map[string]any{}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		v := values[key]
+		if key == "" {
+			continue
+		}
+		var value any = v[0]
+		if f, ok := formKeys[strings.ToLower(key)]; ok {
+			key = f.key
+			if f.typ == "number" {
+				if n, err := strconv.ParseFloat(v[0], 64); err == nil {
+					value = n
+				}
+			}
+		}
+		setEventKey(event, key, value)
+	}
+	if len(event) == 0 {
+		return nil, &payloadError{"empty form"}
+	}
+	return json.Marshal(event)
+}
+
+// setEventKey sets a key of event JSON, nesting dotted keys; a plain key
+// already holding a value wins
+func setEventKey(event map[string]any, key string, value any) {
+	obj := event
+	parts := strings.Split(key, ".")
+	for _, p := range parts[:len(parts)-1] {
+		child, ok := obj[p].(map[string]any)
+		if !ok {
+			if _, taken := obj[p]; taken {
+				return
+			}
+			child = map[string]any{}
+			obj[p] = child
+		}
+		obj = child
+	}
+	obj[parts[len(parts)-1]] = value
+}
@@ -0,0 +1,65 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestFormJSON(t *testing.T) {
+	s := &Server{}
+	for _, tt := range []struct {
+		form string
+		want string
+	}{
+		{"plateutf8=AB123&CARID=7&geotag.lat=52.5&geotag_lon=13.4&vehicle_info.make=VW&lane=2",
+			`{"carID":"7","geotag":{"lat":52.5,"lon":13.4},"lane":"2","plateUTF8":"AB123","vehicle_info":{"make":"VW"}}`},
+		{"plate_utf8=CD%20456&camera_serial=CAM1&geotag.lat=north",
+			`{"camera_info":{"SerialNumber":"CAM1"},"geotag":{"lat":"north"},"plateUTF8":"CD 456"}`},
+		{"data=" + url.QueryEscape(`{"plateUTF8":"EF789"}`) + "&carID=1",
+			`{"plateUTF8":"EF789"}`},
+	} {
+		got, err := s.formJSON([]byte(tt.form))
+		if err != nil {
+			t.Errorf("%s: %v", tt.form, err)
+			continue
+		}
+		var a, b any
+		json.Unmarshal(got, &a)
+		json.Unmarshal([]byte(tt.want), &b)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.form, got, tt.want)
+		}
+	}
+	if _, err := s.formJSON([]byte("")); err == nil {
+		t.Error("empty form accepted")
+	}
+}
+
+func TestIngestURLEncoded(t *testing.T) {
+	s := newTestServer(t)
+
+	form := url.Values{"carID": {"9"}, "plateUTF8": {"XY987"}, "plateCountry": {"DE"}, "vehicle_info.make": {"Skoda"}}
+	r := httptest.NewRequest("POST", "/api", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	w := httptest.NewRecorder()
+	s.HandleAPI(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /api = %d %s", w.Code, w.Body)
+	}
+	events, _ := dbgen.New(s.DB).GetRecentEvents(context.Background(), dbgen.GetRecentEventsParams{Limit: 10})
+	if len(events) != 1 {
+		t.Fatalf("events = %d, want 1", len(events))
+	}
+	ev := events[0]
+	if deref(ev.PlateUtf8) != "XY987" || deref(ev.PlateCountry) != "DE" || deref(ev.VehicleMake) != "Skoda" {
+		t.Errorf("stored plate %q country %q make %q", deref(ev.PlateUtf8), deref(ev.PlateCountry), deref(ev.VehicleMake))
+	}
+}
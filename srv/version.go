@@ -0,0 +1,274 @@
+package srv
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Build information, set at link time:
+//
+//	go build -ldflags "-X srv.exe.dev/srv.Version=v1.2.3 -X srv.exe.dev/srv.Commit=abc123 -X srv.exe.dev/srv.BuildDate=2026-01-02"
+//
+// Commit and BuildDate fall back to the VCS stamp Go embeds in the binary.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if Commit == "" {
+				Commit = setting.Value
+			}
+		case "vcs.time":
+			if BuildDate == "" {
+				BuildDate = setting.Value
+			}
+		}
+	}
+}
+
+// ReleaseInfo is what the release server answers for the latest build
+type ReleaseInfo struct {
+	Version string `json:"version"`
+	URL     string `json:"url,omitempty"`
+}
+
+// UpdateChecker asks the release server for the latest version. Results are
+// cached so polling /api/version across a fleet doesn't hammer it; failures
+// aren't, so the next poll tries again.
+type UpdateChecker struct {
+	Endpoint string
+	Client   *http.Client
+	TTL      time.Duration
+
+	mu        sync.Mutex
+	latest    *ReleaseInfo
+	checkedAt time.Time
+	running   *updateCheck // fetch in progress, shared by concurrent callers
+}
+
+// updateCheckTimeout bounds a fetch, which doesn't end with the request
+// that started it
+const updateCheckTimeout = 10 * time.Second
+
+// updateCheck is one fetch from the release server; done is closed when the
+// other fields are set
+type updateCheck struct {
+	done      chan struct{}
+	info      *ReleaseInfo
+	checkedAt time.Time
+	err       error
+}
+
+func NewUpdateChecker(endpoint string) *UpdateChecker {
+	return &UpdateChecker{
+		Endpoint: endpoint,
+		Client:   &http.Client{Timeout: updateCheckTimeout},
+		TTL:      time.Hour,
+	}
+}
+
+// Check returns the cached release info, refreshing it once the TTL has
+// passed. The fetch runs detached from ctx, so a caller giving up doesn't
+// fail it for the others; ctx only bounds the wait.
+func (u *UpdateChecker) Check(ctx context.Context) (*ReleaseInfo, time.Time, error) {
+	u.mu.Lock()
+	if u.latest != nil && time.Since(u.checkedAt) < u.TTL {
+		defer u.mu.Unlock()
+		return u.latest, u.checkedAt, nil
+	}
+	check := u.running
+	if check == nil {
+		check = &updateCheck{done: make(chan struct{})}
+		u.running = check
+		go u.refresh(check)
+	}
+	u.mu.Unlock()
+
+	select {
+	case <-check.done:
+		return check.info, check.checkedAt, check.err
+	case <-ctx.Done():
+		return nil, time.Time{}, ctx.Err()
+	}
+}
+
+func (u *UpdateChecker) refresh(check *updateCheck) {
+	ctx, cancel := context.WithTimeout(context.Background(), updateCheckTimeout)
+	defer cancel()
+	info, err := u.fetch(ctx)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	check.info, check.checkedAt, check.err = info, time.Now(), err
+	if err != nil {
+		slog.Warn("update check failed", "endpoint", u.Endpoint, "error", err)
+	} else {
+		u.latest, u.checkedAt = info, check.checkedAt
+	}
+	u.running = nil
+	close(check.done)
+}
+
+func (u *UpdateChecker) fetch(ctx context.Context) (*ReleaseInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.Endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "mmrapi/"+Version)
+
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release server returned %s", resp.Status)
+	}
+
+	var info ReleaseInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("decode release info: %w", err)
+	}
+	if info.Version == "" {
+		return nil, fmt.Errorf("release server returned no version")
+	}
+	return &info, nil
+}
+
+// HandleVersion reports the running build and, when an update server is
+// configured, whether a newer release is available
+func (s *Server) HandleVersion(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]any{
+		"version":    Version,
+		"commit":     Commit,
+		"build_date": BuildDate,
+		"go_version": runtime.Version(),
+		"hostname":   s.Hostname,
+		"node_id":    s.NodeID,
+	}
+
+	if s.Updates != nil {
+		update := map[string]any{}
+		current, release := parseSemver(Version)
+		if !release {
+			// Development and untagged builds have nothing to compare
+			update["available"] = false
+			update["skipped"] = "not a release build"
+		} else if latest, checkedAt, err := s.Updates.Check(r.Context()); err != nil {
+			update["checked_at"] = checkedAt
+			update["error"] = err.Error()
+		} else {
+			update["checked_at"] = checkedAt
+			update["latest"] = latest.Version
+			update["url"] = latest.URL
+			if v, ok := parseSemver(latest.Version); ok {
+				update["available"] = v.compare(current) > 0
+			} else {
+				update["error"] = fmt.Sprintf("release server returned version %q, not vMAJOR.MINOR.PATCH", latest.Version)
+			}
+		}
+		resp["update"] = update
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// semver is a parsed vMAJOR.MINOR.PATCH[-PRERELEASE][+BUILD] version
+type semver struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// parseSemver parses a semantic version with optional leading "v"; ok is
+// false for anything else, such as "dev" or a bare commit hash
+func parseSemver(v string) (semver, bool) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	var sv semver
+	core := v
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		core = v[:i]
+		sv.pre = strings.Split(v[i+1:], ".")
+		for _, id := range sv.pre {
+			if id == "" {
+				return semver{}, false
+			}
+		}
+	}
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	nums := []*int{&sv.major, &sv.minor, &sv.patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || (len(p) > 1 && p[0] == '0') {
+			return semver{}, false
+		}
+		*nums[i] = n
+	}
+	return sv, true
+}
+
+// compare returns -1, 0 or +1 as v is older than, the same as or newer than
+// o. A pre-release is older than its release; build metadata is ignored.
+func (v semver) compare(o semver) int {
+	if c := cmp.Compare(v.major, o.major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.minor, o.minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.patch, o.patch); c != 0 {
+		return c
+	}
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		a, aErr := strconv.Atoi(v.pre[i])
+		b, bErr := strconv.Atoi(o.pre[i])
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = cmp.Compare(a, b)
+		case aErr == nil:
+			c = -1 // numeric identifiers sort before alphanumeric ones
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(v.pre[i], o.pre[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(v.pre), len(o.pre))
+}
@@ -0,0 +1,121 @@
+package srv
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemverCompare(t *testing.T) {
+	ordered := []string{"v0.9.9", "v1.2.0-alpha", "v1.2.0-alpha.1", "v1.2.0-alpha.beta", "v1.2.0-beta.2", "v1.2.0-beta.11", "v1.2.0-rc.1", "v1.2.0", "1.2.1", "v1.10.0", "v2.0.0"}
+	for i := range ordered {
+		for j := range ordered {
+			a, okA := parseSemver(ordered[i])
+			b, okB := parseSemver(ordered[j])
+			if !okA || !okB {
+				t.Fatalf("parse %q, %q", ordered[i], ordered[j])
+			}
+			if got, want := a.compare(b), cmp.Compare(i, j); got != want {
+				t.Errorf("compare(%s, %s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+	if a, _ := parseSemver("v1.2.3+build.7"); a.compare(semver{major: 1, minor: 2, patch: 3}) != 0 {
+		t.Error("build metadata not ignored")
+	}
+	for _, v := range []string{"dev", "", "abc1234", "v1.2", "v1.2.3.4", "v1.02.3", "v1.2.x", "v1.2.3-"} {
+		if _, ok := parseSemver(v); ok {
+			t.Errorf("%q parsed as a release", v)
+		}
+	}
+}
+
+func TestUpdateChecker(t *testing.T) {
+	var hits atomic.Int32
+	status := http.StatusInternalServerError
+	release := make(chan struct{})
+	close(release)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.WriteHeader(status)
+		w.Write([]byte(`{"version":"v1.3.0","url":"https://example.com/v1.3.0"}`))
+	}))
+	defer srv.Close()
+	u := NewUpdateChecker(srv.URL)
+	ctx := context.Background()
+
+	// Failures aren't cached
+	if _, _, err := u.Check(ctx); err == nil {
+		t.Fatal("no error from a failing release server")
+	}
+	status = http.StatusOK
+	info, checkedAt, err := u.Check(ctx)
+	if err != nil || info.Version != "v1.3.0" || hits.Load() != 2 {
+		t.Fatalf("after failure: %+v, %v, %d requests", info, err, hits.Load())
+	}
+	if again, at, _ := u.Check(ctx); again != info || !at.Equal(checkedAt) || hits.Load() != 2 {
+		t.Errorf("result not cached: %d requests", hits.Load())
+	}
+
+	// A caller giving up neither holds up nor cancels the fetch; the next
+	// caller gets its result
+	u = NewUpdateChecker(srv.URL)
+	release = make(chan struct{})
+	hits.Store(0)
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := u.Check(short); err != context.DeadlineExceeded {
+		t.Fatalf("gave up with %v", err)
+	}
+	start := time.Now()
+	short2, cancel2 := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel2()
+	if _, _, err := u.Check(short2); err != context.DeadlineExceeded || time.Since(start) > time.Second {
+		t.Fatalf("second caller: %v after %v", err, time.Since(start))
+	}
+	close(release)
+	if info, _, err := u.Check(ctx); err != nil || info.Version != "v1.3.0" || hits.Load() != 1 {
+		t.Errorf("shared fetch: %+v, %v, %d requests", info, err, hits.Load())
+	}
+}
+
+func TestVersionUpdate(t *testing.T) {
+	latest := "v1.2.0-rc.1"
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		json.NewEncoder(w).Encode(ReleaseInfo{Version: latest})
+	}))
+	defer srv.Close()
+	defer func(v string) { Version = v }(Version)
+
+	update := func(version string) map[string]any {
+		t.Helper()
+		Version = version
+		s := &Server{Updates: NewUpdateChecker(srv.URL)}
+		w := httptest.NewRecorder()
+		s.HandleVersion(w, httptest.NewRequest("GET", "/api/version", nil))
+		var resp struct {
+			Update map[string]any `json:"update"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp.Update
+	}
+	if u := update("v1.2.0"); u["available"] != false || u["latest"] != "v1.2.0-rc.1" {
+		t.Errorf("release candidate of the running version: %v", u)
+	}
+	latest = "v1.10.0"
+	if u := update("v1.9.3"); u["available"] != true {
+		t.Errorf("newer release: %v", u)
+	}
+	hits.Store(0)
+	if u := update("dev"); u["available"] != false || u["skipped"] == nil || hits.Load() != 0 {
+		t.Errorf("dev build: %v, %d requests", u, hits.Load())
+	}
+}
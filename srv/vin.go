@@ -0,0 +1,446 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Some integrations know the vehicle's VIN, e.g. an access control system
+// that reads the badge of a fleet car. A VIN sent with the event ("vin" or
+// vehicle_info.vin) or entered on the event page is decoded offline: the
+// make from the World Manufacturer Identifier (first three characters), the
+// model year from the tenth character, and the model where a pattern is
+// known. The decoded make and model are the ground truth for MMR accuracy:
+// when a session is archived, and when a VIN is set on an archived event,
+// the maker and model verdicts of those events are filled in by comparing
+// the camera's make and model with the VIN's.
+//
+// -vin-config adds manufacturers and model patterns to the built-in tables;
+// a pattern matches the start of the VIN, ? standing for any character:
+//
+//	{"wmi": {"XTA": "Lada"}, "models": {"WVW???AU": "Golf", "TMB???NX": "Octavia"}}
+
+// VINConfig extends the built-in VIN tables
+type VINConfig struct {
+	WMI    map[string]string `json:"wmi"`    // 2 or 3 character manufacturer prefix -> make
+	Models map[string]string `json:"models"` // VIN prefix pattern -> model
+
+	patterns []vinPattern
+}
+
+// vinPattern is a model pattern over the start of a VIN
+type vinPattern struct {
+	pattern, model string
+}
+
+// vinInfo is what a VIN tells about the vehicle
+type vinInfo struct {
+	VIN   string
+	Make  string
+	Model string
+	Year  int // 0 when not encoded
+}
+
+// LoadVINConfig reads a VIN decoding config file
+func LoadVINConfig(path string) (*VINConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg VINConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// compile checks the tables and orders the model patterns
+func (c *VINConfig) compile() error {
+	for wmi, make := range c.WMI {
+		if n := len(wmi); n < 2 || n > 3 || !validVINChars(wmi) || strings.TrimSpace(make) == "" {
+			return fmt.Errorf("wmi %q: want 2-3 VIN characters and a make", wmi)
+		}
+	}
+	for p, model := range c.Models {
+		if len(p) < 4 || len(p) > 17 || !validVINChars(strings.ReplaceAll(p, "?", "")) || strings.TrimSpace(model) == "" {
+			return fmt.Errorf("model pattern %q: want 4-17 VIN characters or ? and a model", p)
+		}
+	}
+	c.patterns = sortedVINPatterns(c.Models)
+	return nil
+}
+
+// sortedVINPatterns orders patterns most specific first
+func sortedVINPatterns(models map[string]string) []vinPattern {
+	var out []vinPattern
+	for _, p := range slices.Sorted(maps.Keys(models)) {
+		out = append(out, vinPattern{p, models[p]})
+	}
+	specific := func(p string) int { return len(p) - strings.Count(p, "?") }
+	slices.SortStableFunc(out, func(a, b vinPattern) int { return specific(b.pattern) - specific(a.pattern) })
+	return out
+}
+
+func (p vinPattern) match(vin string) bool {
+	if len(vin) < len(p.pattern) {
+		return false
+	}
+	for i := range len(p.pattern) {
+		if p.pattern[i] != '?' && p.pattern[i] != vin[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// validVINChars reports whether s only has characters a VIN may contain:
+// digits and letters other than I, O and Q
+func validVINChars(s string) bool {
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+		case r >= 'A' && r <= 'Z' && r != 'I' && r != 'O' && r != 'Q':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeVIN uppercases a VIN and drops separators
+func normalizeVIN(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, strings.TrimSpace(s))
+}
+
+// vinCheckDigitValid verifies the ninth character, which North American
+// VINs must carry and others may
+func vinCheckDigitValid(vin string) bool {
+	const values = "0123456789" + "12345678.12345.7.9" + "23456789" // transliteration of 0-9 and A-Z
+	weights := [17]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+	sum := 0
+	for i := range len(vin) {
+		c := vin[i]
+		if c >= 'A' {
+			c -= 'A' - 10 - '0'
+		}
+		sum += int(values[c-'0']-'0') * weights[i]
+	}
+	return vin[8] == "0123456789X"[sum%11]
+}
+
+// vinYearCodes are the model year codes of the tenth character, 1980 on
+const vinYearCodes = "ABCDEFGHJKLMNPRSTVWXY123456789"
+
+// vinYear decodes the model year. The 30-year cycle is told apart by the
+// seventh character on North American VINs (a letter from 2010 on); for
+// others the latest year that isn't in the future is taken.
+func vinYear(vin string, now time.Time) int {
+	i := strings.IndexByte(vinYearCodes, vin[9])
+	if i < 0 {
+		return 0
+	}
+	year := 1980 + i
+	if vin[0] >= '1' && vin[0] <= '5' {
+		if vin[6] >= 'A' && vin[6] <= 'Z' {
+			year += 30
+		}
+		return year
+	}
+	for year+30 <= now.Year()+1 {
+		year += 30
+	}
+	return year
+}
+
+// decode reads a VIN with the built-in tables and the config's additions
+func (c *VINConfig) decode(raw string) (vinInfo, error) {
+	vin := normalizeVIN(raw)
+	if len(vin) != 17 || !validVINChars(vin) {
+		return vinInfo{VIN: vin}, fmt.Errorf("invalid VIN %q: want 17 characters without I, O or Q", raw)
+	}
+	info := vinInfo{VIN: vin}
+	var wmi map[string]string
+	var patterns []vinPattern
+	if c != nil {
+		wmi, patterns = c.WMI, c.patterns
+	}
+	for _, table := range []map[string]string{wmi, builtinWMI} {
+		if info.Make = coalesce(table[vin[:3]], table[vin[:2]]); info.Make != "" {
+			break
+		}
+	}
+	for _, p := range append(patterns, builtinVINModels...) {
+		if p.match(vin) {
+			info.Model = p.model
+			break
+		}
+	}
+	// Without a check digit the tenth character isn't reliably the year
+	// outside North America
+	if vin[0] >= '1' && vin[0] <= '5' || vinCheckDigitValid(vin) || strings.Contains(vin[3:6], "ZZZ") {
+		info.Year = vinYear(vin, time.Now())
+	}
+	return info, nil
+}
+
+// builtinWMI maps common World Manufacturer Identifiers to makes
+var builtinWMI = map[string]string{
+	// Germany
+	"WAU": "Audi", "WA1": "Audi", "WUA": "Audi", "TRU": "Audi",
+	"WBA": "BMW", "WBS": "BMW", "WBX": "BMW", "WBY": "BMW", "WMW": "Mini", "WBW": "Mini",
+	"WDB": "Mercedes-Benz", "WDC": "Mercedes-Benz", "WDD": "Mercedes-Benz", "WDF": "Mercedes-Benz",
+	"W1K": "Mercedes-Benz", "W1N": "Mercedes-Benz", "W1V": "Mercedes-Benz", "WME": "Smart",
+	"WVW": "Volkswagen", "WVG": "Volkswagen", "WV1": "Volkswagen", "WV2": "Volkswagen", "WV3": "Volkswagen",
+	"WP0": "Porsche", "WP1": "Porsche",
+	"W0L": "Opel", "W0V": "Opel", "WF0": "Ford",
+	// Rest of Europe
+	"TMB": "Skoda", "VSS": "SEAT", "VSK": "Nissan",
+	"VF1": "Renault", "VF6": "Renault", "VF3": "Peugeot", "VR3": "Peugeot", "VF7": "Citroen", "VR7": "Citroen",
+	"VR1": "DS", "UU1": "Dacia", "VNK": "Toyota",
+	"ZFA": "Fiat", "ZFF": "Ferrari", "ZAR": "Alfa Romeo", "ZLA": "Lancia", "ZHW": "Lamborghini", "ZAM": "Maserati",
+	"ZCF": "Iveco",
+	"SAL": "Land Rover", "SAJ": "Jaguar", "SCC": "Lotus", "SCF": "Aston Martin", "SCA": "Rolls-Royce",
+	"SCB": "Bentley", "SB1": "Toyota", "SJN": "Nissan", "SHH": "Honda", "SFD": "Alexander Dennis",
+	"YV1": "Volvo", "YV4": "Volvo", "YS2": "Scania", "YS3": "Saab", "LYV": "Volvo",
+	"TMA": "Hyundai", "TMK": "Hyundai", "U5Y": "Kia", "U6Y": "Kia", "NMT": "Toyota", "NM0": "Ford",
+	"XTA": "Lada", "X7L": "Renault", "XW8": "Volkswagen",
+	// Asia
+	"JHM": "Honda", "JHL": "Honda", "JH4": "Acura", "JT": "Toyota", "JTH": "Lexus", "JTJ": "Lexus",
+	"JN1": "Nissan", "JN8": "Nissan", "JNK": "Infiniti", "JM1": "Mazda", "JMZ": "Mazda", "JM3": "Mazda",
+	"JF1": "Subaru", "JF2": "Subaru", "JS1": "Suzuki", "JS2": "Suzuki", "JSA": "Suzuki", "TSM": "Suzuki",
+	"JA3": "Mitsubishi", "JA4": "Mitsubishi", "JMB": "Mitsubishi", "JMY": "Mitsubishi",
+	"KMH": "Hyundai", "KM8": "Hyundai", "KNA": "Kia", "KND": "Kia", "KNM": "Renault Samsung",
+	"KPT": "SsangYong", "MA1": "Mahindra", "MAT": "Tata", "MAL": "Hyundai",
+	"LRW": "Tesla", "LVS": "Ford", "LFV": "Volkswagen", "LSV": "Volkswagen", "LBV": "BMW", "LE4": "Mercedes-Benz",
+	"LGX": "BYD", "LC0": "BYD", "L6T": "Geely", "LB3": "Geely", "LSJ": "MG", "LPS": "Polestar", "LNB": "BAIC",
+	// North America
+	"1FA": "Ford", "1FB": "Ford", "1FC": "Ford", "1FD": "Ford", "1FM": "Ford", "1FT": "Ford", "2FA": "Ford",
+	"2FM": "Ford", "3FA": "Ford", "1LN": "Lincoln", "5LM": "Lincoln",
+	"1G1": "Chevrolet", "1GC": "Chevrolet", "1GN": "Chevrolet", "2G1": "Chevrolet", "3GN": "Chevrolet",
+	"1GT": "GMC", "1GK": "GMC", "1G6": "Cadillac", "1GY": "Cadillac", "1G4": "Buick", "5GA": "Buick",
+	"1C3": "Chrysler", "2C3": "Chrysler", "1C4": "Jeep", "1J4": "Jeep", "1J8": "Jeep",
+	"1B3": "Dodge", "2B3": "Dodge", "1D7": "Dodge", "3D7": "Ram", "1C6": "Ram", "3C6": "Ram",
+	"5YJ": "Tesla", "7SA": "Tesla", "1N4": "Nissan", "1N6": "Nissan", "5N1": "Nissan", "3N1": "Nissan",
+	"1HG": "Honda", "2HG": "Honda", "5FN": "Honda", "5J6": "Honda", "19X": "Honda",
+	"4T1": "Toyota", "4T3": "Toyota", "5TD": "Toyota", "5TF": "Toyota", "2T1": "Toyota", "2T3": "Toyota",
+	"4S3": "Subaru", "4S4": "Subaru", "5XY": "Kia", "5NP": "Hyundai", "5NM": "Hyundai", "4JG": "Mercedes-Benz",
+	"5UX": "BMW", "4US": "BMW", "3VW": "Volkswagen", "1VW": "Volkswagen", "7MU": "Toyota", "3MZ": "Mazda",
+	"1YV": "Mazda", "4F2": "Mazda", "7FA": "Honda", "3KP": "Kia", "1FU": "Freightliner", "1XK": "Kenworth",
+	"1XP": "Peterbilt", "4V4": "Volvo Trucks",
+}
+
+// builtinVINModels are model patterns of the VW group, whose model code is
+// characters 7-8, and of Tesla, whose model letter is character 4
+var builtinVINModels = sortedVINPatterns(map[string]string{
+	"WVW???1K": "Golf", "WVW???5G": "Golf", "WVW???AU": "Golf", "WVW???CD": "Golf", "WVW???1J": "Golf",
+	"WVW???6R": "Polo", "WVW???AW": "Polo", "WVW???3C": "Passat", "WVW???3G": "Passat", "WVW???CB": "Passat",
+	"WVW???3H": "Arteon", "WVW???5K": "Golf", "WVW???1T": "Touran", "WVW???5T": "Touran", "WVW???AA": "up!",
+	"WVG???5N": "Tiguan", "WVG???AD": "Tiguan", "WVG???7P": "Touareg", "WVG???CR": "Touareg",
+	"WVG???A1": "T-Roc", "WVG???E1": "ID.4", "WVW???E1": "ID.3", "WV2???7H": "Transporter", "WV2???7J": "Transporter",
+	"WV1???7H": "Transporter", "WV1???2K": "Caddy", "WV2???2K": "Caddy", "WV1???SB": "Caddy",
+	"WAU???8K": "A4", "WAU???8W": "A4", "WAU???8V": "A3", "WAU???8Y": "A3", "WAU???4G": "A6", "WAU???4A": "A6",
+	"WAU???8X": "A1", "WAU???GB": "A1", "WAU???4H": "A8", "WAU???F5": "A5", "WAU???8T": "A5",
+	"WA1???8U": "Q3", "WA1???F3": "Q3", "WA1???8R": "Q5", "WA1???FY": "Q5", "WA1???4M": "Q7",
+	"TMB???1Z": "Octavia", "TMB???5E": "Octavia", "TMB???NX": "Octavia", "TMB???3T": "Superb",
+	"TMB???3V": "Superb", "TMB???5J": "Fabia", "TMB???NJ": "Fabia", "TMB???PJ": "Fabia", "TMB???NS": "Kodiaq",
+	"TMB???NU": "Karoq", "TMB???NW": "Scala", "TMB???5L": "Yeti", "TMB???NH": "Rapid",
+	"VSS???5F": "Leon", "VSS???KL": "Leon", "VSS???6J": "Ibiza", "VSS???KJ": "Ibiza", "VSS???5P": "Altea",
+	"VSS???KH": "Ateca", "VSS???5N": "Alhambra",
+	"5YJS": "Model S", "5YJX": "Model X", "5YJ3": "Model 3", "5YJY": "Model Y", "LRW3": "Model 3",
+	"LRWY": "Model Y", "7SAY": "Model Y", "7SAX": "Model X", "7SAS": "Model S",
+})
+
+// makeAliases are spellings of one make
+var makeAliases = map[string]string{
+	"vw": "volkswagen", "mercedes": "mercedesbenz", "benz": "mercedesbenz", "mb": "mercedesbenz",
+	"chevy": "chevrolet", "alfa": "alfaromeo", "rangerover": "landrover",
+	"cupra": "seat", "bmwmini": "mini",
+}
+
+// foldAccents replaces the accented letters of make names
+var foldAccents = strings.NewReplacer("š", "s", "ë", "e", "é", "e", "è", "e", "ö", "o", "ü", "u", "ä", "a", "č", "c")
+
+// vehicleKey folds a make or model for comparison: lowercase, without
+// accents, spaces and punctuation
+func vehicleKey(s string) string {
+	var b strings.Builder
+	for _, r := range foldAccents.Replace(strings.ToLower(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// sameMake reports whether the camera's make names the VIN's
+func sameMake(camera, vin string) bool {
+	a, b := vehicleKey(camera), vehicleKey(vin)
+	if alias, ok := makeAliases[a]; ok {
+		a = alias
+	}
+	if alias, ok := makeAliases[b]; ok {
+		b = alias
+	}
+	return a != "" && a == b
+}
+
+// sameModel reports whether the camera's model names the VIN's; a camera
+// model with a body style ("A4 Avant", "Golf Variant") still matches
+func sameModel(camera, vin string) bool {
+	a, b := vehicleKey(camera), vehicleKey(vin)
+	return a != "" && b != "" && (strings.HasPrefix(a, b) || strings.HasPrefix(b, a))
+}
+
+// vinVerdict is the compare verdict the VIN gives for an event
+func vinVerdict(e dbgen.GetArchiveVINEventsRow) compareUpdate {
+	u := compareUpdate{EventID: e.ID}
+	maker := sameMake(deref(e.VehicleMake), deref(e.VinMake))
+	u.Maker = ptr(!maker)
+	if e.VinModel != nil {
+		u.Model = ptr(!maker || !sameModel(deref(e.VehicleModel), *e.VinModel))
+	}
+	return u
+}
+
+// applyVINTruth fills in the maker and model verdicts of an archive's
+// events that have a decoded VIN, or of one event when eventID isn't 0
+func (s *Server) applyVINTruth(ctx context.Context, archiveID, eventID int64) (int, error) {
+	events, err := dbgen.New(s.DB).GetArchiveVINEvents(ctx, &archiveID)
+	if err != nil {
+		return 0, err
+	}
+	var updates []compareUpdate
+	for _, e := range events {
+		if eventID == 0 || e.ID == eventID {
+			updates = append(updates, vinVerdict(e))
+		}
+	}
+	if len(updates) == 0 {
+		return 0, nil
+	}
+	n, err := s.saveCompareUpdates(ctx, archiveID, updates, "", false)
+	if err != nil {
+		return 0, err
+	}
+	slog.Info("vin ground truth applied", "archive", archiveID, "events", len(updates), "verdicts", n)
+	return n, nil
+}
+
+// archiveVINTruth applies the VIN ground truth to a new archive
+func (s *Server) archiveVINTruth(ctx context.Context, archiveID int64) {
+	if _, err := s.applyVINTruth(ctx, archiveID, 0); err != nil {
+		slog.Warn("apply vin ground truth", "archive", archiveID, "error", err)
+	}
+}
+
+// recordVIN decodes and stores an event's VIN; an empty VIN clears it
+func (s *Server) recordVIN(ctx context.Context, q *dbgen.Queries, eventID int64, raw string) (vinInfo, error) {
+	params := dbgen.SetEventVINParams{ID: eventID}
+	var info vinInfo
+	var decodeErr error
+	if strings.TrimSpace(raw) != "" {
+		info, decodeErr = s.VIN.decode(raw)
+		params.Vin = ptr(info.VIN)
+		params.VinMake = ptrIfNotEmpty(info.Make)
+		params.VinModel = ptrIfNotEmpty(info.Model)
+		if info.Year > 0 {
+			params.VinYear = ptr(int64(info.Year))
+		}
+	}
+	if err := q.SetEventVIN(ctx, params); err != nil {
+		return info, err
+	}
+	return info, decodeErr
+}
+
+// vin is the VIN a camera message carries
+func (e IncomingEvent) vin() string {
+	if e.VehicleInfo != nil && e.VehicleInfo.VIN != "" {
+		return e.VehicleInfo.VIN
+	}
+	return e.VIN
+}
+
+// ingestVIN stores the VIN of a camera message, if it has one
+func (s *Server) ingestVIN(ctx context.Context, q *dbgen.Queries, eventID int64, e IncomingEvent) {
+	if e.vin() == "" {
+		return
+	}
+	if info, err := s.recordVIN(ctx, q, eventID, e.vin()); err != nil {
+		slog.Warn("event vin", "id", eventID, "vin", info.VIN, "error", err)
+	}
+}
+
+// HandleSetEventVIN stores a VIN entered for an event
+func (s *Server) HandleSetEventVIN(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid event id", http.StatusBadRequest)
+		return
+	}
+	q := dbgen.New(s.DB)
+	event, err := q.GetEventByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "event not found", http.StatusNotFound)
+		return
+	}
+	if locked, err := s.eventLocked(r.Context(), event.ArchiveID); err != nil || locked {
+		http.Error(w, "event is in a locked archive", http.StatusConflict)
+		return
+	}
+	info, err := s.recordVIN(r.Context(), q, id, r.FormValue("vin"))
+	var invalid bool
+	if err != nil {
+		if info.VIN == "" {
+			slog.Error("failed to set vin", "error", err)
+			http.Error(w, "failed to set vin", http.StatusInternalServerError)
+			return
+		}
+		invalid = true // stored as entered, nothing decoded
+	}
+	slog.Info("vin set", "id", id, "vin", info.VIN, "make", info.Make, "model", info.Model, "year", info.Year, "invalid", invalid)
+	if event.ArchiveID != nil && !invalid {
+		if _, err := s.applyVINTruth(r.Context(), *event.ArchiveID, id); err != nil && !errors.Is(err, errArchiveLocked) {
+			slog.Warn("apply vin ground truth", "archive", *event.ArchiveID, "event", id, "error", err)
+		}
+	}
+	http.Redirect(w, r, fmt.Sprintf("/event/%d", id), http.StatusSeeOther)
+}
+
+// HandleApplyVINTruth re-applies the VIN ground truth to an archive's
+// maker and model verdicts
+func (s *Server) HandleApplyVINTruth(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid archive id", http.StatusBadRequest)
+		return
+	}
+	n, err := s.applyVINTruth(r.Context(), id, 0)
+	if err != nil {
+		s.writeCompareError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "verdicts": n})
+}
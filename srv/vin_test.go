@@ -0,0 +1,124 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestDecodeVIN(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vin.json")
+	os.WriteFile(path, []byte(`{"wmi": {"XTA": "Lada"}, "models": {"XTA21???": "Niva", "WVW???1K": "Golf Mk5"}}`), 0o644)
+	cfg, err := LoadVINConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		vin  string
+		want vinInfo
+	}{
+		{"1HGCM82633A004352", vinInfo{"1HGCM82633A004352", "Honda", "", 2003}},
+		{"5YJ3E1EA7KF317000", vinInfo{"5YJ3E1EA7KF317000", "Tesla", "Model 3", 2019}},
+		{"wauzzz8k9ba123456", vinInfo{"WAUZZZ8K9BA123456", "Audi", "A4", 2011}},
+		{"WVW ZZZ 1KZ 6W000001", vinInfo{"WVWZZZ1KZ6W000001", "Volkswagen", "Golf Mk5", 2006}},
+		{"TMBJJ7NX4MY012345", vinInfo{"TMBJJ7NX4MY012345", "Skoda", "Octavia", 0}},
+		{"XTA212141K1234567", vinInfo{"XTA212141K1234567", "Lada", "Niva", 0}},
+		{"9BWZZZ377HT004251", vinInfo{"9BWZZZ377HT004251", "", "", 2017}},
+	} {
+		got, err := cfg.decode(tt.vin)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: %+v %v, want %+v", tt.vin, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"WVWZZZ1KZ6W00000O", "WVWZZZ1KZ6W", ""} {
+		if _, err := cfg.decode(bad); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+	if !vinCheckDigitValid("1HGCM82633A004352") || vinCheckDigitValid("1HGCM82643A004352") {
+		t.Error("check digit")
+	}
+	for _, c := range []struct{ camera, vin string }{{"VW", "Volkswagen"}, {"Mercedes", "Mercedes-Benz"}, {"ŠKODA", "Skoda"}} {
+		if !sameMake(c.camera, c.vin) {
+			t.Errorf("%s is %s", c.camera, c.vin)
+		}
+	}
+	if sameMake("", "Audi") || !sameModel("A4 Avant", "A4") || sameModel("Golf", "Polo") {
+		t.Error("make/model comparison")
+	}
+}
+
+func TestVINTruth(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	for _, body := range []string{
+		`{"carID":"1","plateUTF8":"V1","vin":"WAUZZZ8K9BA123456","vehicle_info":{"make":"Audi","model":"A4 Avant"}}`,
+		`{"carID":"2","plateUTF8":"V2","vehicle_info":{"make":"VW","model":"Passat","vin":"WVWZZZ1KZ6W000001"}}`,
+		`{"carID":"3","plateUTF8":"V3","vin":"WAUZZZ8K9BA123457","vehicle_info":{"make":"Skoda"}}`,
+		`{"carID":"4","plateUTF8":"V4","vehicle_info":{"make":"BMW","model":"X5"}}`,
+		`{"carID":"5","plateUTF8":"V5","vin":"NOT-A-VIN"}`,
+	} {
+		if _, err := s.ingestEvent(ctx, newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q := dbgen.New(s.DB)
+	if e, _ := q.GetEventByID(ctx, 5); deref(e.Vin) != "NOTAVIN" || e.VinMake != nil {
+		t.Errorf("invalid vin stored as %v / %v", deref(e.Vin), e.VinMake)
+	}
+
+	s.HandleClean(httptest.NewRecorder(), httptest.NewRequest("POST", "/clean", nil))
+	verdicts := func() map[string]bool {
+		res, _ := q.GetCompareResults(ctx, 1)
+		out := map[string]bool{}
+		for _, r := range res {
+			out[string(rune('0'+r.EventID))+r.Field] = r.IsIncorrect
+		}
+		return out
+	}
+	want := map[string]bool{"1maker": false, "1model": false, "2maker": false, "2model": true, "3maker": true, "3model": true}
+	if got := verdicts(); len(got) != len(want) {
+		t.Fatalf("verdicts %v, want %v", got, want)
+	} else {
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s incorrect=%v, want %v", k, got[k], v)
+			}
+		}
+	}
+
+	// A VIN entered for an archived event fills in its verdicts
+	r := httptest.NewRequest("POST", "/event/4/vin", strings.NewReader(url.Values{"vin": {"WBAFE41000LA12345"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetPathValue("id", "4")
+	w := httptest.NewRecorder()
+	s.HandleSetEventVIN(w, r)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("set vin: %d %s", w.Code, w.Body)
+	}
+	if got := verdicts(); len(got) != 7 || got["4maker"] {
+		t.Errorf("verdicts after vin: %v", got)
+	}
+
+	r = httptest.NewRequest("GET", "/event/2", nil)
+	r.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	s.HandleEvent(w, r)
+	if !strings.Contains(w.Body.String(), "WVWZZZ1KZ6W000001 (Volkswagen Golf, 2006)") {
+		t.Error("event page doesn't show the decoded VIN")
+	}
+	r = httptest.NewRequest("GET", "/archive/1/compare", nil)
+	r.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	s.HandleCompare(w, r)
+	if !strings.Contains(w.Body.String(), "VIN: Golf (2006)") {
+		t.Error("compare page doesn't show the VIN's model")
+	}
+}
@@ -0,0 +1,834 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Watchlists are named lists of plates managed on /watchlists or via
+// /api/watchlists. Entries are exact plates or * and ? patterns, matched
+// like the email watchlist without spaces, dashes and dots, with an
+// optional note and expiry date. Every new read is checked against the
+// enabled lists; a match is recorded as a hit (once per event and list)
+// and runs the list's actions:
+//
+//   - webhook: the hit is POSTed as JSON to the list's URL
+//   - email: sent to the list's addresses through the -email-config SMTP
+//     server, plate crop attached
+//   - flag: the read is highlighted on the dashboard
+//
+// The webhook and email aren't repeated for the same plate and list within
+// watchlistCooldown, so a car's new/update/lost messages notify once; the
+// hit records "cooldown" instead.
+//
+//	GET    /api/watchlists                     lists with entry and hit counts
+//	POST   /api/watchlists                     {"name", "webhook_url", "email_to": [..], "flag", "enabled"}
+//	GET    /api/watchlists/{id}                a list and its entries
+//	PATCH  /api/watchlists/{id}                change any of the fields above
+//	DELETE /api/watchlists/{id}                delete a list, its entries and hits
+//	POST   /api/watchlists/{id}/entries        {"plate", "note", "expires": "2026-12-31"}
+//	DELETE /api/watchlists/{id}/entries/{entry}
+//	GET    /api/watchlists/hits?watchlist=&plate=AB*&current=1&flagged=1&limit=100
+
+const (
+	watchlistCooldown = 10 * time.Minute
+	watchlistTimeout  = 10 * time.Second // per webhook request
+	watchlistMaxHits  = 1000
+)
+
+var watchlistClient = &http.Client{Timeout: watchlistTimeout}
+
+// watchlistCache holds the entries of the enabled lists, reloaded after a
+// change
+type watchlistCache struct {
+	mu         sync.Mutex
+	entries    []dbgen.GetActiveWatchlistEntriesRow
+	loaded     bool
+	lastAction map[string]time.Time // list and plate -> last webhook/email
+}
+
+// active returns the entries of the enabled lists
+func (c *watchlistCache) active(ctx context.Context, q *dbgen.Queries) ([]dbgen.GetActiveWatchlistEntriesRow, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.loaded {
+		entries, err := q.GetActiveWatchlistEntries(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.entries, c.loaded = entries, true
+	}
+	return c.entries, nil
+}
+
+// invalidate makes the next read reload the entries
+func (c *watchlistCache) invalidate() {
+	c.mu.Lock()
+	c.loaded = false
+	c.entries = nil
+	c.mu.Unlock()
+}
+
+// cooling reports whether a list's actions ran for plate within the
+// cooldown, and otherwise starts a new one
+func (c *watchlistCache) cooling(watchlistID int64, plate string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strconv.FormatInt(watchlistID, 10) + "\x00" + plate
+	if last, ok := c.lastAction[key]; ok && now.Sub(last) < watchlistCooldown {
+		return true
+	}
+	if c.lastAction == nil {
+		c.lastAction = make(map[string]time.Time)
+	}
+	if len(c.lastAction) >= 10000 {
+		for k, t := range c.lastAction {
+			if now.Sub(t) >= watchlistCooldown {
+				delete(c.lastAction, k)
+			}
+		}
+	}
+	c.lastAction[key] = now
+	return false
+}
+
+// watchlistPattern normalizes a plate or pattern for an entry
+func watchlistPattern(plate string) (string, error) {
+	p := normalizePlate(plate)
+	if p == "" || strings.Trim(p, "*?") == "" {
+		return "", errors.New("plate is required and must have more than wildcards")
+	}
+	if _, err := path.Match(p, ""); err != nil {
+		return "", fmt.Errorf("plate %q: %w", plate, err)
+	}
+	if strings.ContainsAny(p, "[]\\/") {
+		return "", fmt.Errorf("plate %q: only * and ? are wildcards", plate)
+	}
+	return p, nil
+}
+
+// watchlistHitMessage is what a webhook receives
+type watchlistHitMessage struct {
+	HitID     int64     `json:"hit_id"`
+	Watchlist string    `json:"watchlist"`
+	Entry     string    `json:"entry"`
+	Note      string    `json:"note,omitempty"`
+	EventID   int64     `json:"event_id"`
+	Plate     string    `json:"plate"`
+	Camera    string    `json:"camera"`
+	At        time.Time `json:"at"`
+	URL       string    `json:"url"`
+}
+
+// matchWatchlists records the hits of a new read on the enabled lists and
+// runs their actions in the background
+func (s *Server) matchWatchlists(ctx context.Context, q *dbgen.Queries, eventID int64, plate, camera string, at time.Time) {
+	norm := normalizePlate(plate)
+	if norm == "" {
+		return
+	}
+	entries, err := s.watchlists.active(ctx, q)
+	if err != nil {
+		slog.Warn("load watchlists", "error", err)
+		return
+	}
+	hit := map[int64]bool{}
+	for _, e := range entries {
+		if hit[e.WatchlistID] || (e.ExpiresAt != nil && !at.Before(*e.ExpiresAt)) {
+			continue
+		}
+		if ok, _ := path.Match(e.Plate, norm); !ok {
+			continue
+		}
+		hit[e.WatchlistID] = true
+		id, err := q.InsertWatchlistHit(ctx, dbgen.InsertWatchlistHitParams{
+			EventID:     eventID,
+			WatchlistID: e.WatchlistID,
+			EntryID:     &e.ID,
+			Pattern:     e.Plate,
+			Plate:       plate,
+			Camera:      ptrIfNotEmpty(camera),
+			Note:        e.Note,
+			CreatedAt:   at,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			slog.Warn("record watchlist hit", "id", eventID, "watchlist", e.WatchlistName, "error", err)
+			continue
+		}
+		slog.Info("watchlist hit", "id", eventID, "plate", plate, "watchlist", e.WatchlistName, "entry", e.Plate, "camera", camera)
+		if deref(e.WebhookUrl) == "" && deref(e.EmailTo) == "" {
+			continue
+		}
+		msg := watchlistHitMessage{
+			HitID:     id,
+			Watchlist: e.WatchlistName,
+			Entry:     e.Plate,
+			Note:      deref(e.Note),
+			EventID:   eventID,
+			Plate:     plate,
+			Camera:    camera,
+			At:        at,
+			URL:       fmt.Sprintf("%s/event/%d", strings.TrimSuffix(s.PublicURL, "/"), eventID),
+		}
+		if s.watchlists.cooling(e.WatchlistID, norm, at) {
+			cooldown := "cooldown"
+			s.setWatchlistHitActions(ctx, q, id, e, &cooldown, &cooldown)
+			continue
+		}
+		s.background.Go(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), emailTimeout)
+			defer cancel()
+			var webhook, email *string
+			if u := deref(e.WebhookUrl); u != "" {
+				webhook = ptr(actionStatus(postWatchlistHit(ctx, u, msg)))
+			}
+			if to := deref(e.EmailTo); to != "" {
+				email = ptr(actionStatus(s.emailWatchlistHit(ctx, strings.Split(to, ","), msg)))
+			}
+			s.setWatchlistHitActions(ctx, dbgen.New(s.DB), id, e, webhook, email)
+		})
+	}
+}
+
+// setWatchlistHitActions records the outcome of a hit's actions; an action
+// the list doesn't have stays NULL
+func (s *Server) setWatchlistHitActions(ctx context.Context, q *dbgen.Queries, id int64, e dbgen.GetActiveWatchlistEntriesRow, webhook, email *string) {
+	if deref(e.WebhookUrl) == "" {
+		webhook = nil
+	}
+	if deref(e.EmailTo) == "" {
+		email = nil
+	}
+	err := q.SetWatchlistHitActions(ctx, dbgen.SetWatchlistHitActionsParams{WebhookStatus: webhook, EmailStatus: email, ID: id})
+	if err != nil {
+		slog.Warn("record watchlist actions", "hit", id, "error", err)
+	}
+}
+
+func actionStatus(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "sent"
+}
+
+// postWatchlistHit sends a hit to a list's webhook
+func postWatchlistHit(ctx context.Context, webhookURL string, msg watchlistHitMessage) error {
+	body, _ := json.Marshal(msg)
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mmrapi/"+Version)
+	resp, err := watchlistClient.Do(req)
+	if err != nil {
+		slog.Warn("watchlist webhook failed", "url", webhookURL, "error", err)
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		slog.Warn("watchlist webhook failed", "url", webhookURL, "status", resp.Status)
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return nil
+}
+
+// emailWatchlistHit mails a hit with the plate crop attached
+func (s *Server) emailWatchlistHit(ctx context.Context, to []string, msg watchlistHitMessage) error {
+	if s.Email == nil {
+		return errors.New("no -email-config")
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, "Plate:     %s\nWatchlist: %s\n", msg.Plate, msg.Watchlist)
+	if msg.Entry != normalizePlate(msg.Plate) {
+		fmt.Fprintf(&body, "Entry:     %s\n", msg.Entry)
+	}
+	if msg.Note != "" {
+		fmt.Fprintf(&body, "Note:      %s\n", msg.Note)
+	}
+	fmt.Fprintf(&body, "Camera:    %s\nTime:      %s\n\n%s\n", msg.Camera, msg.At.Local().Format("2006-01-02 15:04:05 MST"), msg.URL)
+	subject := fmt.Sprintf("%s: %s at %s", msg.Watchlist, msg.Plate, msg.Camera)
+	return s.sendEmail(ctx, to, subject, body.String(), s.plateCrop(ctx, msg.EventID, msg.Plate))
+}
+
+// watchlistView is a list as the API shows it
+type watchlistView struct {
+	ID         int64                `json:"id"`
+	Name       string               `json:"name"`
+	WebhookURL string               `json:"webhook_url"`
+	EmailTo    []string             `json:"email_to"`
+	Flag       bool                 `json:"flag"`
+	Enabled    bool                 `json:"enabled"`
+	CreatedAt  time.Time            `json:"created_at"`
+	Entries    int64                `json:"entries"`
+	Hits       int64                `json:"hits"`
+	EntryList  []watchlistEntryView `json:"entry_list,omitempty"`
+}
+
+func newWatchlistView(w dbgen.GetWatchlistsRow) watchlistView {
+	return watchlistView{
+		ID:         w.ID,
+		Name:       w.Name,
+		WebhookURL: deref(w.WebhookUrl),
+		EmailTo:    splitAddresses(deref(w.EmailTo)),
+		Flag:       w.Flag,
+		Enabled:    w.Enabled,
+		CreatedAt:  w.CreatedAt,
+		Entries:    w.Entries,
+		Hits:       w.Hits,
+	}
+}
+
+// EmailList is the list's email addresses for the watchlists page
+func (v watchlistView) EmailList() string {
+	return strings.Join(v.EmailTo, ", ")
+}
+
+// watchlistEntryView is an entry as the API shows it
+type watchlistEntryView struct {
+	dbgen.WatchlistEntry
+	Expired bool `json:"expired"`
+}
+
+func newWatchlistEntryViews(entries []dbgen.WatchlistEntry, now time.Time) []watchlistEntryView {
+	views := make([]watchlistEntryView, len(entries))
+	for i, e := range entries {
+		views[i] = watchlistEntryView{WatchlistEntry: e, Expired: e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)}
+	}
+	return views
+}
+
+func splitAddresses(s string) []string {
+	var out []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// watchlistChange is a new list or changes to one; absent fields are kept
+type watchlistChange struct {
+	Name       *string   `json:"name"`
+	WebhookURL *string   `json:"webhook_url"`
+	EmailTo    *[]string `json:"email_to"`
+	Flag       *bool     `json:"flag"`
+	Enabled    *bool     `json:"enabled"`
+}
+
+// apply checks the changes and makes them to p
+func (c watchlistChange) apply(p *dbgen.UpdateWatchlistParams) error {
+	if c.Name != nil {
+		p.Name = strings.TrimSpace(*c.Name)
+	}
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if c.WebhookURL != nil {
+		u := strings.TrimSpace(*c.WebhookURL)
+		if u != "" {
+			parsed, err := url.Parse(u)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("webhook_url %q: want an http(s) URL", u)
+			}
+		}
+		p.WebhookUrl = ptrIfNotEmpty(u)
+	}
+	if c.EmailTo != nil {
+		var to []string
+		for _, a := range *c.EmailTo {
+			to = append(to, splitAddresses(a)...)
+		}
+		if err := checkRecipients(to); err != nil {
+			return err
+		}
+		p.EmailTo = ptrIfNotEmpty(strings.Join(to, ","))
+	}
+	if c.Flag != nil {
+		p.Flag = *c.Flag
+	}
+	if c.Enabled != nil {
+		p.Enabled = *c.Enabled
+	}
+	return nil
+}
+
+// createWatchlist stores a new list
+func (s *Server) createWatchlist(ctx context.Context, c watchlistChange) (dbgen.Watchlist, int, error) {
+	p := dbgen.UpdateWatchlistParams{Flag: true, Enabled: true}
+	if err := c.apply(&p); err != nil {
+		return dbgen.Watchlist{}, http.StatusBadRequest, err
+	}
+	w, err := dbgen.New(s.DB).CreateWatchlist(ctx, dbgen.CreateWatchlistParams{
+		Name:       p.Name,
+		WebhookUrl: p.WebhookUrl,
+		EmailTo:    p.EmailTo,
+		Flag:       p.Flag,
+		Enabled:    p.Enabled,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		return w, watchlistErrorStatus(err), watchlistError(err, p.Name)
+	}
+	s.watchlists.invalidate()
+	slog.Info("watchlist created", "id", w.ID, "name", w.Name)
+	return w, http.StatusCreated, nil
+}
+
+// updateWatchlist changes a list
+func (s *Server) updateWatchlist(ctx context.Context, id int64, c watchlistChange) (int, error) {
+	q := dbgen.New(s.DB)
+	w, err := q.GetWatchlist(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, errors.New("watchlist not found")
+	}
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("database error")
+	}
+	p := dbgen.UpdateWatchlistParams{Name: w.Name, WebhookUrl: w.WebhookUrl, EmailTo: w.EmailTo, Flag: w.Flag, Enabled: w.Enabled, ID: id}
+	if err := c.apply(&p); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if _, err := q.UpdateWatchlist(ctx, p); err != nil {
+		return watchlistErrorStatus(err), watchlistError(err, p.Name)
+	}
+	s.watchlists.invalidate()
+	slog.Info("watchlist updated", "id", id, "name", p.Name, "enabled", p.Enabled)
+	return http.StatusOK, nil
+}
+
+func watchlistErrorStatus(err error) int {
+	if strings.Contains(err.Error(), "UNIQUE constraint") {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func watchlistError(err error, name string) error {
+	if strings.Contains(err.Error(), "UNIQUE constraint") {
+		return fmt.Errorf("a watchlist named %q already exists", name)
+	}
+	slog.Error("failed to save watchlist", "error", err)
+	return errors.New("database error")
+}
+
+// deleteWatchlist removes a list with its entries and hits
+func (s *Server) deleteWatchlist(ctx context.Context, id int64) (bool, error) {
+	n, err := dbgen.New(s.DB).DeleteWatchlist(ctx, id)
+	if err != nil || n == 0 {
+		return false, err
+	}
+	s.watchlists.invalidate()
+	slog.Info("watchlist deleted", "id", id)
+	return true, nil
+}
+
+// parseWatchlistExpiry reads an entry's expiry: RFC 3339, or a date the
+// entry is valid through
+func parseWatchlistExpiry(v string) (*time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		t = t.AddDate(0, 0, 1)
+		return &t, nil
+	}
+	return nil, fmt.Errorf("invalid expiry %q (want YYYY-MM-DD or RFC 3339)", v)
+}
+
+// addWatchlistEntry adds a plate to a list, or updates its note and expiry
+func (s *Server) addWatchlistEntry(ctx context.Context, watchlistID int64, plate, note, expires, user string) (dbgen.WatchlistEntry, int, error) {
+	pattern, err := watchlistPattern(plate)
+	if err != nil {
+		return dbgen.WatchlistEntry{}, http.StatusBadRequest, err
+	}
+	expiresAt, err := parseWatchlistExpiry(expires)
+	if err != nil {
+		return dbgen.WatchlistEntry{}, http.StatusBadRequest, err
+	}
+	q := dbgen.New(s.DB)
+	if _, err := q.GetWatchlist(ctx, watchlistID); errors.Is(err, sql.ErrNoRows) {
+		return dbgen.WatchlistEntry{}, http.StatusNotFound, errors.New("watchlist not found")
+	} else if err != nil {
+		return dbgen.WatchlistEntry{}, http.StatusInternalServerError, errors.New("database error")
+	}
+	e, err := q.UpsertWatchlistEntry(ctx, dbgen.UpsertWatchlistEntryParams{
+		WatchlistID: watchlistID,
+		Plate:       pattern,
+		Note:        ptrIfNotEmpty(strings.TrimSpace(note)),
+		ExpiresAt:   expiresAt,
+		CreatedBy:   ptrIfNotEmpty(user),
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		slog.Error("failed to add watchlist entry", "error", err)
+		return e, http.StatusInternalServerError, errors.New("database error")
+	}
+	s.watchlists.invalidate()
+	slog.Info("watchlist entry saved", "watchlist", watchlistID, "plate", pattern, "by", user)
+	return e, http.StatusCreated, nil
+}
+
+// deleteWatchlistEntry removes a plate from a list
+func (s *Server) deleteWatchlistEntry(ctx context.Context, watchlistID, entryID int64) (bool, error) {
+	n, err := dbgen.New(s.DB).DeleteWatchlistEntry(ctx, dbgen.DeleteWatchlistEntryParams{ID: entryID, WatchlistID: watchlistID})
+	if err != nil || n == 0 {
+		return false, err
+	}
+	s.watchlists.invalidate()
+	return true, nil
+}
+
+// watchlistHits returns hits, newest first, filtered by the query
+// parameters watchlist, plate (a pattern), current and flagged
+func (s *Server) watchlistHits(r *http.Request) ([]dbgen.GetWatchlistHitsRow, error) {
+	query := r.URL.Query()
+	p := dbgen.GetWatchlistHitsParams{
+		CurrentOnly: query.Get("current") == "1" || query.Get("current") == "true",
+		FlaggedOnly: query.Get("flagged") == "1" || query.Get("flagged") == "true",
+		Limit:       100,
+	}
+	if v := query.Get("watchlist"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid watchlist %q", v)
+		}
+		p.WatchlistID = &id
+	}
+	if v := normalizePlate(query.Get("plate")); v != "" {
+		p.Plate = &v
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > watchlistMaxHits {
+			return nil, fmt.Errorf("limit must be 1-%d", watchlistMaxHits)
+		}
+		p.Limit = n
+	}
+	return dbgen.New(s.DB).GetWatchlistHits(r.Context(), p)
+}
+
+// flaggedHits maps the events of the current session with a hit on a
+// flagged list to the lists' names, for the dashboard
+func (s *Server) flaggedHits(ctx context.Context, q *dbgen.Queries, limit int64) (map[int64]string, []dbgen.GetWatchlistHitsRow) {
+	if limit < 0 || limit > watchlistMaxHits {
+		limit = watchlistMaxHits
+	}
+	hits, err := q.GetWatchlistHits(ctx, dbgen.GetWatchlistHitsParams{CurrentOnly: true, FlaggedOnly: true, Limit: limit})
+	if err != nil {
+		slog.Warn("load watchlist hits", "error", err)
+		return nil, nil
+	}
+	flagged := make(map[int64]string, len(hits))
+	for _, h := range hits {
+		if flagged[h.EventID] != "" {
+			flagged[h.EventID] += ", "
+		}
+		flagged[h.EventID] += h.WatchlistName
+	}
+	return flagged, hits
+}
+
+// HandleWatchlistsAPI lists the watchlists
+func (s *Server) HandleWatchlistsAPI(w http.ResponseWriter, r *http.Request) {
+	lists, err := dbgen.New(s.DB).GetWatchlists(r.Context())
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	out := make([]watchlistView, len(lists))
+	for i, l := range lists {
+		out[i] = newWatchlistView(l)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// HandleWatchlistAPI returns a watchlist with its entries
+func (s *Server) HandleWatchlistAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid watchlist id", http.StatusBadRequest)
+		return
+	}
+	q := dbgen.New(s.DB)
+	lists, err := q.GetWatchlists(r.Context())
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	for _, l := range lists {
+		if l.ID != id {
+			continue
+		}
+		entries, err := q.GetWatchlistEntries(r.Context(), id)
+		if err != nil {
+			s.jsonError(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		v := newWatchlistView(l)
+		v.EntryList = newWatchlistEntryViews(entries, time.Now())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+		return
+	}
+	s.jsonError(w, "watchlist not found", http.StatusNotFound)
+}
+
+// HandleCreateWatchlistAPI creates a watchlist
+func (s *Server) HandleCreateWatchlistAPI(w http.ResponseWriter, r *http.Request) {
+	var c watchlistChange
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		s.jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	l, status, err := s.createWatchlist(r.Context(), c)
+	if err != nil {
+		s.jsonError(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newWatchlistView(dbgen.GetWatchlistsRow{
+		ID: l.ID, Name: l.Name, WebhookUrl: l.WebhookUrl, EmailTo: l.EmailTo, Flag: l.Flag, Enabled: l.Enabled, CreatedAt: l.CreatedAt,
+	}))
+}
+
+// HandleUpdateWatchlistAPI changes the fields of a watchlist present in the
+// body
+func (s *Server) HandleUpdateWatchlistAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid watchlist id", http.StatusBadRequest)
+		return
+	}
+	var c watchlistChange
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		s.jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if status, err := s.updateWatchlist(r.Context(), id, c); err != nil {
+		s.jsonError(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "id": id})
+}
+
+// HandleDeleteWatchlistAPI deletes a watchlist with its entries and hits
+func (s *Server) HandleDeleteWatchlistAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid watchlist id", http.StatusBadRequest)
+		return
+	}
+	ok, err := s.deleteWatchlist(r.Context(), id)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		s.jsonError(w, "watchlist not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "id": id})
+}
+
+// HandleAddWatchlistEntryAPI adds a plate to a watchlist from {"plate",
+// "note", "expires"}; an existing plate gets the new note and expiry
+func (s *Server) HandleAddWatchlistEntryAPI(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.jsonError(w, "invalid watchlist id", http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		Plate   string `json:"plate"`
+		Note    string `json:"note"`
+		Expires string `json:"expires"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	e, status, err := s.addWatchlistEntry(r.Context(), id, body.Plate, body.Note, body.Expires, sessionUser(r))
+	if err != nil {
+		s.jsonError(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
+
+// HandleDeleteWatchlistEntryAPI removes a plate from a watchlist
+func (s *Server) HandleDeleteWatchlistEntryAPI(w http.ResponseWriter, r *http.Request) {
+	id, err1 := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	entryID, err2 := strconv.ParseInt(r.PathValue("entry"), 10, 64)
+	if err1 != nil || err2 != nil {
+		s.jsonError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	ok, err := s.deleteWatchlistEntry(r.Context(), id, entryID)
+	if err != nil {
+		s.jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		s.jsonError(w, "entry not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "id": entryID})
+}
+
+// HandleWatchlistHitsAPI returns watchlist hits, newest first
+func (s *Server) HandleWatchlistHitsAPI(w http.ResponseWriter, r *http.Request) {
+	hits, err := s.watchlistHits(r)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hits)
+}
+
+// HandleWatchlists shows the watchlists page
+func (s *Server) HandleWatchlists(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	lists, err := q.GetWatchlists(r.Context())
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	views := make([]watchlistView, len(lists))
+	for i, l := range lists {
+		views[i] = newWatchlistView(l)
+		entries, err := q.GetWatchlistEntries(r.Context(), l.ID)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		views[i].EntryList = newWatchlistEntryViews(entries, now)
+	}
+	hits, err := s.watchlistHits(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data := struct {
+		Lists []watchlistView
+		Hits  []dbgen.GetWatchlistHitsRow
+		Email bool
+	}{
+		Lists: views,
+		Hits:  hits,
+		Email: s.Email != nil,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.renderTemplate(w, "watchlists.html", data); err != nil {
+		slog.Warn("render template", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// watchlistForm reads a list's fields from the watchlists page
+func watchlistForm(r *http.Request) watchlistChange {
+	name, webhook := r.FormValue("name"), r.FormValue("webhook_url")
+	to := []string{r.FormValue("email_to")}
+	flag, enabled := r.FormValue("flag") != "", r.FormValue("enabled") != ""
+	c := watchlistChange{Name: &name, WebhookURL: &webhook, EmailTo: &to, Flag: &flag}
+	if r.FormValue("update") != "" {
+		c.Enabled = &enabled
+	}
+	return c
+}
+
+// HandleCreateWatchlist creates a watchlist from the watchlists page
+func (s *Server) HandleCreateWatchlist(w http.ResponseWriter, r *http.Request) {
+	if _, status, err := s.createWatchlist(r.Context(), watchlistForm(r)); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	http.Redirect(w, r, "/watchlists", http.StatusSeeOther)
+}
+
+// HandleWatchlistAction updates or deletes a watchlist from the watchlists
+// page
+func (s *Server) HandleWatchlistAction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid watchlist id", http.StatusBadRequest)
+		return
+	}
+	switch r.PathValue("action") {
+	case "update":
+		if status, err := s.updateWatchlist(r.Context(), id, watchlistForm(r)); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+	case "delete":
+		if _, err := s.deleteWatchlist(r.Context(), id); err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "unknown action", http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, "/watchlists", http.StatusSeeOther)
+}
+
+// HandleAddWatchlistEntry adds a plate from the watchlists page
+func (s *Server) HandleAddWatchlistEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid watchlist id", http.StatusBadRequest)
+		return
+	}
+	if _, status, err := s.addWatchlistEntry(r.Context(), id, r.FormValue("plate"), r.FormValue("note"), r.FormValue("expires"), sessionUser(r)); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	http.Redirect(w, r, "/watchlists", http.StatusSeeOther)
+}
+
+// HandleDeleteWatchlistEntry removes a plate from the watchlists page
+func (s *Server) HandleDeleteWatchlistEntry(w http.ResponseWriter, r *http.Request) {
+	id, err1 := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	entryID, err2 := strconv.ParseInt(r.PathValue("entry"), 10, 64)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.deleteWatchlistEntry(r.Context(), id, entryID); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/watchlists", http.StatusSeeOther)
+}
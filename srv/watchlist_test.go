@@ -0,0 +1,176 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestWatchlists(t *testing.T) {
+	s := newTestServer(t)
+	dir := s.DataDir
+	port, received := testSMTP(t)
+	cfgPath := filepath.Join(dir, "email.json")
+	os.WriteFile(cfgPath, []byte(`{"smtp": {"host": "127.0.0.1", "port": `+strconv.Itoa(port)+`, "from": "lpr@example.com", "tls": "none"}}`), 0o644)
+	email, err := LoadEmailConfig(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := make(chan watchlistHitMessage, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg watchlistHitMessage
+		json.NewDecoder(r.Body).Decode(&msg)
+		hooks <- msg
+	}))
+	defer hook.Close()
+	s.PublicURL = "https://lpr.example.com"
+	s.Email = email
+	ctx := context.Background()
+
+	call := func(method, target, body string, handler http.HandlerFunc, pathValues ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for i := 0; i+1 < len(pathValues); i += 2 {
+			req.SetPathValue(pathValues[i], pathValues[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+	rec := call("POST", "/api/watchlists", `{"name": "stolen", "webhook_url": "`+hook.URL+`", "email_to": ["police@example.com"]}`, s.HandleCreateWatchlistAPI)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	var list watchlistView
+	json.NewDecoder(rec.Body).Decode(&list)
+	if !list.Flag || !list.Enabled {
+		t.Errorf("new list %+v", list)
+	}
+	id := strconv.FormatInt(list.ID, 10)
+	if rec := call("POST", "/api/watchlists", `{"name": "stolen"}`, s.HandleCreateWatchlistAPI); rec.Code != http.StatusConflict {
+		t.Errorf("duplicate name: %d", rec.Code)
+	}
+	for _, bad := range []string{`{"name": ""}`, `{"name": "x", "webhook_url": "ftp://x"}`, `{"name": "x", "email_to": ["nobody"]}`} {
+		if rec := call("POST", "/api/watchlists", bad, s.HandleCreateWatchlistAPI); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d", bad, rec.Code)
+		}
+	}
+	for _, e := range []string{`{"plate": "ab-123 cd", "note": "reported 3 May"}`, `{"plate": "XY9*"}`, `{"plate": "OLD1", "expires": "2020-01-01"}`} {
+		if rec := call("POST", "/api/watchlists/"+id+"/entries", e, s.HandleAddWatchlistEntryAPI, "id", id); rec.Code/100 != 2 {
+			t.Errorf("add %s: %d %s", e, rec.Code, rec.Body)
+		}
+	}
+	if rec := call("POST", "/api/watchlists/"+id+"/entries", `{"plate": "**"}`, s.HandleAddWatchlistEntryAPI, "id", id); rec.Code != http.StatusBadRequest {
+		t.Errorf("wildcards only: %d", rec.Code)
+	}
+
+	ingest := func(plate string) int64 {
+		t.Helper()
+		res, err := s.ingestEvent(ctx, newIngestRequest([]byte(`{"plateUTF8":"`+plate+`","sensorProviderID":"gate"}`), "", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.ID
+	}
+	stolen := ingest("AB 123 CD")
+	msg := <-hooks
+	if msg.Watchlist != "stolen" || msg.Entry != "AB123CD" || msg.Note != "reported 3 May" || msg.EventID != stolen ||
+		msg.Camera != "gate" || msg.URL != "https://lpr.example.com/event/"+strconv.FormatInt(stolen, 10) {
+		t.Errorf("webhook %+v", msg)
+	}
+	var mailed *mail.Message
+	select {
+	case mailed = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email")
+	}
+	if mailed.Header.Get("Subject") != "stolen: AB 123 CD at gate" || mailed.Header.Get("To") != "police@example.com" {
+		t.Errorf("email headers %v", mailed.Header)
+	}
+	s.background.Wait()
+	ingest("OLD1")
+	ingest("CD456EF")
+	wildcard := ingest("XY987")
+	ingest("AB123CD") // within the cooldown
+	s.background.Wait()
+
+	q := dbgen.New(s.DB)
+	hits, err := q.GetWatchlistHits(ctx, dbgen.GetWatchlistHitsParams{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 3 {
+		t.Fatalf("hits %+v", hits)
+	}
+	if h := hits[0]; h.Plate != "AB123CD" || deref(h.WebhookStatus) != "cooldown" || deref(h.EmailStatus) != "cooldown" {
+		t.Errorf("cooldown hit %+v", h)
+	}
+	if h := hits[1]; h.EventID != wildcard || h.Pattern != "XY9*" || deref(h.WebhookStatus) != "sent" {
+		t.Errorf("wildcard hit %+v", h)
+	}
+	if h := hits[2]; h.EventID != stolen || deref(h.WebhookStatus) != "sent" || deref(h.EmailStatus) != "sent" {
+		t.Errorf("first hit %+v", h)
+	}
+	<-hooks
+	<-received
+
+	// The dashboard flags the current reads on flagged lists only
+	flagged, _ := s.flaggedHits(ctx, q, 100)
+	if flagged[stolen] != "stolen" || flagged[wildcard] != "stolen" {
+		t.Errorf("flagged %v", flagged)
+	}
+	if rec := call("PATCH", "/api/watchlists/"+id, `{"flag": false, "webhook_url": ""}`, s.HandleUpdateWatchlistAPI, "id", id); rec.Code != http.StatusOK {
+		t.Errorf("update: %d %s", rec.Code, rec.Body)
+	}
+	if flagged, _ := s.flaggedHits(ctx, q, 100); len(flagged) != 0 {
+		t.Errorf("flagged after unflagging %v", flagged)
+	}
+	rec = call("GET", "/api/watchlists/hits?plate=XY*&watchlist="+id, "", s.HandleWatchlistHitsAPI)
+	if !strings.Contains(rec.Body.String(), `"XY987"`) || strings.Contains(rec.Body.String(), "AB123CD") {
+		t.Errorf("hits by plate %s", rec.Body)
+	}
+
+	rec = call("GET", "/api/watchlists/"+id, "", s.HandleWatchlistAPI, "id", id)
+	var got watchlistView
+	json.NewDecoder(rec.Body).Decode(&got)
+	if got.Flag || got.WebhookURL != "" || len(got.EntryList) != 3 || got.Hits != 3 {
+		t.Errorf("list %+v", got)
+	}
+	for _, e := range got.EntryList {
+		if e.Expired != (e.Plate == "OLD1") {
+			t.Errorf("entry %+v", e)
+		}
+		if e.Plate == "XY9*" {
+			entry := strconv.FormatInt(e.ID, 10)
+			if rec := call("DELETE", "/", "", s.HandleDeleteWatchlistEntryAPI, "id", id, "entry", entry); rec.Code != http.StatusOK {
+				t.Errorf("delete entry: %d", rec.Code)
+			}
+		}
+	}
+	ingest("XY999")
+	if hits, _ := q.GetWatchlistHits(ctx, dbgen.GetWatchlistHitsParams{Limit: 10}); len(hits) != 3 {
+		t.Errorf("hit after removing the entry: %+v", hits)
+	}
+
+	if rec := call("DELETE", "/", "", s.HandleDeleteWatchlistAPI, "id", id); rec.Code != http.StatusOK {
+		t.Errorf("delete: %d", rec.Code)
+	}
+	if rec := call("DELETE", "/", "", s.HandleDeleteWatchlistAPI, "id", id); rec.Code != http.StatusNotFound {
+		t.Errorf("delete again: %d", rec.Code)
+	}
+	rec = call("GET", "/api/watchlists", "", s.HandleWatchlistsAPI)
+	if body, _ := io.ReadAll(rec.Body); strings.TrimSpace(string(body)) != "[]" {
+		t.Errorf("lists after delete %s", body)
+	}
+}
@@ -0,0 +1,322 @@
+package srv
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// GET /ws pushes newly recorded events over a WebSocket for wall displays
+// and integrations that can't keep an SSE connection (GET /api/events/stream)
+// open. It takes the same filters as query parameters, plus thumbnails=1
+// to list each event's images with /image/{id}/thumb URLs. Every message is
+// a JSON object with a type:
+//
+//	{"type":"subscribed","filter":{...}}     after connecting and every subscribe
+//	{"type":"event","event":{...}}           a matching event (export format)
+//	{"type":"dropped","count":3}             events skipped for a slow client
+//	{"type":"error","message":"..."}         a rejected subscribe message
+//
+// Clients change their filter without reconnecting by sending
+//
+//	{"type":"subscribe","filter":{"plate_prefix":"AB","camera":"CAM1"},"thumbnails":true}
+//
+// Only the small subset of RFC 6455 the server needs is implemented: text
+// messages, ping/pong and close, no extensions or fragmented client messages.
+
+const (
+	wsGUID          = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxClientSize = 64 << 10
+	wsWriteTimeout  = 10 * time.Second
+
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// wsCloseGoingAway is the close frame payload sent when the server shuts
+// down (status 1001)
+var wsCloseGoingAway = []byte{0x03, 0xE9}
+
+// wsConn is a server-side WebSocket connection. Writes are serialized so the
+// read loop can answer pings while events are pushed.
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex
+}
+
+// upgradeWebSocket performs the opening handshake and takes over the
+// connection
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return nil, errors.New("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// The connection outlives the server's read and write timeouts
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+// headerHasToken reports whether a comma-separated header contains token
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeFrame sends one unmasked, unfragmented frame
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	header := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	c.rw.Write(header)
+	c.rw.Write(payload)
+	return c.rw.Flush()
+}
+
+// writeJSON sends v as a text message
+func (c *wsConn) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsOpText, data)
+}
+
+// readFrame reads one client frame and unmasks its payload
+func (c *wsConn) readFrame() (op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	if head[0]&0x80 == 0 {
+		return 0, nil, errors.New("fragmented messages are not supported")
+	}
+	if head[1]&0x80 == 0 {
+		return 0, nil, errors.New("client frame is not masked")
+	}
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxClientSize {
+		return 0, nil, fmt.Errorf("client frame of %d bytes is too large", n)
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return head[0] & 0x0F, payload, nil
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+// wsSubscribe is a client message changing the subscription
+type wsSubscribe struct {
+	Type       string            `json:"type"`
+	Filter     map[string]string `json:"filter"` // same keys as the query parameters
+	Thumbnails bool              `json:"thumbnails"`
+}
+
+// wsEvent is an event pushed to a WebSocket client
+type wsEvent struct {
+	Type  string      `json:"type"`
+	Event ExportEvent `json:"event"`
+}
+
+// wsFilter is the active subscription as reported to the client
+type wsFilter struct {
+	eventFilter
+	Thumbnails bool `json:"thumbnails"`
+}
+
+// HandleWebSocket streams matching events to a WebSocket client as they are
+// recorded
+func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r.URL.Query())
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	thumbnails := r.URL.Query().Get("thumbnails") == "1" || r.URL.Query().Get("thumbnails") == "true"
+
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		s.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer ws.Close()
+
+	sub := s.subscribers.subscribe(filter)
+	defer s.subscribers.unsubscribe(sub)
+	slog.Info("websocket subscribed", "remote", r.RemoteAddr, "filter", filter, "thumbnails", thumbnails)
+
+	// Subscription changes arrive on the read loop; the write loop below
+	// owns the thumbnails setting
+	changes := make(chan bool, 1)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			op, payload, err := ws.readFrame()
+			if err != nil {
+				return
+			}
+			switch op {
+			case wsOpClose:
+				ws.writeFrame(wsOpClose, nil)
+				return
+			case wsOpPing:
+				ws.writeFrame(wsOpPong, payload)
+			case wsOpText:
+				var msg wsSubscribe
+				if err := json.Unmarshal(payload, &msg); err != nil || msg.Type != "subscribe" {
+					ws.writeJSON(map[string]string{"type": "error", "message": `expected {"type":"subscribe","filter":{...}}`})
+					continue
+				}
+				q := url.Values{}
+				for k, v := range msg.Filter {
+					q.Set(k, v)
+				}
+				f, err := parseEventFilter(q)
+				if err != nil {
+					ws.writeJSON(map[string]string{"type": "error", "message": err.Error()})
+					continue
+				}
+				s.subscribers.setFilter(sub, f)
+				select {
+				case <-changes:
+				default:
+				}
+				changes <- msg.Thumbnails
+				ws.writeJSON(map[string]any{"type": "subscribed", "filter": wsFilter{f, msg.Thumbnails}})
+			}
+		}
+	}()
+
+	if err := ws.writeJSON(map[string]any{"type": "subscribed", "filter": wsFilter{filter, thumbnails}}); err != nil {
+		return
+	}
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	base := baseURL(r)
+	for {
+		select {
+		case <-closed:
+			slog.Info("websocket closed", "remote", r.RemoteAddr)
+			return
+		case <-s.stopping.done():
+			ws.writeFrame(wsOpClose, wsCloseGoingAway)
+			return
+		case thumbnails = <-changes:
+			continue
+		case e := <-sub.events:
+			if n := s.subscribers.takeDropped(sub); n > 0 {
+				if err := ws.writeJSON(map[string]any{"type": "dropped", "count": n}); err != nil {
+					return
+				}
+			}
+			if thumbnails {
+				e.Images = s.eventThumbnails(r, base, e.ID)
+			}
+			err = ws.writeJSON(wsEvent{Type: "event", Event: e})
+		case <-keepAlive.C:
+			err = ws.writeFrame(wsOpPing, nil)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// eventThumbnails lists the images of an event with absolute image and
+// thumbnail URLs
+func (s *Server) eventThumbnails(r *http.Request, base string, eventID int64) []ExportImage {
+	rows, err := dbgen.New(s.DB).GetImagesByEventIDs(r.Context(), []int64{eventID})
+	if err != nil {
+		slog.Warn("load images for websocket", "id", eventID, "error", err)
+		return nil
+	}
+	var images []ExportImage
+	for _, img := range rows {
+		images = append(images, ExportImage{
+			ID:       img.ID,
+			Type:     img.ImageType,
+			Filename: img.Filename,
+			URL:      fmt.Sprintf("%s/image/%d", base, img.ID),
+			ThumbURL: fmt.Sprintf("%s/image/%d/thumb", base, img.ID),
+		})
+	}
+	return images
+}
@@ -0,0 +1,130 @@
+package srv
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsTestClient is a minimal WebSocket client for the tests
+type wsTestClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialWS(t *testing.T, serverURL, path string) *wsTestClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(serverURL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	key := base64.StdEncoding.EncodeToString([]byte("the sample nonce")) // RFC 6455 example
+	io.WriteString(conn, "GET "+path+" HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: "+key+"\r\n\r\n")
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake: %s, accept %q", resp.Status, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	return &wsTestClient{conn: conn, r: r}
+}
+
+func (c *wsTestClient) send(t *testing.T, msg string) {
+	t.Helper()
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x81, 0x80 | 126}
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(msg)))
+	frame = append(frame, mask...)
+	for i := range len(msg) {
+		frame = append(frame, msg[i]^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *wsTestClient) read(t *testing.T) map[string]any {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := int(head[1] & 0x7F)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(c.r, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatal(err)
+	}
+	var msg map[string]any
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		t.Fatalf("message %q: %v", payload, err)
+	}
+	return msg
+}
+
+func TestWebSocket(t *testing.T) {
+	s := newTestServer(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ws", s.HandleWebSocket)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c := dialWS(t, ts.URL, "/ws?plate_prefix=ab&thumbnails=1")
+	if msg := c.read(t); msg["type"] != "subscribed" || msg["filter"].(map[string]any)["plate"] != "AB*" {
+		t.Fatalf("first message = %v", msg)
+	}
+	ingest := func(body string) {
+		t.Helper()
+		if _, err := s.ingest(t.Context(), newIngestRequest([]byte(body), "", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ingest(`{"plateUTF8":"XY1"}`)
+	ingest(`{"plateUTF8":"AB1","sensorProviderID":"CAM1"}`)
+	msg := c.read(t)
+	event, _ := msg["event"].(map[string]any)
+	if msg["type"] != "event" || event["plate"] != "AB1" {
+		t.Fatalf("event message = %v", msg)
+	}
+
+	// Resubscribe to another camera without reconnecting
+	c.send(t, `{"type":"subscribe","filter":{"camera":"CAM2"}}`)
+	if msg := c.read(t); msg["type"] != "subscribed" {
+		t.Fatalf("resubscribe = %v", msg)
+	}
+	ingest(`{"plateUTF8":"AB2","sensorProviderID":"CAM1"}`)
+	ingest(`{"plateUTF8":"ZZ3","sensorProviderID":"CAM2"}`)
+	if msg := c.read(t); msg["event"].(map[string]any)["plate"] != "ZZ3" {
+		t.Errorf("after resubscribe got %v", msg)
+	}
+	c.send(t, `{"type":"subscribe","filter":{"plate":"["}}`)
+	if msg := c.read(t); msg["type"] != "error" {
+		t.Errorf("bad filter = %v", msg)
+	}
+
+	resp, err := http.Get(ts.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain GET: status %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,373 @@
+package srv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Vaxtor and other XML cameras post their reads as XML (Axis event
+// notifications are first rewritten by axis.go). An XML body or multipart
+// part is turned into event JSON much like a URL-encoded form: the
+// document is flattened, every leaf element and attribute becomes a key, and the names these schemas use are mapped to
+// the camera keys in ingestFields, ignoring namespaces, case, _ and -:
+//
+//	<ALPR>
+//	  <Plate confidence="93">B AB 123</Plate><Country>D</Country>
+//	  <Vehicle><Make>VW</Make><Model>Golf</Model><Color>white</Color></Vehicle>
+//	  <Camera><SerialNumber>ACCC8E012345</SerialNumber><IP>10.0.0.7</IP></Camera>
+//	  <PlateImage format="jpg">/9j/4AAQ...</PlateImage>
+//	</ALPR>
+//
+// The first value of a key wins. Elements named like an image whose text
+// (or data/base64 child) is a base64 JPEG or PNG go to ImageArray, typed
+// by their name or type attribute. Unknown leaves keep their element name,
+// so nothing the camera sent is lost from the stored JSON.
+
+// xmlKeys maps XML element and attribute names (lower case, without _ and
+// -) to the camera key they are read as. Names qualified by a parent from
+// xmlParents take precedence.
+var xmlKeys = map[string]formKey{
+	"plate":              {"plateUTF8", "string"},
+	"platenumber":        {"plateUTF8", "string"},
+	"platestring":        {"plateUTF8", "string"},
+	"licenseplate":       {"plateUTF8", "string"},
+	"licenseplatenumber": {"plateUTF8", "string"},
+	"country":            {"plateCountry", "string"},
+	"countrycode":        {"plateCountry", "string"},
+	"region":             {"plateRegion", "string"},
+	"regioncode":         {"plateRegionCode", "string"},
+	"confidence":         {"plateConfidence", "number as string"},
+	"ocrconfidence":      {"plateConfidence", "number as string"},
+	"ocrscore":           {"plateConfidence", "number as string"},
+	"trackid":            {"carID", "string"},
+	"vehicleid":          {"carID", "string"},
+	"eventstate":         {"carState", "string"},
+	"eventtime":          {"datetime", "timestamp"},
+	"capturetime":        {"datetime", "timestamp"},
+	"timestamp":          {"capture_timestamp", "timestamp"},
+	"utctime":            {"capture_timestamp", "timestamp"},
+	"make":               {"vehicle_info.make", "string"},
+	"brand":              {"vehicle_info.make", "string"},
+	"manufacturer":       {"vehicle_info.make", "string"},
+	"model":              {"vehicle_info.model", "string"},
+	"color":              {"vehicle_info.color", "string"},
+	"colour":             {"vehicle_info.color", "string"},
+	"vehiclecolor":       {"vehicle_info.color", "string"},
+	"vehicletype":        {"vehicle_info.type", "string"},
+	"vehicleclass":       {"vehicle_info.type", "string"},
+	"mmrconfidence":      {"vehicle_info.confidenceMMR", "string"},
+	"makeconfidence":     {"vehicle_info.confidenceMMR", "string"},
+	"colorconfidence":    {"vehicle_info.confidenceColor", "string"},
+	"serial":             {"camera_info.SerialNumber", "string"},
+	"serialnumber":       {"camera_info.SerialNumber", "string"},
+	"serialno":           {"camera_info.SerialNumber", "string"},
+	"cameraserial":       {"camera_info.SerialNumber", "string"},
+	"deviceserial":       {"camera_info.SerialNumber", "string"},
+	"ip":                 {"camera_info.IPAddress", "string"},
+	"ipaddress":          {"camera_info.IPAddress", "string"},
+	"cameraip":           {"camera_info.IPAddress", "string"},
+	"cameraname":         {"sensorProviderID", "string"},
+	"devicename":         {"sensorProviderID", "string"},
+	"cameraid":           {"sensorProviderID", "string"},
+	"sequence":           {"packetCounter", "string"},
+	"sequencenumber":     {"packetCounter", "string"},
+	"laneno":             {"lane", "integer as string"},
+	"lanenumber":         {"lane", "integer as string"},
+	"laneid":             {"lane", "integer as string"},
+	"movingdirection":    {"direction", "string"},
+	"lat":                {"geotag.lat", "number"},
+	"latitude":           {"geotag.lat", "number"},
+	"lon":                {"geotag.lon", "number"},
+	"lng":                {"geotag.lon", "number"},
+	"longitude":          {"geotag.lon", "number"},
+
+	"vehicle_info.type":       {"vehicle_info.type", "string"},
+	"vehicle_info.class":      {"vehicle_info.type", "string"},
+	"vehicle_info.confidence": {"vehicle_info.confidenceMMR", "string"},
+	"camera_info.name":        {"sensorProviderID", "string"},
+	"camera_info.id":          {"sensorProviderID", "string"},
+}
+
+// xmlParents maps parent element names to the JSON object their children
+// belong to
+var xmlParents = map[string]string{
+	"vehicle":     "vehicle_info",
+	"vehicleinfo": "vehicle_info",
+	"mmr":         "vehicle_info",
+	"camera":      "camera_info",
+	"camerainfo":  "camera_info",
+	"device":      "camera_info",
+	"geotag":      "geotag",
+	"gps":         "geotag",
+	"location":    "geotag",
+	"position":    "geotag",
+}
+
+// xmlDataNames are the children of an image element holding its base64
+var xmlDataNames = []string{"data", "binaryimage", "base64", "content", "imagedata"}
+
+// isXMLType reports whether a Content-Type is an XML media type
+func isXMLType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// utf8BOM starts the XML of some Windows-based camera software
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// looksLikeXML reports whether a body is XML rather than JSON, which
+// never starts with <
+func looksLikeXML(data []byte) bool {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	return bytes.HasPrefix(bytes.TrimLeftFunc(data, unicode.IsSpace), []byte("<"))
+}
+
+// xmlNode is an element of an XML event
+type xmlNode struct {
+	name     string // local name, without namespace
+	attrs    []xml.Attr
+	text     []byte
+	children []*xmlNode
+}
+
+// xmlName normalizes an element or attribute name for lookups
+func xmlName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+// parseXMLTree reads the element tree of an XML document
+func parseXMLTree(data []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+	dec.CharsetReader = xmlCharsetReader
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name.Local, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				top := stack[len(stack)-1]
+				top.text = append(top.text, t...)
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
+
+// xmlCharsetReader decodes the Latin-1 documents some camera firmware
+// declares besides UTF-8
+func xmlCharsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(label) {
+	case "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1", "windows-1252", "cp1252":
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", label)
+	}
+	var buf bytes.Buffer
+	r := bufio.NewReader(input)
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return &buf, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteRune(rune(b))
+	}
+}
+
+// xmlJSON converts an XML event into event JSON
+func xmlJSON(data []byte) ([]byte, error) {
+	root, err := parseXMLTree(data)
+	if err != nil {
+		return nil, &payloadError{"invalid XML: " + err.Error()}
+	}
+	return xmlTreeJSON(root)
+}
+
+// xmlTreeJSON flattens an XML element tree into event JSON
+func xmlTreeJSON(root *xmlNode) ([]byte, error) {
+	event := map[string]any{}
+	var images []embeddedImage
+	var walk func(n *xmlNode, parent string)
+	walk = func(n *xmlNode, parent string) {
+		if img, ok := xmlImage(n); ok {
+			images = append(images, img)
+			return
+		}
+		for _, a := range n.attrs {
+			if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" || a.Name.Space == "xsi" {
+				continue
+			}
+			setXMLValue(event, a.Name.Local, n.name, a.Value)
+		}
+		if text := strings.TrimSpace(string(n.text)); len(n.children) == 0 && text != "" {
+			setXMLValue(event, n.name, parent, text)
+		}
+		for _, c := range n.children {
+			walk(c, n.name)
+		}
+	}
+	walk(root, "")
+	if len(images) > 0 {
+		event["ImageArray"] = images
+	}
+	if len(event) == 0 {
+		return nil, &payloadError{"empty XML event"}
+	}
+	return json.Marshal(event)
+}
+
+// setXMLValue stores an element or attribute value under its camera key,
+// unless that key already has one
+func setXMLValue(event map[string]any, name, parent, value string) {
+	key, typ := name, "string"
+	if k, ok := xmlKey(name, parent); ok {
+		key, typ = k.key, k.typ
+	}
+	if hasEventKey(event, key) {
+		return
+	}
+	var v any = value
+	if typ == "number" {
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			v = n
+		}
+	}
+	setEventKey(event, key, v)
+}
+
+// xmlKey looks up the camera key of an element or attribute name
+func xmlKey(name, parent string) (formKey, bool) {
+	norm := xmlName(name)
+	var lookups []string
+	if obj, ok := xmlParents[xmlName(parent)]; ok {
+		lookups = append(lookups, obj+"."+norm)
+	}
+	lookups = append(lookups, norm, strings.ToLower(name))
+	for _, l := range lookups {
+		if k, ok := xmlKeys[l]; ok {
+			return k, true
+		}
+		if k, ok := formKeys[l]; ok {
+			return k, true
+		}
+	}
+	return formKey{}, false
+}
+
+// hasEventKey reports whether a dotted key of event JSON is set
+func hasEventKey(event map[string]any, key string) bool {
+	obj := event
+	parts := strings.Split(key, ".")
+	for _, p := range parts[:len(parts)-1] {
+		child, ok := obj[p].(map[string]any)
+		if !ok {
+			_, taken := obj[p]
+			return taken
+		}
+		obj = child
+	}
+	_, ok := obj[parts[len(parts)-1]]
+	return ok
+}
+
+// xmlImage reads an image element: its text, or a data child, must be a
+// base64 JPEG or PNG
+func xmlImage(n *xmlNode) (embeddedImage, bool) {
+	name := xmlName(n.name)
+	if !strings.Contains(name, "image") && !strings.Contains(name, "picture") && !strings.Contains(name, "snapshot") && !strings.Contains(name, "photo") {
+		return embeddedImage{}, false
+	}
+	data := string(n.text)
+	var typ, format string
+	for _, a := range n.attrs {
+		switch xmlName(a.Name.Local) {
+		case "type", "imagetype":
+			typ = a.Value
+		case "format", "imageformat":
+			format = a.Value
+		}
+	}
+	for _, c := range n.children {
+		switch cn := xmlName(c.name); {
+		case cn == "type" || cn == "imagetype":
+			typ = strings.TrimSpace(string(c.text))
+		case cn == "format" || cn == "imageformat":
+			format = strings.TrimSpace(string(c.text))
+		default:
+			for _, d := range xmlDataNames {
+				if cn == d {
+					data = string(c.text)
+				}
+			}
+		}
+	}
+	// Long base64 is wrapped over lines
+	data = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, data)
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || imageExtension(raw) == "" {
+		return embeddedImage{}, false
+	}
+	if format == "" {
+		format = strings.TrimPrefix(imageExtension(raw), ".")
+	}
+	return embeddedImage{ImageType: xmlImageType(typ, n.name), ImageFormat: strings.ToLower(format), BinaryImage: data}, true
+}
+
+// xmlImageType tells plate crops and overview frames apart by the type
+// the camera gave, else the element name
+func xmlImageType(typ, name string) string {
+	for _, v := range []string{typ, name} {
+		v = strings.ToLower(v)
+		switch {
+		case strings.Contains(v, "lpup"), strings.Contains(v, "plate"):
+			return "plate"
+		case strings.Contains(v, "roi"), strings.Contains(v, "vehicle"), strings.Contains(v, "overview"),
+			strings.Contains(v, "scene"), strings.Contains(v, "context"):
+			return "vehicle"
+		}
+	}
+	return strings.ToLower(typ)
+}
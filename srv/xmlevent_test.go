@@ -0,0 +1,112 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXMLJSON(t *testing.T) {
+	for _, tt := range []struct {
+		xml  string
+		want string
+	}{
+		{`<?xml version="1.0"?>
+<ALPR xmlns="http://www.vaxtor.com/alpr">
+  <Plate confidence="93">B AB 123</Plate><Country>D</Country><Lane>2</Lane>
+  <Vehicle><Make>VW</Make><Type>car</Type><Confidence>80</Confidence></Vehicle>
+  <Camera><Serial_Number>ACCC8E012345</Serial_Number><IP>10.0.0.7</IP><Name>Gate</Name></Camera>
+  <GPS><Latitude>52.5</Latitude><Longitude>13.4</Longitude></GPS>
+</ALPR>`,
+			`{"plateUTF8":"B AB 123","plateConfidence":"93","plateCountry":"D","lane":"2",
+			  "vehicle_info":{"make":"VW","type":"car","confidenceMMR":"80"},
+			  "camera_info":{"SerialNumber":"ACCC8E012345","IPAddress":"10.0.0.7"},"sensorProviderID":"Gate",
+			  "geotag":{"lat":52.5,"lon":13.4}}`},
+		// Camera keys are read as they are; the first value wins
+		{`<event carID="7"><plateUTF8>XY1</plateUTF8><plate_number>ignored</plate_number><capture_timestamp>1700000000</capture_timestamp></event>`,
+			`{"carID":"7","plateUTF8":"XY1","capture_timestamp":"1700000000"}`},
+		// Latin-1 is decoded
+		{"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><r><make>Citro\xebn</make></r>",
+			`{"vehicle_info":{"make":"Citroën"}}`},
+	} {
+		got, err := xmlJSON([]byte(tt.xml))
+		if err != nil {
+			t.Errorf("%s: %v", tt.xml, err)
+			continue
+		}
+		var a, b any
+		json.Unmarshal(got, &a)
+		json.Unmarshal([]byte(tt.want), &b)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.xml, got, tt.want)
+		}
+	}
+	for _, bad := range []string{`<a><b></a>`, `<empty/>`, `{"plateUTF8":"A"}`} {
+		if _, err := xmlJSON([]byte(bad)); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+func TestIngestXML(t *testing.T) {
+	s := newTestServer(t)
+	var pic bytes.Buffer
+	png.Encode(&pic, image.NewGray(image.Rect(0, 0, 8, 8)))
+	b64 := base64.StdEncoding.EncodeToString(pic.Bytes())
+	wrapped := b64[:20] + "\n    " + b64[20:]
+
+	post := func(contentType string, body []byte) {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/api", bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		s.HandleAPI(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST /api %s = %d %s", contentType, w.Code, w.Body)
+		}
+	}
+	post("text/xml; charset=utf-8", []byte(`<ALPR><PlateNumber>XML1</PlateNumber><Brand>Skoda</Brand>
+		<PlateImage format="png">`+wrapped+`</PlateImage>
+		<Image><Type>overview</Type><Data>`+b64+`</Data></Image>
+		<ImageFile>/var/lpr/1.jpg</ImageFile></ALPR>`))
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, _ := mw.CreateFormFile("event", "read.xml")
+	fw.Write([]byte(`<ALPR><Plate>XML2</Plate></ALPR>`))
+	fw, _ = mw.CreateFormFile("image", "lpup.png")
+	fw.Write(pic.Bytes())
+	mw.Close()
+	post(mw.FormDataContentType(), form.Bytes())
+
+	rows, err := s.DB.Query(`SELECT e.plate_utf8, COALESCE(e.vehicle_make, ''), e.raw_json, e.json_filename,
+		(SELECT GROUP_CONCAT(image_type) FROM (SELECT image_type FROM images WHERE event_id = e.id ORDER BY id))
+		FROM events e ORDER BY e.id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var plate, vMake, rawJSON, jsonFile, types string
+		rows.Scan(&plate, &vMake, &rawJSON, &jsonFile, &types)
+		got = append(got, plate+" "+vMake+" "+types)
+		if !strings.Contains(rawJSON, `"plateUTF8"`) || !strings.HasSuffix(jsonFile, ".json") {
+			t.Errorf("%s stored as %s: %s", plate, jsonFile, rawJSON)
+		}
+		if plate == "XML1" && !strings.Contains(rawJSON, `"ImageFile":"/var/lpr/1.jpg"`) {
+			t.Errorf("unknown element dropped: %s", rawJSON)
+		}
+	}
+	if want := []string{"XML1 Skoda plate,vehicle", "XML2  plate"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored %q, want %q", got, want)
+	}
+}